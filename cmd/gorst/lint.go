@@ -0,0 +1,444 @@
+package main
+
+/*
+The lint command: parse documents without writing output, and report the
+system messages and the problems found by additional checks.
+
+Usage:
+
+	gorst lint [options] [source ...]
+
+	--format=FORMAT  human (default), json, sarif (SARIF 2.1.0) or github
+	                 (GitHub Actions workflow commands, shown as annotations)
+	--report=LEVEL   report problems at or above LEVEL (default: info)
+	--parser=NAME    input format (default: from the source name)
+	--config=FILE    read this configuration file, after the standard ones
+
+The checks, besides the system messages of the parser and transforms:
+
+  - unused-target: hyperlink targets no reference refers to, by name or id;
+  - unreferenced-footnote: footnotes without footnote references;
+  - duplicate-implicit-target: sections with the same title;
+  - title-levels: section title adornment styles used inconsistently;
+  - trailing-whitespace: lines ending with whitespace.
+
+The first three run on the parsed document, title-levels reports the
+messages of the reStructuredText parser on section titles, and
+trailing-whitespace runs on the lines of reStructuredText sources. The
+exit status is the highest level of the
+problems reported if it is a warning or above, 0 otherwise.
+*/
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+
+	rst "github.com/siongui/go-rst"
+	"github.com/siongui/go-rst/core"
+)
+
+const lintUsage = `usage: gorst lint [options] [source ...]
+
+Check the sources (default: standard input) and report the problems found.
+
+Options:
+`
+
+// A check of the lint command.
+type rule struct {
+	id, description string
+}
+
+var rules = []rule{
+	{"system-message", "System message of the parser or of a transform."},
+	{"unused-target", "Hyperlink target not referenced."},
+	{"unreferenced-footnote", "Footnote without footnote reference."},
+	{"duplicate-implicit-target", "Sections with the same title, which cannot be referenced by name."},
+	{"title-levels", "Section title adornment styles used inconsistently."},
+	{"trailing-whitespace", "Line ending with whitespace."},
+}
+
+/*
+   System messages replaced by checks: the checks give the same
+   information, with the references by id taken into account.
+*/
+var replacedMessages = []string{" is not referenced.", "Duplicate implicit target name: "}
+
+// A problem found in a document.
+type diagnostic struct {
+	Source  string `json:"source"`
+	Line    int    `json:"line,omitempty"`
+	Level   int    `json:"level"`
+	Type    string `json:"type"`
+	Rule    string `json:"rule"`
+	Message string `json:"message"`
+}
+
+// Collects the diagnostics of the documents.
+type linter struct {
+	diagnostics []diagnostic
+}
+
+func (l *linter) report(source string, line, level int, ruleID, message string) {
+	l.diagnostics = append(l.diagnostics, diagnostic{source, line, level, rst.Levels[level], ruleID, message})
+}
+
+// Report the problems of the system message `message`.
+func (l *linter) systemMessage(message *rst.Element) {
+	text := ""
+	if len(message.Children()) > 0 {
+		text = message.Children()[0].AsText()
+	}
+	for _, replaced := range replacedMessages {
+		if strings.Contains(text, replaced) {
+			return
+		}
+	}
+	level, _ := strconv.Atoi(message.Get("level"))
+	line, _ := strconv.Atoi(message.Get("line"))
+	if strings.HasPrefix(text, "Title level inconsistent:") && len(message.Children()) > 1 {
+		// the title is quoted in the literal block, with its adornment
+		for _, title := range strings.Split(message.Children()[1].AsText(), "\n") {
+			if strings.TrimSpace(title) != "" && !isAdornment(title) {
+				l.report(message.Get("source"), line, level, "title-levels",
+					"Title level inconsistent: \""+strings.TrimSpace(title)+"\".")
+				return
+			}
+		}
+	}
+	l.report(message.Get("source"), line, level, "system-message", text)
+}
+
+// Return the source of `node`, `document`'s if unknown.
+func nodeSource(document *rst.Document, node *rst.Element) string {
+	if node.Source() != "" {
+		return node.Source()
+	}
+	return document.Source()
+}
+
+// Run the checks of the document tree `document`.
+func (l *linter) checkDocument(document *rst.Document) {
+	referencedIDs := map[string]bool{}
+	referencedNames := map[string]bool{}
+	for _, node := range document.Traverse(nil) {
+		if e, ok := node.(*rst.Element); ok {
+			if e.HasAttr("refid") {
+				referencedIDs[e.Get("refid")] = true
+			}
+			if e.HasAttr("refname") {
+				referencedNames[e.Get("refname")] = true
+			}
+		}
+	}
+
+	for _, node := range document.Traverse(rst.ByTag("target", "footnote", "section")) {
+		e := node.(*rst.Element)
+		referenced := false
+		for _, id := range e.Ids {
+			referenced = referenced || referencedIDs[id]
+		}
+		for _, name := range e.Names {
+			referenced = referenced || referencedNames[name]
+		}
+		switch e.TagName() {
+		case "target":
+			if referenced || e.Referenced || e.HasAttr("anonymous") || e.HasAttr("refid") && len(e.Names) == 0 {
+				// propagated targets are reported with the element
+				// they were propagated to
+				continue
+			}
+			naming := e.Get("refid")
+			if len(e.Names) > 0 {
+				naming = e.Names[0]
+			} else if len(e.Ids) > 0 {
+				naming = e.Ids[0]
+			}
+			l.report(nodeSource(document, e), e.Line(), rst.InfoLevel, "unused-target",
+				"Hyperlink target \""+naming+"\" is not referenced.")
+		case "footnote":
+			if !referenced && len(e.Backrefs) == 0 {
+				label := ""
+				for _, child := range e.Children() {
+					if child.TagName() == "label" {
+						label = child.AsText()
+					}
+				}
+				l.report(nodeSource(document, e), e.Line(), rst.WarningLevel, "unreferenced-footnote",
+					"Footnote ["+label+"] is not referenced.")
+			}
+		case "section":
+			for _, name := range e.Dupnames {
+				l.report(nodeSource(document, e), e.Line(), rst.InfoLevel, "duplicate-implicit-target",
+					"Duplicate implicit target name: \""+name+"\".")
+			}
+		}
+	}
+}
+
+// Is `line` a section title adornment (a repeated punctuation character)?
+func isAdornment(line string) bool {
+	line = strings.TrimRight(line, " \t")
+	if len(line) < 2 || line[0] > unicode.MaxASCII || !unicode.IsPunct(rune(line[0])) && !unicode.IsSymbol(rune(line[0])) {
+		return false
+	}
+	return strings.Count(line, line[:1]) == len(line)
+}
+
+// Run the checks of the reStructuredText source lines `lines`.
+func (l *linter) checkLines(lines *rst.StringList) {
+	for i := 0; i < lines.Length(); i++ {
+		line, _ := lines.GetItem(i)
+		if trimmed := strings.TrimRight(line, " \t"); trimmed != line {
+			source, _ := lines.Source(i)
+			offset, _ := lines.Offset(i)
+			l.report(source, offset+1, rst.InfoLevel, "trailing-whitespace", "Trailing whitespace.")
+		}
+	}
+}
+
+// Return the source lines of `text`, read from `sourcePath`.
+func sourceLines(text, sourcePath string) *rst.StringList {
+	split := strings.Split(strings.Replace(text, "\r\n", "\n", -1), "\n")
+	if len(split) > 0 && split[len(split)-1] == "" {
+		split = split[:len(split)-1]
+	}
+	lines := &rst.StringList{}
+	lines.Init(split, sourcePath, nil, nil, 0)
+	return lines
+}
+
+// Replace the characters of `s` that GitHub workflow commands reserve.
+func escapeWorkflowCommand(s string, property bool) string {
+	s = strings.NewReplacer("%", "%25", "\r", "%0D", "\n", "%0A").Replace(s)
+	if property {
+		s = strings.NewReplacer(":", "%3A", ",", "%2C").Replace(s)
+	}
+	return s
+}
+
+// Write `diagnostics` to `w` in the format `format`.
+func writeDiagnostics(w io.Writer, diagnostics []diagnostic, format string) error {
+	switch format {
+	case "json":
+		if diagnostics == nil {
+			diagnostics = []diagnostic{}
+		}
+		data, err := json.MarshalIndent(diagnostics, "", "  ")
+		if err != nil {
+			return err
+		}
+		_, err = fmt.Fprintf(w, "%s\n", data)
+		return err
+	case "sarif":
+		return writeSARIF(w, diagnostics)
+	}
+	for _, d := range diagnostics {
+		location := d.Source
+		if d.Line > 0 {
+			location += ":" + strconv.Itoa(d.Line)
+		}
+		if format == "github" {
+			command := map[int]string{rst.InfoLevel: "notice", rst.WarningLevel: "warning"}[d.Level]
+			if d.Level >= rst.ErrorLevel {
+				command = "error"
+			}
+			properties := "file=" + escapeWorkflowCommand(d.Source, true)
+			if d.Line > 0 {
+				properties += ",line=" + strconv.Itoa(d.Line)
+			}
+			properties += ",title=" + escapeWorkflowCommand(d.Rule, true)
+			fmt.Fprintf(w, "::%s %s::%s\n", command, properties, escapeWorkflowCommand(d.Message, false))
+		} else {
+			fmt.Fprintf(w, "%s: (%s/%d) %s [%s]\n", location, d.Type, d.Level, d.Message, d.Rule)
+		}
+	}
+	return nil
+}
+
+// Write `diagnostics` to `w` as a SARIF 2.1.0 log.
+func writeSARIF(w io.Writer, diagnostics []diagnostic) error {
+	type message struct {
+		Text string `json:"text"`
+	}
+	type region struct {
+		StartLine int `json:"startLine"`
+	}
+	type artifactLocation struct {
+		URI string `json:"uri"`
+	}
+	type physicalLocation struct {
+		ArtifactLocation artifactLocation `json:"artifactLocation"`
+		Region           *region          `json:"region,omitempty"`
+	}
+	type location struct {
+		PhysicalLocation physicalLocation `json:"physicalLocation"`
+	}
+	type result struct {
+		RuleID    string     `json:"ruleId"`
+		RuleIndex int        `json:"ruleIndex"`
+		Level     string     `json:"level"`
+		Message   message    `json:"message"`
+		Locations []location `json:"locations"`
+	}
+	type reportingDescriptor struct {
+		ID               string  `json:"id"`
+		ShortDescription message `json:"shortDescription"`
+	}
+
+	ruleIndex := map[string]int{}
+	var descriptors []reportingDescriptor
+	for i, r := range rules {
+		ruleIndex[r.id] = i
+		descriptors = append(descriptors, reportingDescriptor{r.id, message{r.description}})
+	}
+	results := []result{}
+	for _, d := range diagnostics {
+		level := "note"
+		if d.Level == rst.WarningLevel {
+			level = "warning"
+		} else if d.Level >= rst.ErrorLevel {
+			level = "error"
+		}
+		loc := physicalLocation{ArtifactLocation: artifactLocation{filepath.ToSlash(d.Source)}}
+		if d.Line > 0 {
+			loc.Region = &region{d.Line}
+		}
+		results = append(results, result{d.Rule, ruleIndex[d.Rule], level, message{d.Message}, []location{{loc}}})
+	}
+
+	log := map[string]interface{}{
+		"$schema": "https://json.schemastore.org/sarif-2.1.0.json",
+		"version": "2.1.0",
+		"runs": []interface{}{map[string]interface{}{
+			"tool": map[string]interface{}{"driver": map[string]interface{}{
+				"name":           "gorst lint",
+				"informationUri": "https://github.com/siongui/go-rst",
+				"rules":          descriptors,
+			}},
+			"results": results,
+		}},
+	}
+	data, err := json.MarshalIndent(log, "", "  ")
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(w, "%s\n", data)
+	return err
+}
+
+// Run the lint command with the arguments `args` and return the exit status.
+func lint(args []string, stdin io.Reader, stdout, stderr io.Writer) int {
+	fail := func(err error) int {
+		fmt.Fprintln(stderr, "gorst lint:", err)
+		return exitFailure
+	}
+	var configFiles stringList
+	flags := flag.NewFlagSet("gorst lint", flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	flags.Usage = func() {
+		fmt.Fprint(stderr, lintUsage)
+		flags.PrintDefaults()
+	}
+	parserName := flags.String("parser", "", "input format: xml, restructuredtext (default: from the source name)")
+	flags.Var(&configFiles, "config", "read this configuration file, after the standard ones")
+	format := flags.String("format", "human", "output format: human, json, sarif, github")
+	report := flags.String("report", "info", "report problems at or above this level")
+	sources, err := parseInterspersed(flags, args)
+	if err == flag.ErrHelp {
+		return 0
+	}
+	if err != nil {
+		return exitFailure
+	}
+	switch *format {
+	case "human", "json", "sarif", "github":
+	default:
+		return fail(fmt.Errorf("unknown format %q", *format))
+	}
+	// the report level setting validates the level
+	levels := &rst.Settings{}
+	if err := levels.Set("report_level", *report); err != nil {
+		return fail(err)
+	}
+	if len(sources) == 0 {
+		sources = []string{"-"}
+	}
+
+	l := &linter{}
+	for _, source := range sources {
+		start := len(l.diagnostics)
+		name := *parserName
+		if name == "" {
+			name = defaultParser(source)
+		}
+		settings := &rst.Settings{}
+		settings.Init()
+		sections := core.ConfigSections("standalone", name, "")
+		if err := settings.ReadConfigFiles(append(rst.ConfigFiles(), configFiles...), sections); err != nil {
+			return fail(err)
+		}
+		// collect all the messages, without writing them
+		settings.WarningStream = nil
+		settings.HaltLevel = rst.SevereLevel + 1
+
+		data, err := readSource(source, stdin)
+		if err != nil {
+			return fail(err)
+		}
+		text, err := core.Decode(data, settings.InputEncoding)
+		if err != nil {
+			return fail(err)
+		}
+		sourcePath := source
+		if source == "-" {
+			sourcePath = "<stdin>"
+		}
+		if name == "restructuredtext" || name == "rst" {
+			l.checkLines(sourceLines(text, sourcePath))
+		}
+
+		publisher := &core.Publisher{Settings: settings}
+		if err := publisher.SetComponents("standalone", name, ""); err != nil {
+			return fail(err)
+		}
+		document, messages, err := publisher.PublishDoctree(text, sourcePath)
+		for _, message := range messages {
+			l.systemMessage(message)
+		}
+		if err != nil {
+			return fail(err)
+		}
+		l.checkDocument(document)
+
+		// in the order of the lines of each source
+		found := l.diagnostics[start:]
+		sort.SliceStable(found, func(i, j int) bool {
+			return found[i].Line < found[j].Line
+		})
+	}
+
+	var diagnostics []diagnostic
+	for _, d := range l.diagnostics {
+		if d.Level >= levels.ReportLevel {
+			diagnostics = append(diagnostics, d)
+		}
+	}
+	if err := writeDiagnostics(stdout, diagnostics, *format); err != nil {
+		return fail(err)
+	}
+	status := 0
+	for _, d := range diagnostics {
+		if d.Level >= rst.WarningLevel && d.Level > status {
+			status = d.Level
+		}
+	}
+	return status
+}
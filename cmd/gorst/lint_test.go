@@ -0,0 +1,141 @@
+package main
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+const lintSource = `=====
+Title
+=====
+
+Section ` + `
+-------
+
+Subsection
+~~~~~~~~~~
+
+Other
+-----
+
+Bad
+===
+
+Worse
+^^^^^
+
++++++
+Worst
++++++
+`
+
+const lintXML = `<document source="doc.xml">
+    <section dupnames="intro" ids="intro">
+        <title>Intro</title>
+        <paragraph>See <reference refid="used">this</reference>.</paragraph>
+        <target ids="used" names="used"/>
+        <paragraph>Used target.</paragraph>
+        <target ids="unused" names="unused" refuri="https://example.com"/>
+        <footnote ids="note" names="1"><label>1</label><paragraph>Never cited.</paragraph></footnote>
+    </section>
+    <section dupnames="intro" ids="intro-1">
+        <title>Intro</title>
+    </section>
+</document>
+`
+
+func TestLintSource(t *testing.T) {
+	dir := t.TempDir()
+	source := filepath.Join(dir, "doc.rst")
+	if err := ioutil.WriteFile(source, []byte(lintSource), 0644); err != nil {
+		t.Fatal(err)
+	}
+	status, stdout, stderr := runCommand(t, "", "lint", source)
+	expected := source + ":5: (INFO/1) Trailing whitespace. [trailing-whitespace]\n" +
+		source + ":14: (SEVERE/4) Title level inconsistent: \"Bad\". [title-levels]\n" +
+		source + ":17: (SEVERE/4) Title level inconsistent: \"Worse\". [title-levels]\n" +
+		source + ":21: (SEVERE/4) Title level inconsistent: \"Worst\". [title-levels]\n"
+	if status != 4 || stdout != expected {
+		t.Errorf("exit status %d, output:\n%s", status, stdout)
+	}
+	if stderr != "" {
+		t.Errorf("unexpected errors %q", stderr)
+	}
+
+	status, stdout, _ = runCommand(t, "", "lint", "--report=warning", "--format=github", source)
+	if status != 4 || !strings.HasPrefix(stdout, "::error file="+strings.Replace(source, ":", "%3A", -1)+",line=14,title=title-levels::Title level") {
+		t.Errorf("exit status %d, output:\n%s", status, stdout)
+	}
+}
+
+func TestLintDocument(t *testing.T) {
+	status, stdout, _ := runCommand(t, lintXML, "lint", "--parser=xml", "--format=json")
+	var diagnostics []diagnostic
+	if err := json.Unmarshal([]byte(stdout), &diagnostics); err != nil {
+		t.Fatal(err)
+	}
+	var found []string
+	for _, d := range diagnostics {
+		found = append(found, d.Rule+" "+d.Type+" "+d.Message)
+	}
+	expected := []string{
+		"duplicate-implicit-target INFO Duplicate implicit target name: \"intro\".",
+		"unused-target INFO Hyperlink target \"unused\" is not referenced.",
+		"unreferenced-footnote WARNING Footnote [1] is not referenced.",
+		"duplicate-implicit-target INFO Duplicate implicit target name: \"intro\".",
+	}
+	if status != 2 || strings.Join(found, "\n") != strings.Join(expected, "\n") {
+		t.Errorf("exit status %d, diagnostics:\n%s", status, strings.Join(found, "\n"))
+	}
+
+	status, stdout, _ = runCommand(t, lintXML, "lint", "--parser=xml", "--format=sarif", "--report=warning")
+	var log struct {
+		Version string
+		Runs    []struct {
+			Tool struct {
+				Driver struct {
+					Rules []struct{ ID string }
+				}
+			}
+			Results []struct {
+				RuleID    string
+				RuleIndex int
+				Level     string
+				Locations []struct {
+					PhysicalLocation struct {
+						ArtifactLocation struct{ URI string }
+					}
+				}
+			}
+		}
+	}
+	if err := json.Unmarshal([]byte(stdout), &log); err != nil {
+		t.Fatal(err)
+	}
+	if log.Version != "2.1.0" || len(log.Runs) != 1 || len(log.Runs[0].Results) != 1 {
+		t.Fatalf("unexpected SARIF log:\n%s", stdout)
+	}
+	result := log.Runs[0].Results[0]
+	if result.RuleID != "unreferenced-footnote" || log.Runs[0].Tool.Driver.Rules[result.RuleIndex].ID != result.RuleID ||
+		result.Level != "warning" || result.Locations[0].PhysicalLocation.ArtifactLocation.URI != "<stdin>" {
+		t.Errorf("unexpected SARIF result:\n%s", stdout)
+	}
+}
+
+func TestLintUsage(t *testing.T) {
+	for _, args := range [][]string{
+		{"lint", "--format=xml"},
+		{"lint", "--report=loud"},
+		{"lint", "missing.rst"},
+	} {
+		if status, _, stderr := runCommand(t, "", args...); status != exitFailure || stderr == "" {
+			t.Errorf("%q: exit status %d, errors %q", args, status, stderr)
+		}
+	}
+	if status, stdout, _ := runCommand(t, "Clean\n=====\n", "lint", "--format=json", "-"); status != 0 || stdout != "[]\n" {
+		t.Errorf("exit status %d, output %q", status, stdout)
+	}
+}
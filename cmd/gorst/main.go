@@ -0,0 +1,280 @@
+/*
+Command gorst converts documents, like the rst2html, rst2latex, ... front-end
+tools of Python docutils.
+
+Usage:
+
+	gorst command [options] [source [destination]]
+
+The command names the output format: html, latex, man, xml, pseudoxml,
+text, markdown (or any other writer name known to `core.GetWriter()`, e.g.
+s5 or epub). The source and destination default to the standard input and
+output ("-" is accepted for both).
+
+The parser is chosen with the --parser option, by default from the source
+file name: "xml" for Docutils XML (".xml"), "restructuredtext" otherwise.
+
+Settings are read from the docutils.conf configuration files (see
+`rst.ConfigFiles()`), then from the --config files, then from the options:
+
+	--report=LEVEL     report system messages at or above LEVEL (a level
+	                   number, or info, warning, error, severe, none)
+	--halt=LEVEL       halt at system messages at or above LEVEL
+	--strict           halt at the slightest problem (--halt=info)
+	--stylesheet=URLS  comma-separated style sheet URLs (HTML)
+	--template=PATH    template of the output (HTML and LaTeX)
+	--tab-width=N      number of spaces for a hard tab in the input
+
+The lint command checks documents instead, see lint.go.
+
+System messages are written to the standard error. The exit status is the
+highest system message level if it is a warning or above (2: warning,
+3: error, 4: severe), 0 otherwise, and 1 for usage and I/O errors.
+
+URL of Python source code:
+http://sourceforge.net/p/docutils/code/HEAD/tree/trunk/docutils/tools/
+*/
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"strconv"
+	"strings"
+
+	rst "github.com/siongui/go-rst"
+	"github.com/siongui/go-rst/core"
+	"github.com/siongui/go-rst/writers/latex"
+)
+
+const usage = `usage: gorst command [options] [source [destination]]
+
+Convert source (default: standard input) and write the result to
+destination (default: standard output).
+
+Commands: html, latex, man, xml, pseudoxml, text, markdown, and lint to
+check documents without writing output (see "gorst lint -h")
+
+Options:
+`
+
+// Exit status of usage and I/O errors.
+const exitFailure = 1
+
+// A command-line option overriding a setting, applied after the
+// configuration files.
+type override struct {
+	name, value string
+}
+
+// Collects the options overriding settings, in command-line order.
+type overrides []override
+
+// A `flag.Value` setting the setting `name` of `o`.
+type settingFlag struct {
+	o    *overrides
+	name string
+}
+
+func (f settingFlag) String() string {
+	return ""
+}
+
+func (f settingFlag) Set(value string) error {
+	*f.o = append(*f.o, override{f.name, value})
+	return nil
+}
+
+// Collects the --config options.
+type stringList []string
+
+func (l *stringList) String() string {
+	return strings.Join(*l, ",")
+}
+
+func (l *stringList) Set(value string) error {
+	*l = append(*l, value)
+	return nil
+}
+
+/*
+   Parse the options of `flags` and return the positional arguments;
+   options may follow positional arguments, as in Python docutils.
+*/
+func parseInterspersed(flags *flag.FlagSet, args []string) ([]string, error) {
+	var positional []string
+	for {
+		if err := flags.Parse(args); err != nil {
+			return nil, err
+		}
+		args = flags.Args()
+		if len(args) == 0 {
+			return positional, nil
+		}
+		if args[0] == "--" {
+			return append(positional, args[1:]...), nil
+		}
+		positional = append(positional, args[0])
+		args = args[1:]
+	}
+}
+
+// Return the highest level of `messages`, -1 if there are none.
+func maxLevel(messages []*rst.Element) int {
+	level := -1
+	for _, message := range messages {
+		if l, err := strconv.Atoi(message.Get("level")); err == nil && l > level {
+			level = l
+		}
+	}
+	return level
+}
+
+// Return the exit status for the system messages `messages`.
+func exitStatus(messages []*rst.Element) int {
+	if level := maxLevel(messages); level >= rst.WarningLevel {
+		return level
+	}
+	return 0
+}
+
+// Read the source `path` ("-" for `stdin`).
+func readSource(path string, stdin io.Reader) ([]byte, error) {
+	if path == "-" {
+		return ioutil.ReadAll(stdin)
+	}
+	return ioutil.ReadFile(path)
+}
+
+// Return the default parser name for the source `path`.
+func defaultParser(path string) string {
+	if strings.HasSuffix(strings.ToLower(path), ".xml") {
+		return "xml"
+	}
+	return "restructuredtext"
+}
+
+/*
+   Run the command-line arguments `args` (without the program name) and
+   return the exit status.
+*/
+func run(args []string, stdin io.Reader, stdout, stderr io.Writer) int {
+	fail := func(err error) int {
+		fmt.Fprintln(stderr, "gorst:", err)
+		return exitFailure
+	}
+	if len(args) == 0 {
+		fmt.Fprint(stderr, usage)
+		return exitFailure
+	}
+	if args[0] == "-h" || args[0] == "--help" || args[0] == "help" {
+		fmt.Fprint(stdout, usage)
+		return 0
+	}
+	command := args[0]
+	if command == "lint" {
+		return lint(args[1:], stdin, stdout, stderr)
+	}
+
+	var settingOverrides overrides
+	var configFiles stringList
+	flags := flag.NewFlagSet("gorst "+command, flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	flags.Usage = func() {
+		fmt.Fprint(stderr, usage)
+		flags.PrintDefaults()
+	}
+	parserName := flags.String("parser", "", "input format: xml, restructuredtext (default: from the source name)")
+	flags.Var(&configFiles, "config", "read this configuration file, after the standard ones")
+	flags.Var(settingFlag{&settingOverrides, "report_level"}, "report", "report system messages at or above this level")
+	flags.Var(settingFlag{&settingOverrides, "halt_level"}, "halt", "halt at system messages at or above this level")
+	flags.Var(settingFlag{&settingOverrides, "stylesheet"}, "stylesheet", "comma-separated style sheet URLs")
+	flags.Var(settingFlag{&settingOverrides, "template"}, "template", "template file of the output")
+	flags.Var(settingFlag{&settingOverrides, "tab_width"}, "tab-width", "number of spaces for a hard tab")
+	strict := flags.Bool("strict", false, "halt at the slightest problem")
+	positional, err := parseInterspersed(flags, args[1:])
+	if err == flag.ErrHelp {
+		return 0
+	}
+	if err != nil {
+		return exitFailure
+	}
+	if len(positional) > 2 {
+		flags.Usage()
+		return exitFailure
+	}
+	source, destination := "-", "-"
+	if len(positional) > 0 {
+		source = positional[0]
+	}
+	if len(positional) > 1 {
+		destination = positional[1]
+	}
+	if *parserName == "" {
+		*parserName = defaultParser(source)
+	}
+
+	publisher := &core.Publisher{}
+	if err := publisher.SetComponents("standalone", *parserName, command); err != nil {
+		return fail(err)
+	}
+	settings := &rst.Settings{}
+	settings.Init()
+	settings.WarningStream = stderr
+	sections := core.ConfigSections("standalone", *parserName, command)
+	if err := settings.ReadConfigFiles(append(rst.ConfigFiles(), configFiles...), sections); err != nil {
+		return fail(err)
+	}
+	for _, o := range settingOverrides {
+		name := o.name
+		if _, ok := publisher.Writer.(*latex.Writer); ok && name == "template" {
+			name = "latex_template"
+		}
+		if err := settings.Set(name, o.value); err != nil {
+			return fail(err)
+		}
+	}
+	if *strict {
+		settings.HaltLevel = rst.InfoLevel
+	}
+	publisher.Settings = settings
+
+	data, err := readSource(source, stdin)
+	if err != nil {
+		return fail(err)
+	}
+	text, err := core.Decode(data, settings.InputEncoding)
+	if err != nil {
+		return fail(err)
+	}
+	sourcePath := source
+	if source == "-" {
+		sourcePath = "<stdin>"
+	}
+	output, messages, err := publisher.PublishString(text, sourcePath)
+	if _, halted := err.(*rst.SystemMessageError); halted && maxLevel(messages) >= 0 {
+		level := maxLevel(messages)
+		fmt.Fprintf(stderr, "gorst: exiting due to level-%d (%s) system message.\n", level, rst.Levels[level])
+		return level
+	}
+	if err != nil {
+		return fail(err)
+	}
+
+	if destination == "-" {
+		_, err = stdout.Write(output)
+	} else {
+		err = ioutil.WriteFile(destination, output, 0644)
+	}
+	if err != nil {
+		return fail(err)
+	}
+	return exitStatus(messages)
+}
+
+func main() {
+	os.Exit(run(os.Args[1:], os.Stdin, os.Stdout, os.Stderr))
+}
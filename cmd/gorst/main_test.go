@@ -0,0 +1,120 @@
+package main
+
+import (
+	"bytes"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+const input = `<document source="in.xml">
+    <title>Title</title>
+    <paragraph>A <reference name="link" refname="nowhere">link</reference>.</paragraph>
+</document>
+`
+
+// Run the command `args` on the standard input `stdin`.
+func runCommand(t *testing.T, stdin string, args ...string) (status int, stdout, stderr string) {
+	// no configuration files but those of the tests
+	t.Setenv("DOCUTILSCONFIG", "")
+	var out, errOut bytes.Buffer
+	status = run(args, strings.NewReader(stdin), &out, &errOut)
+	return status, out.String(), errOut.String()
+}
+
+func TestConvert(t *testing.T) {
+	status, stdout, stderr := runCommand(t, input, "html", "--parser=xml", "-", "--stylesheet", "a.css")
+	if status != 3 {
+		t.Errorf("exit status %d, expected 3 (error)", status)
+	}
+	if !strings.Contains(stdout, `<link rel="stylesheet" href="a.css" type="text/css" />`) ||
+		!strings.Contains(stdout, "<h1 class=\"title\">Title</h1>") {
+		t.Errorf("unexpected output:\n%s", stdout)
+	}
+	if stderr != "<stdin>:: (ERROR/3) Unknown target name: \"nowhere\".\n" {
+		t.Errorf("unexpected system messages:\n%s", stderr)
+	}
+
+	status, _, stderr = runCommand(t, input, "pseudoxml", "--parser", "xml", "--report", "none")
+	if status != 3 || stderr != "" {
+		t.Errorf("exit status %d, system messages %q", status, stderr)
+	}
+
+	status, _, stderr = runCommand(t, strings.Replace(input, "refname=\"nowhere\"", "refuri=\"x\"", 1), "text", "--parser=xml")
+	if status != 0 || stderr != "" {
+		t.Errorf("exit status %d, system messages %q", status, stderr)
+	}
+
+	// reStructuredText, the default for the standard input
+	status, stdout, stderr = runCommand(t, "=====\nTitle\n=====\n\nA link_.\n", "html")
+	if status != 3 || !strings.Contains(stdout, "<h1 class=\"title\">Title</h1>") {
+		t.Errorf("exit status %d, output:\n%s", status, stdout)
+	}
+	if stderr != "<stdin>:5: (ERROR/3) Unknown target name: \"link\".\n" {
+		t.Errorf("unexpected system messages:\n%s", stderr)
+	}
+}
+
+func TestHalt(t *testing.T) {
+	for _, args := range [][]string{{"--halt=error"}, {"--strict"}} {
+		status, stdout, stderr := runCommand(t, input, append([]string{"xml", "--parser=xml"}, args...)...)
+		if status != 3 || stdout != "" || !strings.HasSuffix(stderr, "gorst: exiting due to level-3 (ERROR) system message.\n") {
+			t.Errorf("%s: exit status %d, output %q, system messages %q", args, status, stdout, stderr)
+		}
+	}
+}
+
+func TestFiles(t *testing.T) {
+	dir := t.TempDir()
+	source := filepath.Join(dir, "in.xml")
+	destination := filepath.Join(dir, "out.html")
+	config := filepath.Join(dir, "docutils.conf")
+	if err := ioutil.WriteFile(source, []byte(input), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(config, []byte("[general]\nreport_level: severe\n[html writers]\nstylesheet: config.css\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	status, stdout, stderr := runCommand(t, "", "html", "--config", config, source, destination)
+	if status != 3 || stdout != "" || stderr != "" {
+		t.Errorf("exit status %d, output %q, system messages %q", status, stdout, stderr)
+	}
+	output, err := ioutil.ReadFile(destination)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(output), `href="config.css"`) {
+		t.Errorf("configuration file not read:\n%s", output)
+	}
+
+	if err := ioutil.WriteFile(config, []byte("[general]\ntab_width: -1\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	status, _, stderr = runCommand(t, "", "html", "--config", config, source, destination)
+	if status != exitFailure || !strings.Contains(stderr, config+":2: [general] invalid value for \"tab_width\"") {
+		t.Errorf("exit status %d, errors %q", status, stderr)
+	}
+}
+
+func TestUsage(t *testing.T) {
+	for _, args := range [][]string{
+		{},
+		{"nonsense"},
+		{"html", "--tab-width=many", "-"},
+		{"html", "a.xml", "b.html", "c"},
+		{"html", "--no-such-option"},
+		{"html", "missing.xml"},
+	} {
+		if status, _, stderr := runCommand(t, input, args...); status != exitFailure || stderr == "" {
+			t.Errorf("%q: exit status %d, errors %q", args, status, stderr)
+		}
+	}
+
+	// help requested
+	for _, args := range [][]string{{"-h"}, {"--help"}, {"help"}, {"html", "-h"}} {
+		if status, stdout, stderr := runCommand(t, input, args...); status != 0 || stdout+stderr == "" {
+			t.Errorf("%q: exit status %d", args, status)
+		}
+	}
+}
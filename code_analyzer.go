@@ -0,0 +1,368 @@
+package rst
+
+/*
+Lexical analysis of formal languages (i.e. code) for the "code" directive
+and role, as in Python docutils utils/code_analyzer.py
+
+URL of Python source code:
+http://sourceforge.net/p/docutils/code/HEAD/tree/trunk/docutils/docutils/utils/code_analyzer.py
+
+Python docutils uses Pygments; here the tokenizer is pluggable with the
+`Highlighter` interface. Token types use the Pygments names (which Chroma
+shares), so an adapter for Chroma or another library only needs to pass
+the token type names through:
+
+	type chromaHighlighter struct{}
+
+	func (chromaHighlighter) Tokenize(code, language string) ([]rst.Token, error) {
+		lexer := lexers.Get(language)
+		if lexer == nil {
+			return nil, &rst.LexerError{...}
+		}
+		iterator, err := lexer.Tokenise(nil, code)
+		...
+		tokens = append(tokens, rst.Token{Type: t.Type.String(), Value: t.Value})
+	}
+
+The built-in `DefaultHighlighter` knows a few languages only.
+*/
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"unicode/utf8"
+)
+
+// A piece of code with its Pygments token type name, e.g.
+// "Keyword.Constant". Plain text has type "Text" or "".
+type Token struct {
+	Type  string
+	Value string
+}
+
+// A piece of code with the classes of the `inline` element that will hold
+// it. No classes means plain text.
+type ClassedToken struct {
+	Classes []string
+	Value   string
+}
+
+/*
+   Splits code into typed tokens. `Tokenize()` returns a `LexerError` if
+   `language` is not supported.
+*/
+type Highlighter interface {
+	Tokenize(code, language string) ([]Token, error)
+}
+
+/*
+   Parse `code` lines and yield "classified" tokens.
+
+   # Arguments
+
+   - `code`: string of source code to parse
+   - `language`: formal language the code is written in
+   - `tokennames`: either "long", "short", or "none" (see below)
+   - `highlighter`: the tokenizer to use, `DefaultHighlighter` if nil
+
+   Merge subsequent tokens of the same token-type.
+
+   Return the tokens as (classes, value) pairs, where `classes` is a list
+   of strings, e.g. ["keyword", "constant"] ("long" tokennames) or ["kc"]
+   ("short" tokennames, the short CSS class names of Pygments styles).
+   With "none", or if `language` is "" or "text", the code is returned as
+   one plain token.
+*/
+func AnalyzeCode(code, language, tokennames string, highlighter Highlighter) ([]ClassedToken, error) {
+	if language == "" || language == "text" || tokennames == "none" {
+		return []ClassedToken{{nil, code}}, nil
+	}
+	if highlighter == nil {
+		highlighter = DefaultHighlighter
+	}
+	tokens, err := highlighter.Tokenize(code, language)
+	if err != nil {
+		return nil, err
+	}
+	var result []ClassedToken
+	for _, token := range mergeTokens(tokens) {
+		var classes []string
+		if tokennames == "long" {
+			for _, cls := range strings.Split(strings.ToLower(token.Type), ".") {
+				if cls != "token" && cls != "text" && cls != "" {
+					classes = append(classes, cls)
+				}
+			}
+		} else if cls := ttypeClass(token.Type); cls != "" {
+			classes = []string{cls}
+		}
+		result = append(result, ClassedToken{classes, token.Value})
+	}
+	return result, nil
+}
+
+// Merge subsequent tokens of same token-type and drop empty ones. Token
+// types are normalized: "String" and "Number" are shortcuts for
+// "Literal.String" and "Literal.Number".
+func mergeTokens(tokens []Token) []Token {
+	var result []Token
+	for _, token := range tokens {
+		ttype := strings.TrimPrefix(token.Type, "Token.")
+		if ttype == "Token" {
+			ttype = ""
+		}
+		if strings.HasPrefix(ttype, "String") || strings.HasPrefix(ttype, "Number") {
+			ttype = "Literal." + ttype
+		}
+		if token.Value == "" {
+			continue
+		}
+		if len(result) > 0 && result[len(result)-1].Type == ttype {
+			result[len(result)-1].Value += token.Value
+			continue
+		}
+		result = append(result, Token{ttype, token.Value})
+	}
+	return result
+}
+
+/*
+   Insert linenumber-tokens at the start of every code line.
+
+   # Arguments
+
+   - `tokens`: the classified tokens of the code
+   - `startline`: first line number
+   - `endline`: last line number (used to pad the line numbers)
+*/
+func NumberLines(tokens []ClassedToken, startline, endline int) []ClassedToken {
+	// pad linenumbers, e.g. endline == 100 -> fmtStr = "%3d "
+	fmtStr := fmt.Sprintf("%%%dd ", len(fmt.Sprint(endline)))
+	lineno := startline
+	result := []ClassedToken{{[]string{"ln"}, fmt.Sprintf(fmtStr, lineno)}}
+	for _, token := range tokens {
+		lines := strings.Split(token.Value, "\n")
+		for _, line := range lines[:len(lines)-1] {
+			result = append(result, ClassedToken{token.Classes, line + "\n"})
+			lineno++
+			result = append(result, ClassedToken{[]string{"ln"}, fmt.Sprintf(fmtStr, lineno)})
+		}
+		result = append(result, ClassedToken{token.Classes, lines[len(lines)-1]})
+	}
+	return result
+}
+
+// Short CSS class names of the Pygments token types (pygments.token).
+var standardTypes = map[string]string{
+	"":                        "",
+	"Text":                    "",
+	"Whitespace":              "w",
+	"Escape":                  "esc",
+	"Error":                   "err",
+	"Other":                   "x",
+	"Keyword":                 "k",
+	"Keyword.Constant":        "kc",
+	"Keyword.Declaration":     "kd",
+	"Keyword.Namespace":       "kn",
+	"Keyword.Pseudo":          "kp",
+	"Keyword.Reserved":        "kr",
+	"Keyword.Type":            "kt",
+	"Name":                    "n",
+	"Name.Attribute":          "na",
+	"Name.Builtin":            "nb",
+	"Name.Builtin.Pseudo":     "bp",
+	"Name.Class":              "nc",
+	"Name.Constant":           "no",
+	"Name.Decorator":          "nd",
+	"Name.Entity":             "ni",
+	"Name.Exception":          "ne",
+	"Name.Function":           "nf",
+	"Name.Property":           "py",
+	"Name.Label":              "nl",
+	"Name.Namespace":          "nn",
+	"Name.Other":              "nx",
+	"Name.Tag":                "nt",
+	"Name.Variable":           "nv",
+	"Literal":                 "l",
+	"Literal.Date":            "ld",
+	"Literal.String":          "s",
+	"Literal.String.Backtick": "sb",
+	"Literal.String.Char":     "sc",
+	"Literal.String.Doc":      "sd",
+	"Literal.String.Double":   "s2",
+	"Literal.String.Escape":   "se",
+	"Literal.String.Interpol": "si",
+	"Literal.String.Other":    "sx",
+	"Literal.String.Regex":    "sr",
+	"Literal.String.Single":   "s1",
+	"Literal.String.Symbol":   "ss",
+	"Literal.Number":          "m",
+	"Literal.Number.Bin":      "mb",
+	"Literal.Number.Float":    "mf",
+	"Literal.Number.Hex":      "mh",
+	"Literal.Number.Integer":  "mi",
+	"Literal.Number.Oct":      "mo",
+	"Operator":                "o",
+	"Operator.Word":           "ow",
+	"Punctuation":             "p",
+	"Comment":                 "c",
+	"Comment.Hashbang":        "ch",
+	"Comment.Multiline":       "cm",
+	"Comment.Preproc":         "cp",
+	"Comment.Single":          "c1",
+	"Comment.Special":         "cs",
+	"Generic":                 "g",
+	"Generic.Deleted":         "gd",
+	"Generic.Emph":            "ge",
+	"Generic.Error":           "gr",
+	"Generic.Heading":         "gh",
+	"Generic.Inserted":        "gi",
+	"Generic.Output":          "go",
+	"Generic.Prompt":          "gp",
+	"Generic.Strong":          "gs",
+	"Generic.Subheading":      "gu",
+	"Generic.Traceback":       "gt",
+}
+
+/*
+   Return the short CSS class name of a token type. Subtypes without a
+   name of their own get the name of the nearest known parent type with
+   the unknown parts appended ("Punctuation.Indicator" gives
+   "pIndicator"), as in Pygments.
+*/
+func ttypeClass(ttype string) string {
+	aname := ""
+	for {
+		if fname, ok := standardTypes[ttype]; ok {
+			return fname + aname
+		}
+		i := strings.LastIndex(ttype, ".")
+		if i < 0 {
+			return aname
+		}
+		aname = ttype[i+1:] + aname
+		ttype = ttype[:i]
+	}
+}
+
+// A lexer rule: text matching `pattern` is a token of type `ttype`. If
+// the pattern has a capturing group, only the group is the token and the
+// rest of the match is trailing context (Go regexps have no lookahead).
+type lexerRule struct {
+	pattern *regexp.Regexp
+	ttype   string
+}
+
+func rules(pairs ...string) []lexerRule {
+	var result []lexerRule
+	for i := 0; i < len(pairs); i += 2 {
+		result = append(result, lexerRule{regexp.MustCompile(`^(?:` + pairs[i] + `)`), pairs[i+1]})
+	}
+	return result
+}
+
+// Adapted from the Pygments GoLexer.
+var goRules = rules(
+	`\s+`, "Text",
+	`//[^\n]*`, "Comment.Single",
+	`(?s)/\*.*?\*/`, "Comment.Multiline",
+	`(?:import|package)\b`, "Keyword.Namespace",
+	`(?:var|func|struct|map|chan|type|interface|const)\b`, "Keyword.Declaration",
+	`(?:break|default|select|case|defer|go|else|goto|switch|fallthrough|if|range|continue|for|return)\b`, "Keyword",
+	`(?:true|false|iota|nil)\b`, "Keyword.Constant",
+	`(?:uint|uint8|uint16|uint32|uint64|int|int8|int16|int32|int64|float32|float64|complex64|complex128|byte|rune|string|bool|error|uintptr|any)\b`, "Keyword.Type",
+	`(append|cap|close|complex|copy|delete|imag|len|make|new|panic|print|println|real|recover)\(`, "Name.Builtin",
+	`\d+i`, "Number",
+	`\d+\.\d*(?:[Ee][-+]\d+)?i?|\.\d+(?:[Ee][-+]\d+)?i?|\d+[Ee][-+]\d+i?`, "Number.Float",
+	`0[xX][0-9a-fA-F_]+`, "Number.Hex",
+	`0[oO]?[0-7_]+`, "Number.Oct",
+	`\d[\d_]*`, "Number.Integer",
+	`'(?:\\['"\\abfnrtv]|\\x[0-9a-fA-F]{2}|\\[0-7]{1,3}|\\u[0-9a-fA-F]{4}|\\U[0-9a-fA-F]{8}|[^\\])'`, "String.Char",
+	"`[^`]*`", "String",
+	`"(?:\\\\|\\[^\\]|[^"\\])*"`, "String",
+	`<<=|>>=|<<|>>|<=|>=|&\^=|&\^|\+=|-=|\*=|/=|%=|&=|\|=|&&|\|\||<-|\+\+|--|==|!=|:=|\.\.\.|[+\-*/%&]`, "Operator",
+	`[|^<>=!()\[\]{}.,;:]`, "Punctuation",
+	`[^\W\d]\w*`, "Name.Other",
+)
+
+// The end of a plain YAML scalar, used as trailing context.
+const yamlScalarEnd = `[ \t]*(?:\n|$|#|,|\]|\})`
+
+// Adapted from the Pygments YamlLexer, line by line instead of stateful.
+var yamlRules = rules(
+	`[ \t]*\n[ \t\n]*|[ \t]+`, "Text",
+	`#[^\n]*`, "Comment.Single",
+	`(---|\.\.\.)(?:\s|$)`, "Name.Namespace",
+	`%[^\n]*`, "Comment.Preproc",
+	`([-?])(?:[ \t]|\n|$)`, "Punctuation.Indicator",
+	`([^\s'"#:,\[\]{}-][^\n#:]*?)[ \t]*:(?:[ \t]|\n|$)`, "Name.Tag",
+	`(:)(?:[ \t]|\n|$)`, "Punctuation",
+	`[\[\]{},]`, "Punctuation.Indicator",
+	`[|>][+-]?\d*`, "Punctuation.Indicator",
+	`&[\w-]+`, "Name.Label",
+	`\*[\w-]+`, "Name.Variable",
+	`!(?:[\w-]*!)?[\w;/?:@&=+$.!~*'()%-]*`, "Keyword.Type",
+	`'(?:[^']|'')*'`, "String",
+	`"(?:\\.|[^"\\])*"`, "String",
+	`(true|false|yes|no|on|off|null|True|False|Null|NULL|~)`+yamlScalarEnd, "Keyword.Constant",
+	`([-+]?(?:0x[0-9a-fA-F]+|0o[0-7]+|\d+(?:\.\d*)?(?:[eE][-+]?\d+)?))`+yamlScalarEnd, "Number",
+	`([^\s#,\[\]{}](?:[^\n#,\[\]{}]*[^\s#,\[\]{}])?)`+yamlScalarEnd, "Literal.Scalar.Plain",
+)
+
+// Adapted from the Pygments JsonLexer.
+var jsonRules = rules(
+	`\s+`, "Text",
+	`("(?:\\.|[^"\\])*")\s*:`, "Name.Tag",
+	`"(?:\\.|[^"\\])*"`, "String.Double",
+	`-?(?:0|[1-9]\d*)(?:\.\d+[eE][-+]?\d+|[eE][-+]?\d+|\.\d+)`, "Number.Float",
+	`-?(?:0|[1-9]\d*)`, "Number.Integer",
+	`(?:true|false|null)\b`, "Keyword.Constant",
+	`[{}\[\],:]`, "Punctuation",
+)
+
+// The languages of the `DefaultHighlighter` with their aliases.
+var builtinLexers = map[string][]lexerRule{
+	"go":     goRules,
+	"golang": goRules,
+	"yaml":   yamlRules,
+	"yml":    yamlRules,
+	"json":   jsonRules,
+}
+
+type builtinHighlighter struct{}
+
+// Split `code` with the first matching rule at each position; characters
+// not matched by any rule are "Error" tokens.
+func (h builtinHighlighter) Tokenize(code, language string) ([]Token, error) {
+	lexer, ok := builtinLexers[strings.ToLower(language)]
+	if !ok {
+		return nil, &LexerError{fmt.Sprintf("Cannot analyze code. No lexer found for \"%s\".", language)}
+	}
+	var tokens []Token
+	for rest := code; rest != ""; {
+		token := Token{"Error", ""}
+		for _, rule := range lexer {
+			m := rule.pattern.FindStringSubmatch(rest)
+			if m == nil || m[0] == "" {
+				continue
+			}
+			token = Token{rule.ttype, m[0]}
+			if len(m) > 1 {
+				token.Value = m[1]
+			}
+			break
+		}
+		if token.Value == "" {
+			_, size := utf8.DecodeRuneInString(rest)
+			token.Value = rest[:size]
+		}
+		tokens = append(tokens, token)
+		rest = rest[len(token.Value):]
+	}
+	return tokens, nil
+}
+
+// The highlighter used when `Settings.Highlighter` is nil. It knows Go,
+// YAML and JSON.
+var DefaultHighlighter Highlighter = builtinHighlighter{}
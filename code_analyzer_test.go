@@ -0,0 +1,52 @@
+package rst
+
+import (
+	"strings"
+	"testing"
+)
+
+func formatTokens(tokens []ClassedToken) string {
+	var parts []string
+	for _, token := range tokens {
+		parts = append(parts, strings.Join(token.Classes, ".")+"{"+token.Value+"}")
+	}
+	return strings.Join(parts, "")
+}
+
+func TestAnalyzeCode(t *testing.T) {
+	tokens, err := AnalyzeCode("x := len(s) // n", "go", "short", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := "nx{x}{ }o{:=}{ }nb{len}p{(}nx{s}p{)}{ }c1{// n}"
+	if s := formatTokens(tokens); s != expected {
+		t.Error("short token names:", s)
+	}
+
+	tokens, _ = AnalyzeCode("return nil", "go", "long", nil)
+	if s := formatTokens(tokens); s != "keyword{return}{ }keyword.constant{nil}" {
+		t.Error("long token names:", s)
+	}
+
+	tokens, _ = AnalyzeCode("key: [1, a]", "yaml", "short", nil)
+	if s := formatTokens(tokens); s != "nt{key}p{:}{ }pIndicator{[}m{1}pIndicator{,}{ }lScalarPlain{a}pIndicator{]}" {
+		t.Error("yaml:", s)
+	}
+
+	tokens, _ = AnalyzeCode("return nil", "go", "none", nil)
+	if s := formatTokens(tokens); s != "{return nil}" {
+		t.Error("no highlighting:", s)
+	}
+
+	if _, err := AnalyzeCode("x", "cobol", "long", nil); err == nil {
+		t.Error("unknown language not reported")
+	}
+}
+
+func TestNumberLines(t *testing.T) {
+	tokens := []ClassedToken{{nil, "a\n"}, {[]string{"k"}, "b\nc"}}
+	expected := "ln{ 9 }{a\n}ln{10 }{}k{b\n}ln{11 }k{c}"
+	if s := formatTokens(NumberLines(tokens, 9, 11)); s != expected {
+		t.Error("number lines:", s)
+	}
+}
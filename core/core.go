@@ -0,0 +1,383 @@
+/*
+Package core implements the Publisher of Python docutils, to convert a
+document in one call: the input is read and parsed into a document tree,
+the transforms of the reader, parser and writer are applied, and the
+writer translates the document into the output format.
+
+URL of Python source code:
+http://sourceforge.net/p/docutils/code/HEAD/tree/trunk/docutils/docutils/core.py
+
+Example:
+
+	publisher := &core.Publisher{}
+	if err := publisher.SetComponents("standalone", "restructuredtext", "html"); err != nil {
+		...
+	}
+	output, messages, err := publisher.PublishString(input, "doc.rst")
+*/
+package core
+
+import (
+	"io/ioutil"
+	"strings"
+	"unicode/utf8"
+
+	rst "github.com/siongui/go-rst"
+	"github.com/siongui/go-rst/parsers"
+	"github.com/siongui/go-rst/parsers/docutilsxml"
+	rstparser "github.com/siongui/go-rst/parsers/restructuredtext"
+	"github.com/siongui/go-rst/readers"
+	"github.com/siongui/go-rst/readers/standalone"
+	"github.com/siongui/go-rst/transforms"
+	"github.com/siongui/go-rst/writers"
+	docutilsxmlwriter "github.com/siongui/go-rst/writers/docutilsxml"
+	"github.com/siongui/go-rst/writers/epub"
+	"github.com/siongui/go-rst/writers/html"
+	"github.com/siongui/go-rst/writers/latex"
+	"github.com/siongui/go-rst/writers/manpage"
+	"github.com/siongui/go-rst/writers/markdown"
+	"github.com/siongui/go-rst/writers/pseudoxml"
+	"github.com/siongui/go-rst/writers/restructuredtext"
+	"github.com/siongui/go-rst/writers/s5"
+	"github.com/siongui/go-rst/writers/text"
+)
+
+type ComponentError struct {
+	msg string
+}
+
+func (e *ComponentError) Error() string {
+	return e.msg
+}
+
+type InputError struct {
+	msg string
+}
+
+func (e *InputError) Error() string {
+	return e.msg
+}
+
+/*
+   A reader, parser or writer: its names (the first one is the name of its
+   configuration file section, e.g. "html5 writer"), the configuration file
+   sections it depends on, and its constructor.
+*/
+type component struct {
+	names        []string
+	dependencies []string
+	new          func() rst.TransformSpec
+}
+
+var readerComponents = []component{
+	{[]string{"standalone"}, nil, func() rst.TransformSpec { return &standalone.Reader{} }},
+}
+
+var parserComponents = []component{
+	{[]string{"restructuredtext", "rst", "rest", "restx", "rtxt", "rstx"}, nil,
+		func() rst.TransformSpec { return &rstparser.Parser{} }},
+	{[]string{"docutils_xml", "xml"}, nil, func() rst.TransformSpec { return &docutilsxml.Parser{} }},
+}
+
+var writerComponents = []component{
+	{[]string{"html5", "html", "xhtml", "xhtml10", "html4css1"}, []string{"html writers"},
+		func() rst.TransformSpec { return &html.Writer{} }},
+	{[]string{"s5_html", "s5", "slides"}, []string{"html writers"},
+		func() rst.TransformSpec { return &s5.Writer{} }},
+	{[]string{"epub", "epub3"}, []string{"html writers"},
+		func() rst.TransformSpec { return &epub.Writer{} }},
+	{[]string{"latex2e", "latex"}, []string{"latex writers"},
+		func() rst.TransformSpec { return &latex.Writer{} }},
+	{[]string{"manpage", "man"}, nil, func() rst.TransformSpec { return &manpage.Writer{} }},
+	{[]string{"markdown", "md", "commonmark", "gfm"}, nil, func() rst.TransformSpec { return &markdown.Writer{} }},
+	{[]string{"restructuredtext", "rst", "rest"}, nil, func() rst.TransformSpec { return &restructuredtext.Writer{} }},
+	{[]string{"text", "txt", "plain"}, nil, func() rst.TransformSpec { return &text.Writer{} }},
+	{[]string{"docutils_xml", "xml"}, nil, func() rst.TransformSpec { return &docutilsxmlwriter.Writer{} }},
+	{[]string{"pseudoxml", "pprint", "pformat"}, nil, func() rst.TransformSpec { return &pseudoxml.Writer{} }},
+}
+
+// Return the component named `name` (case-insensitive), nil if unknown.
+func findComponent(components []component, name string) *component {
+	name = strings.ToLower(name)
+	for i := range components {
+		for _, n := range components[i].names {
+			if n == name {
+				return &components[i]
+			}
+		}
+	}
+	return nil
+}
+
+// Return a new reader for the reader name `name` (e.g. "standalone").
+func GetReader(name string) (readers.Reader, error) {
+	if c := findComponent(readerComponents, name); c != nil {
+		return c.new().(readers.Reader), nil
+	}
+	return nil, &ComponentError{"Unknown reader: \"" + name + "\"."}
+}
+
+/*
+   Return a new parser for the input format `name` (e.g.
+   "restructuredtext" or "xml"). Parsers of other formats can be set as
+   `Publisher.Parser` directly.
+*/
+func GetParser(name string) (parsers.Parser, error) {
+	if c := findComponent(parserComponents, name); c != nil {
+		return c.new().(parsers.Parser), nil
+	}
+	return nil, &ComponentError{"Unknown parser: \"" + name + "\"."}
+}
+
+// Return a new writer for the output format `name` (e.g. "html").
+func GetWriter(name string) (writers.Writer, error) {
+	if c := findComponent(writerComponents, name); c != nil {
+		return c.new().(writers.Writer), nil
+	}
+	return nil, &ComponentError{"Unknown writer: \"" + name + "\"."}
+}
+
+/*
+   Return the configuration file sections applying to the reader, parser
+   and writer named `readerName`, `parserName` and `writerName` (see
+   `rst.Settings.ReadConfig()`), in the order of Python docutils: e.g.
+   "general", "readers", "standalone reader", "parsers", "docutils_xml
+   parser", "writers", "html writers", "html5 writer". Empty or unknown
+   names only add the general section of their kind ("writers", ...).
+*/
+func ConfigSections(readerName, parserName, writerName string) []string {
+	sections := []string{"general"}
+	for _, kind := range []struct {
+		name, section string
+		components    []component
+	}{
+		{readerName, "reader", readerComponents},
+		{parserName, "parser", parserComponents},
+		{writerName, "writer", writerComponents},
+	} {
+		sections = append(sections, kind.section+"s")
+		if c := findComponent(kind.components, kind.name); c != nil {
+			sections = append(sections, c.dependencies...)
+			sections = append(sections, c.names[0]+" "+kind.section)
+		}
+	}
+	return sections
+}
+
+/*
+   A facade encapsulating the high-level logic of a Docutils system: the
+   reader, parser and writer components, and the runtime settings.
+
+   Each `Publish*()` method returns the system messages generated while
+   processing the document, at all levels (filter them with the
+   "level" attribute), together with its result. Processing stops with a
+   `rst.SystemMessageError` at the first message at or above the
+   `HaltLevel` setting; the messages generated until then are returned
+   with the error.
+*/
+type Publisher struct {
+	// Reads the input; nil for the standalone reader.
+	Reader readers.Reader
+
+	// Parses the input; nil for the reStructuredText parser (see
+	// `GetParser()`).
+	Parser parsers.Parser
+
+	// Translates the document tree; nil for documents trees only (see
+	// `PublishDoctree()`).
+	Writer writers.Writer
+
+	// Runtime settings; nil for the default settings.
+	Settings *rst.Settings
+
+	// System messages of the current publication.
+	messages []*rst.Element
+
+	// Serial number of the current publication: the observers of previous
+	// publications are left attached, but inactive.
+	serialno int
+}
+
+/*
+   Set the reader, parser and writer by name, see `GetReader()`,
+   `GetParser()` and `GetWriter()`. Components with an empty name are
+   left unchanged.
+*/
+func (p *Publisher) SetComponents(readerName, parserName, writerName string) (err error) {
+	if readerName != "" {
+		if p.Reader, err = GetReader(readerName); err != nil {
+			return
+		}
+	}
+	if parserName != "" {
+		if p.Parser, err = GetParser(parserName); err != nil {
+			return
+		}
+	}
+	if writerName != "" {
+		p.Writer, err = GetWriter(writerName)
+	}
+	return
+}
+
+// Start a publication: collect the system messages of `document`.
+func (p *Publisher) observe(document *rst.Document) {
+	p.messages = nil
+	p.serialno++
+	serialno := p.serialno
+	document.Reporter().Attach(func(message *rst.Element) {
+		if p.serialno == serialno {
+			p.messages = append(p.messages, message)
+		}
+	})
+}
+
+// Apply the transforms of `components` to `document`.
+func applyTransforms(document *rst.Document, components ...rst.TransformSpec) error {
+	transformer := &transforms.Transformer{}
+	transformer.Init(document)
+	transformer.PopulateFromComponents(components...)
+	return transformer.ApplyTransforms()
+}
+
+// Read and parse `source` into a new document, and apply the transforms.
+func (p *Publisher) read(source, sourcePath string, writer rst.TransformSpec) (*rst.Document, error) {
+	p.messages = nil
+	parser := p.Parser
+	if parser == nil {
+		parser = &rstparser.Parser{}
+	}
+	reader := p.Reader
+	if reader == nil {
+		reader = &standalone.Reader{}
+	}
+	document := rst.NewDocument(sourcePath, p.Settings)
+	p.observe(document)
+	// a byte order mark is not part of the text
+	source = strings.TrimPrefix(source, "\ufeff")
+	if err := reader.Read(source, parser, document); err != nil {
+		return document, err
+	}
+	if err := document.Reporter().Halted(); err != nil {
+		return document, err
+	}
+	return document, applyTransforms(document, reader, parser, writer)
+}
+
+// Translate the transformed `document` with the writer.
+func (p *Publisher) write(document *rst.Document) ([]byte, error) {
+	output, err := p.Writer.Write(document)
+	if err != nil {
+		return nil, err
+	}
+	return []byte(output), nil
+}
+
+/*
+   Convert the string `source` (read from `sourcePath`, a path or a
+   description used in system messages) and return the output.
+*/
+func (p *Publisher) PublishString(source, sourcePath string) ([]byte, []*rst.Element, error) {
+	if p.Writer == nil {
+		return nil, nil, &ComponentError{"No writer set."}
+	}
+	document, err := p.read(source, sourcePath, p.Writer)
+	if err != nil {
+		return nil, p.messages, err
+	}
+	output, err := p.write(document)
+	return output, p.messages, err
+}
+
+/*
+   Like `PublishString()`, but return the document parts of the writer
+   (see `writers.Writer`) instead of the whole output.
+*/
+func (p *Publisher) PublishParts(source, sourcePath string) (map[string]string, []*rst.Element, error) {
+	_, messages, err := p.PublishString(source, sourcePath)
+	if err != nil {
+		return nil, messages, err
+	}
+	return p.Writer.Parts(), messages, nil
+}
+
+/*
+   Decode the input `data` in `encoding` (see the `InputEncoding` setting):
+   "utf-8" (the default when empty) or "latin-1".
+*/
+func Decode(data []byte, encoding string) (string, error) {
+	switch strings.ToLower(encoding) {
+	case "", "utf-8", "utf8", "utf-8-sig":
+		if !utf8.Valid(data) {
+			return "", &InputError{"Unable to decode input data as UTF-8 (set the input encoding)."}
+		}
+		return string(data), nil
+	case "latin-1", "latin1", "iso-8859-1":
+		runes := make([]rune, len(data))
+		for i, b := range data {
+			runes[i] = rune(b)
+		}
+		return string(runes), nil
+	}
+	return "", &InputError{"Unknown input encoding: \"" + encoding + "\"."}
+}
+
+/*
+   Convert the file `sourcePath` (decoded in the `InputEncoding` setting),
+   and write the output to the file `destinationPath` unless it is empty.
+   The output is returned too.
+*/
+func (p *Publisher) PublishFile(sourcePath, destinationPath string) ([]byte, []*rst.Element, error) {
+	data, err := ioutil.ReadFile(sourcePath)
+	if err != nil {
+		return nil, nil, err
+	}
+	encoding := "utf-8"
+	if p.Settings != nil {
+		encoding = p.Settings.InputEncoding
+	}
+	source, err := Decode(data, encoding)
+	if err != nil {
+		return nil, nil, &InputError{sourcePath + ": " + err.Error()}
+	}
+	output, messages, err := p.PublishString(source, sourcePath)
+	if err != nil {
+		return nil, messages, err
+	}
+	if destinationPath != "" {
+		if err := ioutil.WriteFile(destinationPath, output, 0644); err != nil {
+			return nil, messages, err
+		}
+	}
+	return output, messages, nil
+}
+
+/*
+   Read and parse `source` and return the transformed document tree,
+   ready for `PublishFromDoctree()` (possibly with several writers). The
+   writer is not used: the transforms common to all writers are applied.
+*/
+func (p *Publisher) PublishDoctree(source, sourcePath string) (*rst.Document, []*rst.Element, error) {
+	document, err := p.read(source, sourcePath, &writers.Base{})
+	if err != nil {
+		return nil, p.messages, err
+	}
+	return document, p.messages, nil
+}
+
+/*
+   Apply the transforms of the writer to `document` (as returned by
+   `PublishDoctree()`) and translate it. The settings of the document are
+   used, not `Settings`.
+*/
+func (p *Publisher) PublishFromDoctree(document *rst.Document) ([]byte, []*rst.Element, error) {
+	if p.Writer == nil {
+		return nil, nil, &ComponentError{"No writer set."}
+	}
+	p.observe(document)
+	if err := applyTransforms(document, p.Writer); err != nil {
+		return nil, p.messages, err
+	}
+	output, err := p.write(document)
+	return output, p.messages, err
+}
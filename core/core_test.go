@@ -0,0 +1,182 @@
+package core
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	rst "github.com/siongui/go-rst"
+)
+
+const input = `<?xml version="1.0" encoding="utf-8"?>
+<document source="in.xml">
+    <section ids="the-title" names="the\ title">
+        <title>The Title</title>
+        <paragraph>"Quoted" text with a <reference name="link" refname="nowhere">link</reference>.</paragraph>
+    </section>
+</document>
+`
+
+func newPublisher(t *testing.T, writerName string) *Publisher {
+	settings := &rst.Settings{}
+	settings.Init()
+	settings.WarningStream = nil
+	settings.SmartQuotes = "yes"
+	publisher := &Publisher{Settings: settings}
+	if err := publisher.SetComponents("standalone", "xml", writerName); err != nil {
+		t.Fatal(err)
+	}
+	return publisher
+}
+
+func TestPublishString(t *testing.T) {
+	publisher := newPublisher(t, "pseudoxml")
+	output, messages, err := publisher.PublishString(input, "in.xml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// the section title is promoted to document title, the quotes are
+	// educated and the dangling reference reported
+	for _, s := range []string{
+		"<document ids=\"the-title\" names=\"the\\ title\" source=\"in.xml\" title=\"The Title\">\n    <title>\n        The Title\n",
+		"“Quoted” text with a \n",
+		"<problematic ids=\"id2\" refid=\"id1\">",
+		"Unknown target name: \"nowhere\".",
+	} {
+		if !strings.Contains(string(output), s) {
+			t.Errorf("%q not in the output:\n%s", s, output)
+		}
+	}
+	if len(messages) != 1 || messages[0].Get("type") != "ERROR" {
+		t.Errorf("unexpected system messages: %v", messages)
+	}
+
+	parts, _, err := newPublisher(t, "html").PublishParts(input, "in.xml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if parts["title"] != "The Title" {
+		t.Errorf("unexpected title part: %q", parts["title"])
+	}
+}
+
+func TestPublishReStructuredText(t *testing.T) {
+	settings := &rst.Settings{}
+	settings.Init()
+	settings.WarningStream = nil
+	settings.SmartQuotes = "yes"
+	publisher := &Publisher{Settings: settings}
+	if err := publisher.SetComponents("standalone", "rst", "pseudoxml"); err != nil {
+		t.Fatal(err)
+	}
+	source := "=========\nThe Title\n=========\n\n\"Quoted\" text with a link_.\n"
+	output, messages, err := publisher.PublishString(source, "in.rst")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, s := range []string{
+		"<document ids=\"the-title\" names=\"the\\ title\" source=\"in.rst\" title=\"The Title\">\n    <title>\n        The Title\n",
+		"“Quoted” text with a \n",
+		"Unknown target name: \"link\".",
+	} {
+		if !strings.Contains(string(output), s) {
+			t.Errorf("%q not in the output:\n%s", s, output)
+		}
+	}
+	if len(messages) != 1 || messages[0].Get("type") != "ERROR" {
+		t.Errorf("unexpected system messages: %v", messages)
+	}
+}
+
+func TestHalt(t *testing.T) {
+	publisher := newPublisher(t, "html")
+	publisher.Settings.HaltLevel = rst.ErrorLevel
+	output, messages, err := publisher.PublishString(input, "in.xml")
+	if _, ok := err.(*rst.SystemMessageError); !ok || output != nil {
+		t.Errorf("processing not halted: %v", err)
+	}
+	if len(messages) != 1 {
+		t.Errorf("unexpected system messages: %v", messages)
+	}
+}
+
+func TestPublishDoctree(t *testing.T) {
+	document, messages, err := newPublisher(t, "").PublishDoctree(input, "in.xml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(messages) != 1 || document.Get("title") != "The Title" {
+		t.Errorf("unexpected document tree:\n%s", document.Pformat("    ", 0))
+	}
+	for _, name := range []string{"html", "latex", "text"} {
+		output, messages, err := newPublisher(t, name).PublishFromDoctree(document)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !strings.Contains(string(output), "The Title") || len(messages) != 0 {
+			t.Errorf("%s: unexpected output %q, messages %v", name, output, messages)
+		}
+	}
+}
+
+func TestPublishFile(t *testing.T) {
+	dir, err := ioutil.TempDir("", "core")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	source := filepath.Join(dir, "in.xml")
+	destination := filepath.Join(dir, "out.txt")
+	if err := ioutil.WriteFile(source, []byte("\ufeff"+input), 0644); err != nil {
+		t.Fatal(err)
+	}
+	output, _, err := newPublisher(t, "text").PublishFile(source, destination)
+	if err != nil {
+		t.Fatal(err)
+	}
+	written, err := ioutil.ReadFile(destination)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(written) != string(output) || !strings.HasPrefix(string(output), "=========\nThe Title\n") {
+		t.Errorf("unexpected output:\n%s", written)
+	}
+}
+
+func TestComponents(t *testing.T) {
+	publisher := &Publisher{}
+	if err := publisher.SetComponents("", "nonsense", "html"); err == nil {
+		t.Error("unknown parser accepted")
+	}
+	if err := publisher.SetComponents("", "", "nonsense"); err == nil {
+		t.Error("unknown writer accepted")
+	}
+	if _, _, err := publisher.PublishString(input, "in.xml"); err == nil {
+		t.Error("published without writer")
+	}
+	if writer, err := GetWriter("HTML5"); err != nil || !writer.Supports("html5") {
+		t.Error("writer aliases not found")
+	}
+
+	sections := strings.Join(ConfigSections("standalone", "xml", "html"), ", ")
+	if sections != "general, readers, standalone reader, parsers, docutils_xml parser, writers, html writers, html5 writer" {
+		t.Errorf("unexpected configuration sections: %s", sections)
+	}
+	if sections := strings.Join(ConfigSections("", "", "man"), ", "); sections != "general, readers, parsers, writers, manpage writer" {
+		t.Errorf("unexpected configuration sections: %s", sections)
+	}
+}
+
+func TestDecode(t *testing.T) {
+	if text, err := Decode([]byte("caf\xe9"), "latin-1"); err != nil || text != "café" {
+		t.Errorf("latin-1 input decoded as %q", text)
+	}
+	if _, err := Decode([]byte("caf\xe9"), "utf-8"); err == nil {
+		t.Error("invalid UTF-8 accepted")
+	}
+	if _, err := Decode([]byte("text"), "ebcdic"); err == nil {
+		t.Error("unknown encoding accepted")
+	}
+}
@@ -0,0 +1,146 @@
+package directives
+
+/*
+Implementation of body element directives in Python docutils: code and
+math
+
+URL of Python source code:
+http://sourceforge.net/p/docutils/code/HEAD/tree/trunk/docutils/docutils/parsers/rst/directives/body.py
+*/
+
+import (
+	"strconv"
+	"strings"
+
+	rst "github.com/siongui/go-rst"
+)
+
+func init() {
+	Register("code", CodeBlock)
+	Register("code-block", CodeBlock)
+	Register("sourcecode", CodeBlock)
+	Register("math", MathBlock)
+}
+
+/*
+   Parse and mark up content of a code block.
+
+   The optional argument is the language of the code; the lexer of the
+   document's `Settings.Highlighter` splits the content into tokens held in
+   `inline` elements with the token classes. If the language is not known
+   to the highlighter, a warning is reported and the content is kept as a
+   plain literal block.
+
+   Options: "number-lines" (with an optional start line number), "class"
+   and "name".
+*/
+var CodeBlock = &Definition{
+	OptionalArguments: 1,
+	OptionSpec: map[string]OptionConverter{
+		"class":        ClassOption,
+		"name":         Unchanged,
+		"number-lines": Unchanged, // integer or None
+	},
+	HasContent: true,
+	Run:        runCodeBlock,
+}
+
+func runCodeBlock(d *Directive) ([]rst.Node, error) {
+	if err := d.AssertHasContent(); err != nil {
+		return nil, err
+	}
+	language := ""
+	if len(d.Arguments) > 0 {
+		language = d.Arguments[0]
+	}
+	classNames := []string{"code"}
+	if language != "" {
+		classNames = append(classNames, language)
+	}
+	classNames = append(classNames, classes(d.Options)...)
+	code := strings.Join(d.Content, "\n")
+	settings := d.Document.Settings()
+
+	var messages []rst.Node
+	// set up lexical analyzer
+	tokens, err := rst.AnalyzeCode(code, language, settings.SyntaxHighlight, settings.Highlighter)
+	if err != nil {
+		msg := d.Document.Reporter().Warning(err.Error(), "", d.Lineno)
+		messages = append(messages, msg)
+		tokens = []rst.ClassedToken{{Value: code}}
+	}
+
+	if value, ok := d.Options["number-lines"]; ok {
+		// optional argument `startline`, defaults to 1
+		startline := 1
+		if value.(string) != "" {
+			startline, err = strconv.Atoi(value.(string))
+			if err != nil {
+				return nil, d.Error(":number-lines: with non-integer start value")
+			}
+		}
+		endline := startline + len(d.Content)
+		// add linenumber filter:
+		tokens = rst.NumberLines(tokens, startline, endline)
+	}
+
+	node := &rst.Element{}
+	node.Init("literal_block", code, "")
+	node.Classes = classNames
+	d.AddName(node)
+	// analyze content and add nodes for every token
+	for _, token := range tokens {
+		if token.Value == "" {
+			continue
+		}
+		if len(token.Classes) > 0 {
+			inline := &rst.Element{}
+			inline.Init("inline", token.Value, token.Value)
+			inline.Classes = token.Classes
+			node.Append(inline)
+		} else {
+			// insert as Text to decrease the verbosity of the output
+			text := &rst.Text{}
+			text.Init(token.Value, token.Value)
+			node.Append(text)
+		}
+	}
+	return append([]rst.Node{node}, messages...), nil
+}
+
+/*
+   Display math. The content is LaTeX math code; blocks separated by blank
+   lines become separate `math_block` elements.
+*/
+var MathBlock = &Definition{
+	OptionSpec: map[string]OptionConverter{
+		"class": ClassOption,
+		"name":  Unchanged,
+	},
+	HasContent: true,
+	Run:        runMathBlock,
+}
+
+func runMathBlock(d *Directive) ([]rst.Node, error) {
+	if err := d.AssertHasContent(); err != nil {
+		return nil, err
+	}
+	// join lines, separate blocks
+	content := strings.Split(strings.Join(d.Content, "\n"), "\n\n")
+	var nodes []rst.Node
+	for _, block := range content {
+		if block == "" {
+			continue
+		}
+		node := &rst.Element{}
+		node.Init("math_block", d.BlockText, block)
+		node.Classes = append(node.Classes, classes(d.Options)...)
+		node.SetLine(d.ContentOffset + 1)
+		// only the first block is the target of the "name" option
+		if len(nodes) == 0 {
+			d.AddName(node)
+		}
+		nodes = append(nodes, node)
+	}
+	return nodes, nil
+}
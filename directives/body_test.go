@@ -0,0 +1,74 @@
+package directives
+
+import (
+	"testing"
+
+	rst "github.com/siongui/go-rst"
+)
+
+func TestCodeBlock(t *testing.T) {
+	settings := &rst.Settings{}
+	settings.Init()
+	settings.WarningStream = nil
+	document := rst.NewDocument("test", settings)
+	document.Extend(Run(document, &document.Element, "code", []string{"go", ":number-lines: 7", ":name: example", "", "if ok {", "	return 1", "}"}, 1, "")...)
+	document.Extend(Run(document, &document.Element, "code-block", []string{"cobol", ":class: old", "", "DISPLAY 'HI'."}, 9, "")...)
+
+	expected := `<document source="test">
+    <literal_block classes="code go" ids="example" names="example">
+        <inline classes="ln">
+             7 
+        <inline classes="k">
+            if
+         
+        <inline classes="nx">
+            ok
+         
+        <inline classes="p">
+            {
+        
+        <inline classes="ln">
+             8 
+        	
+        <inline classes="k">
+            return
+         
+        <inline classes="mi">
+            1
+        
+        <inline classes="ln">
+             9 
+        <inline classes="p">
+            }
+    <literal_block classes="code cobol old">
+        DISPLAY 'HI'.
+    <system_message level="2" line="9" source="test" type="WARNING">
+        <paragraph>
+            Cannot analyze code. No lexer found for "cobol".
+`
+	if output := document.Pformat("    ", 0); output != expected {
+		t.Error("code directive failed:\n" + output)
+	}
+
+	result := Run(document, nil, "code", []string{"go", ":number-lines: x", "", "x"}, 11, "")
+	if result[0].AsText() != "test:11: (ERROR/3) :number-lines: with non-integer start value\n\n" {
+		t.Error("invalid number-lines not reported: " + result[0].AsText())
+	}
+}
+
+func TestMathBlock(t *testing.T) {
+	settings := &rst.Settings{}
+	settings.Init()
+	document := rst.NewDocument("test", settings)
+	document.Extend(Run(document, &document.Element, "math", []string{":class: big", ":name: euler", "", "e^{i\\pi} + 1 = 0", "", "a^2 + b^2 = c^2"}, 1, ".. math::")...)
+
+	expected := `<document source="test">
+    <math_block classes="big" ids="euler" names="euler">
+        e^{i\pi} + 1 = 0
+    <math_block classes="big">
+        a^2 + b^2 = c^2
+`
+	if output := document.Pformat("    ", 0); output != expected {
+		t.Error("math directive failed:\n" + output)
+	}
+}
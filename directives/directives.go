@@ -0,0 +1,628 @@
+/*
+Package directives implements reStructuredText directives of Python
+docutils: the directive registry, the option conversion functions, and
+the parsing of directive blocks into arguments, options and content.
+
+URL of Python source code:
+http://sourceforge.net/p/docutils/code/HEAD/tree/trunk/docutils/docutils/parsers/rst/directives/__init__.py
+http://sourceforge.net/p/docutils/code/HEAD/tree/trunk/docutils/docutils/parsers/rst/__init__.py
+
+The reStructuredText parser (package parsers/restructuredtext) recognizes
+".. name::" and calls `RunWithState()` with the rest of the directive
+block; directives parse their content with the `State` they are given.
+*/
+package directives
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode"
+
+	rst "github.com/siongui/go-rst"
+)
+
+/*
+   Function converting and validating a directive option argument.
+
+   `argument` is the text following the option name, "" if there is none.
+   Return the converted value, or a `ValueError` if the argument is
+   invalid.
+*/
+type OptionConverter func(argument string) (interface{}, error)
+
+/*
+   Definition of a directive type, registered with `Register()`.
+
+   - `RequiredArguments`: the number of required directive arguments.
+   - `OptionalArguments`: the number of optional arguments after the
+     required arguments.
+   - `FinalArgumentWhitespace`: may the final argument contain whitespace?
+   - `OptionSpec`: mapping of option names to validator functions.
+   - `HasContent`: may the directive have content?
+   - `Run`: the directive function, which returns a list of nodes to insert
+     into the document tree, or a `DirectiveError`.
+*/
+type Definition struct {
+	RequiredArguments       int
+	OptionalArguments       int
+	FinalArgumentWhitespace bool
+	OptionSpec              map[string]OptionConverter
+	HasContent              bool
+	Run                     func(d *Directive) ([]rst.Node, error)
+}
+
+/*
+   One use of a directive in a document, passed to `Definition.Run`.
+
+   - `Name` is the directive type name (the name as used in the document).
+   - `Arguments` is a list of positional arguments (strings).
+   - `Options` is a dictionary mapping option names (strings) to values
+     (type depends on option conversion functions; see `OptionSpec`).
+   - `Content` is a list of strings, the directive content line by line.
+   - `Lineno` is the absolute line number of the first line of the
+     directive.
+   - `ContentOffset` is the line offset of the first line of the content
+     from the beginning of the current input.
+   - `BlockText` is a string containing the entire directive.
+   - `Document` is the document being parsed.
+   - `Parent` is the element the directive's nodes will be added to, or
+     nil if unknown.
+   - `State` is the state of the parser which called the directive, or nil
+     if the directive is not run by a parser.
+*/
+type Directive struct {
+	Name          string
+	Arguments     []string
+	Options       map[string]interface{}
+	Content       []string
+	Lineno        int
+	ContentOffset int
+	BlockText     string
+	Document      *rst.Document
+	Parent        *rst.Element
+	State         State
+}
+
+/*
+   The part of the reStructuredText parser state directives use to parse
+   their content.
+*/
+type State interface {
+	/*
+	   Parse the body elements of `block` (whose first line is at the
+	   offset `inputOffset` from the beginning of the source) into `node`.
+	   Section titles are recognized if `matchTitles` is true.
+	*/
+	NestedParse(block []string, inputOffset int, node *rst.Element, matchTitles bool) error
+
+	/*
+	   Parse the inline markup of `text` (from the line `lineno`); return
+	   the nodes and the system messages.
+	*/
+	InlineText(text string, lineno int) ([]rst.Node, []rst.Node)
+}
+
+// Return a `DirectiveError` suitable for being returned by a directive
+// function, level-3 ("ERROR").
+func (d *Directive) Error(message string) *DirectiveError {
+	return &DirectiveError{ErrorLevel, message}
+}
+
+// Return a level-4 ("SEVERE") `DirectiveError`.
+func (d *Directive) Severe(message string) *DirectiveError {
+	return &DirectiveError{SevereLevel, message}
+}
+
+// Return a level-2 ("WARNING") `DirectiveError`.
+func (d *Directive) Warning(message string) *DirectiveError {
+	return &DirectiveError{WarningLevel, message}
+}
+
+// Throw an error if the directive has no content.
+func (d *Directive) AssertHasContent() error {
+	if len(d.Content) == 0 {
+		return d.Error("Content block expected for the \"" + d.Name + "\" directive; none found.")
+	}
+	return nil
+}
+
+/*
+   Append the "name" option to the names of `node` and register it as an
+   explicit target.
+*/
+func (d *Directive) AddName(node *rst.Element) {
+	if value, ok := d.Options["name"]; ok {
+		name := rst.FullyNormalizeName(value.(string))
+		node.Names = append(node.Names, name)
+		d.Document.NoteExplicitTarget(node, node)
+	}
+}
+
+// Shortcuts for the system message levels.
+const (
+	WarningLevel = rst.WarningLevel
+	ErrorLevel   = rst.ErrorLevel
+	SevereLevel  = rst.SevereLevel
+)
+
+// Mapping of directive name to directive definition.
+var registry = map[string]*Definition{}
+
+/*
+   Register a nonstandard application-defined directive function.
+   Language lookups are not needed for such functions.
+*/
+func Register(name string, directive *Definition) {
+	registry[strings.ToLower(name)] = directive
+}
+
+// Locate and return a directive definition by name (case-insensitive).
+func Lookup(name string) (*Definition, bool) {
+	directive, ok := registry[strings.ToLower(name)]
+	return directive, ok
+}
+
+var fieldMarker = regexp.MustCompile(`^:(?:[^:\\]|\\.|:[^ \t` + "`" + `:])+:( +|$)`)
+
+/*
+   Parse and run the directive `name`; return the nodes to insert into the
+   document tree (including system messages).
+
+   Parameters:
+
+   - `document`: the document being parsed.
+   - `parent`: the element the result will be added to (may be nil).
+   - `name`: the directive type name, as given in the source.
+   - `block`: the directive block without its indentation: the text
+     following "name::" on the first line, then the indented lines.
+   - `lineno`: the absolute line number of the directive start.
+   - `blockText`: the entire directive text, for error messages.
+*/
+func Run(document *rst.Document, parent *rst.Element, name string, block []string, lineno int, blockText string) []rst.Node {
+	return RunWithState(nil, document, parent, name, block, lineno, blockText)
+}
+
+// Like `Run()`, for a directive found by a parser in the state `state`.
+func RunWithState(state State, document *rst.Document, parent *rst.Element, name string, block []string, lineno int, blockText string) []rst.Node {
+	reporter := document.Reporter()
+	directive, ok := Lookup(name)
+	if !ok {
+		msg := reporter.Error("Unknown directive type \""+name+"\".", "", lineno, literalBlock(blockText))
+		return []rst.Node{msg}
+	}
+	arguments, options, content, contentOffset, err := ParseDirectiveBlock(block, lineno-1, directive)
+	if err != nil {
+		msg := reporter.Error("Error in \""+name+"\" directive:\n"+err.Error()+".", "", lineno, literalBlock(blockText))
+		return []rst.Node{msg}
+	}
+	d := &Directive{
+		Name:          name,
+		Arguments:     arguments,
+		Options:       options,
+		Content:       content,
+		Lineno:        lineno,
+		ContentOffset: contentOffset,
+		BlockText:     blockText,
+		Document:      document,
+		Parent:        parent,
+		State:         state,
+	}
+	result, err := directive.Run(d)
+	if err != nil {
+		level := ErrorLevel
+		if e, ok := err.(*DirectiveError); ok {
+			level = e.level
+		}
+		msg := reporter.SystemMessage(level, err.Error(), "", lineno, literalBlock(blockText))
+		return []rst.Node{msg}
+	}
+	return result
+}
+
+func literalBlock(text string) *rst.Element {
+	block := &rst.Element{}
+	block.Init("literal_block", text, text)
+	return block
+}
+
+func isBlank(line string) bool {
+	return strings.TrimSpace(line) == ""
+}
+
+/*
+   Split a directive block into arguments, options and content.
+
+   Parameters:
+
+   - `indented`: The directive block. Its first line is the text following
+     the directive marker, the rest is the indented block.
+   - `lineOffset`: The offset of the first line of the block.
+   - `directive`: The directive definition.
+
+   Returns a 4-tuple: list of arguments, dict of options, list of strings
+   (content block), and a content offset.
+*/
+func ParseDirectiveBlock(indented []string, lineOffset int, directive *Definition) (arguments []string, options map[string]interface{}, content []string, contentOffset int, err error) {
+	optionSpec := directive.OptionSpec
+	if len(indented) > 0 && isBlank(indented[0]) {
+		indented = indented[1:]
+		lineOffset++
+	}
+	for len(indented) > 0 && isBlank(indented[len(indented)-1]) {
+		indented = indented[:len(indented)-1]
+	}
+	var argBlock []string
+	i := 0
+	if len(indented) > 0 && (directive.RequiredArguments > 0 || directive.OptionalArguments > 0 || optionSpec != nil) {
+		for i < len(indented) && !isBlank(indented[i]) {
+			i++
+		}
+		argBlock = indented[:i]
+		if i < len(indented) {
+			content = indented[i+1:]
+		}
+		contentOffset = lineOffset + i + 1
+	} else {
+		content = indented
+		contentOffset = lineOffset
+	}
+	options = make(map[string]interface{})
+	if optionSpec != nil {
+		options, argBlock, err = parseDirectiveOptions(optionSpec, argBlock)
+		if err != nil {
+			return
+		}
+	}
+	if len(argBlock) > 0 && !(directive.RequiredArguments > 0 || directive.OptionalArguments > 0) {
+		content = append(append([]string(nil), argBlock...), indented[i:]...)
+		contentOffset = lineOffset
+		argBlock = nil
+	}
+	for len(content) > 0 && isBlank(content[0]) {
+		content = content[1:]
+		contentOffset++
+	}
+	if directive.RequiredArguments > 0 || directive.OptionalArguments > 0 {
+		arguments, err = parseDirectiveArguments(directive, argBlock)
+		if err != nil {
+			return
+		}
+	}
+	if len(content) > 0 && !directive.HasContent {
+		err = &MarkupError{"no content permitted"}
+	}
+	return
+}
+
+func parseDirectiveOptions(optionSpec map[string]OptionConverter, argBlock []string) (map[string]interface{}, []string, error) {
+	var optBlock []string
+	for i, line := range argBlock {
+		if fieldMarker.MatchString(line) {
+			optBlock = argBlock[i:]
+			argBlock = argBlock[:i]
+			break
+		}
+	}
+	options := make(map[string]interface{})
+	if len(optBlock) > 0 {
+		if err := extractExtensionOptions(optionSpec, optBlock, options); err != nil {
+			return nil, nil, err
+		}
+	}
+	return options, argBlock, nil
+}
+
+/*
+   Parse the ":name: value" field list of a directive block into `options`
+   and convert the values with the `optionSpec` functions. Field bodies may
+   continue on indented lines.
+*/
+func extractExtensionOptions(optionSpec map[string]OptionConverter, optBlock []string, options map[string]interface{}) error {
+	type field struct {
+		name  string
+		lines []string
+	}
+	var fields []*field
+	for _, line := range optBlock {
+		if m := fieldMarker.FindString(line); m != "" {
+			name := strings.TrimSpace(m)
+			name = name[1 : len(name)-1]
+			fields = append(fields, &field{name, []string{strings.TrimSpace(line[len(m):])}})
+		} else if len(fields) > 0 && len(line) > 0 && (line[0] == ' ' || line[0] == '\t') {
+			f := fields[len(fields)-1]
+			f.lines = append(f.lines, strings.TrimSpace(line))
+		} else {
+			return &MarkupError{"invalid option block"}
+		}
+	}
+	for _, f := range fields {
+		if len(strings.Fields(f.name)) != 1 {
+			return &MarkupError{"extension option field name may not contain multiple words"}
+		}
+		convertor, ok := optionSpec[f.name]
+		if !ok {
+			return &MarkupError{"unknown option: \"" + f.name + "\""}
+		}
+		if _, ok := options[f.name]; ok {
+			return &MarkupError{"duplicate option \"" + f.name + "\""}
+		}
+		value := strings.TrimSpace(strings.Join(f.lines, "\n"))
+		converted, err := convertor(value)
+		if err != nil {
+			return &MarkupError{fmt.Sprintf("invalid option value: (option: \"%s\"; value: %s)\n%s", f.name, pyRepr(value), err.Error())}
+		}
+		options[f.name] = converted
+	}
+	return nil
+}
+
+// Return `value` quoted like Python's repr() of a string, None if empty.
+func pyRepr(value string) string {
+	if value == "" {
+		return "None"
+	}
+	return "'" + strings.Replace(value, "\n", "\\n", -1) + "'"
+}
+
+func parseDirectiveArguments(directive *Definition, argBlock []string) ([]string, error) {
+	required := directive.RequiredArguments
+	optional := directive.OptionalArguments
+	argText := strings.Join(argBlock, "\n")
+	arguments := strings.Fields(argText)
+	if len(arguments) < required {
+		return nil, &MarkupError{fmt.Sprintf("%d argument(s) required, %d supplied", required, len(arguments))}
+	} else if len(arguments) > required+optional {
+		if directive.FinalArgumentWhitespace {
+			arguments = splitN(argText, required+optional)
+		} else {
+			return nil, &MarkupError{fmt.Sprintf("maximum %d argument(s) allowed, %d supplied", required+optional, len(arguments))}
+		}
+	}
+	return arguments, nil
+}
+
+// Split `text` at whitespace into at most `n` fields, like Python's
+// ``text.split(None, n-1)``.
+func splitN(text string, n int) []string {
+	var fields []string
+	text = strings.TrimLeft(text, " \t\n")
+	for len(fields) < n-1 {
+		i := strings.IndexAny(text, " \t\n")
+		if i < 0 {
+			break
+		}
+		fields = append(fields, text[:i])
+		text = strings.TrimLeft(text[i:], " \t\n")
+	}
+	if text != "" {
+		fields = append(fields, text)
+	}
+	return fields
+}
+
+/*
+   Directive option utility function, supplied to enable options with no
+   argument. The option value is nil.
+*/
+func Flag(argument string) (interface{}, error) {
+	if strings.TrimSpace(argument) != "" {
+		return nil, &ValueError{"no argument is allowed; \"" + argument + "\" supplied"}
+	}
+	return nil, nil
+}
+
+/*
+   Return the argument text, unchanged. (Directive option conversion
+   function.)
+
+   Raise `ValueError` if no argument is found.
+*/
+func UnchangedRequired(argument string) (interface{}, error) {
+	if argument == "" {
+		return nil, &ValueError{"argument required but none supplied"}
+	}
+	return argument, nil
+}
+
+/*
+   Return the argument text, unchanged. (Directive option conversion
+   function.)
+
+   No argument implies empty string ("").
+*/
+func Unchanged(argument string) (interface{}, error) {
+	return argument, nil
+}
+
+/*
+   Return the path argument unwrapped (with newlines removed). (Directive
+   option conversion function.)
+
+   Raise `ValueError` if no argument is found.
+*/
+func Path(argument string) (interface{}, error) {
+	if argument == "" {
+		return nil, &ValueError{"argument required but none supplied"}
+	}
+	var path string
+	for _, s := range strings.Split(argument, "\n") {
+		path += strings.TrimSpace(s)
+	}
+	return path, nil
+}
+
+/*
+   Return the URI argument with whitespace removed. (Directive option
+   conversion function.)
+
+   Raise `ValueError` if no argument is found.
+*/
+func URI(argument string) (interface{}, error) {
+	if argument == "" {
+		return nil, &ValueError{"argument required but none supplied"}
+	}
+	return strings.Join(strings.Fields(argument), ""), nil
+}
+
+// Convert the argument into an integer.
+func Int(argument string) (interface{}, error) {
+	value, err := strconv.Atoi(strings.TrimSpace(argument))
+	if err != nil {
+		return nil, &ValueError{"invalid literal for int() with base 10: " + pyRepr(argument)}
+	}
+	return value, nil
+}
+
+/*
+   Check for a nonnegative integer argument; raise `ValueError` if not.
+   (Directive option conversion function.)
+*/
+func NonnegativeInt(argument string) (interface{}, error) {
+	value, err := Int(argument)
+	if err != nil {
+		return nil, err
+	}
+	if value.(int) < 0 {
+		return nil, &ValueError{"negative value; must be positive or zero"}
+	}
+	return value, nil
+}
+
+// Converts the argument into an integer. Raises ValueError for negative,
+// zero, or non-integer values. (Directive option conversion function.)
+func PositiveInt(argument string) (interface{}, error) {
+	value, err := Int(argument)
+	if err != nil {
+		return nil, err
+	}
+	if value.(int) < 1 {
+		return nil, &ValueError{"negative or zero value; must be positive"}
+	}
+	return value, nil
+}
+
+/*
+   Convert the argument into a list of ID-compatible strings and return
+   it. (Directive option conversion function.)
+
+   Raise `ValueError` if no argument is found.
+*/
+func ClassOption(argument string) (interface{}, error) {
+	if argument == "" {
+		return nil, &ValueError{"argument required but none supplied"}
+	}
+	var classNames []string
+	for _, name := range strings.Fields(argument) {
+		className := rst.MakeID(name)
+		if className == "" {
+			return nil, &ValueError{"cannot make \"" + name + "\" into a class name"}
+		}
+		classNames = append(classNames, className)
+	}
+	return classNames, nil
+}
+
+var unicodePattern = regexp.MustCompile(`(?i)^(?:(?:0x|x|\\x|U\+?|\\u)([0-9a-f]+)|&#x([0-9a-f]+);)$`)
+
+/*
+   Convert a Unicode character code to a Unicode character.
+
+   Codes may be decimal numbers, hexadecimal numbers (prefixed by ``0x``,
+   ``x``, ``\x``, ``U+``, ``u``, or ``\u``; e.g. ``U+262E``), or XML-style
+   numeric character entities (e.g. ``&#x262E;``). Other text remains
+   as-is.
+
+   Raise `ValueError` for illegal Unicode code values.
+*/
+func UnicodeCode(code string) (string, error) {
+	var value string
+	base := 10
+	if strings.Trim(code, "0123456789") == "" {
+		// decimal number
+		value = code
+	} else if match := unicodePattern.FindStringSubmatch(code); match != nil {
+		// hex number
+		value = match[1] + match[2]
+		base = 16
+	} else {
+		// other text
+		return code, nil
+	}
+	n, err := strconv.ParseInt(value, base, 32)
+	if err != nil || n > unicode.MaxRune {
+		return "", &ValueError{"code too large (" + code + ")"}
+	}
+	return string(rune(n)), nil
+}
+
+/*
+   Directive option utility function, supplied to enable options whose
+   argument must be a member of a finite set of possible values (must be
+   lower case). A custom conversion function must be written to use it.
+   For example::
+
+       func yesno(argument string) (interface{}, error) {
+           return directives.Choice(argument, []string{"yes", "no"})
+       }
+
+   Raise `ValueError` if no argument is found or if the argument's value is
+   not valid (not an entry in the supplied list).
+*/
+func Choice(argument string, values []string) (interface{}, error) {
+	value := strings.ToLower(strings.TrimSpace(argument))
+	if argument == "" {
+		return nil, &ValueError{"must supply an argument; choose from " + formatValues(values)}
+	}
+	for _, v := range values {
+		if v == value {
+			return value, nil
+		}
+	}
+	return nil, &ValueError{"\"" + argument + "\" unknown; choose from " + formatValues(values)}
+}
+
+func formatValues(values []string) string {
+	var quoted []string
+	for _, v := range values[:len(values)-1] {
+		quoted = append(quoted, "\""+v+"\"")
+	}
+	return strings.Join(quoted, ", ") + ", or \"" + values[len(values)-1] + "\""
+}
+
+// Return the "class" option value (see `ClassOption`), nil if unset.
+func classes(options map[string]interface{}) []string {
+	if value, ok := options["class"]; ok {
+		return value.([]string)
+	}
+	return nil
+}
+
+// Error raised by directive functions: a system message level and text.
+type DirectiveError struct {
+	level int
+	msg   string
+}
+
+func (e *DirectiveError) Error() string {
+	return e.msg
+}
+
+// Error in the syntax of a directive block.
+type MarkupError struct {
+	msg string
+}
+
+func (e *MarkupError) Error() string {
+	return e.msg
+}
+
+// Invalid directive option value.
+type ValueError struct {
+	msg string
+}
+
+func (e *ValueError) Error() string {
+	return e.msg
+}
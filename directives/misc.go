@@ -0,0 +1,354 @@
+package directives
+
+/*
+Implementation of miscellaneous directives in Python docutils: raw,
+replace, unicode and date; and speaker-notes (no Python docutils
+counterpart)
+
+URL of Python source code:
+http://sourceforge.net/p/docutils/code/HEAD/tree/trunk/docutils/docutils/parsers/rst/directives/misc.py
+*/
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	rst "github.com/siongui/go-rst"
+)
+
+func init() {
+	Register("raw", Raw)
+	Register("replace", Replace)
+	Register("unicode", Unicode)
+	Register("date", Date)
+	Register("speaker-notes", SpeakerNotes)
+}
+
+/*
+   Pass-through content (e.g. for HTML output only).
+
+   The argument names the output format(s); writers include the content
+   only if it matches their format. The content is either given in the
+   directive or read from a local file ("file" option, relative to the
+   source document); remote content is not supported. The directive is
+   disabled unless the "RawEnabled" setting is true, the "file" option
+   unless the "FileInsertionEnabled" setting is true.
+*/
+var Raw = &Definition{
+	RequiredArguments:       1,
+	FinalArgumentWhitespace: true,
+	OptionSpec: map[string]OptionConverter{
+		"file":  Path,
+		"class": ClassOption,
+	},
+	HasContent: true,
+	Run:        runRaw,
+}
+
+func runRaw(d *Directive) ([]rst.Node, error) {
+	settings := d.Document.Settings()
+	if _, ok := d.Options["file"]; !settings.RawEnabled || ok && !settings.FileInsertionEnabled {
+		return nil, d.Warning("\"" + d.Name + "\" directive disabled.")
+	}
+	var text, source string
+	if len(d.Content) > 0 {
+		if _, ok := d.Options["file"]; ok {
+			return nil, d.Error("\"" + d.Name + "\" directive may not both specify an external file and have content.")
+		}
+		text = strings.Join(d.Content, "\n")
+	} else if value, ok := d.Options["file"]; ok {
+		sourceDir := filepath.Dir(d.Document.Get("source"))
+		source = filepath.Clean(filepath.Join(sourceDir, value.(string)))
+		data, err := os.ReadFile(source)
+		if err != nil {
+			return nil, d.Severe("Problems with \"" + d.Name + "\" directive path:\n" + err.Error() + ".")
+		}
+		text = string(data)
+	} else {
+		// This will always fail because there is no content.
+		if err := d.AssertHasContent(); err != nil {
+			return nil, err
+		}
+	}
+	node := &rst.Element{}
+	node.Init("raw", "", text)
+	node.Classes = classes(d.Options)
+	node.Set("format", strings.ToLower(strings.Join(strings.Fields(d.Arguments[0]), " ")))
+	if source != "" {
+		node.Set("source", source)
+	}
+	node.SetLine(d.Lineno)
+	return []rst.Node{node}, nil
+}
+
+// Return an error unless the directive is used in a substitution definition.
+func (d *Directive) assertSubstitutionDef() error {
+	if d.Parent == nil || d.Parent.TagName() != "substitution_definition" {
+		return d.Error("Invalid context: the \"" + d.Name + "\" directive can only be used within a substitution definition.")
+	}
+	return nil
+}
+
+/*
+   Text replacement in a substitution definition. The content is a single
+   paragraph, whose inline markup is parsed by the parser running the
+   directive; without a parser its text is used as is.
+*/
+var Replace = &Definition{
+	HasContent: true,
+	Run:        runReplace,
+}
+
+func runReplace(d *Directive) ([]rst.Node, error) {
+	if err := d.assertSubstitutionDef(); err != nil {
+		return nil, err
+	}
+	if err := d.AssertHasContent(); err != nil {
+		return nil, err
+	}
+	if d.State != nil {
+		return d.replaceParsed()
+	}
+	// element might contain only a single paragraph
+	var lines []string
+	for _, line := range d.Content {
+		if isBlank(line) {
+			lines = append(lines, "")
+		} else if len(lines) > 0 && lines[len(lines)-1] == "" {
+			return nil, d.Error("Error in \"" + d.Name + "\" directive: may contain a single paragraph only.")
+		} else {
+			lines = append(lines, strings.TrimSpace(line))
+		}
+	}
+	text := strings.TrimRight(strings.Join(lines, "\n"), "\n")
+	node := &rst.Text{}
+	node.Init(text, text)
+	return []rst.Node{node}, nil
+}
+
+// Parse the content of the "replace" directive with the parser state.
+func (d *Directive) replaceParsed() ([]rst.Node, error) {
+	element := &rst.Element{}
+	element.Init("", strings.Join(d.Content, "\n"), "")
+	if err := d.State.NestedParse(d.Content, d.ContentOffset, element, false); err != nil {
+		return nil, err
+	}
+	// element might contain [paragraph] + system_message(s)
+	var node *rst.Element
+	var messages []rst.Node
+	for _, child := range element.Children() {
+		elem, ok := child.(*rst.Element)
+		switch {
+		case ok && node == nil && elem.TagName() == "paragraph":
+			node = elem
+		case ok && elem.TagName() == "system_message":
+			elem.Backrefs = nil
+			messages = append(messages, elem)
+		default:
+			msg := d.Document.Reporter().Error("Error in \""+d.Name+"\" directive: may contain a single paragraph only.", "", d.Lineno)
+			return []rst.Node{msg}, nil
+		}
+	}
+	if node != nil {
+		return append(messages, node.Children()...), nil
+	}
+	return messages, nil
+}
+
+/*
+   Convert Unicode character codes (numbers) to characters. Codes may be
+   decimal numbers, hexadecimal numbers (prefixed by ``0x``, ``x``, ``\x``,
+   ``U+``, ``u``, or ``\u``; e.g. ``U+262E``), or XML-style numeric
+   character entities (e.g. ``&#x262E;``). Text following ".." is a
+   comment and is ignored. The "trim", "ltrim" and "rtrim" options remove
+   the whitespace around the substitution reference.
+*/
+var Unicode = &Definition{
+	RequiredArguments:       1,
+	FinalArgumentWhitespace: true,
+	OptionSpec: map[string]OptionConverter{
+		"trim":  Flag,
+		"ltrim": Flag,
+		"rtrim": Flag,
+	},
+	Run: runUnicode,
+}
+
+var commentPattern = regexp.MustCompile(`( |\n|^)\.\. `)
+
+func runUnicode(d *Directive) ([]rst.Node, error) {
+	if err := d.assertSubstitutionDef(); err != nil {
+		return nil, err
+	}
+	substitutionDefinition := d.Parent
+	if _, ok := d.Options["trim"]; ok {
+		substitutionDefinition.Set("ltrim", "1")
+		substitutionDefinition.Set("rtrim", "1")
+	}
+	if _, ok := d.Options["ltrim"]; ok {
+		substitutionDefinition.Set("ltrim", "1")
+	}
+	if _, ok := d.Options["rtrim"]; ok {
+		substitutionDefinition.Set("rtrim", "1")
+	}
+	codes := strings.Fields(commentPattern.Split(d.Arguments[0], 2)[0])
+	var nodes []rst.Node
+	for _, code := range codes {
+		decoded, err := UnicodeCode(code)
+		if err != nil {
+			return nil, d.Error("Invalid character code: " + code + "\n" + err.Error())
+		}
+		node := &rst.Text{}
+		node.Init(decoded, decoded)
+		nodes = append(nodes, node)
+	}
+	return nodes, nil
+}
+
+/*
+   Speaker notes of a slide: a container with the class "speaker-notes",
+   which the slide writer shows apart from the slides (other writers show
+   it as a plain container). The content is body elements, parsed by the
+   parser running the directive; without a parser it is paragraphs
+   separated by blank lines, whose text is used as is.
+
+   Options: "class" and "name".
+*/
+var SpeakerNotes = &Definition{
+	OptionSpec: map[string]OptionConverter{
+		"class": ClassOption,
+		"name":  Unchanged,
+	},
+	HasContent: true,
+	Run:        runSpeakerNotes,
+}
+
+func runSpeakerNotes(d *Directive) ([]rst.Node, error) {
+	if err := d.AssertHasContent(); err != nil {
+		return nil, err
+	}
+	container := &rst.Element{}
+	container.Init("container", strings.Join(d.Content, "\n"), "")
+	container.Classes = append([]string{"speaker-notes"}, classes(d.Options)...)
+	d.AddName(container)
+	if d.State != nil {
+		if err := d.State.NestedParse(d.Content, d.ContentOffset, container, false); err != nil {
+			return nil, err
+		}
+		return []rst.Node{container}, nil
+	}
+	for _, block := range strings.Split(strings.Join(d.Content, "\n"), "\n\n") {
+		var lines []string
+		for _, line := range strings.Split(block, "\n") {
+			if !isBlank(line) {
+				lines = append(lines, strings.TrimSpace(line))
+			}
+		}
+		if len(lines) == 0 {
+			continue
+		}
+		text := strings.Join(lines, "\n")
+		paragraph := &rst.Element{}
+		paragraph.Init("paragraph", text, text)
+		container.Append(paragraph)
+	}
+	return []rst.Node{container}, nil
+}
+
+/*
+   The current date (or time), formatted by the content: a strftime format
+   string (default "%Y-%m-%d"). If the SOURCE_DATE_EPOCH environment
+   variable is set, its timestamp is used instead of the current time, for
+   reproducible builds.
+*/
+var Date = &Definition{
+	HasContent: true,
+	Run:        runDate,
+}
+
+func runDate(d *Directive) ([]rst.Node, error) {
+	if err := d.assertSubstitutionDef(); err != nil {
+		return nil, err
+	}
+	format := strings.Join(d.Content, "\n")
+	if format == "" {
+		format = "%Y-%m-%d"
+	}
+	now := time.Now()
+	if epoch := os.Getenv("SOURCE_DATE_EPOCH"); epoch != "" {
+		seconds, err := strconv.ParseInt(epoch, 10, 64)
+		if err != nil {
+			return nil, d.Error("Invalid SOURCE_DATE_EPOCH value: " + epoch)
+		}
+		now = time.Unix(seconds, 0).UTC()
+	}
+	text := strftime(format, now)
+	node := &rst.Text{}
+	node.Init(text, text)
+	return []rst.Node{node}, nil
+}
+
+// Format `t` like Python's time.strftime() (C locale).
+func strftime(format string, t time.Time) string {
+	var b strings.Builder
+	for i := 0; i < len(format); i++ {
+		if format[i] != '%' || i+1 == len(format) {
+			b.WriteByte(format[i])
+			continue
+		}
+		i++
+		switch format[i] {
+		case 'a':
+			b.WriteString(t.Format("Mon"))
+		case 'A':
+			b.WriteString(t.Format("Monday"))
+		case 'b', 'h':
+			b.WriteString(t.Format("Jan"))
+		case 'B':
+			b.WriteString(t.Format("January"))
+		case 'c':
+			b.WriteString(t.Format("Mon Jan _2 15:04:05 2006"))
+		case 'd':
+			fmt.Fprintf(&b, "%02d", t.Day())
+		case 'H':
+			fmt.Fprintf(&b, "%02d", t.Hour())
+		case 'I':
+			fmt.Fprintf(&b, "%02d", (t.Hour()+11)%12+1)
+		case 'j':
+			fmt.Fprintf(&b, "%03d", t.YearDay())
+		case 'm':
+			fmt.Fprintf(&b, "%02d", int(t.Month()))
+		case 'M':
+			fmt.Fprintf(&b, "%02d", t.Minute())
+		case 'p':
+			b.WriteString(t.Format("PM"))
+		case 'S':
+			fmt.Fprintf(&b, "%02d", t.Second())
+		case 'w':
+			fmt.Fprintf(&b, "%d", int(t.Weekday()))
+		case 'x':
+			b.WriteString(t.Format("01/02/06"))
+		case 'X':
+			b.WriteString(t.Format("15:04:05"))
+		case 'y':
+			fmt.Fprintf(&b, "%02d", t.Year()%100)
+		case 'Y':
+			fmt.Fprintf(&b, "%d", t.Year())
+		case 'z':
+			b.WriteString(t.Format("-0700"))
+		case 'Z':
+			b.WriteString(t.Format("MST"))
+		case '%':
+			b.WriteByte('%')
+		default:
+			b.WriteByte('%')
+			b.WriteByte(format[i])
+		}
+	}
+	return b.String()
+}
@@ -0,0 +1,179 @@
+package directives
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	rst "github.com/siongui/go-rst"
+	"github.com/siongui/go-rst/transforms"
+)
+
+func TestRaw(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "footer.html"), []byte("<hr>\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	settings := &rst.Settings{}
+	settings.Init()
+	settings.WarningStream = nil
+	document := rst.NewDocument(filepath.Join(dir, "test.rst"), settings)
+	result := Run(document, &document.Element, "raw", []string{"html", "", "<b>x</b>"}, 1, ".. raw:: html")
+	if result[0].AsText() != document.Get("source")+":1: (WARNING/2) \"raw\" directive disabled.\n\n.. raw:: html" {
+		t.Error("disabled raw directive not reported: " + result[0].AsText())
+	}
+
+	settings.RawEnabled = true
+	document = rst.NewDocument(filepath.Join(dir, "test.rst"), settings)
+	document.Extend(Run(document, &document.Element, "raw", []string{"HTML  LaTeX", ":class: special", "", "<b>x</b>"}, 1, "")...)
+	document.Extend(Run(document, &document.Element, "raw", []string{"html", ":file: footer.html"}, 5, "")...)
+	document.Extend(Run(document, &document.Element, "raw", []string{"html", ":file: missing.html"}, 7, "")...)
+	document.Extend(Run(document, &document.Element, "raw", []string{"html", ":file: footer.html", "", "text"}, 9, "")...)
+
+	expected := `<document source="` + dir + `/test.rst">
+    <raw classes="special" format="html latex">
+        <b>x</b>
+    <raw format="html" source="` + dir + `/footer.html">
+        <hr>
+    <system_message level="4" line="7" source="` + dir + `/test.rst" type="SEVERE">
+        <paragraph>
+            Problems with "raw" directive path:
+            open ` + dir + `/missing.html: no such file or directory.
+        <literal_block>
+    <system_message level="3" line="9" source="` + dir + `/test.rst" type="ERROR">
+        <paragraph>
+            "raw" directive may not both specify an external file and have content.
+        <literal_block>
+`
+	if output := document.Pformat("    ", 0); output != expected {
+		t.Error("raw directive failed:\n" + output)
+	}
+
+	settings.FileInsertionEnabled = false
+	result = Run(document, &document.Element, "raw", []string{"html", ":file: footer.html"}, 11, "")
+	if message := result[0].(*rst.Element); message.Get("level") != "2" || message.Children()[0].AsText() != "\"raw\" directive disabled." {
+		t.Error("file insertion not disabled: " + result[0].AsText())
+	}
+}
+
+// Return a substitution definition built by running the directive `name`.
+func substitutionDef(document *rst.Document, subname, name string, block ...string) *rst.Element {
+	subdef := &rst.Element{}
+	subdef.Init("substitution_definition", ".. |"+subname+"| "+name+":: "+block[0], "")
+	subdef.Names = append(subdef.Names, rst.WhitespaceNormalizeName(subname))
+	document.Append(subdef)
+	subdef.Extend(Run(document, subdef, name, block, 1, subdef.RawSource())...)
+	document.NoteSubstitutionDef(subdef, subname, &document.Element)
+	return subdef
+}
+
+func substitutionRef(document *rst.Document, refname string) *rst.Element {
+	subref := &rst.Element{}
+	subref.Init("substitution_reference", "|"+refname+"|", refname)
+	document.NoteSubstitutionRef(subref, refname)
+	return subref
+}
+
+func TestSubstitutions(t *testing.T) {
+	t.Setenv("SOURCE_DATE_EPOCH", "1700000000")
+	settings := &rst.Settings{}
+	settings.Init()
+	settings.WarningStream = nil
+	document := rst.NewDocument("test", settings)
+	substitutionDef(document, "Project", "replace", "", "Go reStructuredText")
+	substitutionDef(document, "copy", "unicode", "0xA9 U+20 x41 66 .. copyright sign", ":trim:")
+	substitutionDef(document, "today", "date", "", "%Y-%m-%d %a")
+	loop := &rst.Element{}
+	loop.Init("substitution_definition", ".. |loop| replace:: |loop|", "", substitutionRef(document, "loop"))
+	loop.SetLine(7)
+	document.Append(loop)
+	document.NoteSubstitutionDef(loop, "loop", nil)
+
+	paragraph := &rst.Element{}
+	paragraph.Init("paragraph", "", "")
+	text := &rst.Text{}
+	text.Init("by ", "")
+	reference := &rst.Element{}
+	reference.Init("reference", "", "", substitutionRef(document, "project"))
+	reference.Set("refname", "project")
+	paragraph.Extend(substitutionRef(document, "Project"), text, substitutionRef(document, "copy"),
+		substitutionRef(document, "today"), reference, substitutionRef(document, "missing"), substitutionRef(document, "loop"))
+	document.Append(paragraph)
+
+	transformer := &transforms.Transformer{}
+	transformer.Init(document)
+	transformer.AddTransforms([]rst.Transform{&transforms.Substitutions{}, &transforms.Messages{}})
+	if err := transformer.ApplyTransforms(); err != nil {
+		t.Fatal(err)
+	}
+	expected := `<document source="test">
+    <substitution_definition names="Project">
+        Go reStructuredText
+    <substitution_definition ltrim="1" names="copy" rtrim="1">
+        ©
+         
+        A
+        B
+    <substitution_definition names="today">
+        2023-11-14 Tue
+    <system_message level="3" line="7" source="test" type="ERROR">
+        <paragraph>
+            Circular substitution definition detected:
+        <literal_block>
+            .. |loop| replace:: |loop|
+    <paragraph>
+        Go reStructuredText
+        by
+        ©
+         
+        A
+        B
+        2023-11-14 Tue
+        <reference refname="project">
+            Go reStructuredText
+        <problematic ids="id2" refid="id1">
+            |missing|
+        <problematic ids="id4" refid="id3">
+            |loop|
+    <section classes="system-messages">
+        <title>
+            Docutils System Messages
+        <system_message backrefs="id2" ids="id1" level="3" source="test" type="ERROR">
+            <paragraph>
+                Undefined substitution referenced: "missing".
+        <system_message backrefs="id4" ids="id3" level="3" source="test" type="ERROR">
+            <paragraph>
+                Circular substitution definition referenced: "loop".
+`
+	if output := document.Pformat("    ", 0); output != expected {
+		t.Error("substitutions failed:\n" + output)
+	}
+
+	result := Run(document, &document.Element, "replace", []string{"", "one", "", "two"}, 1, "")
+	if result[0].(*rst.Element).Children()[0].AsText() != "Invalid context: the \"replace\" directive can only be used within a substitution definition." {
+		t.Error("invalid context not reported: " + result[0].AsText())
+	}
+}
+
+func TestSpeakerNotes(t *testing.T) {
+	settings := &rst.Settings{}
+	settings.Init()
+	settings.WarningStream = nil
+	document := rst.NewDocument("test", settings)
+	document.Extend(Run(document, &document.Element, "speaker-notes", []string{":class: short", "", "Mention the", "  history.", "", "Ask questions."}, 1, "")...)
+
+	expected := `<document source="test">
+    <container classes="speaker-notes short">
+        <paragraph>
+            Mention the
+            history.
+        <paragraph>
+            Ask questions.
+`
+	if output := document.Pformat("    ", 0); output != expected {
+		t.Error("speaker-notes directive failed:\n" + output)
+	}
+	if result := Run(document, nil, "speaker-notes", nil, 9, ""); result[0].TagName() != "system_message" {
+		t.Error("speaker notes without content not reported")
+	}
+}
@@ -0,0 +1,123 @@
+package directives
+
+/*
+Implementation of document parts directives in Python docutils: contents
+and sectnum
+
+URL of Python source code:
+http://sourceforge.net/p/docutils/code/HEAD/tree/trunk/docutils/docutils/parsers/rst/directives/parts.py
+*/
+
+import (
+	rst "github.com/siongui/go-rst"
+	"github.com/siongui/go-rst/transforms"
+)
+
+func init() {
+	Register("contents", Contents)
+	Register("sectnum", Sectnum)
+	Register("section-numbering", Sectnum)
+}
+
+var backlinksValues = []string{"top", "entry", "none"}
+
+func backlinks(argument string) (interface{}, error) {
+	value, err := Choice(argument, backlinksValues)
+	if err != nil {
+		return nil, err
+	}
+	if value == "none" {
+		return "", nil
+	}
+	return value, nil
+}
+
+/*
+   Table of contents.
+
+   The table of contents is generated in two passes: initial parse and
+   transform. During the initial parse, a 'pending' element is generated
+   which acts as a placeholder, storing the TOC title and any options
+   internally. At a later stage in the processing, the 'pending' element is
+   replaced by a 'topic' element, a title and the table of contents proper.
+*/
+var Contents = &Definition{
+	OptionalArguments:       1,
+	FinalArgumentWhitespace: true,
+	OptionSpec: map[string]OptionConverter{
+		"depth":     NonnegativeInt,
+		"local":     Flag,
+		"backlinks": backlinks,
+		"class":     ClassOption,
+	},
+	Run: runContents,
+}
+
+func runContents(d *Directive) ([]rst.Node, error) {
+	if d.Parent != nil {
+		switch d.Parent.TagName() {
+		case "document", "section", "sidebar":
+		default:
+			return nil, d.Error("The \"" + d.Name + "\" directive may not be used within topics or body elements.")
+		}
+	}
+	document := d.Document
+	language := rst.GetLanguage(document.Settings().LanguageCode)
+	_, local := d.Options["local"]
+	var title *rst.Element
+	var messages []rst.Node
+	if len(d.Arguments) > 0 && d.State != nil {
+		var textNodes []rst.Node
+		textNodes, messages = d.State.InlineText(d.Arguments[0], d.Lineno)
+		title = &rst.Element{}
+		title.Init("title", d.Arguments[0], "", textNodes...)
+	} else if len(d.Arguments) > 0 {
+		title = &rst.Element{}
+		title.Init("title", d.Arguments[0], d.Arguments[0])
+	} else if !local {
+		title = &rst.Element{}
+		title.Init("title", "", language.Labels["contents"])
+	}
+	topic := &rst.Element{}
+	topic.Init("topic", "", "")
+	topic.Classes = append(topic.Classes, "contents")
+	topic.Classes = append(topic.Classes, classes(d.Options)...)
+	topic.SetSource(document.Source())
+	topic.SetLine(d.Lineno - 1)
+	if local {
+		topic.Classes = append(topic.Classes, "local")
+	}
+	var name string
+	if title != nil {
+		name = title.AsText()
+		topic.Append(title)
+	} else {
+		name = language.Labels["contents"]
+	}
+	name = rst.FullyNormalizeName(name)
+	if !document.HasName(name) {
+		topic.Names = append(topic.Names, name)
+	}
+	document.NoteImplicitTarget(topic, nil)
+	pending := rst.NewPending(&transforms.Contents{}, d.Options, d.BlockText)
+	document.NotePending(pending, -1)
+	topic.Append(pending)
+	return append([]rst.Node{topic}, messages...), nil
+}
+
+// Automatic section numbering.
+var Sectnum = &Definition{
+	OptionSpec: map[string]OptionConverter{
+		"depth":  Int,
+		"start":  Int,
+		"prefix": UnchangedRequired,
+		"suffix": UnchangedRequired,
+	},
+	Run: runSectnum,
+}
+
+func runSectnum(d *Directive) ([]rst.Node, error) {
+	pending := rst.NewPending(&transforms.SectNum{}, d.Options, "")
+	d.Document.NotePending(pending, -1)
+	return []rst.Node{pending}, nil
+}
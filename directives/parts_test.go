@@ -0,0 +1,201 @@
+package directives
+
+import (
+	"strings"
+	"testing"
+
+	rst "github.com/siongui/go-rst"
+	"github.com/siongui/go-rst/parsers/docutilsxml"
+	"github.com/siongui/go-rst/transforms"
+)
+
+const sections = `<document source="test">
+    <section ids="one" names="one">
+        <title>One</title>
+        <section ids="sub-one" names="sub\ one"><title>Sub One</title></section>
+    </section>
+    <section ids="two" names="two"><title>Two</title></section>
+</document>`
+
+func applyTransforms(t *testing.T, document *rst.Document) {
+	transformer := &transforms.Transformer{}
+	transformer.Init(document)
+	if err := transformer.ApplyTransforms(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestContentsSectnum(t *testing.T) {
+	document, err := docutilsxml.ParseDocument(sections, "test", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	document.Insert(0, append(Run(document, &document.Element, "sectnum", nil, 1, ".. sectnum::"),
+		Run(document, &document.Element, "contents", []string{"Table of Contents", ":depth: 1"}, 3, ".. contents:: Table of Contents")...)...)
+	applyTransforms(t, document)
+
+	expected := `<document source="test">
+    <topic classes="contents" ids="table-of-contents" names="table\ of\ contents">
+        <title>
+            Table of Contents
+        <bullet_list classes="auto-toc">
+            <list_item>
+                <paragraph>
+                    <reference ids="id1" refid="one">
+                        <generated classes="sectnum">
+                            1&nbsp;&nbsp;&nbsp;
+                        One
+            <list_item>
+                <paragraph>
+                    <reference ids="id2" refid="two">
+                        <generated classes="sectnum">
+                            2&nbsp;&nbsp;&nbsp;
+                        Two
+    <section ids="one" names="one">
+        <title auto="1" refid="id1">
+            <generated classes="sectnum">
+                1&nbsp;&nbsp;&nbsp;
+            One
+        <section ids="sub-one" names="sub\ one">
+            <title auto="1">
+                <generated classes="sectnum">
+                    1.1&nbsp;&nbsp;&nbsp;
+                Sub One
+    <section ids="two" names="two">
+        <title auto="1" refid="id2">
+            <generated classes="sectnum">
+                2&nbsp;&nbsp;&nbsp;
+            Two
+`
+	// show the no-break spaces following section numbers
+	output := strings.Replace(document.Pformat("    ", 0), "\u00a0", "&nbsp;", -1)
+	if output != expected {
+		t.Error("contents/sectnum failed:\n" + output)
+	}
+}
+
+func TestContentsLocal(t *testing.T) {
+	document, err := docutilsxml.ParseDocument(sections, "test", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	section := document.Children()[0].(*rst.Element)
+	section.Insert(1, Run(document, section, "contents", []string{"", ":local:", ":backlinks: none"}, 3, ".. contents::")...)
+	applyTransforms(t, document)
+
+	expected := `<document source="test">
+    <section ids="one" names="one">
+        <title>
+            One
+        <topic classes="contents local" ids="contents" names="contents">
+            <bullet_list>
+                <list_item>
+                    <paragraph>
+                        <reference ids="id1" refid="sub-one">
+                            Sub One
+        <section ids="sub-one" names="sub\ one">
+            <title>
+                Sub One
+    <section ids="two" names="two">
+        <title>
+            Two
+`
+	if output := document.Pformat("    ", 0); output != expected {
+		t.Error("local contents failed:\n" + output)
+	}
+}
+
+func TestContentsWithoutIds(t *testing.T) {
+	// elements not registered in the document, as parsed from
+	// Docutils XML: sections without ids, sections without title
+	input := `<document source="test">
+    <section/>
+    <section><paragraph>Text.</paragraph></section>
+    <section><title>Titled</title></section>
+</document>`
+	document, err := docutilsxml.ParseDocument(input, "test", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// and a contents topic without id
+	result := Run(document, &document.Element, "contents", []string{"", ":backlinks: top"}, 1, ".. contents::")
+	topic := result[0].(*rst.Element)
+	document.RemoveID(topic.Ids[0])
+	topic.Ids = nil
+	topic.Names = nil
+	document.Insert(0, result...)
+	applyTransforms(t, document)
+
+	expected := `<document source="test">
+    <topic classes="contents" ids="id1">
+        <title>
+            Contents
+        <bullet_list>
+            <list_item>
+                <paragraph>
+                    <reference ids="id3" refid="id2">
+                        Titled
+    <section>
+    <section>
+        <paragraph>
+            Text.
+    <section ids="id2">
+        <title refid="id1">
+            Titled
+`
+	if output := document.Pformat("    ", 0); output != expected {
+		t.Error("contents without ids failed:\n" + output)
+	}
+}
+
+func TestSectnumUntitled(t *testing.T) {
+	input := `<document source="test">
+    <section/>
+    <section><paragraph>Text.</paragraph></section>
+    <section><title>Titled</title></section>
+</document>`
+	document, err := docutilsxml.ParseDocument(input, "test", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	document.Insert(0, Run(document, &document.Element, "sectnum", nil, 1, ".. sectnum::")...)
+	applyTransforms(t, document)
+
+	expected := `<document source="test">
+    <section>
+    <section>
+        <paragraph>
+            Text.
+    <section>
+        <title auto="1">
+            <generated classes="sectnum">
+                1&nbsp;&nbsp;&nbsp;
+            Titled
+`
+	if output := strings.Replace(document.Pformat("    ", 0), "\u00a0", "&nbsp;", -1); output != expected {
+		t.Error("sectnum of untitled sections failed:\n" + output)
+	}
+}
+
+func TestDirectiveErrors(t *testing.T) {
+	settings := &rst.Settings{}
+	settings.Init()
+	settings.WarningStream = nil
+	document := rst.NewDocument("test", settings)
+	paragraph := &rst.Element{}
+	paragraph.Init("paragraph", "", "")
+	result := Run(document, paragraph, "contents", nil, 5, ".. contents::")
+	if len(result) != 1 || result[0].AsText() != "test:5: (ERROR/3) The \"contents\" directive may not be used within topics or body elements.\n\n.. contents::" {
+		t.Error("contents in body element failed: " + result[0].AsText())
+	}
+
+	result = Run(document, nil, "sectnum", []string{"", ":depth: x"}, 7, ".. sectnum::")
+	if result[0].TagName() != "system_message" || result[0].(*rst.Element).Get("level") != "3" {
+		t.Error("invalid option value not reported")
+	}
+
+	result = Run(document, nil, "nosuchdirective", nil, 9, ".. nosuchdirective::")
+	if result[0].AsText() != "test:9: (ERROR/3) Unknown directive type \"nosuchdirective\".\n\n.. nosuchdirective::" {
+		t.Error("unknown directive failed: " + result[0].AsText())
+	}
+}
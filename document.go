@@ -0,0 +1,618 @@
+package rst
+
+/*
+Implementation of the document root element of Python docutils
+
+URL of Python source code:
+http://sourceforge.net/p/docutils/code/HEAD/tree/trunk/docutils/docutils/nodes.py
+*/
+
+import (
+	"sort"
+	"strconv"
+	"strings"
+)
+
+/*
+   The document root element.
+
+   Do not instantiate this class directly; use `NewDocument()` instead.
+*/
+type Document struct {
+	Element
+
+	// Runtime settings data record.
+	settings *Settings
+
+	// System message generator.
+	reporter *Reporter
+
+	// Mapping of ids to nodes.
+	ids map[string]*Element
+
+	// Mapping of names to unique id's; "" for names which are duplicated.
+	nameids map[string]string
+
+	// Mapping of names to hyperlink type (true: explicit, false: implicit).
+	nametypes map[string]bool
+
+	// Initial auto-id number.
+	idStart int
+
+	// Pending elements noted for processing by a transform, in order.
+	pending []PendingNote
+
+	// Mapping of substitution names to substitution_definition nodes.
+	substitutionDefs map[string]*Element
+
+	// Mapping of case-normalized substitution names to case-sensitive
+	// names.
+	substitutionNames map[string]string
+
+	// System messages generated while applying transforms.
+	transformMessages []*Element
+
+	// Interpreted text roles defined in the document with the "role"
+	// directive, by lowercase name; the values belong to package roles.
+	localRoles map[string]interface{}
+
+	// Name of the role of interpreted text without an explicit role, as
+	// set by the "default-role" directive; "" for the standard default.
+	defaultRole string
+
+	// Mapping of names to lists of referencing nodes.
+	refnames map[string][]*Element
+
+	// Mapping of ids to lists of referencing nodes.
+	refids map[string][]*Element
+
+	// List of indirect target nodes.
+	indirectTargets []*Element
+
+	// Lists of auto-numbered footnotes and of references to them.
+	autofootnotes    []*Element
+	autofootnoteRefs []*Element
+
+	// Lists of symbol footnotes and of references to them.
+	symbolFootnotes    []*Element
+	symbolFootnoteRefs []*Element
+
+	// Lists of manually numbered footnotes and of citations.
+	footnotes []*Element
+	citations []*Element
+
+	// Initial auto-numbered footnote number.
+	AutofootnoteStart int
+
+	// Initial symbol footnote symbol index.
+	SymbolFootnoteStart int
+
+	// Mapping of footnote labels to lists of footnote_reference nodes.
+	footnoteRefs map[string][]*Element
+
+	// Mapping of citation labels to lists of citation_reference nodes.
+	citationRefs map[string][]*Element
+
+	// Functions resolving references to unknown targets, in order.
+	unknownReferenceResolvers []UnknownReferenceResolver
+}
+
+// A pending element noted with `Document.NotePending()`.
+type PendingNote struct {
+	Node     *Element
+	Priority int
+}
+
+/*
+   Return a new empty document object.
+
+   Parameters:
+
+   - `sourcePath`: The path to or description of the source text of the
+     document.
+   - `settings`: Runtime settings. If nil, the default settings are used.
+*/
+func NewDocument(sourcePath string, settings *Settings) *Document {
+	if settings == nil {
+		settings = &Settings{}
+		settings.Init()
+	}
+	reporter := &Reporter{}
+	reporter.Init(sourcePath, settings.ReportLevel, settings.HaltLevel, settings.WarningStream, settings.Debug)
+	d := &Document{}
+	d.Init(settings, reporter)
+	d.Set("source", sourcePath)
+	d.source = sourcePath
+	return d
+}
+
+func (d *Document) Init(settings *Settings, reporter *Reporter) {
+	d.Element.Init("document", "", "")
+	d.document = d
+	d.settings = settings
+	d.reporter = reporter
+	d.ids = make(map[string]*Element)
+	d.nameids = make(map[string]string)
+	d.nametypes = make(map[string]bool)
+	d.substitutionDefs = make(map[string]*Element)
+	d.substitutionNames = make(map[string]string)
+	d.localRoles = make(map[string]interface{})
+	d.refnames = make(map[string][]*Element)
+	d.refids = make(map[string][]*Element)
+	d.footnoteRefs = make(map[string][]*Element)
+	d.citationRefs = make(map[string][]*Element)
+	d.idStart = 1
+	d.AutofootnoteStart = 1
+}
+
+func (d *Document) Settings() *Settings {
+	return d.settings
+}
+
+func (d *Document) Reporter() *Reporter {
+	return d.reporter
+}
+
+// Return the element with id `id`, or nil.
+func (d *Document) GetElementByID(id string) *Element {
+	return d.ids[id]
+}
+
+// Register `node` as the element with id `id`.
+func (d *Document) SetElementID(id string, node *Element) {
+	d.ids[id] = node
+}
+
+// Forget the element with id `id`.
+func (d *Document) RemoveID(id string) {
+	delete(d.ids, id)
+}
+
+// Return the id of the element named `name`, "" if unknown or duplicated.
+func (d *Document) NameID(name string) string {
+	return d.nameids[name]
+}
+
+/*
+   Give `node` an id (made from its first usable name, or automatically
+   generated) unless it has one, register it, and return it.
+
+   A "Duplicate ID" system message is appended to `msgnode` (if not nil)
+   when one of the ids of `node` is already in use by another element.
+*/
+func (d *Document) SetID(node *Element, msgnode *Element) string {
+	for _, id := range node.Ids {
+		if other, ok := d.ids[id]; ok && other != node {
+			msg := d.reporter.Severe("Duplicate ID: \""+id+"\".", "", 0)
+			if msgnode != nil {
+				msgnode.Append(msg)
+			}
+		}
+	}
+	var id string
+	if len(node.Ids) == 0 {
+		for _, name := range node.Names {
+			id = d.settings.IDPrefix + MakeID(name)
+			if _, ok := d.ids[id]; id != "" && !ok {
+				break
+			}
+			id = ""
+		}
+		for id == "" || d.ids[id] != nil {
+			id = d.settings.IDPrefix + d.settings.AutoIDPrefix + strconv.Itoa(d.idStart)
+			d.idStart++
+		}
+		node.Ids = append(node.Ids, id)
+	} else {
+		id = node.Ids[0]
+	}
+	for _, i := range node.Ids {
+		if _, ok := d.ids[i]; !ok {
+			d.ids[i] = node
+		}
+	}
+	return id
+}
+
+/*
+   `self.nameids` maps names to IDs, while `self.nametypes` maps names to
+   booleans representing hyperlink type (true==explicit,
+   false==implicit). This method updates the mappings.
+
+   The following state transition table shows how `self.nameids` ("ids")
+   and `self.nametypes` ("types") change with new input (a call to this
+   method), and what actions are performed ("implicit"-type system
+   messages are INFO/1, and "explicit"-type system messages are ERROR/3):
+
+   ====  =====  ========  ========  =======  ====  =====  =====
+
+       Old State    Input          Action        New State   Notes
+
+   -----------  --------  -----------------  -----------  -----
+   ids   types  new type  sys.msg.  dupname  ids   types
+   ====  =====  ========  ========  =======  ====  =====  =====
+   -     -      explicit  -         -        new   True
+   -     -      implicit  -         -        new   False
+   None  False  explicit  -         -        new   True
+   old   False  explicit  implicit  old      new   True
+   None  True   explicit  explicit  new      None  True
+   old   True   explicit  explicit  new,old  None  True   [#]_
+   None  False  implicit  implicit  new      None  False
+   old   False  implicit  implicit  new,old  None  False
+   None  True   implicit  implicit  new      None  True
+   old   True   implicit  implicit  new      old   True
+   ====  =====  ========  ========  =======  ====  =====  =====
+
+   .. [#] Do not clear the name-to-id map or invalidate the old target if
+
+       both old and new targets are external and refer to identical URIs.
+       The new target is invalidated regardless.
+*/
+func (d *Document) SetNameIDMap(node *Element, id string, msgnode *Element, explicit bool) {
+	for _, name := range append([]string(nil), node.Names...) {
+		if _, ok := d.nameids[name]; ok {
+			d.setDuplicateNameID(node, id, name, msgnode, explicit)
+		} else {
+			d.nameids[name] = id
+			d.nametypes[name] = explicit
+		}
+	}
+}
+
+func (d *Document) setDuplicateNameID(node *Element, id, name string, msgnode *Element, explicit bool) {
+	oldID := d.nameids[name]
+	oldExplicit := d.nametypes[name]
+	d.nametypes[name] = oldExplicit || explicit
+	if explicit {
+		if oldExplicit {
+			level := WarningLevel
+			if oldID != "" {
+				oldNode := d.ids[oldID]
+				if node.HasAttr("refuri") {
+					refuri := node.Get("refuri")
+					if len(oldNode.Names) > 0 && oldNode.HasAttr("refuri") && oldNode.Get("refuri") == refuri {
+						// just inform if refuri's identical
+						level = InfoLevel
+					}
+				}
+				if level > InfoLevel {
+					Dupname(oldNode, name)
+					d.nameids[name] = ""
+				}
+			}
+			msg := d.reporter.SystemMessage(level, "Duplicate explicit target name: \""+name+"\".", node.Source(), node.Line())
+			msg.Backrefs = append(msg.Backrefs, id)
+			if msgnode != nil {
+				msgnode.Append(msg)
+			}
+			Dupname(node, name)
+		} else {
+			d.nameids[name] = id
+			if oldID != "" {
+				Dupname(d.ids[oldID], name)
+			}
+		}
+	} else {
+		if oldID != "" && !oldExplicit {
+			d.nameids[name] = ""
+			Dupname(d.ids[oldID], name)
+		}
+		Dupname(node, name)
+	}
+	if !explicit || (!oldExplicit && oldID != "") {
+		msg := d.reporter.Info("Duplicate implicit target name: \""+name+"\".", node.Source(), node.Line())
+		msg.Backrefs = append(msg.Backrefs, id)
+		if msgnode != nil {
+			msgnode.Append(msg)
+		}
+	}
+}
+
+func (d *Document) HasName(name string) bool {
+	_, ok := d.nameids[name]
+	return ok
+}
+
+func (d *Document) NoteImplicitTarget(target *Element, msgnode *Element) {
+	id := d.SetID(target, msgnode)
+	d.SetNameIDMap(target, id, msgnode, false)
+}
+
+func (d *Document) NoteExplicitTarget(target *Element, msgnode *Element) {
+	id := d.SetID(target, msgnode)
+	d.SetNameIDMap(target, id, msgnode, true)
+}
+
+/*
+   Note a `pending` element for processing by its transform.
+
+   `priority` overrides the transform's default priority if not negative.
+*/
+func (d *Document) NotePending(pending *Element, priority int) {
+	if priority < 0 {
+		priority = pending.Transform.DefaultPriority()
+	}
+	d.pending = append(d.pending, PendingNote{pending, priority})
+}
+
+// Return the pending elements noted since the last call, and forget them.
+func (d *Document) TakePending() []PendingNote {
+	pending := d.pending
+	d.pending = nil
+	return pending
+}
+
+/*
+   Register the substitution definition `subdef` named `defName`. Only the
+   last definition of a name is kept; earlier ones are reported (to
+   `msgnode` if not nil) and get a dupname.
+*/
+func (d *Document) NoteSubstitutionDef(subdef *Element, defName string, msgnode *Element) {
+	name := WhitespaceNormalizeName(defName)
+	if oldnode, ok := d.substitutionDefs[name]; ok {
+		msg := d.reporter.Error("Duplicate substitution definition name: \""+name+"\".", subdef.Source(), subdef.Line())
+		if msgnode != nil {
+			msgnode.Append(msg)
+		}
+		Dupname(oldnode, name)
+	}
+	// keep only the last definition:
+	d.substitutionDefs[name] = subdef
+	// case-insensitive mapping:
+	d.substitutionNames[FullyNormalizeName(name)] = name
+}
+
+// Set the name referenced by the substitution_reference `subref`.
+func (d *Document) NoteSubstitutionRef(subref *Element, refname string) {
+	subref.Set("refname", WhitespaceNormalizeName(refname))
+}
+
+// Return the substitution definition named `name`, or nil.
+func (d *Document) SubstitutionDef(name string) *Element {
+	return d.substitutionDefs[name]
+}
+
+/*
+   Return the name of the substitution definition matching the
+   case-normalized `name`, "" if there is none.
+*/
+func (d *Document) SubstitutionName(name string) string {
+	return d.substitutionNames[name]
+}
+
+// Record a system message generated while applying transforms.
+func (d *Document) NoteTransformMessage(message *Element) {
+	d.transformMessages = append(d.transformMessages, message)
+}
+
+// Return the transform messages noted so far, and forget them.
+func (d *Document) TakeTransformMessages() []*Element {
+	messages := d.transformMessages
+	d.transformMessages = nil
+	return messages
+}
+
+// Register an interpreted text role defined in the document.
+func (d *Document) NoteLocalRole(name string, role interface{}) {
+	d.localRoles[strings.ToLower(name)] = role
+}
+
+// Return the role defined in the document as `name` (case-insensitive).
+func (d *Document) LocalRole(name string) (interface{}, bool) {
+	role, ok := d.localRoles[strings.ToLower(name)]
+	return role, ok
+}
+
+// Set the role of interpreted text without an explicit role.
+func (d *Document) SetDefaultRole(name string) {
+	d.defaultRole = name
+}
+
+// Return the default role set in the document, "" if unset.
+func (d *Document) DefaultRole() string {
+	return d.defaultRole
+}
+
+// Note a node referencing a name (its "refname" attribute).
+func (d *Document) NoteRefname(node *Element) {
+	name := node.Get("refname")
+	d.refnames[name] = append(d.refnames[name], node)
+}
+
+// Note a node referencing an id (its "refid" attribute).
+func (d *Document) NoteRefid(node *Element) {
+	id := node.Get("refid")
+	d.refids[id] = append(d.refids[id], node)
+}
+
+// Return the nodes referencing the name `name`.
+func (d *Document) Refnames(name string) []*Element {
+	return d.refnames[name]
+}
+
+// Return the nodes referencing the id `id`.
+func (d *Document) Refids(id string) []*Element {
+	return d.refids[id]
+}
+
+func (d *Document) NoteIndirectTarget(target *Element) {
+	d.indirectTargets = append(d.indirectTargets, target)
+	if len(target.Names) > 0 {
+		d.NoteRefname(target)
+	}
+}
+
+func (d *Document) IndirectTargets() []*Element {
+	return d.indirectTargets
+}
+
+func (d *Document) NoteAnonymousTarget(target *Element) {
+	d.SetID(target, nil)
+}
+
+func (d *Document) NoteAutofootnote(footnote *Element) {
+	d.SetID(footnote, nil)
+	d.autofootnotes = append(d.autofootnotes, footnote)
+}
+
+func (d *Document) Autofootnotes() []*Element {
+	return d.autofootnotes
+}
+
+func (d *Document) NoteAutofootnoteRef(ref *Element) {
+	d.SetID(ref, nil)
+	d.autofootnoteRefs = append(d.autofootnoteRefs, ref)
+}
+
+func (d *Document) AutofootnoteRefs() []*Element {
+	return d.autofootnoteRefs
+}
+
+func (d *Document) NoteSymbolFootnote(footnote *Element) {
+	d.SetID(footnote, nil)
+	d.symbolFootnotes = append(d.symbolFootnotes, footnote)
+}
+
+func (d *Document) SymbolFootnotes() []*Element {
+	return d.symbolFootnotes
+}
+
+func (d *Document) NoteSymbolFootnoteRef(ref *Element) {
+	d.SetID(ref, nil)
+	d.symbolFootnoteRefs = append(d.symbolFootnoteRefs, ref)
+}
+
+func (d *Document) SymbolFootnoteRefs() []*Element {
+	return d.symbolFootnoteRefs
+}
+
+func (d *Document) NoteFootnote(footnote *Element) {
+	d.SetID(footnote, nil)
+	d.footnotes = append(d.footnotes, footnote)
+}
+
+func (d *Document) Footnotes() []*Element {
+	return d.footnotes
+}
+
+func (d *Document) NoteFootnoteRef(ref *Element) {
+	d.SetID(ref, nil)
+	name := ref.Get("refname")
+	d.footnoteRefs[name] = append(d.footnoteRefs[name], ref)
+	d.NoteRefname(ref)
+}
+
+// Return the footnote_reference nodes referencing the label `name`.
+func (d *Document) FootnoteRefs(name string) []*Element {
+	return d.footnoteRefs[name]
+}
+
+func (d *Document) NoteCitation(citation *Element) {
+	d.citations = append(d.citations, citation)
+}
+
+func (d *Document) Citations() []*Element {
+	return d.citations
+}
+
+func (d *Document) NoteCitationRef(ref *Element) {
+	d.SetID(ref, nil)
+	name := ref.Get("refname")
+	d.citationRefs[name] = append(d.citationRefs[name], ref)
+	d.NoteRefname(ref)
+}
+
+// Return the citation_reference nodes referencing the label `name`.
+func (d *Document) CitationRefs(name string) []*Element {
+	return d.citationRefs[name]
+}
+
+/*
+   Register the elements of the tree `node` built without the reST parser
+   (e.g. from Docutils XML or JSON), as the parser would have: their ids,
+   the names of sections (implicit targets) and of other elements
+   (explicit targets), the referenced names, footnotes (auto-numbered ones
+   unless numbered already), citations and substitution definitions.
+*/
+func (d *Document) NoteTree(node *Element) {
+	for _, n := range node.Traverse(nil) {
+		e, ok := n.(*Element)
+		if !ok {
+			continue
+		}
+		switch {
+		case e.tagname == "substitution_definition":
+			if len(e.Names) > 0 {
+				d.NoteSubstitutionDef(e, e.Names[0], nil)
+			}
+		case len(e.Names) == 0:
+			for _, id := range e.Ids {
+				d.SetElementID(id, e)
+			}
+		case e.tagname == "section" || e.tagname == "document":
+			d.NoteImplicitTarget(e, nil)
+		default:
+			d.NoteExplicitTarget(e, nil)
+		}
+		// auto-numbered footnotes get a label, and their references a
+		// refid, when numbered
+		switch {
+		case e.tagname == "footnote" && e.HasAttr("auto") && (e.Len() == 0 || e.children[0].TagName() != "label"):
+			if e.Get("auto") == "*" {
+				d.NoteSymbolFootnote(e)
+			} else {
+				d.NoteAutofootnote(e)
+			}
+		case e.tagname == "footnote":
+			d.NoteFootnote(e)
+		case e.tagname == "footnote_reference" && e.HasAttr("auto") && !e.HasAttr("refid") && !e.HasAttr("refname"):
+			if e.Get("auto") == "*" {
+				d.NoteSymbolFootnoteRef(e)
+			} else {
+				d.NoteAutofootnoteRef(e)
+			}
+		case e.tagname == "citation":
+			d.NoteCitation(e)
+		case e.tagname == "target" && e.HasAttr("anonymous"):
+			d.NoteAnonymousTarget(e)
+		}
+		if !e.HasAttr("refname") {
+			continue
+		}
+		switch e.tagname {
+		case "substitution_reference":
+		case "target":
+			d.NoteIndirectTarget(e)
+		case "footnote_reference":
+			d.NoteFootnoteRef(e)
+		case "citation_reference":
+			d.NoteCitationRef(e)
+		default:
+			d.NoteRefname(e)
+		}
+	}
+}
+
+/*
+   Add resolvers for references to unknown targets, keeping the resolvers
+   sorted by priority.
+*/
+func (d *Document) AddUnknownReferenceResolvers(resolvers ...UnknownReferenceResolver) {
+	d.unknownReferenceResolvers = append(d.unknownReferenceResolvers, resolvers...)
+	sort.SliceStable(d.unknownReferenceResolvers, func(i, j int) bool {
+		return d.unknownReferenceResolvers[i].Priority < d.unknownReferenceResolvers[j].Priority
+	})
+}
+
+/*
+   Try the unknown reference resolvers on the reference `node`; return true
+   if one of them resolved it.
+*/
+func (d *Document) ResolveUnknownReference(node *Element) bool {
+	for _, resolver := range d.unknownReferenceResolvers {
+		if resolver.Resolve(node) {
+			return true
+		}
+	}
+	return false
+}
@@ -15,8 +15,15 @@ func (e *IndexError) Error() string {
 	return e.msg
 }
 
+/*
+   An indented line was found where the text block had to be flush-left.
+   `Block` holds the lines before it, `Source` and `Lineno` locate it.
+*/
 type UnexpectedIndentationError struct {
-	msg string
+	msg    string
+	Block  StringList
+	Source string
+	Lineno int
 }
 
 func (e *UnexpectedIndentationError) Error() string {
@@ -78,3 +85,107 @@ type TransitionMethodNotFound struct {
 func (e *TransitionMethodNotFound) Error() string {
 	return e.msg
 }
+
+/*
+   Returned by a transition method to try another transition of the
+   current state on the current line: the transition named `Transition`.
+*/
+type TransitionCorrection struct {
+	Transition string
+}
+
+func (e *TransitionCorrection) Error() string {
+	return "TransitionCorrection: " + e.Transition
+}
+
+/*
+   Returned by a transition method (or `State.Eof()`) to switch to the state
+   named `State` and try the current line again, with the transition named
+   `Transition` only ("" for all the transitions of the state).
+*/
+type StateCorrection struct {
+	State      string
+	Transition string
+}
+
+func (e *StateCorrection) Error() string {
+	return "StateCorrection: " + e.State + " " + e.Transition
+}
+
+type SystemMessageError struct {
+	msg string
+}
+
+func (e *SystemMessageError) Error() string {
+	return e.msg
+}
+
+/*
+   Tree pruning errors, returned by `NodeVisitor` methods to change the
+   course of the tree traversal.
+*/
+
+// Do not visit any children of the current node, or its departure method.
+type SkipNode struct {
+	msg string
+}
+
+func (e *SkipNode) Error() string {
+	return e.msg
+}
+
+// Do not visit any children of the current node. The current node's
+// siblings and ``Depart`` method are not affected.
+type SkipChildren struct {
+	msg string
+}
+
+func (e *SkipChildren) Error() string {
+	return e.msg
+}
+
+// Do not visit any more siblings (to the right) of the current node.
+type SkipSiblings struct {
+	msg string
+}
+
+func (e *SkipSiblings) Error() string {
+	return e.msg
+}
+
+// Do not call the current node's ``Depart`` method.
+type SkipDeparture struct {
+	msg string
+}
+
+func (e *SkipDeparture) Error() string {
+	return e.msg
+}
+
+// Stop the traversal altogether. The current node's ``Depart`` method is
+// still called, as are those of its ancestors.
+type StopTraversal struct {
+	msg string
+}
+
+func (e *StopTraversal) Error() string {
+	return e.msg
+}
+
+// Code cannot be analyzed in the requested language.
+type LexerError struct {
+	msg string
+}
+
+func (e *LexerError) Error() string {
+	return e.msg
+}
+
+// LaTeX math code cannot be converted.
+type MathError struct {
+	msg string
+}
+
+func (e *MathError) Error() string {
+	return e.msg
+}
@@ -0,0 +1,249 @@
+package rst
+
+/*
+JSON representation of document trees (no Python docutils counterpart): the
+node types implement `json.Marshaler` and `json.Unmarshaler`.
+
+Each node is a JSON object with its type (the tag name, "#text" for text
+nodes), "source" if it differs from the source of the parent node, and
+"line" if known. Elements have "attributes" (list attributes as arrays of
+strings, the others as strings) and "children"; text nodes have "text", and
+"rawsource" if it differs from the text.
+
+The document (root) object also has "schema", the `JSONSchemaVersion` of
+the representation. Transform state (pending transforms, references
+bookkeeping) is not part of the representation.
+*/
+
+import (
+	"encoding/json"
+	"strconv"
+)
+
+/*
+   Version of the JSON representation of document trees, incremented on
+   incompatible changes.
+*/
+const JSONSchemaVersion = 1
+
+type JSONError struct {
+	msg string
+}
+
+func (e *JSONError) Error() string {
+	return e.msg
+}
+
+type jsonNode struct {
+	Schema     int                        `json:"schema,omitempty"`
+	Type       string                     `json:"type"`
+	Attributes map[string]json.RawMessage `json:"attributes,omitempty"`
+	Text       *string                    `json:"text,omitempty"`
+	RawSource  string                     `json:"rawsource,omitempty"`
+	Children   []json.RawMessage          `json:"children,omitempty"`
+	Source     string                     `json:"source,omitempty"`
+	Line       int                        `json:"line,omitempty"`
+}
+
+// Return the source of `node`, or "" if it is the source of its parent.
+func jsonSource(node Node) string {
+	if parent := node.Parent(); parent != nil && parent.Source() == node.Source() {
+		return ""
+	}
+	return node.Source()
+}
+
+func (t *Text) MarshalJSON() ([]byte, error) {
+	n := jsonNode{Type: "#text", Text: &t.data, Source: jsonSource(t), Line: t.line}
+	if t.rawsource != t.data {
+		n.RawSource = t.rawsource
+	}
+	return json.Marshal(&n)
+}
+
+func (t *Text) UnmarshalJSON(data []byte) error {
+	var n jsonNode
+	if err := json.Unmarshal(data, &n); err != nil {
+		return err
+	}
+	if n.Type != "#text" || n.Text == nil {
+		return &JSONError{"Not a text node: \"" + n.Type + "\"."}
+	}
+	rawsource := n.RawSource
+	if rawsource == "" {
+		rawsource = *n.Text
+	}
+	t.Init(*n.Text, rawsource)
+	t.source, t.line = n.Source, n.Line
+	return nil
+}
+
+func (t *Text) fromJSON(data []byte, parentSource string) error {
+	if err := t.UnmarshalJSON(data); err != nil {
+		return err
+	}
+	if t.source == "" {
+		t.source = parentSource
+	}
+	return nil
+}
+
+func (e *Element) jsonNode() (*jsonNode, error) {
+	n := &jsonNode{Type: e.tagname, Source: jsonSource(e), Line: e.line}
+	n.Attributes = map[string]json.RawMessage{}
+	for name, value := range e.attributes {
+		n.Attributes[name], _ = json.Marshal(value)
+	}
+	for name, values := range e.listAttributes() {
+		if len(values) > 0 {
+			n.Attributes[name], _ = json.Marshal(values)
+		}
+	}
+	for _, child := range e.children {
+		data, err := json.Marshal(child)
+		if err != nil {
+			return nil, err
+		}
+		n.Children = append(n.Children, data)
+	}
+	return n, nil
+}
+
+func (e *Element) MarshalJSON() ([]byte, error) {
+	n, err := e.jsonNode()
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(n)
+}
+
+func (e *Element) UnmarshalJSON(data []byte) error {
+	var n jsonNode
+	if err := json.Unmarshal(data, &n); err != nil {
+		return err
+	}
+	return e.fromJSONNode(&n, "")
+}
+
+// Initialize the element from `n`; the source defaults to `parentSource`.
+func (e *Element) fromJSONNode(n *jsonNode, parentSource string) error {
+	if n.Type == "" || n.Type == "#text" {
+		return &JSONError{"Not an element: \"" + n.Type + "\"."}
+	}
+	e.Init(n.Type, "", "")
+	e.source, e.line = n.Source, n.Line
+	if e.source == "" {
+		e.source = parentSource
+	}
+	if err := e.setJSONAttributes(n.Attributes); err != nil {
+		return err
+	}
+	for _, data := range n.Children {
+		child, err := unmarshalNode(data, e.source)
+		if err != nil {
+			return err
+		}
+		e.Append(child)
+	}
+	return nil
+}
+
+func (e *Element) setJSONAttributes(attributes map[string]json.RawMessage) error {
+	lists := map[string]*[]string{
+		"ids":      &e.Ids,
+		"classes":  &e.Classes,
+		"names":    &e.Names,
+		"dupnames": &e.Dupnames,
+		"backrefs": &e.Backrefs,
+	}
+	for name, data := range attributes {
+		if list, ok := lists[name]; ok {
+			if err := json.Unmarshal(data, list); err != nil {
+				return &JSONError{"Attribute \"" + name + "\" of <" + e.tagname + "> must be a list of strings."}
+			}
+			continue
+		}
+		var value string
+		if err := json.Unmarshal(data, &value); err != nil {
+			// accept numbers and booleans from other tools
+			var other interface{}
+			if json.Unmarshal(data, &other) != nil {
+				return err
+			}
+			switch v := other.(type) {
+			case float64:
+				value = strconv.FormatFloat(v, 'f', -1, 64)
+			case bool:
+				value = strconv.FormatBool(v)
+			default:
+				return &JSONError{"Attribute \"" + name + "\" of <" + e.tagname + "> must be a string."}
+			}
+		}
+		e.attributes[name] = value
+	}
+	return nil
+}
+
+/*
+   Return the node (element or text) represented by `data`, a child of a
+   node with source `parentSource`.
+*/
+func unmarshalNode(data []byte, parentSource string) (Node, error) {
+	var n jsonNode
+	if err := json.Unmarshal(data, &n); err != nil {
+		return nil, err
+	}
+	if n.Type == "#text" {
+		t := &Text{}
+		if err := t.fromJSON(data, parentSource); err != nil {
+			return nil, err
+		}
+		return t, nil
+	}
+	e := &Element{}
+	if err := e.fromJSONNode(&n, parentSource); err != nil {
+		return nil, err
+	}
+	return e, nil
+}
+
+func (d *Document) MarshalJSON() ([]byte, error) {
+	n, err := d.Element.jsonNode()
+	if err != nil {
+		return nil, err
+	}
+	n.Schema = JSONSchemaVersion
+	return json.Marshal(n)
+}
+
+/*
+   Rehydrate the document from its JSON representation. The document must
+   have been created with `NewDocument()`: its settings and reporter are
+   kept, its attributes and children replaced, and the elements registered
+   (see `NoteTree()`).
+*/
+func (d *Document) UnmarshalJSON(data []byte) error {
+	var n jsonNode
+	if err := json.Unmarshal(data, &n); err != nil {
+		return err
+	}
+	if n.Schema != JSONSchemaVersion {
+		return &JSONError{"Unsupported JSON schema version " + strconv.Itoa(n.Schema) +
+			" (expected " + strconv.Itoa(JSONSchemaVersion) + ")."}
+	}
+	if n.Type != "document" {
+		return &JSONError{"Document root must be a \"document\" node, not \"" + n.Type + "\"."}
+	}
+	root := &Element{}
+	if err := root.fromJSONNode(&n, ""); err != nil {
+		return err
+	}
+	d.attributes = root.attributes
+	d.Ids, d.Classes, d.Names, d.Dupnames, d.Backrefs = root.Ids, root.Classes, root.Names, root.Dupnames, root.Backrefs
+	if root.source != "" {
+		d.source = root.source
+	}
+	d.SetChildren(root.children)
+	d.NoteTree(&d.Element)
+	return nil
+}
@@ -0,0 +1,64 @@
+package rst
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestJSON(t *testing.T) {
+	document := NewDocument("test.rst", nil)
+	section := &Element{}
+	section.Init("section", "", "")
+	section.Ids = append(section.Ids, "title")
+	section.Names = append(section.Names, "title")
+	section.SetLine(1)
+	title := &Element{}
+	title.Init("title", "Title", "Title")
+	paragraph := &Element{}
+	paragraph.Init("paragraph", "", "")
+	paragraph.SetLine(4)
+	text := &Text{}
+	text.Init("Some text.", `Some\ text.`)
+	paragraph.Append(text)
+	reference := &Element{}
+	reference.Init("reference", "", "link")
+	reference.Set("refid", "title")
+	paragraph.Append(reference)
+	section.Extend(title, paragraph)
+	document.Append(section)
+
+	data, err := json.Marshal(document)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := `{"schema":1,"type":"document","attributes":{"source":"test.rst"},"children":[` +
+		`{"type":"section","attributes":{"ids":["title"],"names":["title"]},"children":[` +
+		`{"type":"title","children":[{"type":"#text","text":"Title"}],"line":1},` +
+		`{"type":"paragraph","children":[{"type":"#text","text":"Some text.","rawsource":"Some\\ text.","line":4},` +
+		`{"type":"reference","attributes":{"refid":"title"},"children":[{"type":"#text","text":"link"}],"line":4}],"line":4}],` +
+		`"line":1}],"source":"test.rst"}`
+	if string(data) != expected {
+		t.Error("unexpected JSON:\n" + string(data))
+	}
+
+	rehydrated := NewDocument("", nil)
+	if err := json.Unmarshal(data, rehydrated); err != nil {
+		t.Fatal(err)
+	}
+	if result, expected := rehydrated.Pformat("    ", 0), document.Pformat("    ", 0); result != expected {
+		t.Errorf("unexpected document:\n%s\nexpected:\n%s", result, expected)
+	}
+	if rehydrated.GetElementByID("title") == nil || rehydrated.NameID("title") != "title" {
+		t.Error("ids and names not registered")
+	}
+	if p := rehydrated.Children()[0].(*Element).Children()[1]; p.Line() != 4 || p.Source() != "test.rst" {
+		t.Error("source position lost")
+	}
+
+	if err := json.Unmarshal([]byte(`{"schema":99,"type":"document"}`), rehydrated); err == nil {
+		t.Error("unsupported schema accepted")
+	}
+	if err := json.Unmarshal([]byte(`{"schema":1,"type":"document","attributes":{"ids":"x"}}`), rehydrated); err == nil {
+		t.Error("invalid list attribute accepted")
+	}
+}
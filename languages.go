@@ -0,0 +1,170 @@
+package rst
+
+/*
+Language-dependent features of Python docutils: labels of generated text
+
+URL of Python source code:
+http://sourceforge.net/p/docutils/code/HEAD/tree/trunk/docutils/docutils/languages/
+*/
+
+import "strings"
+
+type Language struct {
+	// Mapping of node class name to label text.
+	Labels map[string]string
+}
+
+// Canonical names of the bibliographic fields.
+var bibliographicFields = []string{
+	"author", "authors", "organization", "address", "contact", "version",
+	"revision", "status", "date", "copyright", "dedication", "abstract",
+}
+
+/*
+   Return the mapping of (lowcased) bibliographic field names in the
+   language, as they appear in the source, to canonical field names: the
+   lowercase labels of the bibliographic fields.
+*/
+func (l *Language) BibliographicFields() map[string]string {
+	fields := map[string]string{}
+	for _, name := range bibliographicFields {
+		fields[strings.ToLower(l.Labels[name])] = name
+	}
+	return fields
+}
+
+/*
+   Return the list of separators for author names in a single paragraph,
+   in order of preference.
+*/
+func (l *Language) AuthorSeparators() []string {
+	return []string{";", ","}
+}
+
+var languages = map[string]*Language{
+	"en": {
+		Labels: map[string]string{
+			"author":       "Author",
+			"authors":      "Authors",
+			"organization": "Organization",
+			"address":      "Address",
+			"contact":      "Contact",
+			"version":      "Version",
+			"revision":     "Revision",
+			"status":       "Status",
+			"date":         "Date",
+			"copyright":    "Copyright",
+			"dedication":   "Dedication",
+			"abstract":     "Abstract",
+			"attention":    "Attention!",
+			"caution":      "Caution!",
+			"danger":       "!DANGER!",
+			"error":        "Error",
+			"hint":         "Hint",
+			"important":    "Important",
+			"note":         "Note",
+			"tip":          "Tip",
+			"warning":      "Warning",
+			"contents":     "Contents",
+		},
+	},
+	"de": {
+		Labels: map[string]string{
+			"author":       "Autor",
+			"authors":      "Autoren",
+			"organization": "Organisation",
+			"address":      "Adresse",
+			"contact":      "Kontakt",
+			"version":      "Version",
+			"revision":     "Revision",
+			"status":       "Status",
+			"date":         "Datum",
+			"copyright":    "Copyright",
+			"dedication":   "Widmung",
+			"abstract":     "Zusammenfassung",
+			"attention":    "Achtung!",
+			"caution":      "Vorsicht!",
+			"danger":       "!GEFAHR!",
+			"error":        "Fehler",
+			"hint":         "Hinweis",
+			"important":    "Wichtig",
+			"note":         "Bemerkung",
+			"tip":          "Tipp",
+			"warning":      "Warnung",
+			"contents":     "Inhalt",
+		},
+	},
+	"fr": {
+		Labels: map[string]string{
+			"author":       "Auteur",
+			"authors":      "Auteurs",
+			"organization": "Organisation",
+			"address":      "Adresse",
+			"contact":      "Contact",
+			"version":      "Version",
+			"revision":     "Révision",
+			"status":       "Statut",
+			"date":         "Date",
+			"copyright":    "Copyright",
+			"dedication":   "Dédicace",
+			"abstract":     "Résumé",
+			"attention":    "Attention!",
+			"caution":      "Avertissement!",
+			"danger":       "!DANGER!",
+			"error":        "Erreur",
+			"hint":         "Indication",
+			"important":    "Important",
+			"note":         "Note",
+			"tip":          "Astuce",
+			"warning":      "Avis",
+			"contents":     "Sommaire",
+		},
+	},
+	"es": {
+		Labels: map[string]string{
+			"author":       "Autor",
+			"authors":      "Autores",
+			"organization": "Organización",
+			"address":      "Dirección",
+			"contact":      "Contacto",
+			"version":      "Versión",
+			"revision":     "Revisión",
+			"status":       "Estado",
+			"date":         "Fecha",
+			"copyright":    "Copyright",
+			"dedication":   "Dedicatoria",
+			"abstract":     "Resumen",
+			"attention":    "¡Atención!",
+			"caution":      "¡Precaución!",
+			"danger":       "¡PELIGRO!",
+			"error":        "Error",
+			"hint":         "Sugerencia",
+			"important":    "Importante",
+			"note":         "Nota",
+			"tip":          "Consejo",
+			"warning":      "Advertencia",
+			"contents":     "Contenido",
+		},
+	},
+}
+
+/*
+   Return the language module for `languageCode`.
+
+   The code is tried as given, then with its subtags stripped one by one
+   ("de-AT" falls back to "de"); English is used if none is known.
+*/
+func GetLanguage(languageCode string) *Language {
+	tag := strings.ToLower(strings.Replace(languageCode, "_", "-", -1))
+	for tag != "" {
+		if language, ok := languages[tag]; ok {
+			return language
+		}
+		i := strings.LastIndex(tag, "-")
+		if i < 0 {
+			break
+		}
+		tag = tag[:i]
+	}
+	return languages["en"]
+}
@@ -0,0 +1,645 @@
+package rst
+
+/*
+Convert LaTeX math code into presentational MathML, as in Python docutils
+utils/math/latex2mathml.py, and other math utilities of utils/math
+
+URL of Python source code:
+http://sourceforge.net/p/docutils/code/HEAD/tree/trunk/docutils/docutils/utils/math/latex2mathml.py
+http://sourceforge.net/p/docutils/code/HEAD/tree/trunk/docutils/docutils/utils/math/__init__.py
+
+Only a subset of LaTeX is supported: letters, numbers and operators,
+Greek letters and common symbols, sub- and superscripts, fractions,
+roots, accents, fonts (\mathbf ...), \text, \left ... \right, spacing
+commands, named functions and large operators, and the matrix, cases and
+alignment environments. Anything else results in a `MathError`.
+*/
+
+import (
+	"regexp"
+	"strings"
+)
+
+/*
+   Return the right math environment to display `code`.
+
+   The test simply looks for line-breaks (``\\``) outside environments.
+   Multi-line formulae are set with ``align``, one-liners with
+   ``equation``.
+
+   If `numbered` is false, the "starred" versions are used to suppress
+   numbering.
+*/
+func PickMathEnvironment(code string, numbered bool) string {
+	// cut out environment content:
+	var toplevel []string
+	for _, chunk := range strings.Split(code, `\begin{`) {
+		parts := strings.Split(chunk, `\end{`)
+		toplevel = append(toplevel, parts[len(parts)-1])
+	}
+	env := "equation"
+	if strings.Contains(strings.Join(toplevel, ""), `\\`) {
+		env = "align"
+	}
+	if !numbered {
+		env += "*"
+	}
+	return env
+}
+
+// A MathML element; `text` is the content of token elements.
+type mathNode struct {
+	tag      string
+	attrs    [][2]string
+	text     string
+	children []*mathNode
+}
+
+func newMath(tag, text string, children ...*mathNode) *mathNode {
+	return &mathNode{tag: tag, text: text, children: children}
+}
+
+func (n *mathNode) set(name, value string) *mathNode {
+	n.attrs = append(n.attrs, [2]string{name, value})
+	return n
+}
+
+var mathEscape = strings.NewReplacer("&", "&amp;", "<", "&lt;", ">", "&gt;")
+
+func (n *mathNode) xml() string {
+	s := "<" + n.tag
+	for _, att := range n.attrs {
+		s += " " + att[0] + "=\"" + mathEscape.Replace(att[1]) + "\""
+	}
+	s += ">" + mathEscape.Replace(n.text)
+	for _, child := range n.children {
+		s += child.xml()
+	}
+	return s + "</" + n.tag + ">"
+}
+
+// Identifiers: Greek letters and letter-like symbols.
+var mathLetters = map[string]string{
+	"alpha": "α", "beta": "β", "gamma": "γ", "delta": "δ", "epsilon": "ϵ",
+	"varepsilon": "ε", "zeta": "ζ", "eta": "η", "theta": "θ",
+	"vartheta": "ϑ", "iota": "ι", "kappa": "κ", "lambda": "λ", "mu": "μ",
+	"nu": "ν", "xi": "ξ", "pi": "π", "varpi": "ϖ", "rho": "ρ",
+	"varrho": "ϱ", "sigma": "σ", "varsigma": "ς", "tau": "τ",
+	"upsilon": "υ", "phi": "ϕ", "varphi": "φ", "chi": "χ", "psi": "ψ",
+	"omega": "ω", "Gamma": "Γ", "Delta": "Δ", "Theta": "Θ", "Lambda": "Λ",
+	"Xi": "Ξ", "Pi": "Π", "Sigma": "Σ", "Upsilon": "Υ", "Phi": "Φ",
+	"Psi": "Ψ", "Omega": "Ω", "infty": "∞", "partial": "∂", "nabla": "∇",
+	"ell": "ℓ", "hbar": "ℏ", "emptyset": "∅", "aleph": "ℵ", "Re": "ℜ",
+	"Im": "ℑ", "wp": "℘", "imath": "ı", "jmath": "ȷ", "dots": "…",
+	"ldots": "…", "cdots": "⋯", "vdots": "⋮", "ddots": "⋱",
+}
+
+// Operators and relations.
+var mathOperators = map[string]string{
+	"pm": "±", "mp": "∓", "times": "×", "div": "÷", "cdot": "⋅",
+	"ast": "∗", "star": "⋆", "circ": "∘", "bullet": "∙", "cap": "∩",
+	"cup": "∪", "vee": "∨", "wedge": "∧", "setminus": "∖", "oplus": "⊕",
+	"otimes": "⊗", "leq": "≤", "le": "≤", "geq": "≥", "ge": "≥",
+	"neq": "≠", "ne": "≠", "approx": "≈", "equiv": "≡", "sim": "∼",
+	"simeq": "≃", "cong": "≅", "propto": "∝", "ll": "≪", "gg": "≫",
+	"in": "∈", "notin": "∉", "ni": "∋", "subset": "⊂", "supset": "⊃",
+	"subseteq": "⊆", "supseteq": "⊇", "to": "→", "rightarrow": "→",
+	"leftarrow": "←", "gets": "←", "leftrightarrow": "↔",
+	"Rightarrow": "⇒", "Leftarrow": "⇐", "Leftrightarrow": "⇔",
+	"implies": "⟹", "iff": "⟺", "mapsto": "↦", "uparrow": "↑",
+	"downarrow": "↓", "forall": "∀", "exists": "∃", "neg": "¬",
+	"lnot": "¬", "mid": "∣", "parallel": "∥", "perp": "⊥", "angle": "∠",
+	"prime": "′", "lbrace": "{", "rbrace": "}", "langle": "⟨",
+	"rangle": "⟩", "lfloor": "⌊", "rfloor": "⌋", "lceil": "⌈",
+	"rceil": "⌉", "vert": "|", "Vert": "‖", "colon": ":",
+	"{": "{", "}": "}", "|": "‖", "lvert": "|", "rvert": "|",
+}
+
+// Large operators: limits are set under and over them.
+var mathBigOperators = map[string]string{
+	"sum": "∑", "prod": "∏", "coprod": "∐", "bigcup": "⋃", "bigcap": "⋂",
+	"bigvee": "⋁", "bigwedge": "⋀", "bigoplus": "⨁", "bigotimes": "⨂",
+}
+
+// Integrals: limits are set as sub- and superscripts.
+var mathIntegrals = map[string]string{
+	"int": "∫", "iint": "∬", "iiint": "∭", "oint": "∮",
+}
+
+// Named functions; those in `mathLimitFunctions` take limits under them.
+var mathFunctions = []string{
+	"arccos", "arcsin", "arctan", "arg", "cos", "cosh", "cot", "coth",
+	"csc", "deg", "det", "dim", "exp", "gcd", "hom", "inf", "ker", "lg",
+	"lim", "liminf", "limsup", "ln", "log", "max", "min", "Pr", "sec",
+	"sin", "sinh", "sup", "tan", "tanh",
+}
+
+var mathLimitFunctions = []string{"det", "gcd", "inf", "lim", "liminf", "limsup", "max", "min", "Pr", "sup"}
+
+// Font commands and the MathML `mathvariant` they select.
+var mathFonts = map[string]string{
+	"mathbf": "bold", "boldsymbol": "bold-italic", "mathit": "italic",
+	"mathrm": "normal", "mathsf": "sans-serif", "mathtt": "monospace",
+	"mathbb": "double-struck", "mathcal": "script", "mathfrak": "fraktur",
+}
+
+// Accents: the character placed over (or under) the argument.
+var mathAccents = map[string][2]string{
+	"hat": {"mover", "^"}, "widehat": {"mover", "^"}, "bar": {"mover", "¯"},
+	"overline": {"mover", "¯"}, "vec": {"mover", "→"}, "dot": {"mover", "˙"},
+	"ddot": {"mover", "¨"}, "tilde": {"mover", "~"}, "widetilde": {"mover", "~"},
+	"check": {"mover", "ˇ"}, "breve": {"mover", "˘"}, "acute": {"mover", "´"},
+	"grave": {"mover", "`"}, "underline": {"munder", "_"},
+	"overrightarrow": {"mover", "→"}, "overbrace": {"mover", "⏞"},
+	"underbrace": {"munder", "⏟"},
+}
+
+// Spacing commands and their widths.
+var mathSpaces = map[string]string{
+	",": "0.1667em", ":": "0.2222em", ">": "0.2222em", ";": "0.2778em",
+	" ": "0.25em", "!": "-0.1667em", "quad": "1em", "qquad": "2em",
+	"enspace": "0.5em", "thinspace": "0.1667em",
+}
+
+// Environments typeset as tables, with their delimiters.
+var mathMatrices = map[string][2]string{
+	"matrix": {"", ""}, "smallmatrix": {"", ""}, "pmatrix": {"(", ")"},
+	"bmatrix": {"[", "]"}, "Bmatrix": {"{", "}"}, "vmatrix": {"|", "|"},
+	"Vmatrix": {"‖", "‖"}, "cases": {"{", ""}, "array": {"", ""},
+	"aligned": {"", ""}, "align": {"", ""}, "align*": {"", ""},
+	"split": {"", ""}, "gathered": {"", ""}, "gather": {"", ""},
+	"gather*": {"", ""}, "equation": {"", ""}, "equation*": {"", ""},
+}
+
+// Delimiter sizes ignored by the conversion: MathML fences stretch.
+var mathSizes = []string{"big", "Big", "bigg", "Bigg", "bigl", "bigr", "Bigl", "Bigr", "biggl", "biggr", "Biggl", "Biggr", "displaystyle", "textstyle", "scriptstyle", "limits", "nolimits"}
+
+var texToken = regexp.MustCompile(`^(?:\\[a-zA-Z]+|\\.|[0-9]+(?:\.[0-9]+)?|\s+|.)`)
+
+// A recursive descent parser of LaTeX math code.
+type texParser struct {
+	tokens []string
+	pos    int
+}
+
+func (p *texParser) init(code string) {
+	p.tokens = nil
+	p.pos = 0
+	for code != "" {
+		token := texToken.FindString(code)
+		if strings.HasPrefix(token, `\`) && len(token) > 2 {
+			// skip the whitespace following a command name
+			code = strings.TrimLeft(code[len(token):], " \t\n")
+		} else {
+			code = code[len(token):]
+		}
+		p.tokens = append(p.tokens, token)
+	}
+}
+
+// Return the next token (skipping whitespace), "" at the end.
+func (p *texParser) peek() string {
+	for p.pos < len(p.tokens) && strings.TrimSpace(p.tokens[p.pos]) == "" {
+		p.pos++
+	}
+	if p.pos < len(p.tokens) {
+		return p.tokens[p.pos]
+	}
+	return ""
+}
+
+func (p *texParser) next() string {
+	token := p.peek()
+	if token != "" {
+		p.pos++
+	}
+	return token
+}
+
+/*
+   Parse a row of expressions until one of the `stop` tokens (which is not
+   consumed) or the end of input. Return the expressions.
+*/
+func (p *texParser) parseRow(stop ...string) ([]*mathNode, error) {
+	var row []*mathNode
+	for {
+		token := p.peek()
+		if token == "" || containsString(stop, token) {
+			return row, nil
+		}
+		node, err := p.parseScripts()
+		if err != nil {
+			return nil, err
+		}
+		if node != nil {
+			row = append(row, node)
+		}
+	}
+}
+
+// Parse an atom with its sub- and superscripts.
+func (p *texParser) parseScripts() (*mathNode, error) {
+	limits := false
+	if token := p.peek(); strings.HasPrefix(token, `\`) {
+		name := token[1:]
+		_, big := mathBigOperators[name]
+		limits = big || containsString(mathLimitFunctions, name)
+	}
+	base, err := p.parseAtom()
+	if err != nil || base == nil {
+		return base, err
+	}
+	var sub, sup *mathNode
+	for {
+		token := p.peek()
+		if token != "_" && token != "^" && token != "'" {
+			break
+		}
+		p.next()
+		if token == "'" {
+			// primes are superscripts
+			prime := newMath("mo", "′")
+			if sup == nil {
+				sup = prime
+			} else {
+				sup = newMath("mrow", "", sup, prime)
+			}
+			continue
+		}
+		arg, err := p.parseArgument()
+		if err != nil {
+			return nil, err
+		}
+		if token == "_" {
+			if sub != nil {
+				return nil, &MathError{"Double subscript"}
+			}
+			sub = arg
+		} else {
+			if sup != nil && sup.tag != "mo" {
+				return nil, &MathError{"Double superscript"}
+			}
+			if sup != nil {
+				arg = newMath("mrow", "", sup, arg)
+			}
+			sup = arg
+		}
+	}
+	under, over, both := "msub", "msup", "msubsup"
+	if limits {
+		under, over, both = "munder", "mover", "munderover"
+	}
+	switch {
+	case sub != nil && sup != nil:
+		return newMath(both, "", base, sub, sup), nil
+	case sub != nil:
+		return newMath(under, "", base, sub), nil
+	case sup != nil:
+		return newMath(over, "", base, sup), nil
+	}
+	return base, nil
+}
+
+// Parse a command argument: a group or a single token.
+func (p *texParser) parseArgument() (*mathNode, error) {
+	if p.peek() == "" {
+		return nil, &MathError{"Missing argument"}
+	}
+	return p.parseAtom()
+}
+
+// Return the text of a {group} argument without parsing it.
+func (p *texParser) rawArgument() (string, error) {
+	if p.next() != "{" {
+		return "", &MathError{"Missing {...} argument"}
+	}
+	depth := 0
+	var text string
+	for p.pos < len(p.tokens) {
+		token := p.tokens[p.pos]
+		p.pos++
+		switch token {
+		case "{":
+			depth++
+		case "}":
+			if depth == 0 {
+				return text, nil
+			}
+			depth--
+		}
+		text += token
+	}
+	return "", &MathError{"Unbalanced braces"}
+}
+
+// Return `row` as one node: a single node as is, else in an mrow.
+func mrow(row []*mathNode) *mathNode {
+	if len(row) == 1 {
+		return row[0]
+	}
+	return newMath("mrow", "", row...)
+}
+
+func (p *texParser) parseAtom() (*mathNode, error) {
+	token := p.next()
+	switch {
+	case token == "{":
+		row, err := p.parseRow("}")
+		if err != nil {
+			return nil, err
+		}
+		if p.next() != "}" {
+			return nil, &MathError{"Unbalanced braces"}
+		}
+		return mrow(row), nil
+	case token == "}":
+		return nil, &MathError{"Unbalanced braces"}
+	case token[0] >= '0' && token[0] <= '9':
+		return newMath("mn", token), nil
+	case len(token) == 1 && (token[0] >= 'a' && token[0] <= 'z' || token[0] >= 'A' && token[0] <= 'Z'):
+		return newMath("mi", token), nil
+	case token == "-":
+		return newMath("mo", "−"), nil
+	case token == "_" || token == "^":
+		return nil, &MathError{"Missing base for " + token}
+	case token == "&":
+		return nil, &MathError{"Alignment tab & outside of an environment"}
+	case token == "~":
+		return newMath("mspace", "").set("width", "0.25em"), nil
+	case !strings.HasPrefix(token, `\`):
+		if strings.ContainsAny(token, "()[]|") {
+			return newMath("mo", token).set("stretchy", "false"), nil
+		}
+		if len(token) == 1 && strings.ContainsAny(token, "+=<>*/,;:!.?") {
+			return newMath("mo", token), nil
+		}
+		// other characters (unicode letters ...) are identifiers
+		return newMath("mi", token), nil
+	}
+	return p.parseCommand(token[1:])
+}
+
+func (p *texParser) parseCommand(name string) (*mathNode, error) {
+	if s, ok := mathLetters[name]; ok {
+		node := newMath("mi", s)
+		if strings.ToUpper(name[:1]) == name[:1] && name != "Re" && name != "Im" {
+			// upright capital Greek letters
+			node.set("mathvariant", "normal")
+		}
+		return node, nil
+	}
+	if s, ok := mathOperators[name]; ok {
+		return newMath("mo", s), nil
+	}
+	if s, ok := mathBigOperators[name]; ok {
+		return newMath("mo", s).set("movablelimits", "true"), nil
+	}
+	if s, ok := mathIntegrals[name]; ok {
+		return newMath("mo", s), nil
+	}
+	if containsString(mathFunctions, name) {
+		node := newMath("mi", name)
+		if len(name) == 1 {
+			node.set("mathvariant", "normal")
+		}
+		if containsString(mathLimitFunctions, name) {
+			return node, nil
+		}
+		// function application
+		return newMath("mrow", "", node, newMath("mo", "⁡")), nil
+	}
+	if width, ok := mathSpaces[name]; ok {
+		return newMath("mspace", "").set("width", width), nil
+	}
+	if containsString(mathSizes, name) {
+		// delimiter sizes and styles are ignored
+		return nil, nil
+	}
+	if variant, ok := mathFonts[name]; ok {
+		arg, err := p.parseArgument()
+		if err != nil {
+			return nil, err
+		}
+		if arg.tag == "mi" {
+			return arg.set("mathvariant", variant), nil
+		}
+		return newMath("mstyle", "", arg).set("mathvariant", variant), nil
+	}
+	if accent, ok := mathAccents[name]; ok {
+		arg, err := p.parseArgument()
+		if err != nil {
+			return nil, err
+		}
+		mark := newMath("mo", accent[1])
+		if name == "widehat" || name == "widetilde" || name == "overline" ||
+			name == "underline" || strings.HasSuffix(name, "brace") || name == "overrightarrow" {
+			mark.set("stretchy", "true")
+		} else {
+			mark.set("stretchy", "false")
+		}
+		node := newMath(accent[0], "", arg, mark)
+		if accent[0] == "mover" {
+			return node.set("accent", "true"), nil
+		}
+		return node.set("accentunder", "true"), nil
+	}
+	switch name {
+	case "frac", "dfrac", "tfrac", "binom":
+		num, err := p.parseArgument()
+		if err != nil {
+			return nil, err
+		}
+		den, err := p.parseArgument()
+		if err != nil {
+			return nil, err
+		}
+		frac := newMath("mfrac", "", num, den)
+		if name == "binom" {
+			frac.set("linethickness", "0")
+			return newMath("mrow", "", newMath("mo", "("), frac, newMath("mo", ")")), nil
+		}
+		return frac, nil
+	case "sqrt":
+		var index *mathNode
+		if p.peek() == "[" {
+			p.next()
+			row, err := p.parseRow("]")
+			if err != nil {
+				return nil, err
+			}
+			if p.next() != "]" {
+				return nil, &MathError{"Missing ]"}
+			}
+			index = mrow(row)
+		}
+		arg, err := p.parseArgument()
+		if err != nil {
+			return nil, err
+		}
+		if index != nil {
+			return newMath("mroot", "", arg, index), nil
+		}
+		return newMath("msqrt", "", arg), nil
+	case "text", "textrm", "mbox", "textit", "textbf":
+		text, err := p.rawArgument()
+		if err != nil {
+			return nil, err
+		}
+		node := newMath("mtext", text)
+		if name == "textit" {
+			node.set("mathvariant", "italic")
+		} else if name == "textbf" {
+			node.set("mathvariant", "bold")
+		}
+		return node, nil
+	case "operatorname":
+		text, err := p.rawArgument()
+		if err != nil {
+			return nil, err
+		}
+		return newMath("mrow", "", newMath("mi", text).set("mathvariant", "normal"), newMath("mo", "⁡")), nil
+	case "left":
+		open := p.next()
+		row, err := p.parseRow(`\right`)
+		if err != nil {
+			return nil, err
+		}
+		if p.next() != `\right` {
+			return nil, &MathError{`Missing \right`}
+		}
+		closing := p.next()
+		return newMath("mrow", "", append(append([]*mathNode{fence(open)}, row...), fence(closing))...), nil
+	case "right":
+		return nil, &MathError{`Missing \left`}
+	case "begin":
+		env, err := p.rawArgument()
+		if err != nil {
+			return nil, err
+		}
+		return p.parseEnvironment(env)
+	case "\\":
+		return nil, &MathError{`Line break \\ outside of an environment`}
+	}
+	return nil, &MathError{"Unknown LaTeX command \"\\" + name + "\""}
+}
+
+// Return a stretchy fence for the \left or \right delimiter `token`.
+func fence(token string) *mathNode {
+	if token == "." {
+		return newMath("mo", "")
+	}
+	text := token
+	if strings.HasPrefix(token, `\`) {
+		text = mathOperators[token[1:]]
+	}
+	return newMath("mo", text).set("fence", "true").set("stretchy", "true")
+}
+
+// Parse the rows and cells of a table environment up to \end{`env`}.
+func (p *texParser) parseEnvironment(env string) (*mathNode, error) {
+	delimiters, ok := mathMatrices[env]
+	if !ok {
+		return nil, &MathError{"Environment not supported: " + env}
+	}
+	if env == "array" {
+		// column specification
+		if _, err := p.rawArgument(); err != nil {
+			return nil, err
+		}
+	}
+	table, err := p.parseTable()
+	if err != nil {
+		return nil, err
+	}
+	if p.next() != `\end` {
+		return nil, &MathError{`Missing \end{` + env + "}"}
+	}
+	if end, err := p.rawArgument(); err != nil || end != env {
+		return nil, &MathError{`Missing \end{` + env + "}"}
+	}
+	switch env {
+	case "cases", "aligned", "align", "align*", "split":
+		table.set("columnalign", "right left right left right left").set("columnspacing", "0em 2em")
+		if env == "cases" {
+			table.attrs = [][2]string{{"columnalign", "left"}}
+		}
+	}
+	if env == "smallmatrix" {
+		table = newMath("mstyle", "", table).set("scriptlevel", "1")
+	}
+	if delimiters[0] == "" && delimiters[1] == "" {
+		return table, nil
+	}
+	return newMath("mrow", "", fence(delimiters[0]), table, fence(delimiters[1])), nil
+}
+
+// Parse table rows separated by \\ and cells separated by &.
+func (p *texParser) parseTable() (*mathNode, error) {
+	table := newMath("mtable", "")
+	row := newMath("mtr", "")
+	for {
+		cell, err := p.parseRow("&", `\\`, `\end`)
+		if err != nil {
+			return nil, err
+		}
+		row.children = append(row.children, newMath("mtd", "", cell...))
+		switch p.peek() {
+		case "&":
+			p.next()
+			continue
+		case `\\`:
+			p.next()
+			table.children = append(table.children, row)
+			row = newMath("mtr", "")
+			continue
+		}
+		table.children = append(table.children, row)
+		return table, nil
+	}
+}
+
+/*
+   Return the MathML representation of the LaTeX math `code` (the content
+   of a `math` or `math_block` element), or a `MathError` if it uses
+   unsupported or invalid LaTeX.
+
+   Block formulas with line breaks (``\\``) outside environments are set as
+   a table with aligned columns (``&``).
+*/
+func Tex2MathML(code string, inline bool) (string, error) {
+	p := &texParser{}
+	p.init(code)
+	var row []*mathNode
+	var err error
+	if !inline && PickMathEnvironment(code, false) == "align*" {
+		var table *mathNode
+		table, err = p.parseTable()
+		if table != nil {
+			table.set("columnalign", "right left right left right left").set("columnspacing", "0em 2em").set("displaystyle", "true")
+			row = []*mathNode{table}
+		}
+	} else {
+		row, err = p.parseRow()
+	}
+	if err == nil && p.peek() != "" {
+		err = &MathError{"Unexpected \"" + p.peek() + "\""}
+	}
+	if err != nil {
+		return "", err
+	}
+	math := newMath("math", "", mrow(row))
+	math.set("xmlns", "http://www.w3.org/1998/Math/MathML")
+	if !inline {
+		math.set("display", "block")
+	}
+	return math.xml(), nil
+}
+
+func containsString(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,42 @@
+package rst
+
+import (
+	"testing"
+)
+
+func TestTex2MathML(t *testing.T) {
+	cases := []struct {
+		code     string
+		inline   bool
+		expected string
+	}{
+		{`\alpha^2`, true, `<math xmlns="http://www.w3.org/1998/Math/MathML"><msup><mi>α</mi><mn>2</mn></msup></math>`},
+		{`\frac{a}{b} + x_i`, false, `<math xmlns="http://www.w3.org/1998/Math/MathML" display="block"><mrow><mfrac><mi>a</mi><mi>b</mi></mfrac><mo>+</mo><msub><mi>x</mi><mi>i</mi></msub></mrow></math>`},
+		{`\sqrt{2}`, true, `<math xmlns="http://www.w3.org/1998/Math/MathML"><msqrt><mn>2</mn></msqrt></math>`},
+	}
+	for _, c := range cases {
+		output, err := Tex2MathML(c.code, c.inline)
+		if err != nil {
+			t.Error(err)
+		}
+		if output != c.expected {
+			t.Error(c.code + " failed: " + output)
+		}
+	}
+
+	if _, err := Tex2MathML(`\foo`, true); err == nil || err.Error() != `Unknown LaTeX command "\foo"` {
+		t.Error("unknown command not reported")
+	}
+	if _, err := Tex2MathML(`{a`, true); err == nil || err.Error() != "Unbalanced braces" {
+		t.Error("unbalanced braces not reported")
+	}
+}
+
+func TestPickMathEnvironment(t *testing.T) {
+	if env := PickMathEnvironment("a = b", true); env != "equation" {
+		t.Error("expected equation, got " + env)
+	}
+	if env := PickMathEnvironment("a &= b \\\\\nc &= d", false); env != "align*" {
+		t.Error("expected align*, got " + env)
+	}
+}
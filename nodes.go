@@ -0,0 +1,989 @@
+package rst
+
+/*
+Implementation of Docutils document tree element classes in Python docutils
+
+URL of Python source code:
+http://sourceforge.net/p/docutils/code/HEAD/tree/trunk/docutils/docutils/nodes.py
+
+Python docutils defines one class per element type. Here all elements share
+the `Element` type and are told apart by their tag name; the element class
+hierarchy (`Structural`, `Body`, `Inline`, `TextElement`, ...) is kept in the
+`nodeClasses` table and queried with `Element.Is()`.
+*/
+
+import (
+	"reflect"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// Abstract base of all document tree nodes: `Element` and `Text`.
+type Node interface {
+	// The element type name, "#text" for `Text` nodes.
+	TagName() string
+
+	// Back-reference to the Element immediately containing this Node.
+	Parent() *Element
+	setParent(parent *Element)
+
+	// The `Document` object at the root of the tree containing this Node.
+	Document() *Document
+	setDocument(document *Document)
+
+	// Path or description of the input source which generated this Node.
+	Source() string
+	SetSource(source string)
+
+	// The line number (1-based) of the beginning of this Node in `source`.
+	Line() int
+	SetLine(line int)
+
+	// Return a string representation of this Node.
+	AsText() string
+
+	// Return an indented pseudo-XML representation, for test purposes.
+	Pformat(indent string, level int) string
+
+	// Return a deep copy of self (also copying children).
+	DeepCopy() Node
+}
+
+type nodeBase struct {
+	parent   *Element
+	document *Document
+	source   string
+	line     int
+}
+
+func (n *nodeBase) Parent() *Element               { return n.parent }
+func (n *nodeBase) setParent(parent *Element)      { n.parent = parent }
+func (n *nodeBase) Document() *Document            { return n.document }
+func (n *nodeBase) setDocument(document *Document) { n.document = document }
+func (n *nodeBase) Source() string                 { return n.source }
+func (n *nodeBase) SetSource(source string)        { n.source = source }
+func (n *nodeBase) Line() int                      { return n.line }
+func (n *nodeBase) SetLine(line int)               { n.line = line }
+
+/*
+   Instances are terminal nodes (leaves) containing text only; no child
+   nodes or attributes.
+*/
+type Text struct {
+	nodeBase
+
+	data string
+
+	// Raw text data, before backslash-escape processing.
+	rawsource string
+}
+
+func (t *Text) Init(data, rawsource string) {
+	t.data = data
+	t.rawsource = rawsource
+}
+
+func (t *Text) TagName() string {
+	return "#text"
+}
+
+func (t *Text) RawSource() string {
+	return t.rawsource
+}
+
+func (t *Text) AsText() string {
+	return t.data
+}
+
+func (t *Text) SetText(data string) {
+	t.data = data
+}
+
+func (t *Text) Pformat(indent string, level int) string {
+	var result string
+	prefix := strings.Repeat(indent, level)
+	for _, line := range SplitLines(t.data) {
+		result += prefix + line + "\n"
+	}
+	return result
+}
+
+func (t *Text) DeepCopy() Node {
+	c := &Text{}
+	c.Init(t.data, t.rawsource)
+	c.source, c.line = t.source, t.line
+	return c
+}
+
+/*
+   `Element` is the superclass to all specific elements.
+
+   Elements contain attributes and child nodes. Elements emulate
+   dictionaries for attributes, indexing by attribute name (a string). To
+   set the attribute 'att' to 'value', do::
+
+       element.Set("att", "value")
+
+   There are five special attributes, kept in exported fields because their
+   values are always lists:
+
+   - `Ids`: A list of unique identifiers for this element.
+   - `Classes`: A list of classes the element belongs to.
+   - `Names`: A list of normalized names for this element.
+   - `Dupnames`: A list of names removed because they were duplicated.
+   - `Backrefs`: A list of ids referring to this element.
+
+   Elements also emulate lists for child nodes (element nodes and/or text
+   nodes), see `Children()`, `Append()`, `Insert()` and `Remove()`.
+*/
+type Element struct {
+	nodeBase
+
+	tagname string
+
+	// The raw text from which this element was constructed.
+	rawsource string
+
+	// List of child nodes (elements and/or `Text`).
+	children []Node
+
+	// Dictionary of non-list attributes of this element.
+	attributes map[string]string
+
+	Ids      []string
+	Classes  []string
+	Names    []string
+	Dupnames []string
+	Backrefs []string
+
+	// Pending elements only: the transform to apply later, and
+	// transform-specific data.
+	Transform Transform
+	Details   map[string]interface{}
+
+	// Transform state, not attributes of the element: has the element been
+	// referenced, has the reference been resolved, and the targets to mark
+	// as referenced along with the element (see `NoteReferencedBy()`).
+	Referenced             bool
+	Resolved               bool
+	ExpectReferencedByName map[string]*Element
+	ExpectReferencedByID   map[string]*Element
+}
+
+/*
+   Initialize an `Element`.
+
+   Parameters:
+
+   - `tagname`: the element type name, such as "paragraph".
+   - `rawsource`: the raw text from which this element was constructed.
+   - `text`: if non-empty, a `Text` node is appended as the first child.
+   - `children`: further child nodes.
+*/
+func (e *Element) Init(tagname, rawsource, text string, children ...Node) {
+	e.tagname = tagname
+	e.rawsource = rawsource
+	e.attributes = make(map[string]string)
+	if tagname == "pending" {
+		e.Details = make(map[string]interface{})
+	}
+	if text != "" {
+		t := &Text{}
+		t.Init(text, "")
+		e.Append(t)
+	}
+	e.Extend(children...)
+}
+
+func (e *Element) TagName() string {
+	return e.tagname
+}
+
+func (e *Element) RawSource() string {
+	return e.rawsource
+}
+
+// Return true if this element belongs to element class `class`.
+func (e *Element) Is(class NodeClass) bool {
+	return nodeClasses[e.tagname]&class != 0
+}
+
+// Return the value of attribute `name`, "" if unset.
+func (e *Element) Get(name string) string {
+	return e.attributes[name]
+}
+
+func (e *Element) Set(name, value string) {
+	e.attributes[name] = value
+}
+
+// Return true if attribute `name` is set.
+func (e *Element) HasAttr(name string) bool {
+	_, ok := e.attributes[name]
+	return ok
+}
+
+func (e *Element) DelAttr(name string) {
+	delete(e.attributes, name)
+}
+
+/*
+   Return a sorted list of (name, value) pairs of the attributes which are
+   set on this element; list attribute values are serialized with
+   `SerialEscape()` and joined with spaces. Empty lists are omitted.
+*/
+func (e *Element) Attlist() [][2]string {
+	var list [][2]string
+	for name, value := range e.attributes {
+		list = append(list, [2]string{name, value})
+	}
+	for name, values := range e.listAttributes() {
+		if len(values) == 0 {
+			continue
+		}
+		var escaped []string
+		for _, v := range values {
+			escaped = append(escaped, SerialEscape(v))
+		}
+		list = append(list, [2]string{name, strings.Join(escaped, " ")})
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i][0] < list[j][0] })
+	return list
+}
+
+func (e *Element) listAttributes() map[string][]string {
+	return map[string][]string{
+		"ids":      e.Ids,
+		"classes":  e.Classes,
+		"names":    e.Names,
+		"dupnames": e.Dupnames,
+		"backrefs": e.Backrefs,
+	}
+}
+
+// Copy the attributes of `other` into this element, extending list
+// attributes.
+func (e *Element) UpdateAttributes(other *Element) {
+	for name, value := range other.attributes {
+		e.attributes[name] = value
+	}
+	e.Ids = appendUnique(e.Ids, other.Ids...)
+	e.Classes = appendUnique(e.Classes, other.Classes...)
+	e.Names = appendUnique(e.Names, other.Names...)
+	e.Dupnames = appendUnique(e.Dupnames, other.Dupnames...)
+	e.Backrefs = appendUnique(e.Backrefs, other.Backrefs...)
+}
+
+func appendUnique(list []string, values ...string) []string {
+	for _, v := range values {
+		found := false
+		for _, l := range list {
+			if l == v {
+				found = true
+				break
+			}
+		}
+		if !found {
+			list = append(list, v)
+		}
+	}
+	return list
+}
+
+func (e *Element) Children() []Node {
+	return e.children
+}
+
+func (e *Element) Len() int {
+	return len(e.children)
+}
+
+func (e *Element) setupChild(child Node) {
+	child.setParent(e)
+	if e.document != nil {
+		setDocument(child, e.document)
+	}
+	if child.Source() == "" {
+		child.SetSource(e.source)
+	}
+	if child.Line() == 0 {
+		child.SetLine(e.line)
+	}
+}
+
+func setDocument(node Node, document *Document) {
+	node.setDocument(document)
+	if e, ok := node.(*Element); ok {
+		for _, child := range e.children {
+			setDocument(child, document)
+		}
+	}
+}
+
+func (e *Element) Append(child Node) {
+	e.setupChild(child)
+	e.children = append(e.children, child)
+}
+
+func (e *Element) Extend(children ...Node) {
+	for _, child := range children {
+		e.Append(child)
+	}
+}
+
+// Insert `children` before index `i`.
+func (e *Element) Insert(i int, children ...Node) {
+	for _, child := range children {
+		e.setupChild(child)
+	}
+	e.children = append(e.children[:i], append(append([]Node{}, children...), e.children[i:]...)...)
+}
+
+// Return the index of `child`, -1 if it is not a child of this element.
+func (e *Element) Index(child Node) int {
+	for i, c := range e.children {
+		if c == child {
+			return i
+		}
+	}
+	return -1
+}
+
+func (e *Element) Remove(child Node) {
+	if i := e.Index(child); i >= 0 {
+		e.children = append(e.children[:i], e.children[i+1:]...)
+	}
+}
+
+// Replace child `old` by `nodes`.
+func (e *Element) Replace(old Node, nodes ...Node) {
+	i := e.Index(old)
+	if i < 0 {
+		return
+	}
+	e.children = append(e.children[:i], e.children[i+1:]...)
+	e.Insert(i, nodes...)
+}
+
+/*
+   Replace `self` node with `nodes`.
+
+   If `nodes` is a single element, its attributes are updated with those of
+   `self`, so that ids and names are not lost.
+*/
+func (e *Element) ReplaceSelf(nodes ...Node) {
+	if len(nodes) == 1 {
+		if n, ok := nodes[0].(*Element); ok {
+			n.UpdateAttributes(e)
+		}
+	}
+	e.parent.Replace(e, nodes...)
+}
+
+func (e *Element) SetChildren(children []Node) {
+	e.children = nil
+	e.Extend(children...)
+}
+
+/*
+   Return the index of the first child whose tag is in `tagnames`.
+
+   Parameters:
+
+   - `start`: index of the first child to check.
+   - `end`: index past the last child to check, -1 for all children.
+*/
+func (e *Element) FirstChildMatchingClass(tagnames []string, start, end int) int {
+	if end < 0 || end > len(e.children) {
+		end = len(e.children)
+	}
+	for i := start; i < end; i++ {
+		for _, name := range tagnames {
+			if e.children[i].TagName() == name {
+				return i
+			}
+		}
+	}
+	return -1
+}
+
+/*
+   Return the index of the first child whose tag is *not* in `tagnames`.
+   See `FirstChildMatchingClass()` for `start` and `end`.
+*/
+func (e *Element) FirstChildNotMatchingClass(tagnames []string, start, end int) int {
+	if end < 0 || end > len(e.children) {
+		end = len(e.children)
+	}
+	for i := start; i < end; i++ {
+		matched := false
+		for _, name := range tagnames {
+			if e.children[i].TagName() == name {
+				matched = true
+				break
+			}
+		}
+		if !matched {
+			return i
+		}
+	}
+	return -1
+}
+
+/*
+   Return a list of this node and all its descendants (in tree traversal
+   order) for which `condition` returns true. A nil `condition` matches all
+   nodes.
+*/
+func (e *Element) Traverse(condition func(Node) bool) []Node {
+	return traverse(e, condition)
+}
+
+func traverse(node Node, condition func(Node) bool) []Node {
+	var result []Node
+	if condition == nil || condition(node) {
+		result = append(result, node)
+	}
+	if e, ok := node.(*Element); ok {
+		for _, child := range e.children {
+			result = append(result, traverse(child, condition)...)
+		}
+	}
+	return result
+}
+
+// Return the first descendant of this node (self excluded) for which
+// `condition` returns true, or nil.
+func (e *Element) NextNode(condition func(Node) bool) Node {
+	for _, child := range e.children {
+		if found := traverse(child, condition); len(found) > 0 {
+			return found[0]
+		}
+	}
+	return nil
+}
+
+// Return a `Traverse()` condition matching nodes whose tag is in `tagnames`.
+func ByTag(tagnames ...string) func(Node) bool {
+	return func(n Node) bool {
+		for _, name := range tagnames {
+			if n.TagName() == name {
+				return true
+			}
+		}
+		return false
+	}
+}
+
+/*
+   Return the language code of this element: the tag of the first class
+   "language-*" found on the element or its ancestors, or `fallback`.
+*/
+func (e *Element) LanguageCode(fallback string) string {
+	for node := e; node != nil; node = node.parent {
+		for _, cls := range node.Classes {
+			if strings.HasPrefix(cls, "language-") {
+				return cls[len("language-"):]
+			}
+		}
+	}
+	return fallback
+}
+
+func (e *Element) childTextSeparator() string {
+	if e.Is(TextElementClass) {
+		return ""
+	}
+	return "\n\n"
+}
+
+func (e *Element) AsText() string {
+	var texts []string
+	for _, child := range e.children {
+		texts = append(texts, child.AsText())
+	}
+	text := strings.Join(texts, e.childTextSeparator())
+	switch e.tagname {
+	case "system_message":
+		return e.Get("source") + ":" + e.Get("line") + ": (" + e.Get("type") + "/" + e.Get("level") + ") " + text
+	case "image":
+		return e.Get("alt")
+	}
+	return text
+}
+
+// Return the start tag in pseudo-XML, with attributes.
+func (e *Element) Starttag() string {
+	parts := []string{e.tagname}
+	for _, att := range e.Attlist() {
+		parts = append(parts, att[0]+"=\""+att[1]+"\"")
+	}
+	return "<" + strings.Join(parts, " ") + ">"
+}
+
+func (e *Element) Pformat(indent string, level int) string {
+	result := strings.Repeat(indent, level) + e.Starttag() + "\n"
+	for _, child := range e.children {
+		result += child.Pformat(indent, level+1)
+	}
+	return result
+}
+
+/*
+   Return a new `pending` element: a placeholder for `transform` to process
+   later, with transform-specific `details` (may be nil). Note it with
+   `Document.NotePending()` to have it processed.
+*/
+func NewPending(transform Transform, details map[string]interface{}, rawsource string) *Element {
+	pending := &Element{}
+	pending.Init("pending", rawsource, "")
+	pending.Transform = transform
+	for key, value := range details {
+		pending.Details[key] = value
+	}
+	return pending
+}
+
+/*
+   Mark this element as referenced by `name` or `id` ("" if unknown), and
+   the targets expected to be referenced along with it.
+*/
+func (e *Element) NoteReferencedBy(name, id string) {
+	e.Referenced = true
+	if target := e.ExpectReferencedByName[name]; name != "" && target != nil {
+		target.Referenced = true
+	}
+	if target := e.ExpectReferencedByID[id]; id != "" && target != nil {
+		target.Referenced = true
+	}
+}
+
+// Append `id` to the backrefs of this element.
+func (e *Element) AddBackref(id string) {
+	e.Backrefs = append(e.Backrefs, id)
+}
+
+// Return a deep copy of self (also copying children).
+func (e *Element) DeepCopy() Node {
+	c := e.Copy()
+	for _, child := range e.children {
+		c.Append(child.DeepCopy())
+	}
+	return c
+}
+
+// Return a shallow copy of self, without children.
+func (e *Element) Copy() *Element {
+	c := &Element{}
+	c.Init(e.tagname, e.rawsource, "")
+	for name, value := range e.attributes {
+		c.attributes[name] = value
+	}
+	c.Ids = append([]string(nil), e.Ids...)
+	c.Classes = append([]string(nil), e.Classes...)
+	c.Names = append([]string(nil), e.Names...)
+	c.Dupnames = append([]string(nil), e.Dupnames...)
+	c.Backrefs = append([]string(nil), e.Backrefs...)
+	c.Transform = e.Transform
+	for key, value := range e.Details {
+		c.Details[key] = value
+	}
+	c.source, c.line = e.source, e.line
+	return c
+}
+
+// Bit set of the element classes of Python docutils.
+type NodeClass uint32
+
+const (
+	Root NodeClass = 1 << iota
+	Titular
+	PreBibliographic
+	Bibliographic
+	Decorative
+	Structural
+	Body
+	General
+	Sequential
+	Admonition
+	Special
+	Invisible
+	Part
+	Inline
+	Referential
+	Targetable
+	Labeled
+	BackLinkable
+	TextElementClass
+	FixedTextElementClass
+)
+
+// Element classes implying their base classes, as class inheritance does
+// in Python docutils.
+const (
+	textElement      = TextElementClass
+	fixedTextElement = TextElementClass | FixedTextElementClass
+	decorative       = Decorative | PreBibliographic
+	invisible        = Invisible | PreBibliographic
+)
+
+// Element classes of each element type, see the class definitions in
+// nodes.py.
+var nodeClasses = map[string]NodeClass{
+	// Structural Elements
+	"document":     Root | Structural,
+	"title":        Titular | PreBibliographic | textElement,
+	"subtitle":     Titular | PreBibliographic | textElement,
+	"rubric":       Titular | textElement,
+	"docinfo":      Bibliographic,
+	"author":       Bibliographic | textElement,
+	"authors":      Bibliographic,
+	"organization": Bibliographic | textElement,
+	"address":      Bibliographic | fixedTextElement,
+	"contact":      Bibliographic | textElement,
+	"version":      Bibliographic | textElement,
+	"revision":     Bibliographic | textElement,
+	"status":       Bibliographic | textElement,
+	"date":         Bibliographic | textElement,
+	"copyright":    Bibliographic | textElement,
+	"decoration":   decorative,
+	"header":       decorative,
+	"footer":       decorative,
+	"section":      Structural,
+	"topic":        Structural,
+	"sidebar":      Structural,
+	"transition":   Structural,
+
+	// Body Elements
+	"paragraph":               General | textElement,
+	"compound":                General,
+	"container":               General,
+	"bullet_list":             Sequential,
+	"enumerated_list":         Sequential,
+	"list_item":               Part,
+	"definition_list":         Sequential,
+	"definition_list_item":    Part,
+	"term":                    Part | textElement,
+	"classifier":              Part | textElement,
+	"definition":              Part,
+	"field_list":              Sequential,
+	"field":                   Part,
+	"field_name":              Part | textElement,
+	"field_body":              Part,
+	"option":                  Part,
+	"option_argument":         Part | textElement,
+	"option_group":            Part,
+	"option_list":             Sequential,
+	"option_list_item":        Part,
+	"option_string":           Part | textElement,
+	"description":             Part,
+	"literal_block":           General | fixedTextElement,
+	"doctest_block":           General | fixedTextElement,
+	"math_block":              General | fixedTextElement,
+	"line_block":              General,
+	"line":                    Part | textElement,
+	"block_quote":             General,
+	"attribution":             Part | textElement,
+	"attention":               Admonition,
+	"caution":                 Admonition,
+	"danger":                  Admonition,
+	"error":                   Admonition,
+	"important":               Admonition,
+	"note":                    Admonition,
+	"tip":                     Admonition,
+	"hint":                    Admonition,
+	"warning":                 Admonition,
+	"admonition":              Admonition,
+	"comment":                 Special | invisible | fixedTextElement,
+	"substitution_definition": Special | invisible | textElement,
+	"target":                  Special | invisible | Inline | Targetable | textElement,
+	"footnote":                General | BackLinkable | Labeled | Targetable,
+	"citation":                General | BackLinkable | Labeled | Targetable,
+	"label":                   Part | textElement,
+	"figure":                  General,
+	"caption":                 Part | textElement,
+	"legend":                  Part,
+	"table":                   General,
+	"tgroup":                  Part,
+	"colspec":                 Part,
+	"thead":                   Part,
+	"tbody":                   Part,
+	"row":                     Part,
+	"entry":                   Part,
+	"system_message":          Special | BackLinkable | PreBibliographic,
+	"pending":                 Special | invisible,
+	"raw":                     Special | Inline | PreBibliographic | fixedTextElement,
+
+	// Inline Elements
+	"emphasis":               Inline | textElement,
+	"strong":                 Inline | textElement,
+	"literal":                Inline | textElement,
+	"reference":              General | Inline | Referential | textElement,
+	"footnote_reference":     Inline | Referential | textElement,
+	"citation_reference":     Inline | Referential | textElement,
+	"substitution_reference": Inline | textElement,
+	"title_reference":        Inline | textElement,
+	"abbreviation":           Inline | textElement,
+	"acronym":                Inline | textElement,
+	"superscript":            Inline | textElement,
+	"subscript":              Inline | textElement,
+	"math":                   Inline | textElement,
+	"image":                  General | Inline,
+	"inline":                 Inline | textElement,
+	"problematic":            Inline | textElement,
+	"generated":              Inline | textElement,
+}
+
+/*
+   Return a string with backslashes and spaces escaped, for values that
+   are elements of a list attribute.
+*/
+func SerialEscape(value string) string {
+	return strings.Replace(strings.Replace(value, "\\", "\\\\", -1), " ", "\\ ", -1)
+}
+
+// Mark `name` as a duplicate name of `node`: move it from `Names` to
+// `Dupnames`.
+func Dupname(node *Element, name string) {
+	node.Dupnames = append(node.Dupnames, name)
+	for i, n := range node.Names {
+		if n == name {
+			node.Names = append(node.Names[:i], node.Names[i+1:]...)
+			break
+		}
+	}
+	// Assume that this method is referenced, even though it isn't; we
+	// don't want to throw unnecessary system_messages.
+	node.Referenced = true
+}
+
+var (
+	nonIDChars  = regexp.MustCompile(`[^a-z0-9]+`)
+	nonIDAtEnds = regexp.MustCompile(`^[-0-9]+|-+$`)
+	whitespace  = regexp.MustCompile(`\s+`)
+)
+
+/*
+   Convert `string` into an identifier and return it.
+
+   Docutils identifiers will conform to the regular expression
+   ``[a-z](-?[a-z0-9]+)*``. For CSS compatibility, identifiers (the "class"
+   and "id" attributes) should have no underscores, colons, or periods.
+   Hyphens may be used.
+*/
+func MakeID(s string) string {
+	id := strings.ToLower(s)
+	id = nonIDChars.ReplaceAllString(id, "-")
+	id = nonIDAtEnds.ReplaceAllString(id, "")
+	return id
+}
+
+// Split `text` at line boundaries, without a trailing empty line (like
+// Python's ``str.splitlines()``).
+func SplitLines(text string) []string {
+	if text == "" {
+		return nil
+	}
+	return strings.Split(strings.TrimSuffix(text, "\n"), "\n")
+}
+
+// Return a case- and whitespace-normalized name.
+func FullyNormalizeName(name string) string {
+	return strings.ToLower(WhitespaceNormalizeName(name))
+}
+
+// Return a whitespace-normalized name.
+func WhitespaceNormalizeName(name string) string {
+	return strings.TrimSpace(whitespace.ReplaceAllString(name, " "))
+}
+
+/*
+   "Visitor" pattern [GoF95]_ abstract superclass implementation for
+   document tree traversals.
+
+   Each node class has corresponding methods, doing nothing by default;
+   override individual methods for specific and useful behaviour. The
+   `dispatchVisit()` function is called by `Walk()` upon entering a node;
+   `Walkabout()` also calls `dispatchDeparture()` before exiting a node.
+
+   The dispatch functions call "``Visit`` + node tag name" or "``Depart``
+   + node tag name", with the tag name in CamelCase (`VisitParagraph`,
+   `DepartBulletList`, `VisitText` for `Text` nodes), found by reflection.
+   The methods take the node (`*Element` or `*Text`) and return nothing
+   or an error; return a `SkipNode`, `SkipChildren`, `SkipSiblings`,
+   `SkipDeparture` or `StopTraversal` error to prune the traversal. Nodes
+   without a method are passed to `UnknownVisit()` or
+   `UnknownDeparture()`.
+*/
+type NodeVisitor interface {
+	// Called when entering unknown `Node` types.
+	UnknownVisit(node Node) error
+
+	// Called before exiting unknown `Node` types.
+	UnknownDeparture(node Node) error
+}
+
+/*
+   Base class for sparse traversals, where only certain node types are of
+   interest. When ``VisitFoo`` (or ``DepartFoo``) methods should be
+   implemented for *all* node types (such as for `writers.Writer`
+   subclasses), embed nothing and implement `UnknownVisit()` to report
+   unknown nodes.
+*/
+type SparseNodeVisitor struct{}
+
+func (v *SparseNodeVisitor) UnknownVisit(node Node) error     { return nil }
+func (v *SparseNodeVisitor) UnknownDeparture(node Node) error { return nil }
+
+// Return the CamelCase form of a node tag name: "bullet_list" gives
+// "BulletList", "#text" gives "Text".
+func camelCase(tagname string) string {
+	var result string
+	for _, part := range strings.Split(strings.TrimPrefix(tagname, "#"), "_") {
+		if part != "" {
+			result += strings.ToUpper(part[:1]) + part[1:]
+		}
+	}
+	return result
+}
+
+func dispatch(visitor NodeVisitor, prefix string, node Node) error {
+	method := reflect.ValueOf(visitor).MethodByName(prefix + camelCase(node.TagName()))
+	if !method.IsValid() {
+		if prefix == "Visit" {
+			return visitor.UnknownVisit(node)
+		}
+		return visitor.UnknownDeparture(node)
+	}
+	retv := method.Call([]reflect.Value{reflect.ValueOf(node)})
+	if len(retv) > 0 && !retv[0].IsNil() {
+		return retv[0].Interface().(error)
+	}
+	return nil
+}
+
+// Call ``visitor.VisitFoo(node)`` for a node of type "foo".
+func dispatchVisit(visitor NodeVisitor, node Node) error {
+	return dispatch(visitor, "Visit", node)
+}
+
+// Call ``visitor.DepartFoo(node)`` for a node of type "foo".
+func dispatchDeparture(visitor NodeVisitor, node Node) error {
+	return dispatch(visitor, "Depart", node)
+}
+
+/*
+   Traverse a tree of `Node` objects, calling the `dispatchVisit()` method
+   of `visitor` when entering each node. (The `Walkabout()` function is
+   similar, except it also calls the `dispatchDeparture()` method before
+   exiting each node.)
+
+   This tree traversal supports limited in-place tree modifications.
+   Replacing one node with one or more nodes is OK, as is removing an
+   element. However, if the node removed or replaced occurs after the
+   current node, the old node will still be traversed, and any new nodes
+   will not.
+
+   Within ``Visit`` methods (and ``Depart`` methods for `Walkabout()`),
+   `SkipNode` and `SkipSiblings` errors may be returned. Errors other than
+   the tree pruning ones abort the traversal and are returned.
+*/
+func Walk(node Node, visitor NodeVisitor) error {
+	if d, ok := node.(*Document); ok {
+		node = &d.Element
+	}
+	_, err := walk(node, visitor)
+	return err
+}
+
+func walk(node Node, visitor NodeVisitor) (bool, error) {
+	stop := false
+	err := dispatchVisit(visitor, node)
+	switch err.(type) {
+	case nil, *SkipDeparture:
+	case *SkipNode:
+		return false, nil
+	case *SkipChildren:
+		return false, nil
+	case *StopTraversal:
+		return true, nil
+	default:
+		return true, err
+	}
+	if e, ok := node.(*Element); ok {
+		for _, child := range append([]Node(nil), e.children...) {
+			childStop, err := walk(child, visitor)
+			if _, ok := err.(*SkipSiblings); ok {
+				break
+			}
+			if err != nil {
+				return true, err
+			}
+			if childStop {
+				stop = true
+				break
+			}
+		}
+	}
+	return stop, nil
+}
+
+/*
+   Perform a tree traversal similarly to `Walk()`, except also call the
+   `dispatchDeparture()` method before exiting each node.
+
+   Parameter `visitor`: A `NodeVisitor` object, containing a ``Visit``
+   and ``Depart`` implementation for each `Node` subclass encountered.
+*/
+func Walkabout(node Node, visitor NodeVisitor) error {
+	if d, ok := node.(*Document); ok {
+		node = &d.Element
+	}
+	_, err := walkabout(node, visitor)
+	return err
+}
+
+func walkabout(node Node, visitor NodeVisitor) (bool, error) {
+	callDepart := true
+	stop := false
+	err := dispatchVisit(visitor, node)
+	descend := true
+	switch err.(type) {
+	case nil:
+	case *SkipNode:
+		return false, nil
+	case *SkipDeparture:
+		callDepart = false
+	case *SkipChildren:
+		descend = false
+	case *StopTraversal:
+		descend = false
+		stop = true
+	default:
+		return true, err
+	}
+	if e, ok := node.(*Element); ok && descend {
+		for _, child := range append([]Node(nil), e.children...) {
+			childStop, err := walkabout(child, visitor)
+			if _, ok := err.(*SkipSiblings); ok {
+				break
+			}
+			if err != nil {
+				return true, err
+			}
+			if childStop {
+				stop = true
+				break
+			}
+		}
+	}
+	if callDepart {
+		if err := dispatchDeparture(visitor, node); err != nil {
+			if _, ok := err.(*SkipSiblings); !ok {
+				return true, err
+			}
+		}
+	}
+	return stop, nil
+}
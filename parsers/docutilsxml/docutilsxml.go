@@ -0,0 +1,219 @@
+/*
+Package docutilsxml implements the Docutils XML parser of Python docutils:
+it reads Docutils XML (as written by the writers/docutilsxml package) back
+into a document tree.
+
+URL of Python source code:
+http://sourceforge.net/p/docutils/code/HEAD/tree/trunk/docutils/docutils/parsers/docutils_xml.py
+*/
+package docutilsxml
+
+import (
+	"encoding/xml"
+	"io"
+	"regexp"
+	"strings"
+
+	rst "github.com/siongui/go-rst"
+	"github.com/siongui/go-rst/parsers"
+)
+
+type ParseError struct {
+	msg string
+}
+
+func (e *ParseError) Error() string {
+	return e.msg
+}
+
+// Attributes holding lists of names, serialized with `rst.SerialEscape()`.
+var listAttributes = map[string]bool{
+	"ids":      true,
+	"classes":  true,
+	"names":    true,
+	"dupnames": true,
+	"backrefs": true,
+}
+
+/*
+   The Docutils XML parser. `Parse()` fills a document from the XML
+   serialization of a document tree: the attributes of the root "document"
+   element are set on `document`, its children appended to it.
+*/
+type Parser struct {
+	parsers.Base
+}
+
+func (p *Parser) Supports(format string) bool {
+	return format == "xml" || format == "docutils_xml"
+}
+
+func (p *Parser) Parse(inputstring string, document *rst.Document) error {
+	root, err := ParseElement(inputstring, document)
+	if err != nil {
+		return err
+	}
+	if root.TagName() != "document" {
+		return &ParseError{"Document root must be a <document> element, not <" + root.TagName() + ">."}
+	}
+	document.UpdateAttributes(root)
+	document.Extend(root.Children()...)
+	// register ids, targets and references, so that transforms can
+	// resolve the references
+	document.NoteTree(&document.Element)
+	return nil
+}
+
+/*
+   Return a new document for the source `sourcePath` with the settings
+   `settings` (the defaults if nil), parsed from the Docutils XML string
+   `inputstring`. Convenient to build document trees, e.g. in tests.
+*/
+func ParseDocument(inputstring, sourcePath string, settings *rst.Settings) (*rst.Document, error) {
+	document := rst.NewDocument(sourcePath, settings)
+	if err := (&Parser{}).Parse(inputstring, document); err != nil {
+		return nil, err
+	}
+	return document, nil
+}
+
+/*
+   Parse the XML string `inputstring` and return the document tree element
+   of its root element. Unknown elements are kept as generic elements.
+*/
+func ParseElement(inputstring string, document *rst.Document) (*rst.Element, error) {
+	decoder := xml.NewDecoder(strings.NewReader(inputstring))
+	var stack []*rst.Element
+	var root *rst.Element
+	for {
+		token, err := decoder.Token()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, &ParseError{"Invalid Docutils XML: " + err.Error()}
+		}
+		switch tok := token.(type) {
+		case xml.StartElement:
+			node := &rst.Element{}
+			node.Init(tok.Name.Local, "", "")
+			setAttributes(node, tok.Attr)
+			if len(stack) > 0 {
+				stack[len(stack)-1].Append(node)
+			} else if root == nil {
+				root = node
+			}
+			stack = append(stack, node)
+		case xml.EndElement:
+			node := stack[len(stack)-1]
+			stack = stack[:len(stack)-1]
+			cleanText(node)
+		case xml.CharData:
+			if len(stack) == 0 {
+				continue
+			}
+			text := &rst.Text{}
+			text.Init(string(tok), string(tok))
+			stack[len(stack)-1].Append(text)
+		}
+	}
+	if root == nil {
+		return nil, &ParseError{"Invalid Docutils XML: no root element."}
+	}
+	return root, nil
+}
+
+func setAttributes(node *rst.Element, attrs []xml.Attr) {
+	for _, attr := range attrs {
+		name := attr.Name.Local
+		switch attr.Name.Space {
+		case "":
+		case "xml", "http://www.w3.org/XML/1998/namespace":
+			name = "xml:" + name
+		default:
+			// skip attributes of other namespaces
+			continue
+		}
+		if !listAttributes[name] {
+			node.Set(name, attr.Value)
+			continue
+		}
+		values := splitNameList(attr.Value)
+		switch name {
+		case "ids":
+			node.Ids = values
+		case "classes":
+			node.Classes = values
+		case "names":
+			node.Names = values
+		case "dupnames":
+			node.Dupnames = values
+		case "backrefs":
+			node.Backrefs = values
+		}
+	}
+}
+
+// Split a space-separated list of names, undoing `rst.SerialEscape()`.
+func splitNameList(value string) []string {
+	var names []string
+	var name strings.Builder
+	escaped := false
+	for _, r := range value {
+		switch {
+		case escaped:
+			name.WriteRune(r)
+			escaped = false
+		case r == '\\':
+			escaped = true
+		case r == ' ':
+			if name.Len() > 0 {
+				names = append(names, name.String())
+				name.Reset()
+			}
+		default:
+			name.WriteRune(r)
+		}
+	}
+	if name.Len() > 0 {
+		names = append(names, name.String())
+	}
+	return names
+}
+
+var indentation = regexp.MustCompile(`\n[ \t]+`)
+
+/*
+   Drop the whitespace between the child elements of `node` (unless it is a
+   text element), merge adjacent text nodes, and remove the indentation
+   added by the writer from the text of text elements (but not fixed text
+   elements).
+*/
+func cleanText(node *rst.Element) {
+	var children []rst.Node
+	for _, child := range node.Children() {
+		text, ok := child.(*rst.Text)
+		if !ok {
+			children = append(children, child)
+			continue
+		}
+		if !node.Is(rst.TextElementClass) && strings.TrimSpace(text.AsText()) == "" {
+			continue
+		}
+		if n := len(children); n > 0 {
+			if prev, ok := children[n-1].(*rst.Text); ok {
+				prev.Init(prev.AsText()+text.AsText(), prev.RawSource()+text.RawSource())
+				continue
+			}
+		}
+		children = append(children, text)
+	}
+	if node.Is(rst.TextElementClass) && !node.Is(rst.FixedTextElementClass) && node.TagName() != "literal" {
+		for _, child := range children {
+			if text, ok := child.(*rst.Text); ok && strings.Contains(text.AsText(), "\n") {
+				text.SetText(indentation.ReplaceAllString(text.AsText(), "\n"))
+			}
+		}
+	}
+	node.SetChildren(children)
+}
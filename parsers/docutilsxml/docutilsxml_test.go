@@ -0,0 +1,100 @@
+package docutilsxml
+
+import (
+	"strings"
+	"testing"
+
+	rst "github.com/siongui/go-rst"
+	"github.com/siongui/go-rst/transforms"
+	"github.com/siongui/go-rst/writers/docutilsxml"
+)
+
+func TestRoundTrip(t *testing.T) {
+	settings := &rst.Settings{}
+	settings.Init()
+	settings.Indents = true
+	document := rst.NewDocument("test.rst", settings)
+	paragraph := &rst.Element{}
+	paragraph.Init("paragraph", "", "A multi-line\nparagraph with ")
+	emphasis := &rst.Element{}
+	emphasis.Init("emphasis", "", "emphasis & <stuff>")
+	emphasis.Classes = append(emphasis.Classes, "a class", "b")
+	paragraph.Append(emphasis)
+	block := &rst.Element{}
+	block.Init("literal_block", "", "if x:\n    pass")
+	block.Set("xml:space", "preserve")
+	target := &rst.Element{}
+	target.Init("target", "", "")
+	target.Ids = append(target.Ids, "here")
+	target.Set("refuri", "http://example.org/?a=1&b=\"2\"")
+	document.Extend(paragraph, block, target)
+
+	writer := &docutilsxml.Writer{}
+	output, err := writer.Write(document)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	parsed := rst.NewDocument("other.rst", settings)
+	parser := &Parser{}
+	if err := parser.Parse(output, parsed); err != nil {
+		t.Fatal(err)
+	}
+	if result, expected := parsed.Pformat("    ", 0), document.Pformat("    ", 0); result != expected {
+		t.Errorf("round trip failed:\n%s\nexpected:\n%s", result, expected)
+	}
+	if parsed.GetElementByID("here") == nil {
+		t.Error("ids not registered")
+	}
+
+	if err := parser.Parse("<paragraph>text</paragraph>", parsed); err == nil {
+		t.Error("non-document root accepted")
+	}
+	if _, err := ParseElement("<document><paragraph>", parsed); err == nil {
+		t.Error("invalid XML accepted")
+	}
+}
+
+func TestReferences(t *testing.T) {
+	settings := &rst.Settings{}
+	settings.Init()
+	settings.WarningStream = nil
+	document := rst.NewDocument("test.xml", settings)
+	input := `<document>
+    <section ids="intro" names="intro">
+        <title>Intro</title>
+        <paragraph>See <reference name="home" refname="home">home</reference> and <reference name="Intro" refname="intro">Intro</reference>.</paragraph>
+        <target ids="home" names="home" refuri="http://example.org/"/>
+        <paragraph><reference anonymous="1" name="Anonymous">Anonymous</reference><footnote_reference auto="1" refname="missing"/><footnote_reference auto="1"/><footnote_reference auto="1" refname="label"/></paragraph>
+        <target anonymous="1" refuri="http://example.org/anonymous"/>
+        <footnote auto="1"><paragraph>Note.</paragraph></footnote>
+        <footnote auto="1" names="label"><paragraph>Labelled note.</paragraph></footnote>
+    </section>
+</document>`
+	if err := (&Parser{}).Parse(input, document); err != nil {
+		t.Fatal(err)
+	}
+	transformer := &transforms.Transformer{}
+	transformer.Init(document)
+	transformer.AddTransforms(transforms.ReferenceTransforms())
+	if err := transformer.ApplyTransforms(); err != nil {
+		t.Fatal(err)
+	}
+
+	writer := &docutilsxml.Writer{}
+	output, err := writer.Write(document)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := `<paragraph>See <reference name="home" refuri="http://example.org/">home</reference> and <reference name="Intro" refid="intro">Intro</reference>.</paragraph>` +
+		`<target ids="home" names="home" refuri="http://example.org/"></target>`
+	// anonymous and auto-numbered footnote references; a labelled one to
+	// an unknown label does not take the number of an unlabelled footnote
+	expected += `<paragraph><reference anonymous="1" name="Anonymous" refuri="http://example.org/anonymous">Anonymous</reference>` +
+		`<problematic ids="id1" refid="id6"></problematic>` +
+		`<footnote_reference auto="1" ids="id2" refid="id5">1</footnote_reference>` +
+		`<footnote_reference auto="1" ids="id3" refid="label">2</footnote_reference></paragraph>`
+	if !strings.Contains(output, expected) || len(document.TakeTransformMessages()) != 1 {
+		t.Errorf("references not resolved:\n%s", output)
+	}
+}
@@ -0,0 +1,34 @@
+/*
+Package parsers holds the Parser interface of Python docutils; the parsers
+themselves live in subpackages (parsers/docutilsxml, ...).
+
+URL of Python source code:
+http://sourceforge.net/p/docutils/code/HEAD/tree/trunk/docutils/docutils/parsers/__init__.py
+*/
+package parsers
+
+import (
+	rst "github.com/siongui/go-rst"
+)
+
+/*
+   Abstract base type for docutils Parsers.
+
+   Parsers fill a document tree from input text in a specific format
+   (`Supports()` tells which formats). They are transform components:
+   `GetTransforms()` returns the transforms to apply to the parsed
+   document.
+*/
+type Parser interface {
+	rst.TransformSpec
+	Supports(format string) bool
+	Parse(inputstring string, document *rst.Document) error
+}
+
+// Defaults common to all parsers, to embed in parser types.
+type Base struct{}
+
+// Return the transforms the parser needs: none by default.
+func (p *Base) GetTransforms() []rst.Transform {
+	return nil
+}
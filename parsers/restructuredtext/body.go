@@ -0,0 +1,1377 @@
+package restructuredtext
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	rst "github.com/siongui/go-rst"
+)
+
+const (
+	nonalphanum7bit = "[!-/:-@[-`{-~]"
+	simplename      = `[\pL\pN]+(?:[-._+:][\pL\pN]+)*`
+	enumPattern     = `([0-9]+|[a-z]|[A-Z]|[ivxlcdm]+|[IVXLCDM]+|#)`
+	optarg          = `([a-zA-Z][a-zA-Z0-9_-]*|<[^<>]+>)`
+	shortopt        = `(-|\+)[a-zA-Z0-9]( ?` + optarg + `)?`
+	longopt         = `(--|/)[a-zA-Z0-9][a-zA-Z0-9_-]*([ =]` + optarg + `)?`
+	option          = `(` + shortopt + `|` + longopt + `)`
+)
+
+// The transition patterns of the states, by transition name.
+var patterns = map[string]rst.Pattern{
+	"bullet": regexp.MustCompile("^[-+*•‣⁃]( +|$)"),
+	"enumerator": regexp.MustCompile(`^((?P<parens>\(` + enumPattern + `\))|(?P<rparen>` + enumPattern +
+		`\))|(?P<period>` + enumPattern + `\.))( +|$)`),
+	"field_marker":       patternFunc(matchFieldMarker),
+	"option_marker":      regexp.MustCompile(`^` + option + `(, ` + option + `)*(  +| ?$)`),
+	"doctest":            regexp.MustCompile(`^>>>( +|$)`),
+	"line_block":         regexp.MustCompile(`^\|( +|$)`),
+	"grid_table_top":     gridTableTopPattern,
+	"simple_table_top":   regexp.MustCompile(`^=+( +=+)+ *$`),
+	"explicit_markup":    regexp.MustCompile(`^\.\.( +|$)`),
+	"anonymous":          regexp.MustCompile(`^__( +|$)`),
+	"line":               patternFunc(matchLine),
+	"text":               regexp.MustCompile(`^`),
+	"underline":          patternFunc(matchLine),
+	"embedded_directive": regexp.MustCompile(`^(` + simplename + `)::( +|$)`),
+	"initial_quoted":     regexp.MustCompile(`^` + nonalphanum7bit),
+}
+
+var (
+	gridTableTopPattern    = regexp.MustCompile(`^\+-[-+]+-\+ *$`)
+	simpleTableBorderPat   = regexp.MustCompile(`^=+[ =]*$`)
+	enumSequencePatterns   = map[string]*regexp.Regexp{}
+	classifierDelimiter    = regexp.MustCompile(` +: +`)
+	romanNumeralPattern    = regexp.MustCompile(`^M{0,4}(CM|CD|D?C{0,3})(XC|XL|L?X{0,3})(IX|IV|V?I{0,3})$`)
+	optionStringsDelimiter = ", "
+)
+
+// The enumeration sequences, in the order they are tried.
+var enumSequences = []string{"arabic", "loweralpha", "upperalpha", "lowerroman", "upperroman"}
+
+// The enumerator formats, in the order they are tried, and their prefix
+// and suffix.
+var enumFormats = []string{"parens", "rparen", "period"}
+
+var enumFormatInfo = map[string][2]string{
+	"parens": {"(", ")"},
+	"rparen": {"", ")"},
+	"period": {"", "."},
+}
+
+func init() {
+	for sequence, pattern := range map[string]string{
+		"arabic":     "[0-9]+",
+		"loweralpha": "[a-z]",
+		"upperalpha": "[A-Z]",
+		"lowerroman": "[ivxlcdm]+",
+		"upperroman": "[IVXLCDM]+",
+	} {
+		enumSequencePatterns[sequence] = regexp.MustCompile("^" + pattern + "$")
+	}
+}
+
+/*
+   A transition pattern Go regular expressions cannot express: the function
+   returns the end of the match at the beginning of the line, -1 if there
+   is none.
+*/
+type patternFunc func(line string) int
+
+func (f patternFunc) FindStringSubmatchIndex(line string) []int {
+	if end := f(line); end >= 0 {
+		return []int{0, end}
+	}
+	return nil
+}
+
+func (f patternFunc) SubexpNames() []string {
+	return []string{""}
+}
+
+/*
+   Match a field marker: ":" and a field name not starting with a colon or
+   a space nor ending with a space, ":", then spaces or the end of the
+   line. Colons in the name must not be followed by a space or a backquote;
+   backslashes escape the next character.
+*/
+func matchFieldMarker(line string) int {
+	if len(line) < 2 || line[0] != ':' || line[1] == ':' || line[1] == ' ' {
+		return -1
+	}
+	for i := 1; i < len(line); i++ {
+		switch line[i] {
+		case '\\':
+			i++
+			if i == len(line) {
+				return -1
+			}
+		case ':':
+			if i+1 < len(line) && line[i+1] != ' ' {
+				if line[i+1] == '`' {
+					return -1
+				}
+				continue
+			}
+			if line[i-1] == ' ' {
+				return -1
+			}
+			end := i + 1
+			for end < len(line) && line[end] == ' ' {
+				end++
+			}
+			return end
+		}
+	}
+	return -1
+}
+
+// Match a line of one repeated 7-bit non-alphanumeric character, such as
+// a section title adornment or a transition marker.
+func matchLine(line string) int {
+	if line == "" || !strings.ContainsRune("!\"#$%&'()*+,-./:;<=>?@[\\]^_`{|}~", rune(line[0])) {
+		return -1
+	}
+	i := 1
+	for i < len(line) && line[i] == line[0] {
+		i++
+	}
+	if strings.TrimLeft(line[i:], " ") != "" {
+		return -1
+	}
+	return len(line)
+}
+
+// Return the end of the match `match`, counted in characters.
+func matchEnd(match *rst.Match) int {
+	return utf8.RuneCountInString(match.String[:match.End(0)])
+}
+
+// Return `line` without its first `n` characters.
+func sliceChars(line string, n int) string {
+	for i := range line {
+		if n == 0 {
+			return line[i:]
+		}
+		n--
+	}
+	return ""
+}
+
+func joinLines(block rst.StringList) string {
+	return strings.Join(block.Lines(), "\n")
+}
+
+// Return values of the transition methods when the method fails.
+func fail(context []string, err error) ([]string, string, []string, error) {
+	return context, "", nil, err
+}
+
+// Generic classifier of the first line of a block.
+type body struct {
+	rstState
+}
+
+var bodyTransitions = []string{
+	"bullet",
+	"enumerator",
+	"field_marker",
+	"option_marker",
+	"doctest",
+	"line_block",
+	"grid_table_top",
+	"simple_table_top",
+	"explicit_markup",
+	"anonymous",
+	"line",
+	"text",
+}
+
+func newBody(sm *stateMachine) *body {
+	s := &body{}
+	s.init(sm, "Body", bodyTransitions, s.methods(), s.Nop, s.indent)
+	return s
+}
+
+func (s *body) methods() map[string]rst.TransitionMethod {
+	return map[string]rst.TransitionMethod{
+		"bullet":           s.bullet,
+		"enumerator":       s.enumerator,
+		"field_marker":     s.fieldMarker,
+		"option_marker":    s.optionMarker,
+		"doctest":          s.doctest,
+		"line_block":       s.lineBlock,
+		"grid_table_top":   s.gridTableTop,
+		"simple_table_top": s.simpleTableTop,
+		"explicit_markup":  s.explicitMarkup,
+		"anonymous":        s.anonymous,
+		"line":             s.line,
+		"text":             s.text,
+	}
+}
+
+// Block quote.
+func (s *body) indent(match *rst.Match, context []string, nextState string) ([]string, string, []string, error) {
+	indented, _, lineOffset, blankFinish := s.sm.GetIndented(false, true)
+	elements, err := s.blockQuote(indented, lineOffset)
+	if err != nil {
+		return fail(context, err)
+	}
+	s.parent.Extend(elements...)
+	if !blankFinish {
+		s.parent.Append(s.unindentWarning("Block quote"))
+	}
+	return context, nextState, nil, nil
+}
+
+func (s *body) blockQuote(indented rst.StringList, lineOffset int) ([]rst.Node, error) {
+	var elements []rst.Node
+	for indented.Length() > 0 {
+		blockquote := newElement("block_quote", joinLines(indented), "")
+		s.setSourceAndLine(blockquote, lineOffset+1)
+		blockquoteLines, attributionLines, attributionOffset, rest, newLineOffset, found := s.splitAttribution(indented, lineOffset)
+		if _, err := s.nestedParse(blockquoteLines, lineOffset, blockquote, false); err != nil {
+			return nil, err
+		}
+		elements = append(elements, blockquote)
+		if !found {
+			break
+		}
+		attribution, messages := s.parseAttribution(attributionLines, lineOffset+attributionOffset)
+		blockquote.Append(attribution)
+		elements = append(elements, messages...)
+		lineOffset = newLineOffset
+		indented = rest
+		for indented.Length() > 0 && indented.Lines()[0] == "" {
+			indented.TrimStart(1)
+			lineOffset++
+		}
+	}
+	return elements, nil
+}
+
+/*
+   Check for a block quote attribution and split it off:
+
+   - First line after a blank line must begin with a dash ("--", "---",
+     em-dash; matches `attributionMarker()`).
+   - Every line after that must have consistent indentation.
+   - Attributions must be preceded by block quote content.
+
+   Return a tuple of: (block quote content lines, attribution lines,
+   attribution offset, remaining indented lines, new line offset, whether
+   an attribution was found).
+*/
+func (s *body) splitAttribution(indented rst.StringList, lineOffset int) (rst.StringList, rst.StringList, int, rst.StringList, int, bool) {
+	blank := -1
+	nonblankSeen := false
+	lines := indented.Lines()
+	for i := range lines {
+		line := strings.TrimRightFunc(lines[i], unicode.IsSpace)
+		if line == "" {
+			blank = i
+			continue
+		}
+		if nonblankSeen && blank == i-1 { // last line blank
+			if end := attributionMarker(line); end >= 0 {
+				attributionEnd, indent := checkAttribution(lines, i)
+				if attributionEnd > 0 {
+					aLines := indented.GetItemsSlice(i, attributionEnd)
+					aLines.Disconnect(0)
+					aLines.TrimLeft(end, 0, 1)
+					aLines.TrimLeft(indent, 1, aLines.Length())
+					return indented.GetItemsSlice(0, i), aLines, i,
+						indented.GetItemsSlice(attributionEnd, indented.Length()),
+						lineOffset + attributionEnd, true
+				}
+			}
+		}
+		nonblankSeen = true
+	}
+	return indented, rst.StringList{}, 0, rst.StringList{}, 0, false
+}
+
+/*
+   Return the end of the attribution marker at the beginning of `line`
+   (a dash, "--", "---" or an em-dash, then spaces before the text), -1 if
+   there is none.
+*/
+func attributionMarker(line string) int {
+	var end int
+	switch {
+	case strings.HasPrefix(line, "---") && !strings.HasPrefix(line, "----"):
+		end = 3
+	case strings.HasPrefix(line, "--") && !strings.HasPrefix(line, "---"):
+		end = 2
+	case strings.HasPrefix(line, "—"):
+		end = len("—")
+	default:
+		return -1
+	}
+	for end < len(line) && line[end] == ' ' {
+		end++
+	}
+	if end == len(line) {
+		return -1
+	}
+	return end
+}
+
+/*
+   Check attribution shape. Return the index past the end of the
+   attribution, and the indent (0 if the shape is bad).
+*/
+func checkAttribution(lines []string, attributionStart int) (int, int) {
+	indent := -1
+	i := attributionStart + 1
+	for ; i < len(lines); i++ {
+		line := strings.TrimRightFunc(lines[i], unicode.IsSpace)
+		if line == "" {
+			break
+		}
+		lineIndent := len(line) - len(strings.TrimLeft(line, " "))
+		if indent < 0 {
+			indent = lineIndent
+		} else if lineIndent != indent {
+			return 0, 0 // bad shape; not an attribution
+		}
+	}
+	if indent < 0 {
+		indent = 0
+	}
+	return i, indent
+}
+
+func (s *body) parseAttribution(indented rst.StringList, lineOffset int) (*rst.Element, []rst.Node) {
+	text := strings.TrimRightFunc(joinLines(indented), unicode.IsSpace)
+	lineno := 1 + lineOffset // lineOffset is zero-based
+	textnodes, messages := s.inlineText(text, lineno)
+	node := newElement("attribution", text, "", textnodes...)
+	s.setSourceAndLine(node, lineno)
+	return node, messages
+}
+
+// Bullet list item.
+func (s *body) bullet(match *rst.Match, context []string, nextState string) ([]string, string, []string, error) {
+	ul := newElement("bullet_list", "", "")
+	s.setSourceAndLine(ul, 0)
+	s.parent.Append(ul)
+	bullet, _ := utf8.DecodeRuneInString(match.String)
+	ul.Set("bullet", string(bullet))
+	item, blankFinish, err := s.listItem(matchEnd(match))
+	if err != nil {
+		return fail(context, err)
+	}
+	ul.Append(item)
+	newLineOffset, blankFinish, err := s.nestedListParse(s.nextLines(), s.sm.AbsLineOffset()+1, ul, "BulletList", blankFinish, "", nil, false)
+	if err != nil {
+		return fail(context, err)
+	}
+	s.gotoLine(newLineOffset)
+	if !blankFinish {
+		s.parent.Append(s.unindentWarning("Bullet list"))
+	}
+	return nil, nextState, nil, nil
+}
+
+// Return a list item whose text starts after `indent` characters of the
+// current line, and the blank finish flag.
+func (s *body) listItem(indent int) (*rst.Element, bool, error) {
+	source, line := s.sm.GetSourceAndLine(0)
+	var indented rst.StringList
+	var lineOffset int
+	var blankFinish bool
+	if sliceChars(s.sm.Line, indent) != "" {
+		indented, lineOffset, blankFinish = s.sm.GetKnownIndented(indent, false, true)
+	} else {
+		indented, _, lineOffset, blankFinish = s.sm.GetFirstKnownIndented(indent, false, true, true)
+	}
+	listitem := newElement("list_item", joinLines(indented), "")
+	listitem.SetSource(source)
+	listitem.SetLine(line)
+	if indented.Length() > 0 {
+		if _, err := s.nestedParse(indented, lineOffset, listitem, false); err != nil {
+			return nil, false, err
+		}
+	}
+	return listitem, blankFinish, nil
+}
+
+// Enumerated List Item.
+func (s *body) enumerator(match *rst.Match, context []string, nextState string) ([]string, string, []string, error) {
+	format, sequence, text, ordinal := s.parseEnumerator(match, "")
+	if !s.isEnumeratedListItem(ordinal, sequence, format) {
+		return fail(context, &rst.TransitionCorrection{Transition: "text"})
+	}
+	enumlist := newElement("enumerated_list", "", "")
+	s.parent.Append(enumlist)
+	if sequence == "#" {
+		enumlist.Set("enumtype", "arabic")
+	} else {
+		enumlist.Set("enumtype", sequence)
+	}
+	enumlist.Set("prefix", enumFormatInfo[format][0])
+	enumlist.Set("suffix", enumFormatInfo[format][1])
+	if ordinal != 1 {
+		enumlist.Set("start", strconv.Itoa(ordinal))
+		msg := s.reporter.Info("Enumerated list start value not ordinal-1: \""+text+
+			"\" (ordinal "+strconv.Itoa(ordinal)+")", "", s.sm.AbsLineNumber())
+		s.parent.Append(msg)
+	}
+	item, blankFinish, err := s.listItem(matchEnd(match))
+	if err != nil {
+		return fail(context, err)
+	}
+	enumlist.Append(item)
+	newLineOffset, blankFinish, err := s.nestedListParse(s.nextLines(), s.sm.AbsLineOffset()+1, enumlist, "EnumeratedList", blankFinish, "",
+		func(sm *stateMachine) {
+			state := sm.State("EnumeratedList").(*enumeratedList)
+			state.lastordinal = ordinal
+			state.format = format
+			state.auto = sequence == "#"
+		}, false)
+	if err != nil {
+		return fail(context, err)
+	}
+	s.gotoLine(newLineOffset)
+	if !blankFinish {
+		s.parent.Append(s.unindentWarning("Enumerated list"))
+	}
+	return nil, nextState, nil, nil
+}
+
+/*
+   Analyze an enumerator and return the results.
+
+   Return:
+
+   - the enumerator format ("period", "parens", or "rparen"),
+   - the sequence used ("arabic", "loweralpha", "upperroman", etc.),
+   - the text of the enumerator, stripped of formatting, and
+   - the ordinal value of the enumerator ("a" -> 1, "ii" -> 2, etc.; -1 is
+     returned for invalid enumerator text).
+
+   The enumerator format has already been determined by the regular
+   expression match. If `expectedSequence` is given, that sequence is
+   tried first. If not, we check for Roman numeral 1. This way, single-
+   character Roman numerals (which are also alphabetical) can be matched.
+   If no sequence has been matched, all sequences are checked in order.
+*/
+func (s *body) parseEnumerator(match *rst.Match, expectedSequence string) (string, string, string, int) {
+	var format, text string
+	for _, format = range enumFormats {
+		if text = match.Named(format); text != "" {
+			break
+		}
+	}
+	prefix, suffix := enumFormatInfo[format][0], enumFormatInfo[format][1]
+	text = text[len(prefix) : len(text)-len(suffix)]
+	sequence := ""
+	if text == "#" {
+		sequence = "#"
+	} else if expectedSequence != "" {
+		if enumSequencePatterns[expectedSequence].MatchString(text) {
+			sequence = expectedSequence
+		}
+	} else if text == "i" {
+		sequence = "lowerroman"
+	} else if text == "I" {
+		sequence = "upperroman"
+	}
+	if sequence == "" {
+		for _, sequence = range enumSequences {
+			if enumSequencePatterns[sequence].MatchString(text) {
+				break
+			}
+		}
+	}
+	ordinal := -1
+	switch sequence {
+	case "#":
+		ordinal = 1
+	case "arabic":
+		if n, err := strconv.Atoi(text); err == nil {
+			ordinal = n
+		}
+	case "loweralpha":
+		ordinal = int(text[0]-'a') + 1
+	case "upperalpha":
+		ordinal = int(text[0]-'A') + 1
+	case "lowerroman", "upperroman":
+		ordinal = fromRoman(strings.ToUpper(text))
+	}
+	return format, sequence, text, ordinal
+}
+
+/*
+   Check validity based on the ordinal value and the second line.
+
+   Return true if the ordinal is valid and the second line is blank,
+   indented, or starts with the next enumerator or an auto-enumerator.
+*/
+func (s *body) isEnumeratedListItem(ordinal int, sequence, format string) bool {
+	if ordinal < 0 {
+		return false
+	}
+	nextLine, err := s.sm.NextLine(1)
+	s.sm.PreviousLine(1)
+	if err != nil { // end of input lines
+		return true
+	}
+	if first, _ := utf8.DecodeRuneInString(nextLine); nextLine == "" || unicode.IsSpace(first) {
+		return true // blank or indented
+	}
+	nextEnumerator, autoEnumerator, ok := makeEnumerator(ordinal+1, sequence, format)
+	return ok && (strings.HasPrefix(nextLine, nextEnumerator) || strings.HasPrefix(nextLine, autoEnumerator))
+}
+
+/*
+   Construct and return the next enumerated list item marker, and an
+   auto-enumerator ("#" instead of the regular enumerator). Return false
+   for invalid (out of range) ordinals.
+*/
+func makeEnumerator(ordinal int, sequence, format string) (string, string, bool) {
+	var enumerator string
+	switch sequence {
+	case "#":
+		enumerator = "#"
+	case "arabic":
+		enumerator = strconv.Itoa(ordinal)
+	case "loweralpha", "upperalpha":
+		if ordinal > 26 {
+			return "", "", false
+		}
+		enumerator = string(rune('a' + ordinal - 1))
+	case "lowerroman", "upperroman":
+		if ordinal <= 0 || ordinal >= 5000 {
+			return "", "", false
+		}
+		enumerator = toRoman(ordinal)
+	}
+	if strings.HasPrefix(sequence, "lower") {
+		enumerator = strings.ToLower(enumerator)
+	} else if strings.HasPrefix(sequence, "upper") {
+		enumerator = strings.ToUpper(enumerator)
+	}
+	prefix, suffix := enumFormatInfo[format][0], enumFormatInfo[format][1]
+	return prefix + enumerator + suffix + " ", prefix + "#" + suffix + " ", true
+}
+
+var romanNumerals = []struct {
+	value   int
+	numeral string
+}{
+	{1000, "M"}, {900, "CM"}, {500, "D"}, {400, "CD"}, {100, "C"}, {90, "XC"},
+	{50, "L"}, {40, "XL"}, {10, "X"}, {9, "IX"}, {5, "V"}, {4, "IV"}, {1, "I"},
+}
+
+func toRoman(n int) string {
+	var b strings.Builder
+	for _, r := range romanNumerals {
+		for n >= r.value {
+			b.WriteString(r.numeral)
+			n -= r.value
+		}
+	}
+	return b.String()
+}
+
+// Convert an upper case Roman numeral to an integer, -1 if it is invalid.
+func fromRoman(s string) int {
+	if s == "" || !romanNumeralPattern.MatchString(s) {
+		return -1
+	}
+	result := 0
+	for _, r := range romanNumerals {
+		for strings.HasPrefix(s, r.numeral) {
+			result += r.value
+			s = s[len(r.numeral):]
+		}
+	}
+	return result
+}
+
+// Field list item.
+func (s *body) fieldMarker(match *rst.Match, context []string, nextState string) ([]string, string, []string, error) {
+	fieldList := newElement("field_list", "", "")
+	s.parent.Append(fieldList)
+	field, blankFinish, err := s.field(match)
+	if err != nil {
+		return fail(context, err)
+	}
+	fieldList.Append(field)
+	newLineOffset, blankFinish, err := s.nestedListParse(s.nextLines(), s.sm.AbsLineOffset()+1, fieldList, "FieldList", blankFinish, "", nil, false)
+	if err != nil {
+		return fail(context, err)
+	}
+	s.gotoLine(newLineOffset)
+	if !blankFinish {
+		s.parent.Append(s.unindentWarning("Field list"))
+	}
+	return nil, nextState, nil, nil
+}
+
+func (s *body) field(match *rst.Match) (*rst.Element, bool, error) {
+	name := parseFieldMarker(match)
+	source, line := s.sm.GetSourceAndLine(0)
+	lineno := s.sm.AbsLineNumber()
+	indented, _, lineOffset, blankFinish := s.sm.GetFirstKnownIndented(matchEnd(match), false, true, true)
+	fieldNode := newElement("field", "", "")
+	fieldNode.SetSource(source)
+	fieldNode.SetLine(line)
+	nameNodes, nameMessages := s.inlineText(name, lineno)
+	fieldNode.Append(newElement("field_name", name, "", nameNodes...))
+	fieldBody := newElement("field_body", joinLines(indented), "", nameMessages...)
+	fieldNode.Append(fieldBody)
+	if indented.Length() > 0 {
+		if _, err := s.nestedParse(indented, lineOffset, fieldBody, false); err != nil {
+			return nil, false, err
+		}
+	}
+	return fieldNode, blankFinish, nil
+}
+
+// Extract & return field name from a field marker match.
+func parseFieldMarker(match *rst.Match) string {
+	field := match.Group(0)[1:]                  // strip off leading ':'
+	return field[:strings.LastIndex(field, ":")] // strip off trailing ':' etc.
+}
+
+// Option list item.
+func (s *body) optionMarker(match *rst.Match, context []string, nextState string) ([]string, string, []string, error) {
+	optionlist := newElement("option_list", "", "")
+	s.setSourceAndLine(optionlist, 0)
+	listitem, blankFinish, err := s.optionListItem(match)
+	if e, ok := err.(*markupError); ok {
+		// This shouldn't happen; pattern won't match.
+		s.parent.Append(s.reporter.Error("Invalid option list marker: "+e.msg, "", s.sm.AbsLineNumber()))
+		indented, _, lineOffset, blankFinish := s.sm.GetFirstKnownIndented(matchEnd(match), false, true, true)
+		elements, err := s.blockQuote(indented, lineOffset)
+		if err != nil {
+			return fail(context, err)
+		}
+		s.parent.Extend(elements...)
+		if !blankFinish {
+			s.parent.Append(s.unindentWarning("Option list"))
+		}
+		return nil, nextState, nil, nil
+	} else if err != nil {
+		return fail(context, err)
+	}
+	s.parent.Append(optionlist)
+	optionlist.Append(listitem)
+	newLineOffset, blankFinish, err := s.nestedListParse(s.nextLines(), s.sm.AbsLineOffset()+1, optionlist, "OptionList", blankFinish, "", nil, false)
+	if err != nil {
+		return fail(context, err)
+	}
+	s.gotoLine(newLineOffset)
+	if !blankFinish {
+		s.parent.Append(s.unindentWarning("Option list"))
+	}
+	return nil, nextState, nil, nil
+}
+
+func (s *body) optionListItem(match *rst.Match) (*rst.Element, bool, error) {
+	offset := s.sm.AbsLineOffset()
+	options, err := parseOptionMarker(match)
+	if err != nil {
+		return nil, false, err
+	}
+	indented, _, lineOffset, blankFinish := s.sm.GetFirstKnownIndented(matchEnd(match), false, true, true)
+	if indented.Length() == 0 { // not an option list item
+		s.gotoLine(offset)
+		return nil, false, &rst.TransitionCorrection{Transition: "text"}
+	}
+	optionGroup := newElement("option_group", "", "", options...)
+	description := newElement("description", joinLines(indented), "")
+	optionListItem := newElement("option_list_item", "", "", optionGroup, description)
+	if _, err := s.nestedParse(indented, lineOffset, description, false); err != nil {
+		return nil, false, err
+	}
+	return optionListItem, blankFinish, nil
+}
+
+/*
+   Return a list of `option` elements from an option list marker match.
+   Return a `markupError` if the option has a wrong number of tokens.
+*/
+func parseOptionMarker(match *rst.Match) ([]rst.Node, error) {
+	var optlist []rst.Node
+	for _, optionstring := range splitOptions(strings.TrimRightFunc(match.Group(0), unicode.IsSpace)) {
+		tokens := strings.Fields(optionstring)
+		delimiter := " "
+		if firstopt := strings.SplitN(tokens[0], "=", 2); len(firstopt) > 1 {
+			// "--opt=value" form
+			tokens = append(firstopt, tokens[1:]...)
+			delimiter = "="
+		} else if len(tokens[0]) > 2 && (strings.HasPrefix(tokens[0], "-") && !strings.HasPrefix(tokens[0], "--") ||
+			strings.HasPrefix(tokens[0], "+")) {
+			// "-ovalue" form
+			tokens = append([]string{tokens[0][:2], tokens[0][2:]}, tokens[1:]...)
+			delimiter = ""
+		}
+		if len(tokens) > 1 && strings.HasPrefix(tokens[1], "<") && strings.HasSuffix(tokens[len(tokens)-1], ">") {
+			// "-o <value1 value2>" form; join value tokens again
+			tokens = []string{tokens[0], strings.Join(tokens[1:], " ")}
+		}
+		if len(tokens) > 2 {
+			return nil, &markupError{"wrong number of option tokens (=" + strconv.Itoa(len(tokens)) +
+				"), should be 1 or 2: \"" + optionstring + "\""}
+		}
+		option := newElement("option", optionstring, "")
+		option.Append(newElement("option_string", tokens[0], tokens[0]))
+		if len(tokens) > 1 {
+			argument := newElement("option_argument", tokens[1], tokens[1])
+			argument.Set("delimiter", delimiter)
+			option.Append(argument)
+		}
+		optlist = append(optlist, option)
+	}
+	return optlist, nil
+}
+
+// Split an option list marker at ", ", except inside < > (complex
+// arguments).
+func splitOptions(marker string) []string {
+	var result []string
+	start := 0
+	for i := 0; i+len(optionStringsDelimiter) <= len(marker); i++ {
+		if !strings.HasPrefix(marker[i:], optionStringsDelimiter) {
+			continue
+		}
+		rest := marker[i+len(optionStringsDelimiter):]
+		if j := strings.IndexAny(rest, "<>"); j >= 0 && rest[j] == '>' {
+			continue
+		}
+		result = append(result, marker[start:i])
+		start = i + len(optionStringsDelimiter)
+	}
+	return append(result, marker[start:])
+}
+
+func (s *body) doctest(match *rst.Match, context []string, nextState string) ([]string, string, []string, error) {
+	block, _ := s.sm.GetTextBlock(false)
+	data := joinLines(block)
+	s.parent.Append(newElement("doctest_block", data, data))
+	return nil, nextState, nil, nil
+}
+
+// First line of a line block.
+func (s *body) lineBlock(match *rst.Match, context []string, nextState string) ([]string, string, []string, error) {
+	block := newElement("line_block", "", "")
+	s.parent.Append(block)
+	lineno := s.sm.AbsLineNumber()
+	line, messages, blankFinish := s.lineBlockLine(match, lineno)
+	block.Append(line)
+	s.parent.Extend(messages...)
+	if !blankFinish {
+		newLineOffset, finish, err := s.nestedListParse(s.nextLines(), s.sm.AbsLineOffset()+1, block, "LineBlock", false, "", nil, false)
+		if err != nil {
+			return fail(context, err)
+		}
+		blankFinish = finish
+		s.gotoLine(newLineOffset)
+	}
+	if !blankFinish {
+		s.parent.Append(s.reporter.Warning("Line block ends without a blank line.", "", lineno+1))
+	}
+	if block.Len() > 0 {
+		first := block.Children()[0].(*rst.Element)
+		if _, ok := s.memo.lineIndents[first]; !ok {
+			s.memo.lineIndents[first] = 0
+		}
+		s.nestLineBlockLines(block)
+	}
+	return nil, nextState, nil, nil
+}
+
+// Return one line element of a line_block.
+func (s *body) lineBlockLine(match *rst.Match, lineno int) (*rst.Element, []rst.Node, bool) {
+	indented, _, _, blankFinish := s.sm.GetFirstKnownIndented(matchEnd(match), true, true, true)
+	text := joinLines(indented)
+	textNodes, messages := s.inlineText(text, lineno)
+	line := newElement("line", text, "", textNodes...)
+	if strings.TrimRightFunc(match.String, unicode.IsSpace) != "|" { // not empty
+		s.memo.lineIndents[line] = len(match.Group(1)) - 1
+	}
+	return line, messages, blankFinish
+}
+
+func (s *body) nestLineBlockLines(block *rst.Element) {
+	indents := s.memo.lineIndents
+	children := block.Children()
+	for i := 1; i < len(children); i++ {
+		line := children[i].(*rst.Element)
+		if _, ok := indents[line]; !ok {
+			indents[line] = indents[children[i-1].(*rst.Element)]
+		}
+	}
+	s.nestLineBlockSegment(block)
+}
+
+func (s *body) nestLineBlockSegment(block *rst.Element) {
+	indents := s.memo.lineIndents
+	least := -1
+	for _, item := range block.Children() {
+		if indent := indents[item.(*rst.Element)]; least < 0 || indent < least {
+			least = indent
+		}
+	}
+	var newItems []rst.Node
+	newBlock := newElement("line_block", "", "")
+	for _, item := range block.Children() {
+		if indents[item.(*rst.Element)] > least {
+			newBlock.Append(item)
+			continue
+		}
+		if newBlock.Len() > 0 {
+			s.nestLineBlockSegment(newBlock)
+			newItems = append(newItems, newBlock)
+			newBlock = newElement("line_block", "", "")
+		}
+		newItems = append(newItems, item)
+	}
+	if newBlock.Len() > 0 {
+		s.nestLineBlockSegment(newBlock)
+		newItems = append(newItems, newBlock)
+	}
+	block.SetChildren(newItems)
+}
+
+// Top border of a full table.
+func (s *body) gridTableTop(match *rst.Match, context []string, nextState string) ([]string, string, []string, error) {
+	return s.tableTop(context, nextState, s.isolateGridTable, parseGridTable)
+}
+
+// Top border of a simple table.
+func (s *body) simpleTableTop(match *rst.Match, context []string, nextState string) ([]string, string, []string, error) {
+	return s.tableTop(context, nextState, s.isolateSimpleTable, parseSimpleTable)
+}
+
+// Top border of a generic table.
+func (s *body) tableTop(context []string, nextState string, isolate func() (rst.StringList, []rst.Node, bool), parse func(rst.StringList) (*tableData, error)) ([]string, string, []string, error) {
+	nodelist, blankFinish, err := s.table(isolate, parse)
+	if err != nil {
+		return fail(context, err)
+	}
+	s.parent.Extend(nodelist...)
+	if !blankFinish {
+		s.parent.Append(s.reporter.Warning("Blank line required after table.", "", s.sm.AbsLineNumber()+1))
+	}
+	return nil, nextState, nil, nil
+}
+
+// Parse a table.
+func (s *body) table(isolate func() (rst.StringList, []rst.Node, bool), parse func(rst.StringList) (*tableData, error)) ([]rst.Node, bool, error) {
+	block, messages, blankFinish := isolate()
+	if block.Length() == 0 {
+		return messages, blankFinish, nil
+	}
+	tabledata, err := parse(block)
+	if e, ok := err.(*tableMarkupError); ok {
+		return append(s.malformedTable(block, e.msg, e.offset), messages...), blankFinish, nil
+	}
+	tableline := s.sm.AbsLineNumber() - block.Length() + 1
+	table, err := s.buildTable(tabledata, tableline)
+	if err != nil {
+		return nil, false, err
+	}
+	return append([]rst.Node{table}, messages...), blankFinish, nil
+}
+
+func (s *body) isolateGridTable() (rst.StringList, []rst.Node, bool) {
+	var messages []rst.Node
+	blankFinish := true
+	block, err := s.sm.GetTextBlock(true)
+	if e, ok := err.(*rst.UnexpectedIndentationError); ok {
+		messages = append(messages, s.reporter.Error("Unexpected indentation.", e.Source, e.Lineno))
+		blankFinish = false
+	}
+	block.Disconnect(0)
+	// for East Asian chars:
+	padDoubleWidth(&block)
+	width := utf8.RuneCountInString(strings.TrimSpace(block.Lines()[0]))
+	for i, line := range block.Lines() {
+		line = strings.TrimSpace(line)
+		block.SetItem(i, line)
+		if line[0] != '+' && line[0] != '|' { // check left edge
+			blankFinish = false
+			s.sm.PreviousLine(block.Length() - i)
+			block.TrimEnd(block.Length() - i)
+			break
+		}
+	}
+	lines := block.Lines()
+	if !gridTableTopPattern.MatchString(lines[len(lines)-1]) { // find bottom
+		blankFinish = false
+		// from second-last to third line of table:
+		found := false
+		for i := len(lines) - 2; i > 1; i-- {
+			if gridTableTopPattern.MatchString(lines[i]) {
+				s.sm.PreviousLine(len(lines) - i + 1)
+				block.TrimEnd(len(lines) - i - 1)
+				found = true
+				break
+			}
+		}
+		if !found {
+			messages = append(messages, s.malformedTable(block, "", 0)...)
+			return rst.StringList{}, messages, blankFinish
+		}
+	}
+	for _, line := range block.Lines() { // check right edge
+		if utf8.RuneCountInString(line) != width || !strings.HasSuffix(line, "+") && !strings.HasSuffix(line, "|") {
+			messages = append(messages, s.malformedTable(block, "", 0)...)
+			return rst.StringList{}, messages, blankFinish
+		}
+	}
+	return block, messages, blankFinish
+}
+
+func (s *body) isolateSimpleTable() (rst.StringList, []rst.Node, bool) {
+	start := s.sm.LineOffset()
+	lines := s.sm.InputLines()
+	data := lines.Lines()
+	limit := len(data) - 1
+	toplen := utf8.RuneCountInString(strings.TrimSpace(data[start]))
+	found := 0
+	foundAt := -1
+	end := -1
+	i := start + 1
+	for ; i <= limit; i++ {
+		line := data[i]
+		if simpleTableBorderPat.MatchString(line) {
+			if utf8.RuneCountInString(strings.TrimSpace(line)) != toplen {
+				s.sm.NextLine(i - start)
+				messages := s.malformedTable(lines.GetItemsSlice(start, i+1), "Bottom/header table border does not match top border.", 0)
+				return rst.StringList{}, messages, i == limit || strings.TrimSpace(data[i+1]) == ""
+			}
+			found++
+			foundAt = i
+			if found == 2 || i == limit || strings.TrimSpace(data[i+1]) == "" {
+				end = i
+				break
+			}
+		}
+	}
+	if end < 0 { // reached end of input lines
+		var block rst.StringList
+		extra := ""
+		if found > 0 {
+			extra = " or no blank line after table bottom"
+			s.sm.NextLine(foundAt - start)
+			block = lines.GetItemsSlice(start, foundAt+1)
+		} else {
+			s.sm.NextLine(i - start - 1)
+			block = lines.GetItemsSlice(start, lines.Length())
+		}
+		messages := s.malformedTable(block, "No bottom table border found"+extra+".", 0)
+		return rst.StringList{}, messages, extra == ""
+	}
+	s.sm.NextLine(end - start)
+	block := lines.GetItemsSlice(start, end+1)
+	block.Disconnect(0)
+	// for East Asian chars:
+	padDoubleWidth(&block)
+	return block, nil, end == limit || strings.TrimSpace(data[end+1]) == ""
+}
+
+func (s *body) malformedTable(block rst.StringList, detail string, offset int) []rst.Node {
+	block.Disconnect(0)
+	block.Replace(doubleWidthPadChar, "")
+	data := joinLines(block)
+	message := "Malformed table."
+	startline := s.sm.AbsLineNumber() - block.Length() + 1
+	if detail != "" {
+		message += "\n" + detail
+	}
+	return []rst.Node{s.reporter.Error(message, "", startline+offset, newElement("literal_block", data, data))}
+}
+
+func (s *body) buildTable(tabledata *tableData, tableline int) (*rst.Element, error) {
+	table := newElement("table", "", "")
+	tgroup := newElement("tgroup", "", "")
+	tgroup.Set("cols", strconv.Itoa(len(tabledata.colwidths)))
+	table.Append(tgroup)
+	for _, colwidth := range tabledata.colwidths {
+		colspec := newElement("colspec", "", "")
+		colspec.Set("colwidth", strconv.Itoa(colwidth))
+		tgroup.Append(colspec)
+	}
+	if len(tabledata.headrows) > 0 {
+		thead := newElement("thead", "", "")
+		tgroup.Append(thead)
+		for _, row := range tabledata.headrows {
+			r, err := s.buildTableRow(row, tableline)
+			if err != nil {
+				return nil, err
+			}
+			thead.Append(r)
+		}
+	}
+	tbody := newElement("tbody", "", "")
+	tgroup.Append(tbody)
+	for _, row := range tabledata.bodyrows {
+		r, err := s.buildTableRow(row, tableline)
+		if err != nil {
+			return nil, err
+		}
+		tbody.Append(r)
+	}
+	return table, nil
+}
+
+func (s *body) buildTableRow(rowdata []*tableCell, tableline int) (*rst.Element, error) {
+	row := newElement("row", "", "")
+	for _, cell := range rowdata {
+		if cell == nil {
+			continue
+		}
+		entry := newElement("entry", "", "")
+		if cell.morerows > 0 {
+			entry.Set("morerows", strconv.Itoa(cell.morerows))
+		}
+		if cell.morecols > 0 {
+			entry.Set("morecols", strconv.Itoa(cell.morecols))
+		}
+		row.Append(entry)
+		if strings.Join(cell.block.Lines(), "") != "" {
+			if _, err := s.nestedParse(cell.block, tableline+cell.offset, entry, false); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return row, nil
+}
+
+// Footnotes, hyperlink targets, directives, comments.
+func (s *body) explicitMarkup(match *rst.Match, context []string, nextState string) ([]string, string, []string, error) {
+	nodelist, blankFinish, err := s.explicitConstruct(match)
+	if err != nil {
+		return fail(context, err)
+	}
+	s.parent.Extend(nodelist...)
+	if err := s.explicitList(blankFinish); err != nil {
+		return fail(context, err)
+	}
+	return nil, nextState, nil, nil
+}
+
+/*
+   Create a nested state machine for a series of explicit markup
+   constructs (including anonymous hyperlink targets).
+*/
+func (s *body) explicitList(blankFinish bool) error {
+	newLineOffset, blankFinish, err := s.nestedListParse(s.nextLines(), s.sm.AbsLineOffset()+1, s.parent, "Explicit", blankFinish, "", nil, s.sm.matchTitles)
+	if err != nil {
+		return err
+	}
+	s.gotoLine(newLineOffset)
+	if !blankFinish {
+		s.parent.Append(s.unindentWarning("Explicit markup"))
+	}
+	return nil
+}
+
+// Anonymous hyperlink targets.
+func (s *body) anonymous(match *rst.Match, context []string, nextState string) ([]string, string, []string, error) {
+	nodelist, blankFinish, err := s.anonymousTarget(match)
+	if err != nil {
+		return fail(context, err)
+	}
+	s.parent.Extend(nodelist...)
+	if err := s.explicitList(blankFinish); err != nil {
+		return fail(context, err)
+	}
+	return nil, nextState, nil, nil
+}
+
+// Section title overline or transition marker.
+func (s *body) line(match *rst.Match, context []string, nextState string) ([]string, string, []string, error) {
+	if s.sm.matchTitles {
+		return []string{match.String}, "Line", nil, nil
+	} else if strings.TrimSpace(match.String) == "::" {
+		return fail(context, &rst.TransitionCorrection{Transition: "text"})
+	} else if len(strings.TrimSpace(match.String)) < 4 {
+		msg := s.reporter.Info("Unexpected possible title overline or transition.\n"+
+			"Treating it as ordinary text because it's so short.", "", s.sm.AbsLineNumber())
+		s.parent.Append(msg)
+		return fail(context, &rst.TransitionCorrection{Transition: "text"})
+	}
+	blocktext := s.sm.Line
+	msg := s.reporter.Severe("Unexpected section title or transition.", "", s.sm.AbsLineNumber(),
+		newElement("literal_block", blocktext, blocktext))
+	s.parent.Append(msg)
+	return nil, nextState, nil, nil
+}
+
+// Titles, definition lists, paragraphs.
+func (s *body) text(match *rst.Match, context []string, nextState string) ([]string, string, []string, error) {
+	return []string{match.String}, "Text", nil, nil
+}
+
+/*
+   Superclass for second and subsequent compound element members. Compound
+   elements are lists and list-like constructs.
+
+   All transition methods are disabled (redefined as `invalidInput`).
+   Override individual methods in subclasses to re-enable.
+
+   For example, once an initial bullet list item, say, is recognized, the
+   `bulletList` subclass takes over, with a "bullet_list" node as its
+   container. Upon encountering the initial bullet list item, `body.bullet`
+   calls its `nestedListParse`, which starts up a nested parsing session
+   with `bulletList` as the initial state. Only the ``bullet`` transition
+   method is enabled in `bulletList`; as long as only bullet list items are
+   encountered, they are parsed and inserted into the container. The first
+   construct which is *not* a bullet list item triggers the `invalidInput`
+   method, which ends the nested parse and closes the container.
+   `bulletList` needs to recognize input that is invalid in the context of
+   a bullet list, which means everything *other than* bullet list items, so
+   it inherits the transition list created in `body`.
+*/
+type specializedBody struct {
+	body
+}
+
+/*
+   Set up the specialized state `name`: all transitions are invalid input
+   but `methods`, and blank lines if `blankInvalid` is false.
+*/
+func (s *specializedBody) initSpecialized(sm *stateMachine, name string, methods map[string]rst.TransitionMethod, blankInvalid bool) {
+	all := make(map[string]rst.TransitionMethod)
+	for _, transition := range bodyTransitions {
+		all[transition] = s.invalidInput
+	}
+	for transition, method := range methods {
+		all[transition] = method
+	}
+	blank := s.Nop
+	if blankInvalid {
+		blank = s.invalidInput
+	}
+	s.init(sm, name, bodyTransitions, all, blank, s.invalidInput)
+}
+
+// Not a compound element member. Abort this state machine.
+func (s *specializedBody) invalidInput(match *rst.Match, context []string, nextState string) ([]string, string, []string, error) {
+	s.sm.PreviousLine(1) // back up so parent SM can reassess
+	return fail(context, rst.ErrEOF)
+}
+
+// Second and subsequent bullet_list list_items.
+type bulletList struct {
+	specializedBody
+}
+
+func newBulletList(sm *stateMachine) *bulletList {
+	s := &bulletList{}
+	s.initSpecialized(sm, "BulletList", map[string]rst.TransitionMethod{"bullet": s.bullet}, false)
+	return s
+}
+
+// Bullet list item.
+func (s *bulletList) bullet(match *rst.Match, context []string, nextState string) ([]string, string, []string, error) {
+	if bullet, _ := utf8.DecodeRuneInString(match.String); string(bullet) != s.parent.Get("bullet") {
+		// different bullet: new list
+		return s.invalidInput(match, context, nextState)
+	}
+	item, blankFinish, err := s.listItem(matchEnd(match))
+	if err != nil {
+		return fail(context, err)
+	}
+	s.parent.Append(item)
+	s.blankFinish = blankFinish
+	return nil, nextState, nil, nil
+}
+
+// Second and subsequent definition_list_items.
+type definitionList struct {
+	specializedBody
+}
+
+func newDefinitionList(sm *stateMachine) *definitionList {
+	s := &definitionList{}
+	s.initSpecialized(sm, "DefinitionList", map[string]rst.TransitionMethod{"text": s.text}, false)
+	return s
+}
+
+// Definition lists.
+func (s *definitionList) text(match *rst.Match, context []string, nextState string) ([]string, string, []string, error) {
+	return []string{match.String}, "Definition", nil, nil
+}
+
+// Second and subsequent enumerated_list list_items.
+type enumeratedList struct {
+	specializedBody
+	lastordinal int
+	format      string
+	auto        bool
+}
+
+func newEnumeratedList(sm *stateMachine) *enumeratedList {
+	s := &enumeratedList{}
+	s.initSpecialized(sm, "EnumeratedList", map[string]rst.TransitionMethod{"enumerator": s.enumerator}, false)
+	return s
+}
+
+// Enumerated list item.
+func (s *enumeratedList) enumerator(match *rst.Match, context []string, nextState string) ([]string, string, []string, error) {
+	format, sequence, _, ordinal := s.parseEnumerator(match, s.parent.Get("enumtype"))
+	if format != s.format ||
+		sequence != "#" && (sequence != s.parent.Get("enumtype") || s.auto || ordinal < 0 || ordinal != s.lastordinal+1) ||
+		!s.isEnumeratedListItem(ordinal, sequence, format) {
+		// different enumeration: new list
+		return s.invalidInput(match, context, nextState)
+	}
+	if sequence == "#" {
+		s.auto = true
+	}
+	item, blankFinish, err := s.listItem(matchEnd(match))
+	if err != nil {
+		return fail(context, err)
+	}
+	s.parent.Append(item)
+	s.blankFinish = blankFinish
+	s.lastordinal = ordinal
+	return nil, nextState, nil, nil
+}
+
+// Second and subsequent field_list fields.
+type fieldList struct {
+	specializedBody
+}
+
+func newFieldList(sm *stateMachine) *fieldList {
+	s := &fieldList{}
+	s.initSpecialized(sm, "FieldList", map[string]rst.TransitionMethod{"field_marker": s.fieldMarker}, false)
+	return s
+}
+
+// Field list field.
+func (s *fieldList) fieldMarker(match *rst.Match, context []string, nextState string) ([]string, string, []string, error) {
+	field, blankFinish, err := s.field(match)
+	if err != nil {
+		return fail(context, err)
+	}
+	s.parent.Append(field)
+	s.blankFinish = blankFinish
+	return nil, nextState, nil, nil
+}
+
+// Second and subsequent option_list option_list_items.
+type optionList struct {
+	specializedBody
+}
+
+func newOptionList(sm *stateMachine) *optionList {
+	s := &optionList{}
+	s.initSpecialized(sm, "OptionList", map[string]rst.TransitionMethod{"option_marker": s.optionMarker}, false)
+	return s
+}
+
+// Option list item.
+func (s *optionList) optionMarker(match *rst.Match, context []string, nextState string) ([]string, string, []string, error) {
+	item, blankFinish, err := s.optionListItem(match)
+	if _, ok := err.(*markupError); ok {
+		return s.invalidInput(match, context, nextState)
+	} else if err != nil {
+		return fail(context, err)
+	}
+	s.parent.Append(item)
+	s.blankFinish = blankFinish
+	return nil, nextState, nil, nil
+}
+
+// Second and subsequent lines of a line_block.
+type lineBlock struct {
+	specializedBody
+}
+
+func newLineBlock(sm *stateMachine) *lineBlock {
+	s := &lineBlock{}
+	s.initSpecialized(sm, "LineBlock", map[string]rst.TransitionMethod{"line_block": s.lineBlock}, true)
+	return s
+}
+
+// New line of line block.
+func (s *lineBlock) lineBlock(match *rst.Match, context []string, nextState string) ([]string, string, []string, error) {
+	lineno := s.sm.AbsLineNumber()
+	line, messages, blankFinish := s.lineBlockLine(match, lineno)
+	s.parent.Append(line)
+	s.parent.Parent().Extend(messages...)
+	s.blankFinish = blankFinish
+	return nil, nextState, nil, nil
+}
+
+// Second and subsequent explicit markup construct.
+type explicit struct {
+	specializedBody
+}
+
+func newExplicit(sm *stateMachine) *explicit {
+	s := &explicit{}
+	s.initSpecialized(sm, "Explicit", map[string]rst.TransitionMethod{
+		"explicit_markup": s.explicitMarkup,
+		"anonymous":       s.anonymous,
+	}, true)
+	return s
+}
+
+// Footnotes, hyperlink targets, directives, comments.
+func (s *explicit) explicitMarkup(match *rst.Match, context []string, nextState string) ([]string, string, []string, error) {
+	nodelist, blankFinish, err := s.explicitConstruct(match)
+	if err != nil {
+		return fail(context, err)
+	}
+	s.parent.Extend(nodelist...)
+	s.blankFinish = blankFinish
+	return nil, nextState, nil, nil
+}
+
+// Anonymous hyperlink targets.
+func (s *explicit) anonymous(match *rst.Match, context []string, nextState string) ([]string, string, []string, error) {
+	nodelist, blankFinish, err := s.anonymousTarget(match)
+	if err != nil {
+		return fail(context, err)
+	}
+	s.parent.Extend(nodelist...)
+	s.blankFinish = blankFinish
+	return nil, nextState, nil, nil
+}
+
+// Parser for the contents of a substitution_definition element.
+type substitutionDef struct {
+	body
+}
+
+func newSubstitutionDef(sm *stateMachine) *substitutionDef {
+	s := &substitutionDef{}
+	s.init(sm, "SubstitutionDef", []string{"embedded_directive", "text"}, map[string]rst.TransitionMethod{
+		"embedded_directive": s.embeddedDirective,
+		"text":               s.text,
+	}, s.Nop, s.indent)
+	return s
+}
+
+func (s *substitutionDef) embeddedDirective(match *rst.Match, context []string, nextState string) ([]string, string, []string, error) {
+	nodelist, blankFinish, err := s.directive(match)
+	if err != nil {
+		return fail(context, err)
+	}
+	s.parent.Extend(nodelist...)
+	if !s.sm.AtEof() {
+		s.blankFinish = blankFinish
+	}
+	return fail(context, rst.ErrEOF)
+}
+
+func (s *substitutionDef) text(match *rst.Match, context []string, nextState string) ([]string, string, []string, error) {
+	if !s.sm.AtEof() {
+		s.blankFinish = s.sm.IsNextLineBlank()
+	}
+	return fail(context, rst.ErrEOF)
+}
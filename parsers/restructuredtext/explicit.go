@@ -0,0 +1,463 @@
+package restructuredtext
+
+import (
+	"regexp"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	rst "github.com/siongui/go-rst"
+	"github.com/siongui/go-rst/directives"
+)
+
+// An error in the markup of an explicit markup construct or an option
+// list.
+type markupError struct {
+	msg string
+}
+
+func (e *markupError) Error() string {
+	return e.msg
+}
+
+const (
+	emailc  = "[-_!~*'{|}/#?^`&=+$%a-zA-Z0-9\\x00]"
+	urilast = `[_~*/=+a-zA-Z0-9]`
+)
+
+var (
+	footnotePattern        = regexp.MustCompile(`^\.\.[ ]+\[([0-9]+|#|#` + simplename + `|\*)\]([ ]+|$)`)
+	citationPattern        = regexp.MustCompile(`^\.\.[ ]+\[(` + simplename + `)\]([ ]+|$)`)
+	hyperlinkTargetPattern = regexp.MustCompile(`^\.\.[ ]+_`)
+	substitutionDefPattern = regexp.MustCompile(`^\.\.[ ]+\|`)
+	directivePattern       = regexp.MustCompile(`^\.\.[ ]+(` + simplename + `)[ ]?::([ ]+|$)`)
+
+	referencePattern = regexp.MustCompile(`^(?:(?P<simple>` + simplename + `)_|` + "`" + `(?P<phrase>[^ ].*)` + "`_)$")
+	emailPattern     = regexp.MustCompile(`^` + emailc + `+(?:\.` + emailc + `+)*@` + emailc + `+(?:\.` + emailc + `*)*` + urilast + `$`)
+)
+
+// An explicit markup construct: its pattern and the method parsing it.
+type explicitConstruct struct {
+	pattern *regexp.Regexp
+	method  func(s *body, match *rst.Match) ([]rst.Node, bool, error)
+}
+
+/*
+   The explicit markup constructs, in the order they are tried. The
+   constructs start with ".." and are followed by an indented block; a
+   line starting with ".." matching none of them is a comment.
+*/
+var explicitConstructs []explicitConstruct
+
+func init() {
+	// Set at run time: the methods refer to the table through the nested
+	// state machines.
+	explicitConstructs = []explicitConstruct{
+		{footnotePattern, (*body).footnote},
+		{citationPattern, (*body).citation},
+		{hyperlinkTargetPattern, (*body).hyperlinkTarget},
+		{substitutionDefPattern, (*body).substitutionDef},
+		{directivePattern, (*body).directive},
+	}
+}
+
+// Determine which explicit construct this is, parse & return it.
+func (s *body) explicitConstruct(match *rst.Match) ([]rst.Node, bool, error) {
+	var errors []rst.Node
+	for _, construct := range explicitConstructs {
+		expmatch := rst.MatchPattern(construct.pattern, match.String)
+		if expmatch == nil {
+			continue
+		}
+		nodelist, blankFinish, err := construct.method(s, expmatch)
+		if e, ok := err.(*markupError); ok {
+			errors = append(errors, s.reporter.Warning(e.msg, "", s.sm.AbsLineNumber()))
+			break
+		}
+		return nodelist, blankFinish, err
+	}
+	nodelist, blankFinish := s.comment(match)
+	return append(nodelist, errors...), blankFinish, nil
+}
+
+func (s *body) footnote(match *rst.Match) ([]rst.Node, bool, error) {
+	source, line := s.sm.GetSourceAndLine(0)
+	indented, _, offset, blankFinish := s.sm.GetFirstKnownIndented(matchEnd(match), false, true, true)
+	label := match.Group(1)
+	name := rst.FullyNormalizeName(label)
+	footnote := newElement("footnote", joinLines(indented), "")
+	footnote.SetSource(source)
+	footnote.SetLine(line)
+	if name[0] == '#' { // auto-numbered
+		name = name[1:] // autonumber label
+		footnote.Set("auto", "1")
+		if name != "" {
+			footnote.Names = append(footnote.Names, name)
+		}
+		s.document.NoteAutofootnote(footnote)
+	} else if name == "*" { // auto-symbol
+		name = ""
+		footnote.Set("auto", "*")
+		s.document.NoteSymbolFootnote(footnote)
+	} else { // manually numbered
+		footnote.Append(newElement("label", "", label))
+		footnote.Names = append(footnote.Names, name)
+		s.document.NoteFootnote(footnote)
+	}
+	if name != "" {
+		s.document.NoteExplicitTarget(footnote, footnote)
+	} else {
+		s.document.SetID(footnote, footnote)
+	}
+	if indented.Length() > 0 {
+		if _, err := s.nestedParse(indented, offset, footnote, false); err != nil {
+			return nil, false, err
+		}
+	}
+	return []rst.Node{footnote}, blankFinish, nil
+}
+
+func (s *body) citation(match *rst.Match) ([]rst.Node, bool, error) {
+	source, line := s.sm.GetSourceAndLine(0)
+	indented, _, offset, blankFinish := s.sm.GetFirstKnownIndented(matchEnd(match), false, true, true)
+	label := match.Group(1)
+	name := rst.FullyNormalizeName(label)
+	citation := newElement("citation", joinLines(indented), "")
+	citation.SetSource(source)
+	citation.SetLine(line)
+	citation.Append(newElement("label", "", label))
+	citation.Names = append(citation.Names, name)
+	s.document.NoteCitation(citation)
+	s.document.NoteExplicitTarget(citation, citation)
+	if indented.Length() > 0 {
+		if _, err := s.nestedParse(indented, offset, citation, false); err != nil {
+			return nil, false, err
+		}
+	}
+	return []rst.Node{citation}, blankFinish, nil
+}
+
+func (s *body) hyperlinkTarget(match *rst.Match) ([]rst.Node, bool, error) {
+	lineno := s.sm.AbsLineNumber()
+	block, _, _, blankFinish := s.sm.GetFirstKnownIndented(matchEnd(match), true, false, true)
+	blocktext := match.String[:match.End(0)] + joinLines(block)
+	lines := make([]string, block.Length())
+	for i, line := range block.Lines() {
+		lines[i] = escape2null(line)
+	}
+	if len(lines) == 0 {
+		return nil, false, &markupError{"malformed hyperlink target."}
+	}
+	escaped := lines[0]
+	blockindex := 0
+	var end int
+	var name string
+	var ok bool
+	for {
+		if end, name, ok = matchTargetName(escaped); ok {
+			break
+		}
+		blockindex++
+		if blockindex == len(lines) {
+			return nil, false, &markupError{"malformed hyperlink target."}
+		}
+		escaped += lines[blockindex]
+	}
+	lines = lines[blockindex:]
+	first := lines[0] + " "
+	lines[0] = strings.TrimSpace(first[len(first)-1-(len(escaped)-end):])
+	target := s.makeTarget(lines, blocktext, lineno, name)
+	return []rst.Node{target}, blankFinish, nil
+}
+
+/*
+   Match the name of a hyperlink target at the beginning of `escaped` (the
+   text following ".. _", escaped with `escape2null()`): "_" for an
+   anonymous target, or a reference name, optionally between backquotes,
+   followed by ":" and whitespace. Return the end of the match, the name
+   ("" for an anonymous target), and whether it matched.
+*/
+func matchTargetName(escaped string) (int, string, bool) {
+	// Match `[ ]?:([ ]+|$)` at `i`.
+	colon := func(i int) (int, bool) {
+		if i < len(escaped) && escaped[i] == ' ' {
+			i++
+		}
+		if i == len(escaped) || escaped[i] != ':' {
+			return 0, false
+		}
+		i++
+		if i < len(escaped) && escaped[i] != ' ' {
+			return 0, false
+		}
+		for i < len(escaped) && escaped[i] == ' ' {
+			i++
+		}
+		return i, true
+	}
+	if strings.HasPrefix(escaped, "_") { // anonymous target
+		end, ok := colon(1)
+		return end, "", ok
+	}
+	start := 0
+	quoted := strings.HasPrefix(escaped, "`")
+	if quoted {
+		start = 1
+	}
+	if start == len(escaped) || escaped[start] == ' ' || escaped[start] == '`' {
+		return 0, "", false
+	}
+	for j := start + 1; j <= len(escaped); j++ {
+		if j < len(escaped) && !utf8.RuneStart(escaped[j]) {
+			continue
+		}
+		if isWhitespaceOrNull(lastRune(escaped[:j])) {
+			continue
+		}
+		k := j
+		if quoted {
+			if k == len(escaped) || escaped[k] != '`' {
+				continue
+			}
+			k++
+		}
+		// no unescaped colon at end
+		if escaped[k-1] == ':' && (k < 2 || escaped[k-2] != 0) {
+			continue
+		}
+		if end, ok := colon(k); ok {
+			return end, escaped[start:j], true
+		}
+	}
+	return 0, "", false
+}
+
+func isWhitespaceOrNull(r rune) bool {
+	return r == 0 || unicode.IsSpace(r)
+}
+
+func (s *body) makeTarget(block []string, blockText string, lineno int, targetName string) *rst.Element {
+	targetType, data := s.parseTarget(block)
+	if targetType == "refname" {
+		target := newElement("target", blockText, "")
+		target.Set("refname", rst.FullyNormalizeName(data))
+		s.addTarget(targetName, "", target, lineno)
+		s.document.NoteIndirectTarget(target)
+		return target
+	}
+	// data is a reference URI
+	target := newElement("target", blockText, "")
+	s.addTarget(targetName, data, target, lineno)
+	return target
+}
+
+/*
+   Determine the type of reference of a target. Return "refname" and the
+   indirect reference name, or "refuri" and the URI.
+*/
+func (s *body) parseTarget(block []string) (string, string) {
+	if len(block) > 0 && strings.HasSuffix(strings.TrimSpace(block[len(block)-1]), "_") {
+		// possible indirect target
+		stripped := make([]string, len(block))
+		for i, line := range block {
+			stripped[i] = strings.TrimSpace(line)
+		}
+		if refname := isReference(strings.Join(stripped, " ")); refname != "" {
+			return "refname", refname
+		}
+	}
+	var parts []string
+	for _, part := range splitEscapedWhitespace(strings.Join(block, " ")) {
+		parts = append(parts, strings.Join(strings.Fields(unescape(part, false)), ""))
+	}
+	return "refuri", strings.Join(parts, " ")
+}
+
+func isReference(reference string) string {
+	match := rst.MatchPattern(referencePattern, rst.WhitespaceNormalizeName(reference))
+	if match == nil {
+		return ""
+	}
+	if simple := match.Named("simple"); simple != "" {
+		return unescape(simple, false)
+	}
+	phrase := match.Named("phrase")
+	if isWhitespaceOrNull(lastRune(phrase)) {
+		return ""
+	}
+	return unescape(phrase, false)
+}
+
+func (s *body) addTarget(targetName, refuri string, target *rst.Element, lineno int) {
+	target.SetLine(lineno)
+	if targetName != "" {
+		name := rst.FullyNormalizeName(unescape(targetName, false))
+		target.Names = append(target.Names, name)
+		if refuri != "" {
+			target.Set("refuri", adjustURI(refuri))
+		}
+		s.document.NoteExplicitTarget(target, s.parent)
+	} else { // anonymous target
+		if refuri != "" {
+			target.Set("refuri", refuri)
+		}
+		target.Set("anonymous", "1")
+		s.document.NoteAnonymousTarget(target)
+	}
+}
+
+// Return `uri` with "mailto:" prepended if it is an email address.
+func adjustURI(uri string) string {
+	if at := strings.IndexByte(uri, '@'); at > 0 && uri[at-1] != 0 && emailPattern.MatchString(uri) {
+		return "mailto:" + uri
+	}
+	return uri
+}
+
+func (s *body) substitutionDef(match *rst.Match) ([]rst.Node, bool, error) {
+	source, line := s.sm.GetSourceAndLine(0)
+	block, _, offset, blankFinish := s.sm.GetFirstKnownIndented(matchEnd(match), false, false, true)
+	blocktext := match.String[:match.End(0)] + joinLines(block)
+	block.Disconnect(0)
+	if block.Length() == 0 {
+		return nil, false, &markupError{"malformed substitution definition."}
+	}
+	lines := block.Lines()
+	escaped := escape2null(strings.TrimRightFunc(lines[0], unicode.IsSpace))
+	blockindex := 0
+	var end int
+	var subname string
+	var ok bool
+	for {
+		if end, subname, ok = matchSubstitutionName(escaped); ok {
+			break
+		}
+		blockindex++
+		if blockindex == len(lines) {
+			return nil, false, &markupError{"malformed substitution definition."}
+		}
+		escaped += " " + escape2null(strings.TrimSpace(lines[blockindex]))
+	}
+	block.TrimStart(blockindex) // strip out the substitution marker
+	first := strings.TrimSpace(block.Lines()[0]) + " "
+	block.SetItem(0, first[len(first)-1-(len(escaped)-end):len(first)-1])
+	if block.Lines()[0] == "" {
+		block.TrimStart(1)
+		offset++
+	}
+	for block.Length() > 0 && strings.TrimSpace(block.Lines()[block.Length()-1]) == "" {
+		block.TrimEnd(1)
+	}
+	substitutionNode := newElement("substitution_definition", blocktext, "")
+	substitutionNode.SetSource(source)
+	substitutionNode.SetLine(line)
+	if block.Length() == 0 {
+		msg := s.reporter.Warning("Substitution definition \""+subname+"\" missing contents.", source, line,
+			newElement("literal_block", blocktext, blocktext))
+		return []rst.Node{msg}, blankFinish, nil
+	}
+	block.SetItem(0, strings.TrimSpace(block.Lines()[0]))
+	substitutionNode.Names = append(substitutionNode.Names, rst.WhitespaceNormalizeName(subname))
+	_, blankFinish, err := s.nestedListParse(block, offset, substitutionNode, "SubstitutionDef", blankFinish, "", nil, false)
+	if err != nil {
+		return nil, false, err
+	}
+	var inline []rst.Node
+	for _, node := range substitutionNode.Children() {
+		if e, ok := node.(*rst.Element); ok && !e.Is(rst.Inline) {
+			s.parent.Append(node)
+		} else {
+			inline = append(inline, node)
+		}
+	}
+	substitutionNode.SetChildren(inline)
+	for _, node := range substitutionNode.Traverse(nil) {
+		if e, ok := node.(*rst.Element); ok && disallowedInsideSubstitutionDefinitions(e) {
+			pformat := newElement("literal_block", "", strings.TrimRightFunc(e.Pformat("    ", 0), unicode.IsSpace))
+			msg := s.reporter.Error("Substitution definition contains illegal element <"+e.TagName()+">:", source, line,
+				pformat, newElement("literal_block", blocktext, blocktext))
+			return []rst.Node{msg}, blankFinish, nil
+		}
+	}
+	if substitutionNode.Len() == 0 {
+		msg := s.reporter.Warning("Substitution definition \""+subname+"\" empty or invalid.", source, line,
+			newElement("literal_block", blocktext, blocktext))
+		return []rst.Node{msg}, blankFinish, nil
+	}
+	s.document.NoteSubstitutionDef(substitutionNode, subname, s.parent)
+	return []rst.Node{substitutionNode}, blankFinish, nil
+}
+
+/*
+   Match the name of a substitution definition at the beginning of
+   `escaped` (the text following ".. |"): a name not starting with a space
+   nor ending with whitespace, "|", then whitespace. Return the end of the
+   match, the name, and whether it matched.
+*/
+func matchSubstitutionName(escaped string) (int, string, bool) {
+	if escaped == "" || escaped[0] == ' ' {
+		return 0, "", false
+	}
+	for j := 1; j < len(escaped); j++ {
+		if escaped[j] != '|' || isWhitespaceOrNull(lastRune(escaped[:j])) {
+			continue
+		}
+		end := j + 1
+		if end < len(escaped) && escaped[end] != ' ' {
+			continue
+		}
+		for end < len(escaped) && escaped[end] == ' ' {
+			end++
+		}
+		return end, escaped[:j], true
+	}
+	return 0, "", false
+}
+
+func disallowedInsideSubstitutionDefinitions(node *rst.Element) bool {
+	return len(node.Ids) > 0 ||
+		node.TagName() == "reference" && node.HasAttr("anonymous") ||
+		node.TagName() == "footnote_reference" && node.HasAttr("auto")
+}
+
+/*
+   Run the directive of the explicit markup construct `match` with package
+   directives.
+*/
+func (s *body) directive(match *rst.Match) ([]rst.Node, bool, error) {
+	typeName := match.Group(1)
+	lineno := s.sm.AbsLineNumber()
+	initialLineOffset := s.sm.LineOffset()
+	indented, _, _, blankFinish := s.sm.GetFirstKnownIndented(matchEnd(match), false, true, false)
+	inputLines := s.sm.InputLines()
+	blockText := strings.Join(inputLines.Lines()[initialLineOffset:s.sm.LineOffset()+1], "\n")
+	result := directives.RunWithState(&s.rstState, s.document, s.parent, typeName, indented.Lines(), lineno, blockText)
+	return result, blankFinish || s.sm.IsNextLineBlank(), nil
+}
+
+func (s *body) comment(match *rst.Match) ([]rst.Node, bool) {
+	if s.sm.IsNextLineBlank() {
+		firstCommentLine := match.String[match.End(0):]
+		if strings.TrimSpace(firstCommentLine) == "" { // empty comment
+			return []rst.Node{newElement("comment", "", "")}, true // "A tiny but practical wart."
+		}
+	}
+	indented, _, _, blankFinish := s.sm.GetFirstKnownIndented(matchEnd(match), false, true, true)
+	for indented.Length() > 0 && strings.TrimSpace(indented.Lines()[indented.Length()-1]) == "" {
+		indented.TrimEnd(1)
+	}
+	text := joinLines(indented)
+	return []rst.Node{newElement("comment", text, text)}, blankFinish
+}
+
+func (s *body) anonymousTarget(match *rst.Match) ([]rst.Node, bool, error) {
+	lineno := s.sm.AbsLineNumber()
+	block, _, _, blankFinish := s.sm.GetFirstKnownIndented(matchEnd(match), true, true, true)
+	blocktext := match.String[:match.End(0)] + joinLines(block)
+	lines := make([]string, block.Length())
+	for i, line := range block.Lines() {
+		lines[i] = escape2null(line)
+	}
+	target := s.makeTarget(lines, blocktext, lineno, "")
+	return []rst.Node{target}, blankFinish, nil
+}
@@ -0,0 +1,948 @@
+package restructuredtext
+
+import (
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	rst "github.com/siongui/go-rst"
+	"github.com/siongui/go-rst/roles"
+)
+
+/*
+   Parse inline markup; call the `parse()` method.
+
+   The inline markup recognition rules are implemented by scanning the
+   text instead of by regular expressions (Go regular expressions have no
+   lookahead or lookbehind assertions): a start-string must be preceded by
+   the start of the text, whitespace, an opening bracket or quote, or a
+   delimiter, and an end-string must be followed by the end of the text,
+   whitespace, an escape, a closing bracket or quote, or a delimiter or
+   punctuation.
+*/
+type Inliner struct {
+	document *rst.Document
+	reporter *rst.Reporter
+	parent   *rst.Element
+}
+
+// A match of the start of an inline markup construct.
+type inlineMatch struct {
+	// The searched string.
+	string string
+
+	// The dispatch key: the start-string, the backquote, the reference
+	// end-string or the footnote reference end-string.
+	key string
+
+	// The start and end of the whole match.
+	start, end int
+
+	// Interpreted text: the start of the backquote and the prefix role
+	// (without colons).
+	backquote int
+	role      string
+
+	// Reference: the reference name; footnote reference: the label and
+	// whether it is a citation label.
+	refname       string
+	footnoteLabel string
+	citation      bool
+}
+
+/*
+   Return a list of nodes for the inline markup of `text` and a list of
+   system messages; `parent` is the element the nodes will be added to.
+*/
+func (i *Inliner) parse(text string, lineno int, memo *memo, parent *rst.Element) ([]rst.Node, []rst.Node) {
+	i.document = memo.document
+	i.reporter = memo.reporter
+	i.parent = parent
+	remaining := escape2null(text)
+	var processed, messages []rst.Node
+	var unprocessed []string
+	for remaining != "" {
+		match := searchInitial(remaining)
+		if match == nil {
+			break
+		}
+		var before, after string
+		var inlines, sysmessages []rst.Node
+		switch match.key {
+		case "*":
+			before, inlines, after, sysmessages, _ = i.inlineObj(match, lineno, "emphasis", false)
+		case "**":
+			before, inlines, after, sysmessages, _ = i.inlineObj(match, lineno, "strong", false)
+		case "`":
+			before, inlines, after, sysmessages = i.interpretedOrPhraseRef(match, lineno)
+		case "``":
+			before, inlines, after, sysmessages, _ = i.inlineObj(match, lineno, "literal", true)
+		case "_`":
+			before, inlines, after, sysmessages = i.inlineInternalTarget(match, lineno)
+		case "]_":
+			before, inlines, after, sysmessages = i.footnoteReference(match)
+		case "|":
+			before, inlines, after, sysmessages = i.substitutionReference(match, lineno)
+		case "_":
+			before, inlines, after, sysmessages = i.reference(match, false)
+		case "__":
+			before, inlines, after, sysmessages = i.reference(match, true)
+		}
+		remaining = after
+		unprocessed = append(unprocessed, before)
+		messages = append(messages, sysmessages...)
+		if len(inlines) > 0 {
+			processed = append(processed, i.implicitInline(strings.Join(unprocessed, ""))...)
+			processed = append(processed, inlines...)
+			unprocessed = nil
+		}
+	}
+	remaining = strings.Join(unprocessed, "") + remaining
+	if remaining != "" {
+		processed = append(processed, i.implicitInline(remaining)...)
+	}
+	return processed, messages
+}
+
+func (i *Inliner) problematic(text, rawsource string, message *rst.Element) *rst.Element {
+	inliner := &roles.Inliner{}
+	inliner.Init(i.document, i.parent)
+	return inliner.Problematic(text, rawsource, message)
+}
+
+/*
+   Return true if the start-string of `match` is quoted: enclosed in a
+   matching pair of brackets or quotes, or at the end of the text.
+*/
+func quotedStart(match *inlineMatch) bool {
+	if match.start == 0 { // start-string at beginning of text
+		return false
+	}
+	if match.end == len(match.string) { // start-string at end of text
+		return true // not "quoted" but no markup start-string either
+	}
+	prestart := lastRune(match.string[:match.start])
+	poststart, _ := firstRune(match.string[match.end:])
+	return matchChars(prestart, poststart)
+}
+
+/*
+   Parse the inline markup started by `match` and ended by its end-string
+   into an element `tagname`. Return the text before the construct, the
+   nodes, the remaining text, the system messages and the end-string.
+*/
+func (i *Inliner) inlineObj(match *inlineMatch, lineno int, tagname string, restoreBackslashes bool) (string, []rst.Node, string, []rst.Node, string) {
+	str := match.string
+	matchstart := match.start
+	matchend := match.end
+	if quotedStart(match) {
+		return str[:matchend], nil, str[matchend:], nil, ""
+	}
+	endstart, endend := searchEnd(str[matchend:], tagname)
+	if endstart > 0 { // 1 or more chars
+		text := str[matchend : matchend+endstart]
+		if restoreBackslashes {
+			text = unescape(text, true)
+		} else {
+			text = unescape(text, false)
+		}
+		textend := matchend + endend
+		rawsource := unescape(str[matchstart:textend], true)
+		node := newElement(tagname, rawsource, text)
+		return str[:matchstart], []rst.Node{node}, str[textend:], nil, str[matchend+endstart : textend]
+	}
+	msg := i.reporter.Warning("Inline "+tagname+" start-string without end-string.", "", lineno)
+	text := unescape(str[matchstart:matchend], true)
+	prb := i.problematic(text, text, msg)
+	return str[:matchstart], []rst.Node{prb}, str[matchend:], []rst.Node{msg}, ""
+}
+
+func (i *Inliner) interpretedOrPhraseRef(match *inlineMatch, lineno int) (string, []rst.Node, string, []rst.Node) {
+	str := match.string
+	matchstart := match.backquote
+	matchend := match.end
+	rolestart := match.start
+	role := match.role
+	position := ""
+	if role != "" {
+		position = "prefix"
+	} else if quotedStart(match) {
+		return str[:matchend], nil, str[matchend:], nil
+	}
+	if end := searchInterpretedEnd(str[matchend:]); end != nil && end.start > 0 { // 1 or more chars
+		textend := matchend + end.end
+		if end.role != "" {
+			if role != "" {
+				msg := i.reporter.Warning("Multiple roles in interpreted text (both prefix and suffix present; only one allowed).", "", lineno)
+				text := unescape(str[rolestart:textend], true)
+				prb := i.problematic(text, text, msg)
+				return str[:rolestart], []rst.Node{prb}, str[textend:], []rst.Node{msg}
+			}
+			role = end.role
+			position = "suffix"
+		}
+		escaped := str[matchend : matchend+end.start]
+		rawsource := unescape(str[matchstart:textend], true)
+		if strings.HasSuffix(rawsource, "_") {
+			if role != "" {
+				msg := i.reporter.Warning("Mismatch: both interpreted text role "+position+" and reference suffix.", "", lineno)
+				text := unescape(str[rolestart:textend], true)
+				prb := i.problematic(text, text, msg)
+				return str[:rolestart], []rst.Node{prb}, str[textend:], []rst.Node{msg}
+			}
+			return i.phraseRef(str[:matchstart], str[textend:], rawsource, escaped)
+		}
+		rawsource = unescape(str[rolestart:textend], true)
+		inliner := &roles.Inliner{}
+		inliner.Init(i.document, i.parent)
+		nodelist, messages := inliner.Interpreted(rawsource, unescape(escaped, false), role, lineno)
+		return str[:rolestart], nodelist, str[textend:], messages
+	}
+	msg := i.reporter.Warning("Inline interpreted text or phrase reference start-string without end-string.", "", lineno)
+	text := unescape(str[matchstart:matchend], true)
+	prb := i.problematic(text, text, msg)
+	return str[:matchstart], []rst.Node{prb}, str[matchend:], []rst.Node{msg}
+}
+
+func (i *Inliner) phraseRef(before, after, rawsource, escaped string) (string, []rst.Node, string, []rst.Node) {
+	var text, rawtext, alias, aliastype string
+	var target *rst.Element
+	if start, open := searchEmbeddedLink(escaped); start != -1 { // embedded <URI> or <alias_>
+		text = unescape(escaped[:start], false)
+		rawtext = unescape(escaped[:start], true)
+		content := escaped[open+1 : len(escaped)-1]
+		aliastext := unescape(content, true)
+		underscoreEscaped := strings.HasSuffix(aliastext, `\_`)
+		if _, ok := matchURI(aliastext, 0); strings.HasSuffix(aliastext, "_") && !underscoreEscaped && !ok {
+			aliastype = "name"
+			alias = rst.FullyNormalizeName(aliastext[:len(aliastext)-1])
+			target = newElement("target", unescape(escaped[start:], true), "")
+			target.Set("refname", alias)
+		} else {
+			aliastype = "uri"
+			var parts []string
+			for _, part := range splitEscapedWhitespace(content) {
+				parts = append(parts, strings.Join(strings.Fields(unescape(part, false)), ""))
+			}
+			alias = adjustURI(strings.Join(parts, " "))
+			if strings.HasSuffix(alias, `\_`) {
+				alias = alias[:len(alias)-2] + "_"
+			}
+			target = newElement("target", unescape(escaped[start:], true), "")
+			target.Set("refuri", alias)
+			target.Referenced = true
+		}
+		if text == "" {
+			text = alias
+			rawtext = rawsource
+		}
+	} else {
+		text = unescape(escaped, false)
+		rawtext = unescape(escaped, true)
+	}
+
+	refname := rst.FullyNormalizeName(text)
+	reference := newElement("reference", rawsource, "", newTextNode(text, rawtext))
+	reference.Set("name", rst.WhitespaceNormalizeName(text))
+	nodeList := []rst.Node{reference}
+
+	if strings.HasSuffix(rawsource, "__") {
+		switch {
+		case target != nil && aliastype == "name":
+			reference.Set("refname", alias)
+			i.document.NoteRefname(reference)
+		case target != nil && aliastype == "uri":
+			reference.Set("refuri", alias)
+		default:
+			reference.Set("anonymous", "1")
+		}
+	} else {
+		if target != nil {
+			target.Names = append(target.Names, refname)
+			if aliastype == "name" {
+				reference.Set("refname", alias)
+				i.document.NoteIndirectTarget(target)
+				i.document.NoteRefname(reference)
+			} else {
+				reference.Set("refuri", alias)
+				i.document.NoteExplicitTarget(target, i.parent)
+			}
+			nodeList = append(nodeList, target)
+		} else {
+			reference.Set("refname", refname)
+			i.document.NoteRefname(reference)
+		}
+	}
+	return before, nodeList, after, nil
+}
+
+func (i *Inliner) inlineInternalTarget(match *inlineMatch, lineno int) (string, []rst.Node, string, []rst.Node) {
+	before, inlines, remaining, sysmessages, _ := i.inlineObj(match, lineno, "target", false)
+	if len(inlines) > 0 {
+		if target := inlines[0].(*rst.Element); target.TagName() == "target" {
+			name := rst.FullyNormalizeName(target.AsText())
+			target.Names = append(target.Names, name)
+			i.document.NoteExplicitTarget(target, i.parent)
+		}
+	}
+	return before, inlines, remaining, sysmessages
+}
+
+func (i *Inliner) substitutionReference(match *inlineMatch, lineno int) (string, []rst.Node, string, []rst.Node) {
+	before, inlines, remaining, sysmessages, endstring := i.inlineObj(match, lineno, "substitution_reference", false)
+	if len(inlines) == 1 {
+		if subrefNode := inlines[0].(*rst.Element); subrefNode.TagName() == "substitution_reference" {
+			subrefText := subrefNode.AsText()
+			i.document.NoteSubstitutionRef(subrefNode, subrefText)
+			if strings.HasSuffix(endstring, "_") {
+				referenceNode := newElement("reference", "|"+subrefText+endstring, "")
+				if strings.HasSuffix(endstring, "__") {
+					referenceNode.Set("anonymous", "1")
+				} else {
+					referenceNode.Set("refname", rst.FullyNormalizeName(subrefText))
+					i.document.NoteRefname(referenceNode)
+				}
+				referenceNode.Append(subrefNode)
+				inlines = []rst.Node{referenceNode}
+			}
+		}
+	}
+	return before, inlines, remaining, sysmessages
+}
+
+func (i *Inliner) footnoteReference(match *inlineMatch) (string, []rst.Node, string, []rst.Node) {
+	label := match.footnoteLabel
+	refname := rst.FullyNormalizeName(label)
+	str := match.string
+	before := str[:match.start]
+	remaining := str[match.end:]
+	var refnode *rst.Element
+	if match.citation {
+		refnode = newElement("citation_reference", "["+label+"]_", label)
+		refnode.Set("refname", refname)
+		i.document.NoteCitationRef(refnode)
+	} else {
+		refnode = newElement("footnote_reference", "["+label+"]_", "")
+		switch {
+		case strings.HasPrefix(refname, "#"):
+			refname = refname[1:]
+			refnode.Set("auto", "1")
+			i.document.NoteAutofootnoteRef(refnode)
+		case refname == "*":
+			label = ""
+			refname = ""
+			refnode.Set("auto", "*")
+			i.document.NoteSymbolFootnoteRef(refnode)
+		default:
+			refnode.Append(newTextNode(label, ""))
+		}
+		if refname != "" {
+			refnode.Set("refname", refname)
+			i.document.NoteFootnoteRef(refnode)
+		}
+		if i.document.Settings().FootnoteReferences == "superscript" {
+			before = strings.TrimRightFunc(before, unicode.IsSpace)
+		}
+	}
+	return before, []rst.Node{refnode}, remaining, nil
+}
+
+func (i *Inliner) reference(match *inlineMatch, anonymous bool) (string, []rst.Node, string, []rst.Node) {
+	referencename := match.refname
+	refname := rst.FullyNormalizeName(referencename)
+	referencenode := newElement("reference", referencename+match.key, "", newTextNode(referencename, referencename))
+	referencenode.Set("name", rst.WhitespaceNormalizeName(referencename))
+	if anonymous {
+		referencenode.Set("anonymous", "1")
+	} else {
+		referencenode.Set("refname", refname)
+		i.document.NoteRefname(referencenode)
+	}
+	str := match.string
+	return str[:match.start], []rst.Node{referencenode}, str[match.end:], nil
+}
+
+/*
+   Return a list of text nodes and reference elements for `text`: the
+   standalone URIs and email addresses are recognized.
+*/
+func (i *Inliner) implicitInline(text string) []rst.Node {
+	if text == "" {
+		return nil
+	}
+	if start, end, scheme, email := searchURI(text); start != -1 {
+		if scheme == "" || uriSchemes[strings.ToLower(scheme)] {
+			// Must recurse on strings before *and* after the match.
+			nodes := i.implicitInline(text[:start])
+			nodes = append(nodes, standaloneURI(text[start:end], email))
+			return append(nodes, i.implicitInline(text[end:])...)
+		}
+	}
+	return []rst.Node{newTextNode(unescape(text, false), unescape(text, true))}
+}
+
+func standaloneURI(text string, email bool) *rst.Element {
+	addscheme := ""
+	if email {
+		addscheme = "mailto:"
+	}
+	unescaped := unescape(text, false)
+	rawsource := unescape(text, true)
+	reference := newElement("reference", rawsource, "", newTextNode(unescaped, rawsource))
+	reference.Set("refuri", addscheme+unescaped)
+	return reference
+}
+
+/*
+   Search `text` for the start of an inline markup construct: a simple
+   start-string, a whole reference or footnote reference, or the start of
+   interpreted text (with an optional role). Return nil if there is none.
+*/
+func searchInitial(text string) *inlineMatch {
+	for pos := range text {
+		if !isStartStringPrefix(text, pos) {
+			continue
+		}
+		if m := matchStartString(text, pos); m != nil {
+			return m
+		}
+		if m := matchWhole(text, pos); m != nil {
+			return m
+		}
+		if m := matchBackquote(text, pos); m != nil {
+			return m
+		}
+	}
+	return nil
+}
+
+func matchStartString(text string, pos int) *inlineMatch {
+	rest := text[pos:]
+	var start string
+	switch {
+	case strings.HasPrefix(rest, "**"):
+		start = "**"
+	case strings.HasPrefix(rest, "*"):
+		start = "*"
+	case strings.HasPrefix(rest, "``"):
+		start = "``"
+	case strings.HasPrefix(rest, "_`"):
+		start = "_`"
+	case strings.HasPrefix(rest, "|") && !strings.HasPrefix(rest, "||"):
+		start = "|"
+	default:
+		return nil
+	}
+	end := pos + len(start)
+	if !isNonWhitespaceAfter(text, end) {
+		return nil
+	}
+	return &inlineMatch{string: text, key: start, start: pos, end: end}
+}
+
+func matchWhole(text string, pos int) *inlineMatch {
+	// reference name & end-string
+	for _, snend := range simplenameEnds(text, pos) {
+		for _, refend := range []string{"__", "_"} {
+			if strings.HasPrefix(text[snend:], refend) && isEndStringSuffix(text, snend+len(refend)) {
+				return &inlineMatch{string: text, key: refend, start: pos, end: snend + len(refend), refname: text[pos:snend]}
+			}
+		}
+	}
+	// footnote label
+	if !strings.HasPrefix(text[pos:], "[") {
+		return nil
+	}
+	labelstart := pos + 1
+	m := &inlineMatch{string: text, key: "]_", start: pos}
+	fnend := func(labelend int) bool {
+		if strings.HasPrefix(text[labelend:], "]_") && isEndStringSuffix(text, labelend+2) {
+			m.end = labelend + 2
+			m.footnoteLabel = text[labelstart:labelend]
+			return true
+		}
+		return false
+	}
+	// manually numbered
+	digits := labelstart
+	for digits < len(text) && text[digits] >= '0' && text[digits] <= '9' {
+		digits++
+	}
+	if digits > labelstart && fnend(digits) {
+		return m
+	}
+	switch {
+	case strings.HasPrefix(text[labelstart:], "#"): // auto-numbered (w/ label?)
+		for _, snend := range simplenameEnds(text, labelstart+1) {
+			if fnend(snend) {
+				return m
+			}
+		}
+		if fnend(labelstart + 1) {
+			return m
+		}
+	case strings.HasPrefix(text[labelstart:], "*"): // auto-symbol
+		if fnend(labelstart + 1) {
+			return m
+		}
+	}
+	// citation reference
+	for _, snend := range simplenameEnds(text, labelstart) {
+		if fnend(snend) {
+			m.citation = true
+			return m
+		}
+	}
+	return nil
+}
+
+func matchBackquote(text string, pos int) *inlineMatch {
+	backquote := func(bqstart int, role string) *inlineMatch {
+		if !strings.HasPrefix(text[bqstart:], "`") || strings.HasPrefix(text[bqstart:], "``") {
+			return nil
+		}
+		if !isNonWhitespaceAfter(text, bqstart+1) {
+			return nil
+		}
+		return &inlineMatch{string: text, key: "`", start: pos, end: bqstart + 1, backquote: bqstart, role: role}
+	}
+	// optional role
+	if strings.HasPrefix(text[pos:], ":") {
+		for _, snend := range simplenameEnds(text, pos+1) {
+			if strings.HasPrefix(text[snend:], ":") {
+				if m := backquote(snend+1, text[pos+1:snend]); m != nil {
+					return m
+				}
+			}
+		}
+	}
+	return backquote(pos, "")
+}
+
+/*
+   Return the possible ends of a simple reference name starting at `pos`
+   in `text`, longest first.
+*/
+func simplenameEnds(text string, pos int) []int {
+	var ends []int
+	i := pos
+	for {
+		j := i
+		for j < len(text) {
+			r, size := utf8.DecodeRuneInString(text[j:])
+			if !isSimplenameChar(r) {
+				break
+			}
+			j += size
+		}
+		if j == i {
+			break
+		}
+		ends = append(ends, j)
+		if j+1 < len(text) && strings.IndexByte("-._+:", text[j]) != -1 {
+			if r, _ := utf8.DecodeRuneInString(text[j+1:]); isSimplenameChar(r) {
+				i = j + 1
+				continue
+			}
+		}
+		break
+	}
+	for l, r := 0, len(ends)-1; l < r; l, r = l+1, r-1 {
+		ends[l], ends[r] = ends[r], ends[l]
+	}
+	return ends
+}
+
+func isSimplenameChar(r rune) bool {
+	return unicode.IsLetter(r) || unicode.IsNumber(r)
+}
+
+/*
+   Search the end-string of the inline markup `tagname` in `text`. Return
+   the start and end of the end-string; the start is -1 if there is none.
+*/
+func searchEnd(text, tagname string) (int, int) {
+	for pos := range text {
+		if pos > 0 {
+			prev := text[pos-1]
+			if prev == ' ' || prev == '\n' || (prev == 0 && tagname != "literal") {
+				continue
+			}
+		}
+		var ends []int
+		switch tagname {
+		case "emphasis":
+			if strings.HasPrefix(text[pos:], "*") {
+				ends = []int{pos + 1}
+			}
+		case "strong":
+			if strings.HasPrefix(text[pos:], "**") {
+				ends = []int{pos + 2}
+			}
+		case "literal":
+			if strings.HasPrefix(text[pos:], "``") {
+				ends = []int{pos + 2}
+			}
+		case "target":
+			if strings.HasPrefix(text[pos:], "`") {
+				ends = []int{pos + 1}
+			}
+		case "substitution_reference":
+			if strings.HasPrefix(text[pos:], "|") {
+				end := pos + 1
+				for end < len(text) && end < pos+3 && text[end] == '_' {
+					end++
+				}
+				for ; end > pos; end-- {
+					ends = append(ends, end)
+				}
+			}
+		}
+		for _, end := range ends {
+			if isEndStringSuffix(text, end) {
+				return pos, end
+			}
+		}
+	}
+	return -1, -1
+}
+
+// A match of the end of interpreted text or a phrase reference.
+type interpretedEnd struct {
+	start, end int
+	role       string
+}
+
+/*
+   Search the end of interpreted text or a phrase reference in `text`:
+   the backquote, followed by an optional role or reference end-string.
+*/
+func searchInterpretedEnd(text string) *interpretedEnd {
+	for pos := range text {
+		if text[pos] != '`' {
+			continue
+		}
+		if pos > 0 {
+			prev := text[pos-1]
+			if (prev == ' ' || prev == '\n' || prev == 0) && (pos < 2 || text[pos-2] != 0) {
+				continue
+			}
+		}
+		suffix := func(start int, role string) *interpretedEnd {
+			for _, refend := range []string{"__", "_", ""} {
+				if strings.HasPrefix(text[start:], refend) && isEndStringSuffix(text, start+len(refend)) {
+					return &interpretedEnd{pos, start + len(refend), role}
+				}
+			}
+			return nil
+		}
+		if strings.HasPrefix(text[pos+1:], ":") {
+			for _, snend := range simplenameEnds(text, pos+2) {
+				if strings.HasPrefix(text[snend:], ":") {
+					if m := suffix(snend+1, text[pos+2:snend]); m != nil {
+						return m
+					}
+				}
+			}
+		}
+		if m := suffix(pos+1, ""); m != nil {
+			return m
+		}
+	}
+	return nil
+}
+
+/*
+   Search an embedded URI or alias (`<...>`, preceded by whitespace or at
+   the start) at the end of `text`. Return the start of the match
+   (including the preceding whitespace) and the position of "<"; the start
+   is -1 if there is none.
+*/
+func searchEmbeddedLink(text string) (int, int) {
+	if len(text) < 3 || text[len(text)-1] != '>' {
+		return -1, -1
+	}
+	if c := text[len(text)-2]; c == ' ' || c == '\n' || c == 0 {
+		return -1, -1
+	}
+	open := -1
+	for pos := len(text) - 2; pos >= 0; pos-- {
+		if c := text[pos]; c == '<' || c == '>' {
+			if pos > 0 && text[pos-1] == 0 {
+				pos--
+				continue
+			}
+			if c == '>' {
+				return -1, -1
+			}
+			open = pos
+			break
+		}
+	}
+	if open == -1 || open+1 == len(text)-1 {
+		return -1, -1
+	}
+	if c := text[open+1]; c == ' ' || c == '\n' {
+		return -1, -1
+	}
+	start := open
+	for start > 0 && (text[start-1] == ' ' || text[start-1] == '\n') {
+		start--
+	}
+	if start == open && open > 0 {
+		return -1, -1
+	}
+	return start, open
+}
+
+/*
+   Search a standalone URI or email address in `text`. Return its start
+   and end, the URI scheme, and whether it is an email address; the start
+   is -1 if there is none.
+*/
+func searchURI(text string) (int, int, string, bool) {
+	for pos := range text {
+		if !isStartStringPrefix(text, pos) {
+			continue
+		}
+		if end, scheme := matchAbsoluteURI(text, pos); end != -1 {
+			return pos, end, scheme, false
+		}
+		if end := matchEmail(text, pos); end != -1 {
+			return pos, end, "", true
+		}
+	}
+	return -1, -1, "", false
+}
+
+// Return the end of the URI or email address at the start of `text`.
+func matchURI(text string, pos int) (int, bool) {
+	if end, _ := matchAbsoluteURI(text, pos); end != -1 {
+		return end, true
+	}
+	if end := matchEmail(text, pos); end != -1 {
+		return end, true
+	}
+	return -1, false
+}
+
+const (
+	uricChars    = "-_.!~*'()[];/:@&=+$,%\x00"
+	urilastChars = "_~*/=+"
+	emailcChars  = "-_!~*'{|}/#?^`&=+$%\x00"
+)
+
+func isASCIIAlnum(c byte) bool {
+	return c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9'
+}
+
+func isUric(c byte) bool {
+	return isASCIIAlnum(c) || strings.IndexByte(uricChars, c) != -1
+}
+
+func isUrilast(c byte) bool {
+	return isASCIIAlnum(c) || strings.IndexByte(urilastChars, c) != -1
+}
+
+func isEmailc(c byte) bool {
+	return isASCIIAlnum(c) || strings.IndexByte(emailcChars, c) != -1
+}
+
+/*
+   Return the possible ends of URI characters ending with a valid last
+   character (or followed by ">"), starting at `pos` in `text`, longest
+   first.
+*/
+func uriEnds(text string, pos int) []int {
+	run := pos
+	for run < len(text) && isUric(text[run]) {
+		run++
+	}
+	var ends []int
+	for end := run; end > pos; end-- {
+		if isUrilast(text[end-1]) || (end < len(text) && text[end] == '>') {
+			ends = append(ends, end)
+		}
+	}
+	return ends
+}
+
+/*
+   Match an absolute URI (scheme, hierarchical part, optional query and
+   fragment) at `pos` in `text`. Return its end (-1 if there is none) and
+   the scheme.
+*/
+func matchAbsoluteURI(text string, pos int) (int, string) {
+	if pos >= len(text) || !(text[pos] >= 'a' && text[pos] <= 'z' || text[pos] >= 'A' && text[pos] <= 'Z') {
+		return -1, ""
+	}
+	colon := pos + 1
+	for colon < len(text) && (isASCIIAlnum(text[colon]) || strings.IndexByte(".+-", text[colon]) != -1) {
+		colon++
+	}
+	if colon >= len(text) || text[colon] != ':' {
+		return -1, ""
+	}
+	// optional part starting with `sep`, or none
+	optional := func(end int, sep byte) []int {
+		var ends []int
+		if end < len(text) && text[end] == sep {
+			ends = uriEnds(text, end+1)
+		}
+		return append(ends, end)
+	}
+	for _, hier := range uriEnds(text, colon+1) {
+		for _, query := range optional(hier, '?') {
+			for _, fragment := range optional(query, '#') {
+				if isEndStringSuffix(text, fragment) {
+					return fragment, text[pos:colon]
+				}
+			}
+		}
+	}
+	return -1, ""
+}
+
+// Match an email address at `pos` in `text`; return its end or -1.
+func matchEmail(text string, pos int) int {
+	at := pos
+	for {
+		end := at
+		for end < len(text) && isEmailc(text[end]) {
+			end++
+		}
+		if end == at {
+			return -1
+		}
+		at = end
+		if at+1 < len(text) && text[at] == '.' && isEmailc(text[at+1]) {
+			at++
+			continue
+		}
+		break
+	}
+	if at >= len(text) || text[at] != '@' || text[at-1] == 0 {
+		return -1
+	}
+	hoststart := at + 1
+	if hoststart >= len(text) || !isEmailc(text[hoststart]) {
+		return -1
+	}
+	hostend := hoststart
+	for hostend < len(text) && (isEmailc(text[hostend]) || text[hostend] == '.') {
+		hostend++
+	}
+	for end := hostend; end > hoststart; end-- {
+		if end >= len(text) {
+			continue
+		}
+		c := text[end]
+		if isUrilast(c) || (isUric(c) && end+1 < len(text) && text[end+1] == '>') {
+			if isEndStringSuffix(text, end+1) {
+				return end + 1
+			}
+		}
+	}
+	return -1
+}
+
+/*
+   Return true if an inline markup start-string may start at `pos` in
+   `text`: at the start of the text, or after whitespace, an opening
+   bracket or quote, or a delimiter.
+*/
+func isStartStringPrefix(text string, pos int) bool {
+	if pos == 0 {
+		return true
+	}
+	r := lastRune(text[:pos])
+	return unicode.IsSpace(r) || isOpener(r) || isDelimiter(r)
+}
+
+/*
+   Return true if an inline markup end-string may end at `pos` in `text`:
+   at the end of the text, or before whitespace, an escape, punctuation,
+   a delimiter, or a closing bracket or quote.
+*/
+func isEndStringSuffix(text string, pos int) bool {
+	if pos == len(text) {
+		return true
+	}
+	r, _ := firstRune(text[pos:])
+	return unicode.IsSpace(r) || r == 0 || strings.ContainsRune(`\.,;!?`, r) || isDelimiter(r) || isCloser(r)
+}
+
+// Return true if `pos` is the end of `text` or not followed by a space or
+// a newline.
+func isNonWhitespaceAfter(text string, pos int) bool {
+	return pos == len(text) || (text[pos] != ' ' && text[pos] != '\n')
+}
+
+func isOpener(r rune) bool {
+	if r < utf8.RuneSelf {
+		return strings.ContainsRune(`"'(<[{`, r)
+	}
+	return unicode.In(r, unicode.Ps, unicode.Pi, unicode.Pf)
+}
+
+func isCloser(r rune) bool {
+	if r < utf8.RuneSelf {
+		return strings.ContainsRune(`"')>]}`, r)
+	}
+	return unicode.In(r, unicode.Pe, unicode.Pi, unicode.Pf)
+}
+
+func isDelimiter(r rune) bool {
+	if r < utf8.RuneSelf {
+		return strings.ContainsRune(`-/:`, r)
+	}
+	return unicode.In(r, unicode.Pd, unicode.Po)
+}
+
+// The closing characters matching the opening brackets and quotes.
+var matchingClosers = map[rune]string{
+	'"': `"`, '\'': `'`, '(': ")", '<': ">", '[': "]", '{': "}",
+	'«': "»", '»': "«»", '‹': "›", '›': "‹›",
+	'‘': "’‚", '’': "‘’", '‚': "‘’", '“': "”„", '”': "“”", '„': "“”",
+	'⁅': "⁆", '〈': "〉", '⟨': "⟩", '〈': "〉", '《': "》", '「': "」",
+	'『': "』", '【': "】", '〔': "〕", '（': "）", '［': "］", '｛': "｝",
+}
+
+/*
+   Return true if `c1` and `c2` are a matching pair of opening and closing
+   brackets or quotes.
+*/
+func matchChars(c1, c2 rune) bool {
+	closers, ok := matchingClosers[c1]
+	return ok && strings.ContainsRune(closers, c2)
+}
+
+// The known URI schemes: a standalone URI with another scheme is text.
+var uriSchemes = map[string]bool{
+	"about": true, "acap": true, "addbook": true, "afp": true, "afs": true,
+	"aim": true, "callto": true, "castanet": true, "chttp": true,
+	"cid": true, "crid": true, "data": true, "dav": true, "dict": true,
+	"dns": true, "eid": true, "fax": true, "feed": true, "file": true,
+	"finger": true, "freenet": true, "ftp": true, "go": true,
+	"gopher": true, "gsm-sms": true, "h323": true, "h324": true,
+	"hdl": true, "hnews": true, "http": true, "https": true,
+	"hydra": true, "iioploc": true, "ilu": true, "im": true, "imap": true,
+	"info": true, "ior": true, "ipp": true, "irc": true, "iris.beep": true,
+	"iseek": true, "jar": true, "javascript": true, "jdbc": true,
+	"ldap": true, "lifn": true, "livescript": true, "lrq": true,
+	"mailbox": true, "mailserver": true, "mailto": true, "md5": true,
+	"mid": true, "mocha": true, "modem": true, "mtqp": true,
+	"mupdate": true, "news": true, "nfs": true, "nntp": true,
+	"opaquelocktoken": true, "phone": true, "pop": true, "pop3": true,
+	"pres": true, "printer": true, "prospero": true, "rdar": true,
+	"res": true, "rtsp": true, "rvp": true, "rwhois": true, "rx": true,
+	"sdp": true, "service": true, "shttp": true, "sip": true, "sips": true,
+	"smb": true, "snews": true, "snmp": true, "soap.beep": true,
+	"soap.beeps": true, "ssh": true, "t120": true, "tag": true, "tcp": true,
+	"tel": true, "telephone": true, "telnet": true, "tftp": true,
+	"tip": true, "tn3270": true, "tv": true, "urn": true, "uuid": true,
+	"vemmi": true, "videotex": true, "view-source": true, "wais": true,
+	"whodp": true, "whois++": true, "x-man-page": true,
+	"xmlrpc.beep": true, "xmlrpc.beeps": true, "z39.50r": true,
+	"z39.50s": true,
+}
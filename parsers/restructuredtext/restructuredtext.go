@@ -0,0 +1,514 @@
+/*
+Package restructuredtext implements the reStructuredText parser of Python
+docutils.
+
+The parser is a state machine (see `rst.StateMachine`): the `Body` state
+recognizes the body elements (lists, tables, explicit markup, ...) and
+hands over to specialized states for the items following the first one of
+a construct; the `Text` and `Line` states recognize paragraphs, definition
+lists and section titles. Directives are run with package directives,
+interpreted text roles with package roles, and the inline markup is parsed
+by `Inliner`.
+
+URL of Python source code:
+http://sourceforge.net/p/docutils/code/HEAD/tree/trunk/docutils/docutils/parsers/rst/__init__.py
+http://sourceforge.net/p/docutils/code/HEAD/tree/trunk/docutils/docutils/parsers/rst/states.py
+*/
+package restructuredtext
+
+import (
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	rst "github.com/siongui/go-rst"
+	"github.com/siongui/go-rst/directives"
+	"github.com/siongui/go-rst/parsers"
+	_ "github.com/siongui/go-rst/roles"
+)
+
+// The reStructuredText parser.
+type Parser struct {
+	parsers.Base
+}
+
+func (p *Parser) Supports(format string) bool {
+	switch format {
+	case "restructuredtext", "rst", "rest", "restx", "rtxt", "rstx":
+		return true
+	}
+	return false
+}
+
+// Parse `inputstring` and populate `document`, a document tree.
+func (p *Parser) Parse(inputstring string, document *rst.Document) error {
+	settings := document.Settings()
+	lines := rst.String2Lines(inputstring, settings.TabWidth)
+	inputLines := rst.NewStringList(lines, document.Get("source"), 0)
+	memo := &memo{
+		document:    document,
+		reporter:    document.Reporter(),
+		inliner:     &Inliner{},
+		lineIndents: make(map[*rst.Element]int),
+	}
+	sm := newStateMachine("Body", settings.Debug)
+	err := sm.run(inputLines, 0, memo, &document.Element, true)
+	sm.Unlink()
+	return err
+}
+
+/*
+   Return a new document for the source `sourcePath` with the settings
+   `settings` (the defaults if nil), parsed from the reStructuredText
+   string `inputstring`.
+*/
+func ParseDocument(inputstring, sourcePath string, settings *rst.Settings) (*rst.Document, error) {
+	document := rst.NewDocument(sourcePath, settings)
+	if err := (&Parser{}).Parse(inputstring, document); err != nil {
+		return nil, err
+	}
+	return document, nil
+}
+
+/*
+   Data shared by the state machines of one parse: the document, the
+   section title styles and the current section level.
+*/
+type memo struct {
+	document *rst.Document
+	reporter *rst.Reporter
+
+	// The adornment styles of the section titles, in order of levels:
+	// the underline character, or the overline and underline characters.
+	titleStyles []string
+
+	sectionLevel          int
+	sectionBubbleUpKludge bool
+	inliner               *Inliner
+
+	// The indents of the `line` elements of the line blocks being parsed.
+	lineIndents map[*rst.Element]int
+}
+
+/*
+   The reStructuredText state machine, for the whole document or for a
+   nested construct: the states of this package, the shared `memo`, and
+   the element to which the parsed elements are added.
+*/
+type stateMachine struct {
+	rst.StateMachine
+	memo        *memo
+	node        *rst.Element
+	matchTitles bool
+}
+
+// Return a state machine holding all states, starting in `initialState`.
+func newStateMachine(initialState string, debug bool) *stateMachine {
+	sm := &stateMachine{}
+	sm.Init([]rst.State{
+		newBody(sm),
+		newBulletList(sm),
+		newDefinitionList(sm),
+		newEnumeratedList(sm),
+		newFieldList(sm),
+		newOptionList(sm),
+		newLineBlock(sm),
+		newExplicit(sm),
+		newSubstitutionDef(sm),
+		newText(sm),
+		newDefinition(sm),
+		newLine(sm),
+		newQuotedLiteralBlock(sm),
+	}, initialState, debug)
+	return sm
+}
+
+/*
+   Parse `inputLines` (at the offset `inputOffset` from the beginning of
+   the source) into `node`; section titles are recognized if
+   `matchTitles` is true.
+*/
+func (sm *stateMachine) run(inputLines rst.StringList, inputOffset int, memo *memo, node *rst.Element, matchTitles bool) error {
+	sm.memo = memo
+	sm.node = node
+	sm.matchTitles = matchTitles
+	_, err := sm.Run(inputLines, inputOffset, nil, "")
+	return err
+}
+
+/*
+   The base of the reStructuredText states: the data of the parse, and the
+   methods common to all states.
+*/
+type rstState struct {
+	rst.StateWS
+	sm       *stateMachine
+	memo     *memo
+	document *rst.Document
+	reporter *rst.Reporter
+	inliner  *Inliner
+
+	// The element to which the parsed elements are added.
+	parent *rst.Element
+
+	// Specialized states only: did the last construct end with a blank
+	// line?
+	blankFinish bool
+}
+
+// The states of this package, all embedding `rstState`.
+type rstStater interface {
+	rst.State
+	rstBase() *rstState
+}
+
+func (s *rstState) rstBase() *rstState {
+	return s
+}
+
+/*
+   Set up the state `name` of `sm`: add the whitespace transitions with
+   the methods `blank` and `indent`, then the transitions `names` with the
+   methods `methods`.
+*/
+func (s *rstState) init(sm *stateMachine, name string, names []string, methods map[string]rst.TransitionMethod, blank, indent rst.TransitionMethod) {
+	s.Init(name)
+	s.sm = sm
+	transitions := make(map[string]rst.Transition)
+	for _, name := range names {
+		transitions[name] = rst.Transition{Pattern: patterns[name], Method: methods[name]}
+	}
+	s.AddTransitions(names, transitions)
+	s.AddWSTransitions(blank, indent)
+}
+
+func (s *rstState) RuntimeInit() {
+	s.memo = s.sm.memo
+	s.document = s.memo.document
+	s.reporter = s.memo.reporter
+	s.inliner = s.memo.inliner
+	s.parent = s.sm.node
+}
+
+// Jump to the absolute line offset `lineOffset`; ignore the end of input.
+func (s *rstState) gotoLine(lineOffset int) {
+	s.sm.GotoLine(lineOffset)
+}
+
+// Report a severe error: a line matches no transition pattern.
+func (s *rstState) NoMatch(context []string, transitions []string) ([]string, string, []string, error) {
+	s.reporter.Severe("Internal error: no transition pattern match.  State: \""+s.Name+
+		"\"; transitions: "+strings.Join(transitions, ", ")+"; current line: "+s.sm.Line+".", "", s.sm.AbsLineNumber())
+	return context, "", nil, nil
+}
+
+// Return a `Match` of the whole line `line`, for transition methods
+// called directly.
+func lineMatch(line string) *rst.Match {
+	return rst.MatchPattern(patterns["text"], line)
+}
+
+/*
+   Create a new state machine and run it on `block` (whose first line is
+   at the absolute offset `inputOffset`), adding the parsed elements to
+   `node`. Return the absolute offset of the line after the block.
+*/
+func (s *rstState) nestedParse(block rst.StringList, inputOffset int, node *rst.Element, matchTitles bool) (int, error) {
+	return s.nestedParseFrom("Body", block, inputOffset, node, matchTitles)
+}
+
+// Like `nestedParse()`, starting in the state `initialState`.
+func (s *rstState) nestedParseFrom(initialState string, block rst.StringList, inputOffset int, node *rst.Element, matchTitles bool) (int, error) {
+	sm := newStateMachine(initialState, s.Debug)
+	err := sm.run(block, inputOffset, s.memo, node, matchTitles)
+	sm.Unlink()
+	return sm.AbsLineOffset(), err
+}
+
+/*
+   Create a new state machine and run it on `block`, starting in the
+   specialized state `initialState` (the rest of a list, ...). The state
+   `blankFinishState` ("" for `initialState`) starts with and returns the
+   blank finish flag; `setup`, if not nil, is called with the new state
+   machine before the run. Return the absolute offset of the line after
+   the block and the blank finish flag.
+*/
+func (s *rstState) nestedListParse(block rst.StringList, inputOffset int, node *rst.Element, initialState string, blankFinish bool, blankFinishState string, setup func(*stateMachine), matchTitles bool) (int, bool, error) {
+	sm := newStateMachine(initialState, s.Debug)
+	if blankFinishState == "" {
+		blankFinishState = initialState
+	}
+	sm.State(blankFinishState).(rstStater).rstBase().blankFinish = blankFinish
+	if setup != nil {
+		setup(sm)
+	}
+	err := sm.run(block, inputOffset, s.memo, node, matchTitles)
+	blankFinish = sm.State(blankFinishState).(rstStater).rstBase().blankFinish
+	sm.Unlink()
+	return sm.AbsLineOffset(), blankFinish, err
+}
+
+// The rest of the input lines, from the line after the current one.
+func (s *rstState) nextLines() rst.StringList {
+	lines := s.sm.InputLines()
+	return lines.GetItemsSlice(s.sm.LineOffset()+1, lines.Length())
+}
+
+// Check for a valid subsection and create one if it checks out.
+func (s *rstState) section(title, source, style string, lineno int, messages []rst.Node) error {
+	ok, err := s.checkSubsection(source, style, lineno)
+	if !ok || err != nil {
+		return err
+	}
+	return s.newSubsection(title, lineno, messages)
+}
+
+/*
+   Check for a valid subsection header. Return true if it is a new
+   subsection of the current section, false if the title is inconsistent
+   (an error is added to the parent).
+
+   When a new section is reached that isn't a subsection of the current
+   section, back up the line count (use ``previous_line(-x)``), then
+   return `rst.ErrEOF`. The current StateMachine will finish, then the
+   calling StateMachine can re-examine the title. This will work its way
+   back up the calling chain until the correct section level is reached.
+*/
+func (s *rstState) checkSubsection(source, style string, lineno int) (bool, error) {
+	memo := s.memo
+	mylevel := memo.sectionLevel
+	level := 0
+	for i, titleStyle := range memo.titleStyles {
+		if titleStyle == style {
+			level = i + 1
+			break
+		}
+	}
+	if level == 0 { // new title style
+		if len(memo.titleStyles) == memo.sectionLevel { // new subsection
+			memo.titleStyles = append(memo.titleStyles, style)
+			return true, nil
+		}
+		// not at lowest level
+		s.parent.Append(s.titleInconsistent(source, lineno))
+		return false, nil
+	}
+	if level <= mylevel { // sibling or supersection
+		memo.sectionLevel = level // bubble up to parent section
+		if len(style) == 2 {
+			memo.sectionBubbleUpKludge = true
+		}
+		// back up 2 lines for underline title, 3 for overline title
+		s.sm.PreviousLine(len(style) + 1)
+		return false, rst.ErrEOF // let parent section re-evaluate
+	}
+	if level == mylevel+1 { // immediate subsection
+		return true, nil
+	}
+	// invalid subsection
+	s.parent.Append(s.titleInconsistent(source, lineno))
+	return false, nil
+}
+
+func (s *rstState) titleInconsistent(sourcetext string, lineno int) *rst.Element {
+	return s.reporter.Severe("Title level inconsistent:", "", lineno, newElement("literal_block", "", sourcetext))
+}
+
+// Append new subsection to document tree. On return, check level.
+func (s *rstState) newSubsection(title string, lineno int, messages []rst.Node) error {
+	memo := s.memo
+	mylevel := memo.sectionLevel
+	memo.sectionLevel++
+	sectionNode := newElement("section", "", "")
+	sectionNode.SetSource(s.document.Source())
+	sectionNode.SetLine(lineno)
+	s.parent.Append(sectionNode)
+	textnodes, titleMessages := s.inlineText(title, lineno)
+	titlenode := newElement("title", title, "", textnodes...)
+	name := rst.FullyNormalizeName(titlenode.AsText())
+	sectionNode.Names = append(sectionNode.Names, name)
+	sectionNode.Append(titlenode)
+	sectionNode.Extend(messages...)
+	sectionNode.Extend(titleMessages...)
+	s.document.NoteImplicitTarget(sectionNode, sectionNode)
+	absoffset := s.sm.AbsLineOffset() + 1
+	newabsoffset, err := s.nestedParse(s.nextLines(), absoffset, sectionNode, true)
+	if err != nil {
+		return err
+	}
+	s.gotoLine(newabsoffset)
+	if memo.sectionLevel <= mylevel { // can't handle next section?
+		return rst.ErrEOF // bubble up to supersection
+	}
+	// reset sectionLevel; next pass will detect it properly
+	memo.sectionLevel = mylevel
+	return nil
+}
+
+/*
+   Return a list (paragraph & messages) & a boolean: literal_block next?
+*/
+func (s *rstState) paragraph(lines []string, lineno int) ([]rst.Node, bool) {
+	data := strings.TrimRightFunc(strings.Join(lines, "\n"), unicode.IsSpace)
+	text := data
+	literalnext := false
+	if hasLiteralMarker(data) {
+		if len(data) == 2 {
+			return nil, true
+		} else if data[len(data)-3] == ' ' || data[len(data)-3] == '\n' {
+			text = strings.TrimRightFunc(data[:len(data)-3], unicode.IsSpace)
+		} else {
+			text = data[:len(data)-1]
+		}
+		literalnext = true
+	}
+	textnodes, messages := s.inlineText(text, lineno)
+	p := newElement("paragraph", data, "", textnodes...)
+	s.setSourceAndLine(p, lineno)
+	return append([]rst.Node{p}, messages...), literalnext
+}
+
+// Does `data` end with an unescaped "::"?
+func hasLiteralMarker(data string) bool {
+	if !strings.HasSuffix(data, "::") {
+		return false
+	}
+	backslashes := 0
+	for i := len(data) - 3; i >= 0 && data[i] == '\\'; i-- {
+		backslashes++
+	}
+	return backslashes%2 == 0
+}
+
+// Return a list of text nodes and a list of system messages.
+func (s *rstState) inlineText(text string, lineno int) ([]rst.Node, []rst.Node) {
+	return s.inliner.parse(text, lineno, s.memo, s.parent)
+}
+
+func (s *rstState) unindentWarning(nodeName string) *rst.Element {
+	// the actual problem is one line below the current line
+	lineno := s.sm.AbsLineNumber() + 1
+	return s.reporter.Warning(nodeName+" ends without a blank line; unexpected unindent.", "", lineno)
+}
+
+// Set the source and line of `node` from the absolute line number `lineno`
+// (0 for the current line).
+func (s *rstState) setSourceAndLine(node *rst.Element, lineno int) {
+	source, line := s.sm.GetSourceAndLine(lineno)
+	node.SetSource(source)
+	node.SetLine(line)
+}
+
+/*
+   Implement `directives.State`: parse the content of a directive into
+   `node`.
+*/
+func (s *rstState) NestedParse(block []string, inputOffset int, node *rst.Element, matchTitles bool) error {
+	source, _ := s.sm.GetSource(inputOffset)
+	if source == "" {
+		source = s.document.Source()
+	}
+	_, err := s.nestedParse(rst.NewStringList(block, source, inputOffset), inputOffset, node, matchTitles)
+	return err
+}
+
+// Implement `directives.State`: parse the inline markup of `text`.
+func (s *rstState) InlineText(text string, lineno int) ([]rst.Node, []rst.Node) {
+	return s.inlineText(text, lineno)
+}
+
+var _ directives.State = &rstState{}
+
+func newElement(tagname, rawsource, text string, children ...rst.Node) *rst.Element {
+	e := &rst.Element{}
+	e.Init(tagname, rawsource, text, children...)
+	return e
+}
+
+func newTextNode(data, rawsource string) *rst.Text {
+	t := &rst.Text{}
+	t.Init(data, rawsource)
+	return t
+}
+
+/*
+   Return a string with nulls removed or restored to backslashes.
+   Backslash-escaped spaces are also removed.
+*/
+func unescape(text string, restoreBackslashes bool) string {
+	if restoreBackslashes {
+		return strings.Replace(text, "\x00", "\\", -1)
+	}
+	for _, sep := range []string{"\x00 ", "\x00\n", "\x00"} {
+		text = strings.Replace(text, sep, "", -1)
+	}
+	return text
+}
+
+// Return a string with escape-backslashes converted to nulls.
+func escape2null(text string) string {
+	var b strings.Builder
+	for {
+		found := strings.IndexByte(text, '\\')
+		if found == -1 {
+			b.WriteString(text)
+			return b.String()
+		}
+		b.WriteString(text[:found])
+		b.WriteByte(0)
+		text = text[found+1:]
+		if text != "" {
+			// skip character after escape
+			_, size := firstRune(text)
+			b.WriteString(text[:size])
+			text = text[size:]
+		}
+	}
+}
+
+// Return the first character of `text` and its width in bytes.
+func firstRune(text string) (rune, int) {
+	return utf8.DecodeRuneInString(text)
+}
+
+// Return the last character of `text`, utf8.RuneError if it is empty.
+func lastRune(text string) rune {
+	r, _ := utf8.DecodeLastRuneInString(text)
+	return r
+}
+
+/*
+   Split `text` on escaped whitespace (null+space or null+newline). Return
+   a list of strings.
+*/
+func splitEscapedWhitespace(text string) []string {
+	var result []string
+	for _, part := range strings.Split(text, "\x00 ") {
+		result = append(result, strings.Split(part, "\x00\n")...)
+	}
+	return result
+}
+
+/*
+   Return the width of `text` in columns: East Asian wide and fullwidth
+   characters count twice, combining characters are not counted.
+*/
+func columnWidth(text string) int {
+	width := 0
+	for _, r := range text {
+		switch {
+		case unicode.Is(unicode.Mn, r):
+		case isWide(r):
+			width += 2
+		default:
+			width++
+		}
+	}
+	return width
+}
+
+// Is `r` an East Asian wide or fullwidth character?
+func isWide(r rune) bool {
+	return unicode.In(r, unicode.Han, unicode.Hiragana, unicode.Katakana, unicode.Hangul) ||
+		r >= 0x3000 && r <= 0x303f || r >= 0xff01 && r <= 0xff60 || r >= 0xffe0 && r <= 0xffe6
+}
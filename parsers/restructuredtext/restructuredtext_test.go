@@ -0,0 +1,743 @@
+package restructuredtext
+
+import (
+	"strings"
+	"testing"
+
+	rst "github.com/siongui/go-rst"
+	"github.com/siongui/go-rst/readers/standalone"
+	"github.com/siongui/go-rst/transforms"
+)
+
+func parse(t *testing.T, input string) *rst.Document {
+	settings := &rst.Settings{}
+	settings.Init()
+	settings.WarningStream = nil
+	document, err := ParseDocument(input, "test data", settings)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return document
+}
+
+// Apply the transforms of the standalone reader and of the parser.
+func applyTransforms(t *testing.T, document *rst.Document) {
+	transformer := &transforms.Transformer{}
+	transformer.Init(document)
+	transformer.PopulateFromComponents(&standalone.Reader{}, &Parser{})
+	if err := transformer.ApplyTransforms(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+var parserTests = []struct {
+	name     string
+	input    string
+	expected string
+}{
+	{
+		"inline markup",
+		"A paragraph with *emphasis*, **strong**, ``literal``, `title`,\n" +
+			":emphasis:`role`, `interpreted`:strong:, _`target`, a reference_,\n" +
+			"an `anonymous phrase`__, `embedded <http://example.org/>`_ and\n" +
+			"http://example.org/path, user@example.org.\n" +
+			"\n" +
+			"Escaped \\*stars\\* and quoted \"*\" stay text.\n",
+		`<document source="test data">
+    <paragraph>
+        A paragraph with 
+        <emphasis>
+            emphasis
+        , 
+        <strong>
+            strong
+        , 
+        <literal>
+            literal
+        , 
+        <title_reference>
+            title
+        ,
+        <emphasis>
+            role
+        , 
+        <strong>
+            interpreted
+        , 
+        <target ids="target" names="target">
+            target
+        , a 
+        <reference name="reference" refname="reference">
+            reference
+        ,
+        an 
+        <reference anonymous="1" name="anonymous phrase">
+            anonymous phrase
+        , 
+        <reference name="embedded" refuri="http://example.org/">
+            embedded
+        <target ids="embedded" names="embedded" refuri="http://example.org/">
+         and
+        <reference refuri="http://example.org/path">
+            http://example.org/path
+        , 
+        <reference refuri="mailto:user@example.org">
+            user@example.org
+        .
+    <paragraph>
+        Escaped *stars* and quoted "*" stay text.
+`,
+	},
+	{
+		"sections and transitions",
+		`=====
+Title
+=====
+
+Section
+-------
+
+Paragraph.
+
+----------
+
+Other
+-----
+
+Text.
+`,
+		`<document source="test data">
+    <section ids="title" names="title">
+        <title>
+            Title
+        <section ids="section" names="section">
+            <title>
+                Section
+            <paragraph>
+                Paragraph.
+            <transition>
+        <section ids="other" names="other">
+            <title>
+                Other
+            <paragraph>
+                Text.
+`,
+	},
+	{
+		"lists",
+		`- bullet
+
+  second paragraph
+- bullet 2
+
+#. auto
+#. enumerated
+
+term
+   definition
+term 2 : classifier
+   definition 2
+
+:field: body
+:other field: more
+   lines
+
+-a            short option
+--long=FILE   long option
+`,
+		`<document source="test data">
+    <bullet_list bullet="-">
+        <list_item>
+            <paragraph>
+                bullet
+            <paragraph>
+                second paragraph
+        <list_item>
+            <paragraph>
+                bullet 2
+    <enumerated_list enumtype="arabic" prefix="" suffix=".">
+        <list_item>
+            <paragraph>
+                auto
+        <list_item>
+            <paragraph>
+                enumerated
+    <definition_list>
+        <definition_list_item>
+            <term>
+                term
+            <definition>
+                <paragraph>
+                    definition
+        <definition_list_item>
+            <term>
+                term 2
+            <classifier>
+                classifier
+            <definition>
+                <paragraph>
+                    definition 2
+    <field_list>
+        <field>
+            <field_name>
+                field
+            <field_body>
+                <paragraph>
+                    body
+        <field>
+            <field_name>
+                other field
+            <field_body>
+                <paragraph>
+                    more
+                    lines
+    <option_list>
+        <option_list_item>
+            <option_group>
+                <option>
+                    <option_string>
+                        -a
+            <description>
+                <paragraph>
+                    short option
+        <option_list_item>
+            <option_group>
+                <option>
+                    <option_string>
+                        --long
+                    <option_argument delimiter="=">
+                        FILE
+            <description>
+                <paragraph>
+                    long option
+`,
+	},
+	{
+		"literal, doctest and line blocks, block quotes",
+		`Literal::
+
+    indented
+      literal
+
+>>> doctest
+output
+
+| line
+|    nested line
+
+    Block quote.
+
+    -- Attribution
+`,
+		`<document source="test data">
+    <paragraph>
+        Literal:
+    <literal_block>
+        indented
+          literal
+    <doctest_block>
+        >>> doctest
+        output
+    <line_block>
+        <line>
+            line
+        <line_block>
+            <line>
+                nested line
+    <block_quote>
+        <paragraph>
+            Block quote.
+        <attribution>
+            Attribution
+`,
+	},
+	{
+		"grid and simple tables",
+		`+------+-------+
+| head | cells |
++======+=======+
+| a    | b     |
++------+-------+
+
+=====  =====
+A      B
+=====  =====
+1      2
+=====  =====
+`,
+		`<document source="test data">
+    <table>
+        <tgroup cols="2">
+            <colspec colwidth="6">
+            <colspec colwidth="7">
+            <thead>
+                <row>
+                    <entry>
+                        <paragraph>
+                            head
+                    <entry>
+                        <paragraph>
+                            cells
+            <tbody>
+                <row>
+                    <entry>
+                        <paragraph>
+                            a
+                    <entry>
+                        <paragraph>
+                            b
+    <table>
+        <tgroup cols="2">
+            <colspec colwidth="5">
+            <colspec colwidth="5">
+            <thead>
+                <row>
+                    <entry>
+                        <paragraph>
+                            A
+                    <entry>
+                        <paragraph>
+                            B
+            <tbody>
+                <row>
+                    <entry>
+                        <paragraph>
+                            1
+                    <entry>
+                        <paragraph>
+                            2
+`,
+	},
+	{
+		"footnotes, citations and targets",
+		`Refer to [1]_, [#auto]_, [*]_ and [CIT2002]_; see target_.
+
+.. [1] Manual.
+.. [#auto] Auto-numbered.
+.. [*] Symbol.
+.. [CIT2002] Citation.
+.. _target: http://example.org/
+.. _alias: target_
+.. comment
+`,
+		`<document source="test data">
+    <paragraph>
+        Refer to 
+        <footnote_reference ids="id1" refname="1">
+            1
+        , 
+        <footnote_reference auto="1" ids="id2" refname="auto">
+        , 
+        <footnote_reference auto="*" ids="id3">
+         and 
+        <citation_reference ids="id4" refname="cit2002">
+            CIT2002
+        ; see 
+        <reference name="target" refname="target">
+            target
+        .
+    <footnote ids="id5" names="1">
+        <label>
+            1
+        <paragraph>
+            Manual.
+    <footnote auto="1" ids="auto" names="auto">
+        <paragraph>
+            Auto-numbered.
+    <footnote auto="*" ids="id6">
+        <paragraph>
+            Symbol.
+    <citation ids="cit2002" names="cit2002">
+        <label>
+            CIT2002
+        <paragraph>
+            Citation.
+    <target ids="target" names="target" refuri="http://example.org/">
+    <target ids="alias" names="alias" refname="target">
+    <comment>
+        comment
+`,
+	},
+	{
+		"errors",
+		"Unclosed *emphasis and :unknown:`role`.\n" +
+			"\n" +
+			".. unknown:: directive\n" +
+			"\n" +
+			"- list\n" +
+			"no blank line\n",
+		"<document source=\"test data\">\n" +
+			"    <paragraph>\n" +
+			"        Unclosed \n" +
+			"        <problematic ids=\"id2\" refid=\"id1\">\n" +
+			"            *\n" +
+			"        emphasis and \n" +
+			"        <problematic ids=\"id4\" refid=\"id3\">\n" +
+			"            :unknown:`role`\n" +
+			"        .\n" +
+			"    <system_message backrefs=\"id2\" ids=\"id1\" level=\"2\" line=\"1\" source=\"test data\" type=\"WARNING\">\n" +
+			"        <paragraph>\n" +
+			"            Inline emphasis start-string without end-string.\n" +
+			"    <system_message backrefs=\"id4\" ids=\"id3\" level=\"3\" line=\"1\" source=\"test data\" type=\"ERROR\">\n" +
+			"        <paragraph>\n" +
+			"            Unknown interpreted text role \"unknown\".\n" +
+			"    <system_message level=\"3\" line=\"3\" source=\"test data\" type=\"ERROR\">\n" +
+			"        <paragraph>\n" +
+			"            Unknown directive type \"unknown\".\n" +
+			"        <literal_block>\n" +
+			"            .. unknown:: directive\n" +
+			"    <bullet_list bullet=\"-\">\n" +
+			"        <list_item>\n" +
+			"            <paragraph>\n" +
+			"                list\n" +
+			"    <system_message level=\"2\" line=\"6\" source=\"test data\" type=\"WARNING\">\n" +
+			"        <paragraph>\n" +
+			"            Bullet list ends without a blank line; unexpected unindent.\n" +
+			"    <paragraph>\n" +
+			"        no blank line\n",
+	},
+}
+
+func TestParser(t *testing.T) {
+	for _, test := range parserTests {
+		if output := parse(t, test.input).Pformat("    ", 0); output != test.expected {
+			t.Errorf("%s failed:\n%s", test.name, output)
+		}
+	}
+}
+
+func TestContentsSectnum(t *testing.T) {
+	document := parse(t, `.. sectnum::
+
+.. contents:: Table of Contents
+   :depth: 1
+
+One
+===
+
+Sub One
+-------
+
+Two
+===
+
+See One_ and `+"`Sub One`"+`_.
+`)
+	applyTransforms(t, document)
+
+	expected := `<document source="test data">
+    <topic classes="contents" ids="table-of-contents" names="table\ of\ contents">
+        <title>
+            Table of Contents
+        <bullet_list classes="auto-toc">
+            <list_item>
+                <paragraph>
+                    <reference ids="id1" refid="one">
+                        <generated classes="sectnum">
+                            1&nbsp;&nbsp;&nbsp;
+                        One
+            <list_item>
+                <paragraph>
+                    <reference ids="id2" refid="two">
+                        <generated classes="sectnum">
+                            2&nbsp;&nbsp;&nbsp;
+                        Two
+    <section ids="one" names="one">
+        <title auto="1" refid="id1">
+            <generated classes="sectnum">
+                1&nbsp;&nbsp;&nbsp;
+            One
+        <section ids="sub-one" names="sub\ one">
+            <title auto="1">
+                <generated classes="sectnum">
+                    1.1&nbsp;&nbsp;&nbsp;
+                Sub One
+    <section ids="two" names="two">
+        <title auto="1" refid="id2">
+            <generated classes="sectnum">
+                2&nbsp;&nbsp;&nbsp;
+            Two
+        <paragraph>
+            See 
+            <reference name="One" refid="one">
+                One
+             and 
+            <reference name="Sub One" refid="sub-one">
+                Sub One
+            .
+`
+	// show the no-break spaces following section numbers
+	output := strings.Replace(document.Pformat("    ", 0), "\u00a0", "&nbsp;", -1)
+	if output != expected {
+		t.Error("contents/sectnum failed:\n" + output)
+	}
+}
+
+// The code directive and role, and a role derived from it.
+func TestCode(t *testing.T) {
+	input := ".. code:: go\n" +
+		"   :number-lines:\n" +
+		"\n" +
+		"   x := 1\n" +
+		"\n" +
+		"Inline :code:`x = 1`.\n" +
+		"\n" +
+		".. role:: golang(code)\n" +
+		"   :language: go\n" +
+		"\n" +
+		":golang:`len(x)`\n"
+	expected := `<document source="test data">
+    <literal_block classes="code go">
+        <inline classes="ln">
+            1 
+        <inline classes="nx">
+            x
+         
+        <inline classes="o">
+            :=
+         
+        <inline classes="mi">
+            1
+    <paragraph>
+        Inline 
+        <literal classes="code">
+            x = 1
+        .
+    <paragraph>
+        <literal classes="code golang go">
+            <inline classes="nb">
+                len
+            <inline classes="p">
+                (
+            <inline classes="nx">
+                x
+            <inline classes="p">
+                )
+`
+	if output := parse(t, input).Pformat("    ", 0); output != expected {
+		t.Error("code failed:\n" + output)
+	}
+}
+
+// The math role keeps the backslashes of the LaTeX code.
+func TestMath(t *testing.T) {
+	input := "Inline :math:`\\alpha^2 + b_1`.\n" +
+		"\n" +
+		".. math::\n" +
+		"\n" +
+		"   x &= 1 \\\\\n" +
+		"   y &= 2\n"
+	expected := `<document source="test data">
+    <paragraph>
+        Inline 
+        <math>
+            \alpha^2 + b_1
+        .
+    <math_block>
+        x &= 1 \\
+        y &= 2
+`
+	if output := parse(t, input).Pformat("    ", 0); output != expected {
+		t.Error("math failed:\n" + output)
+	}
+}
+
+// The raw directive and a role derived from the raw role, enabled and
+// disabled.
+func TestRaw(t *testing.T) {
+	input := `.. raw:: html
+
+   <hr>
+
+.. role:: html(raw)
+   :format: html
+
+A :html:` + "`<br>`" + ` break.
+`
+	cases := []struct {
+		rawEnabled bool
+		expected   string
+	}{
+		{true, `<document source="test data">
+    <raw format="html">
+        <hr>
+    <paragraph>
+        A 
+        <raw classes="html" format="html">
+            <br>
+         break.
+`},
+		{false, `<document source="test data">
+    <system_message level="2" line="1" source="test data" type="WARNING">
+        <paragraph>
+            "raw" directive disabled.
+        <literal_block>
+            .. raw:: html
+            
+               <hr>
+    <paragraph>
+        A 
+        <problematic ids="id2" refid="id1">
+            :html:` + "`<br>`" + `
+         break.
+    <system_message backrefs="id2" ids="id1" level="2" line="8" source="test data" type="WARNING">
+        <paragraph>
+            raw (and derived) roles disabled
+`},
+	}
+	for _, c := range cases {
+		settings := &rst.Settings{}
+		settings.Init()
+		settings.WarningStream = nil
+		settings.RawEnabled = c.rawEnabled
+		document, err := ParseDocument(input, "test data", settings)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if output := document.Pformat("    ", 0); output != c.expected {
+			t.Errorf("raw (enabled: %v) failed:\n%s", c.rawEnabled, output)
+		}
+	}
+}
+
+// The content of the replace directive is parsed and substituted.
+func TestReplace(t *testing.T) {
+	document := parse(t, `A |rep| and |link|.
+
+.. |rep| replace:: *emphasized* text
+.. |link| replace:: the `+"`target <http://x.org/>`__"+`
+.. |bad| replace:: one
+
+   two
+`)
+	applyTransforms(t, document)
+
+	expected := `<document source="test data">
+    <paragraph>
+        A 
+        <emphasis>
+            emphasized
+         text
+         and 
+        the 
+        <reference name="target" refuri="http://x.org/">
+            target
+        .
+    <substitution_definition names="rep">
+        <emphasis>
+            emphasized
+         text
+    <substitution_definition names="link">
+        the 
+        <reference name="target" refuri="http://x.org/">
+            target
+    <system_message level="3" line="5" source="test data" type="ERROR">
+        <paragraph>
+            Error in "replace" directive: may contain a single paragraph only.
+    <system_message level="2" line="5" source="test data" type="WARNING">
+        <paragraph>
+            Substitution definition "bad" empty or invalid.
+        <literal_block>
+            .. |bad| replace:: one
+            
+               two
+`
+	if output := document.Pformat("    ", 0); output != expected {
+		t.Error("replace failed:\n" + output)
+	}
+}
+
+// Roles defined in the document, the default role and built-in roles.
+func TestRoles(t *testing.T) {
+	input := ".. role:: custom\n" +
+		"   :class: special\n" +
+		"\n" +
+		".. default-role:: literal\n" +
+		"\n" +
+		":custom:`one`, `two`, :PEP:`8`, :rfc:`2822`, :sub:`x` and :Custom:`three`.\n" +
+		"\n" +
+		".. default-role::\n" +
+		"\n" +
+		"`four`\n"
+	expected := `<document source="test data">
+    <paragraph>
+        <inline classes="special">
+            one
+        , 
+        <literal>
+            two
+        , 
+        <reference refuri="https://peps.python.org/pep-0008">
+            PEP 8
+        , 
+        <reference refuri="https://tools.ietf.org/html/rfc2822.html">
+            RFC 2822
+        , 
+        <subscript>
+            x
+         and 
+        <inline classes="special">
+            three
+        .
+    <paragraph>
+        <title_reference>
+            four
+`
+	if output := parse(t, input).Pformat("    ", 0); output != expected {
+		t.Error("roles failed:\n" + output)
+	}
+}
+
+// The SmartQuotes transform educates the quotes of parsed text, not of
+// literals.
+func TestSmartQuotes(t *testing.T) {
+	settings := &rst.Settings{}
+	settings.Init()
+	settings.WarningStream = nil
+	settings.SmartQuotes = "yes"
+	input := "\"Quoted\" text -- it's *\"emphasized\"* and ``\"literal\"``...\n"
+	document, err := ParseDocument(input, "test data", settings)
+	if err != nil {
+		t.Fatal(err)
+	}
+	applyTransforms(t, document)
+
+	expected := `<document source="test data">
+    <paragraph>
+        “Quoted” text – it’s 
+        <emphasis>
+            “emphasized”
+         and 
+        <literal>
+            "literal"
+        …
+`
+	if output := document.Pformat("    ", 0); output != expected {
+		t.Error("smart quotes failed:\n" + output)
+	}
+}
+
+func TestSpeakerNotes(t *testing.T) {
+	document := parse(t, `.. speaker-notes::
+   :class: short
+
+   Mention the *history*.
+
+   - Ask questions.
+`)
+	expected := `<document source="test data">
+    <container classes="speaker-notes short">
+        <paragraph>
+            Mention the 
+            <emphasis>
+                history
+            .
+        <bullet_list bullet="-">
+            <list_item>
+                <paragraph>
+                    Ask questions.
+`
+	if output := document.Pformat("    ", 0); output != expected {
+		t.Error("speaker-notes directive failed:\n" + output)
+	}
+}
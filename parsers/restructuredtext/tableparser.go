@@ -0,0 +1,668 @@
+package restructuredtext
+
+/*
+Implement the table parsers of Python docutils: `parseGridTable()` for grid
+tables and `parseSimpleTable()` for simple tables.
+
+URL of Python source code:
+http://sourceforge.net/p/docutils/code/HEAD/tree/trunk/docutils/docutils/parsers/rst/tableparser.py
+*/
+
+import (
+	"math"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+
+	rst "github.com/siongui/go-rst"
+)
+
+// Padding added after East Asian wide and fullwidth characters in tables,
+// so that each column is one character.
+const doubleWidthPadChar = "\x00"
+
+// An error in the markup of a table; `offset` is the table line offset.
+type tableMarkupError struct {
+	msg    string
+	offset int
+}
+
+func (e *tableMarkupError) Error() string {
+	return e.msg
+}
+
+/*
+   A table cell: the number of rows and columns it spans in addition to its
+   own, the line offset of its content in the table, and the content.
+*/
+type tableCell struct {
+	morerows int
+	morecols int
+	offset   int
+	block    rst.StringList
+}
+
+/*
+   The structure of a table: the column widths, and the head and body rows.
+   Cells spanned by another cell are nil.
+*/
+type tableData struct {
+	colwidths []int
+	headrows  [][]*tableCell
+	bodyrows  [][]*tableCell
+}
+
+var (
+	gridHeadBodySeparatorPat   = regexp.MustCompile(`^\+=[=+]+=\+ *$`)
+	simpleHeadBodySeparatorPat = regexp.MustCompile(`^=[ =]*$`)
+	simpleSpanPat              = regexp.MustCompile(`^-[ -]*$`)
+)
+
+// Add `pad` after the East Asian wide and fullwidth characters of the
+// lines of `block`.
+func padDoubleWidth(block *rst.StringList) {
+	for i, line := range block.Lines() {
+		var b strings.Builder
+		for _, r := range line {
+			b.WriteRune(r)
+			if isWide(r) {
+				b.WriteString(doubleWidthPadChar)
+			}
+		}
+		block.Lines()[i] = b.String()
+	}
+}
+
+/*
+   Find the head/body row separator of `block` (matched by `pattern`) and
+   replace its "=" by "-". Return its line offset, 0 if there is none.
+*/
+func findHeadBodySep(block *rst.StringList, pattern *regexp.Regexp) (int, error) {
+	headBodySep := 0
+	lines := block.Lines()
+	i := 0
+	for ; i < len(lines); i++ {
+		if pattern.MatchString(lines[i]) {
+			if headBodySep != 0 {
+				return 0, &tableMarkupError{"Multiple head/body row separators (table lines " +
+					strconv.Itoa(headBodySep+1) + " and " + strconv.Itoa(i+1) + "); only one allowed.", i}
+			}
+			headBodySep = i
+			lines[i] = strings.Replace(lines[i], "=", "-", -1)
+		}
+	}
+	if headBodySep == len(lines)-1 {
+		return 0, &tableMarkupError{"The head/body row separator may not be the first or last line of the table.", i}
+	}
+	return headBodySep, nil
+}
+
+/*
+   Return the lines `top` to `bottom` of `block`, cut to the characters
+   `left` to `right` and stripped of their common indentation.
+*/
+func get2DBlock(block rst.StringList, top, left, bottom, right int) rst.StringList {
+	cellblock := block.GetItemsSlice(top, bottom)
+	cellblock.Disconnect(0) // lines in cell can't sync with parent
+	indent := right
+	for i, line := range cellblock.Lines() {
+		runes := []rune(line)
+		l, r := left, right
+		if l > len(runes) {
+			l = len(runes)
+		}
+		if r > len(runes) {
+			r = len(runes)
+		}
+		line = strings.TrimRightFunc(string(runes[l:r]), unicode.IsSpace)
+		cellblock.SetItem(i, line)
+		if line != "" {
+			if lineIndent := len([]rune(line)) - len([]rune(strings.TrimLeftFunc(line, unicode.IsSpace))); lineIndent < indent {
+				indent = lineIndent
+			}
+		}
+	}
+	if 0 < indent && indent < right {
+		for i, line := range cellblock.Lines() {
+			cellblock.SetItem(i, sliceChars(line, indent))
+		}
+	}
+	cellblock.Replace(doubleWidthPadChar, "")
+	return cellblock
+}
+
+/*
+   Parse a grid table using `parseGridTable()`.
+
+   Here's an example of a grid table::
+
+       +------------------------+------------+----------+----------+
+       | Header row, column 1   | Header 2   | Header 3 | Header 4 |
+       +========================+============+==========+==========+
+       | body row 1, column 1   | column 2   | column 3 | column 4 |
+       +------------------------+------------+----------+----------+
+       | body row 2             | Cells may span columns.          |
+       +------------------------+------------+---------------------+
+       | body row 3             | Cells may  | - Table cells       |
+       +------------------------+ span rows. | - contain           |
+       | body row 4             |            | - body elements.    |
+       +------------------------+------------+---------------------+
+
+   Intersections use '+', row separators use '-' (except for one optional
+   head/body row separator, which uses '='), and column separators use '|'.
+
+   The table is scanned cell by cell, from the top left corner: the corners
+   of each cell are found by scanning right along its top border, down its
+   right border, left along its bottom border and up its left border.
+*/
+type gridTableParser struct {
+	block       [][]rune
+	lines       rst.StringList
+	bottom      int
+	right       int
+	headBodySep int
+	done        []int
+	cells       []gridCell
+	rowseps     map[int]bool
+	colseps     map[int]bool
+}
+
+type gridCell struct {
+	top, left, bottom, right int
+	block                    rst.StringList
+}
+
+/*
+   Analyze the text `block` of a grid table and return the table data, or a
+   `tableMarkupError`.
+*/
+func parseGridTable(block rst.StringList) (*tableData, error) {
+	p := &gridTableParser{}
+	lines := block.GetItemsSlice(0, block.Length()) // make a copy; it may be modified
+	lines.Disconnect(0)                             // don't propagate changes to parent
+	headBodySep, err := findHeadBodySep(&lines, gridHeadBodySeparatorPat)
+	if err != nil {
+		return nil, err
+	}
+	p.lines = lines
+	p.headBodySep = headBodySep
+	for _, line := range lines.Lines() {
+		p.block = append(p.block, []rune(line))
+	}
+	p.bottom = len(p.block) - 1
+	p.right = len(p.block[0]) - 1
+	p.done = make([]int, len(p.block[0]))
+	for i := range p.done {
+		p.done[i] = -1
+	}
+	p.rowseps = map[int]bool{0: true}
+	p.colseps = map[int]bool{0: true}
+	if err := p.parseTable(); err != nil {
+		return nil, err
+	}
+	return p.structureFromCells(), nil
+}
+
+/*
+   Start with a queue of upper-left corners, containing the upper-left
+   corner of the table itself. Trace out one rectangular cell, remember it,
+   and add its upper-right and lower-left corners to the queue of potential
+   upper-left corners of further cells. Process the queue in top-to-bottom
+   order, keeping track of how much of each text column has been seen.
+
+   We'll end up knowing all the row and column boundaries, cell positions
+   and their dimensions.
+*/
+func (p *gridTableParser) parseTable() error {
+	corners := [][2]int{{0, 0}}
+	for len(corners) > 0 {
+		top, left := corners[0][0], corners[0][1]
+		corners = corners[1:]
+		if top == p.bottom || left == p.right || top <= p.done[left] {
+			continue
+		}
+		bottom, right, rowseps, colseps, ok := p.scanCell(top, left)
+		if !ok {
+			continue
+		}
+		for sep := range rowseps {
+			p.rowseps[sep] = true
+		}
+		for sep := range colseps {
+			p.colseps[sep] = true
+		}
+		p.markDone(top, left, bottom, right)
+		cellblock := get2DBlock(p.lines, top+1, left+1, bottom, right)
+		p.cells = append(p.cells, gridCell{top, left, bottom, right, cellblock})
+		corners = append(corners, [2]int{top, right}, [2]int{bottom, left})
+		sort.Slice(corners, func(i, j int) bool {
+			if corners[i][0] != corners[j][0] {
+				return corners[i][0] < corners[j][0]
+			}
+			return corners[i][1] < corners[j][1]
+		})
+	}
+	if !p.checkParseComplete() {
+		return &tableMarkupError{"Malformed table; parse incomplete.", 0}
+	}
+	return nil
+}
+
+// For keeping track of how much of each text column has been seen.
+func (p *gridTableParser) markDone(top, left, bottom, right int) {
+	for col := left; col < right; col++ {
+		p.done[col] = bottom - 1
+	}
+}
+
+// Each text column should have been completely seen.
+func (p *gridTableParser) checkParseComplete() bool {
+	last := p.bottom - 1
+	for col := 0; col < p.right; col++ {
+		if p.done[col] != last {
+			return false
+		}
+	}
+	return true
+}
+
+// Return the bottom right corner of the cell at `top`, `left`, and the
+// row and column separators found.
+func (p *gridTableParser) scanCell(top, left int) (int, int, map[int]bool, map[int]bool, bool) {
+	return p.scanRight(top, left)
+}
+
+/*
+   Look for the top-right corner of the cell, and make note of all column
+   boundaries ('+').
+*/
+func (p *gridTableParser) scanRight(top, left int) (int, int, map[int]bool, map[int]bool, bool) {
+	colseps := map[int]bool{}
+	line := p.block[top]
+	for i := left + 1; i <= p.right; i++ {
+		if line[i] == '+' {
+			colseps[i] = true
+			if bottom, rowseps, newcolseps, ok := p.scanDown(top, left, i); ok {
+				for sep := range newcolseps {
+					colseps[sep] = true
+				}
+				return bottom, i, rowseps, colseps, true
+			}
+		} else if line[i] != '-' {
+			return 0, 0, nil, nil, false
+		}
+	}
+	return 0, 0, nil, nil, false
+}
+
+// Look for the bottom-right corner of the cell, making note of all row
+// boundaries.
+func (p *gridTableParser) scanDown(top, left, right int) (int, map[int]bool, map[int]bool, bool) {
+	rowseps := map[int]bool{}
+	for i := top + 1; i <= p.bottom; i++ {
+		if p.charAt(i, right) == '+' {
+			rowseps[i] = true
+			if newrowseps, colseps, ok := p.scanLeft(top, left, i, right); ok {
+				for sep := range newrowseps {
+					rowseps[sep] = true
+				}
+				return i, rowseps, colseps, true
+			}
+		} else if p.charAt(i, right) != '|' {
+			return 0, nil, nil, false
+		}
+	}
+	return 0, nil, nil, false
+}
+
+/*
+   Noting column boundaries, look for the bottom-left corner of the cell.
+   It must line up with the starting point.
+*/
+func (p *gridTableParser) scanLeft(top, left, bottom, right int) (map[int]bool, map[int]bool, bool) {
+	colseps := map[int]bool{}
+	for i := right - 1; i > left; i-- {
+		if p.charAt(bottom, i) == '+' {
+			colseps[i] = true
+		} else if p.charAt(bottom, i) != '-' {
+			return nil, nil, false
+		}
+	}
+	if p.charAt(bottom, left) != '+' {
+		return nil, nil, false
+	}
+	rowseps, ok := p.scanUp(top, left, bottom)
+	return rowseps, colseps, ok
+}
+
+// Noting row boundaries, see if we can return to the starting point.
+func (p *gridTableParser) scanUp(top, left, bottom int) (map[int]bool, bool) {
+	rowseps := map[int]bool{}
+	for i := bottom - 1; i > top; i-- {
+		if p.charAt(i, left) == '+' {
+			rowseps[i] = true
+		} else if p.charAt(i, left) != '|' {
+			return nil, false
+		}
+	}
+	return rowseps, true
+}
+
+// Return the character at line `i`, column `j`; 0 if it is off the line.
+func (p *gridTableParser) charAt(i, j int) rune {
+	if j >= len(p.block[i]) {
+		return 0
+	}
+	return p.block[i][j]
+}
+
+// From the data collected by `scanCell()`, convert to the final data
+// structure.
+func (p *gridTableParser) structureFromCells() *tableData {
+	rowseps := sortedKeys(p.rowseps) // list of row boundaries
+	rowindex := map[int]int{}
+	for i, sep := range rowseps {
+		rowindex[sep] = i // row boundary -> row number mapping
+	}
+	colseps := sortedKeys(p.colseps) // list of column boundaries
+	colindex := map[int]int{}
+	for i, sep := range colseps {
+		colindex[sep] = i // column boundary -> col number map
+	}
+	var colspecs []int // list of column widths
+	for i := 1; i < len(colseps); i++ {
+		colspecs = append(colspecs, colseps[i]-colseps[i-1]-1)
+	}
+	// prepare an empty table with the correct number of rows & columns
+	rows := make([][]*tableCell, len(rowseps)-1)
+	for i := range rows {
+		rows[i] = make([]*tableCell, len(colseps)-1)
+	}
+	for _, cell := range p.cells {
+		rownum := rowindex[cell.top]
+		colnum := colindex[cell.left]
+		morerows := rowindex[cell.bottom] - rownum - 1
+		morecols := colindex[cell.right] - colnum - 1
+		// write the cell into the table
+		rows[rownum][colnum] = &tableCell{morerows, morecols, cell.top + 1, cell.block}
+	}
+	data := &tableData{colwidths: colspecs, bodyrows: rows}
+	if p.headBodySep != 0 { // separate head rows from body rows
+		numheadrows := rowindex[p.headBodySep]
+		data.headrows = rows[:numheadrows]
+		data.bodyrows = rows[numheadrows:]
+	}
+	return data
+}
+
+func sortedKeys(m map[int]bool) []int {
+	keys := make([]int, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Ints(keys)
+	return keys
+}
+
+/*
+   Parse a simple table using `parseSimpleTable()`.
+
+   Here's an example of a simple table::
+
+       =====  =====
+       col 1  col 2
+       =====  =====
+       1      Second column of row 1.
+       2      Second column of row 2.
+              Second line of paragraph.
+       3      - Second column of row 3.
+
+              - Second item in bullet
+                list (row 3, column 2).
+       4 is a span
+       ------------
+       5
+       =====  =====
+
+   Top and bottom borders use '=', column span underlines use '-', column
+   separation is indicated with spaces.
+
+   Passing the above table to `parseSimpleTable()` returns rows of cells
+   whose contents are the cells' text blocks; rows and columns are
+   determined by the borders and the first column.
+*/
+type simpleTableParser struct {
+	block       rst.StringList
+	headBodySep int
+	columns     [][2]int
+	borderEnd   int
+	table       [][]*tableCell
+}
+
+/*
+   Analyze the text `block` of a simple table and return the table data, or
+   a `tableMarkupError`.
+*/
+func parseSimpleTable(block rst.StringList) (*tableData, error) {
+	p := &simpleTableParser{}
+	p.block = block.GetItemsSlice(0, block.Length()) // make a copy; it will be modified
+	p.block.Disconnect(0)                            // don't propagate changes to parent
+	// Convert top & bottom borders to column span underlines:
+	last := p.block.Length() - 1
+	p.block.SetItem(0, strings.Replace(p.block.Lines()[0], "=", "-", -1))
+	p.block.SetItem(last, strings.Replace(p.block.Lines()[last], "=", "-", -1))
+	headBodySep, err := findHeadBodySep(&p.block, simpleHeadBodySeparatorPat)
+	if err != nil {
+		return nil, err
+	}
+	p.headBodySep = headBodySep
+	if err := p.parseTable(); err != nil {
+		return nil, err
+	}
+	return p.structureFromCells(), nil
+}
+
+/*
+   First determine the column boundaries from the top border, then
+   process rows. Each row may consist of multiple lines; accumulate lines
+   until a row is complete. Call `parseRow()` to finish the job.
+*/
+func (p *simpleTableParser) parseTable() error {
+	// Top border must fully describe all table columns.
+	columns, err := p.parseColumns(p.block.Lines()[0], 0)
+	if err != nil {
+		return err
+	}
+	p.columns = columns
+	p.borderEnd = p.columns[len(p.columns)-1][1]
+	firststart, firstend := p.columns[0][0], p.columns[0][1]
+	offset := 1 // skip top border
+	start := 1
+	textFound := false
+	for offset < p.block.Length() {
+		line := p.block.Lines()[offset]
+		if simpleSpanPat.MatchString(line) {
+			// Column span underline or border; row is complete.
+			spanline := strings.TrimRightFunc(line, unicode.IsSpace)
+			if err := p.parseRow(p.block.GetItemsSlice(start, offset), start, spanline, offset, true); err != nil {
+				return err
+			}
+			start = offset + 1
+			textFound = false
+		} else if strings.TrimSpace(sliceRunes(line, firststart, firstend)) != "" {
+			// First column not blank, therefore it's a new row.
+			if textFound && offset != start {
+				if err := p.parseRow(p.block.GetItemsSlice(start, offset), start, "", 0, false); err != nil {
+					return err
+				}
+			}
+			start = offset
+			textFound = true
+		} else if !textFound {
+			start = offset + 1
+		}
+		offset++
+	}
+	return nil
+}
+
+/*
+   Given a column span underline, return a list of (begin, end) pairs.
+*/
+func (p *simpleTableParser) parseColumns(line string, offset int) ([][2]int, error) {
+	runes := []rune(line)
+	var cols [][2]int
+	end := 0
+	for {
+		begin := indexRune(runes, '-', end)
+		if begin < 0 {
+			break
+		}
+		end = indexRune(runes, ' ', begin)
+		if end < 0 {
+			end = len(runes)
+		}
+		cols = append(cols, [2]int{begin, end})
+	}
+	if p.columns != nil {
+		if cols[len(cols)-1][1] != p.borderEnd {
+			return nil, &tableMarkupError{"Column span incomplete in table line " + strconv.Itoa(offset+1) + ".", offset}
+		}
+		// Allow for an unbounded rightmost column:
+		cols[len(cols)-1][1] = p.columns[len(p.columns)-1][1]
+	}
+	return cols, nil
+}
+
+// Return the index of `r` in `runes` from `start`, -1 if it is not found.
+func indexRune(runes []rune, r rune, start int) int {
+	for i := start; i < len(runes); i++ {
+		if runes[i] == r {
+			return i
+		}
+	}
+	return -1
+}
+
+// Return the characters `start` to `end` of `line`, clipped to the line.
+func sliceRunes(line string, start, end int) string {
+	runes := []rune(line)
+	if end > len(runes) {
+		end = len(runes)
+	}
+	if start > end {
+		start = end
+	}
+	return string(runes[start:end])
+}
+
+func (p *simpleTableParser) initRow(colspec [][2]int, offset int) ([]*tableCell, error) {
+	i := 0
+	var cells []*tableCell
+	for _, col := range colspec {
+		morecols := 0
+		if i >= len(p.columns) || col[0] != p.columns[i][0] {
+			return nil, &tableMarkupError{"Column span alignment problem in table line " + strconv.Itoa(offset+2) + ".", offset + 1}
+		}
+		for col[1] != p.columns[i][1] {
+			i++
+			morecols++
+			if i >= len(p.columns) {
+				return nil, &tableMarkupError{"Column span alignment problem in table line " + strconv.Itoa(offset+2) + ".", offset + 1}
+			}
+		}
+		cells = append(cells, &tableCell{0, morecols, offset, rst.StringList{}})
+		i++
+	}
+	return cells, nil
+}
+
+/*
+   Given the text `lines` of a row, parse it and append to `p.table`.
+
+   The row is parsed according to the current column spec (either
+   `spanline` if `hasSpanline`, or `p.columns`). For each column, extract
+   text from each line, and check for text in column margins. Finally,
+   adjust for insignificant whitespace.
+*/
+func (p *simpleTableParser) parseRow(lines rst.StringList, start int, spanline string, spanOffset int, hasSpanline bool) error {
+	if lines.Length() == 0 && !hasSpanline {
+		// No new row, just blank lines.
+		return nil
+	}
+	var columns [][2]int
+	if hasSpanline {
+		var err error
+		if columns, err = p.parseColumns(spanline, spanOffset); err != nil {
+			return err
+		}
+	} else {
+		columns = append([][2]int{}, p.columns...)
+	}
+	if err := p.checkColumns(lines, start, columns); err != nil {
+		return err
+	}
+	row, err := p.initRow(columns, start)
+	if err != nil {
+		return err
+	}
+	for i, col := range columns {
+		row[i].block = get2DBlock(lines, 0, col[0], lines.Length(), col[1])
+	}
+	p.table = append(p.table, row)
+	return nil
+}
+
+/*
+   Check for text in column margins and text overflow in the last column.
+   Return a `tableMarkupError` if anything but whitespace is in column
+   margins. Adjust the end value for the last column if there is text
+   overflow.
+*/
+func (p *simpleTableParser) checkColumns(lines rst.StringList, firstLine int, columns [][2]int) error {
+	lastcol := len(columns) - 1
+	for i := range columns {
+		start, end := columns[i][0], columns[i][1]
+		// "Infinite" value for a dummy last column's beginning, used to
+		// check for text overflow:
+		nextstart := math.MaxInt32
+		if i < lastcol {
+			nextstart = columns[i+1][0]
+		}
+		for offset, line := range lines.Lines() {
+			if i == lastcol && strings.TrimSpace(sliceRunes(line, end, math.MaxInt32)) != "" {
+				text := strings.TrimRightFunc(sliceRunes(line, start, math.MaxInt32), unicode.IsSpace)
+				newEnd := start + len([]rune(text))
+				mainStart, mainEnd := p.columns[len(p.columns)-1][0], p.columns[len(p.columns)-1][1]
+				if newEnd > mainEnd {
+					columns[i] = [2]int{start, newEnd}
+					p.columns[len(p.columns)-1] = [2]int{mainStart, newEnd}
+				} else {
+					columns[i] = [2]int{start, mainEnd}
+				}
+			} else if strings.TrimSpace(sliceRunes(line, end, nextstart)) != "" {
+				return &tableMarkupError{"Text in column margin in table line " + strconv.Itoa(firstLine+offset+1) + ".", firstLine + offset}
+			}
+		}
+	}
+	return nil
+}
+
+func (p *simpleTableParser) structureFromCells() *tableData {
+	var colspecs []int
+	for _, col := range p.columns {
+		colspecs = append(colspecs, col[1]-col[0])
+	}
+	firstBodyRow := 0
+	if p.headBodySep != 0 {
+		for i, row := range p.table {
+			if row[0].offset > p.headBodySep {
+				firstBodyRow = i
+				break
+			}
+		}
+	}
+	return &tableData{colspecs, p.table[:firstBodyRow], p.table[firstBodyRow:]}
+}
@@ -0,0 +1,504 @@
+package restructuredtext
+
+import (
+	"regexp"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	rst "github.com/siongui/go-rst"
+)
+
+/*
+   Classifier of second line of a text block.
+
+   Could be a paragraph, a definition list item, or a title.
+*/
+type text struct {
+	rstState
+}
+
+var textTransitions = []string{"underline", "text"}
+
+func newText(sm *stateMachine) *text {
+	s := &text{}
+	s.init(sm, "Text", textTransitions, map[string]rst.TransitionMethod{
+		"underline": s.underline,
+		"text":      s.text,
+	}, s.blank, s.indent)
+	return s
+}
+
+// End of paragraph.
+func (s *text) blank(match *rst.Match, context []string, nextState string) ([]string, string, []string, error) {
+	paragraph, literalnext := s.paragraph(context, s.sm.AbsLineNumber()-1)
+	s.parent.Extend(paragraph...)
+	if literalnext {
+		nodelist, err := s.literalBlock()
+		if err != nil {
+			return fail(nil, err)
+		}
+		s.parent.Extend(nodelist...)
+	}
+	return nil, "Body", nil, nil
+}
+
+func (s *text) Eof(context []string) ([]string, error) {
+	if len(context) > 0 {
+		_, _, _, err := s.blank(nil, context, "")
+		return nil, err
+	}
+	return nil, nil
+}
+
+// Definition list item.
+func (s *text) indent(match *rst.Match, context []string, nextState string) ([]string, string, []string, error) {
+	definitionlist := newElement("definition_list", "", "")
+	definitionlistitem, blankFinish, err := s.definitionListItem(context)
+	if err != nil {
+		return fail(nil, err)
+	}
+	definitionlist.Append(definitionlistitem)
+	s.parent.Append(definitionlist)
+	newLineOffset, blankFinish, err := s.nestedListParse(s.nextLines(), s.sm.AbsLineOffset()+1, definitionlist, "DefinitionList", blankFinish, "Definition", nil, false)
+	if err != nil {
+		return fail(nil, err)
+	}
+	s.gotoLine(newLineOffset)
+	if !blankFinish {
+		s.parent.Append(s.unindentWarning("Definition list"))
+	}
+	return nil, "Body", nil, nil
+}
+
+// Section title.
+func (s *text) underline(match *rst.Match, context []string, nextState string) ([]string, string, []string, error) {
+	lineno := s.sm.AbsLineNumber()
+	title := strings.TrimRightFunc(context[0], unicode.IsSpace)
+	underline := strings.TrimRightFunc(match.String, unicode.IsSpace)
+	source := title + "\n" + underline
+	var messages []rst.Node
+	if columnWidth(title) > utf8.RuneCountInString(underline) {
+		if utf8.RuneCountInString(underline) < 4 {
+			if s.sm.matchTitles {
+				msg := s.reporter.Info("Possible title underline, too short for the title.\n"+
+					"Treating it as ordinary text because it's so short.", "", lineno)
+				s.parent.Append(msg)
+			}
+			return fail(context, &rst.TransitionCorrection{Transition: "text"})
+		}
+		blocktext := context[0] + "\n" + s.sm.Line
+		msg := s.reporter.Warning("Title underline too short.", "", lineno,
+			newElement("literal_block", blocktext, blocktext))
+		messages = append(messages, msg)
+	}
+	if !s.sm.matchTitles {
+		blocktext := context[0] + "\n" + s.sm.Line
+		// We need GetSourceAndLine() here to report correctly
+		source, line := s.sm.GetSourceAndLine(0)
+		msg := s.reporter.Severe("Unexpected section title.", source, line,
+			newElement("literal_block", blocktext, blocktext))
+		s.parent.Extend(messages...)
+		s.parent.Append(msg)
+		return nil, "Body", nil, nil
+	}
+	style := string(firstRuneOf(underline))
+	if err := s.section(title, source, style, lineno-1, messages); err != nil {
+		return fail(nil, err)
+	}
+	return nil, "Body", nil, nil
+}
+
+// Paragraph.
+func (s *text) text(match *rst.Match, context []string, nextState string) ([]string, string, []string, error) {
+	startline := s.sm.AbsLineNumber() - 1
+	var msg *rst.Element
+	block, err := s.sm.GetTextBlock(true)
+	if e, ok := err.(*rst.UnexpectedIndentationError); ok {
+		msg = s.reporter.Error("Unexpected indentation.", e.Source, e.Lineno)
+	}
+	lines := append(append([]string{}, context...), block.Lines()...)
+	paragraph, literalnext := s.paragraph(lines, startline)
+	s.parent.Extend(paragraph...)
+	if msg != nil {
+		s.parent.Append(msg)
+	}
+	if literalnext {
+		s.sm.NextLine(1)
+		nodelist, err := s.literalBlock()
+		if err != nil {
+			return fail(nil, err)
+		}
+		s.parent.Extend(nodelist...)
+	}
+	return nil, "Body", nil, nil
+}
+
+// Return a list of nodes.
+func (s *text) literalBlock() ([]rst.Node, error) {
+	indented, _, offset, blankFinish := s.sm.GetIndented(false, true)
+	for indented.Length() > 0 && strings.TrimSpace(indented.Lines()[indented.Length()-1]) == "" {
+		indented.TrimEnd(1)
+	}
+	if indented.Length() == 0 {
+		return s.quotedLiteralBlock()
+	}
+	data := joinLines(indented)
+	literalBlock := newElement("literal_block", data, data)
+	s.setSourceAndLine(literalBlock, offset+1)
+	nodelist := []rst.Node{literalBlock}
+	if !blankFinish {
+		nodelist = append(nodelist, s.unindentWarning("Literal block"))
+	}
+	return nodelist, nil
+}
+
+func (s *text) quotedLiteralBlock() ([]rst.Node, error) {
+	absLineOffset := s.sm.AbsLineOffset()
+	lines := s.sm.InputLines()
+	block := lines.GetItemsSlice(s.sm.LineOffset(), lines.Length())
+	parentNode := newElement("", "", "")
+	newAbsOffset, err := s.nestedParseFrom("QuotedLiteralBlock", block, absLineOffset, parentNode, false)
+	if err != nil {
+		return nil, err
+	}
+	s.gotoLine(newAbsOffset)
+	children := parentNode.Children()
+	parentNode.SetChildren(nil)
+	return children, nil
+}
+
+func (s *text) definitionListItem(termline []string) (*rst.Element, bool, error) {
+	indented, _, lineOffset, blankFinish := s.sm.GetIndented(false, true)
+	itemnode := newElement("definition_list_item", strings.Join(append(append([]string{}, termline...), indented.Lines()...), "\n"), "")
+	lineno := s.sm.AbsLineNumber() - 1
+	s.setSourceAndLine(itemnode, lineno)
+	termlist, messages := s.term(termline, lineno)
+	itemnode.Extend(termlist...)
+	definition := newElement("definition", "", "", messages...)
+	itemnode.Append(definition)
+	if strings.HasSuffix(termline[0], "::") {
+		definition.Append(s.reporter.Info("Blank line missing before literal block (after the \"::\")? "+
+			"Interpreted as a definition list item.", "", lineno+1))
+	}
+	if _, err := s.nestedParse(indented, lineOffset, definition, false); err != nil {
+		return nil, false, err
+	}
+	return itemnode, blankFinish, nil
+}
+
+// Return a definition_list's term and optional classifiers.
+func (s *text) term(lines []string, lineno int) ([]rst.Node, []rst.Node) {
+	textNodes, messages := s.inlineText(lines[0], lineno)
+	termNode := newElement("term", lines[0], "")
+	s.setSourceAndLine(termNode, lineno)
+	nodeList := []*rst.Element{termNode}
+	for _, node := range textNodes {
+		t, ok := node.(*rst.Text)
+		if !ok {
+			nodeList[len(nodeList)-1].Append(node)
+			continue
+		}
+		parts := classifierDelimiter.Split(t.RawSource(), -1)
+		if len(parts) == 1 {
+			nodeList[len(nodeList)-1].Append(node)
+			continue
+		}
+		rawtext := strings.TrimRightFunc(parts[0], unicode.IsSpace)
+		nodeList[len(nodeList)-1].Append(newTextNode(unescape(escape2null(rawtext), false), rawtext))
+		for _, part := range parts[1:] {
+			nodeList = append(nodeList, newElement("classifier", part, unescape(escape2null(part), false)))
+		}
+	}
+	result := make([]rst.Node, len(nodeList))
+	for i, node := range nodeList {
+		result[i] = node
+	}
+	return result, messages
+}
+
+// Return the first character of `text`.
+func firstRuneOf(text string) rune {
+	r, _ := firstRune(text)
+	return r
+}
+
+/*
+   Superclass for second and subsequent lines of Text-variants.
+
+   All transition methods are disabled. Override individual methods in
+   subclasses to re-enable.
+*/
+type specializedText struct {
+	text
+}
+
+/*
+   Set up the specialized state `name`: all transitions are invalid input
+   but `methods` ("blank", "indent", "underline" or "text").
+*/
+func (s *specializedText) initSpecialized(sm *stateMachine, name string, methods map[string]rst.TransitionMethod) {
+	all := map[string]rst.TransitionMethod{
+		"blank":     s.invalidInput,
+		"indent":    s.invalidInput,
+		"underline": s.invalidInput,
+		"text":      s.invalidInput,
+	}
+	for transition, method := range methods {
+		all[transition] = method
+	}
+	s.init(sm, name, textTransitions, all, all["blank"], all["indent"])
+}
+
+// Incomplete construct.
+func (s *specializedText) Eof(context []string) ([]string, error) {
+	return nil, nil
+}
+
+// Not a compound element member. Abort this state machine.
+func (s *specializedText) invalidInput(match *rst.Match, context []string, nextState string) ([]string, string, []string, error) {
+	return fail(context, rst.ErrEOF)
+}
+
+// Second line of potential definition_list_item.
+type definition struct {
+	specializedText
+}
+
+func newDefinition(sm *stateMachine) *definition {
+	s := &definition{}
+	s.initSpecialized(sm, "Definition", map[string]rst.TransitionMethod{"indent": s.indent})
+	return s
+}
+
+// Not a definition.
+func (s *definition) Eof(context []string) ([]string, error) {
+	s.sm.PreviousLine(2) // so parent SM can reassess
+	return nil, nil
+}
+
+// Definition list item.
+func (s *definition) indent(match *rst.Match, context []string, nextState string) ([]string, string, []string, error) {
+	itemnode, blankFinish, err := s.definitionListItem(context)
+	if err != nil {
+		return fail(nil, err)
+	}
+	s.parent.Append(itemnode)
+	s.blankFinish = blankFinish
+	return nil, "DefinitionList", nil, nil
+}
+
+/*
+   Second line of over- & underlined section title or transition marker.
+*/
+type line struct {
+	specializedText
+
+	// Add a transition at the end of input? Not when the end of input is
+	// reached because a section title bubbles up.
+	eofcheck bool
+}
+
+func newLine(sm *stateMachine) *line {
+	s := &line{eofcheck: true}
+	s.initSpecialized(sm, "Line", map[string]rst.TransitionMethod{
+		"blank":     s.blank,
+		"indent":    s.text, // indented title
+		"underline": s.underline,
+		"text":      s.text,
+	})
+	return s
+}
+
+// Transition marker at end of section or document.
+func (s *line) Eof(context []string) ([]string, error) {
+	marker := strings.TrimSpace(context[0])
+	if s.memo.sectionBubbleUpKludge {
+		s.memo.sectionBubbleUpKludge = false
+	} else if utf8.RuneCountInString(marker) < 4 {
+		return nil, s.stateCorrection(1)
+	}
+	if s.eofcheck { // ignore EOFError with sections
+		transition := newElement("transition", context[0], "")
+		transition.SetLine(s.sm.AbsLineNumber() - 1)
+		s.parent.Append(transition)
+	}
+	s.eofcheck = true
+	return nil, nil
+}
+
+// Transition marker.
+func (s *line) blank(match *rst.Match, context []string, nextState string) ([]string, string, []string, error) {
+	source, line := s.sm.GetSourceAndLine(0)
+	marker := strings.TrimSpace(context[0])
+	if utf8.RuneCountInString(marker) < 4 {
+		return fail(nil, s.stateCorrection(1))
+	}
+	transition := newElement("transition", marker, "")
+	transition.SetSource(source)
+	transition.SetLine(line - 1)
+	s.parent.Append(transition)
+	return nil, "Body", nil, nil
+}
+
+// Potential over- & underlined title.
+func (s *line) text(match *rst.Match, context []string, nextState string) ([]string, string, []string, error) {
+	lineno := s.sm.AbsLineNumber() - 1
+	overline := context[0]
+	title := match.String
+	underline, err := s.sm.NextLine(1)
+	if err != nil {
+		underline = ""
+		blocktext := overline + "\n" + title
+		if utf8.RuneCountInString(strings.TrimRightFunc(overline, unicode.IsSpace)) < 4 {
+			return fail(nil, s.shortOverline(blocktext, lineno, 2))
+		}
+		msg := s.reporter.Severe("Incomplete section title.", "", lineno,
+			newElement("literal_block", blocktext, blocktext))
+		s.parent.Append(msg)
+		return nil, "Body", nil, nil
+	}
+	source := overline + "\n" + title + "\n" + underline
+	overline = strings.TrimRightFunc(overline, unicode.IsSpace)
+	underline = strings.TrimRightFunc(underline, unicode.IsSpace)
+	if rst.MatchPattern(patterns["underline"], underline) == nil {
+		blocktext := overline + "\n" + title + "\n" + underline
+		if utf8.RuneCountInString(overline) < 4 {
+			return fail(nil, s.shortOverline(blocktext, lineno, 2))
+		}
+		msg := s.reporter.Severe("Missing matching underline for section title overline.", "", lineno,
+			newElement("literal_block", source, source))
+		s.parent.Append(msg)
+		return nil, "Body", nil, nil
+	} else if overline != underline {
+		blocktext := overline + "\n" + title + "\n" + underline
+		if utf8.RuneCountInString(overline) < 4 {
+			return fail(nil, s.shortOverline(blocktext, lineno, 2))
+		}
+		msg := s.reporter.Severe("Title overline & underline mismatch.", "", lineno,
+			newElement("literal_block", source, source))
+		s.parent.Append(msg)
+		return nil, "Body", nil, nil
+	}
+	title = strings.TrimRightFunc(title, unicode.IsSpace)
+	var messages []rst.Node
+	if columnWidth(title) > utf8.RuneCountInString(overline) {
+		blocktext := overline + "\n" + title + "\n" + underline
+		if utf8.RuneCountInString(overline) < 4 {
+			return fail(nil, s.shortOverline(blocktext, lineno, 2))
+		}
+		msg := s.reporter.Warning("Title overline too short.", "", lineno,
+			newElement("literal_block", source, source))
+		messages = append(messages, msg)
+	}
+	style := string(firstRuneOf(overline)) + string(firstRuneOf(underline))
+	s.eofcheck = false // @@@ not sure this is correct
+	if err := s.section(strings.TrimLeftFunc(title, unicode.IsSpace), source, style, lineno+1, messages); err != nil {
+		return fail(context, err)
+	}
+	s.eofcheck = true
+	return nil, "Body", nil, nil
+}
+
+func (s *line) underline(match *rst.Match, context []string, nextState string) ([]string, string, []string, error) {
+	overline := context[0]
+	blocktext := overline + "\n" + s.sm.Line
+	lineno := s.sm.AbsLineNumber() - 1
+	if utf8.RuneCountInString(strings.TrimRightFunc(overline, unicode.IsSpace)) < 4 {
+		return fail(nil, s.shortOverline(blocktext, lineno, 1))
+	}
+	msg := s.reporter.Error("Invalid section title or transition marker.", "", lineno,
+		newElement("literal_block", blocktext, blocktext))
+	s.parent.Append(msg)
+	return nil, "Body", nil, nil
+}
+
+func (s *line) shortOverline(blocktext string, lineno, lines int) error {
+	msg := s.reporter.Info("Possible incomplete section title.\nTreating the overline as "+
+		"ordinary text because it's so short.", "", lineno)
+	s.parent.Append(msg)
+	return s.stateCorrection(lines)
+}
+
+/*
+   Back up `lines` lines and return a `rst.StateCorrection` to parse the
+   overline again as ordinary text.
+*/
+func (s *line) stateCorrection(lines int) error {
+	s.sm.PreviousLine(lines)
+	return &rst.StateCorrection{State: "Body", Transition: "text"}
+}
+
+/*
+   Nested parse handler for quoted (unindented) literal blocks.
+
+   Special-purpose. Not for inclusion in the general states.
+*/
+type quotedLiteralBlock struct {
+	rstState
+	messages      []rst.Node
+	initialLineno int
+}
+
+func newQuotedLiteralBlock(sm *stateMachine) *quotedLiteralBlock {
+	s := &quotedLiteralBlock{}
+	s.init(sm, "QuotedLiteralBlock", []string{"initial_quoted", "text"}, map[string]rst.TransitionMethod{
+		"initial_quoted": s.initialQuoted,
+		"text":           s.text,
+	}, s.blank, s.indent)
+	return s
+}
+
+func (s *quotedLiteralBlock) blank(match *rst.Match, context []string, nextState string) ([]string, string, []string, error) {
+	if len(context) > 0 {
+		return fail(context, rst.ErrEOF)
+	}
+	return context, nextState, nil, nil
+}
+
+func (s *quotedLiteralBlock) Eof(context []string) ([]string, error) {
+	if len(context) > 0 {
+		text := strings.Join(context, "\n")
+		literalBlock := newElement("literal_block", text, text)
+		s.setSourceAndLine(literalBlock, s.initialLineno)
+		s.parent.Append(literalBlock)
+	} else {
+		s.parent.Append(s.reporter.Warning("Literal block expected; none found.", "", s.sm.AbsLineNumber()))
+		// src not available, statemachine.input_lines is empty
+		s.sm.PreviousLine(1)
+	}
+	s.parent.Extend(s.messages...)
+	return nil, nil
+}
+
+func (s *quotedLiteralBlock) indent(match *rst.Match, context []string, nextState string) ([]string, string, []string, error) {
+	s.messages = append(s.messages, s.reporter.Error("Unexpected indentation.", "", s.sm.AbsLineNumber()))
+	s.sm.PreviousLine(1)
+	return fail(context, rst.ErrEOF)
+}
+
+// Match arbitrary quote character on the first line only.
+func (s *quotedLiteralBlock) initialQuoted(match *rst.Match, context []string, nextState string) ([]string, string, []string, error) {
+	s.RemoveTransition("initial_quoted")
+	quote, _ := firstRune(match.String)
+	// New transition matches consistent quotes only:
+	s.AddTransition("quoted", rst.Transition{
+		Pattern: regexp.MustCompile("^" + regexp.QuoteMeta(string(quote))),
+		Method:  s.quoted,
+	})
+	s.initialLineno = s.sm.AbsLineNumber()
+	return []string{match.String}, nextState, nil, nil
+}
+
+// Match consistent quotes on subsequent lines.
+func (s *quotedLiteralBlock) quoted(match *rst.Match, context []string, nextState string) ([]string, string, []string, error) {
+	return append(context, match.String), nextState, nil, nil
+}
+
+func (s *quotedLiteralBlock) text(match *rst.Match, context []string, nextState string) ([]string, string, []string, error) {
+	if len(context) > 0 {
+		s.messages = append(s.messages, s.reporter.Error("Inconsistent literal block quoting.", "", s.sm.AbsLineNumber()))
+		s.sm.PreviousLine(1)
+	}
+	return fail(context, rst.ErrEOF)
+}
@@ -0,0 +1,43 @@
+/*
+Package readers holds the Reader interface of Python docutils; the readers
+themselves live in subpackages (readers/standalone, ...).
+
+URL of Python source code:
+http://sourceforge.net/p/docutils/code/HEAD/tree/trunk/docutils/docutils/readers/__init__.py
+*/
+package readers
+
+import (
+	rst "github.com/siongui/go-rst"
+	"github.com/siongui/go-rst/parsers"
+	"github.com/siongui/go-rst/transforms"
+)
+
+/*
+   Abstract base type for docutils Readers.
+
+   Readers know the context of the input (a standalone file, a PEP, ...):
+   `Read()` has the parser fill the document from the input text, and
+   `GetTransforms()` returns the transforms that context needs.
+*/
+type Reader interface {
+	rst.TransformSpec
+	Read(inputstring string, parser parsers.Parser, document *rst.Document) error
+}
+
+// Reader parts common to all readers, to embed in reader types.
+type Base struct{}
+
+/*
+   Return the transforms all readers need: the SmartQuotes transform (added
+   by the reStructuredText parser in Python docutils), which does nothing
+   unless enabled by the `SmartQuotes` setting.
+*/
+func (r *Base) GetTransforms() []rst.Transform {
+	return []rst.Transform{&transforms.SmartQuotes{}}
+}
+
+// Parse `inputstring` into `document` with `parser`.
+func (r *Base) Read(inputstring string, parser parsers.Parser, document *rst.Document) error {
+	return parser.Parse(inputstring, document)
+}
@@ -0,0 +1,36 @@
+/*
+Package standalone implements the standalone reader of Python docutils, for
+independent documents: the references of the document are resolved, its
+title, subtitle and bibliographic fields are recognized.
+
+URL of Python source code:
+http://sourceforge.net/p/docutils/code/HEAD/tree/trunk/docutils/docutils/readers/standalone.py
+*/
+package standalone
+
+import (
+	rst "github.com/siongui/go-rst"
+	"github.com/siongui/go-rst/readers"
+	"github.com/siongui/go-rst/transforms"
+)
+
+// The standalone reader.
+type Reader struct {
+	readers.Base
+}
+
+func (r *Reader) GetTransforms() []rst.Transform {
+	return append(r.Base.GetTransforms(),
+		&transforms.Substitutions{},
+		&transforms.PropagateTargets{},
+		&transforms.DocTitle{},
+		&transforms.SectionSubTitle{},
+		&transforms.DocInfo{},
+		&transforms.AnonymousHyperlinks{},
+		&transforms.IndirectHyperlinks{},
+		&transforms.Footnotes{},
+		&transforms.ExternalTargets{},
+		&transforms.InternalTargets{},
+		&transforms.DanglingReferences{},
+	)
+}
@@ -0,0 +1,69 @@
+package roles
+
+/*
+The "code" role of Python docutils roles.py: inline code with syntax
+highlighting.
+*/
+
+import (
+	rst "github.com/siongui/go-rst"
+	"github.com/siongui/go-rst/directives"
+)
+
+func init() {
+	RegisterRole("code", CodeRole, map[string]directives.OptionConverter{
+		"class":    directives.ClassOption,
+		"language": directives.Unchanged,
+	})
+}
+
+/*
+   Inline code. The "language" option (set with a custom role, see the
+   "role" directive) selects the lexer used to split the code into tokens;
+   an unknown language results in a `problematic` node and a warning.
+*/
+func CodeRole(name, rawtext, text string, lineno int, inliner *Inliner, options map[string]interface{}, content []string) ([]rst.Node, []rst.Node) {
+	language, _ := options["language"].(string)
+	classNames := []string{"code"}
+	if value, ok := options["class"]; ok {
+		classNames = append(classNames, value.([]string)...)
+	}
+	if language != "" && !contains(classNames, language) {
+		classNames = append(classNames, language)
+	}
+	settings := inliner.Document.Settings()
+	tokens, err := rst.AnalyzeCode(text, language, settings.SyntaxHighlight, settings.Highlighter)
+	if err != nil {
+		msg := inliner.Reporter.Warning(err.Error(), "", lineno)
+		prb := inliner.Problematic(rawtext, rawtext, msg)
+		return []rst.Node{prb}, []rst.Node{msg}
+	}
+
+	node := &rst.Element{}
+	node.Init("literal", rawtext, "")
+	node.Classes = classNames
+	// analyze content and add nodes for every token
+	for _, token := range tokens {
+		if len(token.Classes) > 0 {
+			inline := &rst.Element{}
+			inline.Init("inline", token.Value, token.Value)
+			inline.Classes = token.Classes
+			node.Append(inline)
+		} else {
+			// insert as Text to decrease the verbosity of the output
+			t := &rst.Text{}
+			t.Init(token.Value, token.Value)
+			node.Append(t)
+		}
+	}
+	return []rst.Node{node}, nil
+}
+
+func contains(list []string, value string) bool {
+	for _, v := range list {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
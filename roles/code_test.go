@@ -0,0 +1,68 @@
+package roles
+
+import (
+	"testing"
+
+	rst "github.com/siongui/go-rst"
+)
+
+func TestCodeRole(t *testing.T) {
+	settings := &rst.Settings{}
+	settings.Init()
+	settings.WarningStream = nil
+	// long token names, the default is short
+	settings.SyntaxHighlight = "long"
+	document := rst.NewDocument("test", settings)
+	paragraph := &rst.Element{}
+	paragraph.Init("paragraph", "", "")
+	document.Append(paragraph)
+	inliner := &Inliner{}
+	inliner.Init(document, paragraph)
+
+	role, ok := Lookup("CODE")
+	if !ok {
+		t.Fatal("code role not registered")
+	}
+	nodes, messages := role("code", ":code:`x := 1`", "x := 1", 1, inliner, map[string]interface{}{"language": "go"}, nil)
+	paragraph.Extend(nodes...)
+	if len(messages) != 0 {
+		t.Error("unexpected messages")
+	}
+	nodes, messages = role("code", ":code:`x`", "x", 2, inliner, map[string]interface{}{"language": "cobol"}, nil)
+	paragraph.Extend(nodes...)
+	document.Extend(messages...)
+
+	expected := `<document source="test">
+    <paragraph>
+        <literal classes="code go">
+            <inline classes="name other">
+                x
+             
+            <inline classes="operator">
+                :=
+             
+            <inline classes="literal number integer">
+                1
+        <problematic ids="id2" refid="id1">
+            :code:` + "`x`" + `
+    <system_message backrefs="id2" ids="id1" level="2" line="2" source="test" type="WARNING">
+        <paragraph>
+            Cannot analyze code. No lexer found for "cobol".
+`
+	if output := document.Pformat("    ", 0); output != expected {
+		t.Error("code role failed:\n" + output)
+	}
+}
+
+func TestMathRole(t *testing.T) {
+	role, ok := Lookup("math")
+	if !ok {
+		t.Fatal("math role not registered")
+	}
+	// backslash escapes are kept in math
+	nodes, _ := role("math", ":math:`\\alpha_1`", "alpha_1", 1, nil, map[string]interface{}{"class": []string{"greek"}}, nil)
+	expected := "<math classes=\"greek\">\n    \\alpha_1\n"
+	if output := nodes[0].Pformat("    ", 0); output != expected {
+		t.Error("math role failed:\n" + output)
+	}
+}
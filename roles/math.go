@@ -0,0 +1,32 @@
+package roles
+
+/*
+The "math" role of Python docutils roles.py: inline LaTeX math.
+*/
+
+import (
+	"strings"
+
+	rst "github.com/siongui/go-rst"
+	"github.com/siongui/go-rst/directives"
+)
+
+func init() {
+	RegisterRole("math", MathRole, map[string]directives.OptionConverter{
+		"class": directives.ClassOption,
+	})
+}
+
+/*
+   Inline math. The LaTeX code is taken from `rawtext`, as backslash
+   escapes are not processed in math.
+*/
+func MathRole(name, rawtext, text string, lineno int, inliner *Inliner, options map[string]interface{}, content []string) ([]rst.Node, []rst.Node) {
+	if parts := strings.Split(rawtext, "`"); len(parts) > 1 {
+		text = parts[1]
+	}
+	node := &rst.Element{}
+	node.Init("math", rawtext, text)
+	setClasses(node, options)
+	return []rst.Node{node}, nil
+}
@@ -0,0 +1,46 @@
+package roles
+
+/*
+The "raw" role of Python docutils roles.py: inline pass-through content.
+*/
+
+import (
+	rst "github.com/siongui/go-rst"
+	"github.com/siongui/go-rst/directives"
+)
+
+func init() {
+	RegisterRole("raw", RawRole, map[string]directives.OptionConverter{
+		"class":  directives.ClassOption,
+		"format": directives.Unchanged,
+	})
+}
+
+/*
+   Inline pass-through content for the output format given by the "format"
+   option. The role cannot be used directly: a custom role with a format
+   must be derived from it with the "role" directive, e.g. "raw-html".
+   Like the "raw" directive, it is disabled unless the "RawEnabled" setting
+   is true.
+*/
+func RawRole(name, rawtext, text string, lineno int, inliner *Inliner, options map[string]interface{}, content []string) ([]rst.Node, []rst.Node) {
+	if !inliner.Document.Settings().RawEnabled {
+		msg := inliner.Reporter.Warning("raw (and derived) roles disabled", "", lineno)
+		prb := inliner.Problematic(rawtext, rawtext, msg)
+		return []rst.Node{prb}, []rst.Node{msg}
+	}
+	format, _ := options["format"].(string)
+	if format == "" {
+		msg := inliner.Reporter.Error("No format (Writer name) is associated with this role: \""+name+"\".\n"+
+			"The \"raw\" role cannot be used directly.\n"+
+			"Instead, use the \"role\" directive to create a new role with an associated format.", "", lineno)
+		prb := inliner.Problematic(rawtext, rawtext, msg)
+		return []rst.Node{prb}, []rst.Node{msg}
+	}
+	node := &rst.Element{}
+	node.Init("raw", rawtext, text)
+	node.Set("format", format)
+	setClasses(node, options)
+	node.SetLine(lineno)
+	return []rst.Node{node}, nil
+}
@@ -0,0 +1,361 @@
+/*
+Package roles implements interpreted text roles of Python docutils: the
+role registry and the role functions.
+
+URL of Python source code:
+http://sourceforge.net/p/docutils/code/HEAD/tree/trunk/docutils/docutils/parsers/rst/roles.py
+
+The inline markup parser of package parsers/restructuredtext recognizes
+:name:`text` and calls `Inliner.Interpreted()`. The `Inliner` type here
+carries the context a role function needs.
+
+Roles are looked up in the roles defined in the document (with the "role"
+directive) first, then in the roles registered with `RegisterRole()`.
+Interpreted text without an explicit role uses the role set with the
+"default-role" directive, or `DefaultInterpretedRole`.
+*/
+package roles
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	rst "github.com/siongui/go-rst"
+	"github.com/siongui/go-rst/directives"
+)
+
+/*
+   Interpreted text role function.
+
+   Parameters:
+
+   - `name`: The role name actually used in the document.
+   - `rawtext`: A string containing the entire interpreted text construct.
+     Return it as a `problematic` node linked to a system message if there
+     is a problem.
+   - `text`: The interpreted text content, backslash escapes processed.
+   - `lineno`: The line number where the interpreted text begins.
+   - `inliner`: The `Inliner` object that called the role function. It
+     defines the following useful attributes: `Document`, `Reporter`,
+     `Parent`, and the `Problematic()` method.
+   - `options`: A dictionary of directive options for customization, to be
+     interpreted by the role function. Used for additional attributes for
+     the generated elements and other functionality.
+   - `content`: A list of strings, the directive content for
+     customization ("role" directive). To be interpreted by the role
+     function.
+
+   Return a list of nodes which will be inserted into the document tree at
+   the point where the interpreted role was encountered (can be empty), and
+   a list of system messages (can be empty).
+*/
+type Role func(name, rawtext, text string, lineno int, inliner *Inliner, options map[string]interface{}, content []string) ([]rst.Node, []rst.Node)
+
+// The context of a role function call.
+type Inliner struct {
+	Document *rst.Document
+	Reporter *rst.Reporter
+
+	// The element the role's nodes will be added to.
+	Parent *rst.Element
+}
+
+func (i *Inliner) Init(document *rst.Document, parent *rst.Element) {
+	i.Document = document
+	i.Reporter = document.Reporter()
+	i.Parent = parent
+}
+
+/*
+   Return a `problematic` element holding `rawsource`, linked to the system
+   message `message` (and back).
+*/
+func (i *Inliner) Problematic(text, rawsource string, message *rst.Element) *rst.Element {
+	msgid := i.Document.SetID(message, i.Parent)
+	problematic := &rst.Element{}
+	problematic.Init("problematic", rawsource, text)
+	problematic.Set("refid", msgid)
+	prbid := i.Document.SetID(problematic, nil)
+	message.Backrefs = append(message.Backrefs, prbid)
+	return problematic
+}
+
+// A registered role: the role function and its option specification
+// (used to customize the role with the "role" directive).
+type registeredRole struct {
+	role       Role
+	optionSpec map[string]directives.OptionConverter
+}
+
+// Mapping of canonical role names to role functions.
+var roleRegistry = map[string]registeredRole{}
+
+/*
+   Register an interpreted text role by its canonical name. `optionSpec`
+   maps the names of the options the role accepts to their conversion
+   functions; it may be nil.
+*/
+func RegisterRole(name string, role Role, optionSpec map[string]directives.OptionConverter) {
+	roleRegistry[strings.ToLower(name)] = registeredRole{role, optionSpec}
+}
+
+// Locate and return a role function from its name (case-insensitive).
+func Lookup(name string) (Role, bool) {
+	r, ok := roleRegistry[strings.ToLower(name)]
+	return r.role, ok
+}
+
+// The role of interpreted text without an explicit role.
+const DefaultInterpretedRole = "title-reference"
+
+/*
+   Locate the role `name` for `document`: a role defined in the document,
+   or a registered role. The empty name stands for the default role.
+*/
+func lookupRole(document *rst.Document, name string) (registeredRole, bool) {
+	if name == "" {
+		name = document.DefaultRole()
+		if name == "" {
+			name = DefaultInterpretedRole
+		}
+	}
+	if r, ok := document.LocalRole(name); ok {
+		return r.(registeredRole), true
+	}
+	r, ok := roleRegistry[strings.ToLower(name)]
+	return r, ok
+}
+
+/*
+   Return the role function `name` as used in the document: a role defined
+   in the document or a registered role. The empty name stands for the
+   default role.
+*/
+func (i *Inliner) Role(name string) (Role, bool) {
+	r, ok := lookupRole(i.Document, name)
+	return r.role, ok
+}
+
+/*
+   Process interpreted text `text` with the role `role` ("" for the
+   default role). An unknown role results in a `problematic` node and an
+   error.
+*/
+func (i *Inliner) Interpreted(rawsource, text, role string, lineno int) ([]rst.Node, []rst.Node) {
+	roleFn, ok := i.Role(role)
+	if !ok {
+		msg := i.Reporter.Error("Unknown interpreted text role \""+role+"\".", "", lineno)
+		return []rst.Node{i.Problematic(rawsource, rawsource, msg)}, []rst.Node{msg}
+	}
+	return roleFn(role, rawsource, text, lineno, i, nil, nil)
+}
+
+/*
+   Return a role deriving from `base` with preset `options` (overridden by
+   the options of the call) and `content` (prepended to the content of the
+   call). Used by the "role" directive.
+*/
+func CustomRole(base Role, options map[string]interface{}, content []string) Role {
+	return func(name, rawtext, text string, lineno int, inliner *Inliner, opts map[string]interface{}, c []string) ([]rst.Node, []rst.Node) {
+		merged := map[string]interface{}{}
+		for key, value := range options {
+			merged[key] = value
+		}
+		for key, value := range opts {
+			merged[key] = value
+		}
+		return base(name, rawtext, text, lineno, inliner, merged, append(append([]string(nil), content...), c...))
+	}
+}
+
+/*
+   Return a role creating an element `tagname` holding the interpreted text.
+   The "class" option sets the classes of the element.
+*/
+func GenericRole(tagname string) Role {
+	return func(name, rawtext, text string, lineno int, inliner *Inliner, options map[string]interface{}, content []string) ([]rst.Node, []rst.Node) {
+		node := &rst.Element{}
+		node.Init(tagname, rawtext, text)
+		setClasses(node, options)
+		return []rst.Node{node}, nil
+	}
+}
+
+// Add the classes of the "class" option to `node`.
+func setClasses(node *rst.Element, options map[string]interface{}) {
+	if value, ok := options["class"]; ok {
+		node.Classes = append(node.Classes, value.([]string)...)
+	}
+}
+
+var classOptionSpec = map[string]directives.OptionConverter{
+	"class": directives.ClassOption,
+}
+
+func init() {
+	for _, r := range []struct {
+		names   []string
+		tagname string
+	}{
+		{[]string{"abbreviation", "ab"}, "abbreviation"},
+		{[]string{"acronym", "ac"}, "acronym"},
+		{[]string{"emphasis"}, "emphasis"},
+		{[]string{"literal"}, "literal"},
+		{[]string{"strong"}, "strong"},
+		{[]string{"subscript", "sub"}, "subscript"},
+		{[]string{"superscript", "sup"}, "superscript"},
+		{[]string{"title-reference", "title", "t"}, "title_reference"},
+	} {
+		for _, name := range r.names {
+			RegisterRole(name, GenericRole(r.tagname), classOptionSpec)
+		}
+	}
+	for _, name := range []string{"pep-reference", "pep"} {
+		RegisterRole(name, PepReferenceRole, classOptionSpec)
+	}
+	for _, name := range []string{"rfc-reference", "rfc"} {
+		RegisterRole(name, RfcReferenceRole, classOptionSpec)
+	}
+}
+
+/*
+   Reference to a Python Enhancement Proposal, e.g. :pep:`8`. The URL is
+   built from the "PepBaseURL" and "PepFileURLTemplate" settings.
+*/
+func PepReferenceRole(name, rawtext, text string, lineno int, inliner *Inliner, options map[string]interface{}, content []string) ([]rst.Node, []rst.Node) {
+	pepnum, err := strconv.Atoi(text)
+	if err != nil || pepnum < 0 || pepnum > 9999 {
+		msg := inliner.Reporter.Error("PEP number must be a number from 0 to 9999; \""+text+"\" is invalid.", "", lineno)
+		prb := inliner.Problematic(rawtext, rawtext, msg)
+		return []rst.Node{prb}, []rst.Node{msg}
+	}
+	settings := inliner.Document.Settings()
+	node := &rst.Element{}
+	node.Init("reference", rawtext, "PEP "+text)
+	node.Set("refuri", settings.PepBaseURL+fmt.Sprintf(settings.PepFileURLTemplate, pepnum))
+	setClasses(node, options)
+	return []rst.Node{node}, nil
+}
+
+/*
+   Reference to a Request For Comments, e.g. :rfc:`2822` or, for a section,
+   :rfc:`2822#section-3.3`. The URL is built from the "RfcBaseURL"
+   setting.
+*/
+func RfcReferenceRole(name, rawtext, text string, lineno int, inliner *Inliner, options map[string]interface{}, content []string) ([]rst.Node, []rst.Node) {
+	parts := strings.SplitN(text, "#", 2)
+	rfcnum, err := strconv.Atoi(parts[0])
+	if err != nil || rfcnum < 1 {
+		msg := inliner.Reporter.Error("RFC number must be a number greater than or equal to 1; \""+text+"\" is invalid.", "", lineno)
+		prb := inliner.Problematic(rawtext, rawtext, msg)
+		return []rst.Node{prb}, []rst.Node{msg}
+	}
+	ref := inliner.Document.Settings().RfcBaseURL + fmt.Sprintf("rfc%d.html", rfcnum)
+	if len(parts) == 2 {
+		ref += "#" + parts[1]
+	}
+	node := &rst.Element{}
+	node.Init("reference", rawtext, "RFC "+strconv.Itoa(rfcnum))
+	node.Set("refuri", ref)
+	setClasses(node, options)
+	return []rst.Node{node}, nil
+}
+
+func init() {
+	directives.Register("role", RoleDirective)
+	directives.Register("default-role", DefaultRoleDirective)
+}
+
+/*
+   Base role of custom roles without an explicit base role: an `inline`
+   element with the classes of the "class" option.
+*/
+var genericCustomRole = GenericRole("inline")
+
+var simplename = `(?:(?:[a-zA-Z0-9]|[a-zA-Z0-9][-._+:a-zA-Z0-9]*[a-zA-Z0-9]))`
+
+var roleArgumentPattern = regexp.MustCompile(`^(` + simplename + `)\s*(\(\s*(` + simplename + `)\s*\)\s*)?$`)
+
+/*
+   Dynamically create and register a custom interpreted text role in the
+   document, e.g.::
+
+       .. role:: custom
+       .. role:: raw-html(raw)
+          :format: html
+
+   The first line gives the new role name, optionally followed by the base
+   role name in parentheses (default: a generic `inline` element); the
+   options and content customize the base role. Unless given, the "class"
+   option is the role name.
+*/
+var RoleDirective = &directives.Definition{
+	HasContent: true,
+	Run:        runRoleDirective,
+}
+
+func runRoleDirective(d *directives.Directive) ([]rst.Node, error) {
+	if d.ContentOffset > d.Lineno || len(d.Content) == 0 {
+		return nil, d.Error("\"" + d.Name + "\" directive requires arguments on the first line.")
+	}
+	args := d.Content[0]
+	match := roleArgumentPattern.FindStringSubmatch(args)
+	if match == nil {
+		return nil, d.Error("\"" + d.Name + "\" directive arguments not valid role names: \"" + args + "\".")
+	}
+	newRoleName := match[1]
+	baseRoleName := match[3]
+	base := registeredRole{genericCustomRole, classOptionSpec}
+	if baseRoleName != "" {
+		var ok bool
+		base, ok = lookupRole(d.Document, baseRoleName)
+		if !ok {
+			return nil, d.Error("Unknown interpreted text role \"" + baseRoleName + "\".")
+		}
+	}
+	optionSpec := map[string]directives.OptionConverter{}
+	for name, converter := range base.optionSpec {
+		optionSpec[name] = converter
+	}
+	converted := &directives.Definition{OptionSpec: optionSpec, HasContent: true}
+	_, options, content, _, err := directives.ParseDirectiveBlock(d.Content[1:], d.ContentOffset, converted)
+	if err != nil {
+		return nil, d.Error("Error in \"" + d.Name + "\" directive:\n" + err.Error() + ".")
+	}
+	if _, ok := options["class"]; !ok {
+		value, err := directives.ClassOption(newRoleName)
+		if err != nil {
+			return nil, d.Error("Invalid argument for \"" + d.Name + "\" directive:\n" + err.Error() + ".")
+		}
+		options["class"] = value
+	}
+	role := CustomRole(base.role, options, content)
+	d.Document.NoteLocalRole(newRoleName, registeredRole{role, optionSpec})
+	return nil, nil
+}
+
+/*
+   Set the default interpreted text role, e.g. ``.. default-role:: math``.
+   Without argument, the standard default (`DefaultInterpretedRole`) is
+   restored.
+*/
+var DefaultRoleDirective = &directives.Definition{
+	OptionalArguments: 1,
+	Run:               runDefaultRoleDirective,
+}
+
+func runDefaultRoleDirective(d *directives.Directive) ([]rst.Node, error) {
+	if len(d.Arguments) == 0 {
+		// restore the "default" default role
+		d.Document.SetDefaultRole("")
+		return nil, nil
+	}
+	roleName := d.Arguments[0]
+	if _, ok := lookupRole(d.Document, roleName); !ok {
+		return nil, d.Error("Unknown interpreted text role \"" + roleName + "\".")
+	}
+	d.Document.SetDefaultRole(roleName)
+	return nil, nil
+}
@@ -0,0 +1,124 @@
+package roles
+
+import (
+	"testing"
+
+	rst "github.com/siongui/go-rst"
+	"github.com/siongui/go-rst/directives"
+)
+
+func TestRawRole(t *testing.T) {
+	settings := &rst.Settings{}
+	settings.Init()
+	settings.WarningStream = nil
+	document := rst.NewDocument("test", settings)
+	paragraph := &rst.Element{}
+	paragraph.Init("paragraph", "", "")
+	document.Append(paragraph)
+	inliner := &Inliner{}
+	inliner.Init(document, paragraph)
+
+	role, _ := Lookup("raw")
+	options := map[string]interface{}{"format": "html"}
+	nodes, messages := role("raw-html", ":raw-html:`<br>`", "<br>", 1, inliner, options, nil)
+	if nodes[0].TagName() != "problematic" || messages[0].AsText() != "test:1: (WARNING/2) raw (and derived) roles disabled" {
+		t.Error("disabled raw role not reported")
+	}
+
+	settings.RawEnabled = true
+	nodes, _ = role("raw-html", ":raw-html:`<br>`", "<br>", 2, inliner, options, nil)
+	if output := nodes[0].Pformat("    ", 0); output != "<raw format=\"html\">\n    <br>\n" {
+		t.Error("raw role failed:\n" + output)
+	}
+	_, messages = role("raw", ":raw:`<br>`", "<br>", 3, inliner, nil, nil)
+	if len(messages) != 1 || messages[0].(*rst.Element).Get("type") != "ERROR" {
+		t.Error("raw role without format not reported")
+	}
+}
+
+func TestInterpreted(t *testing.T) {
+	settings := &rst.Settings{}
+	settings.Init()
+	settings.WarningStream = nil
+	settings.RawEnabled = true
+	document := rst.NewDocument("test", settings)
+	for _, block := range [][]string{
+		{"custom"},
+		{"KeyWord(strong)", ":class: kw"},
+		{"raw-html(raw)", ":format: html"},
+		{"bad(unknown)"},
+	} {
+		document.Extend(directives.Run(document, &document.Element, "role", block, 1, ".. role:: "+block[0])...)
+	}
+	paragraph := &rst.Element{}
+	paragraph.Init("paragraph", "", "")
+	document.Append(paragraph)
+	inliner := &Inliner{}
+	inliner.Init(document, paragraph)
+
+	interpret := func(role, text string) {
+		rawtext := ":" + role + ":`" + text + "`"
+		if role == "" {
+			rawtext = "`" + text + "`"
+		}
+		nodes, messages := inliner.Interpreted(rawtext, text, role, 5)
+		paragraph.Extend(nodes...)
+		document.Extend(messages...)
+	}
+	interpret("", "Book")
+	interpret("sup", "2")
+	interpret("ab", "HTML")
+	interpret("pep", "8")
+	interpret("RFC", "2822#section-3.3")
+	interpret("rfc", "x")
+	interpret("custom", "c")
+	interpret("keyword", "func")
+	interpret("raw-html", "<br>")
+	interpret("nonexistent", "n")
+	document.Extend(directives.Run(document, &document.Element, "default-role", []string{"emphasis"}, 7, "")...)
+	interpret("", "emphasized")
+	document.Extend(directives.Run(document, &document.Element, "default-role", nil, 9, "")...)
+	interpret("", "cited")
+
+	expected := `<document source="test">
+    <system_message level="3" line="1" source="test" type="ERROR">
+        <paragraph>
+            Unknown interpreted text role "unknown".
+        <literal_block>
+            .. role:: bad(unknown)
+    <paragraph>
+        <title_reference>
+            Book
+        <superscript>
+            2
+        <abbreviation>
+            HTML
+        <reference refuri="https://peps.python.org/pep-0008">
+            PEP 8
+        <reference refuri="https://tools.ietf.org/html/rfc2822.html#section-3.3">
+            RFC 2822
+        <problematic ids="id2" refid="id1">
+            :rfc:` + "`x`" + `
+        <inline classes="custom">
+            c
+        <strong classes="kw">
+            func
+        <raw classes="raw-html" format="html">
+            <br>
+        <problematic ids="id4" refid="id3">
+            :nonexistent:` + "`n`" + `
+        <emphasis>
+            emphasized
+        <title_reference>
+            cited
+    <system_message backrefs="id2" ids="id1" level="3" line="5" source="test" type="ERROR">
+        <paragraph>
+            RFC number must be a number greater than or equal to 1; "x" is invalid.
+    <system_message backrefs="id4" ids="id3" level="3" line="5" source="test" type="ERROR">
+        <paragraph>
+            Unknown interpreted text role "nonexistent".
+`
+	if output := document.Pformat("    ", 0); output != expected {
+		t.Error("interpreted text failed:\n" + output)
+	}
+}
@@ -0,0 +1,496 @@
+package rst
+
+/*
+Runtime settings, the values of the command-line options and configuration
+file entries in Python docutils
+
+URL of Python source code:
+http://sourceforge.net/p/docutils/code/HEAD/tree/trunk/docutils/docutils/frontend.py
+*/
+
+import (
+	"bufio"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+/*
+   Runtime settings of the reader, parser, transforms and writers.
+
+   The settings with a "config" tag can be set by name (the name of the
+   Python docutils setting), from configuration files or command-line
+   options: see `Set()` and `ReadConfig()`.
+*/
+type Settings struct {
+	// Report system messages at or higher than this level.
+	ReportLevel int `config:"report_level"`
+
+	// Halt execution at system messages at or above this level.
+	HaltLevel int `config:"halt_level"`
+
+	// Enable debug-level system messages and diagnostics.
+	Debug bool `config:"debug"`
+
+	// Where system messages are written. Nil to suppress them.
+	WarningStream io.Writer
+
+	// The encoding of input files: "utf-8" (an initial byte order mark is
+	// ignored) or "latin-1".
+	InputEncoding string `config:"input_encoding"`
+
+	// Number of spaces for a hard tab in the input.
+	TabWidth int `config:"tab_width"`
+
+	// Specify the language (as BCP 47 language tag).
+	LanguageCode string `config:"language_code"`
+
+	// Prepend this string to all ids generated by Docutils.
+	IDPrefix string `config:"id_prefix"`
+
+	// Prefix for ids automatically generated by Docutils.
+	AutoIDPrefix string `config:"auto_id_prefix"`
+
+	// Enable backlinks from section headers to table of contents entries
+	// ("entry"), to the top of the TOC ("top"), or disable them ("").
+	TocBacklinks string `config:"toc_backlinks"`
+
+	// Enable automatic section numbering by Docutils; if false, the
+	// `sectnum` directive options are stored in the Sectnum* fields
+	// below for the writer.
+	SectnumXform bool `config:"sectnum_xform"`
+
+	// Section numbering parameters passed on to the writer when
+	// SectnumXform is false.
+	SectnumDepth  int
+	SectnumStart  int
+	SectnumPrefix string
+	SectnumSuffix string
+
+	// Token names used by the "code" directive and role: "short"
+	// (Pygments CSS class names, the default, so that the output works
+	// with Pygments style sheets) or "long", or "none" for no
+	// highlighting.
+	SyntaxHighlight string `config:"syntax_highlight"`
+
+	// Tokenizer of code for syntax highlighting; nil for the
+	// `DefaultHighlighter`.
+	Highlighter Highlighter
+
+	// HTML writer: level of the first section header (the document title
+	// is always <h1>).
+	InitialHeaderLevel int `config:"initial_header_level"`
+
+	// HTML writer: remove the paragraph tags in simple lists, field lists
+	// and definition lists.
+	CompactLists      bool `config:"compact_lists"`
+	CompactFieldLists bool `config:"compact_field_lists"`
+
+	// HTML writer: comma-separated URLs of the style sheets linked from
+	// the HTML head.
+	Stylesheet string `config:"stylesheet"`
+
+	// HTML writer: path of an html/template file for the whole page, empty
+	// for the built-in template. See `html.TemplateData` for its fields.
+	Template string `config:"template"`
+
+	// Format for footnote references: "superscript" or "brackets".
+	FootnoteReferences string `config:"footnote_references"`
+
+	// Link from footnotes and citations to their references.
+	FootnoteBacklinks bool `config:"footnote_backlinks"`
+
+	// Comma-separated class values added to tables in HTML output.
+	TableStyle string `config:"table_style"`
+
+	// HTML writer: the format of math elements, optionally followed by
+	// whitespace and an option: "MathML" (converted from LaTeX without
+	// JavaScript), "MathJax URL" or "KaTeX URL" (LaTeX rendered by the
+	// script found at URL), or "LaTeX" (the LaTeX code as is).
+	MathOutput string `config:"math_output"`
+
+	// Enable the "raw" directive and role. Disabled by default, as raw
+	// content is passed untouched to the output (e.g. <script> elements in
+	// HTML): enable it for trusted sources only. With raw disabled, a
+	// warning is reported instead.
+	RawEnabled bool `config:"raw_enabled"`
+
+	// Enable directives inserting the contents of external files (e.g.
+	// the "file" option of "raw"). Disable it for untrusted sources.
+	FileInsertionEnabled bool `config:"file_insertion_enabled"`
+
+	// Base URL and file name template of PEP references (:pep: role).
+	PepBaseURL         string `config:"pep_base_url"`
+	PepFileURLTemplate string `config:"pep_file_url_template"`
+
+	// Base URL of RFC references (:rfc: role).
+	RfcBaseURL string `config:"rfc_base_url"`
+
+	// Specify the document title as metadata (default: the title of the
+	// document, or the source file name).
+	Title string `config:"title"`
+
+	// Promote a lone top-level section title to document title, and a lone
+	// subsection title to document subtitle (DocTitle transform).
+	DoctitleXform bool `config:"doctitle_xform"`
+
+	// Transform a leading field list of bibliographic fields into a
+	// docinfo element (DocInfo transform).
+	DocinfoXform bool `config:"docinfo_xform"`
+
+	// Promote lone subsection titles to section subtitles (SectionSubTitle
+	// transform).
+	SectsubtitleXform bool `config:"sectsubtitle_xform"`
+
+	// Change straight quotation marks to typographic form (SmartQuotes
+	// transform): "no", "yes", or "alt" for the alternative quotes of the
+	// language (e.g. guillemets in German). "--", "---" and "..." are
+	// converted to en dash, em dash and ellipsis too.
+	SmartQuotes string `config:"smart_quotes"`
+
+	// Additional or overriding quote characters for SmartQuotes, mapping a
+	// language tag to a string of four characters (primary opening and
+	// closing, secondary opening and closing quote), or to four strings
+	// separated by colons.
+	SmartquotesLocales map[string]string `config:"smartquotes_locales"`
+
+	// Pseudo-XML writer: show the text nodes as "<#text>" elements, with
+	// the text lines quoted, to make whitespace visible.
+	Detailed bool `config:"detailed"`
+
+	// Docutils XML writer: generate an XML declaration, and a DOCTYPE
+	// declaration referencing the Docutils Generic DTD.
+	XMLDeclaration     bool `config:"xml_declaration"`
+	DoctypeDeclaration bool `config:"doctype_declaration"`
+
+	// Docutils XML writer: generate XML with newlines before and after
+	// tags, and indent it (for readability).
+	Newlines bool `config:"newlines"`
+	Indents  bool `config:"indents"`
+
+	// LaTeX writer: the document class ("article", "report", "book", ...),
+	// its comma-separated options, and the paper size option (e.g.
+	// "a4paper"; empty for the class default).
+	DocumentClass   string `config:"documentclass"`
+	DocumentOptions string `config:"documentoptions"`
+	PaperSize       string `config:"paper_size"`
+
+	// LaTeX writer: path of the template file, empty for the built-in
+	// template. Template variables ($body, $titledata, ...) are the names
+	// of the writer parts.
+	LatexTemplate string `config:"latex_template"`
+
+	// LaTeX writer: code inserted into the preamble, after the required
+	// packages.
+	LatexPreamble string `config:"latex_preamble"`
+
+	// LaTeX writer: environment for literal blocks, "verbatim" or
+	// "lstlisting" (listings package). Literal blocks with inline markup
+	// always use "alltt".
+	LiteralBlockEnv string `config:"literal_block_env"`
+
+	// reStructuredText writer: the adornment characters of the section
+	// levels (the document title and subtitle are overlined with the
+	// first two), and the width paragraphs are re-wrapped to (0 keeps the
+	// line breaks).
+	SectionAdornments string `config:"section_adornments"`
+	WrapWidth         int    `config:"wrap_width"`
+
+	// Text writer: the width text is wrapped to (0 for no wrapping), and
+	// whether headings and inline markup are styled with ANSI escape
+	// sequences, for terminals.
+	TextWidth  int  `config:"text_width"`
+	AnsiStyles bool `config:"ansi_styles"`
+}
+
+// Set the Python docutils default values.
+func (s *Settings) Init() {
+	s.ReportLevel = WarningLevel
+	s.HaltLevel = SevereLevel
+	s.WarningStream = os.Stderr
+	s.InputEncoding = "utf-8"
+	s.TabWidth = 8
+	s.LanguageCode = "en"
+	s.AutoIDPrefix = "id"
+	s.TocBacklinks = "entry"
+	s.SectnumXform = true
+	s.SyntaxHighlight = "short"
+	s.InitialHeaderLevel = 2
+	s.CompactLists = true
+	s.CompactFieldLists = true
+	s.FootnoteReferences = "brackets"
+	s.FootnoteBacklinks = true
+	s.MathOutput = "MathML"
+	s.FileInsertionEnabled = true
+	s.PepBaseURL = "https://peps.python.org/"
+	s.PepFileURLTemplate = "pep-%04d"
+	s.RfcBaseURL = "https://tools.ietf.org/html/"
+	s.DoctitleXform = true
+	s.DocinfoXform = true
+	s.SmartQuotes = "no"
+	s.XMLDeclaration = true
+	s.DoctypeDeclaration = true
+	s.DocumentClass = "article"
+	s.LiteralBlockEnv = "verbatim"
+	s.SectionAdornments = "=-~^\"'`+*#"
+	s.TextWidth = 80
+}
+
+type SettingError struct {
+	msg string
+}
+
+func (e *SettingError) Error() string {
+	return e.msg
+}
+
+// Values of the report and halt levels, besides the level numbers.
+var levelNames = map[string]int{
+	"debug":   DebugLevel,
+	"info":    InfoLevel,
+	"warning": WarningLevel,
+	"error":   ErrorLevel,
+	"severe":  SevereLevel,
+	"none":    SevereLevel + 1,
+}
+
+// Valid values of the settings with a fixed set of values.
+var settingChoices = map[string][]string{
+	"footnote_references": {"superscript", "brackets"},
+	"input_encoding":      {"utf-8", "utf8", "utf-8-sig", "latin-1", "latin1", "iso-8859-1"},
+	"literal_block_env":   {"verbatim", "lstlisting"},
+	"smart_quotes":        {"yes", "no", "alt", "alternative"},
+	"syntax_highlight":    {"long", "short", "none"},
+	"toc_backlinks":       {"entry", "top", "none"},
+}
+
+// Python docutils boolean values.
+var booleans = map[string]bool{
+	"1": true, "yes": true, "true": true, "on": true,
+	"0": false, "no": false, "false": false, "off": false,
+}
+
+/*
+   Setting names specific to configuration file sections: in Python
+   docutils, "template" in the LaTeX writer sections is the LaTeX template.
+*/
+var sectionSettings = map[string]map[string]string{
+	"latex writers":  {"template": "latex_template"},
+	"latex2e writer": {"template": "latex_template"},
+}
+
+// Return the setting name `name` normalized: lowercase, "-" as "_".
+func settingName(name string) string {
+	return strings.Replace(strings.ToLower(strings.TrimSpace(name)), "-", "_", -1)
+}
+
+// Return the field of the setting `name` (normalized), false if unknown.
+func (s *Settings) field(name string) (reflect.Value, bool) {
+	v := reflect.ValueOf(s).Elem()
+	for i := 0; i < v.NumField(); i++ {
+		if v.Type().Field(i).Tag.Get("config") == name {
+			return v.Field(i), true
+		}
+	}
+	return reflect.Value{}, false
+}
+
+/*
+   Set the setting `name` (the Python docutils name, e.g. "report_level" or
+   "report-level") from the string `value`, as found in configuration
+   files and command-line options.
+
+   Boolean settings take "yes", "true", "on", "1" and "no", "false", "off",
+   "0"; the report and halt levels take a level number or name ("info",
+   "warning", ..., "none"). A `SettingError` is returned for unknown
+   settings and invalid values.
+*/
+func (s *Settings) Set(name, value string) error {
+	name = settingName(name)
+	value = strings.TrimSpace(value)
+	field, ok := s.field(name)
+	if !ok {
+		return &SettingError{"unknown setting \"" + name + "\""}
+	}
+	invalid := func(expected string) error {
+		return &SettingError{"invalid value for \"" + name + "\": \"" + value + "\" (expected " + expected + ")"}
+	}
+	lower := strings.ToLower(value)
+
+	if choices, ok := settingChoices[name]; ok {
+		if b, ok := booleans[lower]; ok && name == "smart_quotes" {
+			lower = "no"
+			if b {
+				lower = "yes"
+			}
+		}
+		valid := false
+		for _, choice := range choices {
+			valid = valid || lower == choice
+		}
+		if !valid {
+			return invalid("one of " + strings.Join(choices, ", "))
+		}
+		if name == "toc_backlinks" && lower == "none" {
+			lower = ""
+		}
+		field.SetString(lower)
+		return nil
+	}
+	switch field.Kind() {
+	case reflect.Bool:
+		b, ok := booleans[lower]
+		if !ok {
+			return invalid("a boolean")
+		}
+		field.SetBool(b)
+	case reflect.Int:
+		if name == "report_level" || name == "halt_level" {
+			if level, ok := levelNames[lower]; ok {
+				field.SetInt(int64(level))
+				return nil
+			}
+		}
+		n, err := strconv.Atoi(value)
+		if err != nil || n < 0 || name == "tab_width" && n == 0 {
+			return invalid("a positive number")
+		}
+		field.SetInt(int64(n))
+	case reflect.Map:
+		// "language: quotes" entries, separated by commas or newlines
+		locales := map[string]string{}
+		for _, entry := range strings.FieldsFunc(value, func(r rune) bool { return r == ',' || r == '\n' }) {
+			parts := strings.SplitN(entry, ":", 2)
+			if len(parts) != 2 || strings.TrimSpace(parts[0]) == "" {
+				return invalid("\"language: quotes\" entries")
+			}
+			locales[strings.TrimSpace(parts[0])] = strings.TrimSpace(parts[1])
+		}
+		field.Set(reflect.ValueOf(locales))
+	default:
+		field.SetString(value)
+	}
+	return nil
+}
+
+/*
+   Return the configuration files read by default, in order: the files
+   listed in the DOCUTILSCONFIG environment variable (separated by the
+   path list separator) if it is set, otherwise "/etc/docutils.conf",
+   "./docutils.conf" and "~/.docutils".
+*/
+func ConfigFiles() []string {
+	if env, ok := os.LookupEnv("DOCUTILSCONFIG"); ok {
+		return filepath.SplitList(env)
+	}
+	files := []string{"/etc/docutils.conf", "./docutils.conf"}
+	if home, err := os.UserHomeDir(); err == nil {
+		files = append(files, filepath.Join(home, ".docutils"))
+	}
+	return files
+}
+
+/*
+   Read the configuration files `paths`, in order, skipping the missing
+   ones. See `ReadConfig()` for `sections`.
+*/
+func (s *Settings) ReadConfigFiles(paths []string, sections []string) error {
+	for _, path := range paths {
+		f, err := os.Open(path)
+		if os.IsNotExist(err) {
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		err = s.ReadConfig(f, path, sections)
+		f.Close()
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+/*
+   Read the settings of a configuration file in the format of Python
+   docutils (docutils.conf), an INI file:
+
+       [general]
+       report_level: error
+
+       [html writers]
+       stylesheet = style.css
+
+   Only the settings of `sections` are applied, in the order of
+   `sections`: later sections override earlier ones (e.g. "general", then
+   the sections of the reader, parser and writer in use, as returned by
+   `core.ConfigSections()`). Lines starting with "#" or ";" are comments,
+   indented lines continue the value of the previous line. Unknown
+   settings are ignored, as they may be meant for Python docutils; a
+   `SettingError` locating the line ("filename:line: ...") is returned for
+   syntax errors and invalid values.
+*/
+func (s *Settings) ReadConfig(r io.Reader, filename string, sections []string) error {
+	type entry struct {
+		name, value string
+		line        int
+	}
+	entries := map[string][]*entry{}
+	section := ""
+	var last *entry
+	scanner := bufio.NewScanner(r)
+	for lineno := 1; scanner.Scan(); lineno++ {
+		line := strings.TrimRight(scanner.Text(), " \t\r")
+		trimmed := strings.TrimSpace(line)
+		location := filename + ":" + strconv.Itoa(lineno) + ": "
+		switch {
+		case trimmed == "" || trimmed[0] == '#' || trimmed[0] == ';':
+			continue
+		case line[0] == ' ' || line[0] == '\t':
+			if last == nil {
+				return &SettingError{location + "unexpected indented line"}
+			}
+			last.value += "\n" + trimmed
+		case trimmed[0] == '[':
+			if !strings.HasSuffix(trimmed, "]") {
+				return &SettingError{location + "invalid section header"}
+			}
+			section = strings.ToLower(strings.TrimSpace(trimmed[1 : len(trimmed)-1]))
+			last = nil
+		default:
+			i := strings.IndexAny(line, ":=")
+			if i < 1 {
+				return &SettingError{location + "expected \"name: value\" or \"name = value\""}
+			}
+			if section == "" {
+				return &SettingError{location + "setting outside of a section"}
+			}
+			last = &entry{settingName(line[:i]), strings.TrimSpace(line[i+1:]), lineno}
+			entries[section] = append(entries[section], last)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return err
+	}
+
+	for _, section := range sections {
+		section = strings.ToLower(section)
+		for _, e := range entries[section] {
+			name := e.name
+			if alias, ok := sectionSettings[section][name]; ok {
+				name = alias
+			}
+			if _, ok := s.field(name); !ok {
+				continue
+			}
+			if err := s.Set(name, e.value); err != nil {
+				return &SettingError{filename + ":" + strconv.Itoa(e.line) + ": [" + section + "] " + err.Error()}
+			}
+		}
+	}
+	return nil
+}
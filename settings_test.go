@@ -0,0 +1,93 @@
+package rst
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestSet(t *testing.T) {
+	settings := &Settings{}
+	settings.Init()
+	for name, value := range map[string]string{
+		"report-level":        "error",
+		"halt_level":          "3",
+		"raw_enabled":         "yes",
+		"TOC_BACKLINKS":       "none",
+		"smart_quotes":        "true",
+		"tab_width":           "4",
+		"stylesheet":          " a.css,b.css ",
+		"smartquotes_locales": "de: „“‚‘, fr: « : » :‹ : ›",
+	} {
+		if err := settings.Set(name, value); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if settings.ReportLevel != ErrorLevel || settings.HaltLevel != ErrorLevel || !settings.RawEnabled ||
+		settings.TocBacklinks != "" || settings.SmartQuotes != "yes" || settings.TabWidth != 4 ||
+		settings.Stylesheet != "a.css,b.css" || settings.SmartquotesLocales["fr"] != "« : » :‹ : ›" {
+		t.Errorf("settings not set: %+v", settings)
+	}
+
+	for name, value := range map[string]string{
+		"report_level":      "loud",
+		"raw_enabled":       "maybe",
+		"tab_width":         "0",
+		"literal_block_env": "alltt",
+		"no_such_setting":   "1",
+		"warning_stream":    "out.txt",
+	} {
+		if err := settings.Set(name, value); err == nil {
+			t.Errorf("%s: %q accepted", name, value)
+		}
+	}
+}
+
+func TestReadConfig(t *testing.T) {
+	config := `# docutils.conf
+[general]
+report_level: info
+output_encoding: ascii
+
+[html writers]
+; several style sheets
+stylesheet = base.css,
+  extra.css
+
+[latex2e writer]
+template: book.tex
+
+[html5 writer]
+initial_header_level: 3
+`
+	settings := &Settings{}
+	settings.Init()
+	sections := []string{"general", "writers", "html writers", "html5 writer"}
+	if err := settings.ReadConfig(strings.NewReader(config), "docutils.conf", sections); err != nil {
+		t.Fatal(err)
+	}
+	if settings.ReportLevel != InfoLevel || settings.Stylesheet != "base.css,\nextra.css" ||
+		settings.InitialHeaderLevel != 3 || settings.LatexTemplate != "" {
+		t.Errorf("configuration not read: %+v", settings)
+	}
+	if err := settings.ReadConfig(strings.NewReader(config), "docutils.conf", []string{"latex2e writer"}); err != nil {
+		t.Fatal(err)
+	}
+	if settings.LatexTemplate != "book.tex" || settings.Template != "" {
+		t.Error("LaTeX template not set")
+	}
+
+	for config, expected := range map[string]string{
+		"[general]\ntab_width: wide\n":   "docutils.conf:2: [general] invalid value for \"tab_width\": \"wide\" (expected a positive number)",
+		"[general]\n\nreport_level\n":    "docutils.conf:3: expected \"name: value\" or \"name = value\"",
+		"report_level: info\n":           "docutils.conf:1: setting outside of a section",
+		"[general\n":                     "docutils.conf:1: invalid section header",
+		"[other]\ntab_width: wide\n":     "",
+		"[general]\n  continuation\n":    "docutils.conf:2: unexpected indented line",
+		"[GENERAL]\nraw-enabled: sure\n": "docutils.conf:2: [general] invalid value for \"raw_enabled\": \"sure\" (expected a boolean)",
+	} {
+		err := settings.ReadConfig(strings.NewReader(config), "docutils.conf", []string{"general"})
+		if expected == "" && err != nil || expected != "" && (err == nil || err.Error() != expected) {
+			t.Errorf("%q: unexpected error %v", config, err)
+		}
+	}
+}
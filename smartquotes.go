@@ -0,0 +1,299 @@
+package rst
+
+/*
+Typographic ("smart") quotes, dashes and ellipses, as in Python docutils
+utils/smartquotes.py (a port of SmartyPants)
+
+URL of Python source code:
+http://sourceforge.net/p/docutils/code/HEAD/tree/trunk/docutils/docutils/utils/smartquotes.py
+
+The context-dependent regular expressions of the original are replaced by a
+scan of the text, deciding for each quote character whether it opens or
+closes a quotation (or is an apostrophe) from the surrounding characters.
+*/
+
+import (
+	"strings"
+	"unicode"
+)
+
+/*
+   Quote characters of a language: primary opening and closing quotes,
+   secondary (single) opening and closing quotes, and the apostrophe.
+*/
+type SmartChars struct {
+	OpQuote    string
+	CpQuote    string
+	OsQuote    string
+	CsQuote    string
+	Apostrophe string
+}
+
+const (
+	smartEndash   = "\u2013"
+	smartEmdash   = "\u2014"
+	smartEllipsis = "\u2026"
+)
+
+/*
+   Quote characters of the supported languages: primary opening, primary
+   closing, secondary opening and secondary closing quote. The "-x-altquot"
+   variants are selected by the "alt" value of the `SmartQuotes` setting.
+*/
+var SmartQuotesLocales = map[string]string{
+	"af":              "“”‘’",
+	"af-x-altquot":    "„”‚’",
+	"bg":              "„“‚‘",
+	"ca":              "«»“”",
+	"ca-x-altquot":    "“”‘’",
+	"cs":              "„“‚‘",
+	"cs-x-altquot":    "»«›‹",
+	"da":              "»«›‹",
+	"da-x-altquot":    "„“‚‘",
+	"de":              "„“‚‘",
+	"de-x-altquot":    "»«›‹",
+	"de-ch":           "«»‹›",
+	"el":              "«»“”",
+	"en":              "“”‘’",
+	"en-uk-x-altquot": "‘’“”",
+	"eo":              "“”‘’",
+	"es":              "«»“”",
+	"es-x-altquot":    "“”‘’",
+	"et":              "„“‚‘",
+	"et-x-altquot":    "«»‹›",
+	"eu":              "«»‹›",
+	"fi":              "””’’",
+	"fi-x-altquot":    "»»››",
+	"fr":              "«\u202f:\u202f»:“:”",
+	"fr-x-altquot":    "«\u00a0:\u00a0»:“:”",
+	"fr-ch":           "«»‹›",
+	"fr-ch-x-altquot": "«\u202f:\u202f»:‹\u202f:\u202f›",
+	"gl":              "«»“”",
+	"he":              "”“»«",
+	"he-x-altquot":    "„”‚’",
+	"hr":              "„”‘’",
+	"hr-x-altquot":    "»«›‹",
+	"hsb":             "„“‚‘",
+	"hsb-x-altquot":   "»«›‹",
+	"hu":              "„”«»",
+	"is":              "„“‚‘",
+	"it":              "«»“”",
+	"it-ch":           "«»‹›",
+	"it-x-altquot":    "“”‘’",
+	"ja":              "「」『』",
+	"ko":              "“”‘’",
+	"lt":              "„“‚‘",
+	"lv":              "„“‚‘",
+	"mk":              "„“‚‘",
+	"nl":              "“”‘’",
+	"nl-x-altquot":    "„”‚’",
+	"nb":              "«»’’",
+	"nn":              "«»’’",
+	"nn-x-altquot":    "«»‘’",
+	"no":              "«»’’",
+	"no-x-altquot":    "«»‘’",
+	"pl":              "„”«»",
+	"pl-x-altquot":    "«»‚’",
+	"pt":              "«»“”",
+	"pt-br":           "“”‘’",
+	"ro":              "„”«»",
+	"ru":              "«»„“",
+	"sh":              "„”‚’",
+	"sh-x-altquot":    "»«›‹",
+	"sk":              "„“‚‘",
+	"sk-x-altquot":    "»«›‹",
+	"sl":              "„“‚‘",
+	"sl-x-altquot":    "»«›‹",
+	"sq":              "«»‹›",
+	"sq-x-altquot":    "“„‘‚",
+	"sr":              "„”’’",
+	"sr-x-altquot":    "»«›‹",
+	"sv":              "””’’",
+	"sv-x-altquot":    "»»››",
+	"tr":              "“”‘’",
+	"tr-x-altquot":    "«»‹›",
+	"uk":              "«»„“",
+	"uk-x-altquot":    "„“‚‘",
+	"zh-cn":           "“”‘’",
+	"zh-tw":           "「」『』",
+}
+
+/*
+   Return the quote characters of `language` given in `locales` (mapping
+   language tags to quote definitions, see `SmartQuotesLocales`), and
+   whether the language is supported. Subtags of `language` are dropped one
+   by one until a definition is found. A definition is either a string of
+   four characters, or four colon-separated strings.
+*/
+func GetSmartChars(language string, locales map[string]string) (*SmartChars, bool) {
+	tag := strings.ToLower(strings.Replace(language, "_", "-", -1))
+	for tag != "" {
+		if definition, ok := locales[tag]; ok {
+			var quotes []string
+			if strings.Contains(definition, ":") {
+				quotes = strings.Split(definition, ":")
+			} else {
+				for _, r := range definition {
+					quotes = append(quotes, string(r))
+				}
+			}
+			if len(quotes) == 4 {
+				return &SmartChars{quotes[0], quotes[1], quotes[2], quotes[3], "’"}, true
+			}
+		}
+		i := strings.LastIndex(tag, "-")
+		if i < 0 {
+			break
+		}
+		tag = tag[:i]
+	}
+	return &SmartChars{"\"", "\"", "'", "'", "'"}, false
+}
+
+/*
+   A token of text to educate: literal text is passed unchanged but serves
+   as context for the following token.
+*/
+type SmartToken struct {
+	Literal bool
+	Text    string
+}
+
+/*
+   Return the educated text of `tokens`: quotes are converted to the
+   typographic quotes of `chars`, "---" and "--" to em and en dashes, and
+   "..." to an ellipsis. The tokens are handled as a unit, so that quotes
+   at token boundaries are educated in context.
+*/
+func EducateTokens(tokens []SmartToken, chars *SmartChars) []string {
+	var result []string
+	prevTokenLastChar := ' '
+	for _, token := range tokens {
+		text := token.Text
+		if text == "" {
+			result = append(result, text)
+			continue
+		}
+		runes := []rune(text)
+		lastChar := runes[len(runes)-1]
+		if !token.Literal {
+			text = EducateDashesOldSchool(text)
+			text = EducateEllipses(text)
+			// Replace plain quotes in context to prevent mistaking them
+			// for a pair.
+			context := prevTokenLastChar
+			if context == '"' || context == '\'' {
+				context = ';'
+			}
+			text = EducateQuotes(text, context, chars)
+		}
+		prevTokenLastChar = lastChar
+		result = append(result, text)
+	}
+	return result
+}
+
+// Convert "---" into an em dash and "--" into an en dash.
+func EducateDashesOldSchool(text string) string {
+	text = strings.Replace(text, "---", smartEmdash, -1)
+	return strings.Replace(text, "--", smartEndash, -1)
+}
+
+// Convert "..." and ". . ." into an ellipsis.
+func EducateEllipses(text string) string {
+	text = strings.Replace(text, "...", smartEllipsis, -1)
+	return strings.Replace(text, ". . .", smartEllipsis, -1)
+}
+
+func isWordChar(r rune) bool {
+	return r == '_' || unicode.IsLetter(r) || unicode.IsDigit(r)
+}
+
+func isPunctChar(r rune) bool {
+	return r < unicode.MaxASCII && strings.ContainsRune("!\"#$%'()*+,-./:;<=>?@[\\]^_`{|}~", r)
+}
+
+// Characters after which a quote opens a quotation.
+func isQuoteOpener(r rune) bool {
+	return unicode.IsSpace(r) || strings.ContainsRune("([{-\u2013\u2014", r)
+}
+
+// Characters after which a quote closes a quotation.
+func isQuoteCloser(r rune) bool {
+	return !strings.ContainsRune(" \t\r\n[{(-", r)
+}
+
+/*
+   Convert the straight quotes of `text` into the typographic quotes of
+   `chars`. `context` is the character preceding `text` (a space at the
+   beginning of a text block).
+*/
+func EducateQuotes(text string, context rune, chars *SmartChars) string {
+	if !strings.ContainsAny(text, "\"'") {
+		return text
+	}
+	runes := []rune(text)
+	at := func(i int) rune {
+		if i < 0 {
+			return context
+		}
+		if i >= len(runes) {
+			return 0
+		}
+		return runes[i]
+	}
+	var b strings.Builder
+	for i, r := range runes {
+		prev, next := at(i-1), at(i+1)
+		switch r {
+		case '\'':
+			b.WriteString(educateSingleQuote(prev, next, runes[i+1:], chars))
+		case '"':
+			b.WriteString(educateDoubleQuote(prev, next, runes[i+1:], chars))
+		default:
+			b.WriteRune(r)
+		}
+	}
+	return b.String()
+}
+
+func educateSingleQuote(prev, next rune, rest []rune, chars *SmartChars) string {
+	switch {
+	// apostrophe in the middle of a word
+	case isWordChar(prev) && isWordChar(next):
+		return chars.Apostrophe
+	// double sets of quotes: "'Quoted' ..." or '"Quoted" ...'
+	case prev == '"' && isWordChar(next),
+		next == '"' && len(rest) >= 2 && isWordChar(rest[1]):
+		return chars.OsQuote
+	// decade abbreviations: the '80s
+	case len(rest) >= 3 && unicode.IsDigit(rest[0]) && unicode.IsDigit(rest[1]) && rest[2] == 's':
+		return chars.Apostrophe
+	case isQuoteOpener(prev) && next != 0 && (isWordChar(next) || isPunctChar(next)):
+		return chars.OsQuote
+	case isQuoteCloser(prev) && !unicode.IsSpace(next) && !unicode.IsDigit(next) &&
+		!(next == 's' && (len(rest) == 1 || !isWordChar(rest[1]))):
+		return chars.CsQuote
+	case next == 0 || unicode.IsSpace(next) || next == 's' && (len(rest) == 1 || !isWordChar(rest[1])):
+		return chars.CsQuote
+	}
+	// Any remaining single quotes should be opening ones.
+	return chars.OsQuote
+}
+
+func educateDoubleQuote(prev, next rune, rest []rune, chars *SmartChars) string {
+	switch {
+	// double sets of quotes: "'Quoted' ..." or '"Quoted" ...'
+	case next == '\'' && len(rest) >= 2 && isWordChar(rest[1]),
+		prev == '\'' && isWordChar(next):
+		return chars.OpQuote
+	case isQuoteOpener(prev) && next != 0 && (isWordChar(next) || isPunctChar(next)):
+		return chars.OpQuote
+	case unicode.IsSpace(next):
+		return chars.CpQuote
+	case isQuoteCloser(prev):
+		return chars.CpQuote
+	}
+	// Any remaining quotes should be opening ones.
+	return chars.OpQuote
+}
@@ -0,0 +1,39 @@
+package rst
+
+import (
+	"testing"
+)
+
+func TestEducateTokens(t *testing.T) {
+	en, _ := GetSmartChars("en-US", SmartQuotesLocales)
+	de, _ := GetSmartChars("de", SmartQuotesLocales)
+	cases := []struct {
+		tokens   []SmartToken
+		chars    *SmartChars
+		expected string
+	}{
+		{[]SmartToken{{false, `"Isn't this fun?" -- 'Yes'... in the '80s.`}}, en,
+			`“Isn’t this fun?” – ‘Yes’… in the ’80s.`},
+		{[]SmartToken{{false, `He said, "'Quoted' words---in a larger quote."`}}, en,
+			`He said, “‘Quoted’ words—in a larger quote.”`},
+		{[]SmartToken{{false, `"Sie sagte: 'Nein'."`}}, de, `„Sie sagte: ‚Nein‘.“`},
+		{[]SmartToken{{false, `Use "`}, {true, `"x"`}, {false, `" -- here.`}}, en,
+			`Use “"x"” – here.`},
+	}
+	for _, c := range cases {
+		var result string
+		for _, text := range EducateTokens(c.tokens, c.chars) {
+			result += text
+		}
+		if result != c.expected {
+			t.Error("expected " + c.expected + ", got " + result)
+		}
+	}
+
+	if _, ok := GetSmartChars("xx", SmartQuotesLocales); ok {
+		t.Error("unknown language supported")
+	}
+	if fr, ok := GetSmartChars("fr-CA", SmartQuotesLocales); !ok || fr.OpQuote != "« " {
+		t.Error("French quotes not found")
+	}
+}
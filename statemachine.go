@@ -9,27 +9,41 @@ http://sourceforge.net/p/docutils/code/HEAD/tree/trunk/docutils/docutils/statema
 Functions:
 
 - `File2lines()`: split file content into a list of one-line strings
+- `String2Lines()`: split a string into a list of one-line strings, for the
+  input of a state machine
+
+Python exceptions are returned as errors: transition methods return an
+`*EOFError` (see `ErrEOF`) to cut processing short, a
+`*TransitionCorrection` or a `*StateCorrection` to try the current line
+again with another transition or state.
 */
 
 import (
 	"bufio"
 	"fmt"
 	"os"
-	"reflect"
 	"regexp"
 	"strings"
+	"unicode"
 )
 
+// Returned by transition methods to end the processing of the input.
+var ErrEOF error = &EOFError{"EOFError"}
+
 /*
    A finite state machine for text filters using regular expressions.
 
    The input is provided in the form of a list of one-line strings (no
-   newlines). States are subclasses of the `State` class. Transitions consist
+   newlines). States embed `StateBase` (or `StateWS`). Transitions consist
    of regular expression patterns and transition methods, and are defined in
    each state.
 
-   The state machine is started with the `run()` method, which returns the
+   The state machine is started with the `Run()` method, which returns the
    results of processing in a list.
+
+   The methods of Python's `StateMachineWS` (`GetIndented()`,
+   `GetKnownIndented()` and `GetFirstKnownIndented()`) are methods of
+   `StateMachine` too.
 */
 type StateMachine struct {
 	// `StringList` of input lines (without newlines).
@@ -40,7 +54,7 @@ type StateMachine struct {
 	inputOffset int
 
 	// Current input line.
-	line string
+	Line string
 
 	// Current input line offset from beginning of `self.input_lines`.
 	lineOffset int
@@ -55,11 +69,11 @@ type StateMachine struct {
 	currentState string
 
 	// Mapping of {state_name: State_object}.
-	states map[string]*State
+	states map[string]State
 
 	// List of bound methods or functions to call whenever the current
-	// line changes.  Observers are called with one argument, ``self``.
-	// Cleared at the end of `run()`.
+	// line changes.  Observers are called with the source and the offset of
+	// the line. Cleared at the end of `run()`.
 	observers []func(string, int)
 }
 
@@ -68,22 +82,23 @@ type StateMachine struct {
 
    Parameters:
 
-   - `state_classes`: a list of `State` (sub)classes.
-   - `initial_state`: a string, the class name of the initial state.
+   - `states`: a list of `State` objects, named by their `StateBase.Name`.
+   - `initialState`: a string, the name of the initial state.
    - `debug`: a boolean; produce verbose output if true (nonzero).
 */
-func (s *StateMachine) Init(stateClasses []*State, initialState string, debug bool) {
+func (s *StateMachine) Init(states []State, initialState string, debug bool) {
 	s.lineOffset = -1
 	s.debug = debug
 	s.initialState = initialState
 	s.currentState = initialState
-	s.addStates(stateClasses)
+	s.states = make(map[string]State)
+	s.AddStates(states)
 }
 
 // Remove circular references to objects no longer required.
-func (s *StateMachine) unlink() {
+func (s *StateMachine) Unlink() {
 	for _, state := range s.states {
-		state.unlink()
+		state.Unlink()
 	}
 	s.states = nil
 }
@@ -102,18 +117,16 @@ func (s *StateMachine) unlink() {
 
    Parameters:
 
-   - `input_lines`: a list of strings without newlines, or `StringList`.
+   - `input_lines`: a `StringList` of strings without newlines.
    - `input_offset`: the line offset of `input_lines` from the beginning
      of the file.
    - `context`: application-specific storage.
-   - `input_source`: name or path of source of `input_lines`.
-   - `initial_state`: name of initial state.
+   - `initial_state`: name of initial state, "" for the state given to
+     `Init()`.
 */
-func (s *StateMachine) run(inputLines StringList, inputOffset int, context Context, initialState string) []string {
+func (s *StateMachine) Run(inputLines StringList, inputOffset int, context []string, initialState string) ([]string, error) {
 	s.runtimeInit()
-
 	s.inputLines = inputLines
-
 	s.inputOffset = inputOffset
 	s.lineOffset = -1
 	if initialState == "" {
@@ -121,51 +134,81 @@ func (s *StateMachine) run(inputLines StringList, inputOffset int, context Conte
 	} else {
 		s.currentState = initialState
 	}
-
 	if s.debug {
-		fmt.Println("\nStateMachine.run: input_lines (line_offset=%d)", s.lineOffset)
-		for _, line := range s.inputLines.data {
-			fmt.Println(line)
-		}
+		fmt.Fprintf(os.Stderr, "\nStateMachine.run: input_lines (line_offset=%d):\n| %s\n",
+			s.lineOffset, strings.Join(s.inputLines.data, "\n| "))
 	}
-
 	var transitions []string
 	var results []string
-	state, _ := s.getState("")
-
+	state, err := s.GetState("")
+	if err != nil {
+		return nil, err
+	}
 	if s.debug {
-		fmt.Println("\nStateMachine.run: bof transition")
+		fmt.Fprintf(os.Stderr, "\nStateMachine.run: bof transition\n")
 	}
-	context, result := state.bof(context)
+	context, result := state.Bof(context)
 	results = append(results, result...)
 	for {
 		var nextState string
-		_, err := s.nextLine(1)
-		if err == nil {
+		if _, err = s.NextLine(1); err == nil {
 			if s.debug {
-				fmt.Println("\nStateMachine.run: line " + s.line)
+				source, offset := s.GetSourceAndLine(0)
+				fmt.Fprintf(os.Stderr, "\nStateMachine.run: line (source=%q, offset=%d):\n| %s\n",
+					source, offset, s.Line)
 			}
-			context, nextState, result = s.checkLine(context, state, transitions)
-		} else {
-			// EOFError
+			context, nextState, result, err = s.CheckLine(context, state, transitions)
+			if err == nil {
+				results = append(results, result...)
+			}
+		}
+		if _, ok := err.(*EOFError); ok {
 			if s.debug {
-				fmt.Println("\nStateMachine.run: %s.eof transition", reflect.TypeOf(state).Name())
+				fmt.Fprintf(os.Stderr, "\nStateMachine.run: %s.eof transition\n", state.base().Name)
 			}
-			result = state.eof(context)
+			result, err = state.Eof(context)
 			results = append(results, result...)
-			break
+			if err == nil {
+				break
+			}
+		}
+		switch e := err.(type) {
+		case nil:
+			transitions = nil
+		case *TransitionCorrection:
+			s.PreviousLine(1) // back up for another try
+			transitions = []string{e.Transition}
+			if s.debug {
+				fmt.Fprintf(os.Stderr, "\nStateMachine.run: TransitionCorrection to state %q, transition %s.\n",
+					state.base().Name, e.Transition)
+			}
+			continue
+		case *StateCorrection:
+			s.PreviousLine(1) // back up for another try
+			nextState = e.State
+			if e.Transition == "" {
+				transitions = nil
+			} else {
+				transitions = []string{e.Transition}
+			}
+			if s.debug {
+				fmt.Fprintf(os.Stderr, "\nStateMachine.run: StateCorrection to state %q, transition %s.\n",
+					nextState, e.Transition)
+			}
+		default:
+			if s.debug {
+				source, line := s.GetSourceAndLine(0)
+				fmt.Fprintf(os.Stderr, "input line %d (%s:%d)\nstate: %s\n",
+					s.AbsLineNumber(), source, line, s.currentState)
+			}
+			return results, err
+		}
+		if state, err = s.GetState(nextState); err != nil {
+			return results, err
 		}
-		results = append(result, result...)
-
-		// FIXME: implement TransitionCorrection
-
-		// FIXME: implement StateCorrection
-
-		transitions = nil
-		state, _ = s.getState(nextState)
 	}
 	s.observers = nil
-	return results
+	return results, nil
 }
 
 /*
@@ -175,14 +218,14 @@ func (s *StateMachine) run(inputLines StringList, inputOffset int, context Conte
 
    Exception: `UnknownStateError` raised if `next_state` unknown.
 */
-func (s *StateMachine) getState(nextState string) (*State, error) {
+func (s *StateMachine) GetState(nextState string) (State, error) {
 	if nextState != "" {
 		if s.debug && nextState != s.currentState {
-			fmt.Println("\nStateMachine.get_state: Changing state from %s to %s", s.currentState, nextState)
+			fmt.Fprintf(os.Stderr, "\nStateMachine.get_state: Changing state from %q to %q (input line %d).\n",
+				s.currentState, nextState, s.AbsLineNumber())
 		}
 		s.currentState = nextState
 	}
-
 	state, ok := s.states[s.currentState]
 	if !ok {
 		return nil, &UnknownStateError{"UnknownStateError: " + s.currentState}
@@ -190,30 +233,31 @@ func (s *StateMachine) getState(nextState string) (*State, error) {
 	return state, nil
 }
 
+// Return the state named `name`, nil if unknown.
+func (s *StateMachine) State(name string) State {
+	return s.states[name]
+}
+
 // Load `self.line` with the `n`'th next line and return it.
-func (s *StateMachine) nextLine(n int) (string, error) {
+func (s *StateMachine) NextLine(n int) (string, error) {
 	s.lineOffset += n
 	var err error
-	s.line, err = s.inputLines.GetItem(s.lineOffset)
+	s.Line, err = s.inputLines.GetItem(s.lineOffset)
 	if err != nil {
 		// IndexError
-		s.line = ""
+		s.Line = ""
 		s.notifyObservers()
-		return "", &EOFError{"EOFError in StateMachine nextLine"}
+		return "", ErrEOF
 	}
 	s.notifyObservers()
-	return s.line, nil
+	return s.Line, nil
 }
 
 // Return true if the next line is blank or non-existant.
-func (s *StateMachine) isNextLineBlank() bool {
+func (s *StateMachine) IsNextLineBlank() bool {
 	line, err := s.inputLines.GetItem(s.lineOffset + 1)
 	if err == nil {
-		line = strings.TrimSpace(line)
-		if line == "" {
-			return true
-		}
-		return false
+		return strings.TrimSpace(line) == ""
 	}
 	return true
 }
@@ -229,29 +273,29 @@ func (s *StateMachine) AtBof() bool {
 }
 
 // Load `self.line` with the `n`'th previous line and return it.
-func (s *StateMachine) previousLine(n int) string {
+func (s *StateMachine) PreviousLine(n int) string {
 	s.lineOffset -= n
 	if s.lineOffset < 0 {
-		s.line = ""
+		s.Line = ""
 	} else {
-		s.line, _ = s.inputLines.GetItem(s.lineOffset)
+		s.Line, _ = s.inputLines.GetItem(s.lineOffset)
 	}
 	s.notifyObservers()
-	return s.line
+	return s.Line
 }
 
 // Jump to absolute line offset `line_offset`, load and return it.
 func (s *StateMachine) GotoLine(lineOffset int) (string, error) {
 	s.lineOffset = lineOffset - s.inputOffset
 	var err error
-	s.line, err = s.inputLines.GetItem(s.lineOffset)
+	s.Line, err = s.inputLines.GetItem(s.lineOffset)
 	if err != nil {
-		s.line = ""
+		s.Line = ""
 		s.notifyObservers()
-		return s.line, err
+		return "", ErrEOF
 	}
 	s.notifyObservers()
-	return s.line, nil
+	return s.Line, nil
 }
 
 // Return source of line at absolute line offset `line_offset`.
@@ -269,6 +313,59 @@ func (s *StateMachine) AbsLineNumber() int {
 	return s.lineOffset + s.inputOffset + 1
 }
 
+// Return the current line offset from the beginning of the input lines.
+func (s *StateMachine) LineOffset() int {
+	return s.lineOffset
+}
+
+// Return the input lines (`self.input_lines`).
+func (s *StateMachine) InputLines() *StringList {
+	return &s.inputLines
+}
+
+/*
+   Return (source, line) tuple for current or given line number.
+
+   Looks up the source and line number in the `self.input_lines`
+   StringList instance to count for included source files.
+
+   If the optional argument `lineno` is given (not 0), convert it from an
+   absolute line number to the corresponding (source, line) pair.
+   Return ("", 0) if the line is off the input.
+*/
+func (s *StateMachine) GetSourceAndLine(lineno int) (string, int) {
+	offset := s.lineOffset
+	if lineno != 0 {
+		offset = lineno - s.inputOffset - 1
+	}
+	if offset < 0 {
+		return "", 0
+	}
+	info, err := s.inputLines.Info(offset)
+	if err != nil {
+		return "", 0
+	}
+	if info.offset < 0 {
+		// "Just past the end"
+		source, line := s.GetSourceAndLine(offset + s.inputOffset)
+		return source, line + 1
+	}
+	return info.source, info.offset + 1
+}
+
+/*
+   Return a contiguous block of text.
+
+   If `flushLeft` is true, return an `UnexpectedIndentationError` (with the
+   block up to the indented line) if an indented line is encountered
+   before the text block ends (with a blank line).
+*/
+func (s *StateMachine) GetTextBlock(flushLeft bool) (StringList, error) {
+	block, err := s.inputLines.GetTextBlock(s.lineOffset, flushLeft)
+	s.NextLine(block.Length() - 1)
+	return block, err
+}
+
 /*
    Examine one line of input for a transition match & execute its method.
 
@@ -282,73 +379,75 @@ func (s *StateMachine) AbsLineNumber() int {
    Return the values returned by the transition method:
 
    - context: possibly modified from the parameter `context`;
-   - next state name (`State` subclass name);
-   - the result output of the transition, a list.
+   - next state name (`State` name);
+   - the result output of the transition, a list;
+   - an error.
 
    When there is no match, ``state.no_match()`` is called and its return
    value is returned.
 */
-func (s *StateMachine) checkLine(context Context, state *State, transitions []string) (Context, string, []string) {
+func (s *StateMachine) CheckLine(context []string, state State, transitions []string) ([]string, string, []string, error) {
+	b := state.base()
 	if transitions == nil {
-		transitions = state.transitionOrder
+		transitions = b.transitionOrder
 	}
-	//state_correction = None
 	if s.debug {
-		fmt.Println("\nStateMachine.check_line: state=", reflect.TypeOf(state).Name())
+		fmt.Fprintf(os.Stderr, "\nStateMachine.check_line: state=%q, transitions=%v.\n", b.Name, transitions)
 	}
 	for _, name := range transitions {
-		pattern := state.transitions[name].compiledPattern
-		method := state.transitions[name].transitionMethod
-		nextState := state.transitions[name].nextStateName
-		match := pattern.FindAllString(s.line, -1)
-		if match != nil {
+		transition := b.transitions[name]
+		if match := transition.match(s.Line); match != nil {
 			if s.debug {
-				fmt.Println("\nStateMachine.check_line: Matched transition")
+				fmt.Fprintf(os.Stderr, "\nStateMachine.check_line: Matched transition %q in state %q.\n", name, b.Name)
 			}
-			retv := method.Call([]reflect.Value{
-				reflect.ValueOf(match),
-				reflect.ValueOf(context),
-				reflect.ValueOf(nextState),
-			})
-			return retv[0].Interface().(Context), retv[1].Interface().(string), retv[2].Interface().([]string)
+			return transition.Method(match, context, transition.NextState)
 		}
 	}
 	if s.debug {
-		fmt.Println("\nStateMachine.check_line: No match in state ", reflect.TypeOf(state).Name())
+		fmt.Fprintf(os.Stderr, "\nStateMachine.check_line: No match in state %q.\n", b.Name)
 	}
-	return state.noMatch(context, transitions)
+	return state.NoMatch(context, transitions)
 }
 
 /*
-   Initialize & add a `state_class` (`State` subclass) object.
+   Add the state `state`, named by its `StateBase.Name`.
 
-   Exception: `DuplicateStateError` raised if `state_class` was already
+   Exception: `DuplicateStateError` raised if `state` was already
    added.
 */
-func (s *StateMachine) addState(stateClass *State) error {
-	statename := reflect.TypeOf(stateClass).Name()
-	if _, ok := s.states[statename]; ok {
-		return &DuplicateStateError{"DuplicateStateError: " + statename}
-	}
-	stateClass.Init(s, s.debug)
-	s.states[statename] = stateClass
+func (s *StateMachine) AddState(state State) error {
+	b := state.base()
+	if _, ok := s.states[b.Name]; ok {
+		return &DuplicateStateError{"DuplicateStateError: " + b.Name}
+	}
+	b.StateMachine = s
+	b.Debug = s.debug
+	s.states[b.Name] = state
 	return nil
 }
 
-// Add `state_classes` (a list of `State` subclasses).
-func (s *StateMachine) addStates(stateClasses []*State) {
-	for _, stateClass := range stateClasses {
-		s.addState(stateClass)
+// Add `states` (a list of `State` objects).
+func (s *StateMachine) AddStates(states []State) {
+	for _, state := range states {
+		s.AddState(state)
 	}
 }
 
 // Initialize `self.states`.
 func (s *StateMachine) runtimeInit() {
 	for _, state := range s.states {
-		state.runtimeInit()
+		state.RuntimeInit()
 	}
 }
 
+/*
+   The `observer` parameter is a function or bound method which takes two
+   arguments, the source and offset of the current line.
+*/
+func (s *StateMachine) AttachObserver(observer func(string, int)) {
+	s.observers = append(s.observers, observer)
+}
+
 func (s *StateMachine) notifyObservers() {
 	for _, observer := range s.observers {
 		info, err := s.inputLines.Info(s.lineOffset)
@@ -361,130 +460,264 @@ func (s *StateMachine) notifyObservers() {
 }
 
 /*
-   State superclass. Contains a list of transitions, and transition methods.
+   Return a block of indented lines of text, and info.
+
+   Extract an indented block where the indent is unknown for all lines.
+
+   Parameters:
+
+   - `untilBlank`: Stop collecting at the first blank line if true.
+   - `stripIndent`: Strip common leading indent if true (default).
+
+   Return:
+
+   - the indented block (a list of lines of text),
+   - its indent,
+   - its first line offset from BOF, and
+   - whether or not it finished with a blank line.
+*/
+func (s *StateMachine) GetIndented(untilBlank, stripIndent bool) (StringList, int, int, bool) {
+	offset := s.AbsLineOffset()
+	indented, indent, blankFinish := s.inputLines.GetIndented(s.lineOffset, untilBlank, stripIndent, -1, -1)
+	if indented.Length() > 0 {
+		s.NextLine(indented.Length() - 1) // advance to last indented line
+	}
+	for indented.Length() > 0 && strings.TrimSpace(indented.data[0]) == "" {
+		indented.TrimStart(1)
+		offset += 1
+	}
+	return indented, indent, offset, blankFinish
+}
+
+/*
+   Return an indented block and info.
+
+   Extract an indented block where the indent is known for all lines.
+   Starting with the current line, extract the entire text block with at
+   least `indent` indentation (which must be whitespace, except for the
+   first line).
+
+   Parameters:
+
+   - `blockIndent`: The number of indent columns/characters.
+   - `untilBlank`: Stop collecting at the first blank line if true.
+   - `stripIndent`: Strip `indent` characters of indentation if true
+     (default).
+
+   Return:
+
+   - the indented block,
+   - its first line offset from BOF, and
+   - whether or not it finished with a blank line.
+*/
+func (s *StateMachine) GetKnownIndented(blockIndent int, untilBlank, stripIndent bool) (StringList, int, bool) {
+	offset := s.AbsLineOffset()
+	indented, _, blankFinish := s.inputLines.GetIndented(s.lineOffset, untilBlank, stripIndent, blockIndent, -1)
+	s.NextLine(indented.Length() - 1) // advance to last indented line
+	for indented.Length() > 0 && strings.TrimSpace(indented.data[0]) == "" {
+		indented.TrimStart(1)
+		offset += 1
+	}
+	return indented, offset, blankFinish
+}
+
+/*
+   Return an indented block and info.
+
+   Extract an indented block where the indent is known for the first line
+   and unknown for all other lines.
+
+   Parameters:
+
+   - `firstIndent`: The first line's indent (# of columns/characters).
+   - `untilBlank`: Stop collecting at the first blank line if true.
+   - `stripIndent`: Strip `indent` characters of indentation if true
+     (default).
+   - `stripTop`: Strip blank lines from the beginning of the block.
+
+   Return:
+
+   - the indented block,
+   - its indent,
+   - its first line offset from BOF, and
+   - whether or not it finished with a blank line.
+*/
+func (s *StateMachine) GetFirstKnownIndented(firstIndent int, untilBlank, stripIndent, stripTop bool) (StringList, int, int, bool) {
+	offset := s.AbsLineOffset()
+	indented, indent, blankFinish := s.inputLines.GetIndented(s.lineOffset, untilBlank, stripIndent, -1, firstIndent)
+	s.NextLine(indented.Length() - 1) // advance to last indented line
+	if stripTop {
+		for indented.Length() > 0 && strings.TrimSpace(indented.data[0]) == "" {
+			indented.TrimStart(1)
+			offset += 1
+		}
+	}
+	return indented, indent, offset, blankFinish
+}
+
+/*
+   A transition pattern, matched at the beginning of input lines like
+   Python's ``re.match()``: a `*regexp.Regexp`, or another matcher with the
+   same methods (for patterns Go regular expressions cannot express).
+*/
+type Pattern interface {
+	FindStringSubmatchIndex(s string) []int
+	SubexpNames() []string
+}
+
+// The match of a transition pattern at the beginning of an input line.
+type Match struct {
+	// The matched input line.
+	String string
+
+	// Submatch index pairs, as returned by `Pattern.FindStringSubmatchIndex`.
+	indices []int
+
+	// Submatch names, as returned by `Pattern.SubexpNames`.
+	names []string
+}
+
+// Match `pattern` at the beginning of `line`; return nil if it does not
+// match.
+func MatchPattern(pattern Pattern, line string) *Match {
+	indices := pattern.FindStringSubmatchIndex(line)
+	if indices == nil || indices[0] != 0 {
+		return nil
+	}
+	return &Match{line, indices, pattern.SubexpNames()}
+}
+
+// Return the text of the submatch `i` (0 for the whole match), "" if it
+// did not participate in the match.
+func (m *Match) Group(i int) string {
+	if 2*i+1 >= len(m.indices) || m.indices[2*i] < 0 {
+		return ""
+	}
+	return m.String[m.indices[2*i]:m.indices[2*i+1]]
+}
+
+// Return the text of the submatch named `name`.
+func (m *Match) Named(name string) string {
+	for i, n := range m.names {
+		if n == name && n != "" {
+			return m.Group(i)
+		}
+	}
+	return ""
+}
+
+// Return the start index of the submatch `i`, -1 if it did not match.
+func (m *Match) Start(i int) int {
+	return m.indices[2*i]
+}
+
+// Return the end index of the submatch `i`, -1 if it did not match.
+func (m *Match) End(i int) int {
+	return m.indices[2*i+1]
+}
+
+/*
+   Transition method.
 
-   Transition methods all have the same signature. They take 3 parameters:
+   Transition methods take 3 parameters:
 
-   - An `re` match object. ``match.string`` contains the matched input line,
-     ``match.start()`` gives the start index of the match, and
-     ``match.end()`` gives the end index.
+   - A `*Match` object. ``match.String`` contains the matched input line,
+     ``match.End(0)`` gives the end index of the match.
    - A context object, whose meaning is application-defined (initial value
-     ``None``). It can be used to store any information required by the state
+     nil). It can be used to store any information required by the state
      machine, and the retured context is passed on to the next transition
      method unchanged.
    - The name of the next state, a string, taken from the transitions list;
      normally it is returned unchanged, but it may be altered by the
      transition method if necessary.
 
-   Transition methods all return a 3-tuple:
+   Transition methods all return a 4-tuple:
 
    - A context object, as (potentially) modified by the transition method.
    - The next state name (a return value of "" means no state change).
    - The processing result, a list, which is accumulated by the state
      machine.
+   - An error: `ErrEOF` cuts processing short; a `*TransitionCorrection`
+     or `*StateCorrection` tries the line again.
+*/
+type TransitionMethod func(match *Match, context []string, nextState string) ([]string, string, []string, error)
+
+// A transition: its pattern, its method, and the name of the next state.
+type Transition struct {
+	Pattern   Pattern
+	Method    TransitionMethod
+	NextState string
+}
+
+func (t Transition) match(line string) *Match {
+	return MatchPattern(t.Pattern, line)
+}
 
-   Transition methods may raise an `EOFError` to cut processing short.
+/*
+   State of a `StateMachine`: a list of transitions, and transition
+   methods. State types embed `StateBase` (or `StateWS`), which provides
+   the transition lists and the default methods.
 
    There are two implicit transitions, and corresponding transition methods
-   are defined: `bof()` handles the beginning-of-file, and `eof()` handles
+   are defined: `Bof()` handles the beginning-of-file, and `Eof()` handles
    the end-of-file. These methods have non-standard signatures and return
-   values. `bof()` returns the initial context and results, and may be used
-   to return a header string, or do any other processing needed. `eof()`
+   values. `Bof()` returns the initial context and results, and may be used
+   to return a header string, or do any other processing needed. `Eof()`
    should handle any remaining context and wrap things up; it returns the
    final processing result.
 
-   Typical applications need only subclass `State` (or a subclass), set the
-   `patterns` and `initial_transitions` class attributes, and provide
-   corresponding transition methods. The default object initialization will
-   take care of constructing the list of transitions.
+   Typical applications embed `StateBase`, add their transitions with
+   `AddTransitions()` when the state is created, and provide the
+   corresponding transition methods.
 */
-type State struct {
-	// {Name: pattern} mapping, used by `make_transition()`. Each pattern may
-	// be a string or a compiled `re` pattern. Override in subclasses.
-	patterns map[string]*regexp.Regexp
-
-	// A list of transitions to initialize when a `State` is instantiated.
-	// Each entry is a (transition name, next state name) pair. See
-	// `make_transitions()`. Override in subclasses.
-	initialTransitions []TransitionNameAndNextState
-
-	// The `StateMachine` class for handling nested processing.
-	//
-	// If left as nil, `nested_sm` defaults to the class of the state's
-	// controlling state machine. Override it in subclasses to avoid the default.
-	nestedSm reflect.Type
-
-	// Keyword arguments dictionary, passed to the `nested_sm` constructor.
-	//
-	// Two keys must have entries in the dictionary:
-	//
-	// - Key 'state_classes' must be set to a list of `State` classes.
-	// - Key 'initial_state' must be set to the name of the initial state class.
-	//
-	// If `nested_sm_kwargs` is left as nil, 'state_classes' defaults to the
-	// class of the current state, and 'initial_state' defaults to the name of
-	// the class of the current state. Override in subclasses to avoid the
-	// defaults.
-	nestedSmKwargs *SmKwargs
+type State interface {
+	base() *StateBase
+
+	// Initialize this `State` before running the state machine; called from
+	// `StateMachine.Run()`.
+	RuntimeInit()
+
+	// Remove circular references to objects no longer required.
+	Unlink()
+
+	Bof(context []string) ([]string, []string)
+	Eof(context []string) ([]string, error)
+	NoMatch(context []string, transitions []string) ([]string, string, []string, error)
+}
+
+// The transition lists and default methods of states.
+type StateBase struct {
+	// The state name, its key in the state machine.
+	Name string
 
 	// Debugging mode on/off.
-	debug bool
+	Debug bool
 
 	// A list of transition names in search order.
 	transitionOrder []string
 
-	// A mapping of transition names to 3-tuples containing
-	// (compiled_pattern, transition_method, next_state_name). Initialized as
-	// an instance attribute dynamically (instead of as a class attribute)
-	// because it may make forward references to patterns and methods in this
-	// or other classes.
+	// A mapping of transition names to transitions.
 	transitions map[string]Transition
 
 	// A reference to the controlling `StateMachine` object.
-	stateMachine *StateMachine
+	StateMachine *StateMachine
 }
 
-/*
-   Initialize a `State` object; make & add initial transitions.
-
-   Parameters:
-
-   - `statemachine`: the controlling `StateMachine` object.
-   - `debug`: a boolean; produce verbose output if true.
-*/
-func (s *State) Init(sm *StateMachine, debug bool) {
-	s.addInitialTransitions()
-
-	s.stateMachine = sm
-	s.debug = debug
-
-	if s.nestedSm == nil {
-		s.nestedSm = reflect.TypeOf(s.stateMachine)
-	}
-	if s.nestedSmKwargs == nil {
-		s.nestedSmKwargs = &SmKwargs{
-			stateClasses: []reflect.Type{reflect.TypeOf(s)},
-			initialState: reflect.TypeOf(s).Name(),
-		}
-	}
+func (s *StateBase) base() *StateBase {
+	return s
 }
 
-// Initialize this `State` before running the state machine; called from
-// `self.stateMachine.run()`.
-func (s *State) runtimeInit() {
+// Set the state name; call before adding transitions.
+func (s *StateBase) Init(name string) {
+	s.Name = name
+	s.transitions = make(map[string]Transition)
 }
 
-// Remove circular references to objects no longer required.
-func (s *State) unlink() {
-	s.stateMachine = nil
+func (s *StateBase) RuntimeInit() {
 }
 
-// Make and add transitions listed in `self.initial_transitions`.
-func (s *State) addInitialTransitions() {
-	if len(s.initialTransitions) > 0 {
-		names, transitions := s.makeTransitions(s.initialTransitions)
-		s.addTransitions(names, transitions)
-	}
+func (s *StateBase) Unlink() {
+	s.StateMachine = nil
 }
 
 /*
@@ -493,11 +726,12 @@ func (s *State) addInitialTransitions() {
    Parameters:
 
    - `names`: a list of transition names.
-   - `transitions`: a mapping of names to transition tuples.
+   - `transitions`: a mapping of names to transitions. An empty
+     `NextState` stands for this state.
 
    Exceptions: `DuplicateTransitionError`, `UnknownTransitionError`.
 */
-func (s *State) addTransitions(names []string, transitions map[string]Transition) error {
+func (s *StateBase) AddTransitions(names []string, transitions map[string]Transition) error {
 	for _, name := range names {
 		if _, ok := s.transitions[name]; ok {
 			return &DuplicateTransitionError{"DuplicateTransitionError: " + name}
@@ -506,9 +740,12 @@ func (s *State) addTransitions(names []string, transitions map[string]Transition
 			return &UnknownTransitionError{"UnknownTransitionError: " + name}
 		}
 	}
-
-	s.transitionOrder = append(names, s.transitionOrder...)
-	for name, transition := range transitions {
+	s.transitionOrder = append(append([]string{}, names...), s.transitionOrder...)
+	for _, name := range names {
+		transition := transitions[name]
+		if transition.NextState == "" {
+			transition.NextState = s.Name
+		}
 		s.transitions[name] = transition
 	}
 	return nil
@@ -517,17 +754,10 @@ func (s *State) addTransitions(names []string, transitions map[string]Transition
 /*
    Add a transition to the start of the transition list.
 
-   Parameter `transition`: a ready-made transition 3-tuple.
-
    Exception: `DuplicateTransitionError`.
 */
-func (s *State) addTransition(name string, transition Transition) error {
-	if _, ok := s.transitions[name]; ok {
-		return &DuplicateTransitionError{"DuplicateTransitionError: " + name}
-	}
-	s.transitionOrder = append([]string{name}, s.transitionOrder...)
-	s.transitions[name] = transition
-	return nil
+func (s *StateBase) AddTransition(name string, transition Transition) error {
+	return s.AddTransitions([]string{name}, map[string]Transition{name: transition})
 }
 
 /*
@@ -535,72 +765,28 @@ func (s *State) addTransition(name string, transition Transition) error {
 
    Exception: `UnknownTransitionError`.
 */
-func (s *State) removeTransition(name string) error {
-	if _, ok := s.transitions[name]; ok {
-		delete(s.transitions, name)
-		for i, n := range s.transitionOrder {
-			if n == name {
-				s.transitionOrder = append(s.transitionOrder[:i], s.transitionOrder[i+1:]...)
-				break
-			}
-		}
-
-	} else {
+func (s *StateBase) RemoveTransition(name string) error {
+	if _, ok := s.transitions[name]; !ok {
 		return &UnknownTransitionError{"UnknownTransitionError: " + name}
 	}
+	delete(s.transitions, name)
+	for i, n := range s.transitionOrder {
+		if n == name {
+			s.transitionOrder = append(s.transitionOrder[:i], s.transitionOrder[i+1:]...)
+			break
+		}
+	}
 	return nil
 }
 
-/*
-   Make & return a transition tuple based on `name`.
-
-   This is a convenience function to simplify transition creation.
-
-   Parameters:
-
-   - `name`: a string, the name of the transition pattern & method. This
-     `State` object must have a method called '`name`', and a dictionary
-     `self.patterns` containing a key '`name`'.
-   - `next_state`: a string, the name of the next `State` object for this
-     transition. A value of "" (empty string) implies no state change
-     (i.e., continue with the same state).
-
-   Exceptions: `TransitionPatternNotFound`, `TransitionMethodNotFound`.
-*/
-func (s *State) makeTransition(name, nextState string) (Transition, error) {
-	if nextState == "" {
-		nextState = reflect.TypeOf(s).Name()
-	}
-
-	pattern, ok := s.patterns[name]
-	if !ok {
-		return Transition{}, &TransitionPatternNotFound{"TransitionPatternNotFound: " + name + " not in " + reflect.TypeOf(s).Name()}
-	}
-
-	method := reflect.New(reflect.TypeOf(s)).MethodByName(name)
-	if !method.IsValid() {
-		return Transition{}, &TransitionMethodNotFound{"TransitionMethodNotFound: " + name + " not in " + reflect.TypeOf(s).Name()}
-	}
-
-	return Transition{pattern, method, nextState}, nil
+// Return the transition named `name`.
+func (s *StateBase) Transition(name string) (Transition, bool) {
+	transition, ok := s.transitions[name]
+	return transition, ok
 }
 
 /*
-   Return a list of transition names and a transition mapping.
-
-   Parameter `pairs`: a list, where each entry is a 2-tuple (transition name,
-   next state name).
-*/
-func (s *State) makeTransitions(pairs []TransitionNameAndNextState) (names []string, transitions map[string]Transition) {
-	for _, pair := range pairs {
-		transitions[pair.name], _ = s.makeTransition(pair.name, pair.nextState)
-		names = append(names, pair.name)
-	}
-	return
-}
-
-/*
-   Called when there is no match from `StateMachine.check_line()`.
+   Called when there is no match from `StateMachine.CheckLine()`.
 
    Return the same values returned by transition methods:
 
@@ -610,8 +796,8 @@ func (s *State) makeTransitions(pairs []TransitionNameAndNextState) (names []str
 
    Override in subclasses to catch this event.
 */
-func (s *State) noMatch(context Context, transitions []string) (Context, string, []string) {
-	return context, "", nil
+func (s *StateBase) NoMatch(context []string, transitions []string) ([]string, string, []string, error) {
+	return context, "", nil, nil
 }
 
 /*
@@ -621,7 +807,7 @@ func (s *State) noMatch(context Context, transitions []string) (Context, string,
 
    Parameter `context`: application-defined storage.
 */
-func (s *State) bof(context Context) (Context, []string) {
+func (s *StateBase) Bof(context []string) ([]string, []string) {
 	return context, nil
 }
 
@@ -632,8 +818,8 @@ func (s *State) bof(context Context) (Context, []string) {
 
    Parameter `context`: application-defined storage.
 */
-func (s *State) eof(context Context) []string {
-	return nil
+func (s *StateBase) Eof(context []string) ([]string, error) {
+	return nil, nil
 }
 
 /*
@@ -642,27 +828,80 @@ func (s *State) eof(context Context) []string {
    Return unchanged `context` & `next_state`, empty result. Useful for
    simple state changes (actionless transitions).
 */
-func (s *State) nop(match []string, context Context, nextState string) (Context, string, []string) {
-	return context, nextState, nil
+func (s *StateBase) Nop(match *Match, context []string, nextState string) ([]string, string, []string, error) {
+	return context, nextState, nil, nil
 }
 
-type Transition struct {
-	compiledPattern  *regexp.Regexp
-	transitionMethod reflect.Value
-	nextStateName    string
+/*
+   State superclass specialized for whitespace (blank lines & indents).
+
+   Use this class with `StateMachine` methods `GetIndented()`,
+   `GetKnownIndented()` and `GetFirstKnownIndented()`. `AddWSTransitions()`
+   adds the "blank" (blank lines) and "indent" (indented text blocks)
+   transitions, which are checked before the other transitions.
+*/
+type StateWS struct {
+	StateBase
 }
 
-type TransitionNameAndNextState struct {
-	name      string
-	nextState string
+var wsPatterns = map[string]*regexp.Regexp{
+	"blank":  regexp.MustCompile(`^ *$`),
+	"indent": regexp.MustCompile(`^ +`),
 }
 
-type SmKwargs struct {
-	stateClasses []reflect.Type
-	initialState string
+/*
+   Add the whitespace transitions to the start of the transition list:
+   "blank" with the method `blank`, then "indent" with the method `indent`.
+*/
+func (s *StateWS) AddWSTransitions(blank, indent TransitionMethod) {
+	s.AddTransitions([]string{"blank", "indent"}, map[string]Transition{
+		"blank":  {wsPatterns["blank"], blank, ""},
+		"indent": {wsPatterns["indent"], indent, ""},
+	})
+}
+
+/*
+   Return a list of one-line strings with tabs expanded, no newlines, and
+   trailing whitespace stripped.
+
+   Each tab is expanded with between 1 and `tabWidth` spaces, so that the
+   next character's index becomes a multiple of `tabWidth` (8 by default).
+   Vertical tabs and form feeds are converted to spaces.
+*/
+func String2Lines(astring string, tabWidth int) []string {
+	astring = strings.NewReplacer("\v", " ", "\f", " ", "\r\n", "\n", "\r", "\n").Replace(astring)
+	astring = strings.TrimSuffix(astring, "\n")
+	if astring == "" {
+		return nil
+	}
+	var lines []string
+	for _, line := range strings.Split(astring, "\n") {
+		lines = append(lines, strings.TrimRightFunc(expandTabs(line, tabWidth), unicode.IsSpace))
+	}
+	return lines
 }
 
-type Context string
+// Expand the tabs of `line` like Python's str.expandtabs().
+func expandTabs(line string, tabWidth int) string {
+	if !strings.Contains(line, "\t") {
+		return line
+	}
+	var b strings.Builder
+	column := 0
+	for _, r := range line {
+		if r == '\t' {
+			if tabWidth > 0 {
+				spaces := tabWidth - column%tabWidth
+				b.WriteString(strings.Repeat(" ", spaces))
+				column += spaces
+			}
+			continue
+		}
+		b.WriteRune(r)
+		column++
+	}
+	return b.String()
+}
 
 func File2lines(filePath string) []string {
 	f, err := os.Open(filePath)
@@ -63,6 +63,20 @@ func (v *StringList) Init(initlist []string, source string, items []StringListIt
 	}
 }
 
+/*
+   Return a new StringList of `lines` read from `source`, the first line at
+   the offset `offset` from the beginning of the source.
+*/
+func NewStringList(lines []string, source string, offset int) StringList {
+	items := make([]StringListItem, len(lines))
+	for i := range lines {
+		items[i] = StringListItem{source, offset + i}
+	}
+	v := StringList{}
+	v.Init(lines, source, items, nil, 0)
+	return v
+}
+
 func (v *StringList) Contains(item string) bool {
 	for _, d := range v.data {
 		if d == item {
@@ -83,9 +97,25 @@ func (v *StringList) GetItem(index int) (string, error) {
 	return "", &IndexError{"index error"}
 }
 
+// Return the lines of the list.
+func (v *StringList) Lines() []string {
+	return v.data
+}
+
+/*
+   Return the items from `start` to `stop` (clipped to the list bounds) as a
+   child list of `v`: a copy of the lines, keeping their source and offset
+   information.
+*/
 func (v *StringList) GetItemsSlice(start, stop int) StringList {
+	if stop > len(v.data) {
+		stop = len(v.data)
+	}
+	if start > stop {
+		start = stop
+	}
 	vl := StringList{}
-	vl.Init(v.data[start:stop], "", v.items, v, start)
+	vl.Init(append([]string{}, v.data[start:stop]...), "", append([]StringListItem{}, v.items[start:stop]...), v, start)
 	return vl
 }
 
@@ -232,7 +262,7 @@ func (v *StringList) Info(i int) (StringListItem, error) {
 	if i < len(v.items) {
 		return v.items[i], nil
 	} else {
-		if i == len(v.data) { // Just past the end
+		if i == len(v.data) && i > 0 { // Just past the end
 			return StringListItem{v.items[i-1].source, -1}, nil
 		} else {
 			return StringListItem{}, &IndexError{"StringList Info IndexError"}
@@ -275,24 +305,118 @@ func (s *StringList) TrimLeft(length, start, end int) {
 
    If `flush_left` is true, raise `UnexpectedIndentationError` if an
    indented line is encountered before the text block ends (with a blank
-   line).
+   line). The error holds the block up to the indented line.
 */
 func (s *StringList) GetTextBlock(start int, flushLeft bool) (StringList, error) {
 	end := start
 	last := len(s.data)
 	for end < last {
 		line := s.data[end]
-		if strings.TrimSpace(line) != "" {
+		if strings.TrimSpace(line) == "" {
 			break
 		}
 		if flushLeft && line[0] == ' ' {
-			return StringList{}, &UnexpectedIndentationError{"UnexpectedIndentationError StringList GetTextBlock"}
+			info, _ := s.Info(end)
+			block := s.GetItemsSlice(start, end)
+			return block, &UnexpectedIndentationError{"Unexpected indentation.", block, info.source, info.offset + 1}
 		}
 		end += 1
 	}
-	result := StringList{}
-	result.Init(s.data[start:end], "", s.items[start:end], s.parent, s.parentOffset)
-	return result, nil
+	return s.GetItemsSlice(start, end), nil
+}
+
+/*
+   Extract and return a StringList of indented lines of text.
+
+   Collect all lines with indentation, determine the minimum indentation,
+   remove the minimum indentation from all indented lines (unless
+   `stripIndent` is false), and return them. All lines up to but not
+   including the first unindented line will be returned.
+
+   Parameters:
+
+   - `start`: The index of the first line to examine.
+   - `untilBlank`: Stop collecting at the first blank line if true.
+   - `stripIndent`: Strip common leading indent if true.
+   - `blockIndent`: The indent of the entire block, if known; -1 if not.
+   - `firstIndent`: The indent of the first line, if known; -1 if not.
+
+   Indents are counted in characters. Return:
+
+   - the indented block (a StringList), with the first `firstIndent`
+     characters of its first line removed;
+   - its indent;
+   - a boolean: did the indented block finish with a blank line or EOF?
+*/
+func (s *StringList) GetIndented(start int, untilBlank, stripIndent bool, blockIndent, firstIndent int) (StringList, int, bool) {
+	indent := blockIndent // start with -1 if unknown
+	end := start
+	if blockIndent >= 0 && firstIndent < 0 {
+		firstIndent = blockIndent
+	}
+	if firstIndent >= 0 {
+		end += 1
+	}
+	last := len(s.data)
+	blankFinish := true
+	for end < last {
+		line := s.data[end]
+		if line != "" && (line[0] != ' ' ||
+			blockIndent >= 0 && strings.TrimSpace(line[:minInt(blockIndent, len(line))]) != "") {
+			// Line not indented or insufficiently indented.
+			// Block finished properly iff the last indented line blank:
+			blankFinish = end > start && strings.TrimSpace(s.data[end-1]) == ""
+			break
+		}
+		stripped := strings.TrimLeft(line, " ")
+		if stripped == "" { // blank line
+			if untilBlank {
+				blankFinish = true
+				break
+			}
+		} else if blockIndent < 0 {
+			lineIndent := len(line) - len(stripped)
+			if indent < 0 || lineIndent < indent {
+				indent = lineIndent
+			}
+		}
+		end += 1
+	}
+	block := s.GetItemsSlice(start, end)
+	if firstIndent >= 0 && len(block.data) > 0 {
+		block.data[0] = trimChars(block.data[0], firstIndent)
+	}
+	if indent > 0 && stripIndent {
+		from := 0
+		if firstIndent >= 0 {
+			from = 1
+		}
+		for i := from; i < len(block.data); i++ {
+			block.data[i] = trimChars(block.data[i], indent)
+		}
+	}
+	if indent < 0 {
+		indent = 0
+	}
+	return block, indent, blankFinish
+}
+
+// Return `line` without its first `n` characters.
+func trimChars(line string, n int) string {
+	for i := range line {
+		if n == 0 {
+			return line[i:]
+		}
+		n--
+	}
+	return ""
+}
+
+func minInt(a, b int) int {
+	if a < b {
+		return a
+	}
+	return b
 }
 
 // Replace all occurrences of substring `oldStr` with `newStr`.
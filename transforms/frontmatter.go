@@ -0,0 +1,611 @@
+package transforms
+
+/*
+Implementation of front matter transforms in Python docutils: document title
+and subtitle promotion, section subtitles and bibliographic fields (docinfo)
+
+URL of Python source code:
+http://sourceforge.net/p/docutils/code/HEAD/tree/trunk/docutils/docutils/transforms/frontmatter.py
+*/
+
+import (
+	"regexp"
+	"strings"
+
+	rst "github.com/siongui/go-rst"
+)
+
+/*
+   Return the index of the first child of `node` which is not
+   pre-bibliographic (see `rst.PreBibliographic`), -1 if there is none.
+*/
+func firstNonPreBibliographic(node *rst.Element) int {
+	for i, child := range node.Children() {
+		if e, ok := child.(*rst.Element); !ok || !e.Is(rst.PreBibliographic) {
+			return i
+		}
+	}
+	return -1
+}
+
+/*
+   Find and return the promotion candidate of `node` and its index: the
+   only non-pre-bibliographic child, if it is a section starting with a
+   title. Return (nil, -1) if no valid candidate was found.
+*/
+func promotionCandidate(node *rst.Element) (*rst.Element, int) {
+	index := firstNonPreBibliographic(node)
+	if index < 0 || node.Len() > index+1 {
+		return nil, -1
+	}
+	section, ok := node.Children()[index].(*rst.Element)
+	if !ok || section.TagName() != "section" {
+		return nil, -1
+	}
+	// sections of documents not parsed from reST source (e.g. Docutils
+	// XML) may lack a title
+	if children := section.Children(); len(children) == 0 || children[0].TagName() != "title" {
+		return nil, -1
+	}
+	return section, index
+}
+
+/*
+   Transform the following tree::
+
+       <node>
+           <section>
+               <title>
+               ...
+
+   into ::
+
+       <node>
+           <title>
+           ...
+
+   `node` is normally a document and must not have a title yet. Return
+   whether a title was promoted.
+*/
+func promoteTitle(node *rst.Element) bool {
+	section, index := promotionCandidate(node)
+	if section == nil {
+		return false
+	}
+	// Transfer the section's attributes to the node:
+	node.UpdateAttributes(section)
+	children := node.Children()
+	sectionChildren := section.Children()
+	var newChildren []rst.Node
+	newChildren = append(newChildren, sectionChildren[:1]...) // section title
+	// everything that was in the node before the section
+	newChildren = append(newChildren, children[:index]...)
+	// everything that was in the section
+	newChildren = append(newChildren, sectionChildren[1:]...)
+	node.SetChildren(newChildren)
+	return true
+}
+
+/*
+   Transform the following node tree::
+
+       <node>
+           <title>
+           <section>
+               <title>
+               ...
+
+   into ::
+
+       <node>
+           <title>
+           <subtitle>
+           ...
+
+   Return whether a subtitle was promoted.
+*/
+func promoteSubtitle(node *rst.Element) bool {
+	subsection, index := promotionCandidate(node)
+	if subsection == nil {
+		return false
+	}
+	subtitle := &rst.Element{}
+	subtitle.Init("subtitle", "", "")
+	// Transfer the subsection's attributes to the new subtitle:
+	subtitle.UpdateAttributes(subsection)
+	// Transfer the contents of the subsection's title to the subtitle:
+	sectionChildren := subsection.Children()
+	if title, ok := sectionChildren[0].(*rst.Element); ok {
+		subtitle.Extend(title.Children()...)
+	}
+	children := node.Children()
+	var newChildren []rst.Node
+	newChildren = append(newChildren, children[:1]...) // title
+	newChildren = append(newChildren, subtitle)
+	// everything that was before the section
+	newChildren = append(newChildren, children[1:index]...)
+	// everything that was in the subsection
+	newChildren = append(newChildren, sectionChildren[1:]...)
+	node.SetChildren(newChildren)
+	return true
+}
+
+/*
+   Return the front matter transforms of reStructuredText documents, in no
+   particular order (the Transformer sorts them by priority).
+*/
+func FrontmatterTransforms() []rst.Transform {
+	return []rst.Transform{
+		&DocTitle{},
+		&SectionSubTitle{},
+		&DocInfo{},
+	}
+}
+
+/*
+   In reStructuredText, there is no way to specify a document title and
+   subtitle explicitly. Instead, we can supply the document title (and
+   possibly the subtitle as well) implicitly, and use this two-step
+   transform to "raise" or "promote" the title(s) (and their corresponding
+   section contents) to the document level.
+
+    1. If the document contains a single top-level section as its first
+     non-comment element, the top-level section's title becomes the
+     document's title, and the top-level section's contents become the
+     document's immediate contents. The lone top-level section header must
+     be the first non-comment element in the document.
+
+    2. If step 1 successfully determines the document title, we continue by
+     checking for a subtitle. If the lone top-level section itself contains
+     a single second-level section as its first non-comment element, that
+     section's title is promoted to the document's subtitle, and that
+     section's contents become the document's immediate contents.
+
+   Any comment elements occurring before the document title or subtitle are
+   accumulated and inserted as the first body elements after the
+   title(s). The promotion can be disabled with the `DoctitleXform`
+   setting.
+
+   This transform also sets the document's metadata title (the "title"
+   attribute): the `Title` setting if given, the document title otherwise.
+*/
+type DocTitle struct{}
+
+func (t *DocTitle) DefaultPriority() int {
+	return 320
+}
+
+func (t *DocTitle) Apply(document *rst.Document, startnode *rst.Element) error {
+	if document.Settings().DoctitleXform {
+		if promoteTitle(&document.Element) {
+			// If a title has been promoted, also try to promote a
+			// subtitle.
+			promoteSubtitle(&document.Element)
+		}
+	}
+	// Set document["title"].
+	if !document.HasAttr("title") {
+		if title := document.Settings().Title; title != "" {
+			document.Set("title", title)
+		} else if document.Len() > 0 && document.Children()[0].TagName() == "title" {
+			document.Set("title", document.Children()[0].AsText())
+		}
+	}
+	return nil
+}
+
+/*
+   This works like document subtitles, but for sections. For example, ::
+
+       <section>
+           <title>
+               Title
+           <section>
+               <title>
+                   Subtitle
+               ...
+
+   is transformed into ::
+
+       <section>
+           <title>
+               Title
+           <subtitle>
+               Subtitle
+           ...
+
+   For details refer to the docstring of DocTitle. Enabled with the
+   `SectsubtitleXform` setting.
+*/
+type SectionSubTitle struct{}
+
+func (t *SectionSubTitle) DefaultPriority() int {
+	return 350
+}
+
+func (t *SectionSubTitle) Apply(document *rst.Document, startnode *rst.Element) error {
+	if !document.Settings().SectsubtitleXform {
+		return nil
+	}
+	sectionSubTitles(&document.Element)
+	return nil
+}
+
+/*
+   Promote the subtitles of `node` if it is a section, then of its
+   descendant sections. Children are looked up after the promotion, as it
+   replaces a subsection by its contents.
+*/
+func sectionSubTitles(node *rst.Element) {
+	if node.TagName() == "section" {
+		promoteSubtitle(node)
+	}
+	for _, child := range node.Children() {
+		if e, ok := child.(*rst.Element); ok {
+			sectionSubTitles(e)
+		}
+	}
+}
+
+// Element tags of the bibliographic fields, by canonical field name.
+var biblioNodes = map[string]string{
+	"author":       "author",
+	"authors":      "authors",
+	"organization": "organization",
+	"address":      "address",
+	"contact":      "contact",
+	"version":      "version",
+	"revision":     "revision",
+	"status":       "status",
+	"date":         "date",
+	"copyright":    "copyright",
+	"dedication":   "topic",
+	"abstract":     "topic",
+}
+
+type rcsKeywordSubstitution struct {
+	pattern     *regexp.Regexp
+	replacement string
+}
+
+var rcsKeywordSubstitutions = []rcsKeywordSubstitution{
+	{regexp.MustCompile(`(?i)\$` + `Date: (\d\d\d\d)[-/](\d\d)[-/](\d\d)[ T][\d:]+[^$]* \$`), "$1-$2-$3"},
+	{regexp.MustCompile(`(?i)\$` + `RCSfile: (.+),v \$`), "$1"},
+	{regexp.MustCompile(`\$[a-zA-Z]+: (.+) \$`), "$1"},
+}
+
+/*
+   Replace the first matching RCS keyword of a paragraph consisting of a
+   single text node ("$Date: 2002-08-10 ... $" becomes "2002-08-10").
+*/
+func cleanRcsKeywords(paragraph *rst.Element) {
+	if paragraph.Len() != 1 {
+		return
+	}
+	text, ok := paragraph.Children()[0].(*rst.Text)
+	if !ok {
+		return
+	}
+	for _, sub := range rcsKeywordSubstitutions {
+		if sub.pattern.MatchString(text.AsText()) {
+			text.SetText(sub.pattern.ReplaceAllString(text.AsText(), sub.replacement))
+			return
+		}
+	}
+}
+
+// Error value signalling a field which cannot be transformed.
+type bibliographicFieldError struct{}
+
+func (e *bibliographicFieldError) Error() string {
+	return "cannot extract bibliographic field"
+}
+
+/*
+   This transform is specific to the reStructuredText markup syntax; see
+   "Bibliographic Fields" in the reStructuredText Markup Specification for a
+   high-level description. This transform should be run *after* the
+   `DocTitle` transform.
+
+   Given a field list as the first non-comment element after the document
+   title and subtitle (if present), registered bibliographic field names
+   are transformed to the corresponding DTD elements, becoming child
+   elements of the "docinfo" element (except for a dedication and/or an
+   abstract, which become "topic" elements after "docinfo").
+
+   For example, given this document fragment after parsing::
+
+       <document>
+           <title>
+               Document Title
+           <field_list>
+               <field>
+                   <field_name>
+                       Author
+                   <field_body>
+                       <paragraph>
+                           A. Name
+               <field>
+                   <field_name>
+                       Status
+                   <field_body>
+                       <paragraph>
+                           $RCSfile$
+           ...
+
+   After running the bibliographic field list transform, the resulting
+   document tree would look like this::
+
+       <document>
+           <title>
+               Document Title
+           <docinfo>
+               <author>
+                   A. Name
+               <status>
+                   frontmatter.py
+           ...
+
+   The "Status" field contained an expanded RCS keyword, which is normally
+   (but optionally) cleaned up by the transform. The sole contents of the
+   field body must be a paragraph containing an expanded RCS keyword of the
+   form "$keyword: expansion text $". Any RCS keyword can be processed in
+   any bibliographic field. The dollar signs and leading RCS keyword name
+   are removed. Extra processing is done for the following RCS keywords:
+
+   - "RCSfile" expands to the name of the file in the RCS or CVS
+     repository, which is the name of the source file with a ",v" suffix
+     appended. The transform will remove the ",v" suffix.
+
+   - "Date" expands to the format "YYYY/MM/DD hh:mm:ss" (in the UTC time
+     zone). The RCS Keywords transform will extract just the date itself
+     and transform it to an ISO 8601 format date, as in "2000-12-31".
+
+   The transform can be disabled with the `DocinfoXform` setting.
+*/
+type DocInfo struct{}
+
+func (t *DocInfo) DefaultPriority() int {
+	return 340
+}
+
+func (t *DocInfo) Apply(document *rst.Document, startnode *rst.Element) error {
+	if !document.Settings().DocinfoXform {
+		return nil
+	}
+	index := firstNonPreBibliographic(&document.Element)
+	if index < 0 {
+		return nil
+	}
+	candidate, ok := document.Children()[index].(*rst.Element)
+	if !ok || candidate.TagName() != "field_list" {
+		return nil
+	}
+	biblioindex := 0
+	for _, child := range document.Children() {
+		e, ok := child.(*rst.Element)
+		if !ok || !(e.Is(rst.Titular) || e.Is(rst.Decorative) || e.TagName() == "meta") {
+			break
+		}
+		biblioindex++
+	}
+	nodelist := t.extractBibliographic(document, candidate)
+	document.Remove(candidate) // untransformed field list
+	document.Insert(biblioindex, nodelist...)
+	return nil
+}
+
+func (t *DocInfo) extractBibliographic(document *rst.Document, fieldList *rst.Element) []rst.Node {
+	docinfo := &rst.Element{}
+	docinfo.Init("docinfo", "", "")
+	language := rst.GetLanguage(document.Settings().LanguageCode)
+	bibliofields := language.BibliographicFields()
+	topics := map[string]*rst.Element{}
+	for _, child := range fieldList.Children() {
+		field, ok := child.(*rst.Element)
+		if !ok || field.Len() == 0 {
+			continue
+		}
+		name := field.Children()[0].AsText()
+		normedname := rst.FullyNormalizeName(name)
+		if err := t.extractField(document, docinfo, topics, field, name, normedname, bibliofields, language); err != nil {
+			body := field.Children()[field.Len()-1].(*rst.Element)
+			if body.Len() == 1 && body.Children()[0].TagName() == "paragraph" {
+				cleanRcsKeywords(body.Children()[0].(*rst.Element))
+			}
+			if classvalue := rst.MakeID(normedname); classvalue != "" {
+				field.Classes = append(field.Classes, classvalue)
+			}
+			docinfo.Append(field)
+		}
+	}
+	var nodelist []rst.Node
+	if docinfo.Len() != 0 {
+		nodelist = append(nodelist, docinfo)
+	}
+	for _, name := range []string{"dedication", "abstract"} {
+		if topic, ok := topics[name]; ok {
+			nodelist = append(nodelist, topic)
+		}
+	}
+	return nodelist
+}
+
+/*
+   Transform a single bibliographic `field` and add the result to
+   `docinfo` (or to `topics`). A non-nil error means the field is kept
+   untransformed in the docinfo.
+*/
+func (t *DocInfo) extractField(document *rst.Document, docinfo *rst.Element, topics map[string]*rst.Element, field *rst.Element, name, normedname string, bibliofields map[string]string, language *rst.Language) error {
+	canonical, ok := bibliofields[normedname]
+	if !(field.Len() == 2 && ok && t.checkEmptyBiblioField(document, field, name)) {
+		return &bibliographicFieldError{}
+	}
+	body := field.Children()[1].(*rst.Element)
+	biblioclass := biblioNodes[canonical]
+	switch biblioclass {
+	case "authors":
+		return t.extractAuthors(document, docinfo, field, name, language)
+	case "topic":
+		if _, ok := topics[canonical]; ok {
+			body.Append(document.Reporter().Warning("There can only be one \""+name+"\" field.",
+				field.Source(), field.Line()))
+			return &bibliographicFieldError{}
+		}
+		title := &rst.Element{}
+		title.Init("title", name, language.Labels[canonical])
+		topic := &rst.Element{}
+		topic.Init("topic", "", "", title)
+		topic.Classes = append(topic.Classes, canonical)
+		topic.Extend(body.Children()...)
+		topics[canonical] = topic
+	case "address":
+		// not a text element: keep the field body contents as is
+		node := &rst.Element{}
+		node.Init(biblioclass, "", "", body.Children()...)
+		docinfo.Append(node)
+	default:
+		if !t.checkCompoundBiblioField(document, field, name) {
+			return &bibliographicFieldError{}
+		}
+		paragraph := body.Children()[0].(*rst.Element)
+		cleanRcsKeywords(paragraph)
+		node := &rst.Element{}
+		node.Init(biblioclass, "", "", paragraph.Children()...)
+		docinfo.Append(node)
+	}
+	return nil
+}
+
+func (t *DocInfo) checkEmptyBiblioField(document *rst.Document, field *rst.Element, name string) bool {
+	body := field.Children()[field.Len()-1].(*rst.Element)
+	if body.Len() < 1 {
+		body.Append(document.Reporter().Warning("Cannot extract empty bibliographic field \""+name+"\".",
+			field.Source(), field.Line()))
+		return false
+	}
+	return true
+}
+
+/*
+   Check that the field body contains a single paragraph (i.e. it must
+   *not* be a compound element).
+*/
+func (t *DocInfo) checkCompoundBiblioField(document *rst.Document, field *rst.Element, name string) bool {
+	body := field.Children()[field.Len()-1].(*rst.Element)
+	if body.Len() > 1 {
+		body.Append(document.Reporter().Warning("Cannot extract compound bibliographic field \""+name+"\".",
+			field.Source(), field.Line()))
+		return false
+	}
+	if body.Children()[0].TagName() != "paragraph" {
+		body.Append(document.Reporter().Warning("Cannot extract bibliographic field \""+name+
+			"\" containing anything other than a single paragraph.", field.Source(), field.Line()))
+		return false
+	}
+	return true
+}
+
+func (t *DocInfo) extractAuthors(document *rst.Document, docinfo *rst.Element, field *rst.Element, name string, language *rst.Language) error {
+	body := field.Children()[1].(*rst.Element)
+	var authors [][]rst.Node
+	var err error
+	if body.Len() == 1 {
+		switch body.Children()[0].TagName() {
+		case "paragraph":
+			authors, err = authorsFromOneParagraph(body, language)
+		case "bullet_list":
+			authors, err = authorsFromBulletList(body)
+		default:
+			err = &bibliographicFieldError{}
+		}
+	} else {
+		authors, err = authorsFromParagraphs(body)
+	}
+	authorsNode := &rst.Element{}
+	authorsNode.Init("authors", "", "")
+	if err == nil {
+		for _, author := range authors {
+			if len(author) == 0 {
+				continue
+			}
+			node := &rst.Element{}
+			node.Init("author", "", "", author...)
+			authorsNode.Append(node)
+		}
+		if authorsNode.Len() == 0 {
+			err = &bibliographicFieldError{}
+		}
+	}
+	if err != nil {
+		body.Append(document.Reporter().Warning("Cannot extract \""+name+"\" from bibliographic field:\n"+
+			"Bibliographic field \""+name+"\" must contain either\n"+
+			" a single paragraph (with author names separated by one of \""+
+			strings.Join(language.AuthorSeparators(), "")+"\"),\n"+
+			" multiple paragraphs (one per author),\n"+
+			" or a bullet list with one author name per item.",
+			field.Source(), field.Line()))
+		return err
+	}
+	docinfo.Append(authorsNode)
+	return nil
+}
+
+/*
+   Return the author names of the single paragraph in `body`, separated by
+   one of the author separators of `language`.
+*/
+func authorsFromOneParagraph(body *rst.Element, language *rst.Language) ([][]rst.Node, error) {
+	text := body.Children()[0].AsText()
+	if text == "" {
+		return nil, &bibliographicFieldError{}
+	}
+	var authornames []string
+	for _, authorsep := range language.AuthorSeparators() {
+		authornames = strings.Split(text, authorsep)
+		if len(authornames) > 1 {
+			break
+		}
+	}
+	var authors [][]rst.Node
+	for _, name := range authornames {
+		if name = strings.TrimSpace(name); name != "" {
+			text := &rst.Text{}
+			text.SetText(name)
+			authors = append(authors, []rst.Node{text})
+		}
+	}
+	return authors, nil
+}
+
+func authorsFromBulletList(body *rst.Element) ([][]rst.Node, error) {
+	var authors [][]rst.Node
+	for _, child := range body.Children()[0].(*rst.Element).Children() {
+		item := child.(*rst.Element)
+		if item.TagName() == "comment" {
+			continue
+		}
+		if item.Len() != 1 || item.Children()[0].TagName() != "paragraph" {
+			return nil, &bibliographicFieldError{}
+		}
+		authors = append(authors, item.Children()[0].(*rst.Element).Children())
+	}
+	if len(authors) == 0 {
+		return nil, &bibliographicFieldError{}
+	}
+	return authors, nil
+}
+
+func authorsFromParagraphs(body *rst.Element) ([][]rst.Node, error) {
+	var authors [][]rst.Node
+	for _, child := range body.Children() {
+		switch child.TagName() {
+		case "paragraph":
+			authors = append(authors, child.(*rst.Element).Children())
+		case "comment":
+		default:
+			return nil, &bibliographicFieldError{}
+		}
+	}
+	return authors, nil
+}
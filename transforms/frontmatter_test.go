@@ -0,0 +1,131 @@
+package transforms
+
+import (
+	"testing"
+
+	rst "github.com/siongui/go-rst"
+	"github.com/siongui/go-rst/parsers/docutilsxml"
+)
+
+func TestFrontmatter(t *testing.T) {
+	settings := &rst.Settings{}
+	settings.Init()
+	settings.WarningStream = nil
+	settings.SectsubtitleXform = true
+	input := `<document source="test">
+    <comment>comment</comment>
+    <section ids="title" names="title">
+        <title>Title</title>
+        <section>
+            <title>Subtitle</title>
+            <field_list>
+                <field><field_name>Author</field_name><field_body><paragraph>A. Name</paragraph></field_body></field>
+                <field><field_name>Authors</field_name><field_body><paragraph>One; Two</paragraph></field_body></field>
+                <field><field_name>Date</field_name><field_body><paragraph>$` + `Date: 2002-08-10 12:00:00 $</paragraph></field_body></field>
+                <field><field_name>Version</field_name><field_body><paragraph>1.0</paragraph><paragraph>2.0</paragraph></field_body></field>
+                <field><field_name>Dedication</field_name><field_body><paragraph>To you.</paragraph></field_body></field>
+                <field><field_name>Custom</field_name><field_body><paragraph>Value</paragraph></field_body></field>
+            </field_list>
+            <section>
+                <title>Section</title>
+                <section>
+                    <title>Section Subtitle</title>
+                    <paragraph>Text.</paragraph>
+                </section>
+            </section>
+        </section>
+    </section>
+</document>`
+	document, err := docutilsxml.ParseDocument(input, "test", settings)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	transformer := &Transformer{}
+	transformer.Init(document)
+	transformer.AddTransforms(FrontmatterTransforms())
+	if err := transformer.ApplyTransforms(); err != nil {
+		t.Fatal(err)
+	}
+
+	if title := document.Get("title"); title != "Title" {
+		t.Errorf("document title: expected \"Title\", got %q", title)
+	}
+	expected := `<document ids="title" names="title" source="test" title="Title">
+    <title>
+        Title
+    <subtitle>
+        Subtitle
+    <docinfo>
+        <author>
+            A. Name
+        <authors>
+            <author>
+                One
+            <author>
+                Two
+        <date>
+            2002-08-10
+        <field classes="version">
+            <field_name>
+                Version
+            <field_body>
+                <paragraph>
+                    1.0
+                <paragraph>
+                    2.0
+                <system_message level="2" source="test" type="WARNING">
+                    <paragraph>
+                        Cannot extract compound bibliographic field "Version".
+        <field classes="custom">
+            <field_name>
+                Custom
+            <field_body>
+                <paragraph>
+                    Value
+    <topic classes="dedication">
+        <title>
+            Dedication
+        <paragraph>
+            To you.
+    <comment>
+        comment
+    <section>
+        <title>
+            Section
+        <subtitle>
+            Section Subtitle
+        <paragraph>
+            Text.
+`
+	if result := document.Pformat("    ", 0); result != expected {
+		t.Errorf("unexpected document:\n%s\nexpected:\n%s", result, expected)
+	}
+}
+
+func TestUntitledSection(t *testing.T) {
+	for _, input := range []string{
+		"<document><section/></document>",
+		"<document><section><paragraph>Text.</paragraph></section></document>",
+	} {
+		settings := &rst.Settings{}
+		settings.Init()
+		settings.WarningStream = nil
+		settings.SectsubtitleXform = true
+		document, err := docutilsxml.ParseDocument(input, "test", settings)
+		if err != nil {
+			t.Fatal(err)
+		}
+		expected := document.Pformat("    ", 0)
+
+		transformer := &Transformer{}
+		transformer.Init(document)
+		transformer.AddTransforms(FrontmatterTransforms())
+		if err := transformer.ApplyTransforms(); err != nil {
+			t.Fatal(err)
+		}
+		if result := document.Pformat("    ", 0); result != expected {
+			t.Errorf("section without title promoted:\n%s", result)
+		}
+	}
+}
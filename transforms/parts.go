@@ -0,0 +1,253 @@
+package transforms
+
+/*
+Implementation of document tree parts transforms in Python docutils:
+section numbering and table of contents generation
+
+URL of Python source code:
+http://sourceforge.net/p/docutils/code/HEAD/tree/trunk/docutils/docutils/transforms/parts.py
+*/
+
+import (
+	"math"
+	"strconv"
+	"strings"
+
+	rst "github.com/siongui/go-rst"
+)
+
+/*
+   Automatically assigns numbers to the titles of document sections.
+
+   It is possible to limit the maximum section level for which the numbers
+   are added. For those sections that are auto-numbered, the "autonum"
+   attribute is set, informing the contents table generator that a
+   different form of the TOC should be used.
+*/
+type SectNum struct{}
+
+func (t *SectNum) DefaultPriority() int {
+	return 710
+}
+
+/*
+   Apply the transform; `startnode` is the pending element left by the
+   `sectnum` directive. Its details may hold "depth" and "start" (int),
+   "prefix" and "suffix" (string).
+*/
+func (t *SectNum) Apply(document *rst.Document, startnode *rst.Element) error {
+	n := sectNumber{maxdepth: -1, startvalue: 1}
+	if depth, ok := startnode.Details["depth"]; ok {
+		n.maxdepth = depth.(int)
+	}
+	if start, ok := startnode.Details["start"]; ok {
+		n.startvalue = start.(int)
+	}
+	if prefix, ok := startnode.Details["prefix"]; ok {
+		n.prefix = prefix.(string)
+	}
+	if suffix, ok := startnode.Details["suffix"]; ok {
+		n.suffix = suffix.(string)
+	}
+	startnode.Parent().Remove(startnode)
+	settings := document.Settings()
+	if settings.SectnumXform {
+		if n.maxdepth < 0 {
+			n.maxdepth = math.MaxInt32
+		}
+		n.updateSectionNumbers(&document.Element, nil, 0)
+	} else {
+		// store details for eventual section numbering by the writer
+		settings.SectnumDepth = n.maxdepth
+		settings.SectnumStart = n.startvalue
+		settings.SectnumPrefix = n.prefix
+		settings.SectnumSuffix = n.suffix
+	}
+	return nil
+}
+
+type sectNumber struct {
+	maxdepth   int
+	startvalue int
+	prefix     string
+	suffix     string
+}
+
+func (n *sectNumber) updateSectionNumbers(node *rst.Element, prefix []string, depth int) {
+	depth++
+	sectnum := n.startvalue
+	if len(prefix) > 0 {
+		sectnum = 1
+	}
+	for _, child := range node.Children() {
+		section, ok := child.(*rst.Element)
+		if !ok || section.TagName() != "section" {
+			continue
+		}
+		// sections of documents not parsed from reST source (e.g.
+		// Docutils XML) may lack a title
+		if section.Len() == 0 || section.Children()[0].TagName() != "title" {
+			continue
+		}
+		numbers := append(append([]string(nil), prefix...), strconv.Itoa(sectnum))
+		title := section.Children()[0].(*rst.Element)
+		// Use &nbsp; for spacing:
+		generated := &rst.Element{}
+		generated.Init("generated", "", n.prefix+strings.Join(numbers, ".")+n.suffix+strings.Repeat("\u00a0", 3))
+		generated.Classes = append(generated.Classes, "sectnum")
+		title.Insert(0, generated)
+		title.Set("auto", "1")
+		if depth < n.maxdepth {
+			n.updateSectionNumbers(section, numbers, depth)
+		}
+		sectnum++
+	}
+}
+
+/*
+   This transform generates a table of contents from the entire document
+   tree or from a single branch. It locates "section" elements and builds
+   them into a nested bullet list, which is placed within a "topic"
+   created by the contents directive. A title is either explicitly
+   specified, taken from the appropriate language module, or omitted
+   (local table of contents). The depth may be specified. Two-way
+   references between the table of contents and section titles are
+   generated (requires Writer support).
+
+   This transform requires a startnode, which contains generation options
+   and provides the location for the generated table of contents (the
+   startnode is replaced by the table of contents "topic").
+*/
+type Contents struct{}
+
+func (t *Contents) DefaultPriority() int {
+	return 720
+}
+
+/*
+   Apply the transform; `startnode` is the pending element left by the
+   `contents` directive. Its details may hold "local" (any value),
+   "depth" (int) and "backlinks" (string: "entry", "top", or "" for none).
+*/
+func (t *Contents) Apply(document *rst.Document, startnode *rst.Element) error {
+	details := startnode.Details
+	var root *rst.Element
+	if _, ok := details["local"]; ok {
+		root = startnode.Parent().Parent()
+		for root.TagName() != "section" && root.TagName() != "document" {
+			// find the ToC root: a direct ancestor of startnode
+			root = root.Parent()
+		}
+	} else {
+		root = &document.Element
+	}
+	b := contentsBuilder{
+		document:  document,
+		startnode: startnode,
+		tocID:     document.SetID(startnode.Parent(), nil),
+		backlinks: document.Settings().TocBacklinks,
+		depth:     math.MaxInt32,
+	}
+	if backlinks, ok := details["backlinks"]; ok {
+		b.backlinks = backlinks.(string)
+	}
+	if depth, ok := details["depth"]; ok {
+		b.depth = depth.(int)
+	}
+	contents := b.buildContents(root, 0)
+	if contents != nil {
+		startnode.ReplaceSelf(contents)
+	} else {
+		topic := startnode.Parent()
+		topic.Parent().Remove(topic)
+	}
+	return nil
+}
+
+type contentsBuilder struct {
+	document  *rst.Document
+	startnode *rst.Element
+	tocID     string
+	backlinks string
+	depth     int
+}
+
+func (b *contentsBuilder) buildContents(node *rst.Element, level int) *rst.Element {
+	level++
+	var entries []rst.Node
+	auto := false
+	for _, child := range node.Children() {
+		section, ok := child.(*rst.Element)
+		if !ok || section.TagName() != "section" {
+			continue
+		}
+		// sections of documents not parsed from reST source (e.g.
+		// Docutils XML) may lack a title
+		if section.Len() == 0 || section.Children()[0].TagName() != "title" {
+			continue
+		}
+		title := section.Children()[0].(*rst.Element)
+		// May be set by SectNum.
+		auto = title.HasAttr("auto")
+		reference := &rst.Element{}
+		reference.Init("reference", "", "", copyAndFilter(title)...)
+		reference.Set("refid", b.document.SetID(section, nil))
+		refID := b.document.SetID(reference, nil)
+		entry := &rst.Element{}
+		entry.Init("paragraph", "", "", reference)
+		item := &rst.Element{}
+		item.Init("list_item", "", "", entry)
+		if (b.backlinks == "entry" || b.backlinks == "top") && title.NextNode(rst.ByTag("reference")) == nil {
+			if b.backlinks == "entry" {
+				title.Set("refid", refID)
+			} else if b.backlinks == "top" {
+				title.Set("refid", b.tocID)
+			}
+		}
+		if level < b.depth {
+			if subsects := b.buildContents(section, level); subsects != nil {
+				item.Append(subsects)
+			}
+		}
+		entries = append(entries, item)
+	}
+	if len(entries) == 0 {
+		return nil
+	}
+	contents := &rst.Element{}
+	contents.Init("bullet_list", "", "", entries...)
+	if auto {
+		contents.Classes = append(contents.Classes, "auto-toc")
+	}
+	return contents
+}
+
+// Return a copy of the children of a title, with references, images,
+// etc. removed.
+func copyAndFilter(node *rst.Element) []rst.Node {
+	var result []rst.Node
+	for _, child := range node.Children() {
+		element, ok := child.(*rst.Element)
+		if !ok {
+			result = append(result, child.DeepCopy())
+			continue
+		}
+		switch element.TagName() {
+		case "citation_reference", "footnote_reference":
+		case "image":
+			if element.HasAttr("alt") {
+				text := &rst.Text{}
+				text.Init(element.Get("alt"), "")
+				result = append(result, text)
+			}
+		case "interpreted", "problematic", "reference", "target":
+			// ignore node but process children
+			result = append(result, copyAndFilter(element)...)
+		default:
+			c := element.Copy()
+			c.Extend(copyAndFilter(element)...)
+			result = append(result, c)
+		}
+	}
+	return result
+}
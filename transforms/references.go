@@ -0,0 +1,941 @@
+package transforms
+
+/*
+Implementation of reference transforms in Python docutils: substitutions,
+hyperlink targets and references, footnotes and citations
+
+URL of Python source code:
+http://sourceforge.net/p/docutils/code/HEAD/tree/trunk/docutils/docutils/transforms/references.py
+*/
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	rst "github.com/siongui/go-rst"
+)
+
+/*
+   Given the following ``document`` as input::
+
+       <document>
+           <paragraph>
+               The
+               <substitution_reference refname="biohazard">
+                   biohazard
+                symbol is deservedly scary-looking.
+           <substitution_definition name="biohazard">
+               <image alt="biohazard" uri="biohazard.png">
+
+   The ``substitution_reference`` will simply be replaced by the contents
+   of the corresponding ``substitution_definition``.
+
+   The transformed result will be::
+
+       <document>
+           <paragraph>
+               The
+               <image alt="biohazard" uri="biohazard.png">
+                symbol is deservedly scary-looking.
+           <substitution_definition name="biohazard">
+               <image alt="biohazard" uri="biohazard.png">
+
+   References are matched case-sensitively first, then case-insensitively.
+   A substitution reference inside a reference (``|name|_``) is replaced
+   inside the reference. Undefined and circular substitutions are reported
+   and the references replaced by ``problematic`` elements.
+*/
+type Substitutions struct{}
+
+func (t *Substitutions) DefaultPriority() int {
+	return 220
+}
+
+func (t *Substitutions) Apply(document *rst.Document, startnode *rst.Element) error {
+	reporter := document.Reporter()
+	// the reference a nested reference was copied in for, and the names
+	// of the substitutions it is nested in
+	refOrigin := map[*rst.Element]*rst.Element{}
+	nested := map[*rst.Element][]string{}
+	var subreflist []*rst.Element
+	for _, node := range document.Traverse(rst.ByTag("substitution_reference")) {
+		subreflist = append(subreflist, node.(*rst.Element))
+	}
+	for i := 0; i < len(subreflist); i++ {
+		ref := subreflist[i]
+		refname := ref.Get("refname")
+		key := refname
+		if document.SubstitutionDef(refname) == nil {
+			key = document.SubstitutionName(rst.FullyNormalizeName(refname))
+		}
+		if key == "" {
+			source, line := rst.GetSourceLine(ref)
+			msg := reporter.Error("Undefined substitution referenced: \""+refname+"\".", source, line)
+			replaceWithProblematic(document, ref, msg, document.SetID(msg, nil))
+			continue
+		}
+		subdef := document.SubstitutionDef(key)
+
+		parent := ref.Parent()
+		index := parent.Index(ref)
+		children := parent.Children()
+		if subdef.HasAttr("ltrim") || subdef.HasAttr("trim") {
+			if index > 0 {
+				if text, ok := children[index-1].(*rst.Text); ok {
+					text.SetText(strings.TrimRight(text.AsText(), " \t\n\r\f\v"))
+				}
+			}
+		}
+		if subdef.HasAttr("rtrim") || subdef.HasAttr("trim") {
+			if index+1 < len(children) {
+				if text, ok := children[index+1].(*rst.Text); ok {
+					text.SetText(strings.TrimLeft(text.AsText(), " \t\n\r\f\v"))
+				}
+			}
+		}
+
+		subdefCopy := subdef.DeepCopy().(*rst.Element)
+		circular := false
+		// Take care of nested substitution references:
+		chain := append(append([]string(nil), nested[ref]...), key)
+		var nestedRefs []*rst.Element
+		for _, node := range subdefCopy.Traverse(rst.ByTag("substitution_reference")) {
+			nestedRef := node.(*rst.Element)
+			nestedName := document.SubstitutionName(rst.FullyNormalizeName(nestedRef.Get("refname")))
+			if contains(chain, nestedName) {
+				circular = true
+				break
+			}
+			refOrigin[nestedRef] = ref
+			nested[nestedRef] = chain
+			nestedRefs = append(nestedRefs, nestedRef)
+		}
+		if circular {
+			if parent.TagName() == "substitution_definition" {
+				block := &rst.Element{}
+				block.Init("literal_block", parent.RawSource(), parent.RawSource())
+				msg := reporter.Error("Circular substitution definition detected:", parent.Source(), parent.Line(), block)
+				parent.Parent().Replace(parent, msg)
+			} else {
+				// find original ref substitution which caused this error
+				origin := ref
+				for refOrigin[origin] != nil {
+					origin = refOrigin[origin]
+				}
+				msg := reporter.Error("Circular substitution definition referenced: \""+refname+"\".", origin.Source(), origin.Line())
+				replaceWithProblematic(document, ref, msg, document.SetID(msg, nil))
+			}
+			continue
+		}
+		subreflist = append(subreflist, nestedRefs...)
+		// the references of the copy are resolved by later transforms
+		for _, node := range subdefCopy.Traverse(func(n rst.Node) bool {
+			e, ok := n.(*rst.Element)
+			return ok && e.Is(rst.Referential) && e.Get("refname") != ""
+		}) {
+			document.NoteRefname(node.(*rst.Element))
+		}
+		parent.Replace(ref, subdefCopy.Children()...)
+	}
+	return nil
+}
+
+/*
+   Return the transforms resolving references, in the order of their
+   priorities: substitutions, hyperlink targets and references, footnotes
+   and citations.
+*/
+func ReferenceTransforms() []rst.Transform {
+	return []rst.Transform{
+		&Substitutions{},
+		&PropagateTargets{},
+		&AnonymousHyperlinks{},
+		&IndirectHyperlinks{},
+		&Footnotes{},
+		&ExternalTargets{},
+		&InternalTargets{},
+		&DanglingReferences{},
+	}
+}
+
+/*
+   Propagate empty internal targets to the next element.
+
+   Given the following nodes::
+
+       <target ids="internal1" names="internal1">
+       <target anonymous="1" ids="id1">
+       <target ids="internal2" names="internal2">
+       <paragraph>
+           This is a test.
+
+   PropagateTargets propagates the ids and names of the internal targets
+   preceding the paragraph to the paragraph itself::
+
+       <target refid="internal1">
+       <target anonymous="1" refid="internal1">
+       <target refid="internal1">
+       <paragraph ids="internal2 id1 internal1" names="internal2 internal1">
+           This is a test.
+*/
+type PropagateTargets struct{}
+
+func (t *PropagateTargets) DefaultPriority() int {
+	return 260
+}
+
+func (t *PropagateTargets) Apply(document *rst.Document, startnode *rst.Element) error {
+	for _, node := range document.Traverse(rst.ByTag("target")) {
+		target := node.(*rst.Element)
+		// Only block-level targets without reference (like ".. _target:"):
+		if target.Parent().Is(rst.TextElementClass) ||
+			target.HasAttr("refid") || target.HasAttr("refuri") || target.HasAttr("refname") {
+			continue
+		}
+		nextNode, ok := nextNode(target).(*rst.Element)
+		// Do not move names and ids into Invisibles (we'd lose the
+		// attributes) or different Targetables (e.g. footnotes).
+		if !ok || (nextNode.Is(rst.Invisible) || nextNode.Is(rst.Targetable)) && nextNode.TagName() != "target" {
+			continue
+		}
+		nextNode.Ids = append(nextNode.Ids, target.Ids...)
+		nextNode.Names = append(nextNode.Names, target.Names...)
+		// Set defaults for nextNode.ExpectReferencedByName/ID.
+		if nextNode.ExpectReferencedByName == nil {
+			nextNode.ExpectReferencedByName = map[string]*rst.Element{}
+		}
+		if nextNode.ExpectReferencedByID == nil {
+			nextNode.ExpectReferencedByID = map[string]*rst.Element{}
+		}
+		for _, id := range target.Ids {
+			// Update IDs to node mapping.
+			document.SetElementID(id, nextNode)
+			// If nextNode is referenced by id ``id``, this target shall be
+			// marked as referenced.
+			nextNode.ExpectReferencedByID[id] = target
+		}
+		for _, name := range target.Names {
+			nextNode.ExpectReferencedByName[name] = target
+		}
+		// If there are any ExpectReferencedBy... attributes in target set,
+		// copy them to nextNode.
+		for name, node := range target.ExpectReferencedByName {
+			nextNode.ExpectReferencedByName[name] = node
+		}
+		for id, node := range target.ExpectReferencedByID {
+			nextNode.ExpectReferencedByID[id] = node
+		}
+		// Set refid to point to the first former ID of target which is now
+		// an ID of nextNode.
+		target.Set("refid", target.Ids[0])
+		// Clear ids and names; they have been moved to nextNode.
+		target.Ids = nil
+		target.Names = nil
+		document.NoteRefid(target)
+	}
+	return nil
+}
+
+// Return the node following `node` (and its descendants) in document
+// order, nil if there is none.
+func nextNode(node rst.Node) rst.Node {
+	for parent := node.Parent(); parent != nil; node, parent = parent, parent.Parent() {
+		if i := parent.Index(node); i+1 < parent.Len() {
+			return parent.Children()[i+1]
+		}
+	}
+	return nil
+}
+
+/*
+   Link anonymous references to targets. Given::
+
+       <paragraph>
+           <reference anonymous="1">
+               internal
+           <reference anonymous="1">
+               external
+       <target anonymous="1" ids="id1">
+       <target anonymous="1" ids="id2" refuri="http://external">
+
+   Corresponding references are linked via "refid" or resolved via
+   "refuri"::
+
+       <paragraph>
+           <reference anonymous="1" refid="id1">
+               text
+           <reference anonymous="1" refuri="http://external">
+               external
+       <target anonymous="1" ids="id1">
+       <target anonymous="1" ids="id2" refuri="http://external">
+*/
+type AnonymousHyperlinks struct{}
+
+func (t *AnonymousHyperlinks) DefaultPriority() int {
+	return 440
+}
+
+func (t *AnonymousHyperlinks) Apply(document *rst.Document, startnode *rst.Element) error {
+	var anonymousRefs, anonymousTargets []*rst.Element
+	for _, node := range document.Traverse(rst.ByTag("reference")) {
+		if node.(*rst.Element).HasAttr("anonymous") {
+			anonymousRefs = append(anonymousRefs, node.(*rst.Element))
+		}
+	}
+	for _, node := range document.Traverse(rst.ByTag("target")) {
+		if node.(*rst.Element).HasAttr("anonymous") {
+			anonymousTargets = append(anonymousTargets, node.(*rst.Element))
+		}
+	}
+	if len(anonymousRefs) != len(anonymousTargets) {
+		msg := document.Reporter().Error(fmt.Sprintf("Anonymous hyperlink mismatch: %d references but %d targets.\n"+
+			"See \"backrefs\" attribute for IDs.", len(anonymousRefs), len(anonymousTargets)), "", 0)
+		msgid := document.SetID(msg, nil)
+		for _, ref := range anonymousRefs {
+			replaceWithProblematic(document, ref, msg, msgid)
+		}
+		return nil
+	}
+	for i, ref := range anonymousRefs {
+		target := anonymousTargets[i]
+		target.Referenced = true
+		for {
+			if target.HasAttr("refuri") {
+				ref.Set("refuri", target.Get("refuri"))
+				ref.Resolved = true
+				break
+			}
+			if len(target.Ids) == 0 {
+				// Propagated target.
+				target = document.GetElementByID(target.Get("refid"))
+				continue
+			}
+			ref.Set("refid", target.Ids[0])
+			document.NoteRefid(ref)
+			break
+		}
+	}
+	return nil
+}
+
+/*
+   a) Indirect external references::
+
+           <paragraph>
+               <reference refname="indirect external">
+                   indirect external
+           <target id="id1" name="direct external"
+               refuri="http://indirect">
+           <target id="id2" name="indirect external"
+               refname="direct external">
+
+       The "refuri" attribute is migrated back to all indirect targets from
+       the final direct target (i.e. a target not referring to another
+       indirect target)::
+
+           <paragraph>
+               <reference refname="indirect external">
+                   indirect external
+           <target id="id1" name="direct external"
+               refuri="http://indirect">
+           <target id="id2" name="indirect external"
+               refuri="http://indirect">
+
+       Once the attribute is migrated, the preexisting "refname" attribute
+       is dropped.
+
+   b) Indirect internal references::
+
+           <target id="id1" name="final target">
+           <paragraph>
+               <reference refname="indirect internal">
+                   indirect internal
+           <target id="id2" name="indirect internal 2"
+               refname="final target">
+           <target id="id3" name="indirect internal"
+               refname="indirect internal 2">
+
+       Targets which indirectly refer to an internal target become one-hop
+       indirect (their "refid" attributes are directly set to the internal
+       target's "id"). References which indirectly refer to an internal
+       target become direct internal references::
+
+           <target id="id1" name="final target">
+           <paragraph>
+               <reference refid="id1">
+                   indirect internal
+           <target id="id2" name="indirect internal 2" refid="id1">
+           <target id="id3" name="indirect internal" refid="id1">
+*/
+type IndirectHyperlinks struct{}
+
+func (t *IndirectHyperlinks) DefaultPriority() int {
+	return 460
+}
+
+func (t *IndirectHyperlinks) Apply(document *rst.Document, startnode *rst.Element) error {
+	r := indirectResolver{document, map[*rst.Element]bool{}}
+	for _, target := range document.IndirectTargets() {
+		if !target.Resolved {
+			r.resolveIndirectTarget(target)
+		}
+		r.resolveIndirectReferences(target)
+	}
+	return nil
+}
+
+type indirectResolver struct {
+	document *rst.Document
+	// targets being resolved, to detect circular references
+	multiplyIndirect map[*rst.Element]bool
+}
+
+func (r *indirectResolver) resolveIndirectTarget(target *rst.Element) {
+	var reftargetID string
+	refname := target.Get("refname")
+	if !target.HasAttr("refname") {
+		reftargetID = target.Get("refid")
+	} else {
+		reftargetID = r.document.NameID(refname)
+		if reftargetID == "" {
+			if !r.document.ResolveUnknownReference(target) {
+				r.nonexistentIndirectTarget(target)
+			}
+			return
+		}
+	}
+	reftarget := r.document.GetElementByID(reftargetID)
+	reftarget.NoteReferencedBy("", reftargetID)
+	if reftarget.TagName() == "target" && !reftarget.Resolved && reftarget.HasAttr("refname") {
+		if r.multiplyIndirect[target] {
+			r.indirectTargetError(target, "forming a circular reference")
+			return
+		}
+		r.multiplyIndirect[target] = true
+		r.resolveIndirectTarget(reftarget) // multiply indirect
+		delete(r.multiplyIndirect, target)
+	}
+	if reftarget.HasAttr("refuri") {
+		target.Set("refuri", reftarget.Get("refuri"))
+		target.DelAttr("refid")
+	} else if reftarget.HasAttr("refid") {
+		target.Set("refid", reftarget.Get("refid"))
+		r.document.NoteRefid(target)
+	} else if len(reftarget.Ids) > 0 {
+		target.Set("refid", reftargetID)
+		r.document.NoteRefid(target)
+	} else {
+		r.nonexistentIndirectTarget(target)
+		return
+	}
+	target.DelAttr("refname")
+	target.Resolved = true
+}
+
+func (r *indirectResolver) nonexistentIndirectTarget(target *rst.Element) {
+	if r.document.HasName(target.Get("refname")) {
+		r.indirectTargetError(target, "which is a duplicate, and cannot be used as a unique reference")
+	} else {
+		r.indirectTargetError(target, "which does not exist")
+	}
+}
+
+func (r *indirectResolver) indirectTargetError(target *rst.Element, explanation string) {
+	naming := ""
+	var reflist []*rst.Element
+	if len(target.Names) > 0 {
+		naming = "\"" + target.Names[0] + "\" "
+	}
+	for _, name := range target.Names {
+		reflist = append(reflist, r.document.Refnames(name)...)
+	}
+	for _, id := range target.Ids {
+		reflist = append(reflist, r.document.Refids(id)...)
+	}
+	if len(target.Ids) > 0 {
+		naming += "(id=\"" + target.Ids[0] + "\")"
+	}
+	msg := r.document.Reporter().Error(fmt.Sprintf("Indirect hyperlink target %s refers to target \"%s\", %s.",
+		naming, target.Get("refname"), explanation), target.Source(), target.Line())
+	msgid := r.document.SetID(msg, nil)
+	seen := map[*rst.Element]bool{}
+	for _, ref := range reflist {
+		if seen[ref] || ref.Parent() == nil {
+			continue
+		}
+		seen[ref] = true
+		replaceWithProblematic(r.document, ref, msg, msgid)
+	}
+	target.Resolved = true
+}
+
+func (r *indirectResolver) resolveIndirectReferences(target *rst.Element) {
+	var attname string
+	var noteRefid bool
+	if target.HasAttr("refid") {
+		attname = "refid"
+		noteRefid = true
+	} else if target.HasAttr("refuri") {
+		attname = "refuri"
+	} else {
+		return
+	}
+	attval := target.Get(attname)
+	resolve := func(reflist []*rst.Element, delattr string) {
+		for _, ref := range reflist {
+			if ref.Resolved {
+				continue
+			}
+			ref.DelAttr(delattr)
+			ref.Set(attname, attval)
+			if noteRefid {
+				r.document.NoteRefid(ref)
+			}
+			ref.Resolved = true
+			if ref.TagName() == "target" {
+				r.resolveIndirectReferences(ref)
+			}
+		}
+	}
+	for _, name := range target.Names {
+		reflist := r.document.Refnames(name)
+		if len(reflist) > 0 {
+			target.NoteReferencedBy(name, "")
+		}
+		resolve(reflist, "refname")
+	}
+	for _, id := range target.Ids {
+		reflist := r.document.Refids(id)
+		if len(reflist) > 0 {
+			target.NoteReferencedBy("", id)
+		}
+		resolve(reflist, "refid")
+	}
+}
+
+/*
+   Given::
+
+       <paragraph>
+           <reference refname="direct external">
+               direct external
+       <target id="id1" name="direct external" refuri="http://direct">
+
+   The "refname" attribute is replaced by the direct "refuri" attribute::
+
+       <paragraph>
+           <reference refuri="http://direct">
+               direct external
+       <target id="id1" name="direct external" refuri="http://direct">
+*/
+type ExternalTargets struct{}
+
+func (t *ExternalTargets) DefaultPriority() int {
+	return 640
+}
+
+func (t *ExternalTargets) Apply(document *rst.Document, startnode *rst.Element) error {
+	for _, node := range document.Traverse(rst.ByTag("target")) {
+		target := node.(*rst.Element)
+		if !target.HasAttr("refuri") {
+			continue
+		}
+		refuri := target.Get("refuri")
+		for _, name := range target.Names {
+			reflist := document.Refnames(name)
+			if len(reflist) > 0 {
+				target.NoteReferencedBy(name, "")
+			}
+			for _, ref := range reflist {
+				if ref.Resolved {
+					continue
+				}
+				ref.DelAttr("refname")
+				ref.Set("refuri", refuri)
+				ref.Resolved = true
+			}
+		}
+	}
+	return nil
+}
+
+/*
+   Given::
+
+       <paragraph>
+           <reference refname="direct internal">
+               direct internal
+       <target id="id1" name="direct internal">
+
+   The "refname" attribute is replaced by "refid" linking to the target's
+   "id"::
+
+       <paragraph>
+           <reference refid="id1">
+               direct internal
+       <target id="id1" name="direct internal">
+*/
+type InternalTargets struct{}
+
+func (t *InternalTargets) DefaultPriority() int {
+	return 660
+}
+
+func (t *InternalTargets) Apply(document *rst.Document, startnode *rst.Element) error {
+	for _, node := range document.Traverse(rst.ByTag("target")) {
+		target := node.(*rst.Element)
+		if target.HasAttr("refuri") || target.HasAttr("refid") {
+			continue
+		}
+		for _, name := range target.Names {
+			refid := document.NameID(name)
+			reflist := document.Refnames(name)
+			if len(reflist) > 0 {
+				target.NoteReferencedBy(name, "")
+			}
+			for _, ref := range reflist {
+				if ref.Resolved {
+					continue
+				}
+				if refid != "" {
+					ref.DelAttr("refname")
+					ref.Set("refid", refid)
+				}
+				ref.Resolved = true
+			}
+		}
+	}
+	return nil
+}
+
+/*
+   Assign numbers to autonumbered footnotes, and resolve links to
+   footnotes, citations, and their references.
+
+   Given the following ``document`` as input::
+
+       <document>
+           <paragraph>
+               A labeled autonumbered footnote reference:
+               <footnote_reference auto="1" id="id1" refname="footnote">
+           <paragraph>
+               An unlabeled autonumbered footnote reference:
+               <footnote_reference auto="1" id="id2">
+           <footnote auto="1" id="id3">
+               <paragraph>
+                   Unlabeled autonumbered footnote.
+           <footnote auto="1" id="footnote" name="footnote">
+               <paragraph>
+                   Labeled autonumbered footnote.
+
+   Auto-numbered footnotes have attribute ``auto="1"`` and no label.
+   Auto-numbered footnote_references have no reference text (they're empty
+   elements). When resolving the numbering, a ``label`` element is added to
+   the beginning of the ``footnote``, and reference text to the
+   ``footnote_reference``.
+
+   The transformed result will be::
+
+       <document>
+           <paragraph>
+               A labeled autonumbered footnote reference:
+               <footnote_reference auto="1" id="id1" refid="footnote">
+                   2
+           <paragraph>
+               An unlabeled autonumbered footnote reference:
+               <footnote_reference auto="1" id="id2" refid="id3">
+                   1
+           <footnote auto="1" id="id3" backrefs="id2">
+               <label>
+                   1
+               <paragraph>
+                   Unlabeled autonumbered footnote.
+           <footnote auto="1" id="footnote" name="footnote" backrefs="id1">
+               <label>
+                   2
+               <paragraph>
+                   Labeled autonumbered footnote.
+
+   Note that the footnotes are not in the same order as the references.
+
+   Symbol footnotes (``auto="*"``) get the symbols of `FootnoteSymbols` as
+   labels, in the order of the footnotes.
+*/
+type Footnotes struct{}
+
+// Symbols of symbol footnotes, used in order; repeated when exhausted.
+var FootnoteSymbols = []string{
+	// Entries 1-4 and 6 below are from section 12.51 of
+	// The Chicago Manual of Style, 14th edition.
+	"*",      // asterisk/star
+	"\u2020", // † dagger
+	"\u2021", // ‡ double dagger
+	"\u00a7", // § section mark
+	"\u00b6", // ¶ paragraph mark (pilcrow)
+	"#",      // number sign
+	// Other entries from HTML 4.0:
+	"\u2660", // ♠ spade suit
+	"\u2665", // ♡ heart suit
+	"\u2666", // ♢ diamond suit
+	"\u2663", // ♣ club suit
+}
+
+func (t *Footnotes) DefaultPriority() int {
+	return 620
+}
+
+func (t *Footnotes) Apply(document *rst.Document, startnode *rst.Element) error {
+	labels, startnum := t.numberFootnotes(document, document.AutofootnoteStart)
+	document.AutofootnoteStart = startnum
+	t.numberFootnoteReferences(document, labels)
+	t.symbolizeFootnotes(document)
+	t.resolveFootnotesAndCitations(document)
+	return nil
+}
+
+/*
+   Assign numbers to autonumbered footnotes, starting at `startnum`.
+
+   For labeled autonumbered footnotes, copy the number over to
+   corresponding footnote references. Return the labels of the unlabeled
+   footnotes and the next number.
+*/
+func (t *Footnotes) numberFootnotes(document *rst.Document, startnum int) ([]string, int) {
+	var labels []string
+	for _, footnote := range document.Autofootnotes() {
+		var label string
+		for {
+			label = strconv.Itoa(startnum)
+			startnum++
+			if !document.HasName(label) {
+				break
+			}
+		}
+		labelNode := &rst.Element{}
+		labelNode.Init("label", "", label)
+		footnote.Insert(0, labelNode)
+		for _, name := range footnote.Names {
+			for _, ref := range document.FootnoteRefs(name) {
+				text := &rst.Text{}
+				text.Init(label, "")
+				ref.Append(text)
+				ref.DelAttr("refname")
+				ref.Set("refid", footnote.Ids[0])
+				footnote.AddBackref(ref.Ids[0])
+				document.NoteRefid(ref)
+				ref.Resolved = true
+			}
+		}
+		if len(footnote.Names) == 0 && len(footnote.Dupnames) == 0 {
+			footnote.Names = append(footnote.Names, label)
+			document.NoteExplicitTarget(footnote, footnote)
+			labels = append(labels, label)
+		}
+	}
+	return labels, startnum
+}
+
+// Assign numbers to autonumbered footnote references.
+func (t *Footnotes) numberFootnoteReferences(document *rst.Document, labels []string) {
+	i := 0
+	refs := document.AutofootnoteRefs()
+	for j, ref := range refs {
+		if ref.Resolved || ref.HasAttr("refid") {
+			continue
+		}
+		if i >= len(labels) {
+			source, line := rst.GetSourceLine(ref)
+			msg := document.Reporter().Error(fmt.Sprintf("Too many autonumbered footnote references: only %d "+
+				"corresponding footnotes available.", len(labels)), source, line)
+			msgid := document.SetID(msg, nil)
+			for _, ref := range refs[j:] {
+				if ref.Resolved || ref.HasAttr("refname") {
+					continue
+				}
+				replaceWithProblematic(document, ref, msg, msgid)
+			}
+			break
+		}
+		label := labels[i]
+		text := &rst.Text{}
+		text.Init(label, "")
+		ref.Append(text)
+		id := document.NameID(label)
+		footnote := document.GetElementByID(id)
+		ref.Set("refid", id)
+		document.NoteRefid(ref)
+		footnote.AddBackref(ref.Ids[0])
+		ref.Resolved = true
+		i++
+	}
+}
+
+// Add symbols indexes to "[*]"-style footnotes and references.
+func (t *Footnotes) symbolizeFootnotes(document *rst.Document) {
+	var labels []string
+	footnotes := document.SymbolFootnotes()
+	for _, footnote := range footnotes {
+		reps, index := document.SymbolFootnoteStart/len(FootnoteSymbols), document.SymbolFootnoteStart%len(FootnoteSymbols)
+		labeltext := strings.Repeat(FootnoteSymbols[index], reps+1)
+		labels = append(labels, labeltext)
+		label := &rst.Element{}
+		label.Init("label", "", labeltext)
+		footnote.Insert(0, label)
+		document.SymbolFootnoteStart++
+		document.SetID(footnote, nil)
+	}
+	refs := document.SymbolFootnoteRefs()
+	for i, ref := range refs {
+		if i >= len(labels) {
+			source, line := rst.GetSourceLine(ref)
+			msg := document.Reporter().Error(fmt.Sprintf("Too many symbol footnote references: only %d "+
+				"corresponding footnotes available.", len(labels)), source, line)
+			msgid := document.SetID(msg, nil)
+			for _, ref := range refs[i:] {
+				if ref.Resolved || ref.HasAttr("refid") {
+					continue
+				}
+				replaceWithProblematic(document, ref, msg, msgid)
+			}
+			break
+		}
+		text := &rst.Text{}
+		text.Init(labels[i], "")
+		ref.Append(text)
+		footnote := footnotes[i]
+		ref.Set("refid", footnote.Ids[0])
+		document.NoteRefid(ref)
+		footnote.AddBackref(ref.Ids[0])
+	}
+}
+
+// Link manually-labeled footnotes and citations to/from their references.
+func (t *Footnotes) resolveFootnotesAndCitations(document *rst.Document) {
+	for _, footnote := range document.Footnotes() {
+		for _, label := range footnote.Names {
+			t.resolveReferences(footnote, document.FootnoteRefs(label))
+		}
+	}
+	for _, citation := range document.Citations() {
+		for _, label := range citation.Names {
+			t.resolveReferences(citation, document.CitationRefs(label))
+		}
+	}
+}
+
+func (t *Footnotes) resolveReferences(note *rst.Element, reflist []*rst.Element) {
+	if len(reflist) == 0 {
+		return
+	}
+	id := note.Ids[0]
+	for _, ref := range reflist {
+		if ref.Resolved {
+			continue
+		}
+		ref.DelAttr("refname")
+		ref.Set("refid", id)
+		note.AddBackref(ref.Ids[0])
+		ref.Resolved = true
+	}
+	note.Resolved = true
+}
+
+/*
+   Check for dangling references (incl. footnote & citation) and for
+   unreferenced targets.
+*/
+type DanglingReferences struct{}
+
+func (t *DanglingReferences) DefaultPriority() int {
+	return 850
+}
+
+func (t *DanglingReferences) Apply(document *rst.Document, startnode *rst.Element) error {
+	reporter := document.Reporter()
+	for _, node := range document.Traverse(rst.ByTag("reference", "footnote_reference", "citation_reference")) {
+		ref := node.(*rst.Element)
+		if ref.Resolved || !ref.HasAttr("refname") {
+			continue
+		}
+		refname := ref.Get("refname")
+		id := document.NameID(refname)
+		if id == "" {
+			if document.ResolveUnknownReference(ref) {
+				continue
+			}
+			var msg *rst.Element
+			source, line := rst.GetSourceLine(ref)
+			if document.HasName(refname) {
+				msg = reporter.Error("Duplicate target name, cannot be used as a unique reference: \""+refname+"\".",
+					source, line)
+			} else {
+				msg = reporter.Error("Unknown target name: \""+refname+"\".", source, line)
+			}
+			msgid := document.SetID(msg, nil)
+			replaceWithProblematic(document, ref, msg, msgid)
+		} else {
+			ref.DelAttr("refname")
+			ref.Set("refid", id)
+			document.GetElementByID(id).NoteReferencedBy("", id)
+			ref.Resolved = true
+		}
+	}
+	// *After* resolving all references, check for unreferenced targets:
+	for _, node := range document.Traverse(rst.ByTag("target")) {
+		target := node.(*rst.Element)
+		if target.Referenced || target.HasAttr("anonymous") {
+			// If we have unreferenced anonymous targets, there is already
+			// an error message about anonymous hyperlink mismatch; no need
+			// to generate another message.
+			continue
+		}
+		var naming string
+		if len(target.Names) > 0 {
+			naming = target.Names[0]
+		} else if len(target.Ids) > 0 {
+			naming = target.Ids[0]
+		} else {
+			// Hack: Propagated targets always have their refid attribute
+			// set.
+			naming = target.Get("refid")
+		}
+		reporter.Info("Hyperlink target \""+naming+"\" is not referenced.", target.Source(), target.Line())
+	}
+	return nil
+}
+
+/*
+   Replace `node` with a problematic element linked to the system message
+   `msg` (with id `msgid`).
+*/
+func replaceWithProblematic(document *rst.Document, node *rst.Element, msg *rst.Element, msgid string) {
+	// documents not parsed from reST source (e.g. Docutils XML) have no
+	// raw source
+	text := node.RawSource()
+	if text == "" {
+		text = node.AsText()
+	}
+	prb := &rst.Element{}
+	prb.Init("problematic", node.RawSource(), text)
+	prb.Set("refid", msgid)
+	// keep the id of a reference, it may be a backref target
+	prbid := ""
+	if len(node.Ids) > 0 {
+		prb.Ids = append(prb.Ids, node.Ids[0])
+		prbid = node.Ids[0]
+	} else {
+		prbid = document.SetID(prb, nil)
+	}
+	msg.AddBackref(prbid)
+	node.Parent().Replace(node, prb)
+}
+
+func contains(list []string, value string) bool {
+	for _, v := range list {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,178 @@
+package transforms
+
+import (
+	"encoding/json"
+	"testing"
+
+	rst "github.com/siongui/go-rst"
+	"github.com/siongui/go-rst/parsers/docutilsxml"
+)
+
+func TestReferences(t *testing.T) {
+	settings := &rst.Settings{}
+	settings.Init()
+	settings.WarningStream = nil
+	settings.ReportLevel = rst.InfoLevel
+	input := `<document source="test">
+    <paragraph><reference refname="direct external">direct external</reference><reference refname="indirect">indirect</reference><reference refname="internal">internal</reference><reference refname="nowhere">nowhere</reference><reference anonymous="1">anonymous</reference><footnote_reference auto="1"/><footnote_reference auto="1" refname="note"/><footnote_reference auto="*"/><citation_reference refname="cit2002">CIT2002</citation_reference></paragraph>
+    <target names="direct\ external" refuri="http://direct"/>
+    <target names="indirect" refname="direct external"/>
+    <target anonymous="1" refuri="http://anonymous"/>
+    <target names="internal"/>
+    <paragraph>Internal target.</paragraph>
+    <target names="unused" refuri="http://unused"/>
+    <footnote auto="1"/>
+    <footnote auto="1" names="note"/>
+    <footnote auto="*"/>
+    <citation names="cit2002"/>
+</document>`
+	document, err := docutilsxml.ParseDocument(input, "test", settings)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	transformer := &Transformer{}
+	transformer.Init(document)
+	transformer.AddTransforms(ReferenceTransforms())
+	transformer.AddTransform(&Messages{}, -1)
+	if err := transformer.ApplyTransforms(); err != nil {
+		t.Fatal(err)
+	}
+
+	expected := `<document source="test">
+    <paragraph>
+        <reference refuri="http://direct">
+            direct external
+        <reference refuri="http://direct">
+            indirect
+        <reference refid="internal">
+            internal
+        <problematic ids="id9" refid="id8">
+            nowhere
+        <reference anonymous="1" refuri="http://anonymous">
+            anonymous
+        <footnote_reference auto="1" ids="id1" refid="id6">
+            1
+        <footnote_reference auto="1" ids="id2" refid="note">
+            2
+        <footnote_reference auto="*" ids="id3" refid="id7">
+            *
+        <citation_reference ids="id4" refid="cit2002">
+            CIT2002
+    <target ids="direct-external" names="direct\ external" refuri="http://direct">
+    <target ids="indirect" names="indirect" refuri="http://direct">
+    <target anonymous="1" ids="id5" refuri="http://anonymous">
+    <target refid="internal">
+    <paragraph ids="internal" names="internal">
+        Internal target.
+    <target ids="unused" names="unused" refuri="http://unused">
+    <footnote auto="1" backrefs="id1" ids="id6" names="1">
+        <label>
+            1
+    <footnote auto="1" backrefs="id2" ids="note" names="note">
+        <label>
+            2
+    <footnote auto="*" backrefs="id3" ids="id7">
+        <label>
+            *
+    <citation backrefs="id4" ids="cit2002" names="cit2002">
+    <section classes="system-messages">
+        <title>
+            Docutils System Messages
+        <system_message backrefs="id9" ids="id8" level="3" source="test" type="ERROR">
+            <paragraph>
+                Unknown target name: "nowhere".
+        <system_message level="1" source="test" type="INFO">
+            <paragraph>
+                Hyperlink target "unused" is not referenced.
+`
+	if output := document.Pformat("    ", 0); output != expected {
+		t.Error("references failed:\n" + output)
+	}
+}
+
+func TestSubstitutions(t *testing.T) {
+	settings := &rst.Settings{}
+	settings.Init()
+	settings.WarningStream = nil
+	// .. |Home  Page| replace:: `site <target_>`_
+	input := `<document source="test">
+    <substitution_definition names="Home\ Page"><reference name="site" refname="target">site</reference></substitution_definition>
+    <paragraph><substitution_reference refname="Home Page">Home Page</substitution_reference><substitution_reference refname="home page">home page</substitution_reference><substitution_reference refname="HOME page">HOME page</substitution_reference></paragraph>
+    <target names="target" refuri="http://target"/>
+</document>`
+	document, err := docutilsxml.ParseDocument(input, "test", settings)
+	if err != nil {
+		t.Fatal(err)
+	}
+	paragraph := document.Children()[1].(*rst.Element)
+
+	transformer := &Transformer{}
+	transformer.Init(document)
+	transformer.AddTransforms(ReferenceTransforms())
+	if err := transformer.ApplyTransforms(); err != nil {
+		t.Fatal(err)
+	}
+
+	// the references of the substituted content are resolved
+	expected := `    <paragraph>
+        <reference name="site" refuri="http://target">
+            site
+        <reference name="site" refuri="http://target">
+            site
+        <reference name="site" refuri="http://target">
+            site
+`
+	if output := paragraph.Pformat("    ", 1); output != expected {
+		t.Error("substitutions failed:\n" + output)
+	}
+}
+
+func TestDanglingReferences(t *testing.T) {
+	settings := &rst.Settings{}
+	settings.Init()
+	settings.WarningStream = nil
+	input := `<document><paragraph>See <reference name="nowhere" refname="nowhere">nowhere</reference>.</paragraph></document>`
+	document, err := docutilsxml.ParseDocument(input, "test", settings)
+	if err != nil {
+		t.Fatal(err)
+	}
+	transformer := &Transformer{}
+	transformer.Init(document)
+	transformer.AddTransforms(ReferenceTransforms())
+	if err := transformer.ApplyTransforms(); err != nil {
+		t.Fatal(err)
+	}
+
+	// without raw source, the problematic element keeps the text
+	problematic := document.Traverse(rst.ByTag("problematic"))
+	if len(problematic) != 1 || problematic[0].AsText() != "nowhere" {
+		t.Error("dangling reference not replaced:\n" + document.Pformat("    ", 0))
+	}
+}
+
+func TestJSONReferences(t *testing.T) {
+	settings := &rst.Settings{}
+	settings.Init()
+	settings.WarningStream = nil
+	document := rst.NewDocument("test", settings)
+	data := `{"schema":1,"type":"document","children":[` +
+		`{"type":"section","attributes":{"ids":["intro"],"names":["intro"]},"children":[` +
+		`{"type":"title","children":[{"type":"#text","text":"Intro"}]},` +
+		`{"type":"paragraph","children":[{"type":"reference","attributes":{"name":"Intro","refname":"intro"},` +
+		`"children":[{"type":"#text","text":"Intro"}]}]}]}]}`
+	if err := json.Unmarshal([]byte(data), document); err != nil {
+		t.Fatal(err)
+	}
+	transformer := &Transformer{}
+	transformer.Init(document)
+	transformer.AddTransforms(ReferenceTransforms())
+	if err := transformer.ApplyTransforms(); err != nil {
+		t.Fatal(err)
+	}
+
+	references := document.Traverse(rst.ByTag("reference"))
+	if len(references) != 1 || references[0].(*rst.Element).Get("refid") != "intro" {
+		t.Error("reference not resolved:\n" + document.Pformat("    ", 0))
+	}
+}
@@ -0,0 +1,179 @@
+/*
+Package transforms implements the document tree transforms of Python
+docutils and the Transformer that applies them.
+
+URL of Python source code:
+http://sourceforge.net/p/docutils/code/HEAD/tree/trunk/docutils/docutils/transforms/__init__.py
+
+Transforms change the document tree in-place, add to the tree, or prune
+it. Transforms resolve references and footnote numbers, process
+interpreted text, and do other context-sensitive processing.
+
+To use a transform, add it to a `Transformer` (or note a `pending` element
+referring to it with `Document.NotePending()`) and call
+`ApplyTransforms()`. Components (readers, parsers, writers) supply their
+transforms through `rst.TransformSpec`, see `PopulateFromComponents()`;
+custom transforms are added with `AddTransform()`. Transforms are applied in increasing order of
+priority, as returned by their `DefaultPriority()` method:
+
+- 000-099: Preparation
+- 100-199: Very early (non-standard)
+- 200-299: Very early
+- 300-399: Early
+- 400-699: Main
+- 700-799: Late
+- 800-899: Very late
+- 900-999: Very late (non-standard)
+*/
+package transforms
+
+import (
+	"sort"
+
+	rst "github.com/siongui/go-rst"
+)
+
+type transformEntry struct {
+	// Priority and serial number: transforms of identical priority are
+	// applied in the order they were added.
+	priority  int
+	serialno  int
+	transform rst.Transform
+	pending   *rst.Element
+}
+
+/*
+   Stores transforms (`Transform` values) and applies them to document
+   trees.
+*/
+type Transformer struct {
+	// List of transforms to apply. Each item is a (priority, serial
+	// number, transform, pending node) entry.
+	transforms []transformEntry
+
+	// The `rst.Document` to transform.
+	document *rst.Document
+
+	// Transforms already applied, in order.
+	applied []transformEntry
+
+	// Boolean: is `self.transforms` sorted?
+	sorted bool
+
+	// Internal serial number to keep track of the add order of transforms.
+	serialno int
+
+	// Are system messages noted in the document as transform messages?
+	observing bool
+}
+
+func (t *Transformer) Init(document *rst.Document) {
+	t.document = document
+	t.sorted = false
+	t.serialno = 0
+}
+
+/*
+   Store a single transform. Use `priority` to override the default, a
+   negative value keeps the transform's default priority.
+*/
+func (t *Transformer) AddTransform(transform rst.Transform, priority int) {
+	if priority < 0 {
+		priority = transform.DefaultPriority()
+	}
+	t.transforms = append(t.transforms, transformEntry{priority, t.nextSerialno(), transform, nil})
+	t.sorted = false
+}
+
+// Store multiple transforms, with default priorities.
+func (t *Transformer) AddTransforms(transforms []rst.Transform) {
+	for _, transform := range transforms {
+		t.AddTransform(transform, -1)
+	}
+}
+
+/*
+   Store each component's default transforms and unknown reference
+   resolvers (for components implementing `rst.ReferenceResolverSpec`).
+   Nil components are skipped.
+*/
+func (t *Transformer) PopulateFromComponents(components ...rst.TransformSpec) {
+	for _, component := range components {
+		if component == nil {
+			continue
+		}
+		t.AddTransforms(component.GetTransforms())
+		if spec, ok := component.(rst.ReferenceResolverSpec); ok {
+			t.document.AddUnknownReferenceResolvers(spec.UnknownReferenceResolvers()...)
+		}
+	}
+	t.sorted = false
+}
+
+// Store a transform with an associated `pending` node.
+func (t *Transformer) AddPending(pending *rst.Element, priority int) {
+	if priority < 0 {
+		priority = pending.Transform.DefaultPriority()
+	}
+	t.transforms = append(t.transforms, transformEntry{priority, t.nextSerialno(), pending.Transform, pending})
+	t.sorted = false
+}
+
+/*
+   Return the next serial number, combined with the priority of a
+   transform.
+
+   This ensures FIFO order on transforms with identical priority.
+*/
+func (t *Transformer) nextSerialno() int {
+	t.serialno++
+	return t.serialno
+}
+
+// Take over the pending elements noted in the document since last time.
+func (t *Transformer) addDocumentPending() {
+	for _, note := range t.document.TakePending() {
+		t.AddPending(note.Node, note.Priority)
+	}
+}
+
+/*
+   Apply all of the stored transforms, in priority order.
+
+   Pending elements noted in the document (see `Document.NotePending()`)
+   before or while transforms are applied are processed too. Stop when a
+   transform fails or a system message at or above the halt level is
+   generated. System messages generated meanwhile are noted with
+   `Document.NoteTransformMessage()`, see the `Messages` transform.
+*/
+func (t *Transformer) ApplyTransforms() error {
+	if !t.observing {
+		t.document.Reporter().Attach(t.document.NoteTransformMessage)
+		t.observing = true
+	}
+	t.addDocumentPending()
+	for len(t.transforms) > 0 {
+		if !t.sorted {
+			// Unsorted initially, and whenever a transform is added.
+			sort.Slice(t.transforms, func(i, j int) bool {
+				a, b := t.transforms[i], t.transforms[j]
+				if a.priority != b.priority {
+					return a.priority > b.priority
+				}
+				return a.serialno > b.serialno
+			})
+			t.sorted = true
+		}
+		entry := t.transforms[len(t.transforms)-1]
+		t.transforms = t.transforms[:len(t.transforms)-1]
+		if err := entry.transform.Apply(t.document, entry.pending); err != nil {
+			return err
+		}
+		t.applied = append(t.applied, entry)
+		if err := t.document.Reporter().Halted(); err != nil {
+			return err
+		}
+		t.addDocumentPending()
+	}
+	return nil
+}
@@ -0,0 +1,144 @@
+package transforms
+
+import (
+	"strconv"
+	"strings"
+	"testing"
+
+	rst "github.com/siongui/go-rst"
+	"github.com/siongui/go-rst/parsers/docutilsxml"
+)
+
+// Records its name when applied, and optionally notes a pending element.
+type recordTransform struct {
+	name     string
+	priority int
+	log      *[]string
+	pending  *rst.Element
+}
+
+func (t *recordTransform) DefaultPriority() int {
+	return t.priority
+}
+
+func (t *recordTransform) Apply(document *rst.Document, startnode *rst.Element) error {
+	name := t.name
+	if startnode != nil {
+		name += "(" + startnode.Details["arg"].(string) + ")"
+	}
+	*t.log = append(*t.log, name)
+	if t.pending != nil {
+		document.NotePending(t.pending, -1)
+	}
+	return nil
+}
+
+type component struct {
+	transforms []rst.Transform
+}
+
+func (c *component) GetTransforms() []rst.Transform {
+	return c.transforms
+}
+
+func (c *component) UnknownReferenceResolvers() []rst.UnknownReferenceResolver {
+	return []rst.UnknownReferenceResolver{{Priority: 100, Resolve: func(node *rst.Element) bool {
+		if !strings.HasPrefix(node.Get("refname"), "issue ") {
+			return false
+		}
+		node.Set("refuri", "https://example.org/issues/"+strings.TrimPrefix(node.Get("refname"), "issue "))
+		node.DelAttr("refname")
+		return true
+	}}}
+}
+
+func TestTransformer(t *testing.T) {
+	document, err := docutilsxml.ParseDocument(`<document><paragraph><reference refname="issue 12">issue 12</reference></paragraph></document>`, "test", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ref := document.Traverse(rst.ByTag("reference"))[0].(*rst.Element)
+	var log []string
+	pending := rst.NewPending(&recordTransform{name: "pending", priority: 500, log: &log},
+		map[string]interface{}{"arg": "x"}, "")
+	document.Insert(0, pending)
+	document.NotePending(pending, 100)
+	later := rst.NewPending(&recordTransform{name: "later", priority: 900, log: &log},
+		map[string]interface{}{"arg": "y"}, "")
+	document.Append(later)
+
+	transformer := &Transformer{}
+	transformer.Init(document)
+	transformer.PopulateFromComponents(&component{[]rst.Transform{
+		&recordTransform{name: "reader", priority: 300, log: &log},
+		&DanglingReferences{},
+	}}, nil)
+	transformer.AddTransform(&recordTransform{name: "user", priority: 300, log: &log, pending: later}, -1)
+	transformer.AddTransform(&recordTransform{name: "first", priority: 300, log: &log}, 10)
+	if err := transformer.ApplyTransforms(); err != nil {
+		t.Fatal(err)
+	}
+	if strings.Join(log, " ") != "first pending(x) reader user later(y)" {
+		t.Error("wrong transform order: " + strings.Join(log, " "))
+	}
+	if ref.Get("refuri") != "https://example.org/issues/12" || ref.HasAttr("refname") {
+		t.Error("unknown reference not resolved")
+	}
+
+	// FIFO order of identical priorities, beyond 999 transforms
+	log = nil
+	transformer = &Transformer{}
+	transformer.Init(document)
+	for i := 0; i < 1200; i++ {
+		transformer.AddTransform(&recordTransform{name: strconv.Itoa(i), priority: 300, log: &log}, -1)
+	}
+	if err := transformer.ApplyTransforms(); err != nil {
+		t.Fatal(err)
+	}
+	for i, name := range log {
+		if name != strconv.Itoa(i) {
+			t.Fatalf("transform %s applied at position %d", name, i)
+		}
+	}
+}
+
+func TestSmartQuotes(t *testing.T) {
+	settings := &rst.Settings{}
+	settings.Init()
+	settings.WarningStream = nil
+	settings.SmartQuotes = "yes"
+	input := `<document source="test">
+    <paragraph>"Don't" use <literal>"quotes"</literal><emphasis> 'here'...</emphasis></paragraph>
+    <paragraph classes="language-de">"Ja."</paragraph>
+    <literal_block>"code"</literal_block>
+    <paragraph classes="language-xx">"Yes."</paragraph>
+</document>`
+	document, err := docutilsxml.ParseDocument(input, "test", settings)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	transformer := &Transformer{}
+	transformer.Init(document)
+	transformer.AddTransform(&SmartQuotes{}, -1)
+	if err := transformer.ApplyTransforms(); err != nil {
+		t.Fatal(err)
+	}
+	expected := `<document source="test">
+    <paragraph>
+        “Don’t” use 
+        <literal>
+            "quotes"
+        <emphasis>
+             ‘here’…
+    <paragraph classes="language-de">
+        „Ja.“
+    <literal_block>
+        "code"
+    <paragraph classes="language-xx">
+        "Yes."
+`
+	if result := document.Pformat("    ", 0); result != expected {
+		t.Errorf("unexpected document:\n%s\nexpected:\n%s", result, expected)
+	}
+}
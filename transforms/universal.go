@@ -0,0 +1,188 @@
+package transforms
+
+/*
+Implementation of universal transforms in Python docutils: system messages
+and their filtering, smart quotes
+
+URL of Python source code:
+http://sourceforge.net/p/docutils/code/HEAD/tree/trunk/docutils/docutils/transforms/universal.py
+*/
+
+import (
+	"strconv"
+	"strings"
+
+	rst "github.com/siongui/go-rst"
+)
+
+/*
+   Place any system messages generated after parsing into a dedicated
+   section of the document.
+*/
+type Messages struct{}
+
+func (t *Messages) DefaultPriority() int {
+	return 860
+}
+
+func (t *Messages) Apply(document *rst.Document, startnode *rst.Element) error {
+	threshold := document.Settings().ReportLevel
+	var looseMessages []rst.Node
+	for _, msg := range document.TakeTransformMessages() {
+		level, _ := strconv.Atoi(msg.Get("level"))
+		if level >= threshold && msg.Parent() == nil {
+			looseMessages = append(looseMessages, msg)
+		}
+	}
+	if len(looseMessages) > 0 {
+		section := &rst.Element{}
+		section.Init("section", "", "")
+		section.Classes = append(section.Classes, "system-messages")
+		// @@@ get this from the language module?
+		title := &rst.Element{}
+		title.Init("title", "", "Docutils System Messages")
+		section.Append(title)
+		section.Extend(looseMessages...)
+		document.Append(section)
+	}
+	return nil
+}
+
+/*
+   Remove system messages below verbosity threshold.
+
+   Also convert <problematic> nodes referencing removed messages to
+   <Text> nodes and remove "System Messages" section if empty.
+*/
+type FilterMessages struct{}
+
+func (t *FilterMessages) DefaultPriority() int {
+	return 870
+}
+
+func (t *FilterMessages) Apply(document *rst.Document, startnode *rst.Element) error {
+	threshold := document.Settings().ReportLevel
+	for _, node := range document.Traverse(rst.ByTag("system_message")) {
+		msg := node.(*rst.Element)
+		if level, _ := strconv.Atoi(msg.Get("level")); level < threshold {
+			msg.Parent().Remove(msg)
+			// also remove id-entry
+			if len(msg.Ids) > 0 {
+				document.RemoveID(msg.Ids[0])
+			}
+		}
+	}
+	for _, node := range document.Traverse(rst.ByTag("problematic")) {
+		prb := node.(*rst.Element)
+		if document.GetElementByID(prb.Get("refid")) == nil {
+			prb.Parent().Replace(prb, prb.Children()...)
+		}
+	}
+	for _, node := range document.Traverse(rst.ByTag("section")) {
+		section := node.(*rst.Element)
+		if contains(section.Classes, "system-messages") && section.Len() == 1 {
+			section.Parent().Remove(section)
+		}
+	}
+	return nil
+}
+
+// Elements whose text is not educated by SmartQuotes, but serves as context.
+func isSmartQuotesLiteral(node *rst.Element) bool {
+	switch node.TagName() {
+	case "image", "literal", "math", "raw", "problematic":
+		return true
+	}
+	return node.Is(rst.FixedTextElementClass) || node.Is(rst.Special)
+}
+
+/*
+   Replace ASCII quotation marks with typographic form, and "--", "---" and
+   "..." with en dash, em dash and ellipsis. Enabled with the `SmartQuotes`
+   setting.
+
+   Each block of text (a text element not nested in another one) is
+   educated as a unit, to keep context around inline elements. The quotes
+   depend on the language of the block (see `rst.Element.LanguageCode()`);
+   a warning is reported for languages without smart quotes definition,
+   whose quotes are left as is.
+*/
+type SmartQuotes struct{}
+
+func (t *SmartQuotes) DefaultPriority() int {
+	return 855
+}
+
+func (t *SmartQuotes) Apply(document *rst.Document, startnode *rst.Element) error {
+	settings := document.Settings()
+	if settings.SmartQuotes == "" || settings.SmartQuotes == "no" {
+		return nil
+	}
+	alternative := strings.HasPrefix(settings.SmartQuotes, "alt")
+	locales := map[string]string{}
+	for tag, quotes := range rst.SmartQuotesLocales {
+		locales[tag] = quotes
+	}
+	for tag, quotes := range settings.SmartquotesLocales {
+		locales[tag] = quotes
+	}
+	unsupportedLanguages := map[string]bool{}
+
+	for _, n := range document.Traverse(func(n rst.Node) bool {
+		e, ok := n.(*rst.Element)
+		return ok && e.Is(rst.TextElementClass)
+	}) {
+		node := n.(*rst.Element)
+		// skip preformatted text blocks and special elements:
+		if node.Is(rst.FixedTextElementClass) || node.Is(rst.Special) {
+			continue
+		}
+		// nested text elements are not "block-level" elements:
+		if node.Parent() != nil && node.Parent().Is(rst.TextElementClass) {
+			continue
+		}
+
+		// text nodes of the "text block":
+		var txtnodes []*rst.Text
+		var tokens []rst.SmartToken
+		for _, child := range node.Traverse(rst.ByTag("#text")) {
+			txtnode := child.(*rst.Text)
+			if txtnode.Parent().TagName() == "option_string" {
+				continue
+			}
+			literal := false
+			for ancestor := txtnode.Parent(); ancestor != node.Parent(); ancestor = ancestor.Parent() {
+				if isSmartQuotesLiteral(ancestor) {
+					literal = true
+					break
+				}
+			}
+			txtnodes = append(txtnodes, txtnode)
+			tokens = append(tokens, rst.SmartToken{Literal: literal, Text: txtnode.AsText()})
+		}
+
+		lang := node.LanguageCode(settings.LanguageCode)
+		// use alternative form if the setting starts with "alt":
+		if alternative {
+			if strings.Contains(lang, "-x-altquot") {
+				lang = strings.Replace(lang, "-x-altquot", "", 1)
+			} else {
+				lang += "-x-altquot"
+			}
+		}
+		chars, ok := rst.GetSmartChars(lang, locales)
+		if !ok {
+			// language not supported -- keep ASCII quotes
+			if !unsupportedLanguages[lang] {
+				document.Reporter().Warning("No smart quotes defined for language \""+lang+"\".",
+					node.Source(), node.Line())
+			}
+			unsupportedLanguages[lang] = true
+		}
+
+		for i, newtext := range rst.EducateTokens(tokens, chars) {
+			txtnodes[i].SetText(newtext)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,59 @@
+package rst
+
+/*
+Transform interfaces shared by the document tree, the Docutils components
+(writers, ...) and package transforms
+
+URL of Python source code:
+http://sourceforge.net/p/docutils/code/HEAD/tree/trunk/docutils/docutils/transforms/__init__.py
+
+The interfaces are declared here rather than in package transforms so that
+`pending` elements can refer to the transform that will process them, and
+components can declare their transforms without importing the transforms.
+*/
+
+/*
+   Docutils transform component abstract base type.
+
+   `DefaultPriority()` returns the numerical priority of the transform, 0
+   through 999: transforms are applied in increasing order of priority.
+   See the transforms package documentation for the ranges in use.
+
+   `Apply()` transforms `document`. `startnode` is the `pending` element
+   for transforms scheduled with `Document.NotePending()`, nil otherwise.
+*/
+type Transform interface {
+	DefaultPriority() int
+	Apply(document *Document, startnode *Element) error
+}
+
+/*
+   Runtime transform specification of a Docutils component (reader,
+   parser, writer): `GetTransforms()` returns the transforms the component
+   needs applied to the document. See `transforms.Transformer`.
+*/
+type TransformSpec interface {
+	GetTransforms() []Transform
+}
+
+/*
+   A function trying to resolve a reference the reference transforms could
+   not resolve (e.g. a "refname" naming no target). `Resolve` returns true
+   if it resolved `node` (e.g. by setting its "refuri" and removing its
+   "refname"), false to let other resolvers try.
+
+   Resolvers are tried in increasing order of `Priority`; use 100 when
+   there is no reason to be tried earlier or later.
+*/
+type UnknownReferenceResolver struct {
+	Priority int
+	Resolve  func(node *Element) bool
+}
+
+/*
+   Implemented by components providing unknown reference resolvers, in
+   addition to `TransformSpec`.
+*/
+type ReferenceResolverSpec interface {
+	UnknownReferenceResolvers() []UnknownReferenceResolver
+}
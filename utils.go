@@ -0,0 +1,223 @@
+package rst
+
+/*
+Implementation of Reporter in Python docutils utils
+
+URL of Python source code:
+http://sourceforge.net/p/docutils/code/HEAD/tree/trunk/docutils/docutils/utils/__init__.py
+*/
+
+import (
+	"fmt"
+	"io"
+	"strconv"
+)
+
+// System message levels.
+const (
+	DebugLevel = iota
+	InfoLevel
+	WarningLevel
+	ErrorLevel
+	SevereLevel
+)
+
+// List of names for system message levels, indexed by level.
+var Levels = []string{"DEBUG", "INFO", "WARNING", "ERROR", "SEVERE"}
+
+/*
+   Info/warning/error reporter and ``system_message`` element generator.
+
+   Five levels of system messages are defined, along with corresponding
+   methods: `Debug()`, `Info()`, `Warning()`, `Error()`, and `Severe()`.
+
+   There is typically one Reporter object per process. A Reporter object is
+   instantiated with thresholds for reporting (generating warnings) and
+   halting processing (raising exceptions), a switch to turn debug output on
+   or off, and an I/O stream for warnings.
+
+   Messages at or above the halting threshold are recorded; processing
+   stages check `Halted()` and stop instead of raising an exception as
+   Python docutils does.
+
+   Multiple reporting thresholds are no longer supported.
+*/
+type Reporter struct {
+	// The path to or description of the source data.
+	source string
+
+	// The level at or above which warning output will be sent to `stream`.
+	reportLevel int
+
+	// The level at or above which processing is halted.
+	haltLevel int
+
+	// Where warning output is sent. Nil for no output.
+	stream io.Writer
+
+	// Show debug (level=0) system messages?
+	debugFlag bool
+
+	// List of bound methods or functions to call with each system_message
+	// created.
+	observers []func(*Element)
+
+	// The highest level system message generated so far.
+	maxLevel int
+
+	// The first system message at or above `haltLevel`.
+	halt error
+}
+
+/*
+   Initialize the `Reporter`'s attributes.
+
+   Parameters:
+
+   - `source`: The path to or description of the source data.
+   - `reportLevel`: The level at or above which warning output will
+     be sent to `stream`.
+   - `haltLevel`: The level at or above which processing is halted.
+   - `stream`: Where warning output is sent. Can be nil.
+   - `debug`: Show debug (level=0) system messages?
+*/
+func (r *Reporter) Init(source string, reportLevel, haltLevel int, stream io.Writer, debug bool) {
+	r.source = source
+	r.reportLevel = reportLevel
+	r.haltLevel = haltLevel
+	r.stream = stream
+	r.debugFlag = debug
+	r.maxLevel = -1
+}
+
+/*
+   The `observer` parameter is a function or bound method which takes one
+   argument, a `system_message` element.
+*/
+func (r *Reporter) Attach(observer func(*Element)) {
+	r.observers = append(r.observers, observer)
+}
+
+func (r *Reporter) notifyObservers(message *Element) {
+	for _, observer := range r.observers {
+		observer(message)
+	}
+}
+
+/*
+   Return a system_message element and notify observers.
+
+   `source` defaults to the reporter's source; `line` is omitted if 0.
+   `children` are appended after the paragraph holding `message`.
+*/
+func (r *Reporter) SystemMessage(level int, message, source string, line int, children ...Node) *Element {
+	if source == "" {
+		source = r.source
+	}
+	msg := &Element{}
+	msg.Init("system_message", message, "")
+	if message != "" {
+		p := &Element{}
+		p.Init("paragraph", "", message)
+		msg.Append(p)
+	}
+	msg.Extend(children...)
+	msg.Set("level", strconv.Itoa(level))
+	msg.Set("type", Levels[level])
+	msg.Set("source", source)
+	msg.SetSource(source)
+	if line > 0 {
+		msg.Set("line", strconv.Itoa(line))
+		msg.SetLine(line)
+	}
+	if r.stream != nil && (level >= r.reportLevel ||
+		r.debugFlag && level == DebugLevel ||
+		level >= r.haltLevel) {
+		fmt.Fprintln(r.stream, msg.AsText())
+	}
+	if level >= r.haltLevel && r.halt == nil {
+		r.halt = &SystemMessageError{msg.AsText()}
+	}
+	if level > DebugLevel || r.debugFlag {
+		r.notifyObservers(msg)
+	}
+	if level > r.maxLevel {
+		r.maxLevel = level
+	}
+	return msg
+}
+
+/*
+   Level-0, "DEBUG": an internal reporting issue. Typically, there is no
+   effect on the processing. Level-0 system messages are handled
+   separately from the others.
+*/
+func (r *Reporter) Debug(message, source string, line int, children ...Node) *Element {
+	if r.debugFlag {
+		return r.SystemMessage(DebugLevel, message, source, line, children...)
+	}
+	return nil
+}
+
+/*
+   Level-1, "INFO": a minor issue that can be ignored. Typically there is
+   no effect on processing, and level-1 system messages are not reported.
+*/
+func (r *Reporter) Info(message, source string, line int, children ...Node) *Element {
+	return r.SystemMessage(InfoLevel, message, source, line, children...)
+}
+
+/*
+   Level-2, "WARNING": an issue that should be addressed. If ignored,
+   there may be unpredictable problems with the output.
+*/
+func (r *Reporter) Warning(message, source string, line int, children ...Node) *Element {
+	return r.SystemMessage(WarningLevel, message, source, line, children...)
+}
+
+/*
+   Level-3, "ERROR": an error that should be addressed. If ignored, the
+   output will contain errors.
+*/
+func (r *Reporter) Error(message, source string, line int, children ...Node) *Element {
+	return r.SystemMessage(ErrorLevel, message, source, line, children...)
+}
+
+/*
+   Level-4, "SEVERE": a severe error that must be addressed. If ignored,
+   the output will contain severe errors. Typically level-4 system
+   messages are turned into exceptions which halt processing.
+*/
+func (r *Reporter) Severe(message, source string, line int, children ...Node) *Element {
+	return r.SystemMessage(SevereLevel, message, source, line, children...)
+}
+
+// Return the highest level of the system messages generated so far, -1 if
+// there were none.
+func (r *Reporter) MaxLevel() int {
+	return r.maxLevel
+}
+
+// Return a `SystemMessageError` if a system message at or above the halt
+// level was generated, nil otherwise.
+func (r *Reporter) Halted() error {
+	return r.halt
+}
+
+/*
+   Return the source and line number of `node`, or of its closest ancestor
+   with a line number (inline nodes have none of their own).
+*/
+func GetSourceLine(node Node) (string, int) {
+	for n := node; n != nil; {
+		if n.Line() > 0 {
+			return n.Source(), n.Line()
+		}
+		parent := n.Parent()
+		if parent == nil {
+			break
+		}
+		n = parent
+	}
+	return node.Source(), 0
+}
@@ -0,0 +1,194 @@
+/*
+Package docutilsxml implements the simple Docutils XML writer of Python
+docutils: the document tree serialized as Docutils XML, conforming to the
+Docutils Generic DTD (docutils.dtd).
+
+URL of Python source code:
+http://sourceforge.net/p/docutils/code/HEAD/tree/trunk/docutils/docutils/writers/docutils_xml.py
+*/
+package docutilsxml
+
+import (
+	"strings"
+
+	rst "github.com/siongui/go-rst"
+	"github.com/siongui/go-rst/writers"
+)
+
+const xmlDeclaration = "<?xml version=\"1.0\" encoding=\"utf-8\"?>\n"
+
+const doctype = "<!DOCTYPE document PUBLIC" +
+	" \"+//IDN docutils.sourceforge.net//DTD Docutils Generic//EN//XML\"" +
+	" \"http://docutils.sourceforge.net/docs/ref/docutils.dtd\">\n"
+
+const generator = "<!-- Generated by go-rst: https://github.com/siongui/go-rst -->\n"
+
+/*
+   The Docutils XML writer. The `XMLDeclaration`, `DoctypeDeclaration`,
+   `Newlines` and `Indents` settings control the output; the parsers/
+   docutilsxml package reads it back into a document tree.
+*/
+type Writer struct {
+	writers.Base
+}
+
+func (w *Writer) Supports(format string) bool {
+	return format == "xml"
+}
+
+func (w *Writer) Write(document *rst.Document) (string, error) {
+	w.Document = document
+	translator := &XMLTranslator{}
+	translator.Init(document)
+	if err := rst.Walkabout(document, translator); err != nil {
+		return "", err
+	}
+	w.Output = strings.Join(translator.output, "")
+	w.AssembleParts()
+	return w.Output, nil
+}
+
+/*
+   Generic Docutils to XML translator: every element is written with its
+   tag and attributes, text is escaped. With the `Newlines` and `Indents`
+   settings, elements which are not text elements get their own indented
+   lines.
+*/
+type XMLTranslator struct {
+	output []string
+
+	newline string
+	indent  string
+	level   int
+
+	// Nesting depths of elements with fixed text (no indent) and text
+	// elements (no newlines).
+	fixedText int
+	inSimple  int
+}
+
+func (t *XMLTranslator) Init(document *rst.Document) {
+	settings := document.Settings()
+	if settings.Newlines {
+		t.newline = "\n"
+	}
+	if settings.Indents {
+		t.newline = "\n"
+		t.indent = "    "
+	}
+	if settings.XMLDeclaration {
+		t.output = append(t.output, xmlDeclaration)
+	}
+	if settings.DoctypeDeclaration {
+		t.output = append(t.output, doctype)
+	}
+	t.output = append(t.output, generator)
+}
+
+/*
+   `rst.Literal` is not a fixed text element by design (see "Inline
+   literals" in the reStructuredText Markup Specification), but its text
+   is preformatted just as well.
+*/
+func isFixedText(node *rst.Element) bool {
+	return node.Is(rst.FixedTextElementClass) || node.TagName() == "literal"
+}
+
+// Start tag of `node` with attribute values quoted as by XML quoteattr().
+func starttag(node *rst.Element) string {
+	parts := []string{node.TagName()}
+	for _, att := range node.Attlist() {
+		parts = append(parts, att[0]+"="+QuoteAttr(att[1]))
+	}
+	return "<" + strings.Join(parts, " ") + ">"
+}
+
+func (t *XMLTranslator) UnknownVisit(n rst.Node) error {
+	node := n.(*rst.Element)
+	if t.inSimple == 0 {
+		t.output = append(t.output, strings.Repeat(t.indent, t.level))
+	}
+	t.output = append(t.output, starttag(node))
+	t.level++
+	if isFixedText(node) {
+		t.fixedText++
+	}
+	if node.Is(rst.TextElementClass) {
+		t.inSimple++
+	}
+	if t.inSimple == 0 {
+		t.output = append(t.output, t.newline)
+	}
+	return nil
+}
+
+func (t *XMLTranslator) UnknownDeparture(n rst.Node) error {
+	node := n.(*rst.Element)
+	t.level--
+	if t.inSimple == 0 {
+		t.output = append(t.output, strings.Repeat(t.indent, t.level))
+	}
+	t.output = append(t.output, "</"+node.TagName()+">")
+	if isFixedText(node) {
+		t.fixedText--
+	}
+	if node.Is(rst.TextElementClass) {
+		t.inSimple--
+	}
+	if t.inSimple == 0 {
+		t.output = append(t.output, t.newline)
+	}
+	return nil
+}
+
+func (t *XMLTranslator) VisitText(node *rst.Text) {
+	text := Escape(node.AsText())
+	// indent text if we are not in a fixed text element:
+	if t.fixedText == 0 {
+		text = strings.Replace(text, "\n", "\n"+strings.Repeat(t.indent, t.level), -1)
+	}
+	t.output = append(t.output, text)
+}
+
+func (t *XMLTranslator) DepartText(node *rst.Text) {}
+
+// Raw XML content is inserted as is; other raw content is escaped.
+func (t *XMLTranslator) VisitRaw(node *rst.Element) error {
+	if !writers.RawFormatMatches(node, "xml") {
+		return t.UnknownVisit(node)
+	}
+	t.UnknownVisit(node)
+	t.output = append(t.output, node.AsText())
+	t.UnknownDeparture(node)
+	return &rst.SkipNode{}
+}
+
+func (t *XMLTranslator) DepartRaw(node *rst.Element) {
+	t.UnknownDeparture(node)
+}
+
+// Escape "&", "<" and ">" in character data.
+func Escape(data string) string {
+	data = strings.Replace(data, "&", "&amp;", -1)
+	data = strings.Replace(data, ">", "&gt;", -1)
+	return strings.Replace(data, "<", "&lt;", -1)
+}
+
+/*
+   Escape and quote an attribute value: like `Escape()`, with newlines,
+   carriage returns and tabs as character references. The value is
+   quoted with double quotes, or single quotes if it contains double
+   quotes only.
+*/
+func QuoteAttr(data string) string {
+	data = Escape(data)
+	data = strings.NewReplacer("\n", "&#10;", "\r", "&#13;", "\t", "&#9;").Replace(data)
+	if strings.Contains(data, "\"") {
+		if strings.Contains(data, "'") {
+			data = strings.Replace(data, "\"", "&quot;", -1)
+		} else {
+			return "'" + data + "'"
+		}
+	}
+	return "\"" + data + "\""
+}
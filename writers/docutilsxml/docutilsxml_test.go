@@ -0,0 +1,65 @@
+package docutilsxml
+
+import (
+	"testing"
+
+	rst "github.com/siongui/go-rst"
+)
+
+func TestWriter(t *testing.T) {
+	settings := &rst.Settings{}
+	settings.Init()
+	document := rst.NewDocument("test.rst", settings)
+	section := &rst.Element{}
+	section.Init("section", "", "")
+	section.Ids = append(section.Ids, "a-title")
+	section.Names = append(section.Names, "a title")
+	title := &rst.Element{}
+	title.Init("title", "", "A <title>")
+	block := &rst.Element{}
+	block.Init("literal_block", "", "x = 1\n  y & z")
+	section.Extend(title, block)
+	document.Append(section)
+
+	writer := &Writer{}
+	output, err := writer.Write(document)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := xmlDeclaration + doctype + generator +
+		`<document source="test.rst"><section ids="a-title" names="a\ title"><title>A &lt;title&gt;</title>` +
+		"<literal_block>x = 1\n  y &amp; z</literal_block></section></document>"
+	if output != expected {
+		t.Error("unexpected output:\n" + output)
+	}
+
+	settings.XMLDeclaration = false
+	settings.DoctypeDeclaration = false
+	settings.Indents = true
+	output, _ = writer.Write(document)
+	expected = generator + `<document source="test.rst">
+    <section ids="a-title" names="a\ title">
+        <title>A &lt;title&gt;</title>
+        <literal_block>x = 1
+  y &amp; z</literal_block>
+    </section>
+</document>
+`
+	if output != expected {
+		t.Error("unexpected indented output:\n" + output)
+	}
+}
+
+func TestQuoteAttr(t *testing.T) {
+	cases := [][2]string{
+		{`a<b`, `"a&lt;b"`},
+		{`say "hi"`, `'say "hi"'`},
+		{`it's "x"`, `"it's &quot;x&quot;"`},
+		{"a\nb", `"a&#10;b"`},
+	}
+	for _, c := range cases {
+		if quoted := QuoteAttr(c[0]); quoted != c[1] {
+			t.Error("expected " + c[1] + ", got " + quoted)
+		}
+	}
+}
@@ -0,0 +1,448 @@
+/*
+Package epub implements an EPUB 3 writer (no Python docutils counterpart).
+
+The document is split into chapters at its top-level sections; the
+elements before the first section (title, docinfo, ...) make a title page.
+The chapters are XHTML documents translated by the writers/html package,
+with links between chapters pointing to the chapter files. The package
+document (OPF) lists them in reading order, and the navigation document
+holds the table of contents built from the sections. Local images below
+the directory of the source are copied into the package if file insertion
+is enabled; other images (but data URIs) are replaced by their alternate
+text. The modification date of the package is taken from the
+SOURCE_DATE_EPOCH environment variable if set, like the "date" directive.
+
+The output of `Write()` is the EPUB (ZIP) file.
+*/
+package epub
+
+import (
+	"archive/zip"
+	"bytes"
+	"crypto/sha1"
+	"fmt"
+	stdhtml "html"
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	rst "github.com/siongui/go-rst"
+	"github.com/siongui/go-rst/writers"
+	"github.com/siongui/go-rst/writers/html"
+)
+
+// Formats this writer supports.
+var supported = []string{"epub", "epub3"}
+
+// The directory of the package content in the EPUB file.
+const contentDir = "OEBPS"
+
+const containerXML = `<?xml version="1.0" encoding="utf-8"?>
+<container version="1.0" xmlns="urn:oasis:names:tc:opendocument:xmlns:container">
+<rootfiles>
+<rootfile full-path="` + contentDir + `/content.opf" media-type="application/oebps-package+xml"/>
+</rootfiles>
+</container>
+`
+
+// The style sheet of the chapters.
+const stylesheet = `body { margin: 0 5%; }
+h1.title, p.subtitle { text-align: center; }
+pre.literal-block, pre.doctest-block, pre.code { margin-left: 2em; white-space: pre-wrap; }
+div.admonition, div.attention, div.caution, div.danger, div.error, div.hint,
+div.important, div.note, div.tip, div.warning { margin: 1em 2em; }
+p.admonition-title { font-weight: bold; }
+table { border-collapse: collapse; }
+td, th { border: 1px solid; padding: 0 0.3em; }
+img.align-center { display: block; margin: 0 auto; }
+`
+
+// Media types of images, by file extension.
+var imageTypes = map[string]string{
+	".gif":  "image/gif",
+	".jpeg": "image/jpeg",
+	".jpg":  "image/jpeg",
+	".png":  "image/png",
+	".svg":  "image/svg+xml",
+	".webp": "image/webp",
+}
+
+/*
+   The EPUB writer. Besides "whole" (the EPUB file), `Parts()` has "opf"
+   and "nav": the package and navigation documents.
+*/
+type Writer struct {
+	writers.Base
+}
+
+func (w *Writer) Supports(format string) bool {
+	for _, f := range supported {
+		if f == format {
+			return true
+		}
+	}
+	return false
+}
+
+// A chapter: an XHTML document of the package.
+type chapter struct {
+	file  string
+	title string
+	nodes []rst.Node
+}
+
+// An image copied into the package.
+type image struct {
+	file      string
+	mediaType string
+	data      []byte
+}
+
+func (w *Writer) Write(document *rst.Document) (string, error) {
+	w.Document = document
+	chapters := split(document)
+	// the chapter of each id, for links between chapters
+	files := map[string]string{}
+	for _, c := range chapters {
+		for _, node := range c.nodes {
+			if element, ok := node.(*rst.Element); ok {
+				for _, n := range element.Traverse(nil) {
+					if e, ok := n.(*rst.Element); ok {
+						for _, id := range e.Ids {
+							files[id] = c.file
+						}
+					}
+				}
+			}
+		}
+	}
+	images, uris := w.collectImages(chapters)
+
+	var buf bytes.Buffer
+	archive := zip.NewWriter(&buf)
+	// the mimetype comes first, uncompressed
+	mimetype, err := archive.CreateHeader(&zip.FileHeader{Name: "mimetype", Method: zip.Store})
+	if err != nil {
+		return "", err
+	}
+	mimetype.Write([]byte("application/epub+zip"))
+	add := func(name string, data []byte) error {
+		f, err := archive.Create(name)
+		if err != nil {
+			return err
+		}
+		_, err = f.Write(data)
+		return err
+	}
+	if err := add("META-INF/container.xml", []byte(containerXML)); err != nil {
+		return "", err
+	}
+	for _, c := range chapters {
+		xhtml, err := w.translate(c, files, uris)
+		if err != nil {
+			return "", err
+		}
+		if err := add(path.Join(contentDir, c.file), []byte(xhtml)); err != nil {
+			return "", err
+		}
+	}
+	for _, img := range images {
+		if err := add(path.Join(contentDir, img.file), img.data); err != nil {
+			return "", err
+		}
+	}
+	opf := w.packageDocument(chapters, images)
+	nav := w.navigationDocument(chapters)
+	for _, file := range [][2]string{{"content.opf", opf}, {"nav.xhtml", nav}, {"style.css", stylesheet}} {
+		if err := add(path.Join(contentDir, file[0]), []byte(file[1])); err != nil {
+			return "", err
+		}
+	}
+	if err := archive.Close(); err != nil {
+		return "", err
+	}
+	w.Output = buf.String()
+	w.AssembleParts()
+	w.SetPart("opf", opf)
+	w.SetPart("nav", nav)
+	return w.Output, nil
+}
+
+/*
+   Split the document into chapters: the elements before the first
+   section, and each top-level section. The header and footer are dropped.
+*/
+func split(document *rst.Document) []*chapter {
+	front := &chapter{file: "title.xhtml", title: title(&document.Element)}
+	if front.title == "" {
+		front.title = filepath.Base(document.Source())
+	}
+	chapters := []*chapter{front}
+	for _, child := range document.Children() {
+		switch child.TagName() {
+		case "decoration":
+			continue
+		case "section":
+			chapters = append(chapters, &chapter{
+				file:  fmt.Sprintf("chapter-%03d.xhtml", len(chapters)),
+				title: title(child.(*rst.Element)),
+			})
+		}
+		last := chapters[len(chapters)-1]
+		last.nodes = append(last.nodes, child)
+	}
+	if len(front.nodes) == 0 {
+		return chapters[1:]
+	}
+	return chapters
+}
+
+// Return the text of the title of a document or section.
+func title(node *rst.Element) string {
+	for _, child := range node.Children() {
+		if child.TagName() == "title" {
+			return child.AsText()
+		}
+	}
+	return ""
+}
+
+/*
+   Read the local images of the chapters. Return them, and the mapping of
+   image URI to file in the package. Only relative URIs inside the
+   directory of the source are read, and only if file insertion is
+   enabled.
+*/
+func (w *Writer) collectImages(chapters []*chapter) ([]*image, map[string]string) {
+	var images []*image
+	uris := map[string]string{}
+	dir := filepath.Dir(w.Document.Source())
+	for _, c := range chapters {
+		for _, node := range c.nodes {
+			element, ok := node.(*rst.Element)
+			if !ok {
+				continue
+			}
+			for _, n := range element.Traverse(rst.ByTag("image")) {
+				img := n.(*rst.Element)
+				uri := img.Get("uri")
+				if _, ok := uris[uri]; ok || strings.HasPrefix(uri, "data:") {
+					continue
+				}
+				if strings.Contains(uri, "://") {
+					w.Document.Reporter().Warning(fmt.Sprintf("EPUB: remote image %q not included.", uri), img.Source(), img.Line())
+					continue
+				}
+				if !w.Document.Settings().FileInsertionEnabled {
+					w.Document.Reporter().Warning(fmt.Sprintf("EPUB: image %q not included; file insertion disabled.", uri), img.Source(), img.Line())
+					continue
+				}
+				if p := path.Clean(filepath.ToSlash(uri)); path.IsAbs(p) || filepath.IsAbs(uri) || p == ".." || strings.HasPrefix(p, "../") {
+					w.Document.Reporter().Warning(fmt.Sprintf("EPUB: image %q outside the source directory not included.", uri), img.Source(), img.Line())
+					continue
+				}
+				mediaType, ok := imageTypes[strings.ToLower(path.Ext(uri))]
+				if !ok {
+					w.Document.Reporter().Warning(fmt.Sprintf("EPUB: unknown image type of %q.", uri), img.Source(), img.Line())
+					continue
+				}
+				data, err := ioutil.ReadFile(filepath.Join(dir, filepath.FromSlash(uri)))
+				if err != nil {
+					w.Document.Reporter().Warning(fmt.Sprintf("EPUB: cannot read image %q: %s", uri, err), img.Source(), img.Line())
+					continue
+				}
+				name := fmt.Sprintf("images/%03d%s", len(images)+1, strings.ToLower(path.Ext(uri)))
+				images = append(images, &image{file: name, mediaType: mediaType, data: data})
+				uris[uri] = name
+			}
+		}
+	}
+	return images, uris
+}
+
+var entity = regexp.MustCompile(`&([a-zA-Z][a-zA-Z0-9]*);`)
+
+// Return the XHTML document of chapter `c`.
+func (w *Writer) translate(c *chapter, files, uris map[string]string) (string, error) {
+	linkTarget := func(id string) string {
+		if file, ok := files[id]; ok && file != c.file {
+			return file + "#" + id
+		}
+		return "#" + id
+	}
+	imageSource := func(uri string) string {
+		if file, ok := uris[uri]; ok {
+			return file
+		}
+		if strings.HasPrefix(uri, "data:") {
+			return uri
+		}
+		// not included: replaced by the alternate text
+		return ""
+	}
+	var body []string
+	for _, node := range c.nodes {
+		text, err := html.TranslateNodeLinks(w.Document, node, linkTarget, imageSource)
+		if err != nil {
+			return "", err
+		}
+		body = append(body, text)
+	}
+	return xhtml(w.language(), c.title, strings.Join(body, "")), nil
+}
+
+/*
+   Return an XHTML document. HTML named character references (other than
+   the XML ones) are replaced by numeric ones.
+*/
+func xhtml(language, title, body string) string {
+	body = entity.ReplaceAllStringFunc(body, func(m string) string {
+		switch m {
+		case "&amp;", "&lt;", "&gt;", "&quot;", "&apos;":
+			return m
+		}
+		if r := []rune(stdhtml.UnescapeString(m)); len(r) == 1 && string(r) != m {
+			return fmt.Sprintf("&#%d;", r[0])
+		}
+		return "&amp;" + m[1:]
+	})
+	return `<?xml version="1.0" encoding="utf-8"?>
+<!DOCTYPE html>
+<html xmlns="http://www.w3.org/1999/xhtml" xmlns:epub="http://www.idpf.org/2007/ops" xml:lang="` +
+		language + `" lang="` + language + `">
+<head>
+<meta charset="utf-8" />
+<title>` + html.Encode(title) + `</title>
+<link rel="stylesheet" type="text/css" href="style.css" />
+</head>
+<body>
+` + body + `</body>
+</html>
+`
+}
+
+func (w *Writer) language() string {
+	if language := w.Document.Settings().LanguageCode; language != "" {
+		return html.Attval(language)
+	}
+	return "en"
+}
+
+/*
+   Return the identifier of the publication: a UUID derived from the
+   document source and title, so that it is stable across builds.
+*/
+func (w *Writer) identifier() string {
+	sum := sha1.Sum([]byte(w.Document.Source() + "\x00" + title(&w.Document.Element)))
+	sum[6] = sum[6]&0x0f | 0x50
+	sum[8] = sum[8]&0x3f | 0x80
+	return fmt.Sprintf("urn:uuid:%x-%x-%x-%x-%x", sum[0:4], sum[4:6], sum[6:8], sum[8:10], sum[10:16])
+}
+
+/*
+   Return the modification time of the publication: the current time, or
+   the timestamp of the SOURCE_DATE_EPOCH environment variable if it is
+   set, for reproducible builds.
+*/
+func (w *Writer) modified() time.Time {
+	epoch := os.Getenv("SOURCE_DATE_EPOCH")
+	if epoch == "" {
+		return time.Now()
+	}
+	seconds, err := strconv.ParseInt(epoch, 10, 64)
+	if err != nil {
+		w.Document.Reporter().Warning("EPUB: invalid SOURCE_DATE_EPOCH value: "+epoch, "", 0)
+		return time.Now()
+	}
+	return time.Unix(seconds, 0)
+}
+
+// Return the package document.
+func (w *Writer) packageDocument(chapters []*chapter, images []*image) string {
+	documentTitle := title(&w.Document.Element)
+	if documentTitle == "" && len(chapters) > 0 {
+		documentTitle = chapters[0].title
+	}
+	var b strings.Builder
+	b.WriteString(`<?xml version="1.0" encoding="utf-8"?>
+<package xmlns="http://www.idpf.org/2007/opf" version="3.0" unique-identifier="uid">
+<metadata xmlns:dc="http://purl.org/dc/elements/1.1/">
+`)
+	fmt.Fprintf(&b, "<dc:identifier id=\"uid\">%s</dc:identifier>\n", w.identifier())
+	fmt.Fprintf(&b, "<dc:title>%s</dc:title>\n", html.Encode(documentTitle))
+	fmt.Fprintf(&b, "<dc:language>%s</dc:language>\n", w.language())
+	for _, n := range w.Document.Traverse(rst.ByTag("docinfo")) {
+		for _, field := range n.(*rst.Element).Children() {
+			switch field.TagName() {
+			case "author":
+				fmt.Fprintf(&b, "<dc:creator>%s</dc:creator>\n", html.Encode(field.AsText()))
+			case "authors":
+				for _, author := range field.(*rst.Element).Children() {
+					fmt.Fprintf(&b, "<dc:creator>%s</dc:creator>\n", html.Encode(author.AsText()))
+				}
+			case "copyright":
+				fmt.Fprintf(&b, "<dc:rights>%s</dc:rights>\n", html.Encode(field.AsText()))
+			case "date":
+				fmt.Fprintf(&b, "<dc:date>%s</dc:date>\n", html.Encode(field.AsText()))
+			}
+		}
+	}
+	fmt.Fprintf(&b, "<meta property=\"dcterms:modified\">%s</meta>\n", w.modified().UTC().Format("2006-01-02T15:04:05Z"))
+	b.WriteString("</metadata>\n<manifest>\n")
+	b.WriteString("<item id=\"nav\" href=\"nav.xhtml\" media-type=\"application/xhtml+xml\" properties=\"nav\"/>\n")
+	b.WriteString("<item id=\"style\" href=\"style.css\" media-type=\"text/css\"/>\n")
+	for i, c := range chapters {
+		fmt.Fprintf(&b, "<item id=\"chapter-%d\" href=\"%s\" media-type=\"application/xhtml+xml\"/>\n", i, c.file)
+	}
+	for i, img := range images {
+		fmt.Fprintf(&b, "<item id=\"image-%d\" href=\"%s\" media-type=\"%s\"/>\n", i+1, img.file, img.mediaType)
+	}
+	b.WriteString("</manifest>\n<spine>\n")
+	for i := range chapters {
+		fmt.Fprintf(&b, "<itemref idref=\"chapter-%d\"/>\n", i)
+	}
+	b.WriteString("</spine>\n</package>\n")
+	return b.String()
+}
+
+// Return the navigation document: the table of contents of the sections.
+func (w *Writer) navigationDocument(chapters []*chapter) string {
+	var b strings.Builder
+	b.WriteString("<nav epub:type=\"toc\" id=\"toc\">\n")
+	fmt.Fprintf(&b, "<h1>%s</h1>\n", html.Encode(rst.GetLanguage(w.Document.Settings().LanguageCode).Labels["contents"]))
+	b.WriteString("<ol>\n")
+	for _, c := range chapters {
+		fmt.Fprintf(&b, "<li><a href=\"%s\">%s</a>", c.file, html.Encode(c.title))
+		if section, ok := c.nodes[0].(*rst.Element); ok && section.TagName() == "section" {
+			writeSections(&b, section, c.file)
+		}
+		b.WriteString("</li>\n")
+	}
+	b.WriteString("</ol>\n</nav>\n")
+	return xhtml(w.language(), title(&w.Document.Element), b.String())
+}
+
+// Write the list of the subsections of `section`, if any.
+func writeSections(b *strings.Builder, section *rst.Element, file string) {
+	var subsections []*rst.Element
+	for _, child := range section.Children() {
+		if child.TagName() == "section" && len(child.(*rst.Element).Ids) > 0 {
+			subsections = append(subsections, child.(*rst.Element))
+		}
+	}
+	if len(subsections) == 0 {
+		return
+	}
+	b.WriteString("\n<ol>\n")
+	for _, subsection := range subsections {
+		fmt.Fprintf(b, "<li><a href=\"%s#%s\">%s</a>", file, html.Attval(subsection.Ids[0]), html.Encode(title(subsection)))
+		writeSections(b, subsection, file)
+		b.WriteString("</li>\n")
+	}
+	b.WriteString("</ol>\n")
+}
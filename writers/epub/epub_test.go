@@ -0,0 +1,281 @@
+package epub
+
+import (
+	"archive/zip"
+	"encoding/xml"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	rst "github.com/siongui/go-rst"
+	"github.com/siongui/go-rst/parsers/docutilsxml"
+)
+
+const input = `<document>
+    <title>The Book</title>
+    <docinfo><author>Jane Doe</author></docinfo>
+    <section ids="introduction">
+        <title>Introduction</title>
+        <paragraph><reference refid="details">Details</reference></paragraph>
+        <section ids="details">
+            <title>Details</title>
+            <paragraph>Non&#160;breaking.</paragraph>
+            <image alt="Logo" uri="images/logo.png"/>
+        </section>
+    </section>
+    <section ids="usage">
+        <title>Usage &amp; more</title>
+        <paragraph>Use it.</paragraph>
+    </section>
+</document>
+`
+
+func TestWriter(t *testing.T) {
+	dir, err := ioutil.TempDir("", "epub")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	if err := os.Mkdir(filepath.Join(dir, "images"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(filepath.Join(dir, "images", "logo.png"), []byte("PNG data"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	settings := &rst.Settings{}
+	settings.Init()
+	settings.WarningStream = nil
+	document, err := docutilsxml.ParseDocument(input, filepath.Join(dir, "book.rst"), settings)
+	if err != nil {
+		t.Fatal(err)
+	}
+	writer := &Writer{}
+	output, err := writer.Write(document)
+	if err != nil {
+		t.Fatal(err)
+	}
+	archive, err := zip.NewReader(strings.NewReader(output), int64(len(output)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	files := map[string]string{}
+	var names []string
+	for _, f := range archive.File {
+		r, err := f.Open()
+		if err != nil {
+			t.Fatal(err)
+		}
+		data, err := ioutil.ReadAll(r)
+		r.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+		names = append(names, f.Name)
+		files[f.Name] = string(data)
+	}
+	if archive.File[0].Name != "mimetype" || archive.File[0].Method != zip.Store ||
+		files["mimetype"] != "application/epub+zip" {
+		t.Error("the mimetype must be the first file, uncompressed")
+	}
+	expected := []string{"mimetype", "META-INF/container.xml", "OEBPS/title.xhtml",
+		"OEBPS/chapter-001.xhtml", "OEBPS/chapter-002.xhtml", "OEBPS/images/001.png",
+		"OEBPS/content.opf", "OEBPS/nav.xhtml", "OEBPS/style.css"}
+	if strings.Join(names, " ") != strings.Join(expected, " ") {
+		t.Fatalf("unexpected files: %q", names)
+	}
+
+	// the XML files are well-formed
+	for _, name := range names {
+		if strings.HasSuffix(name, ".xml") || strings.HasSuffix(name, ".xhtml") || strings.HasSuffix(name, ".opf") {
+			decoder := xml.NewDecoder(strings.NewReader(files[name]))
+			for {
+				if _, err := decoder.Token(); err == io.EOF {
+					break
+				} else if err != nil {
+					t.Errorf("%s: %s", name, err)
+					break
+				}
+			}
+		}
+	}
+
+	var opf struct {
+		Title    string   `xml:"metadata>title"`
+		Creators []string `xml:"metadata>creator"`
+		Items    []struct {
+			Href       string `xml:"href,attr"`
+			MediaType  string `xml:"media-type,attr"`
+			Properties string `xml:"properties,attr"`
+		} `xml:"manifest>item"`
+		Spine []struct {
+			Idref string `xml:"idref,attr"`
+		} `xml:"spine>itemref"`
+	}
+	if err := xml.Unmarshal([]byte(files["OEBPS/content.opf"]), &opf); err != nil {
+		t.Fatal(err)
+	}
+	if opf.Title != "The Book" || len(opf.Creators) != 1 || opf.Creators[0] != "Jane Doe" {
+		t.Errorf("unexpected metadata: %q %q", opf.Title, opf.Creators)
+	}
+	manifest := map[string]string{}
+	for _, item := range opf.Items {
+		manifest[item.Href] = item.MediaType + " " + item.Properties
+		if _, ok := files["OEBPS/"+item.Href]; !ok {
+			t.Errorf("manifest item %q not in the package", item.Href)
+		}
+	}
+	if manifest["nav.xhtml"] != "application/xhtml+xml nav" || manifest["images/001.png"] != "image/png " {
+		t.Errorf("unexpected manifest: %q", manifest)
+	}
+	if len(opf.Spine) != 3 || opf.Spine[0].Idref != "chapter-0" {
+		t.Errorf("unexpected spine: %v", opf.Spine)
+	}
+	if writer.Parts()["opf"] != files["OEBPS/content.opf"] {
+		t.Error("wrong opf part")
+	}
+
+	nav := files["OEBPS/nav.xhtml"]
+	for _, entry := range []string{
+		`<li><a href="title.xhtml">The Book</a></li>`,
+		`<li><a href="chapter-001.xhtml">Introduction</a>` + "\n<ol>\n" +
+			`<li><a href="chapter-001.xhtml#details">Details</a></li>`,
+		`<li><a href="chapter-002.xhtml">Usage &amp; more</a></li>`,
+	} {
+		if !strings.Contains(nav, entry) {
+			t.Errorf("%q not in the navigation document:\n%s", entry, nav)
+		}
+	}
+
+	chapter := files["OEBPS/chapter-001.xhtml"]
+	for _, s := range []string{`href="#details"`, `src="images/001.png"`, "Non&#160;breaking."} {
+		if !strings.Contains(chapter, s) {
+			t.Errorf("%q not in the chapter:\n%s", s, chapter)
+		}
+	}
+	if !strings.Contains(files["OEBPS/title.xhtml"], "Jane Doe") {
+		t.Error("no docinfo in the title page")
+	}
+}
+
+func TestLinksBetweenChapters(t *testing.T) {
+	// a reference from the second chapter to the first one
+	linked := strings.Replace(input, "<paragraph>Use it.</paragraph>",
+		`<paragraph>Use it.</paragraph><paragraph><reference refid="introduction">Introduction</reference></paragraph>`, 1)
+	settings := &rst.Settings{}
+	settings.Init()
+	settings.WarningStream = nil
+	document, err := docutilsxml.ParseDocument(linked, "book.rst", settings)
+	if err != nil {
+		t.Fatal(err)
+	}
+	output, err := (&Writer{}).Write(document)
+	if err != nil {
+		t.Fatal(err)
+	}
+	archive, err := zip.NewReader(strings.NewReader(output), int64(len(output)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, f := range archive.File {
+		if f.Name == "OEBPS/chapter-002.xhtml" {
+			r, _ := f.Open()
+			data, _ := ioutil.ReadAll(r)
+			if !strings.Contains(string(data), `href="chapter-001.xhtml#introduction"`) {
+				t.Errorf("link between chapters not rewritten:\n%s", data)
+			}
+		}
+		if strings.HasPrefix(f.Name, "OEBPS/images/") {
+			t.Error("missing image copied")
+		}
+	}
+}
+
+func TestImagesNotIncluded(t *testing.T) {
+	dir, err := ioutil.TempDir("", "epub")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	if err := os.Mkdir(filepath.Join(dir, "book"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	secret := filepath.Join(dir, "secret.png")
+	if err := ioutil.WriteFile(secret, []byte("PNG data"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(filepath.Join(dir, "book", "logo.png"), []byte("PNG data"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, test := range []struct {
+		uri           string
+		fileInsertion bool
+	}{
+		{"logo.png", false},
+		{"../secret.png", true},
+		{"images/../../secret.png", true},
+		{filepath.ToSlash(secret), true},
+		{"http://example.org/logo.png", true},
+	} {
+		settings := &rst.Settings{}
+		settings.Init()
+		settings.WarningStream = nil
+		settings.FileInsertionEnabled = test.fileInsertion
+		document, err := docutilsxml.ParseDocument(strings.Replace(input, "images/logo.png", test.uri, 1),
+			filepath.Join(dir, "book", "book.rst"), settings)
+		if err != nil {
+			t.Fatal(err)
+		}
+		output, err := (&Writer{}).Write(document)
+		if err != nil {
+			t.Fatal(err)
+		}
+		archive, err := zip.NewReader(strings.NewReader(output), int64(len(output)))
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, f := range archive.File {
+			if strings.HasPrefix(f.Name, "OEBPS/images/") {
+				t.Errorf("%s: image copied (file insertion %v)", test.uri, test.fileInsertion)
+			}
+			// the alternate text replaces the image
+			if f.Name == "OEBPS/chapter-001.xhtml" {
+				r, _ := f.Open()
+				data, _ := ioutil.ReadAll(r)
+				if strings.Contains(string(data), "<img") || !strings.Contains(string(data), `<p class="image-alt">Logo</p>`) {
+					t.Errorf("%s: image not replaced:\n%s", test.uri, data)
+				}
+			}
+		}
+	}
+}
+
+func TestSourceDateEpoch(t *testing.T) {
+	t.Setenv("SOURCE_DATE_EPOCH", "1700000000")
+	var outputs []string
+	for i := 0; i < 2; i++ {
+		settings := &rst.Settings{}
+		settings.Init()
+		settings.WarningStream = nil
+		document, err := docutilsxml.ParseDocument(input, "book.rst", settings)
+		if err != nil {
+			t.Fatal(err)
+		}
+		writer := &Writer{}
+		output, err := writer.Write(document)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !strings.Contains(writer.Parts()["opf"], `<meta property="dcterms:modified">2023-11-14T22:13:20Z</meta>`) {
+			t.Errorf("modification time not from SOURCE_DATE_EPOCH:\n%s", writer.Parts()["opf"])
+		}
+		outputs = append(outputs, output)
+	}
+	if outputs[0] != outputs[1] {
+		t.Error("output not reproducible")
+	}
+}
@@ -0,0 +1,1969 @@
+/*
+Package html implements the HTML5 writer of Python docutils
+(html5_polyglot), producing polyglot HTML5/XHTML output.
+
+URL of Python source code:
+http://sourceforge.net/p/docutils/code/HEAD/tree/trunk/docutils/docutils/writers/_html_base.py
+http://sourceforge.net/p/docutils/code/HEAD/tree/trunk/docutils/docutils/writers/html5_polyglot/__init__.py
+*/
+package html
+
+import (
+	"bytes"
+	"fmt"
+	"html/template"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	rst "github.com/siongui/go-rst"
+	"github.com/siongui/go-rst/writers"
+)
+
+// Formats this writer supports.
+var supported = []string{"html", "html5", "xhtml", "xhtml10", "html4css1"}
+
+const doctype = "<!DOCTYPE html>\n"
+
+const headPrefixTemplate = "<html xmlns=\"http://www.w3.org/1999/xhtml\" xml:lang=\"%[1]s\" lang=\"%[1]s\">\n<head>\n"
+
+const contentType = "<meta charset=\"%s\" />\n"
+
+const generator = "<meta name=\"generator\" content=\"go-rst: https://github.com/siongui/go-rst\" />\n"
+
+// The default template of the "whole" output.
+const defaultTemplate = `%(head_prefix)s
+%(head)s
+%(stylesheet)s
+%(body_prefix)s
+%(body_pre_docinfo)s
+%(docinfo)s
+%(body)s
+%(body_suffix)s
+`
+
+/*
+   The HTML writer. The translated document is available from `Parts()`
+   in pieces:
+
+   - "whole": the complete HTML page.
+   - "head_prefix", "head", "stylesheet", "body_prefix",
+     "body_pre_docinfo", "docinfo", "body", "body_suffix": the parts the
+     template is made of.
+   - "title", "subtitle": the document title and subtitle.
+   - "header", "footer", "meta": the document decorations and meta tags.
+   - "fragment": the document body without title or docinfo.
+   - "toc": the tables of contents (also in "body").
+   - "html_prolog", "html_head", "html_title", "html_subtitle",
+     "html_body": the corresponding parts with HTML markup.
+
+   The whole page is made with `Template` if set, else with the template
+   file of the `Template` setting if set, else with the built-in template.
+   `TemplateData()` returns the fields of the template.
+*/
+type Writer struct {
+	writers.Base
+
+	// Template of the whole page, executed with the `TemplateData`.
+	Template *template.Template
+
+	translator *HTMLTranslator
+}
+
+/*
+   The fields of the HTML template. The HTML fragments are not escaped by
+   the template.
+
+   - `Title`, `Subtitle`: the document title and subtitle (HTML text, no
+     element).
+   - `Body`: the document body after the docinfo, without the table of
+     contents.
+   - `TOC`: the tables of contents (<nav> elements), empty if the document
+     has none.
+   - `Docinfo`: the bibliographic fields (a <dl> element).
+   - `Header`, `Footer`: the document decorations.
+   - `Stylesheets`: the URLs of the `Stylesheet` setting.
+   - `Meta`: the <meta> elements.
+   - `Head`: the content of the <head> element but the style sheets: the
+     <meta> and <title> elements, and the math scripts.
+   - `Language`: the document language (BCP 47 tag).
+*/
+type TemplateData struct {
+	Title       template.HTML
+	Subtitle    template.HTML
+	Body        template.HTML
+	TOC         template.HTML
+	Docinfo     template.HTML
+	Header      template.HTML
+	Footer      template.HTML
+	Stylesheets []string
+	Meta        template.HTML
+	Head        template.HTML
+	Language    string
+}
+
+func (w *Writer) Supports(format string) bool {
+	for _, f := range supported {
+		if f == format {
+			return true
+		}
+	}
+	return false
+}
+
+func (w *Writer) Write(document *rst.Document) (string, error) {
+	w.Document = document
+	w.translator = &HTMLTranslator{}
+	w.translator.Init(document)
+	if err := rst.Walkabout(document, w.translator); err != nil {
+		return "", err
+	}
+	tmpl := w.Template
+	if tmpl == nil && document.Settings().Template != "" {
+		var err error
+		if tmpl, err = template.ParseFiles(document.Settings().Template); err != nil {
+			return "", err
+		}
+	}
+	if tmpl != nil {
+		var buf bytes.Buffer
+		if err := tmpl.Execute(&buf, w.TemplateData()); err != nil {
+			return "", err
+		}
+		w.Output = buf.String()
+	} else {
+		w.Output = w.applyTemplate()
+	}
+	w.AssembleParts()
+	return w.Output, nil
+}
+
+// Return the fields of the HTML template for the last `Write()`.
+func (w *Writer) TemplateData() TemplateData {
+	t := w.translator
+	var body []string
+	for i, item := range t.body {
+		if !t.tocIndices[i] {
+			body = append(body, item)
+		}
+	}
+	language := t.settings.LanguageCode
+	if language == "" {
+		language = "en"
+	}
+	return TemplateData{
+		Title:       template.HTML(strings.Join(t.title, "")),
+		Subtitle:    template.HTML(strings.Join(t.subtitle, "")),
+		Body:        template.HTML(strings.Join(body, "")),
+		TOC:         template.HTML(strings.Join(t.toc, "")),
+		Docinfo:     template.HTML(strings.Join(t.docinfo, "")),
+		Header:      template.HTML(strings.Join(t.header, "")),
+		Footer:      template.HTML(strings.Join(t.footer, "")),
+		Stylesheets: t.stylesheetURLs(),
+		Meta:        template.HTML(strings.Join(t.meta, "")),
+		Head:        template.HTML(strings.Join(t.head, "")),
+		Language:    language,
+	}
+}
+
+var templateVariable = regexp.MustCompile(`%\((\w+)\)s`)
+
+func (w *Writer) applyTemplate() string {
+	subs := w.templateVars()
+	return templateVariable.ReplaceAllStringFunc(defaultTemplate, func(m string) string {
+		return subs[m[2:len(m)-2]]
+	})
+}
+
+func (w *Writer) templateVars() map[string]string {
+	t := w.translator
+	subs := map[string]string{}
+	for name, part := range t.parts() {
+		subs[name] = strings.Join(part, "")
+	}
+	subs["encoding"] = "utf-8"
+	// the content-type meta tag comes with the head
+	subs["head"] = strings.Join(t.head, "")
+	subs["title"] = strings.Join(t.title, "")
+	subs["subtitle"] = strings.Join(t.subtitle, "")
+	return subs
+}
+
+func (w *Writer) AssembleParts() {
+	w.Base.AssembleParts()
+	for name, value := range w.templateVars() {
+		w.SetPart(name, value)
+	}
+}
+
+/*
+   Generic Docutils to HTML translator.
+
+   See the docutils html writers for the generated HTML; most elements map
+   to HTML elements, with a class of the node type where needed. Visit and
+   depart methods are called by `rst.Walkabout()`.
+*/
+type HTMLTranslator struct {
+	document *rst.Document
+	settings *rst.Settings
+	language *rst.Language
+
+	headPrefix     []string
+	head           []string
+	stylesheet     []string
+	meta           []string
+	bodyPrefix     []string
+	bodyPreDocinfo []string
+	docinfo        []string
+	body           []string
+	fragment       []string
+	bodySuffix     []string
+	title          []string
+	subtitle       []string
+	header         []string
+	footer         []string
+	htmlProlog     []string
+	htmlHead       []string
+	htmlTitle      []string
+	htmlSubtitle   []string
+	htmlBody       []string
+	toc            []string
+
+	// Stack of closing tags or saved states, pushed in visit methods and
+	// popped in the corresponding depart methods.
+	context []interface{}
+
+	sectionLevel       int
+	initialHeaderLevel int
+	// Start of the table of contents being visited in the body, and the
+	// body items holding the tables of contents.
+	tocStart   int
+	tocIndices map[int]bool
+
+	compactP         bool
+	compactSimple    bool
+	compactFieldList bool
+	inDocumentTitle  int
+	inFootnoteList   bool
+
+	// Table state: the colspecs of the current tgroup, its stub columns,
+	// and the column of the current entry.
+	colspecs []*rst.Element
+	stubs    []bool
+	column   int
+
+	// Math output format (lowercase, set up at the first math element),
+	// its options, and the lines it needs in the HTML head.
+	mathOutput        string
+	mathOutputOptions []string
+	mathHeader        []string
+
+	// The system messages of inline math conversion errors, written at
+	// the end of the body (not inside the paragraph of the math).
+	mathMessages []*rst.Element
+
+	// Rewrite the links to element ids and the image URIs, if not nil;
+	// see `TranslateNodeLinks()`.
+	linkTarget  func(id string) string
+	imageSource func(uri string) string
+}
+
+func (t *HTMLTranslator) Init(document *rst.Document) {
+	t.document = document
+	t.settings = document.Settings()
+	t.language = rst.GetLanguage(t.settings.LanguageCode)
+	t.meta = []string{generator}
+	t.head = append([]string(nil), t.meta...)
+	t.bodyPrefix = []string{"</head>\n<body>\n"}
+	t.bodySuffix = []string{"</body>\n</html>\n"}
+	t.initialHeaderLevel = t.settings.InitialHeaderLevel
+	if t.initialHeaderLevel == 0 {
+		t.initialHeaderLevel = 2
+	}
+	t.compactP = true
+	for _, url := range t.stylesheetURLs() {
+		t.stylesheet = append(t.stylesheet, fmt.Sprintf("<link rel=\"stylesheet\" href=\"%s\" type=\"text/css\" />\n", Attval(url)))
+	}
+}
+
+// Return the URLs of the `Stylesheet` setting.
+func (t *HTMLTranslator) stylesheetURLs() []string {
+	var urls []string
+	for _, url := range strings.Split(t.settings.Stylesheet, ",") {
+		if url = strings.TrimSpace(url); url != "" {
+			urls = append(urls, url)
+		}
+	}
+	return urls
+}
+
+func (t *HTMLTranslator) parts() map[string][]string {
+	return map[string][]string{
+		"head_prefix":      t.headPrefix,
+		"stylesheet":       t.stylesheet,
+		"meta":             t.meta,
+		"body_prefix":      t.bodyPrefix,
+		"body_pre_docinfo": t.bodyPreDocinfo,
+		"docinfo":          t.docinfo,
+		"body":             t.body,
+		"fragment":         t.fragment,
+		"body_suffix":      t.bodySuffix,
+		"header":           t.header,
+		"footer":           t.footer,
+		"html_prolog":      t.htmlProlog,
+		"html_head":        t.htmlHead,
+		"html_title":       t.htmlTitle,
+		"html_subtitle":    t.htmlSubtitle,
+		"html_body":        t.htmlBody,
+		"toc":              t.toc,
+	}
+}
+
+/*
+   Translate `node`, an element of `document`, and return its HTML as the
+   body of the whole document would hold it. Writers of other formats use
+   it for elements without equivalent in their format.
+*/
+func TranslateNode(document *rst.Document, node rst.Node) (string, error) {
+	return TranslateNodeLinks(document, node, nil, nil)
+}
+
+/*
+   Translate `node` like `TranslateNode()`, with the links to the element
+   with id `id` pointing to `linkTarget(id)` instead of "#id", and images
+   with URI `uri` read from `imageSource(uri)`, or replaced by their
+   alternate text if it is empty; nil functions keep the defaults. Writers
+   splitting a document into several HTML files use it.
+*/
+func TranslateNodeLinks(document *rst.Document, node rst.Node, linkTarget, imageSource func(string) string) (string, error) {
+	t := &HTMLTranslator{}
+	t.Init(document)
+	t.linkTarget, t.imageSource = linkTarget, imageSource
+	if err := rst.Walkabout(node, t); err != nil {
+		return "", err
+	}
+	// the document title, subtitle and docinfo have their own parts
+	return strings.Join(t.bodyPreDocinfo, "") + strings.Join(t.docinfo, "") + strings.Join(t.body, ""), nil
+}
+
+func (t *HTMLTranslator) UnknownVisit(node rst.Node) error {
+	return &NotImplementedError{fmt.Sprintf("visiting unknown node type: %s", node.TagName())}
+}
+
+func (t *HTMLTranslator) UnknownDeparture(node rst.Node) error {
+	return &NotImplementedError{fmt.Sprintf("departing unknown node type: %s", node.TagName())}
+}
+
+// Return the href of a link to the element with id `id`.
+func (t *HTMLTranslator) href(id string) string {
+	if t.linkTarget != nil {
+		return t.linkTarget(id)
+	}
+	return "#" + id
+}
+
+func (t *HTMLTranslator) push(value interface{}) {
+	t.context = append(t.context, value)
+}
+
+func (t *HTMLTranslator) pop() interface{} {
+	value := t.context[len(t.context)-1]
+	t.context = t.context[:len(t.context)-1]
+	return value
+}
+
+// Append the string popped from the context stack to the body.
+func (t *HTMLTranslator) appendPopped() {
+	t.body = append(t.body, t.pop().(string))
+}
+
+var specialCharacters = strings.NewReplacer(
+	"&", "&amp;",
+	"<", "&lt;",
+	`"`, "&quot;",
+	">", "&gt;",
+	"@", "&#64;", // may thwart address harvesters
+	"\u00a0", "&nbsp;",
+)
+
+// Encode special characters in `text` & return.
+func Encode(text string) string {
+	return specialCharacters.Replace(text)
+}
+
+var attvalWhitespace = regexp.MustCompile(`[\n\r\t\v\f]`)
+
+// Cleanse, HTML encode, and return attribute value text.
+func Attval(text string) string {
+	return Encode(attvalWhitespace.ReplaceAllString(text, " "))
+}
+
+/*
+   Construct and return a start tag given a node (id & class attributes
+   are extracted), tag name, and optional attributes. `node` may be nil.
+
+   The "class" attribute in `attributes` is added to the node classes;
+   classes "language-*" set the "lang" attribute. Additional ids are
+   placed in empty <span> elements.
+*/
+func (t *HTMLTranslator) Starttag(node *rst.Element, tagname, suffix string, empty bool, attributes map[string]string) string {
+	tagname = strings.ToLower(tagname)
+	var prefix []string
+	atts := map[string]string{}
+	for name, value := range attributes {
+		atts[strings.ToLower(name)] = value
+	}
+	var classes, languages, ids []string
+	var nodeClasses []string
+	if node != nil {
+		nodeClasses = node.Classes
+	}
+	for _, cls := range append(append([]string(nil), nodeClasses...), strings.Fields(atts["class"])...) {
+		if strings.HasPrefix(cls, "language-") {
+			languages = append(languages, cls[9:])
+		} else if strings.TrimSpace(cls) != "" && !contains(classes, cls) {
+			classes = append(classes, cls)
+		}
+	}
+	delete(atts, "class")
+	if len(languages) > 0 {
+		atts["lang"] = languages[0]
+	}
+	if len(classes) > 0 {
+		atts["class"] = strings.Join(classes, " ")
+	}
+	if node != nil {
+		ids = append(ids, node.Ids...)
+	}
+	if len(ids) > 0 {
+		atts["id"] = ids[0]
+		for _, id := range ids[1:] {
+			// Add empty "span" elements for additional IDs. Note that we
+			// cannot use empty "a" elements because there may be targets
+			// inside of references, but nested "a" elements aren't allowed
+			// in XHTML (even if they do not all have a "href" attribute).
+			if empty || node.Is(rst.Sequential) || node.TagName() == "docinfo" || node.TagName() == "table" {
+				// Insert target right in front of element.
+				prefix = append(prefix, fmt.Sprintf("<span id=\"%s\"></span>", id))
+			} else {
+				// Non-empty tag. Place the auxiliary <span> tag *inside*
+				// the element, as the first child.
+				suffix += fmt.Sprintf("<span id=\"%s\"></span>", id)
+			}
+		}
+	}
+	parts := []string{tagname}
+	for _, name := range sortedKeys(atts) {
+		parts = append(parts, fmt.Sprintf("%s=\"%s\"", name, Attval(atts[name])))
+	}
+	infix := ""
+	if empty {
+		infix = " /"
+	}
+	return strings.Join(prefix, "") + "<" + strings.Join(parts, " ") + infix + ">" + suffix
+}
+
+// Construct and return an XML-compatible empty tag.
+func (t *HTMLTranslator) Emptytag(node *rst.Element, tagname, suffix string, attributes map[string]string) string {
+	return t.Starttag(node, tagname, suffix, true, attributes)
+}
+
+func (t *HTMLTranslator) starttag(node *rst.Element, tagname, suffix string, attributes ...string) string {
+	return t.Starttag(node, tagname, suffix, false, pairs(attributes))
+}
+
+func (t *HTMLTranslator) emptytag(node *rst.Element, tagname, suffix string, attributes ...string) string {
+	return t.Starttag(node, tagname, suffix, true, pairs(attributes))
+}
+
+// Return a map of the (name, value) pairs in `list`.
+func pairs(list []string) map[string]string {
+	atts := map[string]string{}
+	for i := 0; i+1 < len(list); i += 2 {
+		atts[list[i]] = list[i+1]
+	}
+	return atts
+}
+
+func (t *HTMLTranslator) addMeta(tag string) {
+	t.meta = append(t.meta, tag)
+	t.head = append(t.head, tag)
+}
+
+// Is `node` a list that can be rendered without paragraph tags?
+func (t *HTMLTranslator) isCompactable(node *rst.Element) bool {
+	// explicit class arguments have precedence
+	if contains(node.Classes, "compact") {
+		return true
+	}
+	if contains(node.Classes, "open") {
+		return false
+	}
+	// check config setting:
+	switch node.TagName() {
+	case "field_list", "definition_list":
+		if !t.settings.CompactFieldLists {
+			return false
+		}
+	case "enumerated_list", "bullet_list":
+		if !t.settings.CompactLists {
+			return false
+		}
+	}
+	// Table of Contents:
+	if node.Parent() != nil && contains(node.Parent().Classes, "contents") {
+		return true
+	}
+	// check the list items:
+	return checkSimpleList(node)
+}
+
+/*
+   Check for a simple list that can be rendered compactly: every item (or
+   field body or definition) holds at most one paragraph, list items
+   optionally followed by a nested simple bullet or enumerated list.
+*/
+func checkSimpleList(node *rst.Element) bool {
+	for _, child := range node.Children() {
+		item, ok := child.(*rst.Element)
+		if !ok {
+			return false
+		}
+		switch item.TagName() {
+		case "list_item":
+		case "field", "definition_list_item", "docinfo_item":
+			// only the body matters, not the field name or term
+			if item.Len() == 0 {
+				continue
+			}
+			last, ok := item.Children()[item.Len()-1].(*rst.Element)
+			if !ok {
+				return false
+			}
+			item = last
+		default:
+			if node.TagName() == "docinfo" {
+				// bibliographic fields are simple
+				continue
+			}
+			return false
+		}
+		var children []*rst.Element
+		for _, c := range item.Children() {
+			e, ok := c.(*rst.Element)
+			if !ok {
+				return false
+			}
+			if !e.Is(rst.Invisible) {
+				children = append(children, e)
+			}
+		}
+		if len(children) > 1 && children[0].TagName() == "paragraph" {
+			last := children[len(children)-1]
+			if last.TagName() == "bullet_list" || last.TagName() == "enumerated_list" {
+				if !checkSimpleList(last) {
+					return false
+				}
+				children = children[:len(children)-1]
+			}
+		}
+		if len(children) > 1 || len(children) == 1 && children[0].TagName() != "paragraph" {
+			return false
+		}
+	}
+	return true
+}
+
+// Determine if the <p> tags around paragraph `node` can be omitted.
+func (t *HTMLTranslator) shouldBeCompactParagraph(node *rst.Element) bool {
+	parent := node.Parent()
+	if parent.TagName() == "document" || parent.TagName() == "compound" {
+		// Never compact paragraphs in document or compound.
+		return false
+	}
+	for _, att := range node.Attlist() {
+		if att[0] != "classes" {
+			// Attribute which needs to survive.
+			return false
+		}
+	}
+	for _, cls := range node.Classes {
+		if cls != "first" && cls != "last" {
+			return false
+		}
+	}
+	first := 0
+	if parent.Len() > 0 && parent.Children()[0].TagName() == "label" {
+		// skip label
+		first = 1
+	}
+	for _, child := range parent.Children()[first:] {
+		// only first paragraph can be compact
+		if e, ok := child.(*rst.Element); ok && e.Is(rst.Invisible) {
+			continue
+		}
+		if child == rst.Node(node) {
+			break
+		}
+		return false
+	}
+	parentLength := 0
+	for _, child := range parent.Children() {
+		if e, ok := child.(*rst.Element); ok && (e.Is(rst.Invisible) || e.TagName() == "label") {
+			continue
+		}
+		parentLength++
+	}
+	return t.compactSimple || t.compactFieldList || t.compactP && parentLength == 1
+}
+
+func (t *HTMLTranslator) VisitText(node *rst.Text) {
+	t.body = append(t.body, Encode(node.AsText()))
+}
+
+func (t *HTMLTranslator) DepartText(node *rst.Text) {}
+
+func (t *HTMLTranslator) VisitAbbreviation(node *rst.Element) {
+	// @@@ implementation incomplete ("title" attribute)
+	t.body = append(t.body, t.starttag(node, "abbr", ""))
+}
+
+func (t *HTMLTranslator) DepartAbbreviation(node *rst.Element) {
+	t.body = append(t.body, "</abbr>")
+}
+
+func (t *HTMLTranslator) VisitAcronym(node *rst.Element) {
+	// @@@ implementation incomplete ("title" attribute)
+	t.body = append(t.body, t.starttag(node, "abbr", ""))
+}
+
+func (t *HTMLTranslator) DepartAcronym(node *rst.Element) {
+	t.body = append(t.body, "</abbr>")
+}
+
+func (t *HTMLTranslator) VisitAddress(node *rst.Element) {
+	t.visitDocinfoItem(node, "address", false)
+	t.body = append(t.body, t.starttag(node, "pre", "", "class", "address"))
+}
+
+func (t *HTMLTranslator) DepartAddress(node *rst.Element) {
+	t.body = append(t.body, "\n</pre>\n")
+	t.departDocinfoItem()
+}
+
+func (t *HTMLTranslator) VisitAdmonition(node *rst.Element) {
+	t.body = append(t.body, t.starttag(node, "div", "\n", "class", "admonition"))
+}
+
+func (t *HTMLTranslator) DepartAdmonition(node *rst.Element) {
+	t.body = append(t.body, "</div>\n")
+}
+
+// The specific admonitions get a generated title from the language module.
+func (t *HTMLTranslator) visitSpecificAdmonition(node *rst.Element) {
+	name := node.TagName()
+	t.body = append(t.body, t.starttag(node, "div", "\n", "class", "admonition "+name))
+	t.body = append(t.body, "<p class=\"admonition-title\">"+Encode(t.language.Labels[name])+"</p>\n")
+}
+
+func (t *HTMLTranslator) VisitAttention(node *rst.Element)  { t.visitSpecificAdmonition(node) }
+func (t *HTMLTranslator) DepartAttention(node *rst.Element) { t.DepartAdmonition(node) }
+func (t *HTMLTranslator) VisitCaution(node *rst.Element)    { t.visitSpecificAdmonition(node) }
+func (t *HTMLTranslator) DepartCaution(node *rst.Element)   { t.DepartAdmonition(node) }
+func (t *HTMLTranslator) VisitDanger(node *rst.Element)     { t.visitSpecificAdmonition(node) }
+func (t *HTMLTranslator) DepartDanger(node *rst.Element)    { t.DepartAdmonition(node) }
+func (t *HTMLTranslator) VisitError(node *rst.Element)      { t.visitSpecificAdmonition(node) }
+func (t *HTMLTranslator) DepartError(node *rst.Element)     { t.DepartAdmonition(node) }
+func (t *HTMLTranslator) VisitHint(node *rst.Element)       { t.visitSpecificAdmonition(node) }
+func (t *HTMLTranslator) DepartHint(node *rst.Element)      { t.DepartAdmonition(node) }
+func (t *HTMLTranslator) VisitImportant(node *rst.Element)  { t.visitSpecificAdmonition(node) }
+func (t *HTMLTranslator) DepartImportant(node *rst.Element) { t.DepartAdmonition(node) }
+func (t *HTMLTranslator) VisitNote(node *rst.Element)       { t.visitSpecificAdmonition(node) }
+func (t *HTMLTranslator) DepartNote(node *rst.Element)      { t.DepartAdmonition(node) }
+func (t *HTMLTranslator) VisitTip(node *rst.Element)        { t.visitSpecificAdmonition(node) }
+func (t *HTMLTranslator) DepartTip(node *rst.Element)       { t.DepartAdmonition(node) }
+func (t *HTMLTranslator) VisitWarning(node *rst.Element)    { t.visitSpecificAdmonition(node) }
+func (t *HTMLTranslator) DepartWarning(node *rst.Element)   { t.DepartAdmonition(node) }
+
+func (t *HTMLTranslator) VisitAttribution(node *rst.Element) {
+	t.body = append(t.body, t.starttag(node, "p", "&mdash;", "class", "attribution"))
+}
+
+func (t *HTMLTranslator) DepartAttribution(node *rst.Element) {
+	t.body = append(t.body, "</p>\n")
+}
+
+func (t *HTMLTranslator) VisitAuthor(node *rst.Element) {
+	if node.Parent().TagName() != "authors" {
+		t.visitDocinfoItem(node, "author", true)
+	} else if node.Parent().Index(node) > 0 {
+		t.body = append(t.body, "<br />")
+	}
+}
+
+func (t *HTMLTranslator) DepartAuthor(node *rst.Element) {
+	if node.Parent().TagName() != "authors" {
+		t.departDocinfoItem()
+	}
+}
+
+func (t *HTMLTranslator) VisitAuthors(node *rst.Element) {
+	t.visitDocinfoItem(node, "authors", false)
+}
+
+func (t *HTMLTranslator) DepartAuthors(node *rst.Element) {
+	t.departDocinfoItem()
+}
+
+func (t *HTMLTranslator) VisitBlockQuote(node *rst.Element) {
+	t.body = append(t.body, t.starttag(node, "blockquote", "\n"))
+}
+
+func (t *HTMLTranslator) DepartBlockQuote(node *rst.Element) {
+	t.body = append(t.body, "</blockquote>\n")
+}
+
+// Save the compact state and decide whether `node` is compact.
+func (t *HTMLTranslator) enterList(node *rst.Element) string {
+	oldCompactSimple := t.compactSimple
+	t.push([2]bool{t.compactSimple, t.compactP})
+	t.compactP = false
+	t.compactSimple = t.isCompactable(node)
+	if t.compactSimple && !oldCompactSimple {
+		return "simple"
+	}
+	return ""
+}
+
+func (t *HTMLTranslator) leaveList() {
+	state := t.pop().([2]bool)
+	t.compactSimple, t.compactP = state[0], state[1]
+}
+
+func (t *HTMLTranslator) VisitBulletList(node *rst.Element) {
+	t.body = append(t.body, t.starttag(node, "ul", "\n", "class", t.enterList(node)))
+}
+
+func (t *HTMLTranslator) DepartBulletList(node *rst.Element) {
+	t.leaveList()
+	t.body = append(t.body, "</ul>\n")
+}
+
+func (t *HTMLTranslator) VisitCaption(node *rst.Element) {
+	t.body = append(t.body, t.starttag(node, "figcaption", ""))
+}
+
+func (t *HTMLTranslator) DepartCaption(node *rst.Element) {
+	t.body = append(t.body, "</figcaption>\n")
+}
+
+func (t *HTMLTranslator) VisitCitation(node *rst.Element) {
+	if !t.inFootnoteList {
+		t.body = append(t.body, "<dl class=\"citation\">\n")
+		t.inFootnoteList = true
+	}
+}
+
+func (t *HTMLTranslator) DepartCitation(node *rst.Element) {
+	t.body = append(t.body, "</dd>\n")
+	if nextSibling(node) == nil || nextSibling(node).TagName() != "citation" {
+		t.body = append(t.body, "</dl>\n")
+		t.inFootnoteList = false
+	}
+}
+
+func (t *HTMLTranslator) VisitCitationReference(node *rst.Element) {
+	href := t.href("")
+	if node.HasAttr("refid") {
+		href = t.href(node.Get("refid"))
+	} else if node.HasAttr("refname") {
+		href = t.href(t.document.NameID(node.Get("refname")))
+	}
+	t.body = append(t.body, t.starttag(node, "a", "[", "class", "citation-reference", "href", href))
+}
+
+func (t *HTMLTranslator) DepartCitationReference(node *rst.Element) {
+	t.body = append(t.body, "]</a>")
+}
+
+func (t *HTMLTranslator) VisitClassifier(node *rst.Element) {
+	t.body = append(t.body, " <span class=\"classifier-delimiter\">:</span> ")
+	t.body = append(t.body, t.starttag(node, "span", "", "class", "classifier"))
+}
+
+func (t *HTMLTranslator) DepartClassifier(node *rst.Element) {
+	t.body = append(t.body, "</span>")
+}
+
+// Column specifications are collected for the <colgroup> of the table.
+func (t *HTMLTranslator) VisitColspec(node *rst.Element) {
+	t.colspecs = append(t.colspecs, node)
+	t.stubs = append(t.stubs, node.HasAttr("stub"))
+}
+
+func (t *HTMLTranslator) DepartColspec(node *rst.Element) {
+	// write out <colgroup> when all colspecs are processed
+	if next := nextSibling(node); next != nil && next.TagName() == "colspec" {
+		return
+	}
+	table := node.Parent().Parent()
+	if contains(table.Classes, "colwidths-auto") ||
+		strings.Contains(t.settings.TableStyle, "colwidths-auto") && !contains(table.Classes, "colwidths-given") {
+		return
+	}
+	totalWidth := 0
+	for _, colspec := range t.colspecs {
+		width, _ := strconv.Atoi(colspec.Get("colwidth"))
+		totalWidth += width
+	}
+	if totalWidth == 0 {
+		return
+	}
+	t.body = append(t.body, t.starttag(nil, "colgroup", "\n"))
+	for _, colspec := range t.colspecs {
+		width, _ := strconv.Atoi(colspec.Get("colwidth"))
+		colwidth := int(float64(width)*100.0/float64(totalWidth) + 0.5)
+		t.body = append(t.body, t.emptytag(nil, "col", "\n", "style", fmt.Sprintf("width: %d%%", colwidth)))
+	}
+	t.body = append(t.body, "</colgroup>\n")
+}
+
+var commentDashes = regexp.MustCompile(`-(-+)`)
+
+// Escape double-dashes in comment text.
+func (t *HTMLTranslator) VisitComment(node *rst.Element) error {
+	text := commentDashes.ReplaceAllStringFunc(node.AsText(), func(m string) string {
+		return strings.Repeat("- ", len(m)-1) + "-"
+	})
+	t.body = append(t.body, "<!-- "+text+" -->\n")
+	// Content already processed:
+	return &rst.SkipNode{}
+}
+
+func (t *HTMLTranslator) VisitCompound(node *rst.Element) {
+	t.body = append(t.body, t.starttag(node, "div", "\n", "class", "compound"))
+}
+
+func (t *HTMLTranslator) DepartCompound(node *rst.Element) {
+	t.body = append(t.body, "</div>\n")
+}
+
+func (t *HTMLTranslator) VisitContact(node *rst.Element) {
+	t.visitDocinfoItem(node, "contact", false)
+}
+
+func (t *HTMLTranslator) DepartContact(node *rst.Element) {
+	t.departDocinfoItem()
+}
+
+func (t *HTMLTranslator) VisitContainer(node *rst.Element) {
+	t.body = append(t.body, t.starttag(node, "div", "\n", "class", "docutils container"))
+}
+
+func (t *HTMLTranslator) DepartContainer(node *rst.Element) {
+	t.body = append(t.body, "</div>\n")
+}
+
+func (t *HTMLTranslator) VisitCopyright(node *rst.Element) {
+	t.visitDocinfoItem(node, "copyright", true)
+}
+
+func (t *HTMLTranslator) DepartCopyright(node *rst.Element) {
+	t.departDocinfoItem()
+}
+
+func (t *HTMLTranslator) VisitDate(node *rst.Element) {
+	t.visitDocinfoItem(node, "date", true)
+}
+
+func (t *HTMLTranslator) DepartDate(node *rst.Element) {
+	t.departDocinfoItem()
+}
+
+func (t *HTMLTranslator) VisitDecoration(node *rst.Element)  {}
+func (t *HTMLTranslator) DepartDecoration(node *rst.Element) {}
+
+func (t *HTMLTranslator) VisitDefinition(node *rst.Element) {
+	t.body = append(t.body, t.starttag(node, "dd", ""))
+}
+
+func (t *HTMLTranslator) DepartDefinition(node *rst.Element) {
+	t.body = append(t.body, "</dd>\n")
+}
+
+func (t *HTMLTranslator) VisitDefinitionList(node *rst.Element) {
+	t.body = append(t.body, t.starttag(node, "dl", "\n", "class", t.enterList(node)))
+}
+
+func (t *HTMLTranslator) DepartDefinitionList(node *rst.Element) {
+	t.leaveList()
+	t.body = append(t.body, "</dl>\n")
+}
+
+func (t *HTMLTranslator) VisitDefinitionListItem(node *rst.Element) {
+	// pass class arguments, ids and names to definition term:
+	if node.Len() == 0 {
+		return
+	}
+	if term, ok := node.Children()[0].(*rst.Element); ok {
+		term.Classes = append(append([]string(nil), node.Classes...), term.Classes...)
+		term.Ids = append(append([]string(nil), node.Ids...), term.Ids...)
+		term.Names = append(append([]string(nil), node.Names...), term.Names...)
+	}
+}
+
+func (t *HTMLTranslator) DepartDefinitionListItem(node *rst.Element) {}
+
+func (t *HTMLTranslator) VisitDescription(node *rst.Element) {
+	t.body = append(t.body, t.starttag(node, "dd", ""))
+}
+
+func (t *HTMLTranslator) DepartDescription(node *rst.Element) {
+	t.body = append(t.body, "</dd>\n")
+}
+
+func (t *HTMLTranslator) VisitDocinfo(node *rst.Element) {
+	t.push(len(t.body))
+	classes := "docinfo"
+	if t.isCompactable(node) {
+		classes += " simple"
+	}
+	t.body = append(t.body, t.starttag(node, "dl", "\n", "class", classes))
+}
+
+func (t *HTMLTranslator) DepartDocinfo(node *rst.Element) {
+	t.body = append(t.body, "</dl>\n")
+	start := t.pop().(int)
+	t.docinfo = append([]string(nil), t.body[start:]...)
+	t.body = t.body[:start]
+}
+
+func (t *HTMLTranslator) visitDocinfoItem(node *rst.Element, name string, meta bool) {
+	if meta {
+		t.addMeta(fmt.Sprintf("<meta name=\"%s\" content=\"%s\" />\n", name, Attval(node.AsText())))
+	}
+	t.body = append(t.body, fmt.Sprintf("<dt class=\"%s\">%s<span class=\"colon\">:</span></dt>\n", name, t.language.Labels[name]))
+	t.body = append(t.body, t.starttag(node, "dd", "", "class", name))
+}
+
+func (t *HTMLTranslator) departDocinfoItem() {
+	t.body = append(t.body, "</dd>\n")
+}
+
+func (t *HTMLTranslator) VisitDoctestBlock(node *rst.Element) {
+	t.body = append(t.body, t.starttag(node, "pre", "", "class", "code python doctest"))
+}
+
+func (t *HTMLTranslator) DepartDoctestBlock(node *rst.Element) {
+	t.body = append(t.body, "\n</pre>\n")
+}
+
+func (t *HTMLTranslator) VisitDocument(node *rst.Element) {
+	title := node.Get("title")
+	if title == "" {
+		title = filepath.Base(node.Get("source"))
+	}
+	if title == "" || title == "." {
+		title = "untitled Docutils document"
+	}
+	t.head = append(t.head, "<title>"+Encode(title)+"</title>\n")
+}
+
+func (t *HTMLTranslator) DepartDocument(node *rst.Element) {
+	for _, msg := range t.mathMessages {
+		rst.Walkabout(msg, t)
+	}
+	t.head = append(t.head, t.mathHeader...)
+	t.headPrefix = append(t.headPrefix, doctype, fmt.Sprintf(headPrefixTemplate, t.settings.LanguageCode))
+	t.htmlProlog = append(t.htmlProlog, doctype)
+	t.meta = append([]string{fmt.Sprintf(contentType, "utf-8")}, t.meta...)
+	t.head = append([]string{fmt.Sprintf(contentType, "utf-8")}, t.head...)
+	// skip content-type meta tag with interpolated charset value:
+	t.htmlHead = append(t.htmlHead, t.head[1:]...)
+	t.bodyPrefix = append(t.bodyPrefix, t.starttag(node, "main", "\n"))
+	t.bodySuffix = append([]string{"</main>\n"}, t.bodySuffix...)
+	// fragment is the "naked" body
+	t.fragment = append(t.fragment, t.body...)
+	t.htmlBody = append(t.htmlBody, t.bodyPrefix[1:]...)
+	t.htmlBody = append(t.htmlBody, t.bodyPreDocinfo...)
+	t.htmlBody = append(t.htmlBody, t.docinfo...)
+	t.htmlBody = append(t.htmlBody, t.body...)
+	t.htmlBody = append(t.htmlBody, t.bodySuffix[:len(t.bodySuffix)-1]...)
+}
+
+func (t *HTMLTranslator) VisitEmphasis(node *rst.Element) {
+	t.body = append(t.body, t.starttag(node, "em", ""))
+}
+
+func (t *HTMLTranslator) DepartEmphasis(node *rst.Element) {
+	t.body = append(t.body, "</em>")
+}
+
+func (t *HTMLTranslator) VisitEntry(node *rst.Element) {
+	var classes []string
+	row := node.Parent()
+	if row.Parent().TagName() == "thead" {
+		classes = append(classes, "head")
+	}
+	if t.column < len(t.stubs) && t.stubs[t.column] {
+		classes = append(classes, "stub")
+	}
+	tagname := "td"
+	if len(classes) > 0 {
+		tagname = "th"
+	}
+	atts := []string{"class", strings.Join(classes, " ")}
+	t.column++
+	if node.HasAttr("morerows") {
+		morerows, _ := strconv.Atoi(node.Get("morerows"))
+		atts = append(atts, "rowspan", strconv.Itoa(morerows+1))
+	}
+	if node.HasAttr("morecols") {
+		morecols, _ := strconv.Atoi(node.Get("morecols"))
+		atts = append(atts, "colspan", strconv.Itoa(morecols+1))
+		t.column += morecols
+	}
+	t.body = append(t.body, t.starttag(node, tagname, "", atts...))
+	t.push("</" + tagname + ">\n")
+}
+
+func (t *HTMLTranslator) DepartEntry(node *rst.Element) {
+	t.appendPopped()
+}
+
+func (t *HTMLTranslator) VisitEnumeratedList(node *rst.Element) {
+	atts := []string{}
+	if node.HasAttr("start") {
+		atts = append(atts, "start", node.Get("start"))
+	}
+	classes := []string{}
+	if node.HasAttr("enumtype") {
+		classes = append(classes, node.Get("enumtype"))
+	}
+	if cls := t.enterList(node); cls != "" {
+		classes = append(classes, cls)
+	}
+	atts = append(atts, "class", strings.Join(classes, " "))
+	t.body = append(t.body, t.starttag(node, "ol", "\n", atts...))
+}
+
+func (t *HTMLTranslator) DepartEnumeratedList(node *rst.Element) {
+	t.leaveList()
+	t.body = append(t.body, "</ol>\n")
+}
+
+func (t *HTMLTranslator) VisitField(node *rst.Element)  {}
+func (t *HTMLTranslator) DepartField(node *rst.Element) {}
+
+func (t *HTMLTranslator) VisitFieldBody(node *rst.Element) {
+	t.body = append(t.body, t.starttag(node, "dd", "", "class", strings.Join(node.Parent().Classes, " ")))
+}
+
+func (t *HTMLTranslator) DepartFieldBody(node *rst.Element) {
+	t.body = append(t.body, "</dd>\n")
+}
+
+func (t *HTMLTranslator) VisitFieldList(node *rst.Element) {
+	t.push([2]bool{t.compactFieldList, t.compactP})
+	t.compactP = false
+	t.compactFieldList = t.isCompactable(node)
+	classes := "field-list"
+	if t.compactFieldList {
+		classes += " simple"
+	}
+	t.body = append(t.body, t.starttag(node, "dl", "\n", "class", classes))
+}
+
+func (t *HTMLTranslator) DepartFieldList(node *rst.Element) {
+	state := t.pop().([2]bool)
+	t.compactFieldList, t.compactP = state[0], state[1]
+	t.body = append(t.body, "</dl>\n")
+}
+
+func (t *HTMLTranslator) VisitFieldName(node *rst.Element) {
+	t.body = append(t.body, t.starttag(node, "dt", "", "class", strings.Join(node.Parent().Classes, " ")))
+}
+
+func (t *HTMLTranslator) DepartFieldName(node *rst.Element) {
+	t.body = append(t.body, "<span class=\"colon\">:</span></dt>\n")
+}
+
+func (t *HTMLTranslator) VisitFigure(node *rst.Element) {
+	atts := []string{"class", "figure"}
+	if node.HasAttr("width") {
+		atts = append(atts, "style", "width: "+node.Get("width"))
+	}
+	if node.HasAttr("align") {
+		atts[1] += " align-" + node.Get("align")
+	}
+	t.body = append(t.body, t.starttag(node, "figure", "\n", atts...))
+}
+
+func (t *HTMLTranslator) DepartFigure(node *rst.Element) {
+	t.body = append(t.body, "</figure>\n")
+}
+
+func (t *HTMLTranslator) VisitFooter(node *rst.Element) {
+	t.push(len(t.body))
+}
+
+func (t *HTMLTranslator) DepartFooter(node *rst.Element) {
+	start := t.pop().(int)
+	footer := []string{t.starttag(node, "footer", "\n")}
+	footer = append(footer, t.body[start:]...)
+	footer = append(footer, "</footer>\n")
+	t.footer = append(t.footer, footer...)
+	t.bodySuffix = append(footer, t.bodySuffix...)
+	t.body = t.body[:start]
+}
+
+func (t *HTMLTranslator) VisitFootnote(node *rst.Element) {
+	if !t.inFootnoteList {
+		classes := "footnote " + t.settings.FootnoteReferences
+		t.body = append(t.body, fmt.Sprintf("<dl class=\"%s\">\n", classes))
+		t.inFootnoteList = true
+	}
+}
+
+func (t *HTMLTranslator) DepartFootnote(node *rst.Element) {
+	t.body = append(t.body, "</dd>\n")
+	if next := nextSibling(node); next == nil || next.TagName() != "footnote" {
+		t.body = append(t.body, "</dl>\n")
+		t.inFootnoteList = false
+	}
+}
+
+func (t *HTMLTranslator) VisitFootnoteReference(node *rst.Element) {
+	href := t.href(node.Get("refid"))
+	classes := "footnote-reference " + t.settings.FootnoteReferences
+	t.body = append(t.body, t.starttag(node, "a", "", "class", classes, "href", href))
+}
+
+func (t *HTMLTranslator) DepartFootnoteReference(node *rst.Element) {
+	t.body = append(t.body, "</a>")
+}
+
+func (t *HTMLTranslator) VisitGenerated(node *rst.Element)  {}
+func (t *HTMLTranslator) DepartGenerated(node *rst.Element) {}
+
+func (t *HTMLTranslator) VisitHeader(node *rst.Element) {
+	t.push(len(t.body))
+}
+
+func (t *HTMLTranslator) DepartHeader(node *rst.Element) {
+	start := t.pop().(int)
+	header := []string{t.starttag(node, "header", "\n")}
+	header = append(header, t.body[start:]...)
+	header = append(header, "</header>\n")
+	t.bodyPrefix = append(t.bodyPrefix, header...)
+	t.header = append(t.header, header...)
+	t.body = t.body[:start]
+}
+
+func (t *HTMLTranslator) VisitImage(node *rst.Element) error {
+	uri := node.Get("uri")
+	src := uri
+	if t.imageSource != nil {
+		src = t.imageSource(uri)
+	}
+	parent := node.Parent()
+	inline := parent.Is(rst.TextElementClass) || parent.TagName() == "reference"
+	if src == "" {
+		// the image is not available: its alternate text replaces it
+		alt := Encode(node.Get("alt"))
+		switch {
+		case alt == "":
+		case inline:
+			t.body = append(t.body, alt)
+		default:
+			t.body = append(t.body, t.starttag(node, "p", "", "class", "image-alt"), alt, "</p>\n")
+		}
+		return &rst.SkipNode{}
+	}
+	atts := []string{"src", src}
+	if node.HasAttr("alt") {
+		atts = append(atts, "alt", node.Get("alt"))
+	} else {
+		atts = append(atts, "alt", uri)
+	}
+	for _, name := range []string{"width", "height"} {
+		if node.HasAttr(name) {
+			atts = append(atts, name, node.Get(name))
+		}
+	}
+	if node.HasAttr("align") {
+		atts = append(atts, "class", "align-"+node.Get("align"))
+	}
+	suffix := "\n"
+	if parent.Is(rst.TextElementClass) ||
+		parent.TagName() == "reference" && !parent.Parent().Is(rst.TextElementClass) {
+		// Inline context or surrounded by <a>...</a>.
+		suffix = ""
+	}
+	t.body = append(t.body, t.emptytag(node, "img", suffix, atts...))
+	return &rst.SkipNode{}
+}
+
+func (t *HTMLTranslator) VisitInline(node *rst.Element) {
+	t.body = append(t.body, t.starttag(node, "span", ""))
+}
+
+func (t *HTMLTranslator) DepartInline(node *rst.Element) {
+	t.body = append(t.body, "</span>")
+}
+
+func (t *HTMLTranslator) VisitLabel(node *rst.Element) {
+	parent := node.Parent()
+	classes := "brackets"
+	if parent.TagName() == "footnote" {
+		classes = t.settings.FootnoteReferences
+	}
+	// pass parent node to get id into starttag:
+	t.body = append(t.body, t.starttag(parent, "dt", "", "class", "label"))
+	t.body = append(t.body, t.starttag(node, "span", "", "class", classes))
+	// footnote/citation backrefs:
+	if t.settings.FootnoteBacklinks && len(parent.Backrefs) == 1 {
+		t.body = append(t.body, fmt.Sprintf("<a class=\"fn-backref\" href=\"%s\">", t.href(parent.Backrefs[0])))
+	}
+}
+
+func (t *HTMLTranslator) DepartLabel(node *rst.Element) {
+	backrefs := node.Parent().Backrefs
+	if t.settings.FootnoteBacklinks && len(backrefs) == 1 {
+		t.body = append(t.body, "</a>")
+	}
+	t.body = append(t.body, "</span>")
+	if t.settings.FootnoteBacklinks && len(backrefs) > 1 {
+		var backlinks []string
+		for i, ref := range backrefs {
+			backlinks = append(backlinks, fmt.Sprintf("<a href=\"%s\">%d</a>", t.href(ref), i+1))
+		}
+		t.body = append(t.body, fmt.Sprintf("<span class=\"fn-backref\">(%s)</span>", strings.Join(backlinks, ",")))
+	}
+	t.body = append(t.body, "</dt>\n<dd>")
+}
+
+func (t *HTMLTranslator) VisitLegend(node *rst.Element) {
+	t.body = append(t.body, t.starttag(node, "div", "\n", "class", "legend"))
+}
+
+func (t *HTMLTranslator) DepartLegend(node *rst.Element) {
+	t.body = append(t.body, "</div>\n")
+}
+
+func (t *HTMLTranslator) VisitLine(node *rst.Element) {
+	t.body = append(t.body, t.starttag(node, "div", "", "class", "line"))
+	if node.Len() == 0 {
+		t.body = append(t.body, "<br />")
+	}
+}
+
+func (t *HTMLTranslator) DepartLine(node *rst.Element) {
+	t.body = append(t.body, "</div>\n")
+}
+
+func (t *HTMLTranslator) VisitLineBlock(node *rst.Element) {
+	t.body = append(t.body, t.starttag(node, "div", "\n", "class", "line-block"))
+}
+
+func (t *HTMLTranslator) DepartLineBlock(node *rst.Element) {
+	t.body = append(t.body, "</div>\n")
+}
+
+func (t *HTMLTranslator) VisitListItem(node *rst.Element) {
+	t.body = append(t.body, t.starttag(node, "li", ""))
+}
+
+func (t *HTMLTranslator) DepartListItem(node *rst.Element) {
+	t.body = append(t.body, "</li>\n")
+}
+
+var (
+	wordsAndSpaces  = regexp.MustCompile(`[^ \n]+| +|\n`)
+	inWordWrapPoint = regexp.MustCompile(`.+\W\W.+|[-?].+`)
+)
+
+/*
+   Inline literals. Text of the "code" role (class "code") is kept in a
+   <code> element with the highlighting spans; other literals are protected
+   from bad line wrapping.
+*/
+func (t *HTMLTranslator) VisitLiteral(node *rst.Element) error {
+	// special case: "code" role
+	if contains(node.Classes, "code") {
+		// filter "code" from class arguments
+		c := node.Copy()
+		c.Classes = remove(c.Classes, "code")
+		t.body = append(t.body, t.starttag(c, "code", ""))
+		return nil
+	}
+	t.body = append(t.body, t.starttag(node, "span", "", "class", "docutils literal"))
+	text := node.AsText()
+	if node.Parent().TagName() != "literal_block" {
+		text = strings.Replace(text, "\n", " ", -1)
+	}
+	// Protect text like ``--an-option`` and the regular expression
+	// ``[+]?(\d+(\.\d*)?|\.\d+)`` from bad line wrapping
+	for _, token := range wordsAndSpaces.FindAllString(text, -1) {
+		if strings.TrimSpace(token) != "" && inWordWrapPoint.MatchString(token) {
+			t.body = append(t.body, "<span class=\"pre\">"+Encode(token)+"</span>")
+		} else {
+			t.body = append(t.body, Encode(token))
+		}
+	}
+	t.body = append(t.body, "</span>")
+	// Content already processed:
+	return &rst.SkipNode{}
+}
+
+// Skipped unless the literal element is from the "code" role.
+func (t *HTMLTranslator) DepartLiteral(node *rst.Element) {
+	t.body = append(t.body, "</code>")
+}
+
+func (t *HTMLTranslator) VisitLiteralBlock(node *rst.Element) {
+	t.body = append(t.body, t.starttag(node, "pre", "", "class", "literal-block"))
+	if contains(node.Classes, "code") {
+		t.body = append(t.body, "<code>")
+	}
+}
+
+func (t *HTMLTranslator) DepartLiteralBlock(node *rst.Element) {
+	if contains(node.Classes, "code") {
+		t.body = append(t.body, "</code>")
+	}
+	t.body = append(t.body, "</pre>\n")
+}
+
+const (
+	mathjaxScript = "<script type=\"text/javascript\" src=\"%s\"></script>\n"
+	// default to the local MathJax installation of Debian and derivatives
+	mathjaxURL = "file:/usr/share/javascript/mathjax/MathJax.js?config=TeX-AMS_CHTML"
+	katexURL   = "https://cdn.jsdelivr.net/npm/katex@0.16.9/dist"
+)
+
+/*
+   Tags and class for math elements by math output format:
+   block element tag, inline element tag, class.
+*/
+var mathTags = map[string][3]string{
+	"mathml":  {"div", "", ""},
+	"mathjax": {"div", "span", "math"},
+	"katex":   {"div", "span", "math"},
+	"latex":   {"pre", "code", "math"},
+}
+
+// Set up the math output format from the "MathOutput" setting.
+func (t *HTMLTranslator) setupMath(node *rst.Element) {
+	fields := strings.Fields(t.settings.MathOutput)
+	t.mathOutput = "mathml"
+	if len(fields) > 0 {
+		t.mathOutput = strings.ToLower(fields[0])
+		t.mathOutputOptions = fields[1:]
+	}
+	switch t.mathOutput {
+	case "mathjax":
+		url := mathjaxURL
+		if len(t.mathOutputOptions) > 0 {
+			url = t.mathOutputOptions[0]
+		} else {
+			t.document.Reporter().Warning("No MathJax URL specified, using local fallback (see config.html).",
+				node.Source(), node.Line())
+		}
+		t.mathHeader = []string{fmt.Sprintf(mathjaxScript, Encode(url))}
+	case "katex":
+		url := katexURL
+		if len(t.mathOutputOptions) > 0 {
+			url = strings.TrimSuffix(t.mathOutputOptions[0], "/")
+		}
+		url = Encode(url)
+		t.mathHeader = []string{
+			fmt.Sprintf("<link rel=\"stylesheet\" href=\"%s/katex.min.css\" type=\"text/css\" />\n", url),
+			fmt.Sprintf("<script defer=\"defer\" src=\"%s/katex.min.js\"></script>\n", url),
+			fmt.Sprintf("<script defer=\"defer\" src=\"%s/contrib/auto-render.min.js\" "+
+				"onload=\"renderMathInElement(document.body);\"></script>\n", url),
+		}
+	case "mathml", "latex":
+	default:
+		t.document.Reporter().Error(fmt.Sprintf("math-output format \"%s\" not supported, falling back to \"latex\"",
+			t.mathOutput), node.Source(), node.Line())
+		t.mathOutput = "latex"
+	}
+}
+
+/*
+   Write math (inline or block) in the configured math output format.
+
+   MathML is converted from the LaTeX code here, no JavaScript is needed;
+   with MathJax or KaTeX the (wrapped) LaTeX code is output and rendered by
+   the script in the HTML head; "LaTeX" outputs the code as is.
+*/
+func (t *HTMLTranslator) writeMath(node *rst.Element, block bool) {
+	if t.mathOutput == "" {
+		t.setupMath(node)
+	}
+	code := node.AsText()
+	env := ""
+	if block {
+		env = rst.PickMathEnvironment(code, false)
+	}
+	tags := mathTags[t.mathOutput]
+	tag := tags[1]
+	suffix := ""
+	if block {
+		tag = tags[0]
+		suffix = "\n"
+	}
+
+	switch t.mathOutput {
+	case "mathml":
+		mathml, err := rst.Tex2MathML(code, !block)
+		if err != nil {
+			msg := t.document.Reporter().Error(err.Error(), node.Source(), node.Line())
+			if block {
+				t.body = append(t.body, t.starttag(node, "pre", "", "class", "literal-block"),
+					Encode(code), "</pre>\n")
+				rst.Walkabout(msg, t)
+			} else {
+				t.body = append(t.body, t.starttag(node, "span", "", "class", "math"),
+					Encode(code), "</span>")
+				t.mathMessages = append(t.mathMessages, msg)
+			}
+			return
+		}
+		code = mathml
+	case "mathjax":
+		code = Encode(code)
+		if !block {
+			code = `\(` + code + `\)`
+		} else if env != "" {
+			code = fmt.Sprintf("\\begin{%s}\n%s\n\\end{%s}", env, code, env)
+		}
+	case "katex":
+		code = Encode(code)
+		if !block {
+			code = `\(` + code + `\)`
+		} else {
+			if strings.HasPrefix(env, "align") {
+				code = "\\begin{aligned}\n" + code + "\n\\end{aligned}"
+			}
+			code = `\[` + code + `\]`
+		}
+	case "latex":
+		code = Encode(code)
+	}
+
+	if tag == "" {
+		t.body = append(t.body, code)
+		return
+	}
+	if tags[2] != "" {
+		t.body = append(t.body, t.starttag(node, tag, suffix, "class", tags[2]))
+	} else {
+		t.body = append(t.body, t.starttag(node, tag, suffix))
+	}
+	t.body = append(t.body, code, suffix, "</"+tag+">"+suffix)
+}
+
+func (t *HTMLTranslator) VisitMath(node *rst.Element) error {
+	t.writeMath(node, false)
+	return &rst.SkipNode{}
+}
+
+func (t *HTMLTranslator) VisitMathBlock(node *rst.Element) error {
+	t.writeMath(node, true)
+	return &rst.SkipNode{}
+}
+
+func (t *HTMLTranslator) VisitOption(node *rst.Element) {
+	t.body = append(t.body, t.starttag(node, "span", "", "class", "option"))
+}
+
+func (t *HTMLTranslator) DepartOption(node *rst.Element) {
+	t.body = append(t.body, "</span>")
+	if next := nextSibling(node); next != nil && next.TagName() == "option" {
+		t.body = append(t.body, ", ")
+	}
+}
+
+func (t *HTMLTranslator) VisitOptionArgument(node *rst.Element) {
+	delimiter := " "
+	if node.HasAttr("delimiter") {
+		delimiter = node.Get("delimiter")
+	}
+	t.body = append(t.body, delimiter)
+	t.body = append(t.body, t.starttag(node, "var", ""))
+}
+
+func (t *HTMLTranslator) DepartOptionArgument(node *rst.Element) {
+	t.body = append(t.body, "</var>")
+}
+
+func (t *HTMLTranslator) VisitOptionGroup(node *rst.Element) {
+	t.body = append(t.body, t.starttag(node, "dt", ""))
+	t.body = append(t.body, "<kbd>")
+}
+
+func (t *HTMLTranslator) DepartOptionGroup(node *rst.Element) {
+	t.body = append(t.body, "</kbd></dt>\n")
+}
+
+func (t *HTMLTranslator) VisitOptionList(node *rst.Element) {
+	t.body = append(t.body, t.starttag(node, "dl", "\n", "class", "option-list"))
+}
+
+func (t *HTMLTranslator) DepartOptionList(node *rst.Element) {
+	t.body = append(t.body, "</dl>\n")
+}
+
+func (t *HTMLTranslator) VisitOptionListItem(node *rst.Element)  {}
+func (t *HTMLTranslator) DepartOptionListItem(node *rst.Element) {}
+func (t *HTMLTranslator) VisitOptionString(node *rst.Element)    {}
+func (t *HTMLTranslator) DepartOptionString(node *rst.Element)   {}
+
+func (t *HTMLTranslator) VisitOrganization(node *rst.Element) {
+	t.visitDocinfoItem(node, "organization", true)
+}
+
+func (t *HTMLTranslator) DepartOrganization(node *rst.Element) {
+	t.departDocinfoItem()
+}
+
+func (t *HTMLTranslator) VisitParagraph(node *rst.Element) {
+	if t.shouldBeCompactParagraph(node) {
+		t.push("")
+	} else {
+		t.body = append(t.body, t.starttag(node, "p", ""))
+		t.push("</p>\n")
+	}
+}
+
+func (t *HTMLTranslator) DepartParagraph(node *rst.Element) {
+	t.appendPopped()
+}
+
+// Pending elements should have been handled by their transforms.
+func (t *HTMLTranslator) VisitPending(node *rst.Element) error {
+	return &rst.SkipNode{}
+}
+
+func (t *HTMLTranslator) VisitProblematic(node *rst.Element) {
+	if node.HasAttr("refid") {
+		t.body = append(t.body, fmt.Sprintf("<a href=\"%s\">", t.href(node.Get("refid"))))
+		t.push("</a>")
+	} else {
+		t.push("")
+	}
+	t.body = append(t.body, t.starttag(node, "span", "", "class", "problematic"))
+}
+
+func (t *HTMLTranslator) DepartProblematic(node *rst.Element) {
+	t.body = append(t.body, "</span>")
+	t.appendPopped()
+}
+
+func (t *HTMLTranslator) VisitRaw(node *rst.Element) error {
+	if writers.RawFormatMatches(node, "html") {
+		tagname := "div"
+		if node.Parent().Is(rst.TextElementClass) {
+			tagname = "span"
+		}
+		if len(node.Classes) > 0 {
+			t.body = append(t.body, t.starttag(node, tagname, ""))
+		}
+		t.body = append(t.body, node.AsText())
+		if len(node.Classes) > 0 {
+			t.body = append(t.body, "</"+tagname+">")
+		}
+	}
+	// Keep non-HTML raw text out of output:
+	return &rst.SkipNode{}
+}
+
+func (t *HTMLTranslator) VisitReference(node *rst.Element) {
+	classes := "reference"
+	var atts []string
+	if node.HasAttr("refuri") {
+		atts = append(atts, "href", node.Get("refuri"))
+		classes += " external"
+	} else if node.HasAttr("refid") {
+		atts = append(atts, "href", t.href(node.Get("refid")))
+		classes += " internal"
+	}
+	if !node.Parent().Is(rst.TextElementClass) {
+		classes += " image-reference"
+	}
+	atts = append(atts, "class", classes)
+	t.body = append(t.body, t.starttag(node, "a", "", atts...))
+}
+
+func (t *HTMLTranslator) DepartReference(node *rst.Element) {
+	t.body = append(t.body, "</a>")
+	if !node.Parent().Is(rst.TextElementClass) {
+		t.body = append(t.body, "\n")
+	}
+}
+
+func (t *HTMLTranslator) VisitRevision(node *rst.Element) {
+	t.visitDocinfoItem(node, "revision", false)
+}
+
+func (t *HTMLTranslator) DepartRevision(node *rst.Element) {
+	t.departDocinfoItem()
+}
+
+func (t *HTMLTranslator) VisitRow(node *rst.Element) {
+	t.body = append(t.body, t.starttag(node, "tr", ""))
+	t.column = 0
+}
+
+func (t *HTMLTranslator) DepartRow(node *rst.Element) {
+	t.body = append(t.body, "</tr>\n")
+}
+
+func (t *HTMLTranslator) VisitRubric(node *rst.Element) {
+	t.body = append(t.body, t.starttag(node, "p", "", "class", "rubric"))
+}
+
+func (t *HTMLTranslator) DepartRubric(node *rst.Element) {
+	t.body = append(t.body, "</p>\n")
+}
+
+func (t *HTMLTranslator) VisitSection(node *rst.Element) {
+	t.sectionLevel++
+	t.body = append(t.body, t.starttag(node, "section", "\n"))
+}
+
+func (t *HTMLTranslator) DepartSection(node *rst.Element) {
+	t.sectionLevel--
+	t.body = append(t.body, "</section>\n")
+}
+
+func (t *HTMLTranslator) VisitSidebar(node *rst.Element) {
+	t.body = append(t.body, t.starttag(node, "aside", "\n", "class", "sidebar"))
+	t.inSidebar(true)
+}
+
+func (t *HTMLTranslator) DepartSidebar(node *rst.Element) {
+	t.body = append(t.body, "</aside>\n")
+	t.inSidebar(false)
+}
+
+// Sidebars are not compact, whatever list they are in.
+func (t *HTMLTranslator) inSidebar(entering bool) {
+	if entering {
+		t.push([2]bool{t.compactSimple, t.compactP})
+		t.compactSimple, t.compactP = false, true
+	} else {
+		state := t.pop().([2]bool)
+		t.compactSimple, t.compactP = state[0], state[1]
+	}
+}
+
+func (t *HTMLTranslator) VisitStatus(node *rst.Element) {
+	t.visitDocinfoItem(node, "status", false)
+}
+
+func (t *HTMLTranslator) DepartStatus(node *rst.Element) {
+	t.departDocinfoItem()
+}
+
+func (t *HTMLTranslator) VisitStrong(node *rst.Element) {
+	t.body = append(t.body, t.starttag(node, "strong", ""))
+}
+
+func (t *HTMLTranslator) DepartStrong(node *rst.Element) {
+	t.body = append(t.body, "</strong>")
+}
+
+func (t *HTMLTranslator) VisitSubscript(node *rst.Element) {
+	t.body = append(t.body, t.starttag(node, "sub", ""))
+}
+
+func (t *HTMLTranslator) DepartSubscript(node *rst.Element) {
+	t.body = append(t.body, "</sub>")
+}
+
+// Substitution definitions are internal; their content is used by the
+// substitution references.
+func (t *HTMLTranslator) VisitSubstitutionDefinition(node *rst.Element) error {
+	return &rst.SkipNode{}
+}
+
+// Unresolved substitution references are written as text.
+func (t *HTMLTranslator) VisitSubstitutionReference(node *rst.Element)  {}
+func (t *HTMLTranslator) DepartSubstitutionReference(node *rst.Element) {}
+
+func (t *HTMLTranslator) VisitSubtitle(node *rst.Element) {
+	switch node.Parent().TagName() {
+	case "sidebar":
+		t.body = append(t.body, t.starttag(node, "p", "", "class", "sidebar-subtitle"))
+		t.push("</p>\n")
+	case "document":
+		t.body = append(t.body, t.starttag(node, "p", "", "class", "subtitle"))
+		t.push("</p>\n")
+		t.inDocumentTitle = len(t.body)
+	case "section":
+		level := t.sectionLevel + t.initialHeaderLevel - 1
+		tagname := "h" + strconv.Itoa(level)
+		t.body = append(t.body, t.starttag(node, tagname, "", "class", "section-subtitle"))
+		t.push("</" + tagname + ">\n")
+	}
+}
+
+func (t *HTMLTranslator) DepartSubtitle(node *rst.Element) {
+	t.appendPopped()
+	if t.inDocumentTitle > 0 {
+		t.subtitle = append([]string(nil), t.body[t.inDocumentTitle:len(t.body)-1]...)
+		t.inDocumentTitle = 0
+		t.bodyPreDocinfo = append(t.bodyPreDocinfo, t.body...)
+		t.htmlSubtitle = append(t.htmlSubtitle, t.body...)
+		t.body = nil
+	}
+}
+
+func (t *HTMLTranslator) VisitSuperscript(node *rst.Element) {
+	t.body = append(t.body, t.starttag(node, "sup", ""))
+}
+
+func (t *HTMLTranslator) DepartSuperscript(node *rst.Element) {
+	t.body = append(t.body, "</sup>")
+}
+
+func (t *HTMLTranslator) VisitSystemMessage(node *rst.Element) {
+	t.body = append(t.body, t.starttag(node, "div", "\n", "class", "system-message"))
+	t.body = append(t.body, "<p class=\"system-message-title\">")
+	backrefText := ""
+	if len(node.Backrefs) == 1 {
+		backrefText = fmt.Sprintf("; <em><a href=\"%s\">backlink</a></em>", t.href(node.Backrefs[0]))
+	} else if len(node.Backrefs) > 1 {
+		var backlinks []string
+		for i, backref := range node.Backrefs {
+			backlinks = append(backlinks, fmt.Sprintf("<a href=\"%s\">%d</a>", t.href(backref), i+1))
+		}
+		backrefText = fmt.Sprintf("; <em>backlinks: %s</em>", strings.Join(backlinks, ", "))
+	}
+	line := ""
+	if node.HasAttr("line") {
+		line = ", line " + node.Get("line")
+	}
+	t.body = append(t.body, fmt.Sprintf("System Message: %s/%s (<span class=\"docutils literal\">%s</span>%s)%s</p>\n",
+		node.Get("type"), node.Get("level"), Encode(node.Get("source")), line, backrefText))
+}
+
+func (t *HTMLTranslator) DepartSystemMessage(node *rst.Element) {
+	t.body = append(t.body, "</div>\n")
+}
+
+func (t *HTMLTranslator) VisitTable(node *rst.Element) {
+	var classes []string
+	for _, cls := range strings.Split(t.settings.TableStyle, ",") {
+		classes = append(classes, strings.TrimSpace(cls))
+	}
+	if node.HasAttr("align") {
+		classes = append(classes, "align-"+node.Get("align"))
+	}
+	atts := []string{"class", strings.Join(classes, " ")}
+	if node.HasAttr("width") {
+		atts = append(atts, "style", "width: "+node.Get("width"))
+	}
+	t.body = append(t.body, t.starttag(node, "table", "\n", atts...))
+}
+
+func (t *HTMLTranslator) DepartTable(node *rst.Element) {
+	t.body = append(t.body, "</table>\n")
+}
+
+func (t *HTMLTranslator) VisitTarget(node *rst.Element) {
+	if !(node.HasAttr("refuri") || node.HasAttr("refid") || node.HasAttr("refname")) {
+		t.body = append(t.body, t.starttag(node, "span", "", "class", "target"))
+		t.push("</span>")
+	} else {
+		t.push("")
+	}
+}
+
+func (t *HTMLTranslator) DepartTarget(node *rst.Element) {
+	t.appendPopped()
+}
+
+func (t *HTMLTranslator) VisitTbody(node *rst.Element) {
+	t.body = append(t.body, t.starttag(node, "tbody", "\n"))
+}
+
+func (t *HTMLTranslator) DepartTbody(node *rst.Element) {
+	t.body = append(t.body, "</tbody>\n")
+}
+
+func (t *HTMLTranslator) VisitTerm(node *rst.Element) {
+	t.body = append(t.body, t.starttag(node, "dt", ""))
+}
+
+func (t *HTMLTranslator) DepartTerm(node *rst.Element) {
+	// Leave the end tag to `VisitDefinition()`, in case there's a
+	// classifier.
+	t.body = append(t.body, "</dt>\n")
+}
+
+func (t *HTMLTranslator) VisitTgroup(node *rst.Element) {
+	t.colspecs = nil
+	t.stubs = nil
+}
+
+func (t *HTMLTranslator) DepartTgroup(node *rst.Element) {}
+
+func (t *HTMLTranslator) VisitThead(node *rst.Element) {
+	t.body = append(t.body, t.starttag(node, "thead", "\n"))
+}
+
+func (t *HTMLTranslator) DepartThead(node *rst.Element) {
+	t.body = append(t.body, "</thead>\n")
+}
+
+// Only 6 section levels are supported by HTML.
+func (t *HTMLTranslator) VisitTitle(node *rst.Element) {
+	closeTag := "</p>\n"
+	parent := node.Parent()
+	switch {
+	case parent.TagName() == "topic":
+		t.body = append(t.body, t.starttag(node, "p", "", "class", "topic-title"))
+	case parent.TagName() == "sidebar":
+		t.body = append(t.body, t.starttag(node, "p", "", "class", "sidebar-title"))
+	case parent.Is(rst.Admonition):
+		t.body = append(t.body, t.starttag(node, "p", "", "class", "admonition-title"))
+	case parent.TagName() == "table":
+		t.body = append(t.body, t.starttag(node, "caption", ""))
+		closeTag = "</caption>\n"
+	case parent.TagName() == "document":
+		t.body = append(t.body, t.starttag(node, "h1", "", "class", "title"))
+		closeTag = "</h1>\n"
+		t.inDocumentTitle = len(t.body)
+	default:
+		level := t.sectionLevel + t.initialHeaderLevel - 1
+		if level > 6 {
+			level = 6
+		}
+		tagname := "h" + strconv.Itoa(level)
+		var atts []string
+		if parent.Len() >= 2 && parent.Children()[1].TagName() == "subtitle" {
+			atts = append(atts, "class", "with-subtitle")
+		}
+		t.body = append(t.body, t.starttag(node, tagname, "", atts...))
+		closeTag = "</" + tagname + ">\n"
+		if node.HasAttr("refid") {
+			t.body = append(t.body, t.starttag(nil, "a", "", "class", "toc-backref", "href", t.href(node.Get("refid"))))
+			closeTag = "</a>" + closeTag
+		}
+	}
+	t.push(closeTag)
+}
+
+func (t *HTMLTranslator) DepartTitle(node *rst.Element) {
+	t.appendPopped()
+	if t.inDocumentTitle > 0 {
+		t.title = append([]string(nil), t.body[t.inDocumentTitle:len(t.body)-1]...)
+		t.inDocumentTitle = 0
+		t.bodyPreDocinfo = append(t.bodyPreDocinfo, t.body...)
+		t.htmlTitle = append(t.htmlTitle, t.body...)
+		t.body = nil
+	}
+}
+
+func (t *HTMLTranslator) VisitTitleReference(node *rst.Element) {
+	t.body = append(t.body, t.starttag(node, "cite", ""))
+}
+
+func (t *HTMLTranslator) DepartTitleReference(node *rst.Element) {
+	t.body = append(t.body, "</cite>")
+}
+
+func (t *HTMLTranslator) VisitTopic(node *rst.Element) {
+	tagname := "div"
+	if contains(node.Classes, "contents") {
+		tagname = "nav"
+		t.tocStart = len(t.body)
+	}
+	t.body = append(t.body, t.starttag(node, tagname, "\n", "class", "topic"))
+	t.push("</" + tagname + ">\n")
+}
+
+func (t *HTMLTranslator) DepartTopic(node *rst.Element) {
+	t.appendPopped()
+	if contains(node.Classes, "contents") {
+		// join the table of contents in one body item, left out of the
+		// template body
+		toc := strings.Join(t.body[t.tocStart:], "")
+		t.body = append(t.body[:t.tocStart], toc)
+		if t.tocIndices == nil {
+			t.tocIndices = map[int]bool{}
+		}
+		t.tocIndices[t.tocStart] = true
+		t.toc = append(t.toc, toc)
+	}
+}
+
+func (t *HTMLTranslator) VisitTransition(node *rst.Element) {
+	t.body = append(t.body, t.emptytag(node, "hr", "\n", "class", "docutils"))
+}
+
+func (t *HTMLTranslator) DepartTransition(node *rst.Element) {}
+
+func (t *HTMLTranslator) VisitVersion(node *rst.Element) {
+	t.visitDocinfoItem(node, "version", false)
+}
+
+func (t *HTMLTranslator) DepartVersion(node *rst.Element) {
+	t.departDocinfoItem()
+}
+
+// Return the sibling following `node`, or nil.
+func nextSibling(node *rst.Element) rst.Node {
+	parent := node.Parent()
+	if parent == nil {
+		return nil
+	}
+	i := parent.Index(node)
+	if i < 0 || i+1 >= parent.Len() {
+		return nil
+	}
+	return parent.Children()[i+1]
+}
+
+func contains(list []string, value string) bool {
+	for _, v := range list {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+func remove(list []string, value string) []string {
+	var result []string
+	for _, v := range list {
+		if v != value {
+			result = append(result, v)
+		}
+	}
+	return result
+}
+
+func sortedKeys(m map[string]string) []string {
+	var keys []string
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// The translator met a node it does not know.
+type NotImplementedError struct {
+	msg string
+}
+
+func (e *NotImplementedError) Error() string {
+	return e.msg
+}
@@ -0,0 +1,325 @@
+package html
+
+import (
+	"html/template"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	rst "github.com/siongui/go-rst"
+	"github.com/siongui/go-rst/directives"
+	"github.com/siongui/go-rst/parsers/docutilsxml"
+	"github.com/siongui/go-rst/transforms"
+)
+
+func TestCodeBlock(t *testing.T) {
+	settings := &rst.Settings{}
+	settings.Init()
+	document := rst.NewDocument("test.rst", settings)
+	document.Extend(directives.Run(document, &document.Element, "code", []string{"go", "", "return nil // done"}, 1, "")...)
+	paragraph := &rst.Element{}
+	paragraph.Init("paragraph", "", "Call ")
+	literal := &rst.Element{}
+	literal.Init("literal", "", "--a-b")
+	paragraph.Append(literal)
+	document.Append(paragraph)
+
+	writer := &Writer{}
+	output, err := writer.Write(document)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(output, "<title>test.rst</title>\n") {
+		t.Error("title missing:\n" + output)
+	}
+	expected := `<pre class="code go literal-block"><code><span class="k">return</span> <span class="kc">nil</span> <span class="c1">// done</span></code></pre>
+<p>Call <span class="docutils literal"><span class="pre">--a-b</span></span></p>
+`
+	if body := writer.Parts()["body"]; body != expected {
+		t.Error("code block failed:\n" + body)
+	}
+	if !writer.Supports("html") || writer.Supports("latex") {
+		t.Error("wrong supported formats")
+	}
+}
+
+func TestMath(t *testing.T) {
+	newDocument := func(mathOutput string) *rst.Document {
+		settings := &rst.Settings{}
+		settings.Init()
+		settings.WarningStream = nil
+		settings.MathOutput = mathOutput
+		document := rst.NewDocument("test.rst", settings)
+		block := &rst.Element{}
+		block.Init("math_block", "", "a &= b \\\\ c &= d")
+		paragraph := &rst.Element{}
+		paragraph.Init("paragraph", "", "Inline ")
+		math := &rst.Element{}
+		math.Init("math", "", "x<y")
+		paragraph.Append(math)
+		document.Extend(block, paragraph)
+		return document
+	}
+	cases := []struct {
+		mathOutput string
+		expected   string
+	}{
+		{"MathML", `<div>
+<math xmlns="http://www.w3.org/1998/Math/MathML" display="block"><mtable columnalign="right left right left right left" columnspacing="0em 2em" displaystyle="true"><mtr><mtd><mi>a</mi></mtd><mtd><mo>=</mo><mi>b</mi></mtd></mtr><mtr><mtd><mi>c</mi></mtd><mtd><mo>=</mo><mi>d</mi></mtd></mtr></mtable></math>
+</div>
+<p>Inline <math xmlns="http://www.w3.org/1998/Math/MathML"><mrow><mi>x</mi><mo>&lt;</mo><mi>y</mi></mrow></math></p>
+`},
+		{"MathJax /js/MathJax.js", `<div class="math">
+\begin{align*}
+a &amp;= b \\ c &amp;= d
+\end{align*}
+</div>
+<p>Inline <span class="math">\(x&lt;y\)</span></p>
+`},
+		{"KaTeX", `<div class="math">
+\[\begin{aligned}
+a &amp;= b \\ c &amp;= d
+\end{aligned}\]
+</div>
+<p>Inline <span class="math">\(x&lt;y\)</span></p>
+`},
+		{"LaTeX", `<pre class="math">
+a &amp;= b \\ c &amp;= d
+</pre>
+<p>Inline <code class="math">x&lt;y</code></p>
+`},
+	}
+	for _, c := range cases {
+		writer := &Writer{}
+		if _, err := writer.Write(newDocument(c.mathOutput)); err != nil {
+			t.Fatal(err)
+		}
+		if body := writer.Parts()["body"]; body != c.expected {
+			t.Error(c.mathOutput + " failed:\n" + body)
+		}
+	}
+
+	writer := &Writer{}
+	output, _ := writer.Write(newDocument("MathJax /js/MathJax.js"))
+	if !strings.Contains(output, `<script type="text/javascript" src="/js/MathJax.js"></script>`) {
+		t.Error("MathJax script missing:\n" + output)
+	}
+	writer = &Writer{}
+	output, _ = writer.Write(newDocument("KaTeX /katex/"))
+	if !strings.Contains(output, `<link rel="stylesheet" href="/katex/katex.min.css" type="text/css" />
+<script defer="defer" src="/katex/katex.min.js"></script>
+`) {
+		t.Error("KaTeX stylesheet or script missing:\n" + output)
+	}
+
+	// the error of inline math goes after the paragraph
+	settings := &rst.Settings{}
+	settings.Init()
+	settings.WarningStream = nil
+	document := rst.NewDocument("test.rst", settings)
+	paragraph := &rst.Element{}
+	paragraph.Init("paragraph", "", "Inline ")
+	math := &rst.Element{}
+	math.Init("math", "", "\\frac{x")
+	paragraph.Append(math)
+	document.Append(paragraph)
+	writer = &Writer{}
+	if _, err := writer.Write(document); err != nil {
+		t.Fatal(err)
+	}
+	expected := `<p>Inline <span class="math">\frac{x</span></p>
+<div class="system-message">
+<p class="system-message-title">System Message: ERROR/3 (<span class="docutils literal">test.rst</span>)</p>
+Unbalanced braces</div>
+`
+	if body := writer.Parts()["body"]; body != expected {
+		t.Error("math error failed:\n" + body)
+	}
+}
+
+func TestRaw(t *testing.T) {
+	settings := &rst.Settings{}
+	settings.Init()
+	settings.RawEnabled = true
+	document := rst.NewDocument("test.rst", settings)
+	for _, format := range []string{"html", "latex", "xml html"} {
+		raw := &rst.Element{}
+		raw.Init("raw", "", "<"+format+">")
+		raw.Set("format", format)
+		document.Append(raw)
+	}
+
+	writer := &Writer{}
+	if _, err := writer.Write(document); err != nil {
+		t.Fatal(err)
+	}
+	if body := writer.Parts()["body"]; body != "<html><xml html>" {
+		t.Error("raw formats not filtered: " + body)
+	}
+
+	// raw content is dropped unless enabled, whatever the parser
+	settings = &rst.Settings{}
+	settings.Init()
+	document = rst.NewDocument("test.xml", settings)
+	input := `<document><paragraph>Text</paragraph><raw format="html">&lt;script&gt;alert(1)&lt;/script&gt;</raw></document>`
+	if err := (&docutilsxml.Parser{}).Parse(input, document); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := writer.Write(document); err != nil {
+		t.Fatal(err)
+	}
+	if body := writer.Parts()["body"]; body != "<p>Text</p>\n" {
+		t.Error("raw content not dropped: " + body)
+	}
+}
+
+func TestDocinfo(t *testing.T) {
+	settings := &rst.Settings{}
+	settings.Init()
+	document := rst.NewDocument("test.rst", settings)
+	paragraph := &rst.Element{}
+	paragraph.Init("paragraph", "", "A. Name")
+	fieldName := &rst.Element{}
+	fieldName.Init("field_name", "", "Author")
+	fieldBody := &rst.Element{}
+	fieldBody.Init("field_body", "", "", paragraph)
+	field := &rst.Element{}
+	field.Init("field", "", "", fieldName, fieldBody)
+	fieldList := &rst.Element{}
+	fieldList.Init("field_list", "", "", field)
+	title := &rst.Element{}
+	title.Init("title", "", "Document Title")
+	section := &rst.Element{}
+	section.Init("section", "", "", title, fieldList)
+	document.Append(section)
+
+	transformer := &transforms.Transformer{}
+	transformer.Init(document)
+	transformer.AddTransforms(transforms.FrontmatterTransforms())
+	if err := transformer.ApplyTransforms(); err != nil {
+		t.Fatal(err)
+	}
+	writer := &Writer{}
+	if _, err := writer.Write(document); err != nil {
+		t.Fatal(err)
+	}
+	parts := writer.Parts()
+	if parts["title"] != "Document Title" {
+		t.Error("wrong title: " + parts["title"])
+	}
+	if !strings.Contains(parts["head"], `<meta name="author" content="A. Name" />`) {
+		t.Error("missing author metadata: " + parts["head"])
+	}
+	if !strings.Contains(parts["docinfo"], `<dl class="docinfo simple">`) {
+		t.Error("missing docinfo: " + parts["docinfo"])
+	}
+}
+
+const templateInput = `<document source="test.rst">
+    <title>The &lt;Title&gt;</title>
+    <topic classes="contents" ids="contents">
+        <title>Contents</title>
+        <bullet_list><list_item><paragraph><reference refid="intro">Intro</reference></paragraph></list_item></bullet_list>
+    </topic>
+    <section ids="intro">
+        <title>Intro</title>
+        <paragraph>Text &amp; more.</paragraph>
+    </section>
+</document>`
+
+func TestTemplate(t *testing.T) {
+	settings := &rst.Settings{}
+	settings.Init()
+	settings.Stylesheet = "site.css, print.css"
+	settings.LanguageCode = "fr"
+	writer := &Writer{}
+	writer.Template = template.Must(template.New("page").Parse(
+		`<html lang="{{.Language}}"><h1>{{.Title}}</h1>{{range .Stylesheets}}[{{.}}]{{end}}` +
+			`<aside>{{.TOC}}</aside><article>{{.Body}}</article></html>`))
+	document, err := docutilsxml.ParseDocument(templateInput, "", settings)
+	if err != nil {
+		t.Fatal(err)
+	}
+	output, err := writer.Write(document)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := `<html lang="fr"><h1>The &lt;Title&gt;</h1>[site.css][print.css]` +
+		`<aside><nav class="contents topic" id="contents">
+<p class="topic-title">Contents</p>
+<ul class="simple">
+<li><a class="reference internal" href="#intro">Intro</a></li>
+</ul>
+</nav>
+</aside><article><section id="intro">
+<h2>Intro</h2>
+<p>Text &amp; more.</p>
+</section>
+</article></html>`
+	if output != expected {
+		t.Errorf("unexpected output:\n%s", output)
+	}
+	parts := writer.Parts()
+	if parts["whole"] != output || !strings.Contains(parts["body"], parts["toc"]) || parts["toc"] == "" {
+		t.Error("wrong parts")
+	}
+	if parts["stylesheet"] != "<link rel=\"stylesheet\" href=\"site.css\" type=\"text/css\" />\n"+
+		"<link rel=\"stylesheet\" href=\"print.css\" type=\"text/css\" />\n" {
+		t.Error("wrong stylesheet part: " + parts["stylesheet"])
+	}
+
+	// two tables of contents, the second one in a section
+	topic := templateInput[strings.Index(templateInput, "<topic"):strings.Index(templateInput, "<section")]
+	twice := strings.Replace(templateInput, "<paragraph>Text", strings.Replace(topic, `ids="contents"`, `ids="local"`, 1)+"<paragraph>Text", 1)
+	if document, err = docutilsxml.ParseDocument(twice, "", settings); err != nil {
+		t.Fatal(err)
+	}
+	writer.Template = template.Must(template.New("page").Parse(`<aside>{{.TOC}}</aside><article>{{.Body}}</article>`))
+	if _, err := writer.Write(document); err != nil {
+		t.Fatal(err)
+	}
+	data := writer.TemplateData()
+	if strings.Count(string(data.TOC), "<nav") != 2 || strings.Contains(string(data.Body), "<nav") ||
+		!strings.Contains(string(data.Body), "<p>Text &amp; more.</p>") {
+		t.Errorf("wrong tables of contents:\n%s\n%s", data.TOC, data.Body)
+	}
+
+	// template file of the settings
+	dir, err := ioutil.TempDir("", "html")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	settings.Template = filepath.Join(dir, "page.html")
+	if err := ioutil.WriteFile(settings.Template, []byte("<title>{{.Title}}</title>"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if output, err := (&Writer{}).Write(document); err != nil || output != "<title>The &lt;Title&gt;</title>" {
+		t.Errorf("template file not used: %q %v", output, err)
+	}
+	settings.Template = filepath.Join(dir, "missing.html")
+	if _, err := (&Writer{}).Write(document); err == nil {
+		t.Error("missing template file not reported")
+	}
+}
+
+// Malformed trees, e.g. parsed from Docutils XML, are written without panics.
+func TestMalformed(t *testing.T) {
+	settings := &rst.Settings{}
+	settings.Init()
+	settings.WarningStream = nil
+	input := `<document><definition_list><definition_list_item/></definition_list></document>`
+	document, err := docutilsxml.ParseDocument(input, "test.xml", settings)
+	if err != nil {
+		t.Fatal(err)
+	}
+	writer := &Writer{}
+	if _, err := writer.Write(document); err != nil {
+		t.Fatal(err)
+	}
+	if body := writer.Parts()["body"]; body != "<dl class=\"simple\">\n</dl>\n" {
+		t.Error("unexpected body:\n" + body)
+	}
+}
@@ -0,0 +1,1648 @@
+/*
+Package latex implements the LaTeX2e writer of Python docutils (latex2e),
+producing a complete LaTeX document.
+
+URL of Python source code:
+http://sourceforge.net/p/docutils/code/HEAD/tree/trunk/docutils/docutils/writers/latex2e/__init__.py
+http://sourceforge.net/p/docutils/code/HEAD/tree/trunk/docutils/docutils/writers/latex2e/default.tex
+*/
+package latex
+
+import (
+	"fmt"
+	"io/ioutil"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	rst "github.com/siongui/go-rst"
+	"github.com/siongui/go-rst/writers"
+)
+
+// Formats this writer supports.
+var supported = []string{"latex", "latex2e"}
+
+const generator = "% generated by go-rst <https://github.com/siongui/go-rst>\n"
+
+// The default template of the "whole" output.
+const template = `$head_prefix$generator$requirements
+%%% Custom LaTeX preamble
+$latex_preamble
+%%% Fallback definitions for Docutils-specific commands
+$fallbacks
+$pdfsetup
+$titledata
+%%% Body
+\begin{document}
+$body_pre_docinfo$docinfo$dedication$abstract$body
+\end{document}
+`
+
+/*
+   The LaTeX writer. The translated document is available from `Parts()`
+   in pieces:
+
+   - "whole": the complete LaTeX document, the template (see the
+     `LatexTemplate` setting) filled with the other parts.
+   - "head_prefix": the \documentclass line.
+   - "requirements", "latex_preamble", "fallbacks", "pdfsetup": the
+     preamble: required packages, custom preamble, definitions of the
+     Docutils-specific commands used in the body, hyperref setup.
+   - "titledata", "body_pre_docinfo": \title{} and \maketitle.
+   - "docinfo", "dedication", "abstract", "body": the document body.
+   - "title", "subtitle": the document title and subtitle.
+*/
+type Writer struct {
+	writers.Base
+
+	translator *LaTeXTranslator
+}
+
+func (w *Writer) Supports(format string) bool {
+	for _, f := range supported {
+		if f == format {
+			return true
+		}
+	}
+	return false
+}
+
+func (w *Writer) Write(document *rst.Document) (string, error) {
+	w.Document = document
+	w.translator = &LaTeXTranslator{}
+	w.translator.Init(document)
+	if err := rst.Walkabout(document, w.translator); err != nil {
+		return "", err
+	}
+	tmpl := template
+	if path := document.Settings().LatexTemplate; path != "" {
+		data, err := ioutil.ReadFile(path)
+		if err != nil {
+			return "", err
+		}
+		tmpl = string(data)
+	}
+	w.Output = applyTemplate(tmpl, w.templateVars())
+	w.AssembleParts()
+	return w.Output, nil
+}
+
+var templateVariable = regexp.MustCompile(`\$(?:\$|(\w+)|\{(\w+)\})`)
+
+/*
+   Substitute the variables of `tmpl`, as Python's string.Template does:
+   "$name" or "${name}" are replaced by the value of `vars`, "$$" by "$".
+*/
+func applyTemplate(tmpl string, vars map[string]string) string {
+	return templateVariable.ReplaceAllStringFunc(tmpl, func(m string) string {
+		if m == "$$" {
+			return "$"
+		}
+		return vars[strings.Trim(m[1:], "{}")]
+	})
+}
+
+func (w *Writer) templateVars() map[string]string {
+	vars := map[string]string{}
+	for name, part := range w.translator.parts() {
+		vars[name] = strings.Join(part, "")
+	}
+	vars["generator"] = generator
+	return vars
+}
+
+func (w *Writer) AssembleParts() {
+	w.Base.AssembleParts()
+	for name, value := range w.templateVars() {
+		w.SetPart(name, value)
+	}
+}
+
+// Definitions of the Docutils-specific LaTeX commands, by name.
+var fallbackDefinitions = map[string]string{
+	"DUadmonition": `% admonition (specially marked topic)
+\providecommand{\DUadmonition}[2][class-arg]{%
+  % try \DUadmonition#1{#2}:
+  \ifcsname DUadmonition#1\endcsname%
+    \csname DUadmonition#1\endcsname{#2}%
+  \else
+    \begin{center}
+      \fbox{\parbox{0.9\linewidth}{#2}}
+    \end{center}
+  \fi
+}
+`,
+	"DUfieldlist": `% field list environment
+\ifx\DUfieldlist\undefined
+  \newenvironment{DUfieldlist}%
+    {\quote\description}
+    {\enddescription\endquote}
+\fi
+`,
+	"DUfootnote": `% numbered or symbolic footnote with hyperlink
+\providecommand*{\DUfootnotemark}[3]{%
+  \raisebox{1em}{\hypertarget{#1}{}}%
+  \hyperlink{#2}{\textsuperscript{#3}}%
+}
+\providecommand{\DUfootnotetext}[4]{%
+  \begingroup%
+  \renewcommand{\thefootnote}{%
+    \protect\raisebox{1em}{\protect\hypertarget{#1}{}}%
+    \protect\hyperlink{#2}{#3}}%
+  \footnotetext{#4}%
+  \endgroup%
+}
+`,
+	"DUlineblock": `% line block environment
+\ifx\DUlineblock\undefined
+  \newenvironment{DUlineblock}[1]{%
+    \list{}{\setlength{\partopsep}{\parskip}
+            \addtolength{\partopsep}{\baselineskip}
+            \setlength{\topsep}{0pt}
+            \setlength{\itemsep}{0.15\baselineskip}
+            \setlength{\parsep}{0pt}
+            \setlength{\leftmargin}{#1}}
+    \raggedright
+  }
+  {\endlist}
+\fi
+`,
+	"DUoptionlist": `% option list environment
+\ifx\DUoptionlist\undefined
+  \newcommand{\DUoptionlistlabel}[1]{\bfseries #1 \hfill}
+  \newenvironment{DUoptionlist}{%
+    \list{}{\setlength{\labelwidth}{3cm}
+            \setlength{\rightmargin}{1cm}
+            \setlength{\leftmargin}{\rightmargin}
+            \addtolength{\leftmargin}{\labelwidth}
+            \addtolength{\leftmargin}{\labelsep}
+            \renewcommand{\makelabel}{\DUoptionlistlabel}}
+  }
+  {\endlist}
+\fi
+`,
+	"DUrole": `% custom inline roles: \DUrole{#1}{#2} tries \DUrole#1{#2}
+\providecommand*{\DUrole}[2]{%
+  \ifcsname DUrole#1\endcsname%
+    \csname DUrole#1\endcsname{#2}%
+  \else%
+    #2%
+  \fi%
+}
+`,
+	"DUroletitlereference": `% title reference role
+\providecommand*{\DUroletitlereference}[1]{\textsl{#1}}
+`,
+	"DUrubric": `% rubric (informal heading)
+\providecommand*{\DUrubric}[1]{%
+  \subsubsection*{\centering\textit{\textmd{#1}}}}
+`,
+	"DUsidebar": `% sidebar (text outside the main text flow)
+\providecommand{\DUsidebar}[1]{%
+  \begin{center}
+    \fbox{\parbox{0.9\linewidth}{#1}}
+  \end{center}
+}
+`,
+	"DUtitle": `% title for topics, admonitions, unsupported section levels, and sidebar
+\providecommand*{\DUtitle}[2][class-arg]{%
+  % call \DUtitle#1{#2} if it exists:
+  \ifcsname DUtitle#1\endcsname%
+    \csname DUtitle#1\endcsname{#2}%
+  \else
+    \smallskip\noindent\textbf{#2}\smallskip%
+  \fi
+}
+`,
+	"DUtopic": `% topic (quote with heading)
+\providecommand{\DUtopic}[2][class-arg]{%
+  \ifcsname DUtopic#1\endcsname%
+    \csname DUtopic#1\endcsname{#2}%
+  \else
+    \begin{quote}#2\end{quote}
+  \fi
+}
+`,
+	"DUtransition": `% transition (break, fancybreak, anonymous section)
+\providecommand*{\DUtransition}{%
+  \hspace*{\fill}\hrulefill\hspace*{\fill}
+  \vskip 0.5\baselineskip
+}
+`,
+}
+
+// Packages (and their setup), in the order they are required.
+var requirementOrder = []string{"alltt", "amsmath", "graphicx", "listings", "longtable", "multirow", "tablewidth"}
+
+var requirementDefinitions = map[string]string{
+	"alltt":      "\\usepackage{alltt}\n",
+	"amsmath":    "\\usepackage{amsmath}\n",
+	"graphicx":   "\\usepackage{graphicx}\n",
+	"listings":   "\\usepackage{listings}\n",
+	"longtable":  "\\usepackage{longtable,ltcaption,array}\n",
+	"multirow":   "\\usepackage{multirow}\n",
+	"tablewidth": "\\newlength{\\DUtablewidth} % internal use in tables\n",
+}
+
+// Sectioning commands of the document classes.
+var sectionCommands = []string{"section", "subsection", "subsubsection", "paragraph", "subparagraph"}
+
+// Document classes with chapters.
+var chapterClasses = []string{"book", "memoir", "report", "scrbook", "scrreprt"}
+
+// LaTeX counters of nested enumerated lists.
+var enumCounters = []string{"enumi", "enumii", "enumiii", "enumiv"}
+
+var enumStyles = map[string]string{
+	"arabic":     "arabic",
+	"loweralpha": "alph",
+	"upperalpha": "Alph",
+	"lowerroman": "roman",
+	"upperroman": "Roman",
+}
+
+// State of the table being translated.
+type table struct {
+	env      string
+	caption  string
+	colwidth []int
+	// Remaining rows (the current one included) spanned by an entry, per column.
+	rowspans []int
+	column   int
+	inHead   bool
+	started  bool
+}
+
+/*
+   Generic Docutils to LaTeX translator. Visit and depart methods are
+   called by `rst.Walkabout()`; they append to the current output part
+   (`out`), normally the body.
+*/
+type LaTeXTranslator struct {
+	document *rst.Document
+	settings *rst.Settings
+	language *rst.Language
+
+	headPrefix     []string
+	requirements   []string
+	latexPreamble  []string
+	fallbacks      []string
+	pdfsetup       []string
+	titledata      []string
+	bodyPreDocinfo []string
+	docinfo        []string
+	dedication     []string
+	abstract       []string
+	body           []string
+	title          []string
+	subtitle       []string
+
+	// The part visit methods append to.
+	out *[]string
+
+	// Stack of closing strings or saved states, pushed in visit methods and
+	// popped in the corresponding depart methods.
+	context []interface{}
+
+	// Names of the packages and fallback definitions in use.
+	required map[string]bool
+	used     map[string]bool
+
+	sections     []string
+	sectionLevel int
+	enumLevel    int
+	tables       []*table
+	inDocinfo    bool
+}
+
+func (t *LaTeXTranslator) Init(document *rst.Document) {
+	t.document = document
+	t.settings = document.Settings()
+	t.language = rst.GetLanguage(t.settings.LanguageCode)
+	t.out = &t.body
+	t.required = map[string]bool{}
+	t.used = map[string]bool{}
+	t.sections = sectionCommands
+	for _, class := range chapterClasses {
+		if t.settings.DocumentClass == class {
+			t.sections = append([]string{"chapter"}, sectionCommands...)
+		}
+	}
+}
+
+func (t *LaTeXTranslator) parts() map[string][]string {
+	return map[string][]string{
+		"head_prefix":      t.headPrefix,
+		"requirements":     t.requirements,
+		"latex_preamble":   t.latexPreamble,
+		"fallbacks":        t.fallbacks,
+		"pdfsetup":         t.pdfsetup,
+		"titledata":        t.titledata,
+		"body_pre_docinfo": t.bodyPreDocinfo,
+		"docinfo":          t.docinfo,
+		"dedication":       t.dedication,
+		"abstract":         t.abstract,
+		"body":             t.body,
+		"title":            t.title,
+		"subtitle":         t.subtitle,
+	}
+}
+
+func (t *LaTeXTranslator) UnknownVisit(node rst.Node) error {
+	return &NotImplementedError{fmt.Sprintf("visiting unknown node type: %s", node.TagName())}
+}
+
+func (t *LaTeXTranslator) UnknownDeparture(node rst.Node) error {
+	return &NotImplementedError{fmt.Sprintf("departing unknown node type: %s", node.TagName())}
+}
+
+func (t *LaTeXTranslator) write(strs ...string) {
+	*t.out = append(*t.out, strs...)
+}
+
+func (t *LaTeXTranslator) push(value interface{}) {
+	t.context = append(t.context, value)
+}
+
+func (t *LaTeXTranslator) pop() interface{} {
+	value := t.context[len(t.context)-1]
+	t.context = t.context[:len(t.context)-1]
+	return value
+}
+
+// Write the string popped from the context stack.
+func (t *LaTeXTranslator) writePopped() {
+	t.write(t.pop().(string))
+}
+
+// Note the use of the Docutils-specific command (or environment) `name`.
+func (t *LaTeXTranslator) fallback(name string) string {
+	t.used[name] = true
+	return name
+}
+
+var specialCharacters = strings.NewReplacer(
+	"\\", `\textbackslash{}`,
+	"{", `\{`,
+	"}", `\}`,
+	"$", `\$`,
+	"&", `\&`,
+	"%", `\%`,
+	"#", `\#`,
+	"_", `\_`,
+	"~", `\textasciitilde{}`,
+	"^", `\textasciicircum{}`,
+	// protect brackets from being read as optional arguments
+	"[", "{[}",
+	"]", "{]}",
+	"\"", `\textquotedbl{}`,
+	"<", `\textless{}`,
+	">", `\textgreater{}`,
+	"|", `\textbar{}`,
+	"\u00a0", "~",
+)
+
+// Encode special characters in `text` & return.
+func Encode(text string) string {
+	return specialCharacters.Replace(text)
+}
+
+var uriSpecialCharacters = strings.NewReplacer(
+	"\\", `\\`,
+	"%", `\%`,
+	"#", `\#`,
+	"{", `\{`,
+	"}", `\}`,
+)
+
+// Encode `uri` for the argument of \href or \url.
+func EncodeURI(uri string) string {
+	return uriSpecialCharacters.Replace(uri)
+}
+
+// Return \label commands for the ids of `node`.
+func labels(node *rst.Element) string {
+	var result string
+	for _, id := range node.Ids {
+		result += `\label{` + id + "}%\n"
+	}
+	return result
+}
+
+// Return a hyperlink anchor with the labels of `node`, "" if it has no ids.
+func anchor(node *rst.Element) string {
+	if len(node.Ids) == 0 {
+		return ""
+	}
+	return "\\phantomsection" + labels(node)
+}
+
+// Return the classes of `node` for the optional argument of DU commands.
+func classArg(node *rst.Element, fallback string) string {
+	if len(node.Classes) > 0 {
+		return node.Classes[0]
+	}
+	return fallback
+}
+
+// Is `node` the first child of its parent (not counting its label)?
+func isFirstChild(node *rst.Element) bool {
+	parent := node.Parent()
+	index := parent.Index(node)
+	return index == 0 || index == 1 && parent.Children()[0].TagName() == "label"
+}
+
+func (t *LaTeXTranslator) VisitText(node *rst.Text) {
+	text := Encode(node.AsText())
+	if t.inDocinfo && node.Parent().TagName() == "address" {
+		text = strings.Replace(text, "\n", "\\newline\n", -1)
+	}
+	t.write(text)
+}
+
+func (t *LaTeXTranslator) DepartText(node *rst.Text) {}
+
+func (t *LaTeXTranslator) VisitAbbreviation(node *rst.Element)  {}
+func (t *LaTeXTranslator) DepartAbbreviation(node *rst.Element) {}
+func (t *LaTeXTranslator) VisitAcronym(node *rst.Element)       {}
+func (t *LaTeXTranslator) DepartAcronym(node *rst.Element)      {}
+
+func (t *LaTeXTranslator) VisitAddress(node *rst.Element) {
+	t.visitDocinfoItem(node, "address")
+}
+
+func (t *LaTeXTranslator) DepartAddress(node *rst.Element) {
+	t.departDocinfoItem()
+}
+
+func (t *LaTeXTranslator) VisitAdmonition(node *rst.Element) {
+	t.write("\n", anchor(node), `\`, t.fallback("DUadmonition"), "[", classArg(node, "admonition"), "]{\n")
+}
+
+func (t *LaTeXTranslator) DepartAdmonition(node *rst.Element) {
+	t.write("}\n")
+}
+
+// Specific admonitions get a title from the language module.
+func (t *LaTeXTranslator) visitSpecificAdmonition(node *rst.Element, name string) {
+	t.write("\n", anchor(node), `\`, t.fallback("DUadmonition"), "[", name, "]{\n")
+	t.write(`\`, t.fallback("DUtitle"), "[", name, "]{", Encode(t.language.Labels[name]), "}\n\n")
+}
+
+func (t *LaTeXTranslator) VisitAttention(node *rst.Element) {
+	t.visitSpecificAdmonition(node, "attention")
+}
+
+func (t *LaTeXTranslator) DepartAttention(node *rst.Element) {
+	t.DepartAdmonition(node)
+}
+
+func (t *LaTeXTranslator) VisitCaution(node *rst.Element) {
+	t.visitSpecificAdmonition(node, "caution")
+}
+
+func (t *LaTeXTranslator) DepartCaution(node *rst.Element) {
+	t.DepartAdmonition(node)
+}
+
+func (t *LaTeXTranslator) VisitDanger(node *rst.Element) {
+	t.visitSpecificAdmonition(node, "danger")
+}
+
+func (t *LaTeXTranslator) DepartDanger(node *rst.Element) {
+	t.DepartAdmonition(node)
+}
+
+func (t *LaTeXTranslator) VisitError(node *rst.Element) {
+	t.visitSpecificAdmonition(node, "error")
+}
+
+func (t *LaTeXTranslator) DepartError(node *rst.Element) {
+	t.DepartAdmonition(node)
+}
+
+func (t *LaTeXTranslator) VisitHint(node *rst.Element) {
+	t.visitSpecificAdmonition(node, "hint")
+}
+
+func (t *LaTeXTranslator) DepartHint(node *rst.Element) {
+	t.DepartAdmonition(node)
+}
+
+func (t *LaTeXTranslator) VisitImportant(node *rst.Element) {
+	t.visitSpecificAdmonition(node, "important")
+}
+
+func (t *LaTeXTranslator) DepartImportant(node *rst.Element) {
+	t.DepartAdmonition(node)
+}
+
+func (t *LaTeXTranslator) VisitNote(node *rst.Element) {
+	t.visitSpecificAdmonition(node, "note")
+}
+
+func (t *LaTeXTranslator) DepartNote(node *rst.Element) {
+	t.DepartAdmonition(node)
+}
+
+func (t *LaTeXTranslator) VisitTip(node *rst.Element) {
+	t.visitSpecificAdmonition(node, "tip")
+}
+
+func (t *LaTeXTranslator) DepartTip(node *rst.Element) {
+	t.DepartAdmonition(node)
+}
+
+func (t *LaTeXTranslator) VisitWarning(node *rst.Element) {
+	t.visitSpecificAdmonition(node, "warning")
+}
+
+func (t *LaTeXTranslator) DepartWarning(node *rst.Element) {
+	t.DepartAdmonition(node)
+}
+
+func (t *LaTeXTranslator) VisitAttribution(node *rst.Element) {
+	t.write("\n\\nopagebreak\n\n\\raggedleft ---{}")
+}
+
+func (t *LaTeXTranslator) DepartAttribution(node *rst.Element) {
+	t.write("\n")
+}
+
+func (t *LaTeXTranslator) VisitAuthor(node *rst.Element) {
+	if node.Parent().TagName() == "authors" {
+		if node.Parent().Index(node) > 0 {
+			t.write(", ")
+		}
+		return
+	}
+	t.visitDocinfoItem(node, "author")
+}
+
+func (t *LaTeXTranslator) DepartAuthor(node *rst.Element) {
+	if node.Parent().TagName() != "authors" {
+		t.departDocinfoItem()
+	}
+}
+
+func (t *LaTeXTranslator) VisitAuthors(node *rst.Element) {
+	t.visitDocinfoItem(node, "authors")
+}
+
+func (t *LaTeXTranslator) DepartAuthors(node *rst.Element) {
+	t.departDocinfoItem()
+}
+
+func (t *LaTeXTranslator) VisitBlockQuote(node *rst.Element) {
+	t.write("\n", anchor(node), "\\begin{quote}\n")
+}
+
+func (t *LaTeXTranslator) DepartBlockQuote(node *rst.Element) {
+	t.write("\\end{quote}\n")
+}
+
+func (t *LaTeXTranslator) VisitBulletList(node *rst.Element) {
+	t.write("\n", anchor(node), "\\begin{itemize}\n")
+}
+
+func (t *LaTeXTranslator) DepartBulletList(node *rst.Element) {
+	t.write("\\end{itemize}\n")
+}
+
+func (t *LaTeXTranslator) VisitCaption(node *rst.Element) {
+	t.write("\\caption{")
+}
+
+func (t *LaTeXTranslator) DepartCaption(node *rst.Element) {
+	t.write("}\n", labels(node.Parent()))
+}
+
+func (t *LaTeXTranslator) VisitCitation(node *rst.Element) {
+	parent := node.Parent()
+	index := parent.Index(node)
+	// consecutive citations form a bibliography
+	if index == 0 || parent.Children()[index-1].TagName() != "citation" {
+		widest := ""
+		for _, sibling := range parent.Children()[index:] {
+			if sibling.TagName() != "citation" {
+				break
+			}
+			if label := citationLabel(sibling.(*rst.Element)); len(label) > len(widest) {
+				widest = label
+			}
+		}
+		t.write("\n\\begin{thebibliography}{", Encode(widest), "}\n")
+	}
+	label := citationLabel(node)
+	t.write(`\bibitem[`, Encode(label), "]{", label, "}\n")
+}
+
+func (t *LaTeXTranslator) DepartCitation(node *rst.Element) {
+	parent := node.Parent()
+	index := parent.Index(node)
+	if index+1 == parent.Len() || parent.Children()[index+1].TagName() != "citation" {
+		t.write("\\end{thebibliography}\n")
+	}
+}
+
+// Return the label of a footnote or citation.
+func citationLabel(node *rst.Element) string {
+	if node.Len() > 0 && node.Children()[0].TagName() == "label" {
+		return node.Children()[0].AsText()
+	}
+	return ""
+}
+
+func (t *LaTeXTranslator) VisitCitationReference(node *rst.Element) error {
+	t.write(`\cite{`, node.AsText(), "}")
+	return &rst.SkipNode{}
+}
+
+func (t *LaTeXTranslator) VisitClassifier(node *rst.Element) {
+	t.write(" \\textit{(")
+}
+
+func (t *LaTeXTranslator) DepartClassifier(node *rst.Element) {
+	t.write(")}")
+}
+
+func (t *LaTeXTranslator) VisitColspec(node *rst.Element) error {
+	colwidth, _ := strconv.Atoi(node.Get("colwidth"))
+	tbl := t.tables[len(t.tables)-1]
+	tbl.colwidth = append(tbl.colwidth, colwidth)
+	tbl.rowspans = append(tbl.rowspans, 0)
+	return &rst.SkipNode{}
+}
+
+func (t *LaTeXTranslator) VisitComment(node *rst.Element) error {
+	t.write("\n")
+	for _, line := range strings.Split(node.AsText(), "\n") {
+		t.write("% ", line, "\n")
+	}
+	return &rst.SkipNode{}
+}
+
+func (t *LaTeXTranslator) VisitCompound(node *rst.Element)  {}
+func (t *LaTeXTranslator) DepartCompound(node *rst.Element) {}
+
+func (t *LaTeXTranslator) VisitContact(node *rst.Element) {
+	t.visitDocinfoItem(node, "contact")
+}
+
+func (t *LaTeXTranslator) DepartContact(node *rst.Element) {
+	t.departDocinfoItem()
+}
+
+func (t *LaTeXTranslator) VisitContainer(node *rst.Element) {
+	t.write(anchor(node))
+}
+
+func (t *LaTeXTranslator) DepartContainer(node *rst.Element) {}
+
+func (t *LaTeXTranslator) VisitCopyright(node *rst.Element) {
+	t.visitDocinfoItem(node, "copyright")
+}
+
+func (t *LaTeXTranslator) DepartCopyright(node *rst.Element) {
+	t.departDocinfoItem()
+}
+
+func (t *LaTeXTranslator) VisitDate(node *rst.Element) {
+	t.visitDocinfoItem(node, "date")
+}
+
+func (t *LaTeXTranslator) DepartDate(node *rst.Element) {
+	t.departDocinfoItem()
+}
+
+// Header and footer are not supported.
+func (t *LaTeXTranslator) VisitDecoration(node *rst.Element) error {
+	return &rst.SkipNode{}
+}
+
+func (t *LaTeXTranslator) VisitDefinition(node *rst.Element) {
+	t.write("}]\n")
+}
+
+func (t *LaTeXTranslator) DepartDefinition(node *rst.Element) {
+	t.write("\n")
+}
+
+func (t *LaTeXTranslator) VisitDefinitionList(node *rst.Element) {
+	t.write("\n", anchor(node), "\\begin{description}\n")
+}
+
+func (t *LaTeXTranslator) DepartDefinitionList(node *rst.Element) {
+	t.write("\\end{description}\n")
+}
+
+func (t *LaTeXTranslator) VisitDefinitionListItem(node *rst.Element)  {}
+func (t *LaTeXTranslator) DepartDefinitionListItem(node *rst.Element) {}
+
+func (t *LaTeXTranslator) VisitDescription(node *rst.Element) {
+	t.write(" ")
+}
+
+func (t *LaTeXTranslator) DepartDescription(node *rst.Element) {
+	t.write("\n")
+}
+
+func (t *LaTeXTranslator) VisitDocinfo(node *rst.Element) {
+	t.out = &t.docinfo
+	t.inDocinfo = true
+	t.write("\n% Docinfo\n\\begin{center}\n\\begin{tabular}{lp{0.6\\linewidth}}\n")
+}
+
+func (t *LaTeXTranslator) DepartDocinfo(node *rst.Element) {
+	t.write("\\end{tabular}\n\\end{center}\n")
+	t.inDocinfo = false
+	t.out = &t.body
+}
+
+func (t *LaTeXTranslator) visitDocinfoItem(node *rst.Element, name string) {
+	t.write(`\textbf{`, Encode(t.language.Labels[name]), "}: &\n\t")
+}
+
+func (t *LaTeXTranslator) departDocinfoItem() {
+	t.write(" \\\\\n")
+}
+
+func (t *LaTeXTranslator) VisitDoctestBlock(node *rst.Element) error {
+	return t.VisitLiteralBlock(node)
+}
+
+func (t *LaTeXTranslator) DepartDoctestBlock(node *rst.Element) {
+	t.DepartLiteralBlock(node)
+}
+
+func (t *LaTeXTranslator) VisitDocument(node *rst.Element) {}
+
+func (t *LaTeXTranslator) DepartDocument(node *rst.Element) {
+	var options []string
+	if t.settings.PaperSize != "" {
+		options = append(options, t.settings.PaperSize)
+	}
+	if t.settings.DocumentOptions != "" {
+		options = append(options, t.settings.DocumentOptions)
+	}
+	class := t.settings.DocumentClass
+	if class == "" {
+		class = "article"
+	}
+	if len(options) > 0 {
+		t.headPrefix = append(t.headPrefix, "\\documentclass["+strings.Join(options, ",")+"]{"+class+"}\n")
+	} else {
+		t.headPrefix = append(t.headPrefix, "\\documentclass{"+class+"}\n")
+	}
+
+	t.requirements = append(t.requirements,
+		"\\usepackage[T1]{fontenc}\n",
+		"\\usepackage[utf8]{inputenc}\n",
+		"\\usepackage{cmap} % fix search and cut-and-paste in Acrobat\n",
+		"\\usepackage{ifthen}\n",
+		"\\setcounter{secnumdepth}{0}\n")
+	for _, name := range requirementOrder {
+		if t.required[name] {
+			t.requirements = append(t.requirements, requirementDefinitions[name])
+		}
+	}
+	if t.settings.LatexPreamble != "" {
+		t.latexPreamble = append(t.latexPreamble, t.settings.LatexPreamble, "\n")
+	}
+
+	var names []string
+	for name := range t.used {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		if name == "DUfootnotemark" || name == "DUfootnotetext" {
+			name = "DUfootnote"
+			if t.used["DUfootnote"] {
+				continue
+			}
+			t.used["DUfootnote"] = true
+		}
+		if definition, ok := fallbackDefinitions[name]; ok {
+			t.fallbacks = append(t.fallbacks, "\n", definition)
+		}
+	}
+
+	t.pdfsetup = append(t.pdfsetup,
+		"% hyperlinks:\n",
+		"\\ifthenelse{\\isundefined{\\hypersetup}}{\n",
+		"  \\usepackage[colorlinks=true,linkcolor=blue,urlcolor=blue]{hyperref}\n",
+		"  \\usepackage{bookmark}\n",
+		"  \\urlstyle{same} % normal text font (alternatives: tt, rm, sf)\n",
+		"}{}\n")
+	if title := node.Get("title"); title != "" {
+		t.pdfsetup = append(t.pdfsetup, "\\hypersetup{\n  pdftitle={"+Encode(title)+"},\n}\n")
+	}
+
+	if len(t.title) > 0 {
+		titledata := "\\title{" + strings.Join(t.title, "")
+		if len(t.subtitle) > 0 {
+			titledata += "\\\\\n  \\large{" + strings.Join(t.subtitle, "") + "}"
+		}
+		t.titledata = append(t.titledata, titledata+"}\n\\author{}\n\\date{}\n")
+		t.bodyPreDocinfo = append(t.bodyPreDocinfo, "\\maketitle\n")
+	}
+}
+
+func (t *LaTeXTranslator) VisitEmphasis(node *rst.Element) {
+	t.write(`\emph{`)
+}
+
+func (t *LaTeXTranslator) DepartEmphasis(node *rst.Element) {
+	t.write("}")
+}
+
+func (t *LaTeXTranslator) VisitEntry(node *rst.Element) {
+	tbl := t.tables[len(t.tables)-1]
+	// skip the columns spanned by entries of previous rows
+	t.skipSpannedColumns(tbl)
+	if tbl.column > 0 {
+		t.write(" & ")
+	}
+	morecols, _ := strconv.Atoi(node.Get("morecols"))
+	morerows, _ := strconv.Atoi(node.Get("morerows"))
+	closing := ""
+	if morecols > 0 {
+		width := 0
+		for _, w := range tbl.colwidth[tbl.column : tbl.column+morecols+1] {
+			width += w
+		}
+		bar := "|"
+		if tbl.column > 0 {
+			bar = ""
+		}
+		t.write(`\multicolumn{`, strconv.Itoa(morecols+1), "}{", bar, t.columnSpec(tbl, width), "|}{")
+		closing += "}"
+	}
+	if morerows > 0 {
+		t.required["multirow"] = true
+		t.write(`\multirow{`, strconv.Itoa(morerows+1), "}{*}{")
+		closing += "}"
+		// the spanned rows and this one
+		for i := tbl.column; i <= tbl.column+morecols; i++ {
+			tbl.rowspans[i] = morerows + 1
+		}
+	}
+	if tbl.inHead {
+		t.write(`\bfseries `)
+	}
+	tbl.column += morecols + 1
+	t.push(closing)
+}
+
+func (t *LaTeXTranslator) DepartEntry(node *rst.Element) {
+	t.writePopped()
+}
+
+// Write the empty entries of the columns spanned by entries of previous
+// rows, starting at the current column.
+func (t *LaTeXTranslator) skipSpannedColumns(tbl *table) {
+	for tbl.column < len(tbl.rowspans) && tbl.rowspans[tbl.column] > 0 {
+		if tbl.column > 0 {
+			t.write(" & ")
+		}
+		tbl.column++
+	}
+}
+
+func (t *LaTeXTranslator) VisitEnumeratedList(node *rst.Element) {
+	t.write("\n", anchor(node), "\\begin{enumerate}\n")
+	if t.enumLevel < len(enumCounters) {
+		counter := enumCounters[t.enumLevel]
+		style, ok := enumStyles[node.Get("enumtype")]
+		if !ok {
+			style = "arabic"
+		}
+		t.write(`\renewcommand{\label`, counter, "}{", Encode(node.Get("prefix")), `\`, style, "{", counter, "}",
+			Encode(node.Get("suffix")), "}\n")
+		if start, err := strconv.Atoi(node.Get("start")); err == nil && start != 1 {
+			t.write(`\setcounter{`, counter, "}{", strconv.Itoa(start-1), "}\n")
+		}
+	}
+	t.enumLevel++
+}
+
+func (t *LaTeXTranslator) DepartEnumeratedList(node *rst.Element) {
+	t.enumLevel--
+	t.write("\\end{enumerate}\n")
+}
+
+func (t *LaTeXTranslator) VisitField(node *rst.Element)  {}
+func (t *LaTeXTranslator) DepartField(node *rst.Element) {}
+
+func (t *LaTeXTranslator) VisitFieldBody(node *rst.Element) {
+	t.write(" ")
+}
+
+func (t *LaTeXTranslator) DepartFieldBody(node *rst.Element) {
+	if t.inDocinfo {
+		t.write(" \\\\\n")
+	} else {
+		t.write("\n")
+	}
+}
+
+func (t *LaTeXTranslator) VisitFieldList(node *rst.Element) {
+	t.write("\n", anchor(node), "\\begin{", t.fallback("DUfieldlist"), "}\n")
+}
+
+func (t *LaTeXTranslator) DepartFieldList(node *rst.Element) {
+	t.write("\\end{DUfieldlist}\n")
+}
+
+func (t *LaTeXTranslator) VisitFieldName(node *rst.Element) {
+	if t.inDocinfo {
+		t.write(`\textbf{`)
+	} else {
+		t.write(`\item[{`)
+	}
+}
+
+func (t *LaTeXTranslator) DepartFieldName(node *rst.Element) {
+	if t.inDocinfo {
+		t.write("}: &\n\t")
+	} else {
+		t.write(":}]")
+	}
+}
+
+func (t *LaTeXTranslator) VisitFigure(node *rst.Element) {
+	t.write("\n\\begin{figure}\n")
+	align := node.Get("align")
+	switch align {
+	case "left":
+		t.write("\\raggedright\n")
+	case "right":
+		t.write("\\raggedleft\n")
+	default:
+		t.write("\\centering\n")
+	}
+	hasCaption := false
+	for _, child := range node.Children() {
+		if child.TagName() == "caption" {
+			hasCaption = true
+		}
+	}
+	if !hasCaption {
+		t.write(anchor(node))
+	}
+}
+
+func (t *LaTeXTranslator) DepartFigure(node *rst.Element) {
+	t.write("\\end{figure}\n")
+}
+
+func (t *LaTeXTranslator) VisitFooter(node *rst.Element) error {
+	return &rst.SkipNode{}
+}
+
+func (t *LaTeXTranslator) VisitFootnote(node *rst.Element) {
+	id := ""
+	if len(node.Ids) > 0 {
+		id = node.Ids[0]
+	}
+	backref := id
+	if len(node.Backrefs) > 0 {
+		backref = node.Backrefs[0]
+	}
+	t.write("\n%\n\\", t.fallback("DUfootnotetext"), "{", id, "}{", backref, "}{",
+		Encode(citationLabel(node)), "}{%\n")
+}
+
+func (t *LaTeXTranslator) DepartFootnote(node *rst.Element) {
+	t.write("}\n")
+}
+
+func (t *LaTeXTranslator) VisitFootnoteReference(node *rst.Element) error {
+	id := ""
+	if len(node.Ids) > 0 {
+		id = node.Ids[0]
+	}
+	t.write(`\`, t.fallback("DUfootnotemark"), "{", id, "}{", node.Get("refid"), "}{", Encode(node.AsText()), "}")
+	return &rst.SkipNode{}
+}
+
+func (t *LaTeXTranslator) VisitGenerated(node *rst.Element)  {}
+func (t *LaTeXTranslator) DepartGenerated(node *rst.Element) {}
+
+func (t *LaTeXTranslator) VisitHeader(node *rst.Element) error {
+	return &rst.SkipNode{}
+}
+
+var lengthUnit = regexp.MustCompile(`^([0-9.]+)\s*(\S*)$`)
+
+/*
+   Convert a reStructuredText length to LaTeX: percentages are relative to
+   the line width, pixels are converted to points (at 96 dpi).
+*/
+func toLatexLength(length string) string {
+	match := lengthUnit.FindStringSubmatch(length)
+	if match == nil {
+		return length
+	}
+	value, _ := strconv.ParseFloat(match[1], 64)
+	switch match[2] {
+	case "%":
+		return strconv.FormatFloat(value/100, 'f', 3, 64) + `\linewidth`
+	case "px":
+		return strconv.FormatFloat(value*0.75, 'f', -1, 64) + "pt"
+	case "":
+		return match[1] + "bp"
+	}
+	return length
+}
+
+func (t *LaTeXTranslator) VisitImage(node *rst.Element) error {
+	t.required["graphicx"] = true
+	var options []string
+	for _, name := range []string{"width", "height"} {
+		if node.HasAttr(name) {
+			options = append(options, name+"="+toLatexLength(node.Get(name)))
+		}
+	}
+	if scale, err := strconv.ParseFloat(node.Get("scale"), 64); err == nil {
+		options = append(options, "scale="+strconv.FormatFloat(scale/100, 'f', -1, 64))
+	}
+	graphic := `\includegraphics`
+	if len(options) > 0 {
+		graphic += "[" + strings.Join(options, ",") + "]"
+	}
+	graphic += "{" + node.Get("uri") + "}"
+
+	parent := node.Parent()
+	if parent.Is(rst.TextElementClass) || parent.TagName() == "figure" ||
+		parent.TagName() == "reference" && parent.Parent().Is(rst.TextElementClass) {
+		// inline image, or alignment by the figure
+		t.write(graphic)
+		return &rst.SkipNode{}
+	}
+	t.write("\n", anchor(node))
+	switch node.Get("align") {
+	case "left":
+		t.write("\\noindent{", graphic, "\\hfill}\n")
+	case "right":
+		t.write("\\noindent{\\hfill", graphic, "}\n")
+	default:
+		t.write("\\noindent\\makebox[\\linewidth][c]{", graphic, "}\n")
+	}
+	return &rst.SkipNode{}
+}
+
+func (t *LaTeXTranslator) VisitInline(node *rst.Element) {
+	closing := ""
+	for _, cls := range node.Classes {
+		t.write(`\`, t.fallback("DUrole"), "{", cls, "}{")
+		closing += "}"
+	}
+	t.push(closing)
+}
+
+func (t *LaTeXTranslator) DepartInline(node *rst.Element) {
+	t.writePopped()
+}
+
+// Labels are written by the footnote or citation.
+func (t *LaTeXTranslator) VisitLabel(node *rst.Element) error {
+	return &rst.SkipNode{}
+}
+
+func (t *LaTeXTranslator) VisitLegend(node *rst.Element) {
+	t.write("\n{\\small\n")
+}
+
+func (t *LaTeXTranslator) DepartLegend(node *rst.Element) {
+	t.write("}\n")
+}
+
+func (t *LaTeXTranslator) VisitLine(node *rst.Element) {
+	t.write(`\item[] `)
+	if node.Len() == 0 {
+		t.write("~")
+	}
+}
+
+func (t *LaTeXTranslator) DepartLine(node *rst.Element) {
+	t.write("\n")
+}
+
+func (t *LaTeXTranslator) VisitLineBlock(node *rst.Element) {
+	if node.Parent().TagName() == "line_block" {
+		t.write(`\item[]`, "\n\\begin{DUlineblock}{1.5em}\n")
+	} else {
+		t.write("\n", anchor(node), "\\begin{", t.fallback("DUlineblock"), "}{0em}\n")
+	}
+}
+
+func (t *LaTeXTranslator) DepartLineBlock(node *rst.Element) {
+	t.write("\\end{DUlineblock}\n")
+}
+
+func (t *LaTeXTranslator) VisitListItem(node *rst.Element) {
+	t.write(`\item `)
+}
+
+func (t *LaTeXTranslator) DepartListItem(node *rst.Element) {
+	t.write("\n")
+}
+
+func (t *LaTeXTranslator) VisitLiteral(node *rst.Element) {
+	t.write(`\texttt{`)
+}
+
+func (t *LaTeXTranslator) DepartLiteral(node *rst.Element) {
+	t.write("}")
+}
+
+/*
+   Literal blocks of text only use the `LiteralBlockEnv` setting
+   environment ("verbatim" or "lstlisting"), literal blocks with inline
+   markup (e.g. parsed literals or highlighted code) use "alltt".
+*/
+func (t *LaTeXTranslator) VisitLiteralBlock(node *rst.Element) error {
+	plain := true
+	for _, child := range node.Children() {
+		if _, ok := child.(*rst.Text); !ok {
+			plain = false
+		}
+	}
+	t.write("\n", anchor(node))
+	if !plain {
+		t.required["alltt"] = true
+		t.write("\\begin{quote}\n\\begin{alltt}\n")
+		t.push("\n\\end{alltt}\n\\end{quote}\n")
+		return nil
+	}
+	env := t.settings.LiteralBlockEnv
+	if env == "lstlisting" {
+		t.required["listings"] = true
+	} else {
+		env = "verbatim"
+	}
+	t.write("\\begin{quote}\n\\begin{", env, "}\n", node.AsText(), "\n\\end{", env, "}\n\\end{quote}\n")
+	return &rst.SkipNode{}
+}
+
+func (t *LaTeXTranslator) DepartLiteralBlock(node *rst.Element) {
+	t.writePopped()
+}
+
+func (t *LaTeXTranslator) VisitMath(node *rst.Element) error {
+	t.required["amsmath"] = true
+	t.write("$", node.AsText(), "$")
+	return &rst.SkipNode{}
+}
+
+func (t *LaTeXTranslator) VisitMathBlock(node *rst.Element) error {
+	t.required["amsmath"] = true
+	code := node.AsText()
+	env := rst.PickMathEnvironment(code, false)
+	t.write("\n", anchor(node), "\\begin{", env, "}\n", code, "\n\\end{", env, "}\n")
+	return &rst.SkipNode{}
+}
+
+func (t *LaTeXTranslator) VisitOption(node *rst.Element) {
+	if node.Parent().Index(node) > 0 {
+		t.write(", ")
+	}
+}
+
+func (t *LaTeXTranslator) DepartOption(node *rst.Element) {}
+
+func (t *LaTeXTranslator) VisitOptionArgument(node *rst.Element) {
+	t.write(Encode(node.Get("delimiter")))
+}
+
+func (t *LaTeXTranslator) DepartOptionArgument(node *rst.Element) {}
+
+func (t *LaTeXTranslator) VisitOptionGroup(node *rst.Element) {
+	t.write(`\item[{`)
+}
+
+func (t *LaTeXTranslator) DepartOptionGroup(node *rst.Element) {
+	t.write("}]")
+}
+
+func (t *LaTeXTranslator) VisitOptionList(node *rst.Element) {
+	t.write("\n", anchor(node), "\\begin{", t.fallback("DUoptionlist"), "}\n")
+}
+
+func (t *LaTeXTranslator) DepartOptionList(node *rst.Element) {
+	t.write("\\end{DUoptionlist}\n")
+}
+
+func (t *LaTeXTranslator) VisitOptionListItem(node *rst.Element)  {}
+func (t *LaTeXTranslator) DepartOptionListItem(node *rst.Element) {}
+func (t *LaTeXTranslator) VisitOptionString(node *rst.Element)    {}
+func (t *LaTeXTranslator) DepartOptionString(node *rst.Element)   {}
+
+func (t *LaTeXTranslator) VisitOrganization(node *rst.Element) {
+	t.visitDocinfoItem(node, "organization")
+}
+
+func (t *LaTeXTranslator) DepartOrganization(node *rst.Element) {
+	t.departDocinfoItem()
+}
+
+func (t *LaTeXTranslator) VisitParagraph(node *rst.Element) {
+	// paragraphs are separated by empty lines
+	if !isFirstChild(node) {
+		t.write("\n")
+	}
+	t.write(anchor(node))
+}
+
+func (t *LaTeXTranslator) DepartParagraph(node *rst.Element) {
+	t.write("\n")
+}
+
+func (t *LaTeXTranslator) VisitPending(node *rst.Element) error {
+	return &rst.SkipNode{}
+}
+
+func (t *LaTeXTranslator) VisitProblematic(node *rst.Element) {
+	t.write(`\hyperref[`, node.Get("refid"), `]{\textbf{`)
+}
+
+func (t *LaTeXTranslator) DepartProblematic(node *rst.Element) {
+	t.write("}}")
+}
+
+func (t *LaTeXTranslator) VisitRaw(node *rst.Element) error {
+	if writers.RawFormatMatches(node, "latex") {
+		t.write(node.AsText())
+	}
+	return &rst.SkipNode{}
+}
+
+func (t *LaTeXTranslator) VisitReference(node *rst.Element) {
+	switch {
+	case node.HasAttr("refuri"):
+		t.write(`\href{`, EncodeURI(node.Get("refuri")), "}{")
+		t.push("}")
+	case node.HasAttr("refid"):
+		t.write(`\hyperref[`, node.Get("refid"), "]{")
+		t.push("}")
+	default:
+		t.push("")
+	}
+}
+
+func (t *LaTeXTranslator) DepartReference(node *rst.Element) {
+	t.writePopped()
+}
+
+func (t *LaTeXTranslator) VisitRevision(node *rst.Element) {
+	t.visitDocinfoItem(node, "revision")
+}
+
+func (t *LaTeXTranslator) DepartRevision(node *rst.Element) {
+	t.departDocinfoItem()
+}
+
+func (t *LaTeXTranslator) VisitRow(node *rst.Element) {
+	tbl := t.tables[len(t.tables)-1]
+	tbl.column = 0
+}
+
+func (t *LaTeXTranslator) DepartRow(node *rst.Element) {
+	tbl := t.tables[len(t.tables)-1]
+	t.skipSpannedColumns(tbl)
+	t.write(" \\\\\n")
+	// rule below the row, except for the columns spanned into the next row
+	var segments []string
+	start := -1
+	for i := range tbl.rowspans {
+		if tbl.rowspans[i] > 0 {
+			tbl.rowspans[i]--
+		}
+		if tbl.rowspans[i] > 0 {
+			if start >= 0 {
+				segments = append(segments, fmt.Sprintf(`\cline{%d-%d}`, start+1, i))
+				start = -1
+			}
+			continue
+		}
+		if start < 0 {
+			start = i
+		}
+	}
+	if start == 0 {
+		t.write("\\hline\n")
+		return
+	}
+	if start > 0 {
+		segments = append(segments, fmt.Sprintf(`\cline{%d-%d}`, start+1, len(tbl.rowspans)))
+	}
+	t.write(strings.Join(segments, ""), "\n")
+}
+
+func (t *LaTeXTranslator) VisitRubric(node *rst.Element) {
+	t.write("\n", anchor(node), `\`, t.fallback("DUrubric"), "{")
+}
+
+func (t *LaTeXTranslator) DepartRubric(node *rst.Element) {
+	t.write("}\n")
+}
+
+func (t *LaTeXTranslator) VisitSection(node *rst.Element) {
+	t.sectionLevel++
+}
+
+func (t *LaTeXTranslator) DepartSection(node *rst.Element) {
+	t.sectionLevel--
+}
+
+func (t *LaTeXTranslator) VisitSidebar(node *rst.Element) {
+	t.write("\n", anchor(node), `\`, t.fallback("DUsidebar"), "{\n")
+}
+
+func (t *LaTeXTranslator) DepartSidebar(node *rst.Element) {
+	t.write("}\n")
+}
+
+func (t *LaTeXTranslator) VisitStatus(node *rst.Element) {
+	t.visitDocinfoItem(node, "status")
+}
+
+func (t *LaTeXTranslator) DepartStatus(node *rst.Element) {
+	t.departDocinfoItem()
+}
+
+func (t *LaTeXTranslator) VisitStrong(node *rst.Element) {
+	t.write(`\textbf{`)
+}
+
+func (t *LaTeXTranslator) DepartStrong(node *rst.Element) {
+	t.write("}")
+}
+
+func (t *LaTeXTranslator) VisitSubscript(node *rst.Element) {
+	t.write(`\textsubscript{`)
+}
+
+func (t *LaTeXTranslator) DepartSubscript(node *rst.Element) {
+	t.write("}")
+}
+
+func (t *LaTeXTranslator) VisitSubstitutionDefinition(node *rst.Element) error {
+	return &rst.SkipNode{}
+}
+
+func (t *LaTeXTranslator) VisitSubstitutionReference(node *rst.Element) error {
+	return t.UnknownVisit(node)
+}
+
+func (t *LaTeXTranslator) VisitSubtitle(node *rst.Element) {
+	switch node.Parent().TagName() {
+	case "document":
+		t.push(t.out)
+		t.out = &t.subtitle
+	case "sidebar":
+		t.push(t.out)
+		t.write(`\textit{`)
+	default:
+		t.push(t.out)
+		t.write(`\`, t.fallback("DUtitle"), "[subtitle]{")
+	}
+}
+
+func (t *LaTeXTranslator) DepartSubtitle(node *rst.Element) {
+	if node.Parent().TagName() != "document" {
+		t.write("}\n\n")
+	}
+	t.out = t.pop().(*[]string)
+}
+
+func (t *LaTeXTranslator) VisitSuperscript(node *rst.Element) {
+	t.write(`\textsuperscript{`)
+}
+
+func (t *LaTeXTranslator) DepartSuperscript(node *rst.Element) {
+	t.write("}")
+}
+
+func (t *LaTeXTranslator) VisitSystemMessage(node *rst.Element) {
+	t.write("\n", anchor(node), `\`, t.fallback("DUadmonition"), "[system-message]{\n")
+	title := "System Message: " + node.Get("type") + "/" + node.Get("level")
+	if node.HasAttr("source") {
+		title += " (" + node.Get("source")
+		if node.HasAttr("line") {
+			title += ", line " + node.Get("line")
+		}
+		title += ")"
+	}
+	t.write(`\`, t.fallback("DUtitle"), "[system-message]{", Encode(title), "}\n\n")
+}
+
+func (t *LaTeXTranslator) DepartSystemMessage(node *rst.Element) {
+	t.write("}\n")
+}
+
+func (t *LaTeXTranslator) VisitTable(node *rst.Element) {
+	tbl := &table{env: "longtable"}
+	parent := node.Parent().TagName()
+	if len(t.tables) > 0 || parent != "document" && parent != "section" {
+		// longtable works at the top level only
+		tbl.env = "tabular"
+	}
+	for _, cls := range node.Classes {
+		if cls == "tabular" || cls == "longtable" {
+			tbl.env = cls
+		}
+	}
+	t.required["tablewidth"] = true
+	if tbl.env == "longtable" {
+		t.required["longtable"] = true
+	}
+	t.tables = append(t.tables, tbl)
+	t.write("\n", anchor(node), "\\setlength{\\DUtablewidth}{\\linewidth}\n")
+}
+
+func (t *LaTeXTranslator) DepartTable(node *rst.Element) {
+	tbl := t.tables[len(t.tables)-1]
+	t.tables = t.tables[:len(t.tables)-1]
+	if tbl.env == "longtable" {
+		t.write("\\end{longtable}\n")
+	} else {
+		t.write("\\end{tabular}\n\\end{center}\n")
+	}
+}
+
+// Return the column specification of a column of `width`.
+func (t *LaTeXTranslator) columnSpec(tbl *table, width int) string {
+	total := 0
+	for _, w := range tbl.colwidth {
+		total += w
+	}
+	ratio := 1.0 / float64(len(tbl.colwidth))
+	if total > 0 {
+		ratio = float64(width) / float64(total)
+	}
+	return fmt.Sprintf(`p{%.3f\DUtablewidth}`, 0.93*ratio)
+}
+
+// Start the table environment, before the first row group.
+func (t *LaTeXTranslator) startTable(tbl *table) {
+	if tbl.started {
+		return
+	}
+	tbl.started = true
+	spec := "|"
+	for _, width := range tbl.colwidth {
+		spec += t.columnSpec(tbl, width) + "|"
+	}
+	if tbl.env == "longtable" {
+		t.write("\\begin{longtable}[c]{", spec, "}\n")
+		if tbl.caption != "" {
+			t.write("\\caption{", tbl.caption, "}\\\\\n")
+		}
+	} else {
+		if tbl.caption != "" {
+			t.write(`\`, t.fallback("DUtitle"), "[table]{", tbl.caption, "}\n")
+		}
+		t.write("\\begin{center}\n\\begin{tabular}{", spec, "}\n")
+	}
+	t.write("\\hline\n")
+}
+
+func (t *LaTeXTranslator) VisitTbody(node *rst.Element) {
+	t.startTable(t.tables[len(t.tables)-1])
+}
+
+func (t *LaTeXTranslator) DepartTbody(node *rst.Element) {}
+
+func (t *LaTeXTranslator) VisitTarget(node *rst.Element) {
+	if !(node.HasAttr("refuri") || node.HasAttr("refid") || node.HasAttr("refname")) {
+		t.write(anchor(node))
+	}
+}
+
+func (t *LaTeXTranslator) DepartTarget(node *rst.Element) {}
+
+func (t *LaTeXTranslator) VisitTerm(node *rst.Element) {
+	if isFirstChild(node) {
+		t.write(`\item[{`)
+	} else {
+		t.write(", ")
+	}
+	t.write(anchor(node))
+}
+
+func (t *LaTeXTranslator) DepartTerm(node *rst.Element) {}
+
+func (t *LaTeXTranslator) VisitTgroup(node *rst.Element)  {}
+func (t *LaTeXTranslator) DepartTgroup(node *rst.Element) {}
+
+func (t *LaTeXTranslator) VisitThead(node *rst.Element) {
+	tbl := t.tables[len(t.tables)-1]
+	t.startTable(tbl)
+	tbl.inHead = true
+}
+
+func (t *LaTeXTranslator) DepartThead(node *rst.Element) {
+	tbl := t.tables[len(t.tables)-1]
+	tbl.inHead = false
+	if tbl.env == "longtable" {
+		// repeat the header rows on every page
+		t.write("\\endhead\n")
+	}
+}
+
+func (t *LaTeXTranslator) VisitTitle(node *rst.Element) error {
+	parent := node.Parent()
+	switch parent.TagName() {
+	case "document":
+		t.push(t.out)
+		t.out = &t.title
+		return nil
+	case "table":
+		t.tables[len(t.tables)-1].caption = Encode(node.AsText())
+		return &rst.SkipNode{}
+	case "section":
+		level := t.sectionLevel - 1
+		if level < len(t.sections) {
+			t.push(t.out)
+			t.write("\n\n\\", t.sections[level], "{")
+			t.push("}\n" + labels(parent))
+			return nil
+		}
+	}
+	t.push(t.out)
+	t.write(`\`, t.fallback("DUtitle"), "[", classArg(parent, parent.TagName()), "]{")
+	t.push("}\n\n")
+	return nil
+}
+
+func (t *LaTeXTranslator) DepartTitle(node *rst.Element) {
+	if node.Parent().TagName() != "document" {
+		t.writePopped()
+	}
+	t.out = t.pop().(*[]string)
+}
+
+func (t *LaTeXTranslator) VisitTitleReference(node *rst.Element) {
+	t.write(`\`, t.fallback("DUroletitlereference"), "{")
+}
+
+func (t *LaTeXTranslator) DepartTitleReference(node *rst.Element) {
+	t.write("}")
+}
+
+func (t *LaTeXTranslator) VisitTopic(node *rst.Element) error {
+	for _, cls := range node.Classes {
+		switch cls {
+		case "contents":
+			t.write("\n", anchor(node))
+			if node.Len() > 0 && node.Children()[0].TagName() == "title" {
+				t.write("\\renewcommand{\\contentsname}{", Encode(node.Children()[0].AsText()), "}\n")
+			}
+			t.write("\\tableofcontents\n")
+			return &rst.SkipNode{}
+		case "abstract":
+			t.push(t.out)
+			t.out = &t.abstract
+		case "dedication":
+			t.push(t.out)
+			t.out = &t.dedication
+		}
+	}
+	if !(t.out == &t.abstract || t.out == &t.dedication) {
+		t.push(t.out)
+	}
+	t.write("\n", anchor(node), `\`, t.fallback("DUtopic"), "[", classArg(node, "topic"), "]{\n")
+	return nil
+}
+
+func (t *LaTeXTranslator) DepartTopic(node *rst.Element) {
+	t.write("}\n")
+	t.out = t.pop().(*[]string)
+}
+
+func (t *LaTeXTranslator) VisitTransition(node *rst.Element) {
+	t.write("\n\n%___________________________________________________________________________\n",
+		anchor(node), `\`, t.fallback("DUtransition"), "\n\n")
+}
+
+func (t *LaTeXTranslator) DepartTransition(node *rst.Element) {}
+
+func (t *LaTeXTranslator) VisitVersion(node *rst.Element) {
+	t.visitDocinfoItem(node, "version")
+}
+
+func (t *LaTeXTranslator) DepartVersion(node *rst.Element) {
+	t.departDocinfoItem()
+}
+
+type NotImplementedError struct {
+	msg string
+}
+
+func (e *NotImplementedError) Error() string {
+	return e.msg
+}
@@ -0,0 +1,138 @@
+package latex
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	rst "github.com/siongui/go-rst"
+	"github.com/siongui/go-rst/parsers/docutilsxml"
+)
+
+const input = `<document source="test.rst" title="A Title">
+    <title>A Title</title>
+    <section ids="introduction">
+        <title>Introduction</title>
+        <paragraph>See <reference refuri="https://example.com/a%20b#top">go-rst</reference> for 100% of $x_1$.</paragraph>
+        <table>
+            <title>Values</title>
+            <tgroup cols="2">
+                <colspec colwidth="10"/>
+                <colspec colwidth="30"/>
+                <thead>
+                    <row><entry><paragraph>Name</paragraph></entry><entry><paragraph>Value</paragraph></entry></row>
+                </thead>
+                <tbody>
+                    <row><entry morerows="1"><paragraph>a</paragraph></entry><entry><paragraph>1 &amp; 2</paragraph></entry></row>
+                    <row><entry><paragraph>3</paragraph></entry></row>
+                </tbody>
+            </tgroup>
+        </table>
+        <math_block>a &amp;= b \\ c &amp;= d</math_block>
+        <literal_block>if x {
+}</literal_block>
+    </section>
+</document>
+`
+
+func TestWriter(t *testing.T) {
+	settings := &rst.Settings{}
+	settings.Init()
+	settings.PaperSize = "a4paper"
+	document, err := docutilsxml.ParseDocument(input, "", settings)
+	if err != nil {
+		t.Fatal(err)
+	}
+	writer := &Writer{}
+	output, err := writer.Write(document)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, fragment := range []string{
+		"\\documentclass[a4paper]{article}\n",
+		"\\usepackage{amsmath}\n",
+		"\\usepackage{longtable,ltcaption,array}\n",
+		"\\usepackage{multirow}\n",
+		"\\title{A Title}\n\\author{}\n\\date{}\n",
+		"pdftitle={A Title}",
+		"\\begin{document}\n\\maketitle\n",
+		"\\section{Introduction}\n\\label{introduction}%\n",
+		"See \\href{https://example.com/a\\%20b\\#top}{go-rst} for 100\\% of \\$x\\_1\\$.\n",
+		"\\begin{longtable}[c]{|p{0.233\\DUtablewidth}|p{0.698\\DUtablewidth}|}\n\\caption{Values}\\\\\n\\hline\n",
+		"\\bfseries Name\n & \\bfseries Value\n \\\\\n\\hline\n\\endhead\n",
+		"\\multirow{2}{*}{a\n} & 1 \\& 2\n \\\\\n\\cline{2-2}\n & 3\n \\\\\n\\hline\n\\end{longtable}\n",
+		"\\begin{align*}\na &= b \\\\ c &= d\n\\end{align*}\n",
+		"\\begin{quote}\n\\begin{verbatim}\nif x {\n}\n\\end{verbatim}\n\\end{quote}\n",
+		"\\end{document}\n",
+	} {
+		if !strings.Contains(output, fragment) {
+			t.Errorf("%q missing from output:\n%s", fragment, output)
+		}
+	}
+	if writer.Parts()["title"] != "A Title" || writer.Parts()["whole"] != output {
+		t.Error("wrong parts")
+	}
+	if !writer.Supports("latex") || writer.Supports("html") {
+		t.Error("wrong supported formats")
+	}
+}
+
+func TestChapters(t *testing.T) {
+	settings := &rst.Settings{}
+	settings.Init()
+	settings.DocumentClass = "book"
+	settings.LiteralBlockEnv = "lstlisting"
+	document, err := docutilsxml.ParseDocument(input, "", settings)
+	if err != nil {
+		t.Fatal(err)
+	}
+	writer := &Writer{}
+	output, err := writer.Write(document)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, fragment := range []string{
+		"\\documentclass{book}\n",
+		"\\chapter{Introduction}\n",
+		"\\usepackage{listings}\n",
+		"\\begin{lstlisting}\nif x {\n}\n\\end{lstlisting}\n",
+	} {
+		if !strings.Contains(output, fragment) {
+			t.Errorf("%q missing from output:\n%s", fragment, output)
+		}
+	}
+}
+
+func TestTemplate(t *testing.T) {
+	dir, err := ioutil.TempDir("", "latex")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "template.tex")
+	if err := ioutil.WriteFile(path, []byte("${title}: $$5 $body"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	settings := &rst.Settings{}
+	settings.Init()
+	settings.LatexTemplate = path
+	document, err := docutilsxml.ParseDocument("<document><title>T</title><paragraph>Text</paragraph></document>", "test.rst", settings)
+	if err != nil {
+		t.Fatal(err)
+	}
+	output, err := (&Writer{}).Write(document)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if output != "T: $5 \nText\n" {
+		t.Errorf("unexpected output: %q", output)
+	}
+
+	settings.LatexTemplate = filepath.Join(dir, "missing.tex")
+	if _, err := (&Writer{}).Write(document); err == nil {
+		t.Error("missing template accepted")
+	}
+}
@@ -0,0 +1,1114 @@
+/*
+Package manpage implements the man page writer of Python docutils
+(rst2man): it renders the document to roff source using the man macros.
+
+URL of Python source code:
+http://sourceforge.net/p/docutils/code/HEAD/tree/trunk/docutils/docutils/writers/manpage.py
+
+The document title is the name of the man page and the subtitle its short
+description. The docinfo gives the header line: the "manual section" and
+"manual group" fields, the version and the date. The author and copyright
+go to AUTHOR and COPYRIGHT sections at the end of the page.
+*/
+package manpage
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	rst "github.com/siongui/go-rst"
+	"github.com/siongui/go-rst/writers"
+)
+
+// Macros used to indent blocks, keeping track of the indentation level.
+const header = `.\" Man page generated from reStructuredText.
+.
+.
+.nr rst2man-indent-level 0
+.
+.de1 rstReportMargin
+\\$1 \\n[an-margin]
+level \\n[rst2man-indent-level]
+level margin: \\n[rst2man-indent\\n[rst2man-indent-level]]
+-
+\\n[rst2man-indent0]
+\\n[rst2man-indent1]
+\\n[rst2man-indent2]
+..
+.de1 INDENT
+.\" .rstReportMargin pre:
+. RS \\$1
+. nr rst2man-indent\\n[rst2man-indent-level] \\n[an-margin]
+. nr rst2man-indent-level +1
+.\" .rstReportMargin post:
+..
+.de UNINDENT
+. RE
+.\" indent \\n[an-margin]
+.\" old: \\n[rst2man-indent\\n[rst2man-indent-level]]
+.nr rst2man-indent-level -1
+.\" new: \\n[rst2man-indent\\n[rst2man-indent-level]]
+.in \\n[rst2man-indent\\n[rst2man-indent-level]]u
+..
+`
+
+const (
+	blockQuoteIndent     = 3.5
+	literalBlockIndent   = 3.5
+	definitionListIndent = 7
+	optionListIndent     = 7
+)
+
+/*
+   The man page writer. Besides "whole", `Parts()` has "header" (the macro
+   definitions and the title lines) and "body".
+*/
+type Writer struct {
+	writers.Base
+}
+
+func (w *Writer) Supports(format string) bool {
+	return format == "manpage" || format == "man"
+}
+
+func (w *Writer) Write(document *rst.Document) (string, error) {
+	w.Document = document
+	translator := &Translator{}
+	translator.Init(document)
+	if err := rst.Walkabout(document, translator); err != nil {
+		return "", err
+	}
+	head := translator.header()
+	body := strings.Join(translator.body, "")
+	w.Output = head + body
+	w.AssembleParts()
+	w.SetPart("header", head)
+	w.SetPart("body", body)
+	return w.Output, nil
+}
+
+var specialCharacters = strings.NewReplacer(
+	"\\", `\e`,
+	"-", `\-`,
+	"'", `\(aq`,
+	"\u00b4", `\'`,
+	"`", `\(ga`,
+	"\"", `\(dq`,
+	"\u00a0", `\ `,
+	"\u2020", `\(dg`,
+)
+
+/*
+   Escape the roff special characters of `text`, and protect periods at the
+   beginning of lines from being read as requests.
+*/
+func Escape(text string) string {
+	text = specialCharacters.Replace(text)
+	if strings.HasPrefix(text, ".") {
+		text = `\&` + text
+	}
+	return strings.Replace(text, "\n.", "\n\\&.", -1)
+}
+
+// List item labels of a bullet or enumerated list.
+type listChar struct {
+	style  string
+	prefix string
+	suffix string
+	next   int
+	width  int
+}
+
+func newListChar(node *rst.Element) *listChar {
+	l := &listChar{style: "bullet", next: 1, width: 2}
+	if !node.HasAttr("enumtype") {
+		return l
+	}
+	l.style = node.Get("enumtype")
+	l.prefix, l.suffix = node.Get("prefix"), node.Get("suffix")
+	if start, err := strconv.Atoi(node.Get("start")); err == nil {
+		l.next = start
+	}
+	// wide enough for the last label
+	l.width = len(l.label(l.next+node.Len()-1)) + 1
+	return l
+}
+
+func (l *listChar) label(n int) string {
+	var label string
+	switch l.style {
+	case "bullet":
+		return `\(bu`
+	case "loweralpha":
+		label = string(rune('a' + n - 1))
+	case "upperalpha":
+		label = string(rune('A' + n - 1))
+	case "lowerroman":
+		label = strings.ToLower(toRoman(n))
+	case "upperroman":
+		label = toRoman(n)
+	default:
+		label = strconv.Itoa(n)
+	}
+	return l.prefix + label + l.suffix
+}
+
+func (l *listChar) nextLabel() string {
+	label := l.label(l.next)
+	l.next++
+	return label
+}
+
+var romanNumerals = []struct {
+	value   int
+	numeral string
+}{
+	{1000, "M"}, {900, "CM"}, {500, "D"}, {400, "CD"}, {100, "C"}, {90, "XC"},
+	{50, "L"}, {40, "XL"}, {10, "X"}, {9, "IX"}, {5, "V"}, {4, "IV"}, {1, "I"},
+}
+
+func toRoman(n int) string {
+	var b strings.Builder
+	for _, r := range romanNumerals {
+		for n >= r.value {
+			b.WriteString(r.numeral)
+			n -= r.value
+		}
+	}
+	return b.String()
+}
+
+// A table being translated: the cells of each row.
+type table struct {
+	cols int
+	rows [][]string
+}
+
+/*
+   Translates the document tree to roff. Visit and depart methods are
+   called by `rst.Walkabout()`.
+*/
+type Translator struct {
+	document *rst.Document
+	language *rst.Language
+
+	body []string
+	// The output visit methods append to: the body or a table cell.
+	out *[]string
+
+	// Stack of closing strings, pushed in visit methods and popped in the
+	// corresponding depart methods.
+	context []string
+
+	// Docinfo values by name, in order of appearance, and the labels of
+	// generic docinfo fields.
+	docinfo      map[string]string
+	docinfoKeys  []string
+	docinfoNames map[string]string
+	authors      []string
+
+	// Indentation steps of the nested INDENT macros.
+	indents      []float64
+	listChars    []*listChar
+	sectionLevel int
+	lineBlock    int
+	tables       []*table
+}
+
+func (t *Translator) Init(document *rst.Document) {
+	t.document = document
+	t.language = rst.GetLanguage(document.Settings().LanguageCode)
+	t.out = &t.body
+	t.docinfo = map[string]string{}
+	t.docinfoNames = map[string]string{}
+	t.indents = []float64{0}
+}
+
+// Return the comments, macros, and the title and name lines of the page.
+func (t *Translator) header() string {
+	title := t.docinfo["title"]
+	th := fmt.Sprintf(".TH \"%s\" \"%s\" \"%s\" \"%s\" \"%s\"\n", Escape(strings.ToUpper(title)),
+		Escape(t.docinfo["manual_section"]), Escape(t.docinfo["date"]), Escape(t.docinfo["version"]),
+		Escape(t.docinfo["manual_group"]))
+	name := ".SH NAME\n" + Escape(title)
+	if subtitle := t.docinfo["subtitle"]; subtitle != "" {
+		name += ` \- ` + Escape(subtitle)
+	}
+	return header + th + name + "\n"
+}
+
+func (t *Translator) UnknownVisit(node rst.Node) error {
+	return &NotImplementedError{fmt.Sprintf("visiting unknown node type: %s", node.TagName())}
+}
+
+func (t *Translator) UnknownDeparture(node rst.Node) error {
+	return &NotImplementedError{fmt.Sprintf("departing unknown node type: %s", node.TagName())}
+}
+
+func (t *Translator) write(strs ...string) {
+	*t.out = append(*t.out, strs...)
+}
+
+func (t *Translator) push(value string) {
+	t.context = append(t.context, value)
+}
+
+// Write the string popped from the context stack.
+func (t *Translator) writePopped() {
+	value := t.context[len(t.context)-1]
+	t.context = t.context[:len(t.context)-1]
+	t.write(value)
+}
+
+// Start a new line, unless the output already ends with one.
+func (t *Translator) ensureEOL() {
+	out := *t.out
+	if len(out) > 0 && !strings.HasSuffix(out[len(out)-1], "\n") {
+		t.write("\n")
+	}
+}
+
+func (t *Translator) comment(text string) string {
+	return `.\" ` + strings.Replace(text, "\n", "\n.\\\" ", -1) + "\n"
+}
+
+/*
+   Indent the following output by the current step, and make `by` the step
+   of nested indentations. The INDENT macro indents relative to the current
+   indentation, so a first indentation is by 0.
+*/
+func (t *Translator) indent(by float64) {
+	step := t.indents[len(t.indents)-1]
+	t.indents = append(t.indents, by)
+	t.ensureEOL()
+	t.write(fmt.Sprintf(".INDENT %.1f\n", step))
+}
+
+func (t *Translator) dedent() {
+	t.indents = t.indents[:len(t.indents)-1]
+	t.ensureEOL()
+	t.write(".UNINDENT\n")
+}
+
+// Is `node` the first child of its parent (not counting its label)?
+func isFirstChild(node rst.Node) bool {
+	parent := node.Parent()
+	index := parent.Index(node)
+	return index == 0 || index == 1 && parent.Children()[0].TagName() == "label"
+}
+
+func (t *Translator) VisitText(node *rst.Text) {
+	t.write(Escape(node.AsText()))
+}
+
+func (t *Translator) DepartText(node *rst.Text) {}
+
+func (t *Translator) VisitAbbreviation(node *rst.Element)  {}
+func (t *Translator) DepartAbbreviation(node *rst.Element) {}
+func (t *Translator) VisitAcronym(node *rst.Element)       {}
+func (t *Translator) DepartAcronym(node *rst.Element)      {}
+
+func (t *Translator) VisitAddress(node *rst.Element) error {
+	return t.visitDocinfoItem(node, "address")
+}
+
+// Admonitions are block quotes with a strong heading.
+func (t *Translator) VisitAdmonition(node *rst.Element) {
+	t.VisitBlockQuote(node)
+}
+
+func (t *Translator) DepartAdmonition(node *rst.Element) {
+	t.DepartBlockQuote(node)
+}
+
+func (t *Translator) visitSpecificAdmonition(node *rst.Element, name string) {
+	t.ensureEOL()
+	t.write(".sp\n", `\fB`, Escape(strings.ToUpper(t.language.Labels[name])), `:\fP`, "\n")
+	t.VisitBlockQuote(node)
+}
+
+func (t *Translator) VisitAttention(node *rst.Element) {
+	t.visitSpecificAdmonition(node, "attention")
+}
+
+func (t *Translator) DepartAttention(node *rst.Element) {
+	t.DepartBlockQuote(node)
+}
+
+func (t *Translator) VisitCaution(node *rst.Element) {
+	t.visitSpecificAdmonition(node, "caution")
+}
+
+func (t *Translator) DepartCaution(node *rst.Element) {
+	t.DepartBlockQuote(node)
+}
+
+func (t *Translator) VisitDanger(node *rst.Element) {
+	t.visitSpecificAdmonition(node, "danger")
+}
+
+func (t *Translator) DepartDanger(node *rst.Element) {
+	t.DepartBlockQuote(node)
+}
+
+func (t *Translator) VisitError(node *rst.Element) {
+	t.visitSpecificAdmonition(node, "error")
+}
+
+func (t *Translator) DepartError(node *rst.Element) {
+	t.DepartBlockQuote(node)
+}
+
+func (t *Translator) VisitHint(node *rst.Element) {
+	t.visitSpecificAdmonition(node, "hint")
+}
+
+func (t *Translator) DepartHint(node *rst.Element) {
+	t.DepartBlockQuote(node)
+}
+
+func (t *Translator) VisitImportant(node *rst.Element) {
+	t.visitSpecificAdmonition(node, "important")
+}
+
+func (t *Translator) DepartImportant(node *rst.Element) {
+	t.DepartBlockQuote(node)
+}
+
+func (t *Translator) VisitNote(node *rst.Element) {
+	t.visitSpecificAdmonition(node, "note")
+}
+
+func (t *Translator) DepartNote(node *rst.Element) {
+	t.DepartBlockQuote(node)
+}
+
+func (t *Translator) VisitTip(node *rst.Element) {
+	t.visitSpecificAdmonition(node, "tip")
+}
+
+func (t *Translator) DepartTip(node *rst.Element) {
+	t.DepartBlockQuote(node)
+}
+
+func (t *Translator) VisitWarning(node *rst.Element) {
+	t.visitSpecificAdmonition(node, "warning")
+}
+
+func (t *Translator) DepartWarning(node *rst.Element) {
+	t.DepartBlockQuote(node)
+}
+
+func (t *Translator) VisitAttribution(node *rst.Element) {
+	t.ensureEOL()
+	t.write(".sp\n", `\(em `)
+}
+
+func (t *Translator) DepartAttribution(node *rst.Element) {
+	t.write("\n")
+}
+
+func (t *Translator) VisitAuthor(node *rst.Element) error {
+	t.authors = append(t.authors, node.AsText())
+	return &rst.SkipNode{}
+}
+
+func (t *Translator) VisitAuthors(node *rst.Element)  {}
+func (t *Translator) DepartAuthors(node *rst.Element) {}
+
+/*
+   The INDENT macro indents by the step of the enclosing indentation, so
+   two are needed to indent the block quote itself.
+*/
+func (t *Translator) VisitBlockQuote(node *rst.Element) {
+	t.indent(blockQuoteIndent)
+	t.indent(0)
+}
+
+func (t *Translator) DepartBlockQuote(node *rst.Element) {
+	t.dedent()
+	t.dedent()
+}
+
+func (t *Translator) VisitBulletList(node *rst.Element) {
+	t.listStart(node)
+}
+
+func (t *Translator) DepartBulletList(node *rst.Element) {
+	t.listEnd()
+}
+
+func (t *Translator) listStart(node *rst.Element) {
+	t.listChars = append(t.listChars, newListChar(node))
+	if n := len(t.listChars); n > 1 {
+		// indent nested lists
+		t.indent(float64(t.listChars[n-2].width))
+	} else {
+		t.indent(float64(t.listChars[n-1].width))
+	}
+}
+
+func (t *Translator) listEnd() {
+	t.dedent()
+	t.listChars = t.listChars[:len(t.listChars)-1]
+}
+
+func (t *Translator) VisitCaption(node *rst.Element) {
+	t.ensureEOL()
+	t.write(".sp\n")
+}
+
+func (t *Translator) DepartCaption(node *rst.Element) {
+	t.write("\n")
+}
+
+func (t *Translator) VisitCitation(node *rst.Element) {
+	t.VisitFootnote(node)
+}
+
+func (t *Translator) DepartCitation(node *rst.Element) {}
+
+func (t *Translator) VisitCitationReference(node *rst.Element) error {
+	t.write("[", Escape(node.AsText()), "]")
+	return &rst.SkipNode{}
+}
+
+func (t *Translator) VisitClassifier(node *rst.Element) {
+	t.write(" : ")
+}
+
+func (t *Translator) DepartClassifier(node *rst.Element) {}
+
+func (t *Translator) VisitColspec(node *rst.Element) error {
+	return &rst.SkipNode{}
+}
+
+func (t *Translator) VisitComment(node *rst.Element) error {
+	t.ensureEOL()
+	t.write(t.comment(node.AsText()))
+	return &rst.SkipNode{}
+}
+
+func (t *Translator) VisitCompound(node *rst.Element)  {}
+func (t *Translator) DepartCompound(node *rst.Element) {}
+
+func (t *Translator) VisitContact(node *rst.Element) error {
+	return t.visitDocinfoItem(node, "contact")
+}
+
+func (t *Translator) VisitContainer(node *rst.Element)  {}
+func (t *Translator) DepartContainer(node *rst.Element) {}
+
+func (t *Translator) VisitCopyright(node *rst.Element) error {
+	return t.visitDocinfoItem(node, "copyright")
+}
+
+func (t *Translator) VisitDate(node *rst.Element) error {
+	return t.visitDocinfoItem(node, "date")
+}
+
+// Header and footer have no place in man pages.
+func (t *Translator) VisitDecoration(node *rst.Element) error {
+	return &rst.SkipNode{}
+}
+
+func (t *Translator) VisitDefinition(node *rst.Element)  {}
+func (t *Translator) DepartDefinition(node *rst.Element) {}
+
+func (t *Translator) VisitDefinitionList(node *rst.Element) {
+	t.indent(definitionListIndent)
+}
+
+func (t *Translator) DepartDefinitionList(node *rst.Element) {
+	t.dedent()
+}
+
+func (t *Translator) VisitDefinitionListItem(node *rst.Element) {
+	t.ensureEOL()
+	t.write(".TP")
+}
+
+func (t *Translator) DepartDefinitionListItem(node *rst.Element) {}
+
+func (t *Translator) VisitDescription(node *rst.Element)  {}
+func (t *Translator) DepartDescription(node *rst.Element) {}
+
+func (t *Translator) VisitDocinfo(node *rst.Element)  {}
+func (t *Translator) DepartDocinfo(node *rst.Element) {}
+
+func (t *Translator) visitDocinfoItem(node *rst.Element, name string) error {
+	if _, ok := t.docinfo[name]; !ok {
+		t.docinfoKeys = append(t.docinfoKeys, name)
+	}
+	t.docinfo[name] = node.AsText()
+	return &rst.SkipNode{}
+}
+
+func (t *Translator) VisitDoctestBlock(node *rst.Element) {
+	t.VisitLiteralBlock(node)
+}
+
+func (t *Translator) DepartDoctestBlock(node *rst.Element) {
+	t.DepartLiteralBlock(node)
+}
+
+func (t *Translator) VisitDocument(node *rst.Element) {}
+
+// Write the AUTHOR section, the remaining docinfo and COPYRIGHT section.
+func (t *Translator) DepartDocument(node *rst.Element) {
+	t.ensureEOL()
+	if len(t.authors) > 0 {
+		var authors []string
+		for _, author := range t.authors {
+			authors = append(authors, Escape(author))
+		}
+		t.write(".SH AUTHOR\n", strings.Join(authors, ", "), "\n")
+	}
+	skip := map[string]bool{
+		"copyright":      true,
+		"date":           true,
+		"manual_group":   true,
+		"manual_section": true,
+		"subtitle":       true,
+		"title":          true,
+		"version":        true,
+	}
+	for _, name := range t.docinfoKeys {
+		if skip[name] {
+			continue
+		}
+		label, ok := t.docinfoNames[name]
+		if !ok {
+			label = t.language.Labels[name]
+		}
+		if name == "address" {
+			t.write("\n", Escape(label), ":\n.sp\n.nf\n", Escape(t.docinfo[name]), "\n.fi\n")
+			continue
+		}
+		t.write("\n", Escape(label), ": ", Escape(t.docinfo[name]), "\n")
+	}
+	if copyright := t.docinfo["copyright"]; copyright != "" {
+		t.write(".SH COPYRIGHT\n", Escape(copyright), "\n")
+	}
+	t.write(t.comment("Generated by go-rst manpage writer."))
+}
+
+func (t *Translator) VisitEmphasis(node *rst.Element) {
+	t.write(`\fI`)
+}
+
+func (t *Translator) DepartEmphasis(node *rst.Element) {
+	t.write(`\fP`)
+}
+
+func (t *Translator) VisitEntry(node *rst.Element) {
+	tbl := t.tables[len(t.tables)-1]
+	row := &tbl.rows[len(tbl.rows)-1]
+	*row = append(*row, "")
+	t.out = &[]string{}
+}
+
+// Store the cell, without the vertical space at its start and end.
+func (t *Translator) DepartEntry(node *rst.Element) {
+	cell := strings.Join(*t.out, "")
+	cell = strings.TrimPrefix(cell, ".sp\n")
+	cell = strings.TrimSuffix(cell, ".sp\n")
+	tbl := t.tables[len(t.tables)-1]
+	row := tbl.rows[len(tbl.rows)-1]
+	row[len(row)-1] = cell
+	t.out = &t.body
+}
+
+func (t *Translator) VisitEnumeratedList(node *rst.Element) {
+	t.listStart(node)
+}
+
+func (t *Translator) DepartEnumeratedList(node *rst.Element) {
+	t.listEnd()
+}
+
+func (t *Translator) VisitField(node *rst.Element) error {
+	if node.Parent().TagName() != "docinfo" {
+		t.ensureEOL()
+		t.write(".TP\n")
+		return nil
+	}
+	// generic docinfo field, e.g. "manual section"
+	var label, value string
+	for _, child := range node.Children() {
+		switch child.TagName() {
+		case "field_name":
+			label = child.AsText()
+		case "field_body":
+			value = child.AsText()
+		}
+	}
+	name := strings.Replace(strings.ToLower(label), " ", "_", -1)
+	if _, ok := t.docinfo[name]; !ok {
+		t.docinfoKeys = append(t.docinfoKeys, name)
+	}
+	t.docinfo[name] = value
+	t.docinfoNames[name] = label
+	return &rst.SkipNode{}
+}
+
+func (t *Translator) DepartField(node *rst.Element) {}
+
+func (t *Translator) VisitFieldBody(node *rst.Element)  {}
+func (t *Translator) DepartFieldBody(node *rst.Element) {}
+
+func (t *Translator) VisitFieldList(node *rst.Element) {
+	t.indent(definitionListIndent)
+}
+
+func (t *Translator) DepartFieldList(node *rst.Element) {
+	t.dedent()
+}
+
+func (t *Translator) VisitFieldName(node *rst.Element) {
+	t.write(".B ")
+}
+
+func (t *Translator) DepartFieldName(node *rst.Element) {
+	t.write("\n")
+}
+
+func (t *Translator) VisitFigure(node *rst.Element) {
+	t.VisitBlockQuote(node)
+}
+
+func (t *Translator) DepartFigure(node *rst.Element) {
+	t.DepartBlockQuote(node)
+}
+
+func (t *Translator) VisitFooter(node *rst.Element) error {
+	return &rst.SkipNode{}
+}
+
+func (t *Translator) VisitFootnote(node *rst.Element) {
+	t.ensureEOL()
+	label := ""
+	if node.Len() > 0 && node.Children()[0].TagName() == "label" {
+		label = node.Children()[0].AsText()
+	}
+	t.write(".IP [", Escape(label), "] 5\n")
+}
+
+func (t *Translator) DepartFootnote(node *rst.Element) {}
+
+func (t *Translator) VisitFootnoteReference(node *rst.Element) error {
+	t.write(`\fB[`, Escape(node.AsText()), `]\fP`)
+	return &rst.SkipNode{}
+}
+
+func (t *Translator) VisitGenerated(node *rst.Element)  {}
+func (t *Translator) DepartGenerated(node *rst.Element) {}
+
+func (t *Translator) VisitHeader(node *rst.Element) error {
+	return &rst.SkipNode{}
+}
+
+func (t *Translator) VisitImage(node *rst.Element) error {
+	var text []string
+	for _, name := range []string{"alt", "uri"} {
+		if node.HasAttr(name) {
+			text = append(text, node.Get(name))
+		}
+	}
+	t.write("[image: ", Escape(strings.Join(text, "/")), "]\n")
+	return &rst.SkipNode{}
+}
+
+func (t *Translator) VisitInline(node *rst.Element)  {}
+func (t *Translator) DepartInline(node *rst.Element) {}
+
+// Labels are written by the footnote or citation.
+func (t *Translator) VisitLabel(node *rst.Element) error {
+	return &rst.SkipNode{}
+}
+
+func (t *Translator) VisitLegend(node *rst.Element)  {}
+func (t *Translator) DepartLegend(node *rst.Element) {}
+
+func (t *Translator) VisitLine(node *rst.Element) {}
+func (t *Translator) DepartLine(node *rst.Element) {
+	t.write("\n")
+}
+
+func (t *Translator) VisitLineBlock(node *rst.Element) {
+	t.lineBlock++
+	t.ensureEOL()
+	if t.lineBlock == 1 {
+		t.write(".sp\n.nf\n")
+	} else {
+		t.write(".in +2\n")
+	}
+}
+
+func (t *Translator) DepartLineBlock(node *rst.Element) {
+	t.lineBlock--
+	t.ensureEOL()
+	if t.lineBlock == 0 {
+		t.write(".fi\n")
+	} else {
+		t.write(".in -2\n")
+	}
+}
+
+func (t *Translator) VisitListItem(node *rst.Element) {
+	l := t.listChars[len(t.listChars)-1]
+	t.ensureEOL()
+	t.write(fmt.Sprintf(".IP %s %d\n", l.nextLabel(), l.width))
+}
+
+func (t *Translator) DepartListItem(node *rst.Element) {}
+
+func (t *Translator) VisitLiteral(node *rst.Element) {
+	t.write(`\fB`)
+}
+
+func (t *Translator) DepartLiteral(node *rst.Element) {
+	t.write(`\fP`)
+}
+
+func (t *Translator) VisitLiteralBlock(node *rst.Element) {
+	t.indent(literalBlockIndent)
+	t.indent(0)
+	t.write(".sp\n.nf\n.ft C\n")
+}
+
+func (t *Translator) DepartLiteralBlock(node *rst.Element) {
+	t.write("\n.ft P\n.fi\n")
+	t.dedent()
+	t.dedent()
+}
+
+func (t *Translator) VisitMath(node *rst.Element)  {}
+func (t *Translator) DepartMath(node *rst.Element) {}
+
+func (t *Translator) VisitMathBlock(node *rst.Element) {
+	t.VisitLiteralBlock(node)
+}
+
+func (t *Translator) DepartMathBlock(node *rst.Element) {
+	t.DepartLiteralBlock(node)
+}
+
+func (t *Translator) VisitOption(node *rst.Element) {
+	if node.Parent().Index(node) > 0 {
+		t.write(", ")
+	}
+}
+
+func (t *Translator) DepartOption(node *rst.Element) {}
+
+func (t *Translator) VisitOptionArgument(node *rst.Element) {
+	t.write(Escape(node.Get("delimiter")), `\fI`)
+}
+
+func (t *Translator) DepartOptionArgument(node *rst.Element) {
+	t.write(`\fB`)
+}
+
+func (t *Translator) VisitOptionGroup(node *rst.Element) {
+	t.write(".B ")
+}
+
+func (t *Translator) DepartOptionGroup(node *rst.Element) {
+	t.write("\n")
+}
+
+func (t *Translator) VisitOptionList(node *rst.Element) {
+	t.indent(optionListIndent)
+}
+
+func (t *Translator) DepartOptionList(node *rst.Element) {
+	t.dedent()
+}
+
+func (t *Translator) VisitOptionListItem(node *rst.Element) {
+	t.ensureEOL()
+	t.write(".TP\n")
+}
+
+func (t *Translator) DepartOptionListItem(node *rst.Element) {}
+
+func (t *Translator) VisitOptionString(node *rst.Element)  {}
+func (t *Translator) DepartOptionString(node *rst.Element) {}
+
+func (t *Translator) VisitOrganization(node *rst.Element) error {
+	return t.visitDocinfoItem(node, "organization")
+}
+
+/*
+   Paragraphs are separated by vertical space (.sp); the paragraph macros
+   would reset the indentation.
+*/
+func (t *Translator) VisitParagraph(node *rst.Element) {
+	t.ensureEOL()
+	if !isFirstChild(node) {
+		t.write(".sp\n")
+	}
+}
+
+func (t *Translator) DepartParagraph(node *rst.Element) {
+	t.write("\n")
+}
+
+func (t *Translator) VisitPending(node *rst.Element) error {
+	return &rst.SkipNode{}
+}
+
+func (t *Translator) VisitProblematic(node *rst.Element) {
+	t.write(`\fB`)
+}
+
+func (t *Translator) DepartProblematic(node *rst.Element) {
+	t.write(`\fP`)
+}
+
+func (t *Translator) VisitRaw(node *rst.Element) error {
+	if writers.RawFormatMatches(node, "manpage") {
+		t.write(node.AsText())
+	}
+	return &rst.SkipNode{}
+}
+
+func (t *Translator) VisitReference(node *rst.Element) {
+	t.write(`\fI\%`)
+}
+
+func (t *Translator) DepartReference(node *rst.Element) {
+	t.write(`\fP`)
+}
+
+func (t *Translator) VisitRevision(node *rst.Element) error {
+	return t.visitDocinfoItem(node, "revision")
+}
+
+func (t *Translator) VisitRow(node *rst.Element) {
+	tbl := t.tables[len(t.tables)-1]
+	tbl.rows = append(tbl.rows, nil)
+}
+
+func (t *Translator) DepartRow(node *rst.Element) {}
+
+func (t *Translator) VisitRubric(node *rst.Element) {
+	t.ensureEOL()
+	t.write(".sp\n", `\fB`)
+}
+
+func (t *Translator) DepartRubric(node *rst.Element) {
+	t.write(`\fP`, "\n")
+}
+
+func (t *Translator) VisitSection(node *rst.Element) {
+	t.sectionLevel++
+}
+
+func (t *Translator) DepartSection(node *rst.Element) {
+	t.sectionLevel--
+}
+
+func (t *Translator) VisitSidebar(node *rst.Element) {
+	t.VisitBlockQuote(node)
+}
+
+func (t *Translator) DepartSidebar(node *rst.Element) {
+	t.DepartBlockQuote(node)
+}
+
+func (t *Translator) VisitStatus(node *rst.Element) error {
+	return t.visitDocinfoItem(node, "status")
+}
+
+func (t *Translator) VisitStrong(node *rst.Element) {
+	t.write(`\fB`)
+}
+
+func (t *Translator) DepartStrong(node *rst.Element) {
+	t.write(`\fP`)
+}
+
+func (t *Translator) VisitSubscript(node *rst.Element) {
+	t.write(`\s-2\d`)
+}
+
+func (t *Translator) DepartSubscript(node *rst.Element) {
+	t.write(`\u\s0`)
+}
+
+func (t *Translator) VisitSubstitutionDefinition(node *rst.Element) error {
+	return &rst.SkipNode{}
+}
+
+func (t *Translator) VisitSubstitutionReference(node *rst.Element) error {
+	return t.UnknownVisit(node)
+}
+
+func (t *Translator) VisitSubtitle(node *rst.Element) error {
+	if node.Parent().TagName() == "document" {
+		t.docinfo["subtitle"] = node.AsText()
+		return &rst.SkipNode{}
+	}
+	t.ensureEOL()
+	t.write(".sp\n", `\fI`)
+	t.push(`\fP` + "\n")
+	return nil
+}
+
+func (t *Translator) DepartSubtitle(node *rst.Element) {
+	t.writePopped()
+}
+
+func (t *Translator) VisitSuperscript(node *rst.Element) {
+	t.write(`\s-2\u`)
+}
+
+func (t *Translator) DepartSuperscript(node *rst.Element) {
+	t.write(`\d\s0`)
+}
+
+func (t *Translator) VisitSystemMessage(node *rst.Element) {
+	title := "System Message: " + node.Get("type") + "/" + node.Get("level")
+	if node.HasAttr("source") {
+		title += " (" + node.Get("source")
+		if node.HasAttr("line") {
+			title += ", line " + node.Get("line")
+		}
+		title += ")"
+	}
+	t.ensureEOL()
+	t.write(".sp\n", `\fB`, Escape(title), `\fP`, "\n")
+	t.VisitBlockQuote(node)
+}
+
+func (t *Translator) DepartSystemMessage(node *rst.Element) {
+	t.DepartBlockQuote(node)
+}
+
+func (t *Translator) VisitTable(node *rst.Element) {
+	t.tables = append(t.tables, &table{})
+}
+
+// Write the table with the tbl preprocessor, one text block per cell.
+func (t *Translator) DepartTable(node *rst.Element) {
+	tbl := t.tables[len(t.tables)-1]
+	t.tables = t.tables[:len(t.tables)-1]
+	t.ensureEOL()
+	coldefs := make([]string, tbl.cols)
+	for i := range coldefs {
+		coldefs[i] = "l"
+	}
+	t.write(".TS\ncenter;\n|", strings.Join(coldefs, "|"), "|.\n")
+	for _, row := range tbl.rows {
+		t.write("_\nT{\n")
+		for i, cell := range row {
+			if !strings.HasSuffix(cell, "\n") {
+				cell += "\n"
+			}
+			t.write(cell)
+			if i < len(row)-1 {
+				t.write("T}\tT{\n")
+			} else {
+				t.write("T}\n")
+			}
+		}
+	}
+	t.write("_\n.TE\n")
+}
+
+func (t *Translator) VisitTarget(node *rst.Element)  {}
+func (t *Translator) DepartTarget(node *rst.Element) {}
+
+func (t *Translator) VisitTbody(node *rst.Element)  {}
+func (t *Translator) DepartTbody(node *rst.Element) {}
+
+func (t *Translator) VisitTerm(node *rst.Element) {
+	if isFirstChild(node) {
+		t.write("\n.B ")
+	} else {
+		t.write(", ")
+	}
+}
+
+func (t *Translator) DepartTerm(node *rst.Element) {
+	parent := node.Parent()
+	index := parent.Index(node)
+	if index+1 == parent.Len() || parent.Children()[index+1].TagName() != "term" &&
+		parent.Children()[index+1].TagName() != "classifier" {
+		t.write("\n")
+	}
+}
+
+func (t *Translator) VisitTgroup(node *rst.Element) {
+	cols, _ := strconv.Atoi(node.Get("cols"))
+	t.tables[len(t.tables)-1].cols = cols
+}
+
+func (t *Translator) DepartTgroup(node *rst.Element) {}
+
+func (t *Translator) VisitThead(node *rst.Element)  {}
+func (t *Translator) DepartThead(node *rst.Element) {}
+
+func (t *Translator) VisitTitle(node *rst.Element) error {
+	switch node.Parent().TagName() {
+	case "document":
+		t.docinfo["title"] = node.AsText()
+		return &rst.SkipNode{}
+	case "section":
+		t.ensureEOL()
+		if t.sectionLevel == 1 {
+			t.write(".SH ", Escape(strings.ToUpper(node.AsText())), "\n")
+			return &rst.SkipNode{}
+		}
+		t.write(".SS ")
+		t.push("\n")
+		return nil
+	}
+	t.ensureEOL()
+	t.write(".sp\n", `\fB`)
+	t.push(`\fP` + "\n")
+	return nil
+}
+
+func (t *Translator) DepartTitle(node *rst.Element) {
+	t.writePopped()
+}
+
+func (t *Translator) VisitTitleReference(node *rst.Element) {
+	t.write(`\fI`)
+}
+
+func (t *Translator) DepartTitleReference(node *rst.Element) {
+	t.write(`\fP`)
+}
+
+func (t *Translator) VisitTopic(node *rst.Element) {
+	t.VisitBlockQuote(node)
+}
+
+func (t *Translator) DepartTopic(node *rst.Element) {
+	t.DepartBlockQuote(node)
+}
+
+func (t *Translator) VisitTransition(node *rst.Element) {
+	t.write("\n.sp\n.ce\n----\n")
+}
+
+func (t *Translator) DepartTransition(node *rst.Element) {
+	t.write("\n.ce 0\n.sp\n")
+}
+
+func (t *Translator) VisitVersion(node *rst.Element) error {
+	return t.visitDocinfoItem(node, "version")
+}
+
+type NotImplementedError struct {
+	msg string
+}
+
+func (e *NotImplementedError) Error() string {
+	return e.msg
+}
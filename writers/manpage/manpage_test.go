@@ -0,0 +1,117 @@
+package manpage
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/siongui/go-rst/parsers/docutilsxml"
+)
+
+const input = `<document source="gorst.rst">
+    <title>gorst</title>
+    <subtitle>reStructuredText tool</subtitle>
+    <docinfo>
+        <author>Jane Doe</author>
+        <date>2026-10-18</date>
+        <version>1.0</version>
+        <field><field_name>manual section</field_name><field_body><paragraph>1</paragraph></field_body></field>
+        <copyright>Public domain</copyright>
+    </docinfo>
+    <section>
+        <title>Options</title>
+        <paragraph>Run <literal>gorst</literal></paragraph>
+        <option_list>
+            <option_list_item>
+                <option_group>
+                    <option><option_string>-o</option_string></option>
+                    <option><option_string>--output</option_string><option_argument delimiter="=">file</option_argument></option>
+                </option_group>
+                <description><paragraph>Write to file.</paragraph></description>
+            </option_list_item>
+        </option_list>
+        <enumerated_list enumtype="lowerroman" suffix=")">
+            <list_item><paragraph>one</paragraph></list_item>
+            <list_item><paragraph>two</paragraph></list_item>
+        </enumerated_list>
+        <definition_list>
+            <definition_list_item>
+                <term>config</term>
+                <definition><paragraph>The "default" one.</paragraph></definition>
+            </definition_list_item>
+        </definition_list>
+        <literal_block>.ft it's
+</literal_block>
+        <section>
+            <title>Notes</title>
+            <paragraph>It's \fine.</paragraph>
+        </section>
+    </section>
+</document>
+`
+
+func TestWriter(t *testing.T) {
+	document, err := docutilsxml.ParseDocument(input, "", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	writer := &Writer{}
+	output, err := writer.Write(document)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := `.TH "GORST" "1" "2026\-10\-18" "1.0" ""
+.SH NAME
+gorst \- reStructuredText tool
+`
+	if !strings.HasPrefix(output, header) || writer.Parts()["header"] != header+expected {
+		t.Error("unexpected header:\n" + writer.Parts()["header"])
+	}
+	expected = `.SH OPTIONS
+.sp
+Run \fBgorst\fP
+.INDENT 0.0
+.TP
+.B \-o, \-\-output=\fIfile\fB
+Write to file.
+.UNINDENT
+.INDENT 0.0
+.IP i) 4
+one
+.IP ii) 4
+two
+.UNINDENT
+.INDENT 0.0
+.TP
+.B config
+The \(dqdefault\(dq one.
+.UNINDENT
+.INDENT 0.0
+.INDENT 3.5
+.sp
+.nf
+.ft C
+\&.ft it\(aqs
+
+.ft P
+.fi
+.UNINDENT
+.UNINDENT
+.SS Notes
+.sp
+It\(aqs \efine.
+.SH AUTHOR
+Jane Doe
+.SH COPYRIGHT
+Public domain
+.\" Generated by go-rst manpage writer.
+`
+	if body := writer.Parts()["body"]; body != expected {
+		t.Error("unexpected body:\n" + body)
+	}
+	if output != writer.Parts()["header"]+writer.Parts()["body"] {
+		t.Error("wrong whole output")
+	}
+	if !writer.Supports("manpage") || writer.Supports("html") {
+		t.Error("wrong supported formats")
+	}
+}
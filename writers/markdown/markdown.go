@@ -0,0 +1,878 @@
+/*
+Package markdown implements a Markdown writer (no Python docutils
+counterpart): it renders the document to CommonMark with the GitHub
+Flavored Markdown extensions (tables, footnotes, math).
+
+Elements without Markdown equivalent (definition, field and option lists,
+admonitions, topics, figures, complex tables, ...) are written as HTML
+blocks, translated by the writers/html package. What cannot be converted
+without loss (e.g. the header and footer, raw content for other formats,
+section levels below 6) is reported as a warning by the document reporter.
+*/
+package markdown
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	rst "github.com/siongui/go-rst"
+	"github.com/siongui/go-rst/writers"
+	"github.com/siongui/go-rst/writers/html"
+)
+
+// Formats this writer supports.
+var supported = []string{"markdown", "md", "commonmark", "gfm"}
+
+/*
+   The Markdown writer. Besides "whole", `Parts()` has "body" (the same
+   output).
+*/
+type Writer struct {
+	writers.Base
+}
+
+func (w *Writer) Supports(format string) bool {
+	for _, f := range supported {
+		if f == format {
+			return true
+		}
+	}
+	return false
+}
+
+func (w *Writer) Write(document *rst.Document) (string, error) {
+	w.Document = document
+	translator := &Translator{}
+	translator.Init(document)
+	if err := rst.Walkabout(document, translator); err != nil {
+		return "", err
+	}
+	w.Output = strings.Join(translator.Body, "")
+	w.AssembleParts()
+	w.SetPart("body", w.Output)
+	return w.Output, nil
+}
+
+var specialCharacters = strings.NewReplacer(
+	"\\", `\\`,
+	"`", "\\`",
+	"*", `\*`,
+	"_", `\_`,
+	"[", `\[`,
+	"]", `\]`,
+	"<", `\<`,
+	">", `\>`,
+	"$", `\$`,
+	"~", `\~`,
+	"&", `\&`,
+	"\n", " ",
+)
+
+// Line starts which would begin a block (heading, list, quote, ...).
+var blockStart = regexp.MustCompile(`^(?:[#+=-]|\d+[.)])`)
+
+/*
+   Escape the Markdown special characters of `text`. Newlines are replaced
+   by spaces: Markdown would read some line starts as block markup. If
+   `lineStart`, `text` starts a line.
+*/
+func Escape(text string, lineStart bool) string {
+	text = specialCharacters.Replace(text)
+	if lineStart {
+		if loc := blockStart.FindStringIndex(text); loc != nil {
+			text = text[:loc[1]-1] + `\` + text[loc[1]-1:]
+		}
+	}
+	return text
+}
+
+// Return `uri` as a link destination: spaces are percent-encoded.
+func destination(uri string) string {
+	return strings.Replace(uri, " ", "%20", -1)
+}
+
+var backtickRun = regexp.MustCompile("`+")
+
+// Return a run of backticks longer than the runs in `text`, at least `min`.
+func fence(text string, min int) string {
+	n := min
+	for _, run := range backtickRun.FindAllString(text, -1) {
+		if len(run) >= n {
+			n = len(run) + 1
+		}
+	}
+	return strings.Repeat("`", n)
+}
+
+// A bullet or enumerated list being translated.
+type list struct {
+	enumerated bool
+	next       int
+	suffix     string
+	items      []string
+}
+
+// A GFM table being translated: the cells of the header and body rows.
+type table struct {
+	head   [][]string
+	rows   [][]string
+	inHead bool
+}
+
+/*
+   Translates the document tree to Markdown. Visit and depart methods are
+   called by `rst.Walkabout()`.
+
+   Blocks nested in list items, block quotes and footnotes are translated
+   into their own buffer, indented or prefixed when the container is
+   departed.
+*/
+type Translator struct {
+	document *rst.Document
+
+	writers.Output
+
+	lists        []*list
+	tables       []*table
+	sectionLevel int
+	// Heading level of the top sections: 2 below a document title.
+	initialLevel int
+}
+
+func (t *Translator) Init(document *rst.Document) {
+	t.document = document
+	t.initialLevel = 1
+}
+
+func (t *Translator) UnknownVisit(node rst.Node) error {
+	return &NotImplementedError{fmt.Sprintf("visiting unknown node type: %s", node.TagName())}
+}
+
+func (t *Translator) UnknownDeparture(node rst.Node) error {
+	return &NotImplementedError{fmt.Sprintf("departing unknown node type: %s", node.TagName())}
+}
+
+// Report the lossy conversion of `node`.
+func (t *Translator) lossy(node rst.Node, message string) {
+	t.document.Reporter().Warning("Markdown: "+message, node.Source(), node.Line())
+}
+
+/*
+   Write `node` as an HTML block. Empty lines are replaced by empty HTML
+   comments: an empty line would end the HTML block.
+*/
+func (t *Translator) fallback(node rst.Node) error {
+	text, err := html.TranslateNode(t.document, node)
+	if err != nil {
+		return err
+	}
+	lines := strings.Split(strings.TrimRight(text, "\n"), "\n")
+	for i, line := range lines {
+		if strings.TrimSpace(line) == "" {
+			lines[i] = "<!-- -->"
+		}
+	}
+	t.StartBlock()
+	t.Write(strings.Join(lines, "\n"), "\n")
+	return &rst.SkipNode{}
+}
+
+// Write `node` as inline HTML.
+func (t *Translator) inlineFallback(node rst.Node) error {
+	text, err := html.TranslateNode(t.document, node)
+	if err != nil {
+		return err
+	}
+	t.Write(strings.Replace(strings.TrimRight(text, "\n"), "\n", " ", -1))
+	return &rst.SkipNode{}
+}
+
+// Is `node` the first child of its parent?
+func isFirstChild(node rst.Node) bool {
+	return node.Parent().Index(node) == 0
+}
+
+func (t *Translator) VisitText(node *rst.Text) {
+	text := Escape(node.AsText(), isFirstChild(node) && node.Parent().TagName() == "paragraph")
+	if len(t.tables) > 0 {
+		text = strings.Replace(text, "|", `\|`, -1)
+	}
+	t.Write(text)
+}
+
+func (t *Translator) DepartText(node *rst.Text) {}
+
+func (t *Translator) VisitAbbreviation(node *rst.Element) error {
+	return t.inlineFallback(node)
+}
+
+func (t *Translator) VisitAcronym(node *rst.Element) error {
+	return t.inlineFallback(node)
+}
+
+func (t *Translator) VisitAddress(node *rst.Element) error {
+	return t.fallback(node)
+}
+
+func (t *Translator) VisitAdmonition(node *rst.Element) error {
+	return t.fallback(node)
+}
+
+func (t *Translator) VisitAttention(node *rst.Element) error {
+	return t.fallback(node)
+}
+
+func (t *Translator) VisitCaution(node *rst.Element) error {
+	return t.fallback(node)
+}
+
+func (t *Translator) VisitDanger(node *rst.Element) error {
+	return t.fallback(node)
+}
+
+func (t *Translator) VisitError(node *rst.Element) error {
+	return t.fallback(node)
+}
+
+func (t *Translator) VisitHint(node *rst.Element) error {
+	return t.fallback(node)
+}
+
+func (t *Translator) VisitImportant(node *rst.Element) error {
+	return t.fallback(node)
+}
+
+func (t *Translator) VisitNote(node *rst.Element) error {
+	return t.fallback(node)
+}
+
+func (t *Translator) VisitTip(node *rst.Element) error {
+	return t.fallback(node)
+}
+
+func (t *Translator) VisitWarning(node *rst.Element) error {
+	return t.fallback(node)
+}
+
+func (t *Translator) VisitAttribution(node *rst.Element) {
+	t.StartBlock()
+	t.Write("— ")
+}
+
+func (t *Translator) DepartAttribution(node *rst.Element) {
+	t.Write("\n")
+}
+
+func (t *Translator) VisitBlockQuote(node *rst.Element) {
+	t.PushBuffer()
+}
+
+func (t *Translator) DepartBlockQuote(node *rst.Element) {
+	content := t.PopBuffer()
+	t.StartBlock()
+	t.Write(writers.PrefixLines(content, "> ", "> "))
+}
+
+func (t *Translator) VisitBulletList(node *rst.Element) {
+	t.lists = append(t.lists, &list{})
+}
+
+func (t *Translator) DepartBulletList(node *rst.Element) {
+	t.departList(node)
+}
+
+/*
+   Write the items of the list, without empty lines between them if every
+   item is a single paragraph.
+*/
+func (t *Translator) departList(node *rst.Element) {
+	l := t.lists[len(t.lists)-1]
+	t.lists = t.lists[:len(t.lists)-1]
+	separator := ""
+	for _, child := range node.Children() {
+		if item := child.(*rst.Element); item.Len() != 1 || item.Children()[0].TagName() != "paragraph" {
+			separator = "\n"
+		}
+	}
+	t.StartBlock()
+	t.Write(strings.Join(l.items, separator))
+}
+
+func (t *Translator) VisitCaption(node *rst.Element)  {}
+func (t *Translator) DepartCaption(node *rst.Element) {}
+
+// Citations are written as footnotes.
+func (t *Translator) VisitCitation(node *rst.Element) {
+	t.VisitFootnote(node)
+}
+
+func (t *Translator) DepartCitation(node *rst.Element) {
+	t.DepartFootnote(node)
+}
+
+func (t *Translator) VisitCitationReference(node *rst.Element) error {
+	t.Write("[^", node.AsText(), "]")
+	return &rst.SkipNode{}
+}
+
+func (t *Translator) VisitClassifier(node *rst.Element) error {
+	return t.fallback(node)
+}
+
+func (t *Translator) VisitColspec(node *rst.Element) error {
+	return &rst.SkipNode{}
+}
+
+func (t *Translator) VisitComment(node *rst.Element) error {
+	t.StartBlock()
+	t.Write("<!-- ", strings.Replace(node.AsText(), "--", "- -", -1), " -->\n")
+	return &rst.SkipNode{}
+}
+
+func (t *Translator) VisitCompound(node *rst.Element)  {}
+func (t *Translator) DepartCompound(node *rst.Element) {}
+
+func (t *Translator) VisitContainer(node *rst.Element)  {}
+func (t *Translator) DepartContainer(node *rst.Element) {}
+
+func (t *Translator) VisitDecoration(node *rst.Element) error {
+	if node.Len() > 0 {
+		t.lossy(node, "header and footer dropped.")
+	}
+	return &rst.SkipNode{}
+}
+
+func (t *Translator) VisitDefinitionList(node *rst.Element) error {
+	return t.fallback(node)
+}
+
+func (t *Translator) VisitDocinfo(node *rst.Element) error {
+	return t.fallback(node)
+}
+
+func (t *Translator) VisitDoctestBlock(node *rst.Element) error {
+	t.writeCodeBlock(node.AsText(), "pycon")
+	return &rst.SkipNode{}
+}
+
+func (t *Translator) VisitDocument(node *rst.Element)  {}
+func (t *Translator) DepartDocument(node *rst.Element) {}
+
+func (t *Translator) VisitEmphasis(node *rst.Element) {
+	t.Write("*")
+}
+
+func (t *Translator) DepartEmphasis(node *rst.Element) {
+	t.Write("*")
+}
+
+func (t *Translator) VisitEntry(node *rst.Element) {
+	t.PushBuffer()
+}
+
+func (t *Translator) DepartEntry(node *rst.Element) {
+	cell := strings.TrimSpace(t.PopBuffer())
+	tbl := t.tables[len(t.tables)-1]
+	rows := &tbl.rows
+	if tbl.inHead {
+		rows = &tbl.head
+	}
+	row := &(*rows)[len(*rows)-1]
+	*row = append(*row, cell)
+}
+
+/*
+   CommonMark has arabic numbers only, followed by "." or ")": other
+   enumerations are reported.
+*/
+func (t *Translator) VisitEnumeratedList(node *rst.Element) {
+	l := &list{enumerated: true, next: 1, suffix: "."}
+	if start, err := strconv.Atoi(node.Get("start")); err == nil {
+		l.next = start
+	}
+	enumtype, prefix, suffix := node.Get("enumtype"), node.Get("prefix"), node.Get("suffix")
+	if suffix == ")" {
+		l.suffix = ")"
+	}
+	if enumtype != "" && enumtype != "arabic" || prefix != "" || suffix != "" && suffix != l.suffix {
+		t.lossy(node, "enumeration \""+prefix+enumtype+suffix+"\" written as arabic numbers.")
+	}
+	t.lists = append(t.lists, l)
+}
+
+func (t *Translator) DepartEnumeratedList(node *rst.Element) {
+	t.departList(node)
+}
+
+func (t *Translator) VisitFieldList(node *rst.Element) error {
+	return t.fallback(node)
+}
+
+func (t *Translator) VisitFigure(node *rst.Element) error {
+	return t.fallback(node)
+}
+
+func (t *Translator) VisitFooter(node *rst.Element) error {
+	return &rst.SkipNode{}
+}
+
+func (t *Translator) VisitFootnote(node *rst.Element) {
+	t.PushBuffer()
+}
+
+// Write the footnote definition, the content indented below its label.
+func (t *Translator) DepartFootnote(node *rst.Element) {
+	content := t.PopBuffer()
+	label := ""
+	if node.Len() > 0 && node.Children()[0].TagName() == "label" {
+		label = node.Children()[0].AsText()
+	}
+	t.StartBlock()
+	t.Write(writers.PrefixLines(content, "[^"+label+"]: ", "    "))
+}
+
+func (t *Translator) VisitFootnoteReference(node *rst.Element) error {
+	t.Write("[^", node.AsText(), "]")
+	return &rst.SkipNode{}
+}
+
+func (t *Translator) VisitGenerated(node *rst.Element)  {}
+func (t *Translator) DepartGenerated(node *rst.Element) {}
+
+func (t *Translator) VisitHeader(node *rst.Element) error {
+	return &rst.SkipNode{}
+}
+
+// Images with size or alignment are written as HTML.
+func (t *Translator) VisitImage(node *rst.Element) error {
+	for _, name := range []string{"width", "height", "scale", "align"} {
+		if node.HasAttr(name) {
+			if node.Parent().Is(rst.TextElementClass) {
+				return t.inlineFallback(node)
+			}
+			return t.fallback(node)
+		}
+	}
+	alt := node.Get("alt")
+	if !node.HasAttr("alt") {
+		alt = node.Get("uri")
+	}
+	image := "![" + Escape(alt, false) + "](" + destination(node.Get("uri")) + ")"
+	if !node.Parent().Is(rst.TextElementClass) && node.Parent().TagName() != "reference" {
+		t.StartBlock()
+		image += "\n"
+	}
+	t.Write(image)
+	return &rst.SkipNode{}
+}
+
+// Inline elements with classes are written as HTML spans.
+func (t *Translator) VisitInline(node *rst.Element) {
+	if len(node.Classes) == 0 {
+		t.Push("")
+		return
+	}
+	t.Write(`<span class="`, html.Attval(strings.Join(node.Classes, " ")), `">`)
+	t.Push("</span>")
+}
+
+func (t *Translator) DepartInline(node *rst.Element) {
+	t.Write(t.Pop())
+}
+
+// Labels are written by the footnote or citation.
+func (t *Translator) VisitLabel(node *rst.Element) error {
+	return &rst.SkipNode{}
+}
+
+func (t *Translator) VisitLegend(node *rst.Element)  {}
+func (t *Translator) DepartLegend(node *rst.Element) {}
+
+func (t *Translator) VisitLine(node *rst.Element) {
+	if !isFirstChild(node) {
+		t.Write("\\\n")
+	}
+}
+
+func (t *Translator) DepartLine(node *rst.Element) {}
+
+// Line blocks are paragraphs with hard line breaks, nested ones HTML.
+func (t *Translator) VisitLineBlock(node *rst.Element) error {
+	for _, child := range node.Children() {
+		if child.TagName() == "line_block" {
+			return t.fallback(node)
+		}
+	}
+	t.StartBlock()
+	return nil
+}
+
+func (t *Translator) DepartLineBlock(node *rst.Element) {
+	t.Write("\n")
+}
+
+func (t *Translator) VisitListItem(node *rst.Element) {
+	t.PushBuffer()
+}
+
+func (t *Translator) DepartListItem(node *rst.Element) {
+	content := t.PopBuffer()
+	l := t.lists[len(t.lists)-1]
+	marker := "-"
+	if l.enumerated {
+		marker = strconv.Itoa(l.next) + l.suffix
+		l.next++
+	}
+	if content == "" {
+		l.items = append(l.items, marker+"\n")
+		return
+	}
+	l.items = append(l.items, writers.PrefixLines(content, marker+" ", strings.Repeat(" ", len(marker)+1)))
+}
+
+func (t *Translator) VisitLiteral(node *rst.Element) error {
+	text := strings.Replace(node.AsText(), "\n", " ", -1)
+	delimiter := fence(text, 1)
+	if strings.HasPrefix(text, "`") || strings.HasSuffix(text, "`") {
+		text = " " + text + " "
+	}
+	t.Write(delimiter, text, delimiter)
+	return &rst.SkipNode{}
+}
+
+/*
+   Literal blocks are fenced code blocks, with the language of code blocks.
+   Parsed literal blocks (with inline markup) are written as HTML.
+*/
+func (t *Translator) VisitLiteralBlock(node *rst.Element) error {
+	language := ""
+	if len(node.Classes) > 1 && node.Classes[0] == "code" {
+		language = node.Classes[1]
+	} else {
+		for _, child := range node.Children() {
+			if _, ok := child.(*rst.Text); !ok {
+				return t.fallback(node)
+			}
+		}
+	}
+	t.writeCodeBlock(node.AsText(), language)
+	return &rst.SkipNode{}
+}
+
+func (t *Translator) writeCodeBlock(code, language string) {
+	delimiter := fence(code, 3)
+	t.StartBlock()
+	t.Write(delimiter, language, "\n", strings.TrimRight(code, "\n"), "\n", delimiter, "\n")
+}
+
+// Math uses the GFM syntax.
+func (t *Translator) VisitMath(node *rst.Element) error {
+	t.Write("$", node.AsText(), "$")
+	return &rst.SkipNode{}
+}
+
+func (t *Translator) VisitMathBlock(node *rst.Element) error {
+	t.writeCodeBlock(node.AsText(), "math")
+	return &rst.SkipNode{}
+}
+
+func (t *Translator) VisitOptionList(node *rst.Element) error {
+	return t.fallback(node)
+}
+
+func (t *Translator) VisitParagraph(node *rst.Element) {
+	t.StartBlock()
+}
+
+func (t *Translator) DepartParagraph(node *rst.Element) {
+	t.Write("\n")
+}
+
+func (t *Translator) VisitPending(node *rst.Element) error {
+	return &rst.SkipNode{}
+}
+
+func (t *Translator) VisitProblematic(node *rst.Element) {
+	if node.HasAttr("refid") {
+		t.Write("[")
+		t.Push("](#" + node.Get("refid") + ")")
+		return
+	}
+	t.Push("")
+}
+
+func (t *Translator) DepartProblematic(node *rst.Element) {
+	t.Write(t.Pop())
+}
+
+// Raw HTML is valid Markdown; raw content for other formats is dropped.
+func (t *Translator) VisitRaw(node *rst.Element) error {
+	if !writers.RawFormatMatches(node, "markdown", "html") {
+		t.lossy(node, "raw content for format \""+node.Get("format")+"\" dropped.")
+		return &rst.SkipNode{}
+	}
+	if node.Parent().Is(rst.TextElementClass) {
+		t.Write(node.AsText())
+	} else {
+		t.StartBlock()
+		t.Write(strings.TrimRight(node.AsText(), "\n"), "\n")
+	}
+	return &rst.SkipNode{}
+}
+
+func (t *Translator) VisitReference(node *rst.Element) {
+	switch {
+	case node.HasAttr("refuri"):
+		uri := node.Get("refuri")
+		if node.AsText() == uri && node.Len() == 1 && !strings.ContainsAny(uri, "<> ") {
+			// standalone hyperlink
+			t.Write("<")
+			t.Push(">")
+		} else {
+			t.Write("[")
+			t.Push("](" + destination(uri) + ")")
+		}
+	case node.HasAttr("refid"):
+		t.Write("[")
+		t.Push("](#" + node.Get("refid") + ")")
+	default:
+		t.Push("")
+	}
+	t.PushBuffer()
+}
+
+func (t *Translator) DepartReference(node *rst.Element) {
+	text := t.PopBuffer()
+	if strings.HasSuffix(t.Peek(), ">") {
+		// the URI itself, unescaped
+		text = node.AsText()
+	}
+	t.Write(text)
+	t.Write(t.Pop())
+}
+
+func (t *Translator) VisitRow(node *rst.Element) {
+	tbl := t.tables[len(t.tables)-1]
+	if tbl.inHead {
+		tbl.head = append(tbl.head, nil)
+	} else {
+		tbl.rows = append(tbl.rows, nil)
+	}
+}
+
+func (t *Translator) DepartRow(node *rst.Element) {}
+
+func (t *Translator) VisitRubric(node *rst.Element) error {
+	return t.fallback(node)
+}
+
+func (t *Translator) VisitSection(node *rst.Element) {
+	t.sectionLevel++
+}
+
+func (t *Translator) DepartSection(node *rst.Element) {
+	t.sectionLevel--
+}
+
+func (t *Translator) VisitSidebar(node *rst.Element) error {
+	return t.fallback(node)
+}
+
+func (t *Translator) VisitStrong(node *rst.Element) {
+	t.Write("**")
+}
+
+func (t *Translator) DepartStrong(node *rst.Element) {
+	t.Write("**")
+}
+
+func (t *Translator) VisitSubscript(node *rst.Element) {
+	t.Write("<sub>")
+}
+
+func (t *Translator) DepartSubscript(node *rst.Element) {
+	t.Write("</sub>")
+}
+
+func (t *Translator) VisitSubstitutionDefinition(node *rst.Element) error {
+	return &rst.SkipNode{}
+}
+
+func (t *Translator) VisitSubstitutionReference(node *rst.Element) error {
+	return t.UnknownVisit(node)
+}
+
+func (t *Translator) VisitSubtitle(node *rst.Element) error {
+	if node.Parent().TagName() == "document" {
+		t.lossy(node, "document subtitle written as emphasized paragraph.")
+		t.StartBlock()
+		t.Write("*")
+		t.Push("*\n")
+		return nil
+	}
+	return t.fallback(node)
+}
+
+func (t *Translator) DepartSubtitle(node *rst.Element) {
+	t.Write(t.Pop())
+}
+
+func (t *Translator) VisitSuperscript(node *rst.Element) {
+	t.Write("<sup>")
+}
+
+func (t *Translator) DepartSuperscript(node *rst.Element) {
+	t.Write("</sup>")
+}
+
+func (t *Translator) VisitSystemMessage(node *rst.Element) error {
+	return t.fallback(node)
+}
+
+/*
+   Tables with a single header row and cells of a single paragraph are GFM
+   tables, other ones HTML.
+*/
+func (t *Translator) VisitTable(node *rst.Element) error {
+	if !isSimpleTable(node) {
+		return t.fallback(node)
+	}
+	t.tables = append(t.tables, &table{})
+	return nil
+}
+
+func isSimpleTable(node *rst.Element) bool {
+	var tgroup *rst.Element
+	for _, child := range node.Children() {
+		e, ok := child.(*rst.Element)
+		if !ok || e.TagName() != "tgroup" || tgroup != nil {
+			// title or several groups
+			return false
+		}
+		tgroup = e
+	}
+	if tgroup == nil {
+		return false
+	}
+	headRows := 0
+	for _, part := range tgroup.Children() {
+		if part.TagName() == "thead" {
+			headRows += part.(*rst.Element).Len()
+		}
+	}
+	if headRows != 1 {
+		return false
+	}
+	for _, n := range tgroup.Traverse(nil) {
+		if n.TagName() != "entry" {
+			continue
+		}
+		entry := n.(*rst.Element)
+		if entry.HasAttr("morecols") || entry.HasAttr("morerows") || entry.Len() > 1 ||
+			entry.Len() == 1 && entry.Children()[0].TagName() != "paragraph" {
+			return false
+		}
+	}
+	return true
+}
+
+func (t *Translator) DepartTable(node *rst.Element) {
+	tbl := t.tables[len(t.tables)-1]
+	t.tables = t.tables[:len(t.tables)-1]
+	t.StartBlock()
+	head := tbl.head[0]
+	t.Write("| ", strings.Join(head, " | "), " |\n")
+	t.Write(strings.Repeat("| --- ", len(head)), "|\n")
+	for _, row := range tbl.rows {
+		t.Write("| ", strings.Join(row, " | "), " |\n")
+	}
+}
+
+// Internal targets are HTML anchors.
+func (t *Translator) VisitTarget(node *rst.Element) {
+	if node.HasAttr("refuri") || node.HasAttr("refid") || node.HasAttr("refname") || len(node.Ids) == 0 {
+		return
+	}
+	anchors := ""
+	for _, id := range node.Ids {
+		anchors += `<a id="` + html.Attval(id) + `"></a>`
+	}
+	if node.Parent().Is(rst.TextElementClass) {
+		t.Write(anchors)
+	} else {
+		t.StartBlock()
+		t.Write(anchors, "\n")
+	}
+}
+
+func (t *Translator) DepartTarget(node *rst.Element) {}
+
+func (t *Translator) VisitTbody(node *rst.Element)  {}
+func (t *Translator) DepartTbody(node *rst.Element) {}
+
+func (t *Translator) VisitTgroup(node *rst.Element)  {}
+func (t *Translator) DepartTgroup(node *rst.Element) {}
+
+func (t *Translator) VisitThead(node *rst.Element) {
+	t.tables[len(t.tables)-1].inHead = true
+}
+
+func (t *Translator) DepartThead(node *rst.Element) {
+	t.tables[len(t.tables)-1].inHead = false
+}
+
+func (t *Translator) VisitTitle(node *rst.Element) error {
+	level := 1
+	switch node.Parent().TagName() {
+	case "document":
+		t.initialLevel = 2
+	case "section":
+		level = t.sectionLevel + t.initialLevel - 1
+	default:
+		// titles of topics, admonitions etc. are written by the HTML
+		// fallback
+		return &rst.SkipNode{}
+	}
+	t.StartBlock()
+	if level > 6 {
+		t.lossy(node, "section level "+strconv.Itoa(level)+" written as strong paragraph.")
+		t.Write("**")
+		t.Push("**\n")
+		return nil
+	}
+	t.Write(strings.Repeat("#", level), " ")
+	t.Push("\n")
+	return nil
+}
+
+func (t *Translator) DepartTitle(node *rst.Element) {
+	t.Write(t.Pop())
+}
+
+func (t *Translator) VisitTitleReference(node *rst.Element) {
+	t.Write("*")
+}
+
+func (t *Translator) DepartTitleReference(node *rst.Element) {
+	t.Write("*")
+}
+
+func (t *Translator) VisitTopic(node *rst.Element) error {
+	return t.fallback(node)
+}
+
+func (t *Translator) VisitTransition(node *rst.Element) error {
+	t.StartBlock()
+	t.Write("* * *\n")
+	return &rst.SkipNode{}
+}
+
+type NotImplementedError struct {
+	msg string
+}
+
+func (e *NotImplementedError) Error() string {
+	return e.msg
+}
@@ -0,0 +1,109 @@
+package markdown
+
+import (
+	"testing"
+
+	rst "github.com/siongui/go-rst"
+	"github.com/siongui/go-rst/parsers/docutilsxml"
+)
+
+const input = `<document source="test.rst">
+    <title>Title</title>
+    <section ids="usage">
+        <title>Usage</title>
+        <paragraph>1. Use <emphasis>all</emphasis> of ` + "`x_y`" + ` <literal>a` + "`" + `b</literal>, see <reference refuri="https://example.com/docs">docs</reference> or <reference refuri="https://example.com">https://example.com</reference><footnote_reference refid="f1">1</footnote_reference></paragraph>
+        <bullet_list>
+            <list_item><paragraph>first</paragraph></list_item>
+            <list_item><paragraph>second &amp;amp; <image alt="an image" uri="my image.png"/></paragraph></list_item>
+        </bullet_list>
+        <enumerated_list enumtype="loweralpha" suffix=".">
+            <list_item><paragraph>one</paragraph></list_item>
+            <list_item><paragraph>two</paragraph><paragraph>more</paragraph></list_item>
+        </enumerated_list>
+        <literal_block classes="code go">x := ` + "`a`" + `
+</literal_block>
+        <table>
+            <tgroup cols="2">
+                <thead><row><entry><paragraph>A</paragraph></entry><entry><paragraph>B</paragraph></entry></row></thead>
+                <tbody><row><entry><paragraph>a|b</paragraph></entry><entry/></row></tbody>
+            </tgroup>
+        </table>
+        <block_quote>
+            <paragraph>Quoted
+            text.</paragraph>
+            <attribution>Someone</attribution>
+        </block_quote>
+        <definition_list>
+            <definition_list_item><term>term</term><definition><paragraph>Def.</paragraph></definition></definition_list_item>
+        </definition_list>
+        <raw format="latex">\newpage</raw>
+        <footnote><label>1</label><paragraph>A note.</paragraph><paragraph>Second paragraph.</paragraph></footnote>
+    </section>
+</document>
+`
+
+func TestWriter(t *testing.T) {
+	settings := &rst.Settings{}
+	settings.Init()
+	settings.WarningStream = nil
+	document, err := docutilsxml.ParseDocument(input, "", settings)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var messages []string
+	document.Reporter().Attach(func(message *rst.Element) {
+		messages = append(messages, message.Children()[0].AsText())
+	})
+
+	writer := &Writer{}
+	output, err := writer.Write(document)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := "# Title\n" +
+		"\n" +
+		"## Usage\n" +
+		"\n" +
+		"1\\. Use *all* of \\`x\\_y\\` ``a`b``, see [docs](https://example.com/docs) or <https://example.com>[^1]\n" +
+		"\n" +
+		"- first\n" +
+		"- second \\&amp; ![an image](my%20image.png)\n" +
+		"\n" +
+		"1. one\n" +
+		"\n" +
+		"2. two\n" +
+		"\n" +
+		"   more\n" +
+		"\n" +
+		"```go\n" +
+		"x := `a`\n" +
+		"```\n" +
+		"\n" +
+		"| A | B |\n" +
+		"| --- | --- |\n" +
+		"| a\\|b |  |\n" +
+		"\n" +
+		"> Quoted text.\n" +
+		">\n" +
+		"> — Someone\n" +
+		"\n" +
+		"<dl class=\"simple\">\n" +
+		"<dt>term</dt>\n" +
+		"<dd>Def.</dd>\n" +
+		"</dl>\n" +
+		"\n" +
+		"[^1]: A note.\n" +
+		"\n" +
+		"    Second paragraph.\n"
+	if output != expected {
+		t.Errorf("unexpected output:\n%s", output)
+	}
+	if len(messages) != 2 ||
+		messages[0] != "Markdown: enumeration \"loweralpha.\" written as arabic numbers." ||
+		messages[1] != "Markdown: raw content for format \"latex\" dropped." {
+		t.Errorf("unexpected messages: %q", messages)
+	}
+	if !writer.Supports("gfm") || writer.Supports("html") {
+		t.Error("wrong supported formats")
+	}
+}
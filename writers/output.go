@@ -0,0 +1,99 @@
+package writers
+
+import (
+	"strings"
+)
+
+/*
+   Output of the translators writing nested blocks of text (plain text,
+   reStructuredText, Markdown), to embed in translator types.
+
+   Blocks nested in containers (list items, directives, ...) are written
+   to their own buffer, prefixed or indented when the container is
+   departed. The context stack holds closing strings and labels, pushed in
+   visit methods and popped in the corresponding depart methods.
+*/
+type Output struct {
+	Body []string
+	// Stack of the buffers started by PushBuffer(), written above the body.
+	outs    []*[]string
+	context []string
+}
+
+// Return the buffer written to.
+func (o *Output) out() *[]string {
+	if len(o.outs) == 0 {
+		return &o.Body
+	}
+	return o.outs[len(o.outs)-1]
+}
+
+// Append `strs` to the buffer started last (the body if none).
+func (o *Output) Write(strs ...string) {
+	out := o.out()
+	*out = append(*out, strs...)
+}
+
+// Return the strings written to the buffer started last.
+func (o *Output) Written() []string {
+	return *o.out()
+}
+
+// Start a buffer for the content of a container.
+func (o *Output) PushBuffer() {
+	o.outs = append(o.outs, &[]string{})
+}
+
+// Return the content of the buffer started last, and drop it.
+func (o *Output) PopBuffer() string {
+	out := o.outs[len(o.outs)-1]
+	o.outs = o.outs[:len(o.outs)-1]
+	return strings.Join(*out, "")
+}
+
+// Separate the following block from the previous one by an empty line.
+func (o *Output) StartBlock() {
+	if len(*o.out()) > 0 {
+		o.Write("\n")
+	}
+}
+
+// Write a block.
+func (o *Output) WriteBlock(text string) {
+	o.StartBlock()
+	o.Write(text)
+}
+
+func (o *Output) Push(value string) {
+	o.context = append(o.context, value)
+}
+
+func (o *Output) Pop() string {
+	value := o.context[len(o.context)-1]
+	o.context = o.context[:len(o.context)-1]
+	return value
+}
+
+// Return the value pushed last, without popping it.
+func (o *Output) Peek() string {
+	return o.context[len(o.context)-1]
+}
+
+/*
+   Prefix the lines of `text` with `first` (the first line) and `rest` (the
+   following ones). Empty lines get the trimmed prefix.
+*/
+func PrefixLines(text, first, rest string) string {
+	lines := strings.Split(strings.TrimRight(text, "\n"), "\n")
+	for i, line := range lines {
+		prefix := rest
+		if i == 0 {
+			prefix = first
+		}
+		if line == "" {
+			prefix = strings.TrimRight(prefix, " ")
+		}
+		lines[i] = prefix + line
+	}
+	return strings.Join(lines, "\n") + "\n"
+}
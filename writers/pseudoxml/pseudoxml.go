@@ -0,0 +1,120 @@
+/*
+Package pseudoxml implements the simple internal document tree Writer of
+Python docutils: it writes indented pseudo-XML, as used in the test suites
+to inspect and compare document trees.
+
+URL of Python source code:
+http://sourceforge.net/p/docutils/code/HEAD/tree/trunk/docutils/docutils/writers/pseudoxml.py
+*/
+package pseudoxml
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+
+	rst "github.com/siongui/go-rst"
+	"github.com/siongui/go-rst/writers"
+)
+
+/*
+   The pseudo-XML writer: the output ("whole" part) is the indented node
+   tree of the document, as `rst.Node.Pformat()` returns it. With the
+   `Detailed` setting, text nodes are shown as "<#text>" elements with
+   quoted lines.
+*/
+type Writer struct {
+	writers.Base
+}
+
+// This writer supports all format-specific elements.
+func (w *Writer) Supports(format string) bool {
+	return true
+}
+
+func (w *Writer) Write(document *rst.Document) (string, error) {
+	w.Document = document
+	if document.Settings().Detailed {
+		w.Output = pformatDetailed(document, "    ", 0)
+	} else {
+		w.Output = document.Pformat("    ", 0)
+	}
+	w.AssembleParts()
+	return w.Output, nil
+}
+
+// Like `rst.Node.Pformat()`, but show text nodes in detail.
+func pformatDetailed(node rst.Node, indent string, level int) string {
+	prefix := strings.Repeat(indent, level)
+	switch n := node.(type) {
+	case *rst.Document:
+		return pformatDetailed(&n.Element, indent, level)
+	case *rst.Element:
+		result := prefix + n.Starttag() + "\n"
+		for _, child := range n.Children() {
+			result += pformatDetailed(child, indent, level+1)
+		}
+		return result
+	}
+	result := prefix + "<#text>\n"
+	for _, line := range splitLinesKeepEnds(node.AsText()) {
+		result += prefix + indent + pythonRepr(line) + "\n"
+	}
+	return result
+}
+
+// Split `text` into lines, keeping the line ends.
+func splitLinesKeepEnds(text string) []string {
+	var lines []string
+	for text != "" {
+		i := strings.IndexAny(text, "\r\n")
+		if i < 0 {
+			lines = append(lines, text)
+			break
+		}
+		end := i + 1
+		if text[i] == '\r' && end < len(text) && text[end] == '\n' {
+			end++
+		}
+		lines = append(lines, text[:end])
+		text = text[end:]
+	}
+	return lines
+}
+
+/*
+   Return the Python representation of the string `s`: single quotes,
+   unless `s` contains single but no double quotes, and escaped special
+   and non-printable characters.
+*/
+func pythonRepr(s string) string {
+	quote := '\''
+	if strings.ContainsRune(s, '\'') && !strings.ContainsRune(s, '"') {
+		quote = '"'
+	}
+	var b strings.Builder
+	b.WriteRune(quote)
+	for _, r := range s {
+		switch {
+		case r == quote || r == '\\':
+			b.WriteRune('\\')
+			b.WriteRune(r)
+		case r == '\n':
+			b.WriteString(`\n`)
+		case r == '\r':
+			b.WriteString(`\r`)
+		case r == '\t':
+			b.WriteString(`\t`)
+		case unicode.IsPrint(r):
+			b.WriteRune(r)
+		case r < 0x100:
+			fmt.Fprintf(&b, `\x%02x`, r)
+		case r < 0x10000:
+			fmt.Fprintf(&b, `\u%04x`, r)
+		default:
+			fmt.Fprintf(&b, `\U%08x`, r)
+		}
+	}
+	b.WriteRune(quote)
+	return b.String()
+}
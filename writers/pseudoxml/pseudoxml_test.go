@@ -0,0 +1,43 @@
+package pseudoxml
+
+import (
+	"testing"
+
+	rst "github.com/siongui/go-rst"
+)
+
+func TestWriter(t *testing.T) {
+	settings := &rst.Settings{}
+	settings.Init()
+	document := rst.NewDocument("test.rst", settings)
+	paragraph := &rst.Element{}
+	paragraph.Init("paragraph", "", "It's a\n\ttest.")
+	paragraph.Names = append(paragraph.Names, "a name")
+	document.Append(paragraph)
+
+	writer := &Writer{}
+	output, err := writer.Write(document)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := `<document source="test.rst">
+    <paragraph names="a\ name">
+        It's a
+        	test.
+`
+	if output != expected || writer.Parts()["whole"] != expected {
+		t.Error("unexpected output:\n" + output)
+	}
+
+	settings.Detailed = true
+	output, _ = writer.Write(document)
+	expected = `<document source="test.rst">
+    <paragraph names="a\ name">
+        <#text>
+            "It's a\n"
+            '\ttest.'
+`
+	if output != expected {
+		t.Error("unexpected detailed output:\n" + output)
+	}
+}
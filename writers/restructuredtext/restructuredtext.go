@@ -0,0 +1,1782 @@
+/*
+Package restructuredtext implements a reStructuredText writer (no Python
+docutils counterpart): it serializes the document tree back to canonical
+reStructuredText, for programs editing reST documents.
+
+The output is normalized: sections get consistent adornments (see the
+`SectionAdornments` setting), bullet lists use "-", tables are regenerated
+as grid tables sized to their content, and paragraphs are optionally
+re-wrapped (`WrapWidth`). Directives are written for the elements they
+produce (admonitions, images, code blocks, ...), and comments are kept, so
+that parsing the output gives an equivalent document tree. Custom
+interpreted text roles are declared at the top of the document.
+*/
+package restructuredtext
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	rst "github.com/siongui/go-rst"
+	"github.com/siongui/go-rst/writers"
+)
+
+// Formats this writer supports.
+var supported = []string{"rst", "restructuredtext", "rest"}
+
+// The writer. Besides "whole", `Parts()` has "body" (the same output).
+type Writer struct {
+	writers.Base
+}
+
+func (w *Writer) Supports(format string) bool {
+	for _, f := range supported {
+		if f == format {
+			return true
+		}
+	}
+	return false
+}
+
+func (w *Writer) Write(document *rst.Document) (string, error) {
+	w.Document = document
+	translator := &Translator{}
+	translator.Init(document)
+	if err := rst.Walkabout(document, translator); err != nil {
+		return "", err
+	}
+	w.Output = strings.Join(translator.Body, "")
+	w.AssembleParts()
+	w.SetPart("body", w.Output)
+	return w.Output, nil
+}
+
+// Placeholder of spaces paragraphs must not be wrapped at.
+const nbsp = '\x00'
+
+/*
+   Escape the characters of `text` which would start or end inline markup:
+   asterisks, backquotes, vertical bars, and underscores ending a word.
+*/
+func Escape(text string) string {
+	var b strings.Builder
+	runes := []rune(text)
+	for i, r := range runes {
+		switch r {
+		case '\\', '*', '`', '|':
+			b.WriteRune('\\')
+		case '_':
+			if i+1 == len(runes) || !isWordChar(runes[i+1]) {
+				b.WriteRune('\\')
+			}
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
+
+func isWordChar(r rune) bool {
+	return unicode.IsLetter(r) || unicode.IsDigit(r)
+}
+
+// Can inline markup start after `r`?
+func isStartBoundary(r rune) bool {
+	return unicode.IsSpace(r) || r == nbsp || strings.ContainsRune("-:/'\"<([{", r) ||
+		unicode.In(r, unicode.Pd, unicode.Po, unicode.Ps, unicode.Pi, unicode.Pf)
+}
+
+// Can inline markup end before `r`?
+func isEndBoundary(r rune) bool {
+	return unicode.IsSpace(r) || strings.ContainsRune("-.,:;!?\\/'\")]}>", r) ||
+		unicode.In(r, unicode.Pd, unicode.Po, unicode.Pe, unicode.Pi, unicode.Pf)
+}
+
+// Return the width of `text` in columns: East Asian wide characters count
+// twice.
+func columnWidth(text string) int {
+	width := 0
+	for _, r := range text {
+		width++
+		if unicode.In(r, unicode.Han, unicode.Hiragana, unicode.Katakana, unicode.Hangul) ||
+			r >= 0xff01 && r <= 0xff60 || r >= 0xffe0 && r <= 0xffe6 {
+			width++
+		}
+	}
+	return width
+}
+
+// Does `line` consist of a repeated punctuation character (an adornment)?
+func isAdornment(line string) bool {
+	line = strings.TrimRight(line, " ")
+	if utf8.RuneCountInString(line) < 2 {
+		return false
+	}
+	first, _ := utf8.DecodeRuneInString(line)
+	if first > unicode.MaxASCII || !unicode.IsPunct(first) && !unicode.IsSymbol(first) {
+		return false
+	}
+	return strings.Trim(line, string(first)) == ""
+}
+
+// Line starts which would be read as block markup.
+var blockStarts = []string{"- ", "* ", "+ ", "• ", "‣ ", "⁃ ", ".. ", ":: ", ">>> ", "| ", "#. "}
+
+// Does `line` start like a list item, explicit markup, field, ...?
+func isBlockStart(line string) bool {
+	for _, start := range blockStarts {
+		if strings.HasPrefix(line+" ", start) {
+			return true
+		}
+	}
+	if isAdornment(line) {
+		return true
+	}
+	// enumerators: "1.", "a)", "(iv)", ...
+	word := strings.SplitN(line, " ", 2)[0]
+	if len(word) > 1 && strings.ContainsAny(word[len(word)-1:], ".)") {
+		enumerator := strings.TrimPrefix(word[:len(word)-1], "(")
+		alnum := enumerator != ""
+		for _, r := range enumerator {
+			if r > unicode.MaxASCII || !isWordChar(r) && r != '#' {
+				alnum = false
+			}
+		}
+		if alnum && (len(enumerator) == 1 || strings.Trim(enumerator, "0123456789") == "" ||
+			strings.Trim(strings.ToLower(enumerator), "ivxlcdm") == "") {
+			return true
+		}
+	}
+	// fields and options
+	if len(line) > 1 && line[0] == ':' && strings.Contains(line[1:], ":") {
+		return true
+	}
+	if len(line) > 1 && strings.ContainsRune("-+/", rune(line[0])) &&
+		(isWordChar(rune(line[1])) || line[1] == '-') {
+		return true
+	}
+	return false
+}
+
+/*
+   Return the text of a paragraph: lines starting like block markup are
+   escaped, a final "::" (literal block marker) too. If `width` > 0, the
+   text is re-wrapped.
+*/
+func formatText(text string, width int) string {
+	if width > 0 {
+		text = wrap(text, width)
+	}
+	lines := strings.Split(text, "\n")
+	for i, line := range lines {
+		if isBlockStart(strings.Replace(line, string(nbsp), " ", -1)) {
+			lines[i] = `\` + line
+		}
+	}
+	text = strings.Join(lines, "\n")
+	if strings.HasSuffix(text, "::") && !strings.HasSuffix(text, `\::`) {
+		text = text[:len(text)-1] + `\:`
+	}
+	return strings.Replace(text, string(nbsp), " ", -1)
+}
+
+// Wrap `text` to lines of at most `width` columns, where possible.
+func wrap(text string, width int) string {
+	var lines []string
+	line := ""
+	for _, word := range strings.Fields(text) {
+		switch {
+		case line == "":
+			line = word
+		case columnWidth(line)+1+columnWidth(word) > width:
+			lines = append(lines, line)
+			line = word
+		default:
+			line += " " + word
+		}
+	}
+	return strings.Join(append(lines, line), "\n")
+}
+
+type directiveOption struct {
+	name  string
+	value string
+}
+
+/*
+   Return the directive `name` with its argument, options and (indented)
+   content.
+*/
+func directive(name, argument string, options []directiveOption, content string) string {
+	text := ".. " + name + "::"
+	if argument != "" {
+		text += " " + argument
+	}
+	text += "\n"
+	for _, option := range options {
+		text += "   :" + option.name + ":"
+		if option.value != "" {
+			text += " " + option.value
+		}
+		text += "\n"
+	}
+	if strings.TrimSpace(content) != "" {
+		text += "\n" + writers.PrefixLines(content, "   ", "   ")
+	}
+	return text
+}
+
+/*
+   Translates the document tree to reStructuredText. Visit and depart
+   methods are called by `rst.Walkabout()`.
+
+   Blocks nested in containers (list items, directives, ...) are translated
+   into their own buffer, indented when the container is departed.
+*/
+type Translator struct {
+	document *rst.Document
+	settings *rst.Settings
+	language *rst.Language
+
+	writers.Output
+
+	// The rendered titles of topics, admonitions, sidebars and tables.
+	titles map[*rst.Element]string
+
+	// Declarations of the custom roles used, by name.
+	roles map[string]string
+
+	// Names of the block-level external targets: references to them are
+	// written as named references.
+	targetNames map[string]bool
+
+	sectionLevel int
+	// Nesting depth of inline markup: reST has no nested inline markup.
+	inlineDepth int
+	lineIndent  int
+	tables      []*writers.Table
+	// The next literal block is introduced by a paragraph ending in "::".
+	literalIntroduced bool
+}
+
+func (t *Translator) Init(document *rst.Document) {
+	t.document = document
+	t.settings = document.Settings()
+	t.language = rst.GetLanguage(t.settings.LanguageCode)
+	t.titles = map[*rst.Element]string{}
+	t.roles = map[string]string{}
+	t.targetNames = map[string]bool{}
+	for _, node := range document.Traverse(rst.ByTag("target")) {
+		target := node.(*rst.Element)
+		if target.HasAttr("refuri") && !target.Parent().Is(rst.TextElementClass) {
+			for _, name := range target.Names {
+				t.targetNames[name] = true
+			}
+		}
+	}
+}
+
+func (t *Translator) UnknownVisit(node rst.Node) error {
+	return &NotImplementedError{fmt.Sprintf("visiting unknown node type: %s", node.TagName())}
+}
+
+func (t *Translator) UnknownDeparture(node rst.Node) error {
+	return &NotImplementedError{fmt.Sprintf("departing unknown node type: %s", node.TagName())}
+}
+
+// Return the last character written to the current buffer, 0 if none.
+func (t *Translator) lastChar() rune {
+	out := t.Written()
+	for i := len(out) - 1; i >= 0; i-- {
+		if out[i] != "" {
+			r, _ := utf8.DecodeLastRuneInString(out[i])
+			return r
+		}
+	}
+	return 0
+}
+
+/*
+   Start inline markup with `start`, separated from a preceding word by an
+   escaped space. Nested markup is dropped.
+*/
+func (t *Translator) openInline(start string) {
+	t.inlineDepth++
+	if t.inlineDepth > 1 {
+		return
+	}
+	if r := t.lastChar(); r != 0 && !isStartBoundary(r) {
+		t.Write(`\` + string(nbsp))
+	}
+	t.Write(start)
+}
+
+// End inline markup with `end`, separated from a following word.
+func (t *Translator) closeInline(node rst.Node, end string) {
+	t.inlineDepth--
+	if t.inlineDepth > 0 {
+		return
+	}
+	t.Write(end)
+	parent := node.Parent()
+	if index := parent.Index(node); index+1 < parent.Len() {
+		next, _ := utf8.DecodeRuneInString(parent.Children()[index+1].AsText())
+		if next != utf8.RuneError && !isEndBoundary(next) {
+			t.Write(`\` + string(nbsp))
+		}
+	}
+}
+
+// Write inline `text` as interpreted text of `role`.
+func (t *Translator) writeRole(node rst.Node, role, text string) {
+	if t.inlineDepth > 0 {
+		t.Write(Escape(text))
+		return
+	}
+	t.openInline(":" + role + ":`")
+	t.Write(strings.Replace(strings.Replace(text, `\`, `\\`, -1), "`", "\\`", -1))
+	t.closeInline(node, "`")
+}
+
+// Return the rendered inline content of `node`.
+func (t *Translator) renderInline(node *rst.Element) (string, error) {
+	t.PushBuffer()
+	for _, child := range node.Children() {
+		if err := rst.Walkabout(child, t); err != nil {
+			t.PopBuffer()
+			return "", err
+		}
+	}
+	return strings.Replace(t.PopBuffer(), string(nbsp), " ", -1), nil
+}
+
+/*
+   Is a block quote (or other indented block) after `node` read as its
+   content? Empty comments separate them.
+*/
+func needsSeparator(node rst.Node) bool {
+	parent := node.Parent()
+	index := parent.Index(node)
+	if index == 0 {
+		return false
+	}
+	switch prev := parent.Children()[index-1]; prev.TagName() {
+	case "paragraph":
+		return strings.HasSuffix(prev.AsText(), "::")
+	case "title", "subtitle", "transition", "section", "literal_block", "doctest_block":
+		return false
+	}
+	return true
+}
+
+func (t *Translator) VisitText(node *rst.Text) {
+	t.Write(Escape(node.AsText()))
+}
+
+func (t *Translator) DepartText(node *rst.Text) {}
+
+func (t *Translator) VisitAbbreviation(node *rst.Element) error {
+	t.writeRole(node, "abbreviation", node.AsText())
+	return &rst.SkipNode{}
+}
+
+func (t *Translator) VisitAcronym(node *rst.Element) error {
+	t.writeRole(node, "acronym", node.AsText())
+	return &rst.SkipNode{}
+}
+
+func (t *Translator) VisitAddress(node *rst.Element) error {
+	return t.visitDocinfoItem(node, "address")
+}
+
+// Generic admonitions have their title as argument.
+func (t *Translator) VisitAdmonition(node *rst.Element) {
+	t.PushBuffer()
+}
+
+func (t *Translator) DepartAdmonition(node *rst.Element) {
+	t.WriteBlock(directive("admonition", t.titles[node], nil, t.PopBuffer()))
+}
+
+func (t *Translator) visitSpecificAdmonition(node *rst.Element) {
+	t.PushBuffer()
+}
+
+func (t *Translator) departSpecificAdmonition(node *rst.Element) {
+	t.WriteBlock(directive(node.TagName(), "", nil, t.PopBuffer()))
+}
+
+func (t *Translator) VisitAttention(node *rst.Element) {
+	t.visitSpecificAdmonition(node)
+}
+
+func (t *Translator) DepartAttention(node *rst.Element) {
+	t.departSpecificAdmonition(node)
+}
+
+func (t *Translator) VisitCaution(node *rst.Element) {
+	t.visitSpecificAdmonition(node)
+}
+
+func (t *Translator) DepartCaution(node *rst.Element) {
+	t.departSpecificAdmonition(node)
+}
+
+func (t *Translator) VisitDanger(node *rst.Element) {
+	t.visitSpecificAdmonition(node)
+}
+
+func (t *Translator) DepartDanger(node *rst.Element) {
+	t.departSpecificAdmonition(node)
+}
+
+func (t *Translator) VisitError(node *rst.Element) {
+	t.visitSpecificAdmonition(node)
+}
+
+func (t *Translator) DepartError(node *rst.Element) {
+	t.departSpecificAdmonition(node)
+}
+
+func (t *Translator) VisitHint(node *rst.Element) {
+	t.visitSpecificAdmonition(node)
+}
+
+func (t *Translator) DepartHint(node *rst.Element) {
+	t.departSpecificAdmonition(node)
+}
+
+func (t *Translator) VisitImportant(node *rst.Element) {
+	t.visitSpecificAdmonition(node)
+}
+
+func (t *Translator) DepartImportant(node *rst.Element) {
+	t.departSpecificAdmonition(node)
+}
+
+func (t *Translator) VisitNote(node *rst.Element) {
+	t.visitSpecificAdmonition(node)
+}
+
+func (t *Translator) DepartNote(node *rst.Element) {
+	t.departSpecificAdmonition(node)
+}
+
+func (t *Translator) VisitTip(node *rst.Element) {
+	t.visitSpecificAdmonition(node)
+}
+
+func (t *Translator) DepartTip(node *rst.Element) {
+	t.departSpecificAdmonition(node)
+}
+
+func (t *Translator) VisitWarning(node *rst.Element) {
+	t.visitSpecificAdmonition(node)
+}
+
+func (t *Translator) DepartWarning(node *rst.Element) {
+	t.departSpecificAdmonition(node)
+}
+
+func (t *Translator) VisitAttribution(node *rst.Element) {
+	t.PushBuffer()
+}
+
+func (t *Translator) DepartAttribution(node *rst.Element) {
+	text := strings.Replace(t.PopBuffer(), string(nbsp), " ", -1)
+	t.WriteBlock(writers.PrefixLines(text, "-- ", "   "))
+}
+
+func (t *Translator) VisitAuthor(node *rst.Element) error {
+	if node.Parent().TagName() == "authors" {
+		if node.Parent().Index(node) > 0 {
+			t.Write("; ")
+		}
+		return nil
+	}
+	return t.visitDocinfoItem(node, "author")
+}
+
+func (t *Translator) DepartAuthor(node *rst.Element) {
+	if node.Parent().TagName() != "authors" {
+		t.departDocinfoItem(node)
+	}
+}
+
+func (t *Translator) VisitAuthors(node *rst.Element) error {
+	return t.visitDocinfoItem(node, "authors")
+}
+
+func (t *Translator) VisitBlockQuote(node *rst.Element) {
+	if needsSeparator(node) {
+		t.WriteBlock("..\n")
+	}
+	t.PushBuffer()
+}
+
+func (t *Translator) DepartBlockQuote(node *rst.Element) {
+	t.WriteBlock(writers.PrefixLines(t.PopBuffer(), "   ", "   "))
+}
+
+func (t *Translator) VisitBulletList(node *rst.Element) {
+	t.StartBlock()
+	t.PushBuffer()
+}
+
+func (t *Translator) DepartBulletList(node *rst.Element) {
+	t.Write(t.PopBuffer())
+}
+
+// The caption is written by the figure.
+func (t *Translator) VisitCaption(node *rst.Element) error {
+	return &rst.SkipNode{}
+}
+
+func (t *Translator) VisitCitation(node *rst.Element) {
+	t.PushBuffer()
+}
+
+func (t *Translator) DepartCitation(node *rst.Element) {
+	t.departFootnote(node, t.label(node))
+}
+
+// Return the label of a footnote or citation.
+func (t *Translator) label(node *rst.Element) string {
+	if node.Len() > 0 && node.Children()[0].TagName() == "label" {
+		return node.Children()[0].AsText()
+	}
+	return ""
+}
+
+func (t *Translator) VisitCitationReference(node *rst.Element) error {
+	t.openInline("[")
+	t.Write(node.AsText())
+	t.closeInline(node, "]_")
+	return &rst.SkipNode{}
+}
+
+func (t *Translator) VisitClassifier(node *rst.Element) {
+	t.Write(" : ")
+}
+
+func (t *Translator) DepartClassifier(node *rst.Element) {}
+
+func (t *Translator) VisitColspec(node *rst.Element) error {
+	return &rst.SkipNode{}
+}
+
+/*
+   Comments are written as is; a first line which could be read as other
+   explicit markup (a target, a directive, ...) goes to the indented block.
+*/
+func (t *Translator) VisitComment(node *rst.Element) error {
+	text := node.AsText()
+	switch {
+	case strings.TrimSpace(text) == "":
+		t.WriteBlock("..\n")
+	case strings.ContainsAny(text[:1], "[_|") || strings.Contains(strings.SplitN(text, "\n", 2)[0], "::"):
+		t.WriteBlock("..\n" + writers.PrefixLines(text, "   ", "   "))
+	default:
+		t.WriteBlock(writers.PrefixLines(text, ".. ", "   "))
+	}
+	return &rst.SkipNode{}
+}
+
+func (t *Translator) VisitCompound(node *rst.Element) {
+	t.PushBuffer()
+}
+
+func (t *Translator) DepartCompound(node *rst.Element) {
+	t.WriteBlock(directive("compound", "", nil, t.PopBuffer()))
+}
+
+func (t *Translator) VisitContact(node *rst.Element) error {
+	return t.visitDocinfoItem(node, "contact")
+}
+
+func (t *Translator) VisitContainer(node *rst.Element) {
+	t.PushBuffer()
+}
+
+func (t *Translator) DepartContainer(node *rst.Element) {
+	t.WriteBlock(directive("container", strings.Join(node.Classes, " "), nil, t.PopBuffer()))
+}
+
+func (t *Translator) VisitCopyright(node *rst.Element) error {
+	return t.visitDocinfoItem(node, "copyright")
+}
+
+func (t *Translator) VisitDate(node *rst.Element) error {
+	return t.visitDocinfoItem(node, "date")
+}
+
+func (t *Translator) VisitDecoration(node *rst.Element)  {}
+func (t *Translator) DepartDecoration(node *rst.Element) {}
+
+func (t *Translator) VisitDefinition(node *rst.Element) {
+	t.Write("\n")
+	t.PushBuffer()
+}
+
+func (t *Translator) DepartDefinition(node *rst.Element) {
+	t.Write(writers.PrefixLines(t.PopBuffer(), "   ", "   "))
+}
+
+func (t *Translator) VisitDefinitionList(node *rst.Element) {
+	t.StartBlock()
+	t.PushBuffer()
+}
+
+func (t *Translator) DepartDefinitionList(node *rst.Element) {
+	t.Write(t.PopBuffer())
+}
+
+func (t *Translator) VisitDefinitionListItem(node *rst.Element) {
+	t.PushBuffer()
+}
+
+func (t *Translator) DepartDefinitionListItem(node *rst.Element) {
+	t.WriteBlock(strings.Replace(t.PopBuffer(), string(nbsp), " ", -1))
+}
+
+func (t *Translator) VisitDescription(node *rst.Element) {
+	t.PushBuffer()
+}
+
+func (t *Translator) DepartDescription(node *rst.Element) {
+	t.Push(t.PopBuffer())
+}
+
+/*
+   The docinfo is a field list, with the dedication and abstract topics
+   following it.
+*/
+func (t *Translator) VisitDocinfo(node *rst.Element) {
+	t.StartBlock()
+	t.PushBuffer()
+}
+
+func (t *Translator) DepartDocinfo(node *rst.Element) error {
+	parent := node.Parent()
+	for _, sibling := range parent.Children()[parent.Index(node)+1:] {
+		topic, ok := sibling.(*rst.Element)
+		if !ok || topic.TagName() != "topic" || !isBibliographicTopic(topic) {
+			break
+		}
+		t.PushBuffer()
+		for _, child := range topic.Children()[1:] {
+			if err := rst.Walkabout(child, t); err != nil {
+				return err
+			}
+		}
+		t.writeField(t.titleText(topic), t.PopBuffer())
+	}
+	t.Write(t.PopBuffer())
+	return nil
+}
+
+func isBibliographicTopic(node *rst.Element) bool {
+	for _, cls := range node.Classes {
+		if cls == "dedication" || cls == "abstract" {
+			return node.Len() > 0 && node.Children()[0].TagName() == "title"
+		}
+	}
+	return false
+}
+
+// Return the text of the title of `node`.
+func (t *Translator) titleText(node *rst.Element) string {
+	return node.Children()[0].AsText()
+}
+
+func (t *Translator) writeField(name, body string) {
+	text := strings.Replace(body, string(nbsp), " ", -1)
+	if strings.TrimSpace(text) == "" {
+		t.Write(":", name, ":\n")
+		return
+	}
+	if strings.HasPrefix(text, "\n") {
+		// the body starts with a block (not a paragraph)
+		t.Write(":", name, ":\n", writers.PrefixLines(strings.TrimLeft(text, "\n"), "   ", "   "))
+		return
+	}
+	t.Write(writers.PrefixLines(text, ":"+name+": ", "   "))
+}
+
+func (t *Translator) visitDocinfoItem(node *rst.Element, name string) error {
+	t.PushBuffer()
+	t.Push(t.language.Labels[name])
+	return nil
+}
+
+func (t *Translator) departDocinfoItem(node *rst.Element) {
+	text := t.PopBuffer()
+	if node.TagName() == "address" {
+		text = "\n" + text
+	}
+	t.writeField(t.Pop(), text)
+}
+
+func (t *Translator) DepartAddress(node *rst.Element) {
+	t.departDocinfoItem(node)
+}
+
+func (t *Translator) DepartAuthors(node *rst.Element) {
+	t.departDocinfoItem(node)
+}
+
+func (t *Translator) DepartContact(node *rst.Element) {
+	t.departDocinfoItem(node)
+}
+
+func (t *Translator) DepartCopyright(node *rst.Element) {
+	t.departDocinfoItem(node)
+}
+
+func (t *Translator) DepartDate(node *rst.Element) {
+	t.departDocinfoItem(node)
+}
+
+func (t *Translator) DepartOrganization(node *rst.Element) {
+	t.departDocinfoItem(node)
+}
+
+func (t *Translator) DepartRevision(node *rst.Element) {
+	t.departDocinfoItem(node)
+}
+
+func (t *Translator) DepartStatus(node *rst.Element) {
+	t.departDocinfoItem(node)
+}
+
+func (t *Translator) DepartVersion(node *rst.Element) {
+	t.departDocinfoItem(node)
+}
+
+func (t *Translator) VisitDoctestBlock(node *rst.Element) error {
+	t.WriteBlock(node.AsText() + "\n")
+	return &rst.SkipNode{}
+}
+
+func (t *Translator) VisitDocument(node *rst.Element) {}
+
+// Declare the custom roles at the top.
+func (t *Translator) DepartDocument(node *rst.Element) {
+	var names []string
+	for name := range t.roles {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	var declarations []string
+	for _, name := range names {
+		declarations = append(declarations, t.roles[name], "\n")
+	}
+	t.Body = append(declarations, t.Body...)
+}
+
+func (t *Translator) VisitEmphasis(node *rst.Element) {
+	t.openInline("*")
+}
+
+func (t *Translator) DepartEmphasis(node *rst.Element) {
+	t.closeInline(node, "*")
+}
+
+func (t *Translator) VisitEntry(node *rst.Element) {
+	t.PushBuffer()
+}
+
+func (t *Translator) DepartEntry(node *rst.Element) {
+	text := strings.Replace(t.PopBuffer(), string(nbsp), " ", -1)
+	t.tables[len(t.tables)-1].AddCell(node).SetText(text)
+}
+
+func (t *Translator) VisitEnumeratedList(node *rst.Element) {
+	t.StartBlock()
+	t.PushBuffer()
+}
+
+func (t *Translator) DepartEnumeratedList(node *rst.Element) {
+	t.Write(t.PopBuffer())
+}
+
+// Return the enumerator of item `n` of the enumerated list `node`.
+func enumerator(node *rst.Element, n int) string {
+	var label string
+	switch node.Get("enumtype") {
+	case "loweralpha":
+		label = string(rune('a' + n - 1))
+	case "upperalpha":
+		label = string(rune('A' + n - 1))
+	case "lowerroman":
+		label = strings.ToLower(toRoman(n))
+	case "upperroman":
+		label = toRoman(n)
+	default:
+		label = fmt.Sprint(n)
+	}
+	suffix := node.Get("suffix")
+	if suffix == "" {
+		suffix = "."
+	}
+	return node.Get("prefix") + label + suffix
+}
+
+var romanNumerals = []struct {
+	value   int
+	numeral string
+}{
+	{1000, "M"}, {900, "CM"}, {500, "D"}, {400, "CD"}, {100, "C"}, {90, "XC"},
+	{50, "L"}, {40, "XL"}, {10, "X"}, {9, "IX"}, {5, "V"}, {4, "IV"}, {1, "I"},
+}
+
+func toRoman(n int) string {
+	var b strings.Builder
+	for _, r := range romanNumerals {
+		for n >= r.value {
+			b.WriteString(r.numeral)
+			n -= r.value
+		}
+	}
+	return b.String()
+}
+
+func (t *Translator) VisitField(node *rst.Element) {
+	t.PushBuffer()
+}
+
+func (t *Translator) DepartField(node *rst.Element) {
+	body := t.PopBuffer()
+	name := t.Pop()
+	t.writeField(name, body)
+}
+
+func (t *Translator) VisitFieldBody(node *rst.Element)  {}
+func (t *Translator) DepartFieldBody(node *rst.Element) {}
+
+func (t *Translator) VisitFieldList(node *rst.Element) {
+	t.StartBlock()
+	t.PushBuffer()
+}
+
+func (t *Translator) DepartFieldList(node *rst.Element) {
+	t.Write(t.PopBuffer())
+}
+
+func (t *Translator) VisitFieldName(node *rst.Element) error {
+	text, err := t.renderInline(node)
+	if err != nil {
+		return err
+	}
+	t.Push(text)
+	return &rst.SkipNode{}
+}
+
+func (t *Translator) VisitFigure(node *rst.Element) {
+	t.PushBuffer()
+}
+
+/*
+   The figure directive has the image options, the caption as first
+   paragraph and the legend.
+*/
+func (t *Translator) DepartFigure(node *rst.Element) error {
+	content := t.PopBuffer()
+	var image *rst.Element
+	for _, n := range node.Traverse(rst.ByTag("image")) {
+		image = n.(*rst.Element)
+		break
+	}
+	if image == nil {
+		return &NotImplementedError{"figure without image"}
+	}
+	options := imageOptions(image)
+	if node.HasAttr("width") {
+		options = append(options, directiveOption{"figwidth", node.Get("width")})
+	}
+	if node.HasAttr("align") {
+		options = append(options, directiveOption{"align", node.Get("align")})
+	}
+	options = append(options, classOptions(node)...)
+	for _, child := range node.Children() {
+		if child.TagName() == "caption" {
+			caption, err := t.renderInline(child.(*rst.Element))
+			if err != nil {
+				return err
+			}
+			content = formatText(caption, t.settings.WrapWidth) + "\n" + content
+		}
+	}
+	t.WriteBlock(directive("figure", image.Get("uri"), options, content))
+	return nil
+}
+
+func imageOptions(image *rst.Element) []directiveOption {
+	var options []directiveOption
+	for _, name := range []string{"alt", "height", "width", "scale", "align"} {
+		if image.HasAttr(name) {
+			options = append(options, directiveOption{name, image.Get(name)})
+		}
+	}
+	if parent := image.Parent(); parent.TagName() == "reference" && parent.HasAttr("refuri") {
+		options = append(options, directiveOption{"target", parent.Get("refuri")})
+	}
+	return options
+}
+
+func classOptions(node *rst.Element) []directiveOption {
+	if len(node.Classes) == 0 {
+		return nil
+	}
+	return []directiveOption{{"class", strings.Join(node.Classes, " ")}}
+}
+
+func (t *Translator) VisitFooter(node *rst.Element) {
+	t.PushBuffer()
+}
+
+func (t *Translator) DepartFooter(node *rst.Element) {
+	t.WriteBlock(directive("footer", "", nil, t.PopBuffer()))
+}
+
+func (t *Translator) VisitFootnote(node *rst.Element) {
+	t.PushBuffer()
+}
+
+/*
+   Auto-numbered footnotes are written "[#]" or "[#name]", auto-symbol
+   footnotes "[*]".
+*/
+func (t *Translator) DepartFootnote(node *rst.Element) {
+	label := t.label(node)
+	switch node.Get("auto") {
+	case "1":
+		label = "#"
+		if len(node.Names) > 0 && node.Names[0] != t.label(node) {
+			label += node.Names[0]
+		}
+	case "*":
+		label = "*"
+	}
+	t.departFootnote(node, label)
+}
+
+func (t *Translator) departFootnote(node *rst.Element, label string) {
+	text := strings.Replace(t.PopBuffer(), string(nbsp), " ", -1)
+	if strings.TrimSpace(text) == "" {
+		t.WriteBlock(".. [" + label + "]\n")
+		return
+	}
+	t.WriteBlock(writers.PrefixLines(text, ".. ["+label+"] ", "   "))
+}
+
+func (t *Translator) VisitFootnoteReference(node *rst.Element) error {
+	label := node.AsText()
+	switch node.Get("auto") {
+	case "1":
+		label = "#"
+		if node.HasAttr("refname") {
+			label += node.Get("refname")
+		} else if footnote := t.document.GetElementByID(node.Get("refid")); footnote != nil &&
+			len(footnote.Names) > 0 && footnote.Names[0] != t.label(footnote) {
+			label += footnote.Names[0]
+		}
+	case "*":
+		label = "*"
+	}
+	t.openInline("[")
+	t.Write(label)
+	t.closeInline(node, "]_")
+	return &rst.SkipNode{}
+}
+
+// Section numbers and other generated text are not part of the source.
+func (t *Translator) VisitGenerated(node *rst.Element) error {
+	return &rst.SkipNode{}
+}
+
+func (t *Translator) VisitHeader(node *rst.Element) {
+	t.PushBuffer()
+}
+
+func (t *Translator) DepartHeader(node *rst.Element) {
+	t.WriteBlock(directive("header", "", nil, t.PopBuffer()))
+}
+
+/*
+   Inline images are written as the reference to the substitution defining
+   them, if any.
+*/
+func (t *Translator) VisitImage(node *rst.Element) error {
+	parent := node.Parent()
+	if parent.TagName() == "substitution_definition" {
+		return &rst.SkipNode{}
+	}
+	if parent.Is(rst.TextElementClass) || parent.TagName() == "reference" && parent.Parent().Is(rst.TextElementClass) {
+		for _, n := range t.document.Traverse(rst.ByTag("substitution_definition")) {
+			definition := n.(*rst.Element)
+			if definition.Len() == 1 && definition.Children()[0].TagName() == "image" &&
+				definition.Children()[0].(*rst.Element).Get("uri") == node.Get("uri") && len(definition.Names) > 0 {
+				t.openInline("|")
+				t.Write(definition.Names[0])
+				t.closeInline(node, "|")
+				break
+			}
+		}
+		return &rst.SkipNode{}
+	}
+	if parent.TagName() == "figure" {
+		return &rst.SkipNode{}
+	}
+	options := append(imageOptions(node), classOptions(node)...)
+	t.WriteBlock(directive("image", node.Get("uri"), options, ""))
+	return &rst.SkipNode{}
+}
+
+/*
+   Inline elements with classes are written as custom interpreted text
+   roles, declared with the role directive.
+*/
+func (t *Translator) VisitInline(node *rst.Element) error {
+	if len(node.Classes) == 0 {
+		return nil
+	}
+	role := node.Classes[0]
+	declaration := ".. role:: " + role + "\n"
+	if role == "code" {
+		if len(node.Classes) > 1 {
+			role = node.Classes[1]
+			declaration = ".. role:: " + role + "(code)\n   :language: " + role + "\n"
+		} else {
+			declaration = ""
+		}
+	} else if len(node.Classes) > 1 {
+		declaration += "   :class: " + strings.Join(node.Classes, " ") + "\n"
+	}
+	if declaration != "" {
+		t.roles[role] = declaration
+	}
+	t.writeRole(node, role, node.AsText())
+	return &rst.SkipNode{}
+}
+
+func (t *Translator) DepartInline(node *rst.Element) {}
+
+// Labels are written by the footnote or citation.
+func (t *Translator) VisitLabel(node *rst.Element) error {
+	return &rst.SkipNode{}
+}
+
+// The legend is written by the figure.
+func (t *Translator) VisitLegend(node *rst.Element)  {}
+func (t *Translator) DepartLegend(node *rst.Element) {}
+
+func (t *Translator) VisitLine(node *rst.Element) {
+	t.PushBuffer()
+}
+
+func (t *Translator) DepartLine(node *rst.Element) {
+	text := strings.Replace(t.PopBuffer(), string(nbsp), " ", -1)
+	if text == "" {
+		t.Write("|\n")
+		return
+	}
+	indent := strings.Repeat("    ", t.lineIndent)
+	t.Write(writers.PrefixLines(text, "| "+indent, "  "+indent))
+}
+
+func (t *Translator) VisitLineBlock(node *rst.Element) {
+	if node.Parent().TagName() == "line_block" {
+		t.lineIndent++
+		return
+	}
+	t.StartBlock()
+}
+
+func (t *Translator) DepartLineBlock(node *rst.Element) {
+	if node.Parent().TagName() == "line_block" {
+		t.lineIndent--
+	}
+}
+
+func (t *Translator) VisitListItem(node *rst.Element) {
+	t.PushBuffer()
+}
+
+func (t *Translator) DepartListItem(node *rst.Element) {
+	text := strings.Replace(t.PopBuffer(), string(nbsp), " ", -1)
+	list := node.Parent()
+	marker := bulletMarker(list)
+	if list.TagName() == "enumerated_list" {
+		start := 1
+		fmt.Sscan(list.Get("start"), &start)
+		marker = enumerator(list, start+list.Index(node))
+	}
+	if list.Index(node) > 0 && !isSimpleList(list) {
+		t.Write("\n")
+	}
+	if strings.TrimSpace(text) == "" {
+		t.Write(marker, "\n")
+		return
+	}
+	indent := strings.Repeat(" ", utf8.RuneCountInString(marker)+1)
+	t.Write(writers.PrefixLines(text, marker+" ", indent))
+}
+
+/*
+   Return the bullet of `list`: "-", or the next of "*" and "+" if the
+   previous sibling is a bullet list (which the items would continue).
+*/
+func bulletMarker(list *rst.Element) string {
+	bullets := []string{"-", "*", "+"}
+	n := 0
+	for parent, index := list.Parent(), list.Parent().Index(list); index > 0; index-- {
+		if parent.Children()[index-1].TagName() != "bullet_list" {
+			break
+		}
+		n++
+	}
+	return bullets[n%len(bullets)]
+}
+
+// Are the items of `list` single paragraphs (written without blank lines)?
+func isSimpleList(list *rst.Element) bool {
+	for _, item := range list.Children() {
+		children := item.(*rst.Element).Children()
+		if len(children) > 1 || len(children) == 1 && children[0].TagName() != "paragraph" {
+			return false
+		}
+	}
+	return true
+}
+
+func (t *Translator) VisitLiteral(node *rst.Element) error {
+	text := node.AsText()
+	if strings.Contains(text, "``") || strings.HasPrefix(text, " ") || strings.HasSuffix(text, " ") || text == "" ||
+		strings.HasSuffix(text, "`") {
+		t.writeRole(node, "literal", text)
+		return &rst.SkipNode{}
+	}
+	if t.inlineDepth > 0 {
+		t.Write(Escape(text))
+		return &rst.SkipNode{}
+	}
+	t.openInline("``")
+	t.Write(strings.Replace(text, " ", string(nbsp), -1))
+	t.closeInline(node, "``")
+	return &rst.SkipNode{}
+}
+
+/*
+   Code blocks are written as code directives, parsed literal blocks as
+   parsed-literal directives, other literal blocks after "::".
+*/
+func (t *Translator) VisitLiteralBlock(node *rst.Element) error {
+	if len(node.Classes) > 0 && node.Classes[0] == "code" {
+		language := ""
+		if len(node.Classes) > 1 {
+			language = node.Classes[1]
+		}
+		t.WriteBlock(directive("code", language, nil, node.AsText()))
+		return &rst.SkipNode{}
+	}
+	for _, child := range node.Children() {
+		if _, ok := child.(*rst.Text); !ok {
+			text, err := t.renderInline(node)
+			if err != nil {
+				return err
+			}
+			t.WriteBlock(directive("parsed-literal", "", classOptions(node), text))
+			return &rst.SkipNode{}
+		}
+	}
+	if !t.literalIntroduced {
+		t.WriteBlock("::\n")
+	}
+	t.literalIntroduced = false
+	t.WriteBlock(writers.PrefixLines(node.AsText(), "   ", "   "))
+	return &rst.SkipNode{}
+}
+
+func (t *Translator) VisitMath(node *rst.Element) error {
+	t.writeRole(node, "math", node.AsText())
+	return &rst.SkipNode{}
+}
+
+func (t *Translator) VisitMathBlock(node *rst.Element) error {
+	t.WriteBlock(directive("math", "", nil, node.AsText()))
+	return &rst.SkipNode{}
+}
+
+func (t *Translator) VisitOption(node *rst.Element) {
+	if node.Parent().Index(node) > 0 {
+		t.Write(", ")
+	}
+}
+
+func (t *Translator) DepartOption(node *rst.Element) {}
+
+func (t *Translator) VisitOptionArgument(node *rst.Element) error {
+	t.Write(node.Get("delimiter"), node.AsText())
+	return &rst.SkipNode{}
+}
+
+func (t *Translator) VisitOptionGroup(node *rst.Element) {
+	t.PushBuffer()
+}
+
+func (t *Translator) DepartOptionGroup(node *rst.Element) {
+	t.Push(t.PopBuffer())
+}
+
+func (t *Translator) VisitOptionList(node *rst.Element) {
+	t.StartBlock()
+	t.PushBuffer()
+}
+
+func (t *Translator) DepartOptionList(node *rst.Element) {
+	t.Write(t.PopBuffer())
+}
+
+func (t *Translator) VisitOptionListItem(node *rst.Element) {}
+
+// The description is aligned after the options (either may be missing).
+func (t *Translator) DepartOptionListItem(node *rst.Element) {
+	var group, description string
+	children := node.Children()
+	for i := len(children) - 1; i >= 0; i-- {
+		switch children[i].TagName() {
+		case "description":
+			description = strings.Replace(t.Pop(), string(nbsp), " ", -1)
+		case "option_group":
+			group = t.Pop()
+		}
+	}
+	if group == "" && description == "" {
+		return
+	}
+	indent := strings.Repeat(" ", utf8.RuneCountInString(group)+2)
+	t.Write(writers.PrefixLines(description, group+"  ", indent))
+}
+
+func (t *Translator) VisitOptionString(node *rst.Element) error {
+	t.Write(node.AsText())
+	return &rst.SkipNode{}
+}
+
+func (t *Translator) VisitOrganization(node *rst.Element) error {
+	return t.visitDocinfoItem(node, "organization")
+}
+
+func (t *Translator) VisitParagraph(node *rst.Element) {
+	t.PushBuffer()
+}
+
+/*
+   A paragraph ending with a colon introduces a following literal block
+   with "::".
+*/
+func (t *Translator) DepartParagraph(node *rst.Element) {
+	text := formatText(t.PopBuffer(), t.settings.WrapWidth)
+	parent := node.Parent()
+	if index := parent.Index(node); index+1 < parent.Len() && strings.HasSuffix(text, ":") &&
+		!strings.HasSuffix(text, `\:`) {
+		next := parent.Children()[index+1].(*rst.Element)
+		if next.TagName() == "literal_block" && len(next.Classes) == 0 && next.Len() == 1 {
+			if _, ok := next.Children()[0].(*rst.Text); ok {
+				text += ":"
+				t.literalIntroduced = true
+			}
+		}
+	}
+	t.WriteBlock(text + "\n")
+}
+
+func (t *Translator) VisitPending(node *rst.Element) error {
+	return &rst.SkipNode{}
+}
+
+// Problematic elements hold the markup they come from.
+func (t *Translator) VisitProblematic(node *rst.Element) error {
+	t.Write(node.AsText())
+	return &rst.SkipNode{}
+}
+
+func (t *Translator) VisitRaw(node *rst.Element) error {
+	if node.Parent().Is(rst.TextElementClass) {
+		role := "raw-" + strings.Fields(node.Get("format") + " x")[0]
+		t.roles[role] = ".. role:: " + role + "(raw)\n   :format: " + node.Get("format") + "\n"
+		t.writeRole(node, role, node.AsText())
+		return &rst.SkipNode{}
+	}
+	t.WriteBlock(directive("raw", node.Get("format"), nil, node.AsText()))
+	return &rst.SkipNode{}
+}
+
+/*
+   References to named targets are written "name_", other external ones
+   with an embedded URI; internal references name their target.
+*/
+func (t *Translator) VisitReference(node *rst.Element) error {
+	if !node.Parent().Is(rst.TextElementClass) {
+		// a block-level image link: the image has the target option
+		return nil
+	}
+	text := node.AsText()
+	suffix := "_"
+	if node.Get("anonymous") == "1" {
+		suffix = "__"
+	}
+	name := node.Get("name")
+	if name == "" {
+		name = text
+	}
+	var target string
+	switch {
+	case node.HasAttr("refname"):
+		target = node.Get("refname")
+	case node.HasAttr("refuri"):
+		uri := node.Get("refuri")
+		if uri == text || "mailto:"+text == uri {
+			// standalone hyperlink
+			if t.inlineDepth > 0 || node.Len() != 1 {
+				break
+			}
+			t.Write(Escape(text))
+			return &rst.SkipNode{}
+		}
+		if t.targetNames[rst.FullyNormalizeName(name)] && suffix == "_" {
+			target = rst.FullyNormalizeName(name)
+		} else {
+			t.writeReference(node, text+" <"+uri+">", suffix)
+			return &rst.SkipNode{}
+		}
+	case node.HasAttr("refid"):
+		if element := t.document.GetElementByID(node.Get("refid")); element != nil && len(element.Names) > 0 {
+			target = element.Names[0]
+		}
+	}
+	if target == "" {
+		t.Write(Escape(text))
+		return &rst.SkipNode{}
+	}
+	if rst.FullyNormalizeName(text) != target {
+		text += " <" + target + "_>"
+	}
+	t.writeReference(node, text, suffix)
+	return &rst.SkipNode{}
+}
+
+func (t *Translator) writeReference(node *rst.Element, text, suffix string) {
+	if t.inlineDepth > 0 {
+		t.Write(Escape(node.AsText()))
+		return
+	}
+	simple := true
+	for _, r := range text {
+		if !isWordChar(r) && !strings.ContainsRune("-.+", r) {
+			simple = false
+		}
+	}
+	if simple && text != "" {
+		t.openInline("")
+		t.Write(text)
+		t.closeInline(node, suffix)
+		return
+	}
+	t.openInline("`")
+	t.Write(strings.Replace(strings.Replace(text, "`", "\\`", -1), " ", string(nbsp), -1))
+	t.closeInline(node, "`"+suffix)
+}
+
+func (t *Translator) DepartReference(node *rst.Element) {}
+
+func (t *Translator) VisitRevision(node *rst.Element) error {
+	return t.visitDocinfoItem(node, "revision")
+}
+
+func (t *Translator) VisitRow(node *rst.Element) {}
+
+func (t *Translator) DepartRow(node *rst.Element) {
+	t.tables[len(t.tables)-1].EndRow(node.Parent().TagName() == "thead")
+}
+
+func (t *Translator) VisitRubric(node *rst.Element) error {
+	text, err := t.renderInline(node)
+	if err != nil {
+		return err
+	}
+	t.WriteBlock(directive("rubric", text, classOptions(node), ""))
+	return &rst.SkipNode{}
+}
+
+/*
+   The section of the system messages, added by the Messages transform, is
+   skipped like the messages themselves: it is not part of the source.
+*/
+func (t *Translator) VisitSection(node *rst.Element) error {
+	for _, cls := range node.Classes {
+		if cls == "system-messages" {
+			return &rst.SkipNode{}
+		}
+	}
+	t.sectionLevel++
+	return nil
+}
+
+func (t *Translator) DepartSection(node *rst.Element) {
+	t.sectionLevel--
+}
+
+func (t *Translator) VisitSidebar(node *rst.Element) {
+	t.PushBuffer()
+}
+
+func (t *Translator) DepartSidebar(node *rst.Element) {
+	var options []directiveOption
+	for _, child := range node.Children() {
+		if child.TagName() == "subtitle" {
+			options = append(options, directiveOption{"subtitle", t.titles[child.(*rst.Element)]})
+		}
+	}
+	options = append(options, classOptions(node)...)
+	t.WriteBlock(directive("sidebar", t.titles[node], options, t.PopBuffer()))
+}
+
+func (t *Translator) VisitStatus(node *rst.Element) error {
+	return t.visitDocinfoItem(node, "status")
+}
+
+func (t *Translator) VisitStrong(node *rst.Element) {
+	t.openInline("**")
+}
+
+func (t *Translator) DepartStrong(node *rst.Element) {
+	t.closeInline(node, "**")
+}
+
+func (t *Translator) VisitSubscript(node *rst.Element) error {
+	t.writeRole(node, "sub", node.AsText())
+	return &rst.SkipNode{}
+}
+
+/*
+   Substitution definitions of a single image are image directives, other
+   ones replace directives.
+*/
+func (t *Translator) VisitSubstitutionDefinition(node *rst.Element) error {
+	if len(node.Names) == 0 {
+		return &rst.SkipNode{}
+	}
+	prefix := "|" + node.Names[0] + "| "
+	if node.Len() == 1 && node.Children()[0].TagName() == "image" {
+		image := node.Children()[0].(*rst.Element)
+		t.WriteBlock(writers.PrefixLines(directive("image", image.Get("uri"), imageOptions(image), ""), ".. "+prefix, ""))
+		return &rst.SkipNode{}
+	}
+	text, err := t.renderInline(node)
+	if err != nil {
+		return err
+	}
+	t.WriteBlock(".. " + prefix + "replace:: " + text + "\n")
+	return &rst.SkipNode{}
+}
+
+func (t *Translator) VisitSubstitutionReference(node *rst.Element) error {
+	t.openInline("|")
+	t.Write(node.Get("refname"))
+	t.closeInline(node, "|")
+	return &rst.SkipNode{}
+}
+
+func (t *Translator) VisitSubtitle(node *rst.Element) error {
+	switch node.Parent().TagName() {
+	case "document":
+		return t.writeTitle(node, true, t.adornment(1))
+	case "sidebar":
+		text, err := t.renderInline(node)
+		if err != nil {
+			return err
+		}
+		t.titles[node] = text
+	}
+	return &rst.SkipNode{}
+}
+
+func (t *Translator) VisitSuperscript(node *rst.Element) error {
+	t.writeRole(node, "sup", node.AsText())
+	return &rst.SkipNode{}
+}
+
+// System messages are generated by the parser.
+func (t *Translator) VisitSystemMessage(node *rst.Element) error {
+	return &rst.SkipNode{}
+}
+
+func (t *Translator) VisitTable(node *rst.Element) {
+	t.tables = append(t.tables, &writers.Table{})
+}
+
+func (t *Translator) DepartTable(node *rst.Element) {
+	tbl := t.tables[len(t.tables)-1]
+	t.tables = t.tables[:len(t.tables)-1]
+	grid := gridTable(tbl)
+	options := classOptions(node)
+	if t.titles[node] != "" || len(options) > 0 {
+		t.WriteBlock(directive("table", t.titles[node], options, grid))
+		return
+	}
+	t.WriteBlock(grid)
+}
+
+// Return the table as a grid table.
+func gridTable(tbl *writers.Table) string {
+	cellWidth := func(c *writers.Cell) int {
+		width := 1
+		for _, line := range c.Lines {
+			if w := utf8.RuneCountInString(line); w > width {
+				width = w
+			}
+		}
+		return width + 2
+	}
+	widths := tbl.ColumnWidths(cellWidth, 1)
+	heights := tbl.RowHeights()
+	xs := []int{0}
+	for _, w := range widths {
+		xs = append(xs, xs[len(xs)-1]+w+1)
+	}
+	ys := []int{0}
+	for _, h := range heights {
+		ys = append(ys, ys[len(ys)-1]+h+1)
+	}
+	canvas := make([][]rune, ys[len(ys)-1]+1)
+	for y := range canvas {
+		canvas[y] = []rune(strings.Repeat(" ", xs[len(xs)-1]+1))
+	}
+	set := func(y, x int, r rune) {
+		if canvas[y][x] != '+' {
+			canvas[y][x] = r
+		}
+	}
+	for _, c := range tbl.Cells {
+		x0, x1 := xs[c.Col], xs[c.Col+c.Colspan]
+		y0, y1 := ys[c.Row], ys[c.Row+c.Rowspan]
+		for _, y := range []int{y0, y1} {
+			for x := x0; x <= x1; x++ {
+				set(y, x, '-')
+			}
+			canvas[y][x0], canvas[y][x1] = '+', '+'
+		}
+		for y := y0 + 1; y < y1; y++ {
+			set(y, x0, '|')
+			set(y, x1, '|')
+		}
+		for i, line := range c.Lines {
+			copy(canvas[y0+1+i][x0+2:], []rune(line))
+		}
+	}
+	lines := make([]string, len(canvas))
+	for y, line := range canvas {
+		lines[y] = strings.TrimRight(string(line), " ")
+		if tbl.HeadRows > 0 && y == ys[tbl.HeadRows] {
+			lines[y] = strings.Replace(lines[y], "-", "=", -1)
+		}
+	}
+	return strings.Join(lines, "\n") + "\n"
+}
+
+/*
+   Internal targets are written before the element they point to; targets
+   of embedded URIs are written by the reference.
+*/
+func (t *Translator) VisitTarget(node *rst.Element) error {
+	if node.Parent().Is(rst.TextElementClass) {
+		if !node.HasAttr("refuri") && node.Len() > 0 {
+			t.openInline("_`")
+			t.Write(Escape(node.AsText()))
+			t.closeInline(node, "`")
+		}
+		return &rst.SkipNode{}
+	}
+	names := node.Names
+	if len(names) == 0 && node.HasAttr("refid") {
+		// the target was propagated to the element it points to
+		if element := t.document.GetElementByID(node.Get("refid")); element != nil {
+			title := ""
+			if element.Len() > 0 && element.Children()[0].TagName() == "title" {
+				title = rst.FullyNormalizeName(element.Children()[0].AsText())
+			}
+			for _, name := range element.Names {
+				if name != title {
+					names = append(names, name)
+				}
+			}
+		}
+	}
+	link := ""
+	switch {
+	case node.HasAttr("refuri"):
+		link = " " + node.Get("refuri")
+	case node.HasAttr("refname"):
+		link = " " + targetName(node.Get("refname")) + "_"
+	}
+	if node.Get("anonymous") == "1" {
+		t.WriteBlock(".. __:" + link + "\n")
+		return &rst.SkipNode{}
+	}
+	var lines []string
+	for _, name := range names {
+		lines = append(lines, ".. _"+targetName(name)+":"+link+"\n")
+	}
+	t.WriteBlock(strings.Join(lines, ""))
+	return &rst.SkipNode{}
+}
+
+// Quote target names containing colons or spaces.
+func targetName(name string) string {
+	if strings.ContainsAny(name, ": `") {
+		return "`" + strings.Replace(name, "`", "\\`", -1) + "`"
+	}
+	return name
+}
+
+func (t *Translator) VisitTbody(node *rst.Element)  {}
+func (t *Translator) DepartTbody(node *rst.Element) {}
+
+func (t *Translator) VisitTerm(node *rst.Element) {
+	if node.Parent().Index(node) > 0 {
+		t.Write("\n")
+	}
+}
+
+func (t *Translator) DepartTerm(node *rst.Element) {}
+
+func (t *Translator) VisitTgroup(node *rst.Element) {
+	tbl := t.tables[len(t.tables)-1]
+	fmt.Sscan(node.Get("cols"), &tbl.Cols)
+}
+
+func (t *Translator) DepartTgroup(node *rst.Element) {
+	t.tables[len(t.tables)-1].EndGroup()
+}
+
+func (t *Translator) VisitThead(node *rst.Element)  {}
+func (t *Translator) DepartThead(node *rst.Element) {}
+
+// Return the adornment character of section `level` (0 for the title).
+func (t *Translator) adornment(level int) rune {
+	chars := []rune(t.settings.SectionAdornments)
+	if len(chars) == 0 {
+		chars = []rune("=-~^\"'`+*#")
+	}
+	return chars[level%len(chars)]
+}
+
+/*
+   Write the title `node` underlined (and overlined if `overline`) with
+   `char`.
+*/
+func (t *Translator) writeTitle(node *rst.Element, overline bool, char rune) error {
+	text, err := t.renderInline(node)
+	if err != nil {
+		return err
+	}
+	text = strings.Replace(text, "\n", " ", -1)
+	line := strings.Repeat(string(char), columnWidth(text))
+	if overline {
+		t.WriteBlock(line + "\n" + text + "\n" + line + "\n")
+	} else {
+		t.WriteBlock(text + "\n" + line + "\n")
+	}
+	return &rst.SkipNode{}
+}
+
+func (t *Translator) VisitTitle(node *rst.Element) error {
+	parent := node.Parent()
+	switch parent.TagName() {
+	case "document":
+		return t.writeTitle(node, true, t.adornment(0))
+	case "section":
+		// levels beyond the adornment characters are overlined
+		chars := utf8.RuneCountInString(t.settings.SectionAdornments)
+		return t.writeTitle(node, chars > 0 && t.sectionLevel > chars, t.adornment(t.sectionLevel-1))
+	}
+	text, err := t.renderInline(node)
+	if err != nil {
+		return err
+	}
+	t.titles[parent] = text
+	return &rst.SkipNode{}
+}
+
+func (t *Translator) VisitTitleReference(node *rst.Element) {
+	t.openInline("`")
+}
+
+func (t *Translator) DepartTitleReference(node *rst.Element) {
+	t.closeInline(node, "`")
+}
+
+/*
+   Tables of contents are written as contents directives (the entries are
+   generated); bibliographic topics by the docinfo.
+*/
+func (t *Translator) VisitTopic(node *rst.Element) error {
+	parent := node.Parent()
+	if isBibliographicTopic(node) && parent.TagName() == "document" {
+		if index := parent.Index(node); index > 0 {
+			switch parent.Children()[index-1].TagName() {
+			case "docinfo", "topic":
+				return &rst.SkipNode{}
+			}
+		}
+		t.StartBlock()
+		t.PushBuffer()
+		for _, child := range node.Children()[1:] {
+			if err := rst.Walkabout(child, t); err != nil {
+				return err
+			}
+		}
+		t.writeField(t.titleText(node), t.PopBuffer())
+		return &rst.SkipNode{}
+	}
+	for _, cls := range node.Classes {
+		if cls == "contents" {
+			title := ""
+			if node.Len() > 0 && node.Children()[0].TagName() == "title" {
+				title = node.Children()[0].AsText()
+			}
+			if title == t.language.Labels["contents"] {
+				title = ""
+			}
+			t.WriteBlock(directive("contents", Escape(title), nil, ""))
+			return &rst.SkipNode{}
+		}
+	}
+	t.PushBuffer()
+	return nil
+}
+
+func (t *Translator) DepartTopic(node *rst.Element) {
+	t.WriteBlock(directive("topic", t.titles[node], classOptions(node), t.PopBuffer()))
+}
+
+func (t *Translator) VisitTransition(node *rst.Element) error {
+	t.WriteBlock("----------\n")
+	return &rst.SkipNode{}
+}
+
+func (t *Translator) VisitVersion(node *rst.Element) error {
+	return t.visitDocinfoItem(node, "version")
+}
+
+type NotImplementedError struct {
+	msg string
+}
+
+func (e *NotImplementedError) Error() string {
+	return e.msg
+}
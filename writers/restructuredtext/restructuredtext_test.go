@@ -0,0 +1,319 @@
+package restructuredtext
+
+import (
+	"testing"
+
+	rst "github.com/siongui/go-rst"
+	"github.com/siongui/go-rst/parsers/docutilsxml"
+	parser "github.com/siongui/go-rst/parsers/restructuredtext"
+	"github.com/siongui/go-rst/readers/standalone"
+	"github.com/siongui/go-rst/transforms"
+)
+
+const input = `<document source="test.rst">
+    <title>Title</title>
+    <docinfo><version>1.0</version></docinfo>
+    <section>
+        <title>Usage</title>
+        <paragraph>1. Use <emphasis>all</emphasis> of x_ *y* <literal>a b</literal>s, see <reference refuri="https://example.com/docs">the docs</reference> or <reference name="Go" refuri="https://go.dev">Go</reference>::</paragraph>
+        <literal_block>code
+</literal_block>
+        <bullet_list>
+            <list_item><paragraph>first</paragraph></list_item>
+            <list_item><paragraph>second</paragraph></list_item>
+        </bullet_list>
+        <enumerated_list enumtype="loweralpha" prefix="(" suffix=")">
+            <list_item><paragraph>one</paragraph></list_item>
+            <list_item><paragraph>two</paragraph><paragraph>more</paragraph></list_item>
+        </enumerated_list>
+        <literal_block classes="code go">x := 1
+</literal_block>
+        <table>
+            <tgroup cols="2">
+                <thead>
+                    <row><entry><paragraph>A</paragraph></entry><entry><paragraph>Column B</paragraph></entry></row>
+                </thead>
+                <tbody>
+                    <row><entry morecols="1"><paragraph>spanning</paragraph></entry></row>
+                    <row><entry><paragraph>a</paragraph></entry><entry/></row>
+                </tbody>
+            </tgroup>
+        </table>
+        <block_quote>
+            <paragraph>Quoted
+            text.</paragraph>
+            <attribution>Someone</attribution>
+        </block_quote>
+        <definition_list>
+            <definition_list_item>
+                <term>term</term><classifier>type</classifier>
+                <definition><paragraph>Def.</paragraph></definition>
+            </definition_list_item>
+        </definition_list>
+        <field_list>
+            <field><field_name>key</field_name><field_body><paragraph>value</paragraph></field_body></field>
+        </field_list>
+        <note><paragraph>Careful.</paragraph></note>
+        <comment>a comment</comment>
+        <target names="go" refuri="https://go.dev"/>
+        <section>
+            <title>Sub</title>
+            <transition/>
+        </section>
+    </section>
+</document>
+`
+
+func TestWriter(t *testing.T) {
+	settings := &rst.Settings{}
+	settings.Init()
+	document, err := docutilsxml.ParseDocument(input, "", settings)
+	if err != nil {
+		t.Fatal(err)
+	}
+	writer := &Writer{}
+	output, err := writer.Write(document)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := "=====\n" +
+		"Title\n" +
+		"=====\n" +
+		"\n" +
+		":Version: 1.0\n" +
+		"\n" +
+		"Usage\n" +
+		"=====\n" +
+		"\n" +
+		"\\1. Use *all* of x\\_ \\*y\\* ``a b``\\ s, see `the docs <https://example.com/docs>`_ or Go_:\\:\n" +
+		"\n" +
+		"::\n" +
+		"\n" +
+		"   code\n" +
+		"\n" +
+		"- first\n" +
+		"- second\n" +
+		"\n" +
+		"(a) one\n" +
+		"\n" +
+		"(b) two\n" +
+		"\n" +
+		"    more\n" +
+		"\n" +
+		".. code:: go\n" +
+		"\n" +
+		"   x := 1\n" +
+		"\n" +
+		"+---+----------+\n" +
+		"| A | Column B |\n" +
+		"+===+==========+\n" +
+		"| spanning     |\n" +
+		"+---+----------+\n" +
+		"| a |          |\n" +
+		"+---+----------+\n" +
+		"\n" +
+		"..\n" +
+		"\n" +
+		"   Quoted\n" +
+		"   text.\n" +
+		"\n" +
+		"   -- Someone\n" +
+		"\n" +
+		"term : type\n" +
+		"   Def.\n" +
+		"\n" +
+		":key: value\n" +
+		"\n" +
+		".. note::\n" +
+		"\n" +
+		"   Careful.\n" +
+		"\n" +
+		".. a comment\n" +
+		"\n" +
+		".. _go: https://go.dev\n" +
+		"\n" +
+		"Sub\n" +
+		"---\n" +
+		"\n" +
+		"----------\n"
+	if output != expected {
+		t.Errorf("unexpected output:\n%s", output)
+	}
+	if !writer.Supports("rst") || writer.Supports("html") {
+		t.Error("wrong supported formats")
+	}
+}
+
+func TestDocinfo(t *testing.T) {
+	input := `<document>
+    <title>T</title>
+    <docinfo>
+        <author>J</author>
+        <authors><author>A</author><author>B</author></authors>
+        <address>1 Road
+Town</address>
+        <contact>j@example.com</contact>
+        <organization>O</organization>
+        <date>2026</date>
+        <status>Draft</status>
+        <revision>2</revision>
+        <version>1.0</version>
+        <copyright>Public domain</copyright>
+    </docinfo>
+    <paragraph>p</paragraph>
+</document>`
+	document, err := docutilsxml.ParseDocument(input, "test.rst", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	output, err := (&Writer{}).Write(document)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := "=\n" +
+		"T\n" +
+		"=\n" +
+		"\n" +
+		":Author: J\n" +
+		":Authors: A; B\n" +
+		":Address:\n" +
+		"   1 Road\n" +
+		"   Town\n" +
+		":Contact: j@example.com\n" +
+		":Organization: O\n" +
+		":Date: 2026\n" +
+		":Status: Draft\n" +
+		":Revision: 2\n" +
+		":Version: 1.0\n" +
+		":Copyright: Public domain\n" +
+		"\n" +
+		"p\n"
+	if output != expected {
+		t.Errorf("unexpected output:\n%s", output)
+	}
+}
+
+func TestWrap(t *testing.T) {
+	settings := &rst.Settings{}
+	settings.Init()
+	settings.WrapWidth = 20
+	settings.SectionAdornments = "#*"
+	input := `<document><section><title>Wrapped</title>
+        <paragraph>A long paragraph, re-wrapped at twenty columns: - and + kept.</paragraph>
+    </section></document>`
+	document, err := docutilsxml.ParseDocument(input, "test.rst", settings)
+	if err != nil {
+		t.Fatal(err)
+	}
+	output, err := (&Writer{}).Write(document)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := "Wrapped\n" +
+		"#######\n" +
+		"\n" +
+		"A long paragraph,\n" +
+		"re-wrapped at twenty\n" +
+		"columns: - and +\n" +
+		"kept.\n"
+	if output != expected {
+		t.Errorf("unexpected output:\n%s", output)
+	}
+}
+
+func TestEscape(t *testing.T) {
+	for text, expected := range map[string]string{
+		"a*b":        `a\*b`,
+		"snake_case": "snake_case",
+		"link_ x":    `link\_ x`,
+		"|sub|":      `\|sub\|`,
+		`back\`:      `back\\`,
+	} {
+		if escaped := Escape(text); escaped != expected {
+			t.Errorf("Escape(%q) = %q, expected %q", text, escaped, expected)
+		}
+	}
+}
+
+// Malformed trees, e.g. parsed from Docutils XML, are written without panics.
+func TestMalformed(t *testing.T) {
+	settings := &rst.Settings{}
+	settings.Init()
+	settings.WarningStream = nil
+	input := `<document>
+    <option_list><option_list_item/><option_list_item><description><paragraph>Only a description.</paragraph></description></option_list_item></option_list>
+    <table><tgroup cols="1"><tbody><row><entry morerows="2"><paragraph>A</paragraph></entry></row></tbody></tgroup></table>
+</document>`
+	document, err := docutilsxml.ParseDocument(input, "test.xml", settings)
+	if err != nil {
+		t.Fatal(err)
+	}
+	output, err := (&Writer{}).Write(document)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := `  Only a description.
+
++---+
+| A |
++---+
+`
+	if output != expected {
+		t.Errorf("unexpected output:\n%s", output)
+	}
+}
+
+// Parse reST source, apply the transforms and write it back.
+func roundTrip(t *testing.T, source string) string {
+	settings := &rst.Settings{}
+	settings.Init()
+	settings.WarningStream = nil
+	document, err := parser.ParseDocument(source, "test.rst", settings)
+	if err != nil {
+		t.Fatal(err)
+	}
+	transformer := &transforms.Transformer{}
+	transformer.Init(document)
+	transformer.PopulateFromComponents(&standalone.Reader{}, &parser.Parser{}, &Writer{})
+	if err := transformer.ApplyTransforms(); err != nil {
+		t.Fatal(err)
+	}
+	output, err := (&Writer{}).Write(document)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return output
+}
+
+// The written source parses to the same document, without the system
+// messages of the first parse.
+func TestRoundTrip(t *testing.T) {
+	source := `Title
+=====
+
+A paragraph with *emphasis* and a reference to nowhere_.
+
+Section
+-------
+
+- item
+`
+	output := roundTrip(t, source)
+	expected := `=====
+Title
+=====
+
+A paragraph with *emphasis* and a reference to nowhere_.
+
+Section
+=======
+
+- item
+`
+	if output != expected {
+		t.Errorf("unexpected output:\n%s", output)
+	}
+	if again := roundTrip(t, output); again != output {
+		t.Errorf("output not stable:\n%s", again)
+	}
+}
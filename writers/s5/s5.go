@@ -0,0 +1,237 @@
+/*
+Package s5 implements a slide show writer, after the S5 HTML writer of
+Python docutils. The presentation is a single self-contained HTML file:
+the style sheet and the script are embedded, no S5 theme files are needed.
+
+Each top-level section is a slide; the elements before the first section
+(title, docinfo, ...) make the title slide. The elements are translated by
+the writers/html package. As in S5:
+
+  - elements with the class "handout" are not shown on the slides, only in
+    the outline view and when printing;
+  - the items of lists with the class "incremental" (the children of other
+    elements with that class) are shown one at a time;
+  - the footer is shown on every slide.
+
+Speaker notes (the "speaker-notes" directive) are hidden, and shown below
+the slides with the "N" key. "T" toggles the outline view; the arrow keys,
+space, Page Up/Down, Home and End navigate.
+
+URL of Python source code:
+http://sourceforge.net/p/docutils/code/HEAD/tree/trunk/docutils/docutils/writers/s5_html/__init__.py
+*/
+package s5
+
+import (
+	"fmt"
+	"strings"
+
+	rst "github.com/siongui/go-rst"
+	"github.com/siongui/go-rst/writers"
+	"github.com/siongui/go-rst/writers/html"
+)
+
+// Formats this writer supports.
+var supported = []string{"s5", "s5_html", "slides"}
+
+const headTemplate = `<!DOCTYPE html>
+<html lang="%s">
+<head>
+<meta charset="utf-8" />
+<meta name="generator" content="go-rst: https://github.com/siongui/go-rst" />
+<meta name="viewport" content="width=device-width, initial-scale=1" />
+<title>%s</title>
+<style>
+%s</style>
+</head>
+<body>
+`
+
+const stylesheet = `body { margin: 0; font-family: sans-serif; }
+.slide { display: none; box-sizing: border-box; height: 100vh; padding: 5vh 6vw 8vh;
+  overflow: auto; font-size: 3vh; }
+.slide.current { display: block; }
+.slide.title-slide { text-align: center; padding-top: 25vh; }
+.slide section { display: contents; }
+.slide .handout, .speaker-notes { display: none; }
+.step-hidden { visibility: hidden; }
+#footer { position: fixed; bottom: 2vh; left: 6vw; right: 6vw; font-size: 2vh; color: gray; }
+#footer p { margin: 0; }
+body.notes .speaker-notes { display: block; margin-top: 2em; padding-top: 0.5em;
+  border-top: 1px dashed gray; font-size: 2.2vh; color: #444; }
+body.outline .slide, body.outline .slide .handout { display: block; }
+body.outline .slide { height: auto; border-bottom: 1px solid gray; }
+body.outline .step-hidden { visibility: visible; }
+body.outline #footer { display: none; }
+@media print {
+  .slide, .slide .handout { display: block !important; height: auto; page-break-after: always; }
+  .step-hidden { visibility: visible; }
+  #footer { display: none; }
+}
+`
+
+const script = `(function() {
+  var slides = document.querySelectorAll(".slide");
+  var current = 0, step = 0;
+  function steps(slide) {
+    var result = [];
+    slide.querySelectorAll(".incremental").forEach(function(element) {
+      for (var i = 0; i < element.children.length; i++) {
+        result.push(element.children[i]);
+      }
+    });
+    return result;
+  }
+  function show(n, s) {
+    if (n < 0 || n >= slides.length) {
+      return;
+    }
+    slides[current].classList.remove("current");
+    current = n;
+    slides[n].classList.add("current");
+    var items = steps(slides[n]);
+    step = s < 0 ? items.length : Math.min(s, items.length);
+    items.forEach(function(item, i) {
+      item.classList.toggle("step-hidden", i >= step);
+    });
+    history.replaceState(null, "", "#" + slides[n].id);
+  }
+  function next() {
+    if (step < steps(slides[current]).length) {
+      show(current, step + 1);
+    } else {
+      show(current + 1, 0);
+    }
+  }
+  function previous() {
+    if (step > 0) {
+      show(current, step - 1);
+    } else {
+      show(current - 1, -1);
+    }
+  }
+  document.addEventListener("keydown", function(event) {
+    switch (event.key) {
+    case "ArrowRight": case "ArrowDown": case "PageDown": case " ": case "Enter":
+      next(); break;
+    case "ArrowLeft": case "ArrowUp": case "PageUp": case "Backspace":
+      previous(); break;
+    case "Home":
+      show(0, 0); break;
+    case "End":
+      show(slides.length - 1, -1); break;
+    case "t": case "T":
+      document.body.classList.toggle("outline"); break;
+    case "n": case "N":
+      document.body.classList.toggle("notes"); break;
+    default:
+      return;
+    }
+    event.preventDefault();
+  });
+  document.addEventListener("click", function(event) {
+    if (!document.body.classList.contains("outline") && !event.target.closest("a")) {
+      next();
+    }
+  });
+  var start = 0;
+  for (var i = 0; i < slides.length; i++) {
+    if ("#" + slides[i].id == location.hash) {
+      start = i;
+    }
+  }
+  show(start, 0);
+})();
+`
+
+/*
+   The slide show writer. Besides "whole", `Parts()` has "title", "body"
+   (the slides) and "footer".
+*/
+type Writer struct {
+	writers.Base
+}
+
+func (w *Writer) Supports(format string) bool {
+	for _, f := range supported {
+		if f == format {
+			return true
+		}
+	}
+	return false
+}
+
+func (w *Writer) Write(document *rst.Document) (string, error) {
+	w.Document = document
+	var slides [][]rst.Node
+	var footer *rst.Element
+	titleSlide := true
+	for _, child := range document.Children() {
+		switch child.TagName() {
+		case "decoration":
+			for _, decoration := range child.(*rst.Element).Children() {
+				if decoration.TagName() == "footer" {
+					footer = decoration.(*rst.Element)
+				}
+			}
+			continue
+		case "section":
+			if len(slides) == 0 {
+				titleSlide = false
+			}
+			slides = append(slides, nil)
+		default:
+			if len(slides) == 0 {
+				slides = append(slides, nil)
+			}
+		}
+		slides[len(slides)-1] = append(slides[len(slides)-1], child)
+	}
+
+	var body []string
+	for i, nodes := range slides {
+		class := "slide"
+		if i == 0 && titleSlide {
+			class += " title-slide"
+		}
+		body = append(body, fmt.Sprintf("<div class=\"%s\" id=\"slide-%d\">\n", class, i))
+		for _, node := range nodes {
+			text, err := html.TranslateNode(document, node)
+			if err != nil {
+				return "", err
+			}
+			body = append(body, text)
+		}
+		body = append(body, "</div>\n")
+	}
+	footerHTML := ""
+	if footer != nil {
+		var parts []string
+		for _, child := range footer.Children() {
+			text, err := html.TranslateNode(document, child)
+			if err != nil {
+				return "", err
+			}
+			parts = append(parts, text)
+		}
+		footerHTML = "<div id=\"footer\">\n" + strings.Join(parts, "") + "</div>\n"
+	}
+
+	title := ""
+	for _, child := range document.Children() {
+		if child.TagName() == "title" {
+			title = child.AsText()
+		}
+	}
+	language := document.Settings().LanguageCode
+	if language == "" {
+		language = "en"
+	}
+	w.Output = fmt.Sprintf(headTemplate, html.Attval(language), html.Encode(title), stylesheet) +
+		strings.Join(body, "") + footerHTML + "<script>\n" + script + "</script>\n</body>\n</html>\n"
+	w.AssembleParts()
+	w.SetPart("title", title)
+	w.SetPart("body", strings.Join(body, ""))
+	w.SetPart("footer", footerHTML)
+	return w.Output, nil
+}
@@ -0,0 +1,84 @@
+package s5
+
+import (
+	"strings"
+	"testing"
+
+	rst "github.com/siongui/go-rst"
+	"github.com/siongui/go-rst/parsers/docutilsxml"
+)
+
+const input = `<document source="talk.rst">
+    <title>My Talk</title>
+    <decoration>
+        <footer><paragraph>Conference 2026</paragraph></footer>
+    </decoration>
+    <section ids="first">
+        <title>First</title>
+        <bullet_list classes="incremental">
+            <list_item><paragraph>one</paragraph></list_item>
+            <list_item><paragraph>two</paragraph></list_item>
+        </bullet_list>
+        <paragraph classes="handout">Only in the handout.</paragraph>
+        <container classes="speaker-notes"><paragraph>Say hello.</paragraph></container>
+    </section>
+    <section ids="second">
+        <title>Second</title>
+        <paragraph>Bye.</paragraph>
+    </section>
+</document>
+`
+
+func TestWriter(t *testing.T) {
+	settings := &rst.Settings{}
+	settings.Init()
+	document, err := docutilsxml.ParseDocument(input, "", settings)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	writer := &Writer{}
+	output, err := writer.Write(document)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := `<div class="slide title-slide" id="slide-0">
+<h1 class="title">My Talk</h1>
+</div>
+<div class="slide" id="slide-1">
+<section id="first">
+<h2>First</h2>
+<ul class="incremental simple">
+<li>one</li>
+<li>two</li>
+</ul>
+<p class="handout">Only in the handout.</p>
+<div class="speaker-notes docutils container">
+Say hello.</div>
+</section>
+</div>
+<div class="slide" id="slide-2">
+<section id="second">
+<h2>Second</h2>
+<p>Bye.</p>
+</section>
+</div>
+`
+	if body := writer.Parts()["body"]; body != expected {
+		t.Errorf("unexpected slides:\n%s", body)
+	}
+	if footer := writer.Parts()["footer"]; footer != "<div id=\"footer\">\nConference 2026</div>\n" {
+		t.Errorf("unexpected footer:\n%s", footer)
+	}
+	for _, s := range []string{"<title>My Talk</title>", "<style>\n", "<script>\n", expected} {
+		if !strings.Contains(output, s) {
+			t.Errorf("%q not in the output", s)
+		}
+	}
+	if strings.Contains(output, " src=") || strings.Contains(output, "<link") {
+		t.Error("the presentation is not self-contained")
+	}
+	if !writer.Supports("s5") || writer.Supports("html") {
+		t.Error("wrong supported formats")
+	}
+}
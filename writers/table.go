@@ -0,0 +1,193 @@
+package writers
+
+import (
+	"fmt"
+	"strings"
+
+	rst "github.com/siongui/go-rst"
+)
+
+/*
+   Layout of a table drawn as text: its cells placed on a grid of rows and
+   columns according to their spans, and the sizes of the columns and rows
+   fitting their content.
+
+   Cells are added row by row with AddCell() and EndRow() (or AddGroup()
+   for a whole tgroup); EndGroup() ends a tgroup.
+*/
+type Table struct {
+	Cols     int
+	Rows     int
+	HeadRows int
+	Cells    []*Cell
+	// Columns occupied by the cells of previous rows, per row.
+	occupied map[int]map[int]bool
+	col      int
+	// Index of the first cell of the current tgroup.
+	group int
+}
+
+// A cell of a table: an entry and the lines of its content.
+type Cell struct {
+	Entry                      *rst.Element
+	Row, Col, Rowspan, Colspan int
+	Lines                      []string
+}
+
+// Set the content of the cell.
+func (c *Cell) SetText(text string) {
+	c.Lines = nil
+	if text = strings.TrimRight(text, "\n"); text != "" {
+		c.Lines = strings.Split(text, "\n")
+	}
+}
+
+/*
+   Add the cell of `entry` to the current row, after the cells spanning
+   from previous rows, and return it.
+*/
+func (tbl *Table) AddCell(entry *rst.Element) *Cell {
+	c := &Cell{Entry: entry, Row: tbl.Rows, Rowspan: 1, Colspan: 1}
+	fmt.Sscan(entry.Get("morerows"), &c.Rowspan)
+	fmt.Sscan(entry.Get("morecols"), &c.Colspan)
+	if entry.HasAttr("morerows") {
+		c.Rowspan++
+	}
+	if entry.HasAttr("morecols") {
+		c.Colspan++
+	}
+	// malformed spans
+	if c.Rowspan < 1 {
+		c.Rowspan = 1
+	}
+	if c.Colspan < 1 {
+		c.Colspan = 1
+	}
+	if tbl.occupied == nil {
+		tbl.occupied = map[int]map[int]bool{}
+	}
+	for tbl.occupied[tbl.Rows][tbl.col] {
+		tbl.col++
+	}
+	c.Col = tbl.col
+	for r := c.Row; r < c.Row+c.Rowspan; r++ {
+		if tbl.occupied[r] == nil {
+			tbl.occupied[r] = map[int]bool{}
+		}
+		for col := c.Col; col < c.Col+c.Colspan; col++ {
+			tbl.occupied[r][col] = true
+		}
+	}
+	tbl.col += c.Colspan
+	if tbl.col > tbl.Cols {
+		tbl.Cols = tbl.col
+	}
+	tbl.Cells = append(tbl.Cells, c)
+	return c
+}
+
+// End the current row, a header row if `head`.
+func (tbl *Table) EndRow(head bool) {
+	tbl.Rows++
+	tbl.col = 0
+	if head {
+		tbl.HeadRows = tbl.Rows
+	}
+}
+
+// End the current tgroup: cells cannot span rows past its last row.
+func (tbl *Table) EndGroup() {
+	for _, c := range tbl.Cells[tbl.group:] {
+		if c.Row+c.Rowspan > tbl.Rows {
+			c.Rowspan = tbl.Rows - c.Row
+		}
+	}
+	tbl.group = len(tbl.Cells)
+	tbl.occupied = nil
+}
+
+// Add the cells of the header and body rows of `tgroup`.
+func (tbl *Table) AddGroup(tgroup *rst.Element) {
+	cols := 0
+	fmt.Sscan(tgroup.Get("cols"), &cols)
+	if cols > tbl.Cols {
+		tbl.Cols = cols
+	}
+	for _, part := range tgroup.Children() {
+		if part.TagName() != "thead" && part.TagName() != "tbody" {
+			continue
+		}
+		for _, row := range part.(*rst.Element).Children() {
+			row, ok := row.(*rst.Element)
+			if !ok {
+				continue
+			}
+			for _, child := range row.Children() {
+				if entry, ok := child.(*rst.Element); ok {
+					tbl.AddCell(entry)
+				}
+			}
+			tbl.EndRow(part.TagName() == "thead")
+		}
+	}
+	tbl.EndGroup()
+}
+
+/*
+   Return the width of the cell `c` with the column `widths`, the columns
+   separated by `gap` characters.
+*/
+func (tbl *Table) SpanWidth(c *Cell, widths []int, gap int) int {
+	width := gap * (c.Colspan - 1)
+	for _, w := range widths[c.Col : c.Col+c.Colspan] {
+		width += w
+	}
+	return width
+}
+
+/*
+   Return the widths of the columns fitting the cells, `width` returning
+   the width a cell needs, the columns separated by `gap` characters. The
+   last column of spanning cells is widened if needed.
+*/
+func (tbl *Table) ColumnWidths(width func(c *Cell) int, gap int) []int {
+	widths := make([]int, tbl.Cols)
+	for _, c := range tbl.Cells {
+		if w := width(c); c.Colspan == 1 && w > widths[c.Col] {
+			widths[c.Col] = w
+		}
+	}
+	for _, c := range tbl.Cells {
+		if w, span := width(c), tbl.SpanWidth(c, widths, gap); w > span {
+			widths[c.Col+c.Colspan-1] += w - span
+		}
+	}
+	return widths
+}
+
+/*
+   Return the heights of the rows fitting the lines of the cells (at least
+   one), the rows separated by one line. The last row of spanning cells is
+   heightened if needed.
+*/
+func (tbl *Table) RowHeights() []int {
+	heights := make([]int, tbl.Rows)
+	for i := range heights {
+		heights[i] = 1
+	}
+	for _, c := range tbl.Cells {
+		if c.Rowspan == 1 && len(c.Lines) > heights[c.Row] {
+			heights[c.Row] = len(c.Lines)
+		}
+	}
+	for _, c := range tbl.Cells {
+		height := c.Rowspan - 1
+		for _, h := range heights[c.Row : c.Row+c.Rowspan] {
+			height += h
+		}
+		if len(c.Lines) > height {
+			heights[c.Row+c.Rowspan-1] += len(c.Lines) - height
+		}
+	}
+	return heights
+}
@@ -0,0 +1,44 @@
+package writers
+
+import (
+	"reflect"
+	"testing"
+
+	rst "github.com/siongui/go-rst"
+	"github.com/siongui/go-rst/parsers/docutilsxml"
+)
+
+func TestTable(t *testing.T) {
+	settings := &rst.Settings{}
+	settings.Init()
+	input := `<tgroup cols="2">
+    <thead><row><entry morecols="1"><paragraph>Head</paragraph></entry></row></thead>
+    <tbody>
+        <row><entry morerows="5"><paragraph>Span</paragraph></entry><entry><paragraph>b</paragraph></entry></row>
+        <row><entry><paragraph>c</paragraph></entry></row>
+    </tbody>
+</tgroup>`
+	tgroup, err := docutilsxml.ParseElement(input, rst.NewDocument("test.xml", settings))
+	if err != nil {
+		t.Fatal(err)
+	}
+	tbl := &Table{}
+	tbl.AddGroup(tgroup)
+	var layout [][4]int
+	for _, c := range tbl.Cells {
+		c.SetText(c.Entry.AsText())
+		layout = append(layout, [4]int{c.Row, c.Col, c.Rowspan, c.Colspan})
+	}
+	// the row span is cut at the last row
+	expected := [][4]int{{0, 0, 1, 2}, {1, 0, 2, 1}, {1, 1, 1, 1}, {2, 1, 1, 1}}
+	if !reflect.DeepEqual(layout, expected) || tbl.Rows != 3 || tbl.HeadRows != 1 {
+		t.Errorf("wrong layout: %v", layout)
+	}
+	width := func(c *Cell) int { return len(c.Lines[0]) }
+	if widths := tbl.ColumnWidths(width, 1); !reflect.DeepEqual(widths, []int{4, 1}) {
+		t.Errorf("wrong column widths: %v", widths)
+	}
+	if heights := tbl.RowHeights(); !reflect.DeepEqual(heights, []int{1, 1, 1}) {
+		t.Errorf("wrong row heights: %v", heights)
+	}
+}
@@ -0,0 +1,1363 @@
+/*
+Package text implements a plain text writer (no Python docutils
+counterpart), for reading documents in a terminal, e.g. the help topics of
+a command line program.
+
+Paragraphs are wrapped to the `TextWidth` setting, lists and block quotes
+are indented, tables are drawn with box-drawing characters, and footnotes
+and citations are collected at the end of the document. With the
+`AnsiStyles` setting, headings and inline markup (emphasis, strong
+emphasis, literals, references) are styled with ANSI escape sequences.
+*/
+package text
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	rst "github.com/siongui/go-rst"
+	"github.com/siongui/go-rst/writers"
+)
+
+// Formats this writer supports.
+var supported = []string{"text", "txt", "plain"}
+
+// The writer. Besides "whole", `Parts()` has "body" (the same output).
+type Writer struct {
+	writers.Base
+}
+
+func (w *Writer) Supports(format string) bool {
+	for _, f := range supported {
+		if f == format {
+			return true
+		}
+	}
+	return false
+}
+
+func (w *Writer) Write(document *rst.Document) (string, error) {
+	w.Document = document
+	translator := &Translator{}
+	translator.Init(document)
+	if err := rst.Walkabout(document, translator); err != nil {
+		return "", err
+	}
+	w.Output = strings.Join(translator.Body, "")
+	w.AssembleParts()
+	w.SetPart("body", w.Output)
+	return w.Output, nil
+}
+
+// ANSI styles (Select Graphic Rendition parameters).
+const (
+	bold      = "1"
+	italic    = "3"
+	underline = "4"
+	cyan      = "36"
+	reset     = "\x1b[0m"
+)
+
+// Placeholder of spaces paragraphs must not be wrapped at.
+const nbsp = '\x00'
+
+var ansiSequence = regexp.MustCompile("\x1b\\[[0-9;]*m")
+
+/*
+   Return the width of `text` in columns: ANSI escape sequences are not
+   counted, East Asian wide characters count twice.
+*/
+func Width(text string) int {
+	width := 0
+	for _, r := range ansiSequence.ReplaceAllString(text, "") {
+		width++
+		if unicode.In(r, unicode.Han, unicode.Hiragana, unicode.Katakana, unicode.Hangul) ||
+			r >= 0xff01 && r <= 0xff60 || r >= 0xffe0 && r <= 0xffe6 {
+			width++
+		}
+	}
+	return width
+}
+
+// Wrap `text` to lines of at most `width` columns (0 for no limit).
+func wrap(text string, width int) []string {
+	var lines []string
+	line := ""
+	for _, word := range strings.Fields(text) {
+		switch {
+		case line == "":
+			line = word
+		case width > 0 && Width(line)+1+Width(word) > width:
+			lines = append(lines, line)
+			line = word
+		default:
+			line += " " + word
+		}
+	}
+	lines = append(lines, line)
+	for i, line := range lines {
+		lines[i] = strings.Replace(line, string(nbsp), " ", -1)
+	}
+	return restyle(lines)
+}
+
+/*
+   Make each line of `lines` self-contained: styles still active at the end
+   of a line are reset there and opened again on the next line, so that
+   indentation and borders added around the lines are not styled.
+*/
+func restyle(lines []string) []string {
+	var active []string
+	for i, line := range lines {
+		prefix := strings.Join(active, "")
+		for _, sequence := range ansiSequence.FindAllString(line, -1) {
+			if sequence == reset {
+				active = nil
+			} else {
+				active = append(active, sequence)
+			}
+		}
+		if len(active) > 0 {
+			line += reset
+		}
+		lines[i] = prefix + line
+	}
+	return lines
+}
+
+/*
+   Return `body` with `label` before its first line, if there is room in
+   `indent` columns, otherwise on a line of its own. The body is indented.
+*/
+func hanging(label, body string, indent int) string {
+	margin := strings.Repeat(" ", indent)
+	body = strings.TrimLeft(body, "\n")
+	if strings.TrimSpace(body) == "" {
+		return label + "\n"
+	}
+	if width := Width(label); width < indent {
+		return writers.PrefixLines(body, label+strings.Repeat(" ", indent-width), margin)
+	}
+	return label + "\n" + writers.PrefixLines(body, margin, margin)
+}
+
+// Return the enumerator of item `n` of the enumerated list `node`.
+func enumerator(node *rst.Element, n int) string {
+	var label string
+	switch node.Get("enumtype") {
+	case "loweralpha":
+		label = string(rune('a' + n - 1))
+	case "upperalpha":
+		label = string(rune('A' + n - 1))
+	case "lowerroman":
+		label = strings.ToLower(toRoman(n))
+	case "upperroman":
+		label = toRoman(n)
+	default:
+		label = fmt.Sprint(n)
+	}
+	suffix := node.Get("suffix")
+	if suffix == "" {
+		suffix = "."
+	}
+	return node.Get("prefix") + label + suffix
+}
+
+var romanNumerals = []struct {
+	value   int
+	numeral string
+}{
+	{1000, "M"}, {900, "CM"}, {500, "D"}, {400, "CD"}, {100, "C"}, {90, "XC"},
+	{50, "L"}, {40, "XL"}, {10, "X"}, {9, "IX"}, {5, "V"}, {4, "IV"}, {1, "I"},
+}
+
+func toRoman(n int) string {
+	var b strings.Builder
+	for _, r := range romanNumerals {
+		for n >= r.value {
+			b.WriteString(r.numeral)
+			n -= r.value
+		}
+	}
+	return b.String()
+}
+
+// Are the items of `list` single paragraphs (written without blank lines)?
+func isSimpleList(list *rst.Element) bool {
+	for _, item := range list.Children() {
+		children := item.(*rst.Element).Children()
+		if len(children) > 1 || len(children) == 1 && children[0].TagName() != "paragraph" {
+			return false
+		}
+	}
+	return true
+}
+
+// Bullets of the nesting levels of bullet lists.
+var bullets = []string{"•", "◦", "▪"}
+
+// Adornments of the section titles; the document title is overlined.
+var adornments = []string{"=", "-", "~", "·"}
+
+// Elements of the docinfo with their language label as field name.
+var bibliographicFields = map[string]bool{
+	"address": true, "author": true, "authors": true, "contact": true, "copyright": true,
+	"date": true, "organization": true, "revision": true, "status": true, "version": true,
+}
+
+// Indentation of block quotes, definitions, admonition and sidebar bodies.
+const indentation = 4
+
+// Field names and option groups longer than this are on a line of their own.
+const maxLabelWidth = 20
+
+/*
+   Translates the document tree to plain text. Visit and depart methods are
+   called by `rst.Walkabout()`.
+
+   Blocks nested in containers are translated into their own buffer, at the
+   width remaining after the indentation, and indented when the container
+   is departed.
+*/
+type Translator struct {
+	document *rst.Document
+	settings *rst.Settings
+	language *rst.Language
+
+	writers.Output
+	// Stack of the widths text is wrapped to (0 for no limit), one per
+	// buffer.
+	widths []int
+	// Active ANSI styles.
+	styles []string
+
+	// Stack of the label widths of field lists, docinfos and option lists.
+	indents []int
+
+	// The rendered titles of topics, admonitions, sidebars.
+	titles map[*rst.Element]string
+
+	// Footnotes and citations, written at the end of the document.
+	notes []string
+
+	sectionLevel int
+	lineIndent   int
+}
+
+func (t *Translator) Init(document *rst.Document) {
+	t.document = document
+	t.settings = document.Settings()
+	t.language = rst.GetLanguage(t.settings.LanguageCode)
+	t.widths = []int{t.settings.TextWidth}
+	t.titles = map[*rst.Element]string{}
+}
+
+func (t *Translator) UnknownVisit(node rst.Node) error {
+	return &NotImplementedError{fmt.Sprintf("visiting unknown node type: %s", node.TagName())}
+}
+
+func (t *Translator) UnknownDeparture(node rst.Node) error {
+	return &NotImplementedError{fmt.Sprintf("departing unknown node type: %s", node.TagName())}
+}
+
+// Start a buffer for the content of a container, `indent` columns
+// narrower.
+func (t *Translator) pushBuffer(indent int) {
+	t.PushBuffer()
+	width := t.width()
+	if width > 0 {
+		width -= indent
+		if width < 10 {
+			width = 10
+		}
+	}
+	t.widths = append(t.widths, width)
+}
+
+// Return the content of the buffer started last, and drop it.
+func (t *Translator) popBuffer() string {
+	t.widths = t.widths[:len(t.widths)-1]
+	return t.PopBuffer()
+}
+
+// Return the width text is wrapped to, 0 for no limit.
+func (t *Translator) width() int {
+	return t.widths[len(t.widths)-1]
+}
+
+// Return `text` in `style`, if styling is enabled.
+func (t *Translator) styled(text, style string) string {
+	if !t.settings.AnsiStyles || text == "" {
+		return text
+	}
+	return "\x1b[" + style + "m" + text + reset
+}
+
+// Start writing inline text in `style`.
+func (t *Translator) openStyle(style string) {
+	if !t.settings.AnsiStyles {
+		return
+	}
+	t.styles = append(t.styles, style)
+	t.Write("\x1b[" + style + "m")
+}
+
+// Stop writing in the last style, and restore the enclosing ones.
+func (t *Translator) closeStyle() {
+	if !t.settings.AnsiStyles {
+		return
+	}
+	t.styles = t.styles[:len(t.styles)-1]
+	t.Write(reset)
+	for _, style := range t.styles {
+		t.Write("\x1b[" + style + "m")
+	}
+}
+
+// Return the inline content of `node` rendered (on a single line).
+func (t *Translator) renderInline(node *rst.Element) (string, error) {
+	t.pushBuffer(0)
+	for _, child := range node.Children() {
+		if err := rst.Walkabout(child, t); err != nil {
+			t.popBuffer()
+			return "", err
+		}
+	}
+	return strings.Join(wrap(t.popBuffer(), 0), " "), nil
+}
+
+// Return the blocks of `node` rendered at `width` (0 for no limit).
+func (t *Translator) renderBlocks(node *rst.Element, width int) (string, error) {
+	t.PushBuffer()
+	t.widths = append(t.widths, width)
+	for _, child := range node.Children() {
+		if err := rst.Walkabout(child, t); err != nil {
+			t.popBuffer()
+			return "", err
+		}
+	}
+	return t.popBuffer(), nil
+}
+
+// Write the wrapped content of the current buffer as a paragraph.
+func (t *Translator) departParagraph() {
+	width := t.width()
+	text := t.popBuffer()
+	t.WriteBlock(strings.Join(wrap(text, width), "\n") + "\n")
+}
+
+func (t *Translator) VisitText(node *rst.Text) {
+	t.Write(node.AsText())
+}
+
+func (t *Translator) DepartText(node *rst.Text) {}
+
+func (t *Translator) VisitAbbreviation(node *rst.Element)  {}
+func (t *Translator) DepartAbbreviation(node *rst.Element) {}
+
+func (t *Translator) VisitAcronym(node *rst.Element)  {}
+func (t *Translator) DepartAcronym(node *rst.Element) {}
+
+func (t *Translator) VisitAddress(node *rst.Element) {
+	t.visitDocinfoItem(node)
+}
+
+func (t *Translator) DepartAddress(node *rst.Element) {
+	t.departDocinfoItem(node)
+}
+
+/*
+   Admonitions are written as their (bold) title followed by the indented
+   content.
+*/
+func (t *Translator) visitAdmonition(node *rst.Element, title string) {
+	t.Push(title)
+	t.pushBuffer(indentation)
+}
+
+func (t *Translator) departAdmonition(node *rst.Element) {
+	body := t.popBuffer()
+	title := t.Pop()
+	if title == "" {
+		title = t.titles[node]
+	}
+	t.WriteBlock(hanging(t.styled(title+":", bold), body, indentation))
+}
+
+func (t *Translator) VisitAdmonition(node *rst.Element) {
+	t.visitAdmonition(node, "")
+}
+
+func (t *Translator) DepartAdmonition(node *rst.Element) {
+	t.departAdmonition(node)
+}
+
+func (t *Translator) visitSpecificAdmonition(node *rst.Element) {
+	t.visitAdmonition(node, t.language.Labels[node.TagName()])
+}
+
+func (t *Translator) VisitAttention(node *rst.Element) {
+	t.visitSpecificAdmonition(node)
+}
+
+func (t *Translator) DepartAttention(node *rst.Element) {
+	t.departAdmonition(node)
+}
+
+func (t *Translator) VisitCaution(node *rst.Element) {
+	t.visitSpecificAdmonition(node)
+}
+
+func (t *Translator) DepartCaution(node *rst.Element) {
+	t.departAdmonition(node)
+}
+
+func (t *Translator) VisitDanger(node *rst.Element) {
+	t.visitSpecificAdmonition(node)
+}
+
+func (t *Translator) DepartDanger(node *rst.Element) {
+	t.departAdmonition(node)
+}
+
+func (t *Translator) VisitError(node *rst.Element) {
+	t.visitSpecificAdmonition(node)
+}
+
+func (t *Translator) DepartError(node *rst.Element) {
+	t.departAdmonition(node)
+}
+
+func (t *Translator) VisitHint(node *rst.Element) {
+	t.visitSpecificAdmonition(node)
+}
+
+func (t *Translator) DepartHint(node *rst.Element) {
+	t.departAdmonition(node)
+}
+
+func (t *Translator) VisitImportant(node *rst.Element) {
+	t.visitSpecificAdmonition(node)
+}
+
+func (t *Translator) DepartImportant(node *rst.Element) {
+	t.departAdmonition(node)
+}
+
+func (t *Translator) VisitNote(node *rst.Element) {
+	t.visitSpecificAdmonition(node)
+}
+
+func (t *Translator) DepartNote(node *rst.Element) {
+	t.departAdmonition(node)
+}
+
+func (t *Translator) VisitTip(node *rst.Element) {
+	t.visitSpecificAdmonition(node)
+}
+
+func (t *Translator) DepartTip(node *rst.Element) {
+	t.departAdmonition(node)
+}
+
+func (t *Translator) VisitWarning(node *rst.Element) {
+	t.visitSpecificAdmonition(node)
+}
+
+func (t *Translator) DepartWarning(node *rst.Element) {
+	t.departAdmonition(node)
+}
+
+func (t *Translator) VisitAttribution(node *rst.Element) {
+	t.pushBuffer(0)
+	t.Write("— ")
+}
+
+func (t *Translator) DepartAttribution(node *rst.Element) {
+	t.departParagraph()
+}
+
+func (t *Translator) VisitAuthor(node *rst.Element) {
+	if node.Parent().TagName() == "authors" {
+		if node.Parent().Index(node) > 0 {
+			t.Write("; ")
+		}
+		return
+	}
+	t.visitDocinfoItem(node)
+}
+
+func (t *Translator) DepartAuthor(node *rst.Element) {
+	if node.Parent().TagName() != "authors" {
+		t.departDocinfoItem(node)
+	}
+}
+
+func (t *Translator) VisitAuthors(node *rst.Element) {
+	t.visitDocinfoItem(node)
+}
+
+func (t *Translator) DepartAuthors(node *rst.Element) {
+	t.departDocinfoItem(node)
+}
+
+func (t *Translator) VisitBlockQuote(node *rst.Element) {
+	t.pushBuffer(indentation)
+}
+
+func (t *Translator) DepartBlockQuote(node *rst.Element) {
+	margin := strings.Repeat(" ", indentation)
+	t.WriteBlock(writers.PrefixLines(t.popBuffer(), margin, margin))
+}
+
+func (t *Translator) VisitBulletList(node *rst.Element) {
+	t.StartBlock()
+}
+
+func (t *Translator) DepartBulletList(node *rst.Element) {}
+
+func (t *Translator) VisitCaption(node *rst.Element) {
+	t.pushBuffer(0)
+	t.openStyle(italic)
+}
+
+func (t *Translator) DepartCaption(node *rst.Element) {
+	t.closeStyle()
+	t.departParagraph()
+}
+
+// Return the label of a footnote or citation.
+func label(node *rst.Element) string {
+	if node.Len() > 0 && node.Children()[0].TagName() == "label" {
+		return node.Children()[0].AsText()
+	}
+	return ""
+}
+
+func (t *Translator) VisitCitation(node *rst.Element) {
+	t.visitFootnote(node)
+}
+
+func (t *Translator) DepartCitation(node *rst.Element) {
+	t.departFootnote(node)
+}
+
+func (t *Translator) VisitCitationReference(node *rst.Element) error {
+	t.Write("[", node.AsText(), "]")
+	return &rst.SkipNode{}
+}
+
+func (t *Translator) VisitClassifier(node *rst.Element) {
+	t.Write(" : ")
+	t.openStyle(italic)
+}
+
+func (t *Translator) DepartClassifier(node *rst.Element) {
+	t.closeStyle()
+}
+
+func (t *Translator) VisitComment(node *rst.Element) error {
+	return &rst.SkipNode{}
+}
+
+func (t *Translator) VisitCompound(node *rst.Element)  {}
+func (t *Translator) DepartCompound(node *rst.Element) {}
+
+func (t *Translator) VisitContact(node *rst.Element) {
+	t.visitDocinfoItem(node)
+}
+
+func (t *Translator) DepartContact(node *rst.Element) {
+	t.departDocinfoItem(node)
+}
+
+func (t *Translator) VisitContainer(node *rst.Element)  {}
+func (t *Translator) DepartContainer(node *rst.Element) {}
+
+func (t *Translator) VisitCopyright(node *rst.Element) {
+	t.visitDocinfoItem(node)
+}
+
+func (t *Translator) DepartCopyright(node *rst.Element) {
+	t.departDocinfoItem(node)
+}
+
+func (t *Translator) VisitDate(node *rst.Element) {
+	t.visitDocinfoItem(node)
+}
+
+func (t *Translator) DepartDate(node *rst.Element) {
+	t.departDocinfoItem(node)
+}
+
+// The header and footer are meant for pages.
+func (t *Translator) VisitDecoration(node *rst.Element) error {
+	return &rst.SkipNode{}
+}
+
+func (t *Translator) VisitDefinition(node *rst.Element) {
+	t.pushBuffer(indentation)
+}
+
+func (t *Translator) DepartDefinition(node *rst.Element) {
+	margin := strings.Repeat(" ", indentation)
+	t.Write("\n", writers.PrefixLines(t.popBuffer(), margin, margin))
+}
+
+func (t *Translator) VisitDefinitionList(node *rst.Element) {
+	t.StartBlock()
+}
+
+func (t *Translator) DepartDefinitionList(node *rst.Element) {}
+
+func (t *Translator) VisitDefinitionListItem(node *rst.Element) {
+	if node.Parent().Index(node) > 0 {
+		t.Write("\n")
+	}
+}
+
+func (t *Translator) DepartDefinitionListItem(node *rst.Element) {}
+
+func (t *Translator) VisitDescription(node *rst.Element) {
+	t.pushBuffer(t.indents[len(t.indents)-1])
+}
+
+func (t *Translator) DepartDescription(node *rst.Element) {
+	body := t.popBuffer()
+	t.Write(hanging(t.Peek(), body, t.indents[len(t.indents)-1]))
+}
+
+/*
+   Return the width of the labels of a field list, docinfo or option list,
+   followed by `gap` spaces.
+*/
+func labelWidth(labels []string, gap int) int {
+	width := 0
+	for _, label := range labels {
+		if w := Width(label) + gap; w > width && w <= maxLabelWidth {
+			width = w
+		}
+	}
+	if width == 0 {
+		width = indentation
+	}
+	return width
+}
+
+/*
+   The docinfo is written as a field list, with the language labels as
+   field names.
+*/
+func (t *Translator) VisitDocinfo(node *rst.Element) {
+	var labels []string
+	for _, child := range node.Children() {
+		if bibliographicFields[child.TagName()] {
+			labels = append(labels, t.language.Labels[child.TagName()]+":")
+		} else if field, ok := child.(*rst.Element); ok && field.Len() > 0 {
+			labels = append(labels, field.Children()[0].AsText()+":")
+		}
+	}
+	t.indents = append(t.indents, labelWidth(labels, 1))
+	t.StartBlock()
+}
+
+func (t *Translator) DepartDocinfo(node *rst.Element) {
+	t.indents = t.indents[:len(t.indents)-1]
+}
+
+func (t *Translator) visitDocinfoItem(node *rst.Element) {
+	t.pushBuffer(t.indents[len(t.indents)-1])
+}
+
+func (t *Translator) departDocinfoItem(node *rst.Element) {
+	width := t.width()
+	text := t.popBuffer()
+	if node.TagName() != "address" {
+		text = strings.Join(wrap(text, width), "\n")
+	}
+	name := t.styled(t.language.Labels[node.TagName()]+":", bold)
+	t.Write(hanging(name, text, t.indents[len(t.indents)-1]))
+}
+
+func (t *Translator) VisitDoctestBlock(node *rst.Element) error {
+	return t.VisitLiteralBlock(node)
+}
+
+func (t *Translator) VisitDocument(node *rst.Element) {}
+
+// Write the footnotes and citations.
+func (t *Translator) DepartDocument(node *rst.Element) {
+	if len(t.notes) == 0 {
+		return
+	}
+	t.WriteBlock(strings.Repeat("─", 20) + "\n")
+	for _, note := range t.notes {
+		t.WriteBlock(note)
+	}
+}
+
+func (t *Translator) VisitEmphasis(node *rst.Element) {
+	t.openStyle(italic)
+}
+
+func (t *Translator) DepartEmphasis(node *rst.Element) {
+	t.closeStyle()
+}
+
+func (t *Translator) VisitEnumeratedList(node *rst.Element) {
+	t.StartBlock()
+}
+
+func (t *Translator) DepartEnumeratedList(node *rst.Element) {}
+
+func (t *Translator) VisitField(node *rst.Element)  {}
+func (t *Translator) DepartField(node *rst.Element) {}
+
+func (t *Translator) VisitFieldBody(node *rst.Element) {
+	t.pushBuffer(t.indents[len(t.indents)-1])
+}
+
+func (t *Translator) DepartFieldBody(node *rst.Element) {
+	body := t.popBuffer()
+	t.Write(hanging(t.Peek(), body, t.indents[len(t.indents)-1]))
+}
+
+func (t *Translator) VisitFieldList(node *rst.Element) {
+	var labels []string
+	for _, field := range node.Children() {
+		labels = append(labels, field.(*rst.Element).Children()[0].AsText()+":")
+	}
+	t.indents = append(t.indents, labelWidth(labels, 1))
+	t.StartBlock()
+}
+
+func (t *Translator) DepartFieldList(node *rst.Element) {
+	t.indents = t.indents[:len(t.indents)-1]
+}
+
+func (t *Translator) VisitFieldName(node *rst.Element) error {
+	name, err := t.renderInline(node)
+	if err != nil {
+		return err
+	}
+	t.Push(t.styled(name+":", bold))
+	return &rst.SkipNode{}
+}
+
+func (t *Translator) VisitFigure(node *rst.Element)  {}
+func (t *Translator) DepartFigure(node *rst.Element) {}
+
+func (t *Translator) visitFootnote(node *rst.Element) {
+	t.pushBuffer(Width(label(node)) + 3)
+}
+
+// Footnotes and citations are written at the end, with a hanging label.
+func (t *Translator) departFootnote(node *rst.Element) {
+	text := "[" + label(node) + "]"
+	t.notes = append(t.notes, hanging(text, t.popBuffer(), Width(text)+1))
+}
+
+func (t *Translator) VisitFootnote(node *rst.Element) {
+	t.visitFootnote(node)
+}
+
+func (t *Translator) DepartFootnote(node *rst.Element) {
+	t.departFootnote(node)
+}
+
+func (t *Translator) VisitFootnoteReference(node *rst.Element) error {
+	t.Write("[", node.AsText(), "]")
+	return &rst.SkipNode{}
+}
+
+func (t *Translator) VisitGenerated(node *rst.Element)  {}
+func (t *Translator) DepartGenerated(node *rst.Element) {}
+
+// Images are written as their alternate text.
+func (t *Translator) VisitImage(node *rst.Element) error {
+	alt := node.Get("alt")
+	if alt == "" {
+		alt = node.Get("uri")
+	}
+	text := "[image: " + alt + "]"
+	if parent := node.Parent(); parent.Is(rst.TextElementClass) ||
+		parent.TagName() == "reference" && parent.Parent().Is(rst.TextElementClass) {
+		t.Write(text)
+	} else {
+		t.WriteBlock(text + "\n")
+	}
+	return &rst.SkipNode{}
+}
+
+func (t *Translator) VisitInline(node *rst.Element)  {}
+func (t *Translator) DepartInline(node *rst.Element) {}
+
+// Labels are written by the footnote or citation.
+func (t *Translator) VisitLabel(node *rst.Element) error {
+	return &rst.SkipNode{}
+}
+
+func (t *Translator) VisitLegend(node *rst.Element)  {}
+func (t *Translator) DepartLegend(node *rst.Element) {}
+
+func (t *Translator) VisitLine(node *rst.Element) {
+	t.pushBuffer(0)
+}
+
+// Lines are not wrapped.
+func (t *Translator) DepartLine(node *rst.Element) {
+	text := strings.Replace(t.popBuffer(), string(nbsp), " ", -1)
+	if strings.TrimSpace(text) == "" {
+		t.Write("\n")
+		return
+	}
+	indent := strings.Repeat(" ", indentation*t.lineIndent)
+	t.Write(writers.PrefixLines(strings.Join(restyle(strings.Split(text, "\n")), "\n"), indent, indent))
+}
+
+func (t *Translator) VisitLineBlock(node *rst.Element) {
+	if node.Parent().TagName() == "line_block" {
+		t.lineIndent++
+		return
+	}
+	t.StartBlock()
+}
+
+func (t *Translator) DepartLineBlock(node *rst.Element) {
+	if node.Parent().TagName() == "line_block" {
+		t.lineIndent--
+	}
+}
+
+// Return the marker of `item`, and the width of the markers of its list.
+func marker(item *rst.Element) (string, int) {
+	list := item.Parent()
+	if list.TagName() == "bullet_list" {
+		depth := 0
+		for parent := list.Parent(); parent != nil; parent = parent.Parent() {
+			if parent.TagName() == "bullet_list" {
+				depth++
+			}
+		}
+		return bullets[depth%len(bullets)], 2
+	}
+	start := 1
+	fmt.Sscan(list.Get("start"), &start)
+	width := 0
+	for n := start; n < start+list.Len(); n++ {
+		if w := Width(enumerator(list, n)) + 1; w > width {
+			width = w
+		}
+	}
+	return enumerator(list, start+list.Index(item)), width
+}
+
+func (t *Translator) VisitListItem(node *rst.Element) {
+	_, width := marker(node)
+	t.pushBuffer(width)
+}
+
+func (t *Translator) DepartListItem(node *rst.Element) {
+	text := t.popBuffer()
+	list := node.Parent()
+	if list.Index(node) > 0 && !isSimpleList(list) {
+		t.Write("\n")
+	}
+	label, width := marker(node)
+	if strings.TrimSpace(text) == "" {
+		t.Write(label, "\n")
+		return
+	}
+	label += strings.Repeat(" ", width-Width(label))
+	t.Write(writers.PrefixLines(text, label, strings.Repeat(" ", width)))
+}
+
+func (t *Translator) VisitLiteral(node *rst.Element) error {
+	t.openStyle(cyan)
+	t.Write(strings.Replace(node.AsText(), " ", string(nbsp), -1))
+	t.closeStyle()
+	return &rst.SkipNode{}
+}
+
+// Literal blocks are indented, not wrapped.
+func (t *Translator) VisitLiteralBlock(node *rst.Element) error {
+	lines := strings.Split(strings.TrimRight(node.AsText(), "\n"), "\n")
+	margin := strings.Repeat(" ", indentation)
+	for i, line := range lines {
+		if line != "" {
+			lines[i] = margin + t.styled(line, cyan)
+		}
+	}
+	t.WriteBlock(strings.Join(lines, "\n") + "\n")
+	return &rst.SkipNode{}
+}
+
+func (t *Translator) VisitMath(node *rst.Element) error {
+	return t.VisitLiteral(node)
+}
+
+func (t *Translator) VisitMathBlock(node *rst.Element) error {
+	return t.VisitLiteralBlock(node)
+}
+
+func (t *Translator) VisitOptionList(node *rst.Element) {
+	var labels []string
+	for _, item := range node.Children() {
+		item, ok := item.(*rst.Element)
+		if !ok {
+			continue
+		}
+		for _, child := range item.Children() {
+			if group, ok := child.(*rst.Element); ok && group.TagName() == "option_group" {
+				labels = append(labels, optionGroup(group))
+			}
+		}
+	}
+	t.indents = append(t.indents, labelWidth(labels, 2))
+	t.StartBlock()
+}
+
+func (t *Translator) DepartOptionList(node *rst.Element) {
+	t.indents = t.indents[:len(t.indents)-1]
+}
+
+// Return the text of an option group: "-o FILE, --output=FILE".
+func optionGroup(node *rst.Element) string {
+	var options []string
+	for _, child := range node.Children() {
+		e, ok := child.(*rst.Element)
+		if !ok {
+			continue
+		}
+		option := ""
+		for _, part := range e.Children() {
+			if part.TagName() == "option_argument" {
+				option += part.(*rst.Element).Get("delimiter")
+			}
+			option += part.AsText()
+		}
+		options = append(options, option)
+	}
+	return strings.Join(options, ", ")
+}
+
+// The label of the item is its option group, if any.
+func (t *Translator) VisitOptionListItem(node *rst.Element) {
+	t.Push("")
+}
+
+func (t *Translator) DepartOptionListItem(node *rst.Element) {
+	t.Pop()
+}
+
+func (t *Translator) VisitOptionGroup(node *rst.Element) error {
+	t.Pop()
+	t.Push(t.styled(optionGroup(node), bold))
+	return &rst.SkipNode{}
+}
+
+func (t *Translator) VisitOrganization(node *rst.Element) {
+	t.visitDocinfoItem(node)
+}
+
+func (t *Translator) DepartOrganization(node *rst.Element) {
+	t.departDocinfoItem(node)
+}
+
+func (t *Translator) VisitParagraph(node *rst.Element) {
+	t.pushBuffer(0)
+}
+
+func (t *Translator) DepartParagraph(node *rst.Element) {
+	t.departParagraph()
+}
+
+func (t *Translator) VisitPending(node *rst.Element) error {
+	return &rst.SkipNode{}
+}
+
+func (t *Translator) VisitProblematic(node *rst.Element)  {}
+func (t *Translator) DepartProblematic(node *rst.Element) {}
+
+// Only raw text is written.
+func (t *Translator) VisitRaw(node *rst.Element) error {
+	if writers.RawFormatMatches(node, supported...) {
+		if node.Parent().Is(rst.TextElementClass) {
+			t.Write(node.AsText())
+		} else {
+			t.WriteBlock(strings.TrimRight(node.AsText(), "\n") + "\n")
+		}
+	}
+	return &rst.SkipNode{}
+}
+
+/*
+   External references are written as their text followed by the URI,
+   unless they are the same.
+*/
+func (t *Translator) VisitReference(node *rst.Element) {
+	t.openStyle(underline)
+}
+
+func (t *Translator) DepartReference(node *rst.Element) {
+	t.closeStyle()
+	uri := node.Get("refuri")
+	if text := node.AsText(); uri != "" && uri != text && uri != "mailto:"+text && node.Parent().Is(rst.TextElementClass) {
+		t.Write(" <", uri, ">")
+	}
+}
+
+func (t *Translator) VisitRevision(node *rst.Element) {
+	t.visitDocinfoItem(node)
+}
+
+func (t *Translator) DepartRevision(node *rst.Element) {
+	t.departDocinfoItem(node)
+}
+
+func (t *Translator) VisitRubric(node *rst.Element) {
+	t.pushBuffer(0)
+	t.openStyle(bold)
+}
+
+func (t *Translator) DepartRubric(node *rst.Element) {
+	t.closeStyle()
+	t.departParagraph()
+}
+
+func (t *Translator) VisitSection(node *rst.Element) {
+	t.sectionLevel++
+}
+
+func (t *Translator) DepartSection(node *rst.Element) {
+	t.sectionLevel--
+}
+
+func (t *Translator) VisitSidebar(node *rst.Element) {
+	t.visitAdmonition(node, "")
+}
+
+func (t *Translator) DepartSidebar(node *rst.Element) {
+	t.departAdmonition(node)
+}
+
+func (t *Translator) VisitStatus(node *rst.Element) {
+	t.visitDocinfoItem(node)
+}
+
+func (t *Translator) DepartStatus(node *rst.Element) {
+	t.departDocinfoItem(node)
+}
+
+func (t *Translator) VisitStrong(node *rst.Element) {
+	t.openStyle(bold)
+}
+
+func (t *Translator) DepartStrong(node *rst.Element) {
+	t.closeStyle()
+}
+
+func (t *Translator) VisitSubscript(node *rst.Element)  {}
+func (t *Translator) DepartSubscript(node *rst.Element) {}
+
+func (t *Translator) VisitSubstitutionDefinition(node *rst.Element) error {
+	return &rst.SkipNode{}
+}
+
+func (t *Translator) VisitSubtitle(node *rst.Element) error {
+	switch node.Parent().TagName() {
+	case "document":
+		return t.writeTitle(node, false, "-")
+	case "sidebar":
+		text, err := t.renderInline(node)
+		if err != nil {
+			return err
+		}
+		t.WriteBlock(t.styled(text, italic) + "\n")
+	}
+	return &rst.SkipNode{}
+}
+
+func (t *Translator) VisitSuperscript(node *rst.Element)  {}
+func (t *Translator) DepartSuperscript(node *rst.Element) {}
+
+func (t *Translator) VisitSystemMessage(node *rst.Element) {
+	line := ""
+	if node.HasAttr("line") {
+		line = ", line " + node.Get("line")
+	}
+	t.visitAdmonition(node, fmt.Sprintf("System Message: %s/%s (%s%s)",
+		node.Get("type"), node.Get("level"), node.Get("source"), line))
+}
+
+func (t *Translator) DepartSystemMessage(node *rst.Element) {
+	t.departAdmonition(node)
+}
+
+/*
+   Tables are drawn with box-drawing characters. Columns are sized to their
+   content; if the table is too wide, the widest columns are narrowed and
+   their cells wrapped.
+*/
+func (t *Translator) VisitTable(node *rst.Element) error {
+	tbl := &writers.Table{}
+	for _, child := range node.Children() {
+		if child.TagName() == "title" {
+			title, err := t.renderInline(child.(*rst.Element))
+			if err != nil {
+				return err
+			}
+			t.WriteBlock(t.styled(title, bold) + "\n")
+		} else if child.TagName() == "tgroup" {
+			tbl.AddGroup(child.(*rst.Element))
+			break
+		}
+	}
+	if len(tbl.Cells) == 0 {
+		return &rst.SkipNode{}
+	}
+	notes := len(t.notes)
+	for _, c := range tbl.Cells {
+		text, err := t.renderBlocks(c.Entry, 0)
+		if err != nil {
+			return err
+		}
+		c.SetText(text)
+	}
+	widths := tbl.ColumnWidths(cellWidth, 3)
+	if available := t.width() - 3*tbl.Cols - 1; t.width() > 0 && sum(widths) > available {
+		for sum(widths) > available {
+			widest := 0
+			for i, w := range widths {
+				if w > widths[widest] {
+					widest = i
+				}
+			}
+			if widths[widest] <= 6 {
+				break
+			}
+			widths[widest]--
+		}
+		// the footnotes of the cells are collected again
+		t.notes = t.notes[:notes]
+		for _, c := range tbl.Cells {
+			text, err := t.renderBlocks(c.Entry, tbl.SpanWidth(c, widths, 3))
+			if err != nil {
+				return err
+			}
+			c.SetText(text)
+		}
+		// words longer than the columns
+		for i, w := range tbl.ColumnWidths(cellWidth, 3) {
+			if w > widths[i] {
+				widths[i] = w
+			}
+		}
+	}
+	t.WriteBlock(drawTable(tbl, widths))
+	return &rst.SkipNode{}
+}
+
+func sum(values []int) int {
+	total := 0
+	for _, v := range values {
+		total += v
+	}
+	return total
+}
+
+// Return the content width of `c`, at least 1.
+func cellWidth(c *writers.Cell) int {
+	width := 1
+	for _, line := range c.Lines {
+		if w := Width(line); w > width {
+			width = w
+		}
+	}
+	return width
+}
+
+// Box-drawing characters of the junctions, indexed by the connected
+// directions (up, down, left, right bits).
+var (
+	junctions       = []rune(" ╵╷│╴┘┐┤╶└┌├─┴┬┼")
+	doubleJunctions = []rune(" ╵╷│═╛╕╡═╘╒╞═╧╤╪")
+)
+
+// Draw the table `tbl` with the column `widths`.
+func drawTable(tbl *writers.Table, widths []int) string {
+	heights := tbl.RowHeights()
+	xs := []int{0}
+	for _, w := range widths {
+		xs = append(xs, xs[len(xs)-1]+w+3)
+	}
+	ys := []int{0}
+	for _, h := range heights {
+		ys = append(ys, ys[len(ys)-1]+h+1)
+	}
+	canvas := make([][]rune, ys[len(ys)-1]+1)
+	for y := range canvas {
+		canvas[y] = []rune(strings.Repeat(" ", xs[len(xs)-1]+1))
+	}
+	// the content lines, by line and column
+	content := map[int]map[int]string{}
+	for _, c := range tbl.Cells {
+		x0, x1 := xs[c.Col], xs[c.Col+c.Colspan]
+		y0, y1 := ys[c.Row], ys[c.Row+c.Rowspan]
+		for _, y := range []int{y0, y1} {
+			for x := x0; x <= x1; x++ {
+				if canvas[y][x] != '+' {
+					canvas[y][x] = '─'
+				}
+			}
+			canvas[y][x0], canvas[y][x1] = '+', '+'
+		}
+		for y := y0 + 1; y < y1; y++ {
+			canvas[y][x0], canvas[y][x1] = '│', '│'
+		}
+		for i, line := range c.Lines {
+			if content[y0+1+i] == nil {
+				content[y0+1+i] = map[int]string{}
+			}
+			content[y0+1+i][x0+2] = line
+		}
+	}
+	var lines []string
+	for y, row := range canvas {
+		double := tbl.HeadRows > 0 && y == ys[tbl.HeadRows]
+		var b strings.Builder
+		for x := 0; x < len(row); x++ {
+			if line, ok := content[y][x]; ok {
+				b.WriteString(line)
+				x += Width(line) - 1
+				continue
+			}
+			r := row[x]
+			switch {
+			case r == '+':
+				index := 0
+				if y > 0 && (canvas[y-1][x] == '│' || canvas[y-1][x] == '+') {
+					index |= 1
+				}
+				if y+1 < len(canvas) && (canvas[y+1][x] == '│' || canvas[y+1][x] == '+') {
+					index |= 2
+				}
+				if x > 0 && (row[x-1] == '─' || row[x-1] == '+') {
+					index |= 4
+				}
+				if x+1 < len(row) && (row[x+1] == '─' || row[x+1] == '+') {
+					index |= 8
+				}
+				if double {
+					r = doubleJunctions[index]
+				} else {
+					r = junctions[index]
+				}
+			case r == '─' && double:
+				r = '═'
+			}
+			b.WriteRune(r)
+		}
+		lines = append(lines, strings.TrimRight(b.String(), " "))
+	}
+	return strings.Join(lines, "\n") + "\n"
+}
+
+func (t *Translator) VisitTarget(node *rst.Element)  {}
+func (t *Translator) DepartTarget(node *rst.Element) {}
+
+func (t *Translator) VisitTerm(node *rst.Element) {
+	if node.Parent().Index(node) > 0 {
+		t.Write("\n")
+	}
+	t.openStyle(bold)
+}
+
+func (t *Translator) DepartTerm(node *rst.Element) {
+	t.closeStyle()
+}
+
+/*
+   Write the title `node` underlined (and overlined if `overline`) with
+   `adornment`.
+*/
+func (t *Translator) writeTitle(node *rst.Element, overline bool, adornment string) error {
+	text, err := t.renderInline(node)
+	if err != nil {
+		return err
+	}
+	line := strings.Repeat(adornment, Width(text))
+	text = t.styled(text, bold)
+	if overline {
+		t.WriteBlock(line + "\n" + text + "\n" + line + "\n")
+	} else {
+		t.WriteBlock(text + "\n" + line + "\n")
+	}
+	return &rst.SkipNode{}
+}
+
+func (t *Translator) VisitTitle(node *rst.Element) error {
+	parent := node.Parent()
+	switch parent.TagName() {
+	case "document":
+		return t.writeTitle(node, true, "=")
+	case "section":
+		level := t.sectionLevel - 1
+		if level >= len(adornments) {
+			level = len(adornments) - 1
+		}
+		return t.writeTitle(node, false, adornments[level])
+	case "topic":
+		text, err := t.renderInline(node)
+		if err != nil {
+			return err
+		}
+		t.WriteBlock(t.styled(text, bold) + "\n")
+		return &rst.SkipNode{}
+	}
+	text, err := t.renderInline(node)
+	if err != nil {
+		return err
+	}
+	t.titles[parent] = text
+	return &rst.SkipNode{}
+}
+
+func (t *Translator) VisitTitleReference(node *rst.Element) {
+	t.openStyle(italic)
+}
+
+func (t *Translator) DepartTitleReference(node *rst.Element) {
+	t.closeStyle()
+}
+
+func (t *Translator) VisitTopic(node *rst.Element)  {}
+func (t *Translator) DepartTopic(node *rst.Element) {}
+
+func (t *Translator) VisitTransition(node *rst.Element) error {
+	text := "* * *"
+	if width := t.width(); width > 0 {
+		text = strings.Repeat(" ", (width-utf8.RuneCountInString(text))/2) + text
+	}
+	t.WriteBlock(text + "\n")
+	return &rst.SkipNode{}
+}
+
+func (t *Translator) VisitVersion(node *rst.Element) {
+	t.visitDocinfoItem(node)
+}
+
+func (t *Translator) DepartVersion(node *rst.Element) {
+	t.departDocinfoItem(node)
+}
+
+type NotImplementedError struct {
+	msg string
+}
+
+func (e *NotImplementedError) Error() string {
+	return e.msg
+}
@@ -0,0 +1,186 @@
+package text
+
+import (
+	"testing"
+
+	rst "github.com/siongui/go-rst"
+	"github.com/siongui/go-rst/parsers/docutilsxml"
+)
+
+const input = `<document source="help.rst">
+    <title>gorst</title>
+    <docinfo>
+        <version>1.0</version>
+        <field><field_name>Topic</field_name><field_body><paragraph>conversion</paragraph></field_body></field>
+    </docinfo>
+    <section>
+        <title>Usage</title>
+        <paragraph>Run <literal>gorst html</literal> to convert a document, see <reference refuri="https://example.com/docs">the docs</reference> for <emphasis>all</emphasis> options.<footnote_reference refid="f1">1</footnote_reference></paragraph>
+        <option_list>
+            <option_list_item>
+                <option_group>
+                    <option><option_string>-o</option_string></option>
+                    <option><option_string>--output</option_string><option_argument delimiter="=">file</option_argument></option>
+                </option_group>
+                <description><paragraph>Write the output to file.</paragraph></description>
+            </option_list_item>
+        </option_list>
+        <bullet_list>
+            <list_item>
+                <paragraph>first</paragraph>
+                <bullet_list><list_item><paragraph>nested</paragraph></list_item></bullet_list>
+            </list_item>
+            <list_item><paragraph>second</paragraph></list_item>
+        </bullet_list>
+        <enumerated_list enumtype="arabic" suffix=".">
+            <list_item><paragraph>one</paragraph></list_item>
+            <list_item><paragraph>two</paragraph></list_item>
+        </enumerated_list>
+        <literal_block>gorst html in.rst
+</literal_block>
+        <table>
+            <tgroup cols="2">
+                <thead>
+                    <row><entry><paragraph>Format</paragraph></entry><entry><paragraph>Writer</paragraph></entry></row>
+                </thead>
+                <tbody>
+                    <row><entry><paragraph>html</paragraph></entry><entry><paragraph>HTML5</paragraph></entry></row>
+                    <row><entry morecols="1"><paragraph>spanning</paragraph></entry></row>
+                </tbody>
+            </tgroup>
+        </table>
+        <definition_list>
+            <definition_list_item><term>term</term><definition><paragraph>Definition.</paragraph></definition></definition_list_item>
+        </definition_list>
+        <note><paragraph>Careful.</paragraph></note>
+        <footnote><label>1</label><paragraph>A note.</paragraph></footnote>
+    </section>
+</document>
+`
+
+func TestWriter(t *testing.T) {
+	settings := &rst.Settings{}
+	settings.Init()
+	settings.TextWidth = 40
+	document, err := docutilsxml.ParseDocument(input, "", settings)
+	if err != nil {
+		t.Fatal(err)
+	}
+	writer := &Writer{}
+	output, err := writer.Write(document)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := `=====
+gorst
+=====
+
+Version: 1.0
+Topic:   conversion
+
+Usage
+=====
+
+Run gorst html to convert a document,
+see the docs <https://example.com/docs>
+for all options.[1]
+
+-o, --output=file  Write the output to
+                   file.
+
+• first
+
+  ◦ nested
+
+• second
+
+1. one
+2. two
+
+    gorst html in.rst
+
+┌────────┬────────┐
+│ Format │ Writer │
+╞════════╪════════╡
+│ html   │ HTML5  │
+├────────┴────────┤
+│ spanning        │
+└─────────────────┘
+
+term
+    Definition.
+
+Note:
+    Careful.
+
+────────────────────
+
+[1] A note.
+`
+	if output != expected {
+		t.Errorf("unexpected output:\n%s", output)
+	}
+	if !writer.Supports("text") || writer.Supports("html") {
+		t.Error("wrong supported formats")
+	}
+}
+
+func TestAnsiStyles(t *testing.T) {
+	settings := &rst.Settings{}
+	settings.Init()
+	settings.TextWidth = 12
+	settings.AnsiStyles = true
+	document, err := docutilsxml.ParseDocument("<document><paragraph>A <strong>very <emphasis>long</emphasis> text</strong></paragraph></document>", "help.rst", settings)
+	if err != nil {
+		t.Fatal(err)
+	}
+	output, err := (&Writer{}).Write(document)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := "A \x1b[1mvery \x1b[3mlong\x1b[0m\x1b[1m\x1b[0m\n" +
+		"\x1b[1mtext\x1b[0m\n"
+	if output != expected {
+		t.Errorf("unexpected output: %q", output)
+	}
+}
+
+func TestWidth(t *testing.T) {
+	for text, expected := range map[string]int{
+		"abc":                3,
+		"\x1b[1mbold\x1b[0m": 4,
+		"日本":                 4,
+	} {
+		if width := Width(text); width != expected {
+			t.Errorf("Width(%q) = %d, expected %d", text, width, expected)
+		}
+	}
+}
+
+// Malformed trees, e.g. parsed from Docutils XML, are written without panics.
+func TestMalformed(t *testing.T) {
+	settings := &rst.Settings{}
+	settings.Init()
+	settings.WarningStream = nil
+	input := `<document>
+    <option_list><option_list_item/><option_list_item><description><paragraph>Only a description.</paragraph></description></option_list_item></option_list>
+    <table><tgroup cols="1"><tbody><row><entry morerows="2"><paragraph>A</paragraph></entry></row></tbody></tgroup></table>
+</document>`
+	document, err := docutilsxml.ParseDocument(input, "test.xml", settings)
+	if err != nil {
+		t.Fatal(err)
+	}
+	output, err := (&Writer{}).Write(document)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := `    Only a description.
+
+┌───┐
+│ A │
+└───┘
+`
+	if output != expected {
+		t.Errorf("unexpected output:\n%s", output)
+	}
+}
@@ -0,0 +1,108 @@
+/*
+Package writers holds the Writer interface of Python docutils, and the
+output buffers and table layout shared by the text-based writers; the
+writers themselves live in subpackages (writers/html, ...).
+
+URL of Python source code:
+http://sourceforge.net/p/docutils/code/HEAD/tree/trunk/docutils/docutils/writers/__init__.py
+*/
+package writers
+
+import (
+	"strings"
+
+	rst "github.com/siongui/go-rst"
+	"github.com/siongui/go-rst/transforms"
+)
+
+/*
+   Abstract base type for docutils Writers.
+
+   Each writer module or package must export a type implementing `Writer`.
+   Writers translate a document tree into a specific output format
+   (`Supports()` tells which formats).
+
+   Writers are transform components: `GetTransforms()` returns the
+   transforms to apply to the document before writing it.
+
+   `Write()` processes a document into its final form: it translates the
+   document and returns the output. `Parts()` returns the document parts
+   of the last `Write()`: a mapping of part name to string. Every writer
+   provides at least "whole" (the complete output) and "encoding".
+*/
+type Writer interface {
+	rst.TransformSpec
+	Supports(format string) bool
+	Write(document *rst.Document) (string, error)
+	Parts() map[string]string
+}
+
+/*
+   Writer parts common to all writers, to embed in writer types.
+
+   `AssembleParts()` fills in the "whole" and "encoding" parts; writers
+   with more parts extend it.
+*/
+type Base struct {
+	// The document to write (set by `Write()`).
+	Document *rst.Document
+
+	// Final translated form of `Document`.
+	Output string
+
+	// Mapping of document part names to fragments of `Output`.
+	parts map[string]string
+}
+
+/*
+   Return the transforms all writers need: system messages generated by
+   transforms are placed in the document, then the messages below the
+   report level are removed.
+*/
+func (w *Base) GetTransforms() []rst.Transform {
+	return []rst.Transform{&transforms.Messages{}, &transforms.FilterMessages{}}
+}
+
+// Assemble the `Output` and the parts into `Parts()`.
+func (w *Base) AssembleParts() {
+	w.parts = map[string]string{
+		"whole":    w.Output,
+		"encoding": "utf-8",
+	}
+}
+
+// Set the named document part.
+func (w *Base) SetPart(name, value string) {
+	if w.parts == nil {
+		w.parts = map[string]string{}
+	}
+	w.parts[name] = value
+}
+
+// Return the document parts of the last `Write()`.
+func (w *Base) Parts() map[string]string {
+	return w.parts
+}
+
+/*
+   Is the `raw` element `node` meant for one of `formats`? Writers output
+   raw content only for their own formats and skip it otherwise. The
+   "format" attribute holds whitespace-separated (lowercase) format names.
+
+   Raw content is dropped unless the "RawEnabled" setting of the document
+   is true: documents not produced by the reST parser (e.g. Docutils XML
+   input) may hold raw elements the "raw" directive would have rejected.
+*/
+func RawFormatMatches(node *rst.Element, formats ...string) bool {
+	if document := node.Document(); document == nil || document.Settings() == nil || !document.Settings().RawEnabled {
+		return false
+	}
+	for _, format := range strings.Fields(node.Get("format")) {
+		for _, f := range formats {
+			if format == f {
+				return true
+			}
+		}
+	}
+	return false
+}